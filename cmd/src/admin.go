@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+var adminCommands commander
+
+func init() {
+	usage := `'src admin' is a tool that administers a Sourcegraph instance. It requires a site admin.
+
+Usage:
+
+	src admin command [command options]
+
+The commands are:
+
+	config                manages the site configuration
+	outbound-requests     inspects the log of requests to code hosts
+	upgrade-check         checks the instance for blockers of an upgrade
+	users                 reports on the activity of users
+
+Use "src admin [command] -h" for more information about a command.
+`
+
+	flagSet := flag.NewFlagSet("admin", flag.ExitOnError)
+	handler := func(args []string) error {
+		adminCommands.run(flagSet, "src admin", usage, args)
+		return nil
+	}
+
+	// Register the command.
+	commands = append(commands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Println(usage)
+		},
+	})
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/siteconfig"
+)
+
+var adminConfigCommands commander
+
+func init() {
+	usage := `'src admin config' is a tool that manages the site configuration of a Sourcegraph instance. It requires a site admin.
+
+Changes are shown as a diff and confirmed before they're written. They're only
+written if nobody else changed the site configuration in the meantime, so that
+automation doesn't overwrite concurrent edits in the web UI. The instance
+validates the new site configuration against its schema and rejects it if it's
+invalid.
+
+Usage:
+
+	src admin config command [command options]
+
+The commands are:
+
+	edit       edits the site configuration in $EDITOR
+	patch      applies a JSON Patch to the site configuration
+
+Use "src admin config [command] -h" for more information about a command.
+`
+
+	flagSet := flag.NewFlagSet("config", flag.ExitOnError)
+	handler := func(args []string) error {
+		adminConfigCommands.run(flagSet, "src admin config", usage, args)
+		return nil
+	}
+
+	// Register the command.
+	adminCommands = append(adminCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Println(usage)
+		},
+	})
+}
+
+// SiteConfiguration is the site configuration of an instance. ID increases
+// with every change.
+type SiteConfiguration struct {
+	ID                 int
+	EffectiveContents  string
+	ValidationMessages []string
+}
+
+func getSiteConfiguration(ctx context.Context, client api.Client) (*SiteConfiguration, error) {
+	query := `query SiteConfiguration {
+    site {
+        configuration {
+            id
+            effectiveContents
+            validationMessages
+        }
+    }
+}`
+
+	var result struct {
+		Site struct {
+			Configuration *SiteConfiguration
+		}
+	}
+	if ok, err := client.NewQuery(query).Do(ctx, &result); err != nil || !ok {
+		return nil, err
+	}
+	if result.Site.Configuration == nil {
+		return nil, errors.New("the site configuration can only be read by site admins")
+	}
+	return result.Site.Configuration, nil
+}
+
+// siteConfigurationWrite is a change of the site configuration.
+type siteConfigurationWrite struct {
+	client api.Client
+	out    io.Writer
+
+	// dryRun only prints the diff.
+	dryRun bool
+	// yes writes the change without asking for confirmation on in, which is
+	// nil if stdin isn't a terminal.
+	yes bool
+	in  io.Reader
+}
+
+// write writes contents as the new site configuration, if it differs from the
+// current one, which must be the one with the ID of current. It prints the
+// diff and asks for confirmation first.
+func (w *siteConfigurationWrite) write(ctx context.Context, current *SiteConfiguration, contents string) error {
+	if err := siteconfig.Validate(contents); err != nil {
+		return errors.Wrap(err, "new site configuration")
+	}
+
+	diff := siteconfig.Diff("site configuration", "new site configuration", current.EffectiveContents, contents)
+	if diff == "" {
+		fmt.Fprintln(w.out, "The site configuration is unchanged.")
+		return nil
+	}
+	fmt.Fprint(w.out, diff)
+	if w.dryRun {
+		return nil
+	}
+
+	if !w.yes {
+		if w.in == nil {
+			return errors.New("stdin isn't a terminal, use -yes to write the site configuration without confirmation")
+		}
+		fmt.Fprint(w.out, "Write the new site configuration? [y/N] ")
+		answer, err := bufio.NewReader(w.in).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+		default:
+			fmt.Fprintln(w.out, "Nothing was written.")
+			return nil
+		}
+	}
+
+	// Catch changes made while the user was editing or confirming. The
+	// instance checks lastID too, which closes the remaining window.
+	latest, err := getSiteConfiguration(ctx, w.client)
+	if err != nil {
+		return err
+	}
+	if latest.ID != current.ID {
+		return errors.Newf("the site configuration was changed by someone else since it was read (version %d, now %d), nothing was written: run the command again", current.ID, latest.ID)
+	}
+
+	query := `mutation UpdateSiteConfiguration($lastID: Int!, $input: String!) {
+    updateSiteConfiguration(lastID: $lastID, input: $input)
+}`
+	var result struct {
+		UpdateSiteConfiguration bool
+	}
+	if ok, err := w.client.NewRequest(query, map[string]interface{}{
+		"lastID": current.ID,
+		"input":  contents,
+	}).Do(ctx, &result); err != nil || !ok {
+		return err
+	}
+	fmt.Fprintln(w.out, "The site configuration was written.")
+	if result.UpdateSiteConfiguration {
+		fmt.Fprintln(w.out, "Some of the changes only take effect after Sourcegraph is restarted.")
+	}
+
+	written, err := getSiteConfiguration(ctx, w.client)
+	if err != nil {
+		return err
+	}
+	for _, msg := range written.ValidationMessages {
+		fmt.Fprintf(w.out, "Warning: %s\n", msg)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/cockroachdb/errors"
+	"github.com/kballard/go-shellquote"
+	"github.com/mattn/go-isatty"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+)
+
+func init() {
+	usage := `
+'src admin config edit' opens the site configuration in the editor given by
+$VISUAL or $EDITOR, or vi, and writes the changes when the editor exits. If the
+edited site configuration isn't valid JSON, nothing is written and the edited
+file is kept.
+
+Examples:
+
+  Edit the site configuration:
+
+    	$ src admin config edit
+
+  Edit the site configuration in VS Code:
+
+    	$ EDITOR='code --wait' src admin config edit
+
+`
+
+	flagSet := flag.NewFlagSet("edit", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src admin config %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		dryRunFlag = flagSet.Bool("dry-run", false, "Only show the changes.")
+		yesFlag    = flagSet.Bool("yes", false, "Write the changes without asking for confirmation.")
+		apiFlags   = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+
+		current, err := getSiteConfiguration(ctx, client)
+		if err != nil {
+			return err
+		}
+
+		dir, err := os.MkdirTemp("", "src-site-config-")
+		if err != nil {
+			return err
+		}
+		file := filepath.Join(dir, "site-config.json")
+		if err := os.WriteFile(file, []byte(current.EffectiveContents), 0600); err != nil {
+			return err
+		}
+
+		if err := runEditor(file); err != nil {
+			os.RemoveAll(dir)
+			return err
+		}
+		edited, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		w := &siteConfigurationWrite{
+			client: client,
+			out:    os.Stdout,
+			dryRun: *dryRunFlag,
+			yes:    *yesFlag,
+		}
+		if isatty.IsTerminal(os.Stdin.Fd()) {
+			w.in = os.Stdin
+		}
+		if err := w.write(ctx, current, string(edited)); err != nil {
+			return errors.Wrapf(err, "the edited site configuration is kept in %s", file)
+		}
+		return os.RemoveAll(dir)
+	}
+
+	// Register the command.
+	adminConfigCommands = append(adminConfigCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
+
+// runEditor opens file in the editor of the user and waits until it exits.
+func runEditor(file string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	args, err := shellquote.Split(editor)
+	if err != nil || len(args) == 0 {
+		return errors.Newf("invalid editor %q", editor)
+	}
+
+	cmd := exec.Command(args[0], append(args[1:], file)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "running %s", editor)
+	}
+	return nil
+}
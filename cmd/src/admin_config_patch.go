@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mattn/go-isatty"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+	"github.com/sourcegraph/src-cli/internal/siteconfig"
+)
+
+func init() {
+	usage := `
+'src admin config patch' applies a JSON Patch (RFC 6902) to the site
+configuration. Comments in the site configuration are kept, except for those
+of removed properties. Use "test" operations to only apply the patch if the
+site configuration has the expected values.
+
+Examples:
+
+  Change the external URL if it has the expected value, without confirmation:
+
+    	$ src admin config patch -yes -value '[
+    	    {"op": "test", "path": "/externalURL", "value": "https://sourcegraph.example.com"},
+    	    {"op": "replace", "path": "/externalURL", "value": "https://sg.example.com"}
+    	  ]'
+
+  Show the changes of a patch file without applying it:
+
+    	$ src admin config patch -dry-run -value-file patch.json
+
+`
+
+	flagSet := flag.NewFlagSet("patch", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src admin config %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		valueFlag     = flagSet.String("value", "", "The JSON Patch to apply.")
+		valueFileFlag = flagSet.String("value-file", "", `Read the JSON Patch from this file instead of from -value ("-" for stdin).`)
+		dryRunFlag    = flagSet.Bool("dry-run", false, "Only show the changes.")
+		yesFlag       = flagSet.Bool("yes", false, "Write the changes without asking for confirmation.")
+		apiFlags      = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		var data []byte
+		switch {
+		case *valueFlag != "" && *valueFileFlag != "":
+			return cmderrors.Usage("only one of -value and -value-file can be used")
+		case *valueFlag != "":
+			data = []byte(*valueFlag)
+		case *valueFileFlag == "-":
+			var err error
+			if data, err = io.ReadAll(os.Stdin); err != nil {
+				return err
+			}
+		case *valueFileFlag != "":
+			var err error
+			if data, err = os.ReadFile(*valueFileFlag); err != nil {
+				return err
+			}
+		default:
+			return cmderrors.Usage("either -value or -value-file must be used")
+		}
+
+		patch, err := siteconfig.ParsePatch(data)
+		if err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+
+		current, err := getSiteConfiguration(ctx, client)
+		if err != nil {
+			return err
+		}
+		contents, err := siteconfig.ApplyPatch(current.EffectiveContents, patch)
+		if err != nil {
+			return err
+		}
+
+		w := &siteConfigurationWrite{
+			client: client,
+			out:    os.Stdout,
+			dryRun: *dryRunFlag,
+			yes:    *yesFlag,
+		}
+		if *valueFileFlag != "-" && isatty.IsTerminal(os.Stdin.Fd()) {
+			w.in = os.Stdin
+		}
+		return w.write(ctx, current, contents)
+	}
+
+	// Register the command.
+	adminConfigCommands = append(adminConfigCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
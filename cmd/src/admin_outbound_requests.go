@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gobwas/glob"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+var adminOutboundRequestsCommands commander
+
+func init() {
+	usage := `'src admin outbound-requests' is a tool that inspects the log of the requests a Sourcegraph instance makes to code hosts and other external services. It requires a site admin, and the log must be enabled with the outboundRequestLogLimit site configuration setting.
+
+Usage:
+
+	src admin outbound-requests command [command options]
+
+The commands are:
+
+	tail       prints the logged requests, and follows new ones
+
+Use "src admin outbound-requests [command] -h" for more information about a command.
+`
+
+	flagSet := flag.NewFlagSet("outbound-requests", flag.ExitOnError)
+	handler := func(args []string) error {
+		adminOutboundRequestsCommands.run(flagSet, "src admin outbound-requests", usage, args)
+		return nil
+	}
+
+	// Register the command.
+	adminCommands = append(adminCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Println(usage)
+		},
+	})
+}
+
+func init() {
+	usage := `
+Examples:
+
+  Print the last 20 logged requests:
+
+    	$ src admin outbound-requests tail
+
+  Follow the requests to GitHub that were rate limited or failed:
+
+    	$ src admin outbound-requests tail -follow -host='*github.com' -status=429,5xx,error
+
+  Print the response headers of failed requests:
+
+    	$ src admin outbound-requests tail -status=4xx,5xx -f='{{.URL}}: {{.StatusCode}} {{.ResponseHeaders|json}}'
+
+`
+
+	flagSet := flag.NewFlagSet("tail", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src admin outbound-requests %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		nFlag        = flagSet.Int("n", 20, "Print the last n logged requests matching the filters before following. (use -1 for all)")
+		followFlag   = flagSet.Bool("follow", false, "Keep printing new requests as they're logged.")
+		intervalFlag = flagSet.Duration("interval", 2*time.Second, "How often to fetch new requests with -follow.")
+		hostFlag     = flagSet.String("host", "", `Only print requests to hosts matching this glob pattern. (e.g. "*.github.com")`)
+		statusFlag   = flagSet.String("status", "", `Only print requests with these comma-separated status codes or classes, or "error" for requests without a response. (e.g. "429,5xx,error")`)
+		methodFlag   = flagSet.String("method", "", `Only print requests with this HTTP method. (e.g. "POST")`)
+		formatFlag   = flagSet.String("f", "{{.StartedAt}} {{.Method}} {{.StatusCode}} {{.DurationMs}}ms {{.URL}}{{with .ErrorMessage}} {{.}}{{end}}", `Format for the output, using the syntax of Go package text/template. (e.g. "{{.URL}}" or "{{.|json}}")`)
+		apiFlags     = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if *intervalFlag <= 0 {
+			return cmderrors.Usage("-interval must be positive")
+		}
+
+		filter, err := parseOutboundRequestFilter(*hostFlag, *statusFlag, *methodFlag)
+		if err != nil {
+			return cmderrors.Usage(err.Error())
+		}
+		tmpl, err := parseTemplate(*formatFlag)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+
+		requests, err := listOutboundRequests(ctx, client, nil)
+		if err != nil {
+			return err
+		}
+		var last *string
+		if len(requests) > 0 {
+			last = &requests[len(requests)-1].ID
+		}
+
+		matching := filter.apply(requests)
+		if *nFlag >= 0 && len(matching) > *nFlag {
+			matching = matching[len(matching)-*nFlag:]
+		}
+		for _, r := range matching {
+			if err := execTemplate(tmpl, r); err != nil {
+				return err
+			}
+		}
+		if !*followFlag {
+			return nil
+		}
+
+		ticker := time.NewTicker(*intervalFlag)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+
+			requests, err := listOutboundRequests(ctx, client, last)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+			if len(requests) > 0 {
+				last = &requests[len(requests)-1].ID
+			}
+			for _, r := range filter.apply(requests) {
+				if err := execTemplate(tmpl, r); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// Register the command.
+	adminOutboundRequestsCommands = append(adminOutboundRequestsCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
+
+type OutboundRequest struct {
+	ID              string
+	StartedAt       time.Time
+	Method          string
+	URL             string
+	RequestHeaders  []HTTPHeader
+	StatusCode      int
+	ResponseHeaders []HTTPHeader
+	DurationMs      int
+	ErrorMessage    string
+}
+
+type HTTPHeader struct {
+	Name   string
+	Values []string
+}
+
+// listOutboundRequests returns the logged outbound requests after the one with
+// the given ID, or all of them if after is nil, oldest first.
+func listOutboundRequests(ctx context.Context, client api.Client, after *string) ([]*OutboundRequest, error) {
+	query := `query OutboundRequests($after: String) {
+    outboundRequests(after: $after) {
+        nodes {
+            id
+            startedAt
+            method
+            url
+            requestHeaders {
+                name
+                values
+            }
+            statusCode
+            responseHeaders {
+                name
+                values
+            }
+            durationMs
+            errorMessage
+        }
+    }
+}`
+
+	var result struct {
+		OutboundRequests struct {
+			Nodes []*OutboundRequest
+		}
+	}
+	if ok, err := client.NewRequest(query, map[string]interface{}{
+		"after": after,
+	}).Do(ctx, &result); err != nil || !ok {
+		return nil, err
+	}
+	return result.OutboundRequests.Nodes, nil
+}
+
+// outboundRequestFilter selects outbound requests by the flags of 'src admin
+// outbound-requests tail'. Nil or empty fields match all requests.
+type outboundRequestFilter struct {
+	host glob.Glob
+	// statuses are status codes, classes such as 5xx, or "error".
+	statuses []string
+	method   string
+}
+
+func parseOutboundRequestFilter(host, statuses, method string) (*outboundRequestFilter, error) {
+	f := &outboundRequestFilter{method: strings.ToUpper(method)}
+	if host != "" {
+		g, err := glob.Compile(strings.ToLower(host))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid -host pattern")
+		}
+		f.host = g
+	}
+	for _, s := range strings.Split(statuses, ",") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "" {
+			continue
+		}
+		if !validStatusFilter(s) {
+			return nil, errors.Newf("invalid -status %q: must be a status code, a class such as 5xx, or error", s)
+		}
+		f.statuses = append(f.statuses, s)
+	}
+	return f, nil
+}
+
+func validStatusFilter(s string) bool {
+	if s == "error" {
+		return true
+	}
+	if len(s) == 3 && strings.HasSuffix(s, "xx") {
+		return s[0] >= '1' && s[0] <= '5'
+	}
+	code, err := strconv.Atoi(s)
+	return err == nil && code >= 100 && code <= 599
+}
+
+func (f *outboundRequestFilter) apply(requests []*OutboundRequest) []*OutboundRequest {
+	var matching []*OutboundRequest
+	for _, r := range requests {
+		if f.matches(r) {
+			matching = append(matching, r)
+		}
+	}
+	return matching
+}
+
+func (f *outboundRequestFilter) matches(r *OutboundRequest) bool {
+	if f.method != "" && r.Method != f.method {
+		return false
+	}
+	if f.host != nil {
+		u, err := url.Parse(r.URL)
+		if err != nil || !f.host.Match(strings.ToLower(u.Hostname())) {
+			return false
+		}
+	}
+	if len(f.statuses) == 0 {
+		return true
+	}
+	for _, s := range f.statuses {
+		switch {
+		case s == "error":
+			if r.StatusCode == 0 || r.ErrorMessage != "" {
+				return true
+			}
+		case strings.HasSuffix(s, "xx"):
+			if r.StatusCode/100 == int(s[0]-'0') {
+				return true
+			}
+		default:
+			if strconv.Itoa(r.StatusCode) == s {
+				return true
+			}
+		}
+	}
+	return false
+}
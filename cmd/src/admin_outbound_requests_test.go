@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOutboundRequestFilter(t *testing.T) {
+	requests := []*OutboundRequest{
+		{ID: "1", Method: "GET", URL: "https://api.github.com/repos/a/b", StatusCode: 200},
+		{ID: "2", Method: "GET", URL: "https://api.github.com/graphql", StatusCode: 429},
+		{ID: "3", Method: "POST", URL: "https://gitlab.com/api/v4/projects", StatusCode: 502},
+		{ID: "4", Method: "GET", URL: "https://bitbucket.example.com/rest", ErrorMessage: "dial tcp: i/o timeout"},
+	}
+
+	for name, tc := range map[string]struct {
+		host, status, method string
+		want                 []string
+	}{
+		"all":          {want: []string{"1", "2", "3", "4"}},
+		"host":         {host: "*.github.com", want: []string{"1", "2"}},
+		"host case":    {host: "GITLAB.com", want: []string{"3"}},
+		"status":       {status: "429", want: []string{"2"}},
+		"status class": {status: "5xx, error", want: []string{"3", "4"}},
+		"method":       {method: "post", want: []string{"3"}},
+		"combined":     {host: "*.github.com", status: "4xx", method: "GET", want: []string{"2"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			f, err := parseOutboundRequestFilter(tc.host, tc.status, tc.method)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var have []string
+			for _, r := range f.apply(requests) {
+				have = append(have, r.ID)
+			}
+			if diff := cmp.Diff(tc.want, have); diff != "" {
+				t.Errorf("wrong requests (-want +have):\n%s", diff)
+			}
+		})
+	}
+
+	for _, status := range []string{"600", "6xx", "4x", "timeout"} {
+		if _, err := parseOutboundRequestFilter("", status, ""); err == nil {
+			t.Errorf("unexpected nil error for status %q", status)
+		}
+	}
+}
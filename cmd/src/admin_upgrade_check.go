@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+	"github.com/sourcegraph/src-cli/internal/upgradecheck"
+)
+
+func init() {
+	usage := `
+'src admin upgrade-check' checks a Sourcegraph instance for known blockers of an
+upgrade to another version, and prints a go/no-go report for upgrade runbooks.
+It requires a site admin.
+
+The checks are:
+
+  version                   the upgrade goes forward, and whether it skips
+                            minor versions, which requires a multi-version
+                            upgrade with the migrator
+  out-of-band migrations    the migrations that the new version no longer
+                            supports unmigrated data of are complete
+  deprecated configuration  the site configuration and the code host
+                            connections don't use settings that were removed
+  auth providers            all auth providers are supported
+  database schema           the database schema doesn't drift from the
+                            schema of the current version
+
+The command exits with status 1 if the upgrade must not go ahead, so that it
+can gate upgrade automation.
+
+Usage:
+
+	src admin upgrade-check -to VERSION [command options]
+
+Examples:
+
+	$ src admin upgrade-check -to 3.41.0
+
+	$ src admin upgrade-check -to 4.0.0 -json
+
+`
+
+	flagSet := flag.NewFlagSet("upgrade-check", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src admin %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		toFlag   = flagSet.String("to", "", "The version to upgrade to. (required)")
+		jsonFlag = flagSet.Bool("json", false, "Print the report as JSON.")
+		apiFlags = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+		if *toFlag == "" {
+			return cmderrors.Usage("-to is required")
+		}
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+
+		instance, err := getUpgradeCheckInstance(ctx, client)
+		if err != nil {
+			return err
+		}
+		report, err := upgradecheck.Check(instance, *toFlag)
+		if err != nil {
+			return cmderrors.Usage(err.Error())
+		}
+
+		if *jsonFlag {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(report); err != nil {
+				return err
+			}
+		} else {
+			printUpgradeCheckReport(os.Stdout, report)
+		}
+
+		if !report.Ready {
+			return cmderrors.ExitCode(1, nil)
+		}
+		return nil
+	}
+
+	// Register the command.
+	adminCommands = append(adminCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
+
+// getUpgradeCheckInstance queries what the upgrade checks need to know about
+// the instance.
+func getUpgradeCheckInstance(ctx context.Context, client api.Client) (*upgradecheck.Instance, error) {
+	query := `query UpgradeCheck {
+    site {
+        productVersion
+    }
+    externalServices(first: 99999) {
+        nodes {
+            kind
+            displayName
+            config
+        }
+    }
+    outOfBandMigrations {
+        id
+        team
+        component
+        description
+        introduced
+        deprecated
+        progress
+        applyReverse
+        errors {
+            message
+        }
+    }
+}`
+
+	var result struct {
+		Site struct {
+			ProductVersion string
+		}
+		ExternalServices struct {
+			Nodes []upgradecheck.ExternalService
+		}
+		OutOfBandMigrations []upgradecheck.Migration
+	}
+	if ok, err := client.NewQuery(query).Do(ctx, &result); err != nil || !ok {
+		return nil, err
+	}
+
+	siteConfig, err := getSiteConfiguration(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &upgradecheck.Instance{
+		Version:          result.Site.ProductVersion,
+		SiteConfig:       siteConfig.EffectiveContents,
+		ExternalServices: result.ExternalServices.Nodes,
+		Migrations:       result.OutOfBandMigrations,
+		SchemaDrift:      getSchemaDrift(ctx, client),
+	}, nil
+}
+
+// getSchemaDrift returns whether the database schema drifts, or nil if the
+// instance is too old to report it.
+func getSchemaDrift(ctx context.Context, client api.Client) *bool {
+	query := `query UpgradeReadiness {
+    site {
+        upgradeReadiness {
+            schemaDrift
+        }
+    }
+}`
+
+	var result struct {
+		Site struct {
+			UpgradeReadiness *struct {
+				SchemaDrift string
+			}
+		}
+	}
+	if ok, err := client.NewQuery(query).Do(ctx, &result); err != nil || !ok || result.Site.UpgradeReadiness == nil {
+		return nil
+	}
+	drift := strings.TrimSpace(result.Site.UpgradeReadiness.SchemaDrift) != ""
+	return &drift
+}
+
+func printUpgradeCheckReport(w io.Writer, report *upgradecheck.Report) {
+	verdict := "GO"
+	if !report.Ready {
+		verdict = "NO-GO"
+	}
+	fmt.Fprintf(w, "Upgrade from %s to %s: %s\n\n", report.From, report.To, verdict)
+
+	for _, r := range report.Results {
+		fmt.Fprintf(w, "  %-4s  %s: %s\n", strings.ToUpper(string(r.Status)), r.Check, r.Summary)
+		for _, d := range r.Details {
+			fmt.Fprintf(w, "          - %s\n", d)
+		}
+	}
+}
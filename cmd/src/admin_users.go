@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+var adminUsersCommands commander
+
+func init() {
+	usage := `'src admin users' is a tool that reports on the users of a Sourcegraph instance. It requires a site admin.
+
+Usage:
+
+	src admin users command [command options]
+
+The commands are:
+
+	activity     exports the activity of users and the licensed seat usage as CSV
+
+Use "src admin users [command] -h" for more information about a command.
+`
+
+	flagSet := flag.NewFlagSet("users", flag.ExitOnError)
+	handler := func(args []string) error {
+		adminUsersCommands.run(flagSet, "src admin users", usage, args)
+		return nil
+	}
+
+	// Register the command.
+	adminCommands = append(adminCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Println(usage)
+		},
+	})
+}
+
+func init() {
+	usage := `
+'src admin users activity' exports every user of an instance as a CSV row with
+the time they were last active, whether that is within the -since period, and
+their all-time event counts, for license true-ups and reclaiming seats. A
+summary of the licensed seat usage is printed to stderr.
+
+All users are fetched in pages, which takes a while on large instances.
+
+Examples:
+
+  Export the activity of all users over the last 90 days:
+
+    	$ src admin users activity -since=90d > activity.csv
+
+  Export the activity since the start of the license term to a file:
+
+    	$ src admin users activity -since=2021-07-01 -o activity.csv
+
+`
+
+	flagSet := flag.NewFlagSet("activity", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src admin users %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		sinceFlag = flagSet.String("since", "90d", `Users last active within this period count as active, as a number of days or weeks, a duration, or a date. (e.g. "90d", "12w", "720h" or "2021-07-01")`)
+		outFlag   = flagSet.String("o", "", "Write the CSV to this file instead of stdout.")
+		apiFlags  = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if flagSet.NArg() != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+		since, err := parseSince(*sinceFlag, time.Now())
+		if err != nil {
+			return cmderrors.Usage(err.Error())
+		}
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+
+		users, err := listUserActivity(ctx, client)
+		if err != nil {
+			return err
+		}
+		seats, err := getLicensedSeats(ctx, client)
+		if err != nil {
+			return err
+		}
+
+		out := io.Writer(os.Stdout)
+		if *outFlag != "" {
+			f, err := os.Create(*outFlag)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+		if err := writeUserActivityCSV(out, users, since); err != nil {
+			return err
+		}
+		printSeatUsage(os.Stderr, users, since, seats)
+		return nil
+	}
+
+	// Register the command.
+	adminUsersCommands = append(adminUsersCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
+
+var sinceDaysPattern = regexp.MustCompile(`^(\d+)([dw])$`)
+
+// parseSince parses the -since flag of 'src admin users activity' into the
+// start of the period: a number of days or weeks such as 90d, a duration such
+// as 720h, or a date such as 2021-07-01.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if m := sinceDaysPattern.FindStringSubmatch(s); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return time.Time{}, errors.Wrapf(err, "invalid -since %q", s)
+		}
+		if m[2] == "w" {
+			n *= 7
+		}
+		return now.AddDate(0, 0, -n), nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		if d < 0 {
+			return time.Time{}, errors.Newf("invalid -since %q: must not be negative", s)
+		}
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errors.Newf("invalid -since %q: must be a number of days or weeks, a duration, or a date, such as 90d, 720h or 2021-07-01", s)
+}
+
+// userActivity is the activity of a user, as exported by 'src admin users
+// activity'.
+type userActivity struct {
+	Username     string
+	DisplayName  string
+	SiteAdmin    bool
+	CreatedAt    time.Time
+	PrimaryEmail *struct {
+		Email string
+	}
+	UsageStatistics struct {
+		LastActiveTime          *time.Time
+		SearchQueries           int
+		PageViews               int
+		CodeIntelligenceActions int
+	}
+}
+
+func listUserActivity(ctx context.Context, client api.Client) ([]*userActivity, error) {
+	query := `query UserActivity($first: Int!, $after: String) {
+    users(first: $first, after: $after) {
+        nodes {
+            username
+            displayName
+            siteAdmin
+            createdAt
+            primaryEmail {
+                email
+            }
+            usageStatistics {
+                lastActiveTime
+                searchQueries
+                pageViews
+                codeIntelligenceActions
+            }
+        }
+        pageInfo {
+            hasNextPage
+            endCursor
+        }
+    }
+}`
+
+	var (
+		users []*userActivity
+		after *string
+	)
+	for {
+		var result struct {
+			Users struct {
+				Nodes    []*userActivity
+				PageInfo struct {
+					HasNextPage bool
+					EndCursor   *string
+				}
+			}
+		}
+		if ok, err := client.NewRequest(query, map[string]interface{}{
+			"first": 500,
+			"after": after,
+		}).Do(ctx, &result); err != nil || !ok {
+			return nil, err
+		}
+
+		users = append(users, result.Users.Nodes...)
+		if !result.Users.PageInfo.HasNextPage {
+			return users, nil
+		}
+		after = result.Users.PageInfo.EndCursor
+	}
+}
+
+// getLicensedSeats returns the number of users the license of the instance
+// allows, or 0 if the instance has no license or it doesn't limit the users.
+func getLicensedSeats(ctx context.Context, client api.Client) (int, error) {
+	query := `query LicensedSeats {
+    site {
+        productSubscription {
+            license {
+                userCount
+            }
+        }
+    }
+}`
+
+	var result struct {
+		Site struct {
+			ProductSubscription struct {
+				License *struct {
+					UserCount int
+				}
+			}
+		}
+	}
+	if ok, err := client.NewQuery(query).Do(ctx, &result); err != nil || !ok {
+		return 0, err
+	}
+	if result.Site.ProductSubscription.License == nil {
+		return 0, nil
+	}
+	return result.Site.ProductSubscription.License.UserCount, nil
+}
+
+// writeUserActivityCSV writes a CSV row for each user, with a header.
+func writeUserActivityCSV(out io.Writer, users []*userActivity, since time.Time) error {
+	w := csv.NewWriter(out)
+	if err := w.Write([]string{"username", "email", "display_name", "site_admin", "created_at", "last_active_at", "active", "search_queries", "page_views", "code_intelligence_actions"}); err != nil {
+		return err
+	}
+	for _, u := range users {
+		var email, lastActive string
+		if u.PrimaryEmail != nil {
+			email = u.PrimaryEmail.Email
+		}
+		if t := u.UsageStatistics.LastActiveTime; t != nil {
+			lastActive = t.UTC().Format(time.RFC3339)
+		}
+		if err := w.Write([]string{
+			u.Username,
+			email,
+			u.DisplayName,
+			strconv.FormatBool(u.SiteAdmin),
+			u.CreatedAt.UTC().Format(time.RFC3339),
+			lastActive,
+			strconv.FormatBool(u.activeSince(since)),
+			strconv.Itoa(u.UsageStatistics.SearchQueries),
+			strconv.Itoa(u.UsageStatistics.PageViews),
+			strconv.Itoa(u.UsageStatistics.CodeIntelligenceActions),
+		}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func (u *userActivity) activeSince(since time.Time) bool {
+	t := u.UsageStatistics.LastActiveTime
+	return t != nil && !t.Before(since)
+}
+
+// printSeatUsage prints how many of the licensed seats are used, and by how
+// many users that weren't active in the period. Every user takes a seat,
+// whether they're active or not.
+func printSeatUsage(out io.Writer, users []*userActivity, since time.Time, seats int) {
+	active := 0
+	for _, u := range users {
+		if u.activeSince(since) {
+			active++
+		}
+	}
+	fmt.Fprintf(out, "%d users, %d active since %s, %d inactive.\n", len(users), active, since.Format("2006-01-02"), len(users)-active)
+	if seats == 0 {
+		fmt.Fprintln(out, "The license doesn't limit the number of users.")
+		return
+	}
+	fmt.Fprintf(out, "%d of %d licensed seats used (%.1f%%).\n", len(users), seats, float64(len(users))/float64(seats)*100)
+	if len(users) > seats {
+		fmt.Fprintf(out, "Over the licensed seats by %d.\n", len(users)-seats)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	for s, want := range map[string]time.Time{
+		"90d":        time.Date(2021, 7, 3, 12, 0, 0, 0, time.UTC),
+		"2w":         time.Date(2021, 9, 17, 12, 0, 0, 0, time.UTC),
+		"36h":        time.Date(2021, 9, 30, 0, 0, 0, 0, time.UTC),
+		"2021-07-01": time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		have, err := parseSince(s, now)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", s, err)
+		} else if !have.Equal(want) {
+			t.Errorf("%q: want %s, have %s", s, want, have)
+		}
+	}
+	for _, s := range []string{"", "90", "3mo", "-1h", "07/01/2021"} {
+		if _, err := parseSince(s, now); err == nil {
+			t.Errorf("%q: want error", s)
+		}
+	}
+}
+
+func TestUserActivityCSV(t *testing.T) {
+	var users []*userActivity
+	if err := json.Unmarshal([]byte(`[
+  {"username": "alice", "displayName": "Alice", "siteAdmin": true, "createdAt": "2020-01-01T00:00:00Z",
+   "primaryEmail": {"email": "alice@example.com"},
+   "usageStatistics": {"lastActiveTime": "2021-09-30T08:00:00Z", "searchQueries": 12, "pageViews": 40, "codeIntelligenceActions": 3}},
+  {"username": "bob", "displayName": "", "siteAdmin": false, "createdAt": "2020-02-01T00:00:00Z",
+   "primaryEmail": null,
+   "usageStatistics": {"lastActiveTime": "2021-01-15T08:00:00Z", "searchQueries": 1, "pageViews": 2, "codeIntelligenceActions": 0}},
+  {"username": "carol", "displayName": "Carol", "siteAdmin": false, "createdAt": "2021-09-01T00:00:00Z",
+   "primaryEmail": {"email": "carol@example.com"},
+   "usageStatistics": {"lastActiveTime": null, "searchQueries": 0, "pageViews": 0, "codeIntelligenceActions": 0}}
+]`), &users); err != nil {
+		t.Fatal(err)
+	}
+	since := time.Date(2021, 7, 3, 0, 0, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	if err := writeUserActivityCSV(&buf, users, since); err != nil {
+		t.Fatal(err)
+	}
+	want := `username,email,display_name,site_admin,created_at,last_active_at,active,search_queries,page_views,code_intelligence_actions
+alice,alice@example.com,Alice,true,2020-01-01T00:00:00Z,2021-09-30T08:00:00Z,true,12,40,3
+bob,,,false,2020-02-01T00:00:00Z,2021-01-15T08:00:00Z,false,1,2,0
+carol,carol@example.com,Carol,false,2021-09-01T00:00:00Z,,false,0,0,0
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("wrong CSV (-want +have):\n%s", diff)
+	}
+
+	buf.Reset()
+	printSeatUsage(&buf, users, since, 2)
+	want = `3 users, 1 active since 2021-07-03, 2 inactive.
+3 of 2 licensed seats used (150.0%).
+Over the licensed seats by 1.
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("wrong seat usage (-want +have):\n%s", diff)
+	}
+}
@@ -41,6 +41,12 @@ Examples:
   Get the curl command for a query (just add '-get-curl' in the flags section):
 
     	$ src api -get-curl -query='query { currentUser { username } }'
+
+  Call an HTTP endpoint that isn't part of the GraphQL API, such as the raw file API:
+
+    	$ src api rest github.com/sourcegraph/src-cli/-/raw/README.md
+
+  See 'src api rest -h' for more information.
 `
 
 	flagSet := flag.NewFlagSet("api", flag.ExitOnError)
@@ -56,6 +62,10 @@ Examples:
 	)
 
 	handler := func(args []string) error {
+		if len(args) > 0 && args[0] == "rest" {
+			return apiRestHandler(args[1:])
+		}
+
 		err := flagSet.Parse(args)
 		if err != nil {
 			return err
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/kballard/go-shellquote"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+const apiRestUsage = `'src api rest' calls an HTTP endpoint of the Sourcegraph instance that isn't
+part of the GraphQL API, such as the raw file API, the search stream or the LSIF
+upload endpoint, authenticated with the configured access token and SRC_HEADER_*
+headers.
+
+Usage:
+
+	src api rest [options] PATH
+
+PATH is relative to the Sourcegraph URL. Unless -output is given, the response
+body is written to stdout. Responses with a status other than 2xx make the
+command fail.
+
+Examples:
+
+  Get the contents of a file:
+
+    	$ src api rest github.com/sourcegraph/src-cli/-/raw/README.md
+
+  Download a zip archive of a repository:
+
+    	$ src api rest -H 'Accept: application/zip' -output src-cli.zip github.com/sourcegraph/src-cli/-/raw/
+
+  Stream search results:
+
+    	$ src api rest '.api/search/stream?q=repo:sourcegraph/src-cli+Router'
+
+  Upload an LSIF dump:
+
+    	$ src api rest -d @dump.lsif '.api/lsif/upload?repository=github.com/sourcegraph/src-cli&commit=...'
+
+  Get the curl command for a request (just add '-get-curl' in the flags section):
+
+    	$ src api rest -get-curl github.com/sourcegraph/src-cli/-/raw/README.md
+`
+
+func init() {
+	flagSet := flag.NewFlagSet("rest", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src api %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(apiRestUsage)
+	}
+	flagSet.Usage = usageFunc
+
+	var (
+		opts     apiRestOptions
+		headers  stringSliceFlag
+		apiFlags = api.NewFlags(flagSet)
+	)
+	flagSet.StringVar(&opts.method, "X", "", "The HTTP method of the request. Defaults to POST if -d is given, and GET otherwise.")
+	flagSet.Var(&headers, "H", "A header to send with the request, as 'Name: value'. Can be given multiple times.")
+	flagSet.StringVar(&opts.data, "d", "", "The body of the request. '@FILE' reads it from FILE, '@-' from stdin.")
+	flagSet.StringVar(&opts.output, "output", "", "Write the response body to this file instead of stdout.")
+	flagSet.BoolVar(&opts.include, "i", false, "Include the status and headers of the response in the output.")
+
+	apiRestHandler = func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if flagSet.NArg() != 1 {
+			return cmderrors.Usage("expected exactly one PATH argument")
+		}
+
+		path, err := apiRestPath(cfg.Endpoint, flagSet.Arg(0))
+		if err != nil {
+			return err
+		}
+		opts.path = path
+		opts.headers = headers
+		if opts.method == "" {
+			opts.method = "GET"
+			if opts.data != "" {
+				opts.method = "POST"
+			}
+		}
+
+		if apiFlags.GetCurl() {
+			fmt.Println(apiRestCurl(cfg, opts))
+			return nil
+		}
+
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+		return apiRest(context.Background(), client, opts, os.Stdin, os.Stdout)
+	}
+}
+
+// apiRestHandler handles 'src api rest'. It's called by the handler of
+// 'src api', which has no other subcommands.
+var apiRestHandler func(args []string) error
+
+// apiRestOptions are the options of a request made by 'src api rest'.
+type apiRestOptions struct {
+	method  string
+	path    string
+	headers []string
+	data    string
+	output  string
+	include bool
+}
+
+// apiRestPath returns the path relative to the Sourcegraph URL. Absolute URLs
+// are only accepted if they point at the Sourcegraph instance, so that the
+// access token isn't sent anywhere else.
+func apiRestPath(endpoint, path string) (string, error) {
+	if strings.Contains(path, "://") {
+		prefix := strings.TrimRight(endpoint, "/") + "/"
+		if !strings.HasPrefix(path, prefix) {
+			return "", cmderrors.Usagef("PATH %q must be relative to the Sourcegraph URL %s", path, endpoint)
+		}
+		path = strings.TrimPrefix(path, prefix)
+	}
+	return strings.TrimLeft(path, "/"), nil
+}
+
+// apiRestBody returns the body of the request given by -d.
+func apiRestBody(data string, stdin io.Reader) (io.ReadCloser, error) {
+	switch {
+	case data == "":
+		return nil, nil
+	case data == "@-":
+		return io.NopCloser(stdin), nil
+	case strings.HasPrefix(data, "@"):
+		return os.Open(strings.TrimPrefix(data, "@"))
+	default:
+		return io.NopCloser(strings.NewReader(data)), nil
+	}
+}
+
+// apiRest makes the request and writes the response to stdout, or the file
+// given by -output.
+func apiRest(ctx context.Context, client api.Client, opts apiRestOptions, stdin io.Reader, stdout io.Writer) error {
+	body, err := apiRestBody(opts.data, stdin)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		defer body.Close()
+	}
+
+	req, err := client.NewHTTPRequest(ctx, opts.method, opts.path, body)
+	if err != nil {
+		return err
+	}
+	for _, header := range opts.headers {
+		idx := strings.Index(header, ":")
+		if idx == -1 {
+			return cmderrors.Usagef("parsing header %q expected 'Name: value' syntax (missing colon)", header)
+		}
+		req.Header.Set(strings.TrimSpace(header[:idx]), strings.TrimSpace(header[idx+1:]))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		return cmderrors.WithKind(fmt.Errorf("%s %s: %s\n\n%s", opts.method, opts.path, resp.Status, body), api.StatusKind(resp.StatusCode))
+	}
+
+	if opts.output == "" {
+		return writeResponse(stdout, resp, opts.include)
+	}
+	f, err := os.Create(opts.output)
+	if err != nil {
+		return err
+	}
+	if err := writeResponse(f, resp, opts.include); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeResponse writes the body of resp to w, preceded by its status line and
+// headers, sorted by name, if include is true, like curl -i does.
+func writeResponse(w io.Writer, resp *http.Response, include bool) error {
+	if include {
+		fmt.Fprintf(w, "%s %s\n", resp.Proto, resp.Status)
+		names := make([]string, 0, len(resp.Header))
+		for name := range resp.Header {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, value := range resp.Header[name] {
+				fmt.Fprintf(w, "%s: %s\n", name, value)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+	_, err := io.Copy(w, resp.Body)
+	return err
+}
+
+// apiRestCurl returns the curl command that makes the same request.
+func apiRestCurl(cfg *config, opts apiRestOptions) string {
+	s := "curl \\\n"
+	s += fmt.Sprintf("   %s \\\n", shellquote.Join("-X", opts.method))
+	if cfg.AccessToken != "" {
+		s += fmt.Sprintf("   %s \\\n", shellquote.Join("-H", "Authorization: token "+cfg.AccessToken))
+	}
+	for k, v := range cfg.AdditionalHeaders {
+		s += fmt.Sprintf("   %s \\\n", shellquote.Join("-H", k+": "+v))
+	}
+	for _, header := range opts.headers {
+		s += fmt.Sprintf("   %s \\\n", shellquote.Join("-H", header))
+	}
+	if opts.data != "" {
+		s += fmt.Sprintf("   %s \\\n", shellquote.Join("--data-binary", opts.data))
+	}
+	if opts.output != "" {
+		s += fmt.Sprintf("   %s \\\n", shellquote.Join("-o", opts.output))
+	}
+	if opts.include {
+		s += "   -i \\\n"
+	}
+	s += fmt.Sprintf("   %s", shellquote.Join(strings.TrimRight(cfg.Endpoint, "/")+"/"+opts.path))
+	return s
+}
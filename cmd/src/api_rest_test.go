@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func TestAPIRestPath(t *testing.T) {
+	for _, tc := range []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: "github.com/a/b/-/raw/README.md", want: "github.com/a/b/-/raw/README.md"},
+		{path: "/.api/search/stream?q=x", want: ".api/search/stream?q=x"},
+		{path: "https://sourcegraph.example.com/.api/lsif/upload", want: ".api/lsif/upload"},
+		{path: "https://example.com/.api/lsif/upload", wantErr: true},
+	} {
+		have, err := apiRestPath("https://sourcegraph.example.com/", tc.path)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error", tc.path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have != tc.want {
+			t.Errorf("%s: wrong path: want %q, have %q", tc.path, tc.want, have)
+		}
+	}
+}
+
+func TestAPIRest(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token abc" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Accept", r.Header.Get("Accept"))
+		w.Write([]byte(r.Method + " " + r.URL.RequestURI() + " " + string(body)))
+	}))
+	defer ts.Close()
+
+	client := (&config{Endpoint: ts.URL, AccessToken: "abc"}).apiClient(nil, io.Discard)
+	ctx := context.Background()
+
+	t.Run("stdout", func(t *testing.T) {
+		var out bytes.Buffer
+		opts := apiRestOptions{method: "POST", path: ".api/lsif/upload?commit=c", data: "@-", headers: []string{"Accept: text/plain"}, include: true}
+		if err := apiRest(ctx, client, opts, strings.NewReader("dump"), &out); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(out.String(), "HTTP/1.1 200 OK\n") || !strings.Contains(out.String(), "X-Accept: text/plain\n") {
+			t.Errorf("missing status and headers in output %q", out.String())
+		}
+		if want := "\n\nPOST /.api/lsif/upload?commit=c dump"; !strings.HasSuffix(out.String(), want) {
+			t.Errorf("wrong output: want suffix %q, have %q", want, out.String())
+		}
+	})
+
+	t.Run("output file", func(t *testing.T) {
+		output := filepath.Join(t.TempDir(), "README.md")
+		var out bytes.Buffer
+		opts := apiRestOptions{method: "GET", path: "github.com/a/b/-/raw/README.md", output: output}
+		if err := apiRest(ctx, client, opts, nil, &out); err != nil {
+			t.Fatal(err)
+		}
+		if out.Len() != 0 {
+			t.Errorf("unexpected output %q", out.String())
+		}
+		data, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have, want := string(data), "GET /github.com/a/b/-/raw/README.md "; have != want {
+			t.Errorf("wrong file contents: want %q, have %q", want, have)
+		}
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		client := (&config{Endpoint: ts.URL}).apiClient(nil, io.Discard)
+		err := apiRest(ctx, client, apiRestOptions{method: "GET", path: "x"}, nil, io.Discard)
+		var kindErr *cmderrors.KindError
+		if !errors.As(err, &kindErr) || kindErr.Kind() != cmderrors.KindAuth {
+			t.Errorf("expected auth error, have %v", err)
+		}
+	})
+}
@@ -18,10 +18,39 @@ The commands are:
 
 	apply                 applies a batch spec to create or update a batch
 	                      change
+	apply-local           applies the changes of a batch spec to local clones
+	                      of the repositories
+	archive-workspace     exports the files of a repository after the steps
+	                      of a batch spec as a tarball
+	changesets            manages the changesets of a batch change, such as
+	                      merging them in the order of their dependencies
+	cleanup               removes containers, volumes and directories left
+	                      behind by interrupted runs
+	diff-stats            compares the changeset specs of a batch spec with
+	                      earlier ones
+	export                exports a batch change to be imported on another
+	                      instance
+	events                prints the state transitions of the changesets of a
+	                      batch change as JSON
+	history               lists the batch specs applied from this machine
+	import                imports a batch change exported from another
+	                      instance
+	migrate-spec          rewrites the deprecated constructs of a batch spec
+	                      to their current equivalents
 	new                   creates a new batch spec YAML file
 	preview               creates a batch spec to be previewed or applied
+	publish               publishes the changesets of a batch change
 	repos,repositories    queries the exact repositories that a batch spec will
 	                      apply to
+	resolve               prints a batch spec with the fragments it includes
+	                      merged into it
+	review                executes a batch spec and shows the diff of each
+	                      changeset to be approved or skipped before uploading
+	revert                creates a batch change that reverts the merged
+	                      changesets of another batch change
+	schedule              applies a batch spec repeatedly on a cron schedule
+	test                  executes a batch spec in fixture repositories and
+	                      compares the results to golden files
 	validate              validates a batch spec
 
 Use "src batch [command] -h" for more information about a command.
@@ -4,10 +4,15 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"os"
+	"time"
 
-	"github.com/sourcegraph/sourcegraph/lib/output"
+	"github.com/mattn/go-isatty"
+
+	"github.com/sourcegraph/src-cli/internal/batches/history"
 	"github.com/sourcegraph/src-cli/internal/batches/ui"
 	"github.com/sourcegraph/src-cli/internal/cmderrors"
+	"github.com/sourcegraph/src-cli/internal/version"
 )
 
 func init() {
@@ -25,11 +30,38 @@ Examples:
   
     $ src batch apply -f batch.spec.yaml -namespace myorg
 
+Execution and apply can be done on separate machines. The results of
+'src batch preview' are kept in its cache directory, which can be reviewed,
+copied, and then applied without executing any steps:
+
+    $ src batch preview -f batch.spec.yaml -cache ./results
+    $ src batch apply -f batch.spec.yaml -from-exec-results ./results
+
+With -confirm, the changesets are summarized after the steps are executed, and
+nothing is uploaded or applied until the changes are confirmed. On a terminal,
+they are confirmed interactively. Otherwise, a confirmation token is printed,
+which -confirm-token reads from a file to apply exactly the reviewed changes:
+
+    $ src batch apply -f batch.spec.yaml -confirm
+    $ echo TOKEN > approved.txt
+    $ src batch apply -f batch.spec.yaml -confirm-token approved.txt
+
+Every run is recorded in the history in -history-dir, with the batch spec, the
+instance, the URL of the batch change, and the number of repositories and
+changeset specs. See 'src batch history'.
+
 `
 
 	flagSet := flag.NewFlagSet("apply", flag.ExitOnError)
 	flags := newBatchExecuteFlags(flagSet, false, batchDefaultCacheDir(), batchDefaultTempDirPrefix())
 
+	var (
+		confirmFlag      = flagSet.Bool("confirm", false, "Summarize the changesets and wait for confirmation before uploading and applying the batch spec.")
+		confirmTokenFlag = flagSet.String("confirm-token", "", "Apply the changes only if they match the confirmation token in this file, printed by an earlier run with -confirm. Implies -confirm.")
+		historyDirFlag   = flagSet.String("history-dir", batchDefaultHistoryDir(), "The directory of the history of applied batch specs.")
+		noHistoryFlag    = flagSet.Bool("no-history", false, "Don't record this run in the history of applied batch specs.")
+	)
+
 	handler := func(args []string) error {
 		if err := flagSet.Parse(args); err != nil {
 			return err
@@ -39,27 +71,56 @@ Examples:
 			return cmderrors.Usage("additional arguments not allowed")
 		}
 
-		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		ctx, stop, cancel := contextStopOnInterrupt(context.Background(), flags.gracePeriod)
 		defer cancel()
 
 		var execUI ui.ExecUI
+		var confirmation *batchConfirmation
 		if flags.textOnly {
+			if *confirmFlag || *confirmTokenFlag != "" {
+				return cmderrors.Usage("-confirm can't be used with -text-only")
+			}
 			execUI = &ui.JSONLines{}
 		} else {
-			out := output.NewOutput(flagSet.Output(), output.OutputOpts{Verbose: *verbose})
-			execUI = &ui.TUI{Out: out}
+			out := newOutput(flagSet.Output(), *verbose)
+			execUI = &ui.TUI{Out: out, Plain: plainOutput()}
+			if *confirmFlag || *confirmTokenFlag != "" {
+				confirmation = &batchConfirmation{
+					out:         out,
+					tokenFile:   *confirmTokenFlag,
+					stdin:       os.Stdin,
+					interactive: isatty.IsTerminal(os.Stdin.Fd()) && flags.file != "" && flags.file != "-",
+				}
+			}
 		}
 
-		err := executeBatchSpec(ctx, executeBatchSpecOpts{
+		opts := executeBatchSpecOpts{
 			flags:  flags,
 			client: cfg.apiClient(flags.api, flagSet.Output()),
 
 			applyBatchSpec: true,
 
-			ui: execUI,
-		})
+			ui:   execUI,
+			stop: stop,
+		}
+		if confirmation != nil {
+			opts.confirm = confirmation.confirm
+		}
+		if !*noHistoryFlag {
+			opts.record = &history.Entry{
+				Started:    time.Now(),
+				Instance:   cfg.Endpoint,
+				SrcVersion: version.BuildTag,
+				Namespace:  flags.namespace,
+			}
+		}
+
+		err := executeBatchSpec(ctx, opts)
+		if opts.record != nil {
+			recordBatchApply(*historyDirFlag, opts.record, err)
+		}
 		if err != nil {
-			return cmderrors.ExitCode(1, nil)
+			return cmderrors.Reported(err)
 		}
 
 		return nil
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+	"github.com/sourcegraph/sourcegraph/lib/output"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/local"
+	"github.com/sourcegraph/src-cli/internal/batches/ui"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src batch apply-local' applies the changes of a batch spec to local clones of
+the repositories, instead of creating changesets. This lets you run builds or
+tests locally, and push the branches yourself.
+
+The steps in the batch spec given with -f are executed as with 'src batch
+preview'. Alternatively, -changeset-specs reads changeset specs that were
+already built, as a JSON array or one JSON object per line.
+
+For every changeset, the branch of the changeset is created from its base
+commit in the local clone of the repository, and a commit is created for each
+of its commits. Clones must not have uncommitted changes.
+
+The local clones are looked up in a YAML file given with -paths, which maps
+repository names, or prefixes ending in "/", to directories:
+
+    github.com/sourcegraph/src-cli: ~/work/src-cli
+    github.com/sourcegraph/: ~/src/
+
+Usage:
+
+    src batch apply-local -f FILE -paths FILE [command options]
+
+Examples:
+
+    $ src batch apply-local -f batch.spec.yaml -paths clones.yaml
+
+    $ src batch apply-local -changeset-specs specs.json -paths clones.yaml -no-commit
+
+`
+
+	flagSet := flag.NewFlagSet("apply-local", flag.ExitOnError)
+	flags := newBatchExecuteFlags(flagSet, false, batchDefaultCacheDir(), batchDefaultTempDirPrefix())
+
+	var (
+		pathsFlag          = flagSet.String("paths", "", "The YAML file mapping repository names to local clones.")
+		changesetSpecsFlag = flagSet.String("changeset-specs", "", "Read changeset specs from this file instead of executing a batch spec.")
+		noCommitFlag       = flagSet.Bool("no-commit", false, "Leave the changes staged on the new branch instead of committing them.")
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+		if *pathsFlag == "" {
+			return cmderrors.Usage("-paths must be provided")
+		}
+
+		paths, err := local.LoadPathMapping(*pathsFlag)
+		if err != nil {
+			return err
+		}
+
+		ctx, stop, cancel := contextStopOnInterrupt(context.Background(), flags.gracePeriod)
+		defer cancel()
+
+		out := newOutput(flagSet.Output(), *verbose)
+		client := cfg.apiClient(flags.api, flagSet.Output())
+		apply := func(specs []*batcheslib.ChangesetSpec, repos []*graphql.Repository) error {
+			return applyChangesetSpecsLocally(ctx, out, specs, repos, paths, local.Options{NoCommit: *noCommitFlag})
+		}
+
+		if *changesetSpecsFlag != "" {
+			specs, err := readChangesetSpecs(*changesetSpecsFlag)
+			if err != nil {
+				return err
+			}
+			repos, err := changesetSpecRepositories(ctx, client, specs)
+			if err != nil {
+				return err
+			}
+			return apply(specs, repos)
+		}
+
+		var execUI ui.ExecUI
+		if flags.textOnly {
+			execUI = &ui.JSONLines{}
+		} else {
+			execUI = &ui.TUI{Out: out, Plain: plainOutput()}
+		}
+
+		err = executeBatchSpec(ctx, executeBatchSpecOpts{
+			flags:       flags,
+			client:      client,
+			ui:          execUI,
+			stop:        stop,
+			handleSpecs: apply,
+		})
+		if err != nil {
+			return cmderrors.Reported(err)
+		}
+		return nil
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+// applyChangesetSpecsLocally applies the changeset specs to the local clones of
+// their repositories. Specs of changesets that are imported, rather than
+// created, are skipped. All specs are attempted, even if some fail.
+func applyChangesetSpecsLocally(ctx context.Context, out *output.Output, specs []*batcheslib.ChangesetSpec, repos []*graphql.Repository, paths local.PathMapping, opts local.Options) error {
+	names := make(map[string]string, len(repos))
+	for _, r := range repos {
+		names[r.ID] = r.Name
+	}
+
+	failed := 0
+	for _, spec := range specs {
+		if spec.ExternalID != "" {
+			continue
+		}
+
+		name, ok := names[spec.BaseRepository]
+		if !ok {
+			return errors.Newf("unknown repository %q", spec.BaseRepository)
+		}
+		dir, ok := paths.Dir(name)
+		if !ok {
+			out.WriteLine(output.Linef(output.EmojiWarning, output.StyleWarning, "%s: no local clone configured, skipping", name))
+			failed++
+			continue
+		}
+
+		cs := local.Changeset{
+			Repository: name,
+			BaseRef:    spec.BaseRef,
+			BaseRev:    spec.BaseRev,
+			Branch:     strings.TrimPrefix(spec.HeadRef, "refs/heads/"),
+		}
+		for _, c := range spec.Commits {
+			cs.Commits = append(cs.Commits, local.Commit{
+				Message:     c.Message,
+				AuthorName:  c.AuthorName,
+				AuthorEmail: c.AuthorEmail,
+				Diff:        c.Diff,
+			})
+		}
+
+		if err := local.Apply(ctx, dir, cs, opts); err != nil {
+			out.WriteLine(output.Linef(output.EmojiFailure, output.StyleWarning, "%s: %s", name, err))
+			failed++
+			continue
+		}
+		out.WriteLine(output.Linef(output.EmojiSuccess, output.StyleSuccess, "%s: created branch %s in %s", name, cs.Branch, dir))
+	}
+
+	if failed > 0 {
+		return errors.Newf("%d changesets could not be applied locally", failed)
+	}
+	return nil
+}
+
+// readChangesetSpecs reads changeset specs from a file containing either a JSON
+// array of specs, or one spec per line.
+func readChangesetSpecs(file string) ([]*batcheslib.ChangesetSpec, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var specs []*batcheslib.ChangesetSpec
+	dec := json.NewDecoder(f)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			return specs, nil
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "parsing %s", file)
+		}
+
+		if strings.HasPrefix(strings.TrimSpace(string(raw)), "[") {
+			var batch []*batcheslib.ChangesetSpec
+			if err := json.Unmarshal(raw, &batch); err != nil {
+				return nil, errors.Wrapf(err, "parsing %s", file)
+			}
+			specs = append(specs, batch...)
+			continue
+		}
+
+		var spec batcheslib.ChangesetSpec
+		if err := json.Unmarshal(raw, &spec); err != nil {
+			return nil, errors.Wrapf(err, "parsing %s", file)
+		}
+		specs = append(specs, &spec)
+	}
+}
+
+const repositoryNodeQuery = `
+query RepositoryNode($id: ID!) {
+    node(id: $id) {
+        ... on Repository {
+            id
+            name
+        }
+    }
+}
+`
+
+// changesetSpecRepositories looks up the repositories the changeset specs
+// belong to.
+func changesetSpecRepositories(ctx context.Context, client api.Client, specs []*batcheslib.ChangesetSpec) ([]*graphql.Repository, error) {
+	seen := map[string]bool{}
+	var repos []*graphql.Repository
+	for _, spec := range specs {
+		if seen[spec.BaseRepository] {
+			continue
+		}
+		seen[spec.BaseRepository] = true
+
+		var result struct {
+			Node *graphql.Repository
+		}
+		if ok, err := client.NewRequest(repositoryNodeQuery, map[string]interface{}{
+			"id": spec.BaseRepository,
+		}).Do(ctx, &result); err != nil || !ok {
+			return nil, err
+		}
+		if result.Node == nil || result.Node.Name == "" {
+			return nil, errors.Newf("repository %q not found", spec.BaseRepository)
+		}
+		repos = append(repos, result.Node)
+	}
+	return repos, nil
+}
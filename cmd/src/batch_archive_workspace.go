@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/batches/ui"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src batch archive-workspace' exports the files of a repository as they are
+after all steps of a batch spec were executed in it, as a gzipped tarball, to
+inspect them or to feed them into external validation tooling.
+
+The files are reconstructed from the cached results of an earlier execution of
+the batch spec with 'src batch preview' or 'src batch exec', like with
+-templates-only: the diffs of the changesets in the repository are applied to
+the archive of the repository at their base revision. Nothing is executed, and
+the changeset template, including the file filters of the batch spec, applies
+to the diffs. Alternatively, -from-exec-results reads the results of an
+execution on another machine.
+
+If the repository has changesets on more than one base branch, select one with
+-branch. -path only exports the files in a directory of the repository, such
+as the path of a workspace.
+
+Usage:
+
+    src batch archive-workspace -f FILE [-o FILE] [command options] REPOSITORY
+
+Examples:
+
+    $ src batch archive-workspace -f batch.spec.yaml -o src-cli.tar.gz github.com/sourcegraph/src-cli
+
+    $ src batch archive-workspace -f batch.spec.yaml -path client/web github.com/sourcegraph/sourcegraph | tar -tz
+
+`
+
+	flagSet := flag.NewFlagSet("archive-workspace", flag.ExitOnError)
+	flags := newBatchExecuteFlags(flagSet, false, batchDefaultCacheDir(), batchDefaultTempDirPrefix())
+
+	var (
+		outFlag    = flagSet.String("o", "", "Write the tarball to this file instead of stdout.")
+		branchFlag = flagSet.String("branch", "", "The base branch of the changesets to export, if the repository has changesets on more than one.")
+		pathFlag   = flagSet.String("path", "", "Only export the files in this directory of the repository, relative to it.")
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 1 {
+			return cmderrors.Usage("exactly one repository must be given")
+		}
+		repoName := flagSet.Arg(0)
+		if flags.clearCache {
+			return cmderrors.Usage("-clear-cache can't be used with 'src batch archive-workspace', which only reads cached results")
+		}
+		if flags.fromExecResults == "" {
+			flags.templatesOnly = true
+		}
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+
+		// The tarball may be written to stdout, so progress goes to stderr.
+		out := newOutput(flagSet.Output(), *verbose)
+		client := cfg.apiClient(flags.api, flagSet.Output())
+
+		var execUI ui.ExecUI
+		if flags.textOnly {
+			execUI = &ui.JSONLines{}
+		} else {
+			execUI = &ui.TUI{Out: out, Plain: plainOutput()}
+		}
+
+		err := executeBatchSpec(ctx, executeBatchSpecOpts{
+			flags:  flags,
+			client: client,
+			ui:     execUI,
+			handleSpecs: func(specs []*batcheslib.ChangesetSpec, repos []*graphql.Repository) error {
+				repo, repoSpecs, err := workspaceArchiveSpecs(specs, repos, repoName, *branchFlag)
+				if err != nil {
+					return err
+				}
+
+				svc := service.New(&service.Opts{Client: client})
+				if *outFlag == "" {
+					return svc.WriteWorkspaceArchive(ctx, os.Stdout, flags.cacheDir, repo, repoSpecs, *pathFlag)
+				}
+
+				f, err := os.Create(*outFlag)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				if err := svc.WriteWorkspaceArchive(ctx, f, flags.cacheDir, repo, repoSpecs, *pathFlag); err != nil {
+					return err
+				}
+				return f.Close()
+			},
+		})
+		if err != nil {
+			return cmderrors.Reported(err)
+		}
+		return nil
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+// workspaceArchiveSpecs returns the repository with the given name and the
+// specs of the changesets created in it on the given base branch, which may
+// be empty if all of them are on the same one.
+func workspaceArchiveSpecs(specs []*batcheslib.ChangesetSpec, repos []*graphql.Repository, repoName, branch string) (*graphql.Repository, []*batcheslib.ChangesetSpec, error) {
+	var repo *graphql.Repository
+	for _, r := range repos {
+		if r.Name == repoName {
+			repo = r
+			break
+		}
+	}
+	if repo == nil {
+		return nil, nil, errors.Newf("the batch spec has no cached results for repository %s", repoName)
+	}
+
+	byBranch := map[string][]*batcheslib.ChangesetSpec{}
+	for _, spec := range specs {
+		if spec.BaseRepository != repo.ID || spec.ExternalID != "" {
+			continue
+		}
+		base := strings.TrimPrefix(spec.BaseRef, "refs/heads/")
+		if branch == "" || base == strings.TrimPrefix(branch, "refs/heads/") {
+			byBranch[base] = append(byBranch[base], spec)
+		}
+	}
+
+	switch len(byBranch) {
+	case 0:
+		if branch != "" {
+			return nil, nil, errors.Newf("no changesets in %s on base branch %s", repoName, branch)
+		}
+		return nil, nil, errors.Newf("no changesets in %s: the steps didn't change any files", repoName)
+	case 1:
+		for _, s := range byBranch {
+			return repo, s, nil
+		}
+	}
+
+	branches := make([]string, 0, len(byBranch))
+	for b := range byBranch {
+		branches = append(branches, b)
+	}
+	sort.Strings(branches)
+	return nil, nil, cmderrors.Usagef("%s has changesets on more than one base branch, select one with -branch: %s", repoName, strings.Join(branches, ", "))
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+var batchChangesetsCommands commander
+
+func init() {
+	usage := `'src batch changesets' manages the changesets of a batch change.
+
+Usage:
+
+	src batch changesets command [command options]
+
+The commands are:
+
+	merge        merges the open changesets of a batch change, optionally in
+	             the order of their dependencies
+
+Use "src batch changesets [command] -h" for more information about a command.
+`
+
+	flagSet := flag.NewFlagSet("changesets", flag.ExitOnError)
+	handler := func(args []string) error {
+		batchChangesetsCommands.run(flagSet, "src batch changesets", usage, args)
+		return nil
+	}
+
+	// Register the command.
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Println(usage)
+		},
+	})
+}
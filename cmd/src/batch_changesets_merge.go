@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+// mergePollInterval is how often 'src batch changesets merge' checks whether
+// the changesets it merges are merged.
+const mergePollInterval = 10 * time.Second
+
+func init() {
+	usage := `
+'src batch changesets merge' merges the open changesets of a batch change on
+the code host.
+
+With -in-order, the changesets are merged in the order of the dependencies
+declared by the changesetDependencies field of the batch spec, such as a
+library before the repositories that use it:
+
+    changesetDependencies:
+      - repository: github.com/sourcegraph/*-service
+        dependsOn:
+          - github.com/sourcegraph/library
+
+The changesets are merged in waves: each wave merges the changesets whose
+dependencies were merged by the waves before, once those are merged. If a
+changeset fails to merge, the changesets that depend on it aren't merged.
+Changesets whose dependencies are closed, unpublished or drafts aren't merged
+at all. Changesets with dependencies can only be merged with -in-order.
+
+Usage:
+
+    src batch changesets merge -name NAME [command options]
+
+Examples:
+
+    $ src batch changesets merge -name hello-world -in-order -dry-run
+
+    $ src batch changesets merge -name hello-world -in-order -squash
+
+`
+
+	flagSet := flag.NewFlagSet("merge", flag.ExitOnError)
+
+	var (
+		nameFlag      = flagSet.String("name", "", "The name of the batch change.")
+		namespaceFlag = flagSet.String("namespace", "", "The user or organization namespace of the batch change. Default is the currently authenticated user.")
+		inOrderFlag   = flagSet.Bool("in-order", false, "Merge the changesets in the order of their dependencies.")
+		squashFlag    = flagSet.Bool("squash", false, "Squash the commits of each changeset when merging.")
+		dryRunFlag    = flagSet.Bool("dry-run", false, "Print the order the changesets would be merged in without merging them.")
+		timeoutFlag   = flagSet.Duration("timeout", time.Hour, "The maximum time to wait for the changesets of a wave to be merged.")
+		apiFlags      = api.NewFlags(flagSet)
+	)
+	flagSet.StringVar(namespaceFlag, "n", "", "Alias for -namespace.")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+		if *nameFlag == "" {
+			return cmderrors.Usage("-name must be provided")
+		}
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+
+		svc := service.New(&service.Opts{
+			Client: cfg.apiClient(apiFlags, flagSet.Output()),
+		})
+
+		namespace, err := svc.ResolveNamespace(ctx, *namespaceFlag)
+		if err != nil {
+			return err
+		}
+
+		batchChange, changesets, err := svc.FetchChangesets(ctx, namespace, *nameFlag)
+		if err != nil {
+			return err
+		}
+
+		var waves []service.MergeWave
+		if *inOrderFlag {
+			var blocked []service.BlockedChangeset
+			waves, blocked, err = service.MergeOrder(changesets)
+			if err != nil {
+				return err
+			}
+			printMergeOrder(os.Stdout, waves, blocked)
+		} else {
+			var wave service.MergeWave
+			for _, cs := range changesets {
+				if cs.State != "OPEN" {
+					continue
+				}
+				if len(cs.DependsOn) > 0 {
+					return cmderrors.Usagef("the changeset in %s depends on the changesets in %s: use -in-order to merge the changesets in order", cs.Repository, strings.Join(cs.DependsOn, ", "))
+				}
+				wave = append(wave, cs)
+			}
+			if len(wave) > 0 {
+				waves = append(waves, wave)
+			}
+		}
+
+		if len(waves) == 0 {
+			fmt.Println("No changesets to merge.")
+			return nil
+		}
+		if *dryRunFlag {
+			return nil
+		}
+
+		for i, wave := range waves {
+			ids := make([]string, len(wave))
+			for j, cs := range wave {
+				ids[j] = cs.ID
+			}
+
+			fmt.Printf("Merging %d changesets (wave %d/%d).\n", len(wave), i+1, len(waves))
+			op, err := svc.MergeChangesets(ctx, batchChange, ids, *squashFlag)
+			if err != nil {
+				return err
+			}
+			failures, err := svc.WaitForBulkOperation(ctx, op, mergePollInterval)
+			if err != nil {
+				return err
+			}
+			if len(failures) > 0 {
+				repos := make(map[string]string, len(wave))
+				for _, cs := range wave {
+					repos[cs.ID] = cs.Repository
+				}
+				for _, f := range failures {
+					fmt.Printf("Failed to merge the changeset in %s: %s\n", repos[f.Changeset], f.Error)
+				}
+				return errors.Newf("%d changesets failed to merge%s", len(failures), remainingWaves(waves[i+1:]))
+			}
+
+			// The next wave is only merged once the changesets of this one
+			// are merged on the code host.
+			if i < len(waves)-1 {
+				if err := waitForMergedChangesets(ctx, svc, namespace, *nameFlag, ids, *timeoutFlag); err != nil {
+					return errors.Wrapf(err, "waiting for the changesets to be merged%s", remainingWaves(waves[i+1:]))
+				}
+			}
+		}
+		fmt.Println("Merged all changesets.")
+		return nil
+	}
+
+	batchChangesetsCommands = append(batchChangesetsCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch changesets %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+// printMergeOrder prints the waves that the changesets are merged in, and the
+// changesets that can't be merged.
+func printMergeOrder(w io.Writer, waves []service.MergeWave, blocked []service.BlockedChangeset) {
+	for i, wave := range waves {
+		fmt.Fprintf(w, "Wave %d:\n", i+1)
+		for _, cs := range wave {
+			fmt.Fprintf(w, "  %s\n", cs.Repository)
+		}
+	}
+	if len(blocked) > 0 {
+		fmt.Fprintln(w, "Blocked:")
+		for _, cs := range blocked {
+			fmt.Fprintf(w, "  %s: %s\n", cs.Repository, strings.Join(cs.Reasons, ", "))
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// remainingWaves describes the changesets of the waves that weren't merged, if
+// there are any.
+func remainingWaves(waves []service.MergeWave) string {
+	n := 0
+	for _, wave := range waves {
+		n += len(wave)
+	}
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf("; the %d changesets that depend on them weren't merged", n)
+}
+
+// waitForMergedChangesets waits until the changesets with the given IDs are
+// merged, failing if one of them is closed instead or they aren't merged
+// within the timeout.
+func waitForMergedChangesets(ctx context.Context, svc *service.Service, namespace, name string, ids []string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	waiting := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		waiting[id] = true
+	}
+	for {
+		_, changesets, err := svc.FetchChangesets(ctx, namespace, name)
+		if err != nil {
+			return err
+		}
+		pending := 0
+		for _, cs := range changesets {
+			if !waiting[cs.ID] {
+				continue
+			}
+			switch cs.State {
+			case "MERGED":
+			case "OPEN":
+				pending++
+			default:
+				return errors.Newf("the changeset in %s is %s", cs.Repository, strings.ToLower(cs.State))
+			}
+		}
+		if pending == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(mergePollInterval):
+		}
+	}
+}
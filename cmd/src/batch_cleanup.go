@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/sourcegraph/sourcegraph/lib/output"
+
+	"github.com/sourcegraph/src-cli/internal/batches/reaper"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src batch cleanup' removes the Docker containers, Docker volumes and temporary
+directories left behind by 'src batch' runs that were killed before they could
+clean up after themselves.
+
+Runs that are still in progress are not touched.
+
+Usage:
+
+    src batch cleanup [-cache DIR]
+
+Examples:
+
+    $ src batch cleanup
+
+`
+
+	flagSet := flag.NewFlagSet("cleanup", flag.ExitOnError)
+	cacheDir := flagSet.String("cache", batchDefaultCacheDir(), "The cache directory used by the runs.")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+
+		out := newOutput(flagSet.Output(), *verbose)
+
+		cleaned, err := reaper.CleanupStale(ctx, batchRunsDir(*cacheDir))
+		for _, state := range cleaned {
+			out.WriteLine(output.Linef(output.EmojiSuccess, output.StyleSuccess,
+				"Cleaned up run of process %d started at %s: %d containers, %d volumes, %d directories",
+				state.PID,
+				state.Started.Format("2006-01-02 15:04:05"),
+				len(state.Containers)+len(state.CIDFiles),
+				len(state.Volumes),
+				len(state.Dirs),
+			))
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(cleaned) == 0 {
+			out.WriteLine(output.Line(output.EmojiSuccess, output.StyleSuccess, "Nothing to clean up."))
+		}
+		return nil
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
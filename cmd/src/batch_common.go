@@ -2,6 +2,9 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -9,11 +12,17 @@ import (
 	"os/exec"
 	"os/signal"
 	"path"
+	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/cockroachdb/errors"
+	humanize "github.com/dustin/go-humanize"
 	"github.com/hashicorp/go-multierror"
 	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
 
@@ -21,6 +30,9 @@ import (
 	"github.com/sourcegraph/src-cli/internal/batches"
 	"github.com/sourcegraph/src-cli/internal/batches/executor"
 	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/history"
+	"github.com/sourcegraph/src-cli/internal/batches/lockfile"
+	"github.com/sourcegraph/src-cli/internal/batches/reaper"
 	"github.com/sourcegraph/src-cli/internal/batches/service"
 	"github.com/sourcegraph/src-cli/internal/batches/ui"
 	"github.com/sourcegraph/src-cli/internal/batches/workspace"
@@ -28,21 +40,43 @@ import (
 )
 
 type batchExecuteFlags struct {
-	allowUnsupported bool
-	allowIgnored     bool
-	api              *api.Flags
-	apply            bool
-	cacheDir         string
-	tempDir          string
-	clearCache       bool
-	file             string
-	keepLogs         bool
-	namespace        string
-	parallelism      int
-	timeout          time.Duration
-	workspace        string
-	cleanArchives    bool
-	skipErrors       bool
+	allowUnsupported  bool
+	allowIgnored      bool
+	api               *api.Flags
+	apply             bool
+	cacheDir          string
+	tempDir           string
+	clearCache        bool
+	waitForLock       bool
+	file              string
+	keepLogs          bool
+	keepWorkspaces    keepWorkspacesFlag
+	forwardSSHAgent   bool
+	namespace         string
+	parallelism       int
+	uploadParallelism int
+	pullParallelism   int
+	registryMirror    string
+	uploadRetries     int
+	timeout           time.Duration
+	workspace         string
+	sandbox           string
+	cleanArchives     bool
+	skipErrors        bool
+	emitEvents        string
+	gracePeriod       time.Duration
+
+	commitAuthorName  string
+	commitAuthorEmail string
+
+	checkBaseBranches bool
+	reExecuteStale    bool
+	templatesOnly     bool
+	fromExecResults   string
+	onBranchCollision string
+	maxChangesetSize  byteSizeFlag
+	params            stringSliceFlag
+	showCommands      string
 
 	// EXPERIMENTAL
 	textOnly bool
@@ -74,11 +108,61 @@ func newBatchExecuteFlags(flagSet *flag.FlagSet, workspaceExecution bool, cacheD
 			&caf.keepLogs, "keep-logs", false,
 			"Retain logs after executing steps.",
 		)
+		flagSet.BoolVar(
+			&caf.forwardSSHAgent, "forward-ssh-agent", false,
+			"Forward the SSH agent of the host into the containers of steps, together with the known hosts and the git credentials stored with the store credential helper, so that steps can fetch private dependencies.",
+		)
+		flagSet.Var(
+			&caf.keepWorkspaces, "keep-workspaces",
+			`Retain the workspaces of tasks after executing steps and print where they are, to inspect what the steps left behind. "-keep-workspaces=on-failure" only retains the workspaces of failed tasks.`,
+		)
 		flagSet.StringVar(
 			&caf.namespace, "namespace", "",
 			"The user or organization namespace to place the batch change within. Default is the currently authenticated user.",
 		)
 		flagSet.StringVar(&caf.namespace, "n", "", "Alias for -namespace.")
+		flagSet.StringVar(
+			&caf.commitAuthorName, "commit-author-name", "",
+			"Overrides the commit author name in the changeset template. Must be used together with -commit-author-email.",
+		)
+		flagSet.StringVar(
+			&caf.commitAuthorEmail, "commit-author-email", "",
+			"Overrides the commit author email in the changeset template. Must be used together with -commit-author-name.",
+		)
+		flagSet.BoolVar(
+			&caf.checkBaseBranches, "check-base-branches", false,
+			"Before uploading the changeset specs, check whether their base branches moved since the steps were executed, and whether the diffs still apply to them.",
+		)
+		flagSet.BoolVar(
+			&caf.reExecuteStale, "re-execute-stale", false,
+			"Re-execute the steps in the repositories whose base branch moved since the steps were executed. Implies -check-base-branches.",
+		)
+		flagSet.BoolVar(
+			&caf.templatesOnly, "templates-only", false,
+			"Only render the changeset template again, reusing the repositories and step results cached by the last execution of a batch spec with the same steps. Repositories are not resolved again and no steps are executed.",
+		)
+		flagSet.StringVar(
+			&caf.fromExecResults, "from-exec-results", "",
+			"Create the changeset specs from the execution results in the given directory, which is the cache directory of an earlier run of the same batch spec, possibly on another machine. Like -templates-only, but neither Docker nor git are required.",
+		)
+		flagSet.StringVar(
+			&caf.onBranchCollision, "on-branch-collision", branchCollisionWarn,
+			`What to do with changeset specs whose branch already exists in the repository or is used by a changeset of another batch change ("warn", "suffix", or "fail"). "suffix" moves them to the first free branch with a numeric suffix, such as my-branch-2.`,
+		)
+		flagSet.Var(
+			&caf.maxChangesetSize, "max-changeset-size",
+			`Fail before uploading if a changeset spec is larger than this, as in "10MB", naming the repositories whose diffs are too large. Changeset specs larger than 1MiB, which many Sourcegraph instances don't accept, are warned about regardless.`,
+		)
+		flagSet.StringVar(
+			&caf.showCommands, "show-commands", "",
+			"Print the docker run invocations of the steps in the given repository as a shell script, instead of executing the batch spec, to reproduce and debug the steps outside of src.",
+		)
+		addBatchParamFlag(flagSet, &caf.params)
+	} else {
+		flagSet.StringVar(
+			&caf.emitEvents, "emit-events", "",
+			"If set, task lifecycle events are written as JSON lines to the given file, or to the given file descriptor if a number is given.",
+		)
 	}
 
 	flagSet.StringVar(
@@ -89,6 +173,10 @@ func newBatchExecuteFlags(flagSet *flag.FlagSet, workspaceExecution bool, cacheD
 		&caf.clearCache, "clear-cache", false,
 		"If true, clears the execution cache and executes all steps anew.",
 	)
+	flagSet.BoolVar(
+		&caf.waitForLock, "wait-for-lock", false,
+		"If true, waits for other runs using the same cache directory to finish executing steps, instead of failing. Runs lock the cache directory while they execute steps, so by default a run fails while another one using the same -cache directory does.",
+	)
 	flagSet.StringVar(
 		&caf.tempDir, "tmp", tempDir,
 		"Directory for storing temporary data, such as log files. Default is /tmp. Can also be set with environment variable SRC_BATCH_TMP_DIR; if both are set, this flag will be used and not the environment variable.",
@@ -101,7 +189,23 @@ func newBatchExecuteFlags(flagSet *flag.FlagSet, workspaceExecution bool, cacheD
 
 	flagSet.IntVar(
 		&caf.parallelism, "j", runtime.GOMAXPROCS(0),
-		"The maximum number of parallel jobs. Default is GOMAXPROCS.",
+		"The maximum number of parallel jobs. Default is GOMAXPROCS. The maxParallelism of the batch spec lowers it.",
+	)
+	flagSet.IntVar(
+		&caf.uploadParallelism, "upload-parallelism", 8,
+		"The maximum number of changeset specs uploaded in parallel.",
+	)
+	flagSet.IntVar(
+		&caf.pullParallelism, "pull-parallelism", 4,
+		"The maximum number of container images pulled in parallel before executing steps.",
+	)
+	flagSet.StringVar(
+		&caf.registryMirror, "registry-mirror", os.Getenv("SRC_BATCH_REGISTRY_MIRROR"),
+		`The registry mirror, such as a pull-through cache, that images from Docker Hub are pulled through, as in "mirror.example.com:5000". Images are pulled from Docker Hub if the mirror fails. Defaults to $SRC_BATCH_REGISTRY_MIRROR.`,
+	)
+	flagSet.IntVar(
+		&caf.uploadRetries, "upload-retries", 3,
+		"The number of times the upload of a changeset spec that fails with a network or server error is retried.",
 	)
 	flagSet.DurationVar(
 		&caf.timeout, "timeout", 60*time.Minute,
@@ -115,10 +219,18 @@ func newBatchExecuteFlags(flagSet *flag.FlagSet, workspaceExecution bool, cacheD
 		&caf.skipErrors, "skip-errors", false,
 		"If true, errors encountered while executing steps in a repository won't stop the execution of the batch spec but only cause that repository to be skipped.",
 	)
+	flagSet.DurationVar(
+		&caf.gracePeriod, "grace-period", batchDefaultGracePeriod,
+		"How long the running steps may take to finish after an interrupt before they're stopped. No new steps are started after an interrupt, and a second interrupt stops the running steps immediately.",
+	)
 
 	flagSet.StringVar(
 		&caf.workspace, "workspace", "auto",
-		`Workspace mode to use ("auto", "bind", or "volume")`,
+		`Workspace mode to use ("auto", "bind", "volume", or "remote"). "remote" streams the repositories and the files of steps to the Docker daemon instead of bind mounting them, for a daemon on another machine as set with DOCKER_HOST or a Docker context. "auto" uses "remote" for such a daemon.`,
+	)
+	flagSet.StringVar(
+		&caf.sandbox, "sandbox", string(executor.SandboxDefault),
+		`Sandbox profile of the step containers ("strict", "default", or "off"). "strict" runs them as a non-root user with a read-only root filesystem except for the workspace and /tmp, no capabilities and no-new-privileges. "off" disables Docker's seccomp and AppArmor profiles.`,
 	)
 
 	flagSet.BoolVar(verbose, "v", false, "print verbose output")
@@ -126,6 +238,41 @@ func newBatchExecuteFlags(flagSet *flag.FlagSet, workspaceExecution bool, cacheD
 	return caf
 }
 
+// keepWorkspacesFlag is the value of the -keep-workspaces flag, which can be
+// given without a value to keep all workspaces.
+type keepWorkspacesFlag struct {
+	keep executor.KeepWorkspaces
+}
+
+func (f *keepWorkspacesFlag) String() string {
+	return string(f.value())
+}
+
+func (f *keepWorkspacesFlag) Set(v string) error {
+	switch v {
+	case "true":
+		f.keep = executor.KeepWorkspacesAlways
+	case "false":
+		f.keep = executor.KeepWorkspacesNever
+	default:
+		keep, err := executor.ParseKeepWorkspaces(v)
+		if err != nil {
+			return err
+		}
+		f.keep = keep
+	}
+	return nil
+}
+
+func (f *keepWorkspacesFlag) IsBoolFlag() bool { return true }
+
+func (f *keepWorkspacesFlag) value() executor.KeepWorkspaces {
+	if f.keep == "" {
+		return executor.KeepWorkspacesNever
+	}
+	return f.keep
+}
+
 func batchDefaultCacheDir() string {
 	uc, err := os.UserCacheDir()
 	if err != nil {
@@ -175,6 +322,93 @@ func batchDefaultTempDirPrefix() string {
 	return os.TempDir()
 }
 
+// batchRunsDir returns the directory containing the state files of the runs
+// using the given cache directory.
+func batchRunsDir(cacheDir string) string {
+	return filepath.Join(cacheDir, "runs")
+}
+
+// lockBatchCache locks the cache directory while a run executes steps, so that
+// simultaneous runs on the same machine, such as parallel CI jobs, don't
+// corrupt the execution cache and the repository archives in it. If the cache
+// directory is locked by another run, an error is returned, unless wait is
+// true, in which case lockBatchCache waits for the other run to release it.
+// Locks of runs that died are recovered. The returned function releases the
+// lock; it can be called again, so that runs can release it as soon as they
+// no longer use the cache and still defer it for errors.
+func lockBatchCache(ctx context.Context, cacheDir string, wait bool) (func() error, error) {
+	if cacheDir == "" {
+		return func() error { return nil }, nil
+	}
+
+	path := filepath.Join(cacheDir, "lock")
+	var (
+		lock *lockfile.Lock
+		err  error
+	)
+	if wait {
+		lock, err = lockfile.Acquire(ctx, path, func(owner lockfile.Owner) {
+			fmt.Fprintf(os.Stderr, "Waiting for the run of process %d to release the cache directory %s...\n", owner.PID, cacheDir)
+		})
+	} else {
+		lock, err = lockfile.TryAcquire(path)
+	}
+	if err != nil {
+		var locked *lockfile.LockedError
+		if errors.As(err, &locked) {
+			return nil, errors.Wrap(err, "the cache directory is in use by another run of src; pass -wait-for-lock to wait for it to finish, or use a different -cache directory")
+		}
+		return nil, errors.Wrap(err, "locking cache directory")
+	}
+	var once sync.Once
+	return func() (err error) {
+		once.Do(func() { err = lock.Release() })
+		return err
+	}, nil
+}
+
+// batchRunTempDir creates the directory for the temporary data of a run, such
+// as workspaces and logs, in tempDir, so that simultaneous runs sharing a -tmp
+// directory don't get in each other's way. The returned function removes the
+// directory when the run finishes, unless it still contains logs or
+// workspaces that were kept.
+func batchRunTempDir(tempDir string) (string, func() error, error) {
+	dir, err := os.MkdirTemp(tempDir, "src-batch-run-")
+	if err != nil {
+		return "", nil, errors.Wrap(err, "creating temporary directory")
+	}
+
+	return dir, func() error {
+		entries, err := os.ReadDir(dir)
+		if err != nil || len(entries) > 0 {
+			return errors.Wrap(err, "cleaning up temporary directory")
+		}
+		return errors.Wrap(os.Remove(dir), "cleaning up temporary directory")
+	}, nil
+}
+
+// batchRunTracker creates the tracker of the containers, volumes and
+// directories of a run. The returned function removes whatever is still
+// tracked when the run finishes, whether it succeeded or not. If cacheDir is
+// empty, nothing is tracked.
+func batchRunTracker(cacheDir string) (*reaper.Tracker, func() error, error) {
+	if cacheDir == "" {
+		return nil, func() error { return nil }, nil
+	}
+
+	tracker, err := reaper.NewTracker(batchRunsDir(cacheDir))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tracker, func() error {
+		// The context of the run is likely cancelled at this point.
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		return errors.Wrap(tracker.Cleanup(ctx), "cleaning up after execution")
+	}, nil
+}
+
 func batchOpenFileFlag(flag *string) (io.ReadCloser, error) {
 	if flag == nil || *flag == "" || *flag == "-" {
 		return os.Stdin, nil
@@ -187,6 +421,21 @@ func batchOpenFileFlag(flag *string) (io.ReadCloser, error) {
 	return file, nil
 }
 
+// batchOpenEventsFlag opens the destination of the -emit-events flag. Numeric
+// values are treated as file descriptors inherited from the parent process,
+// everything else as a path to a file that is created or truncated.
+func batchOpenEventsFlag(flag string) (io.WriteCloser, error) {
+	if fd, err := strconv.Atoi(flag); err == nil {
+		return os.NewFile(uintptr(fd), "fd "+flag), nil
+	}
+
+	file, err := os.Create(flag)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create events file %q", flag)
+	}
+	return file, nil
+}
+
 type executeBatchSpecOpts struct {
 	flags *batchExecuteFlags
 
@@ -195,6 +444,114 @@ type executeBatchSpecOpts struct {
 	ui ui.ExecUI
 
 	client api.Client
+
+	// stop, if set, is closed to stop executing the steps gracefully, such as
+	// by contextStopOnInterrupt.
+	stop <-chan struct{}
+
+	// skipUnchanged, if set, is called with a digest of the changeset specs
+	// before they are uploaded. If it returns true, the batch spec is neither
+	// uploaded nor applied.
+	skipUnchanged func(digest string) bool
+
+	// confirm, if set, is called with the changeset specs before they are
+	// uploaded. If it returns an error, such as because the changes weren't
+	// confirmed, the batch spec is neither uploaded nor applied.
+	confirm func(namespace, rawSpec string, specs []*batcheslib.ChangesetSpec, repos []*graphql.Repository) error
+
+	// review, if set, is called with the validated changeset specs and
+	// returns the ones to upload. If ok is false, the batch spec is neither
+	// uploaded nor applied.
+	review func(specs []*batcheslib.ChangesetSpec, repos []*graphql.Repository) (approved []*batcheslib.ChangesetSpec, ok bool, err error)
+
+	// handleSpecs, if set, is called with the validated changeset specs and
+	// the repositories they were built for, instead of uploading the specs.
+	handleSpecs func(specs []*batcheslib.ChangesetSpec, repos []*graphql.Repository) error
+
+	// record, if set, is filled in with the spec and the results of the
+	// run, for the history of src batch apply.
+	record *history.Entry
+}
+
+// recordHistory updates the history entry of the run, if there is one.
+func (opts executeBatchSpecOpts) recordHistory(f func(e *history.Entry)) {
+	if opts.record != nil {
+		f(opts.record)
+	}
+}
+
+// checkRemoteWorkspace returns an error if the steps can't be executed in the
+// workspaces on a remote Docker daemon with the given options, since they'd
+// mount files of this machine into the containers.
+func checkRemoteWorkspace(creator workspace.Creator, sandbox executor.SandboxProfile, credentialOpts []string) error {
+	if creator.Type() != workspace.CreatorTypeRemote {
+		return nil
+	}
+	if sandbox == executor.SandboxStrict {
+		return cmderrors.Usage("the strict sandbox profile can't be used with a remote Docker host, since the files of steps can't be copied into containers with a read-only root filesystem: use another -sandbox profile")
+	}
+	if len(credentialOpts) > 0 {
+		return cmderrors.Usage("-forward-ssh-agent can't be used with a remote Docker host, since the SSH agent of this machine can't be mounted into its containers")
+	}
+	return nil
+}
+
+// reExecuteStaleRepos executes the steps again in the workspaces of the
+// repositories whose base branch moved, against the new head of the branch,
+// and replaces their changeset specs with the new ones.
+func reExecuteStaleRepos(
+	ctx context.Context,
+	opts executeBatchSpecOpts,
+	svc *service.Service,
+	coord *executor.Coordinator,
+	batchSpec *batcheslib.BatchSpec,
+	workspaces []service.RepoWorkspace,
+	specs []*batcheslib.ChangesetSpec,
+	staleRepos map[*graphql.Repository]string,
+) ([]*batcheslib.ChangesetSpec, error) {
+	staleIDs := map[string]bool{}
+	for repo, head := range staleRepos {
+		staleIDs[repo.ID] = true
+		if repo.Branch.Name != "" {
+			repo.Branch.Target.OID = head
+			repo.Commit.OID = head
+		} else {
+			repo.DefaultBranch.Target.OID = head
+		}
+	}
+
+	var staleWorkspaces []service.RepoWorkspace
+	for _, w := range workspaces {
+		if _, ok := staleRepos[w.Repo]; ok {
+			staleWorkspaces = append(staleWorkspaces, w)
+		}
+	}
+
+	tasks := svc.BuildTasks(ctx, batchSpec, staleWorkspaces)
+	uncachedTasks, cachedSpecs, err := coord.CheckCache(ctx, tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	taskExecUI := opts.ui.ExecutingTasks(*verbose, opts.flags.parallelism)
+	freshSpecs, _, err := coord.Execute(ctx, uncachedTasks, batchSpec, taskExecUI)
+	if err != nil && (!opts.flags.skipErrors || errors.Is(err, context.Canceled)) {
+		taskExecUI.Failed(err)
+		return nil, executionError(err)
+	}
+	if err == nil {
+		taskExecUI.Success()
+	} else {
+		opts.ui.ExecutingTasksSkippingErrors(err)
+	}
+
+	kept := make([]*batcheslib.ChangesetSpec, 0, len(specs))
+	for _, spec := range specs {
+		if !staleIDs[spec.BaseRepository] {
+			kept = append(kept, spec)
+		}
+	}
+	return append(append(kept, cachedSpecs...), freshSpecs...), nil
 }
 
 // executeBatchSpec performs all the steps required to upload the batch spec to
@@ -207,32 +564,91 @@ func executeBatchSpec(ctx context.Context, opts executeBatchSpecOpts) (err error
 		}
 	}()
 
+	sandbox, err := executor.ParseSandboxProfile(opts.flags.sandbox)
+	if err != nil {
+		return cmderrors.Usage(err.Error())
+	}
+
+	unlock, err := lockBatchCache(ctx, opts.flags.cacheDir, opts.flags.waitForLock)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if uerr := unlock(); uerr != nil && err == nil {
+			err = uerr
+		}
+	}()
+
+	tempDir, removeTempDir, err := batchRunTempDir(opts.flags.tempDir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if rerr := removeTempDir(); rerr != nil && err == nil {
+			err = rerr
+		}
+	}()
+
+	tracker, cleanup, err := batchRunTracker(opts.flags.cacheDir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := cleanup(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	resultsDir, templatesOnly, err := execResultsDir(opts.flags)
+	if err != nil {
+		return err
+	}
+
 	svc := service.New(&service.Opts{
 		AllowUnsupported: opts.flags.allowUnsupported,
 		AllowIgnored:     opts.flags.allowIgnored,
 		Client:           opts.client,
+		RegistryMirror:   opts.flags.registryMirror,
+		WithoutHostEnv:   opts.flags.fromExecResults != "",
 	})
 
 	if err := svc.DetermineFeatureFlags(ctx); err != nil {
 		return err
 	}
 
-	if err := checkExecutable("git", "version"); err != nil {
-		return err
+	switch opts.flags.onBranchCollision {
+	case branchCollisionWarn, branchCollisionSuffix, branchCollisionFail:
+	default:
+		return cmderrors.Usagef("-on-branch-collision must be %q, %q, or %q", branchCollisionWarn, branchCollisionSuffix, branchCollisionFail)
 	}
 
-	if err := checkExecutable("docker", "version"); err != nil {
-		return err
+	// Nothing is executed when only rendering templates or showing the
+	// commands of the steps.
+	if !templatesOnly && opts.flags.showCommands == "" {
+		if err := checkExecutable("git", "version"); err != nil {
+			return err
+		}
+
+		if err := checkExecutable("docker", "version"); err != nil {
+			return err
+		}
+	}
+
+	var credentialOpts []string
+	if opts.flags.forwardSSHAgent && !templatesOnly && opts.flags.showCommands == "" {
+		if credentialOpts, err = executor.ForwardSSHAgentOpts(); err != nil {
+			return cmderrors.WithKind(errors.Wrap(err, "-forward-ssh-agent"), cmderrors.KindValidation)
+		}
 	}
 
 	// Parse flags and build up our service and executor options.
 	opts.ui.ParsingBatchSpec()
-	batchSpec, rawSpec, err := parseBatchSpec(&opts.flags.file, svc)
+	batchSpec, rawSpec, err := parseBatchSpec(&opts.flags.file, opts.flags.params, svc)
 	if err != nil {
 		var multiErr *multierror.Error
 		if errors.As(err, &multiErr) {
 			opts.ui.ParsingBatchSpecFailure(multiErr)
-			return cmderrors.ExitCode(2, nil)
+			return cmderrors.Reported(cmderrors.WithKind(multiErr, cmderrors.KindValidation))
 		} else {
 			// This shouldn't happen; let's just punt and let the normal
 			// rendering occur.
@@ -240,6 +656,28 @@ func executeBatchSpec(ctx context.Context, opts executeBatchSpecOpts) (err error
 		}
 	}
 	opts.ui.ParsingBatchSpecSuccess()
+	// The changeset specs of -from-exec-results are rendered on a machine
+	// that may not have the environment of the one that executed the batch
+	// spec, so its secrets and dotenv files aren't needed, but the
+	// environment variables of its expressions have to be the same.
+	if opts.flags.fromExecResults != "" {
+		if err := checkLoadTimeEnv(resultsDir, svc.LoadTimeEnv()); err != nil {
+			return cmderrors.WithKind(err, cmderrors.KindValidation)
+		}
+	}
+	opts.recordHistory(func(e *history.Entry) {
+		e.Spec = rawSpec
+		e.SpecFile = opts.flags.file
+		e.Name = batchSpec.Name
+	})
+
+	if err := overrideCommitAuthor(batchSpec, opts.flags.commitAuthorName, opts.flags.commitAuthorEmail); err != nil {
+		return err
+	}
+
+	if opts.flags.showCommands != "" {
+		return showBatchStepCommands(ctx, os.Stdout, svc, batchSpec, sandbox, opts.flags.showCommands)
+	}
 
 	opts.ui.ResolvingNamespace()
 	namespace, err := svc.ResolveNamespace(ctx, opts.flags.namespace)
@@ -248,25 +686,61 @@ func executeBatchSpec(ctx context.Context, opts executeBatchSpecOpts) (err error
 	}
 	opts.ui.ResolvingNamespaceSuccess(namespace)
 
+	if templatesOnly {
+		if opts.flags.clearCache {
+			return errors.New("-templates-only cannot be used together with -clear-cache")
+		}
+
+		coord := svc.NewCoordinator(executor.NewCoordinatorOpts{
+			CacheDir:   resultsDir,
+			SkipErrors: opts.flags.skipErrors,
+			TempDir:    tempDir,
+		})
+
+		opts.ui.CheckingCache()
+		specs, repos, found, err := coord.RenderCachedTemplates(ctx, batchSpec)
+		if err != nil && !opts.flags.skipErrors {
+			return err
+		}
+		if !found {
+			if opts.flags.fromExecResults != "" {
+				return errors.Newf("no execution results found for the steps of this batch spec in %s", resultsDir)
+			}
+			return errors.New("no cached results found for the steps of this batch spec; run it once without -templates-only")
+		}
+		opts.ui.CheckingCacheSuccess(len(specs), 0)
+		if err != nil {
+			opts.ui.ExecutingTasksSkippingErrors(err)
+		}
+
+		if err := unlock(); err != nil {
+			return err
+		}
+		return uploadChangesetSpecs(ctx, opts, svc, namespace, batchSpec.Name, rawSpec, repos, specs)
+	}
+
 	var workspaceCreator workspace.Creator
 
 	if svc.HasDockerImages(batchSpec) {
 		opts.ui.PreparingContainerImages()
-		images, err := svc.EnsureDockerImages(ctx, batchSpec, opts.ui.PreparingContainerImagesProgress)
+		images, err := svc.EnsureDockerImages(ctx, batchSpec, opts.flags.pullParallelism, opts.ui.PreparingContainerImagesProgress)
 		if err != nil {
 			return err
 		}
 		opts.ui.PreparingContainerImagesSuccess()
 
 		opts.ui.DeterminingWorkspaceCreatorType()
-		workspaceCreator = workspace.NewCreator(ctx, opts.flags.workspace, opts.flags.cacheDir, opts.flags.tempDir, images)
-		if workspaceCreator.Type() == workspace.CreatorTypeVolume {
+		workspaceCreator = workspace.NewCreator(ctx, opts.flags.workspace, opts.flags.cacheDir, tempDir, images, tracker)
+		if workspaceCreator.Type() != workspace.CreatorTypeBind {
 			_, err = svc.EnsureImage(ctx, workspace.DockerVolumeWorkspaceImage)
 			if err != nil {
 				return err
 			}
 		}
 		opts.ui.DeterminingWorkspaceCreatorTypeSuccess(workspaceCreator.Type())
+		if err := checkRemoteWorkspace(workspaceCreator, sandbox, credentialOpts); err != nil {
+			return err
+		}
 	}
 
 	opts.ui.ResolvingRepositories()
@@ -289,19 +763,30 @@ func executeBatchSpec(ctx context.Context, opts executeBatchSpecOpts) (err error
 		return err
 	}
 	opts.ui.DeterminingWorkspacesSuccess(len(workspaces))
+	opts.recordHistory(func(e *history.Entry) { e.Workspaces = len(workspaces) })
 
 	// EXECUTION OF TASKS
 	coord := svc.NewCoordinator(executor.NewCoordinatorOpts{
-		Creator:       workspaceCreator,
-		CacheDir:      opts.flags.cacheDir,
-		ClearCache:    opts.flags.clearCache,
-		SkipErrors:    opts.flags.skipErrors,
-		CleanArchives: opts.flags.cleanArchives,
-		Parallelism:   opts.flags.parallelism,
-		Timeout:       opts.flags.timeout,
-		KeepLogs:      opts.flags.keepLogs,
-		TempDir:       opts.flags.tempDir,
+		Creator:        workspaceCreator,
+		CacheDir:       opts.flags.cacheDir,
+		ClearCache:     opts.flags.clearCache,
+		SkipErrors:     opts.flags.skipErrors,
+		CleanArchives:  opts.flags.cleanArchives,
+		Parallelism:    opts.flags.parallelism,
+		Timeout:        opts.flags.timeout,
+		KeepLogs:       opts.flags.keepLogs,
+		KeepWorkspaces: opts.flags.keepWorkspaces.value(),
+		CredentialOpts: credentialOpts,
+		TempDir:        tempDir,
+		Sandbox:        sandbox,
+		Tracker:        tracker,
+		Stop:           opts.stop,
 	})
+	defer func() {
+		if kept := coord.KeptWorkspaces(); len(kept) > 0 {
+			opts.ui.WorkspacesKept(kept)
+		}
+	}()
 
 	opts.ui.CheckingCache()
 	tasks := svc.BuildTasks(ctx, batchSpec, workspaces)
@@ -313,8 +798,8 @@ func executeBatchSpec(ctx context.Context, opts executeBatchSpecOpts) (err error
 
 	taskExecUI := opts.ui.ExecutingTasks(*verbose, opts.flags.parallelism)
 	freshSpecs, logFiles, err := coord.Execute(ctx, uncachedTasks, batchSpec, taskExecUI)
-	if err != nil && !opts.flags.skipErrors {
-		return err
+	if err != nil && (!opts.flags.skipErrors || errors.Is(err, context.Canceled)) {
+		return executionError(err)
 	}
 	if err == nil || opts.flags.skipErrors {
 		if err == nil {
@@ -325,36 +810,155 @@ func executeBatchSpec(ctx context.Context, opts executeBatchSpecOpts) (err error
 	} else {
 		if err != nil {
 			taskExecUI.Failed(err)
-			return err
+			return executionError(err)
 		}
 	}
 
 	if len(logFiles) > 0 && opts.flags.keepLogs {
 		opts.ui.LogFilesKept(logFiles)
 	}
+	if stats := coord.ArchiveCacheStats(); stats.Total() > 0 {
+		opts.ui.ArchiveCacheStats(stats)
+	}
 
 	specs := append(cachedSpecs, freshSpecs...)
 
-	err = svc.ValidateChangesetSpecs(repos, specs)
+	if opts.flags.checkBaseBranches || opts.flags.reExecuteStale {
+		opts.ui.CheckingBaseBranches()
+		checks, err := svc.CheckBaseBranches(ctx, specs, repos)
+		if err != nil {
+			return err
+		}
+		var stale, conflicting []string
+		staleRepos := map[*graphql.Repository]string{}
+		for _, c := range checks {
+			if !c.Stale {
+				continue
+			}
+			stale = append(stale, c.Repo.Name)
+			if c.Conflict {
+				conflicting = append(conflicting, c.Repo.Name)
+			}
+			staleRepos[c.Repo] = c.HeadRev
+		}
+		opts.ui.CheckingBaseBranchesSuccess(stale, conflicting)
+
+		if opts.flags.reExecuteStale && len(staleRepos) > 0 {
+			specs, err = reExecuteStaleRepos(ctx, opts, svc, coord, batchSpec, workspaces, specs, staleRepos)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := coord.CacheTemplateContexts(ctx, batchSpec, tasks); err != nil {
+		return err
+	}
+	if err := saveLoadTimeEnv(opts.flags.cacheDir, svc.LoadTimeEnv()); err != nil {
+		return err
+	}
+
+	// Other runs don't have to wait for the prompts and the upload, which
+	// don't use the cache.
+	if err := unlock(); err != nil {
+		return err
+	}
+	return uploadChangesetSpecs(ctx, opts, svc, namespace, batchSpec.Name, rawSpec, repos, specs)
+}
+
+// executionError marks an error returned by executing the steps of a batch
+// spec as an execution failure, unless the execution was interrupted.
+func executionError(err error) error {
+	var interrupted *executor.InterruptedError
+	if errors.As(err, &interrupted) {
+		if remaining := interrupted.Total - interrupted.Completed; remaining > 0 {
+			return cmderrors.WithKind(errors.Newf("%s; the results of the completed tasks are cached, run the same command again to execute the remaining %d", err, remaining), cmderrors.KindInterrupted)
+		}
+		return cmderrors.WithKind(errors.Newf("%s; the results of the tasks are cached, run the same command again to continue", err), cmderrors.KindInterrupted)
+	}
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+	return cmderrors.WithKind(err, cmderrors.KindExecution)
+}
+
+// uploadChangesetSpecs validates the changeset specs built for the given
+// repositories, uploads them together with the raw batch spec and applies the
+// resulting batch spec if specified.
+func uploadChangesetSpecs(
+	ctx context.Context,
+	opts executeBatchSpecOpts,
+	svc *service.Service,
+	namespace string,
+	name string,
+	rawSpec string,
+	repos []*graphql.Repository,
+	specs []*batcheslib.ChangesetSpec,
+) error {
+	opts.recordHistory(func(e *history.Entry) {
+		e.Repositories = len(repos)
+		e.ChangesetSpecs = len(specs)
+	})
+
+	err := svc.ValidateChangesetSpecs(repos, specs)
 	if err != nil {
 		return err
 	}
 
-	ids := make([]graphql.ChangesetSpecID, len(specs))
+	if opts.review != nil {
+		var ok bool
+		if specs, ok, err = opts.review(specs, repos); err != nil || !ok {
+			return err
+		}
+	}
 
-	if len(specs) > 0 {
-		opts.ui.UploadingChangesetSpecs(len(specs))
+	if err := svc.AddChangesetDependencies(repos, specs); err != nil {
+		return err
+	}
 
-		for i, spec := range specs {
-			id, err := svc.CreateChangesetSpec(ctx, spec)
-			if err != nil {
-				return err
-			}
-			ids[i] = id
-			opts.ui.UploadingChangesetSpecsProgress(i+1, len(specs))
+	if opts.handleSpecs != nil {
+		return opts.handleSpecs(specs, repos)
+	}
+
+	if err := checkChangesetSpecSizes(opts, svc, repos, specs); err != nil {
+		return err
+	}
+
+	if err := checkBranchCollisions(ctx, opts, svc, namespace, name, repos, specs); err != nil {
+		return err
+	}
+
+	if opts.skipUnchanged != nil {
+		digest, err := changesetSpecsDigest(specs)
+		if err != nil {
+			return err
+		}
+		if opts.skipUnchanged(digest) {
+			return nil
 		}
+	}
 
-		opts.ui.UploadingChangesetSpecsSuccess(ids)
+	if opts.confirm != nil {
+		if err := opts.confirm(namespace, rawSpec, specs, repos); err != nil {
+			return err
+		}
+	}
+
+	// Uploaded changeset specs are recorded, so that only the missing ones
+	// are sent again if uploading or creating the batch spec fails.
+	var uploads *service.ChangesetSpecUploads
+	if opts.flags.cacheDir != "" {
+		uploads, err = service.OpenChangesetSpecUploads(changesetSpecUploadsPath(opts.flags.cacheDir), cfg.Endpoint)
+		if err != nil {
+			return err
+		}
+	}
+
+	ids := []graphql.ChangesetSpecID{}
+	if len(specs) > 0 {
+		if ids, err = createChangesetSpecs(ctx, opts, svc, specs, uploads); err != nil {
+			return err
+		}
 	} else if len(repos) == 0 {
 		opts.ui.NoChangesetSpecs()
 	}
@@ -364,12 +968,15 @@ func executeBatchSpec(ctx context.Context, opts executeBatchSpecOpts) (err error
 	if err != nil {
 		return opts.ui.CreatingBatchSpecError(err)
 	}
+	if err := uploads.Clear(); err != nil {
+		return err
+	}
 	previewURL := cfg.Endpoint + url
 	opts.ui.CreatingBatchSpecSuccess(previewURL)
 
 	if !opts.applyBatchSpec {
 		opts.ui.PreviewBatchSpec(previewURL)
-		return
+		return nil
 	}
 
 	opts.ui.ApplyingBatchSpec()
@@ -378,13 +985,150 @@ func executeBatchSpec(ctx context.Context, opts executeBatchSpecOpts) (err error
 		return err
 	}
 	opts.ui.ApplyingBatchSpecSuccess(cfg.Endpoint + batch.URL)
+	opts.recordHistory(func(e *history.Entry) { e.BatchChangeURL = cfg.Endpoint + batch.URL })
+
+	return nil
+}
+
+// The values of -on-branch-collision.
+const (
+	branchCollisionWarn   = "warn"
+	branchCollisionSuffix = "suffix"
+	branchCollisionFail   = "fail"
+)
+
+// checkBranchCollisions looks for changeset specs whose branch is already in
+// use in their repository, which would otherwise only surface as an error of
+// the code host when the changeset is published, and handles them as
+// configured by -on-branch-collision.
+func checkBranchCollisions(
+	ctx context.Context,
+	opts executeBatchSpecOpts,
+	svc *service.Service,
+	namespace string,
+	name string,
+	repos []*graphql.Repository,
+	specs []*batcheslib.ChangesetSpec,
+) error {
+	opts.ui.CheckingBranchCollisions()
+	collisions, err := svc.CheckBranchCollisions(ctx, namespace, name, specs, repos)
+	if err != nil {
+		return err
+	}
+	if len(collisions) > 0 && opts.flags.onBranchCollision == branchCollisionSuffix {
+		if err := svc.SuffixBranches(ctx, namespace, name, collisions, specs); err != nil {
+			return err
+		}
+	}
+
+	descriptions := make([]string, 0, len(collisions))
+	for _, c := range collisions {
+		descriptions = append(descriptions, c.String())
+	}
+	opts.ui.CheckingBranchCollisionsSuccess(descriptions)
+
+	if len(collisions) > 0 && opts.flags.onBranchCollision == branchCollisionFail {
+		return errors.Newf("%d changeset specs have branches that are already in use. Change changesetTemplate.branch in the batch spec, or rerun with -on-branch-collision=suffix.", len(collisions))
+	}
+	return nil
+}
+
+// checkChangesetSpecSizes warns about the changeset specs that are larger
+// than most Sourcegraph instances accept, and fails if any is larger than
+// -max-changeset-size, so that an upload that would be rejected fails before
+// anything is uploaded, naming the repositories.
+func checkChangesetSpecSizes(
+	opts executeBatchSpecOpts,
+	svc *service.Service,
+	repos []*graphql.Repository,
+	specs []*batcheslib.ChangesetSpec,
+) error {
+	maxSize := opts.flags.maxChangesetSize.bytes
+	limit := service.ChangesetSpecSizeWarning
+	if maxSize > 0 && int(maxSize) < limit {
+		limit = int(maxSize)
+	}
+	large, err := svc.LargeChangesetSpecs(repos, specs, limit)
+	if err != nil || len(large) == 0 {
+		return err
+	}
 
+	descriptions := make([]string, 0, len(large))
+	var tooLarge []string
+	for _, l := range large {
+		descriptions = append(descriptions, l.String())
+		if maxSize > 0 && uint64(l.Size) > maxSize {
+			tooLarge = append(tooLarge, l.Repo)
+		}
+	}
+	opts.ui.LargeChangesetSpecs(descriptions)
+
+	if len(tooLarge) > 0 {
+		return cmderrors.WithKind(
+			errors.Newf("%d changeset specs are larger than -max-changeset-size=%s: %s. Reduce their diffs, for example by excluding generated files, or split the batch spec.", len(tooLarge), &opts.flags.maxChangesetSize, strings.Join(tooLarge, ", ")),
+			cmderrors.KindValidation,
+		)
+	}
 	return nil
 }
 
-// parseBatchSpec parses and validates the given batch spec. If the spec has
-// validation errors, they are returned.
-func parseBatchSpec(file *string, svc *service.Service) (*batcheslib.BatchSpec, string, error) {
+// byteSizeFlag is the value of a flag that's a size in bytes, which can be
+// given with a unit, as in "512KB" or "1MiB".
+type byteSizeFlag struct {
+	bytes uint64
+}
+
+func (f *byteSizeFlag) String() string {
+	if f.bytes == 0 {
+		return ""
+	}
+	return humanize.IBytes(f.bytes)
+}
+
+func (f *byteSizeFlag) Set(v string) error {
+	bytes, err := humanize.ParseBytes(v)
+	if err != nil {
+		return err
+	}
+	f.bytes = bytes
+	return nil
+}
+
+// changesetSpecUploadsPath returns the path of the journal of uploaded
+// changeset specs in the cache directory.
+func changesetSpecUploadsPath(cacheDir string) string {
+	return filepath.Join(cacheDir, "changeset-spec-uploads.jsonl")
+}
+
+// createChangesetSpecs uploads the changeset specs to Sourcegraph and returns
+// their IDs, reporting the progress to the UI. uploads may be nil.
+func createChangesetSpecs(
+	ctx context.Context,
+	opts executeBatchSpecOpts,
+	svc *service.Service,
+	specs []*batcheslib.ChangesetSpec,
+	uploads *service.ChangesetSpecUploads,
+) ([]graphql.ChangesetSpecID, error) {
+	opts.ui.UploadingChangesetSpecs(len(specs))
+
+	ids, err := svc.CreateChangesetSpecs(ctx, specs, service.CreateChangesetSpecsOpts{
+		Parallelism: opts.flags.uploadParallelism,
+		Retries:     opts.flags.uploadRetries,
+		Uploads:     uploads,
+		Progress:    opts.ui.UploadingChangesetSpecsProgress,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	opts.ui.UploadingChangesetSpecsSuccess(ids)
+	return ids, nil
+}
+
+// parseBatchSpec parses and validates the given batch spec, with the values of
+// its parameters given by -param. If the spec has validation errors, they are
+// returned.
+func parseBatchSpec(file *string, params []string, svc *service.Service) (*batcheslib.BatchSpec, string, error) {
 	f, err := batchOpenFileFlag(file)
 	if err != nil {
 		return nil, "", err
@@ -396,10 +1140,179 @@ func parseBatchSpec(file *string, svc *service.Service) (*batcheslib.BatchSpec,
 		return nil, "", errors.Wrap(err, "reading batch spec")
 	}
 
-	spec, err := svc.ParseBatchSpec(data)
+	values, err := parseBatchParams(params)
+	if err != nil {
+		return nil, "", err
+	}
+
+	spec, data, err := svc.ResolveBatchSpec(data, batchSpecDir(*file), values, os.LookupEnv)
 	return spec, string(data), err
 }
 
+// resolveBatchSpec merges the fragments that the batch spec read from file
+// includes into it, replaces its parameters and environment variables with
+// their values, and replaces its library steps, which, unlike the other fields
+// that src resolves, doesn't depend on the Sourcegraph instance.
+func resolveBatchSpec(data []byte, file string, params []string) ([]byte, error) {
+	values, err := parseBatchParams(params)
+	if err != nil {
+		return nil, err
+	}
+	return service.ResolveLocally(data, batchSpecDir(file), values, os.LookupEnv)
+}
+
+// addBatchParamFlag adds the -param flag, which sets the parameters of the
+// batch spec, to flagSet.
+func addBatchParamFlag(flagSet *flag.FlagSet, params *stringSliceFlag) {
+	flagSet.Var(params, "param", "Sets a parameter declared in the batch spec, as NAME=VALUE. Can be given multiple times.")
+}
+
+// parseBatchParams parses the values of -param.
+func parseBatchParams(params []string) (map[string]string, error) {
+	values := make(map[string]string, len(params))
+	for _, param := range params {
+		idx := strings.Index(param, "=")
+		if idx <= 0 {
+			return nil, cmderrors.Usagef("invalid -param %q, expected NAME=VALUE", param)
+		}
+		values[param[:idx]] = param[idx+1:]
+	}
+	return values, nil
+}
+
+// batchSpecDir returns the directory that relative paths in the batch spec
+// read from file, such as includes and local build contexts, are resolved
+// against.
+func batchSpecDir(file string) string {
+	if file == "" || file == "-" {
+		return "."
+	}
+	return filepath.Dir(file)
+}
+
+// execResultsDir returns the directory that changeset specs are rendered from
+// when only the changeset template is rendered, and whether that's the case.
+// Changeset specs are created from the results of an earlier execution, which
+// is in the cache directory of that execution.
+func execResultsDir(flags *batchExecuteFlags) (dir string, templatesOnly bool, err error) {
+	dir = flags.cacheDir
+	if flags.fromExecResults != "" {
+		if flags.templatesOnly || flags.clearCache {
+			return "", false, cmderrors.Usage("-from-exec-results cannot be used together with -templates-only or -clear-cache")
+		}
+		if _, err := os.Stat(flags.fromExecResults); err != nil {
+			return "", false, errors.Wrap(err, "reading execution results")
+		}
+		dir = flags.fromExecResults
+	}
+	templatesOnly = flags.templatesOnly || flags.fromExecResults != ""
+	if flags.showCommands != "" && templatesOnly {
+		return "", false, cmderrors.Usage("-show-commands cannot be used together with -templates-only or -from-exec-results")
+	}
+	return dir, templatesOnly, nil
+}
+
+// loadTimeEnvPath returns the path of the file in the cache directory dir
+// that the digests of the environment variables that replaced the
+// `${{ env.NAME }}` expressions of the executed batch specs are stored in, so
+// that -from-exec-results can check that they're the same. Their values aren't
+// stored, since they're often secrets.
+func loadTimeEnvPath(dir string) string {
+	return filepath.Join(dir, executor.TemplateContextsCacheKey{}.Slug(), "env.json")
+}
+
+// readLoadTimeEnv reads the digests of the environment variables stored by
+// saveLoadTimeEnv in the cache directory dir, by name.
+func readLoadTimeEnv(dir string) (map[string]string, error) {
+	digests := map[string]string{}
+	data, err := os.ReadFile(loadTimeEnvPath(dir))
+	if os.IsNotExist(err) {
+		return digests, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "reading environment of execution results")
+	}
+	if err := json.Unmarshal(data, &digests); err != nil {
+		return nil, errors.Wrap(err, "reading environment of execution results")
+	}
+	return digests, nil
+}
+
+// saveLoadTimeEnv adds the digests of the given environment variables to the
+// ones stored in the cache directory dir.
+func saveLoadTimeEnv(dir string, env map[string]string) error {
+	if len(env) == 0 {
+		return nil
+	}
+	saved, err := readLoadTimeEnv(dir)
+	if err != nil {
+		return err
+	}
+	for k, v := range env {
+		saved[k] = envDigest(v)
+	}
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	path := loadTimeEnvPath(dir)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// checkLoadTimeEnv returns an error naming the given environment variables
+// whose values differ from the ones the batch specs of the execution results
+// in the cache directory dir were executed with. Variables without a stored
+// digest aren't checked.
+func checkLoadTimeEnv(dir string, env map[string]string) error {
+	saved, err := readLoadTimeEnv(dir)
+	if err != nil {
+		return err
+	}
+
+	var differing []string
+	for k, v := range env {
+		if digest, ok := saved[k]; ok && digest != envDigest(v) {
+			differing = append(differing, k)
+		}
+	}
+	if len(differing) == 0 {
+		return nil
+	}
+	sort.Strings(differing)
+	return errors.Newf("the environment variables %s have different values than when the batch spec was executed; set them to the same values to use its execution results", strings.Join(differing, ", "))
+}
+
+// envDigest returns the digest of the value of an environment variable that
+// is stored instead of the value.
+func envDigest(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// overrideCommitAuthor replaces the commit author in the changeset template of
+// the given spec, if name and email are set. Both may contain the same
+// template variables as the fields in the spec.
+func overrideCommitAuthor(spec *batcheslib.BatchSpec, name, email string) error {
+	if name == "" && email == "" {
+		return nil
+	}
+	if name == "" || email == "" {
+		return cmderrors.Usage("-commit-author-name and -commit-author-email must be used together")
+	}
+	if spec.ChangesetTemplate == nil {
+		return nil
+	}
+
+	spec.ChangesetTemplate.Commit.Author = &batcheslib.GitCommitAuthor{
+		Name:  name,
+		Email: email,
+	}
+	return nil
+}
+
 func checkExecutable(cmd string, args ...string) error {
 	if err := exec.Command(cmd, args...).Run(); err != nil {
 		return fmt.Errorf(
@@ -413,10 +1326,49 @@ func checkExecutable(cmd string, args ...string) error {
 	return nil
 }
 
+// batchDefaultGracePeriod is the default of the -grace-period flag.
+const batchDefaultGracePeriod = 30 * time.Second
+
+// contextStopOnInterrupt shuts down in two stages on interrupts: the returned
+// stop channel is closed on the first interrupt, so that no new work is
+// started while the work that's running finishes and its results are kept,
+// and the returned context is canceled on a second interrupt, or once the
+// grace period after the first one passed.
+func contextStopOnInterrupt(parent context.Context, grace time.Duration) (context.Context, <-chan struct{}, func()) {
+	ctx, ctxCancel := context.WithCancel(parent)
+	stop := make(chan struct{})
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-c:
+		case <-ctx.Done():
+			return
+		}
+		close(stop)
+		fmt.Fprintf(os.Stderr, "\nInterrupted: waiting up to %s for the running steps to finish. Interrupt again to stop them now.\n", grace)
+
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+		select {
+		case <-c:
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+		ctxCancel()
+	}()
+
+	return ctx, stop, func() {
+		signal.Stop(c)
+		ctxCancel()
+	}
+}
+
 func contextCancelOnInterrupt(parent context.Context) (context.Context, func()) {
 	ctx, ctxCancel := context.WithCancel(parent)
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
 		select {
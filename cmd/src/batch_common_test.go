@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func TestOverrideCommitAuthor(t *testing.T) {
+	specAuthor := &batcheslib.GitCommitAuthor{Name: "Spec", Email: "spec@example.com"}
+	newSpec := func() *batcheslib.BatchSpec {
+		return &batcheslib.BatchSpec{
+			ChangesetTemplate: &batcheslib.ChangesetTemplate{
+				Commit: batcheslib.ExpandedGitCommitDescription{
+					Message: "Fix",
+					Author:  specAuthor,
+				},
+			},
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		name, email string
+		want        *batcheslib.GitCommitAuthor
+	}{
+		"no override": {want: specAuthor},
+		"override":    {name: "CI", email: "ci@example.com", want: &batcheslib.GitCommitAuthor{Name: "CI", Email: "ci@example.com"}},
+		"templated": {
+			name:  "${{ repository.name }} bot",
+			email: "bot@example.com",
+			want:  &batcheslib.GitCommitAuthor{Name: "${{ repository.name }} bot", Email: "bot@example.com"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			spec := newSpec()
+			if err := overrideCommitAuthor(spec, tc.name, tc.email); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, spec.ChangesetTemplate.Commit.Author); diff != "" {
+				t.Errorf("wrong author (-want +have):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("no changeset template", func(t *testing.T) {
+		spec := &batcheslib.BatchSpec{}
+		if err := overrideCommitAuthor(spec, "CI", "ci@example.com"); err != nil {
+			t.Fatal(err)
+		}
+		if spec.ChangesetTemplate != nil {
+			t.Errorf("unexpected changeset template: %+v", spec.ChangesetTemplate)
+		}
+	})
+
+	for name, tc := range map[string]struct{ name, email string }{
+		"only name":  {name: "CI"},
+		"only email": {email: "ci@example.com"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			spec := newSpec()
+			err := overrideCommitAuthor(spec, tc.name, tc.email)
+			if err == nil {
+				t.Fatal("unexpected nil error")
+			}
+			if kind := cmderrors.Classify(err); kind != cmderrors.KindUsage {
+				t.Errorf("wrong kind of error %q: %s", kind, err)
+			}
+			if diff := cmp.Diff(specAuthor, spec.ChangesetTemplate.Commit.Author); diff != "" {
+				t.Errorf("author changed (-want +have):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExecResultsDir(t *testing.T) {
+	resultsDir := t.TempDir()
+
+	for name, tc := range map[string]struct {
+		flags             batchExecuteFlags
+		wantDir           string
+		wantTemplatesOnly bool
+		wantKind          cmderrors.Kind
+	}{
+		"execute": {
+			flags:   batchExecuteFlags{cacheDir: "cache"},
+			wantDir: "cache",
+		},
+		"templates only": {
+			flags:             batchExecuteFlags{cacheDir: "cache", templatesOnly: true},
+			wantDir:           "cache",
+			wantTemplatesOnly: true,
+		},
+		"from exec results": {
+			flags:             batchExecuteFlags{cacheDir: "cache", fromExecResults: resultsDir},
+			wantDir:           resultsDir,
+			wantTemplatesOnly: true,
+		},
+		"from exec results with templates only": {
+			flags:    batchExecuteFlags{cacheDir: "cache", fromExecResults: resultsDir, templatesOnly: true},
+			wantKind: cmderrors.KindUsage,
+		},
+		"from exec results with clear cache": {
+			flags:    batchExecuteFlags{cacheDir: "cache", fromExecResults: resultsDir, clearCache: true},
+			wantKind: cmderrors.KindUsage,
+		},
+		"from exec results with show commands": {
+			flags:    batchExecuteFlags{cacheDir: "cache", fromExecResults: resultsDir, showCommands: "sh"},
+			wantKind: cmderrors.KindUsage,
+		},
+		"missing exec results": {
+			flags:    batchExecuteFlags{cacheDir: "cache", fromExecResults: filepath.Join(resultsDir, "missing")},
+			wantKind: cmderrors.KindFailure,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, templatesOnly, err := execResultsDir(&tc.flags)
+			if tc.wantKind != "" {
+				if err == nil {
+					t.Fatal("unexpected nil error")
+				}
+				if kind := cmderrors.Classify(err); kind != tc.wantKind {
+					t.Errorf("wrong kind of error %q: %s", kind, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dir != tc.wantDir {
+				t.Errorf("wrong directory. want=%q, have=%q", tc.wantDir, dir)
+			}
+			if templatesOnly != tc.wantTemplatesOnly {
+				t.Errorf("wrong templatesOnly. want=%t, have=%t", tc.wantTemplatesOnly, templatesOnly)
+			}
+		})
+	}
+
+	t.Run("missing exec results error", func(t *testing.T) {
+		_, _, err := execResultsDir(&batchExecuteFlags{fromExecResults: filepath.Join(resultsDir, "missing")})
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestLoadTimeEnv(t *testing.T) {
+	dir := t.TempDir()
+
+	env, err := readLoadTimeEnv(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(env) != 0 {
+		t.Fatalf("unexpected environment without execution results: %v", env)
+	}
+
+	if err := saveLoadTimeEnv(dir, map[string]string{"TEAM": "platform", "TOKEN": "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveLoadTimeEnv(dir, map[string]string{"TOKEN": "rotated"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(loadTimeEnvPath(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "platform") || strings.Contains(string(data), "rotated") {
+		t.Errorf("values of environment variables are stored: %s", data)
+	}
+
+	for name, tc := range map[string]struct {
+		env     map[string]string
+		wantErr string
+	}{
+		"same":     {env: map[string]string{"TEAM": "platform", "TOKEN": "rotated"}},
+		"unstored": {env: map[string]string{"OTHER": "value"}},
+		"different": {
+			env:     map[string]string{"TEAM": "web", "TOKEN": "secret"},
+			wantErr: "TEAM, TOKEN",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := checkLoadTimeEnv(dir, tc.env)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("want error naming %s, have %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestBatchRunTempDir(t *testing.T) {
+	tempDir := t.TempDir()
+
+	empty, removeEmpty, err := batchRunTempDir(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, removeKept, err := batchRunTempDir(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty == kept || filepath.Dir(empty) != tempDir || filepath.Dir(kept) != tempDir {
+		t.Fatalf("runs don't have their own directories in %s: %s and %s", tempDir, empty, kept)
+	}
+	if err := os.WriteFile(filepath.Join(kept, "kept.log"), []byte("log"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := removeEmpty(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(empty); !os.IsNotExist(err) {
+		t.Errorf("empty directory wasn't removed: %v", err)
+	}
+	if err := removeKept(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(kept, "kept.log")); err != nil {
+		t.Errorf("kept log was removed: %v", err)
+	}
+}
+
+func TestLockBatchCache(t *testing.T) {
+	ctx := context.Background()
+	cacheDir := t.TempDir()
+
+	unlock, err := lockBatchCache(ctx, cacheDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockBatchCache(ctx, cacheDir, false); err == nil {
+		t.Fatal("cache directory locked twice")
+	}
+
+	// Runs release the lock once they're done executing steps, and again in
+	// a deferred call.
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := unlock(); err != nil {
+		t.Fatalf("releasing the lock again: %v", err)
+	}
+
+	unlock, err = lockBatchCache(ctx, cacheDir, false)
+	if err != nil {
+		t.Fatalf("cache directory still locked: %v", err)
+	}
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
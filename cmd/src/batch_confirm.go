@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+	"github.com/sourcegraph/sourcegraph/lib/output"
+
+	"github.com/sourcegraph/src-cli/internal/batches/compare"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+// batchConfirmation is the checkpoint of 'src batch apply -confirm' between
+// executing the batch spec and uploading and applying it.
+type batchConfirmation struct {
+	out *output.Output
+
+	// tokenFile is the file given with -confirm-token. If it's empty, the
+	// user is asked for confirmation if stdin is a terminal.
+	tokenFile string
+
+	stdin       io.Reader
+	interactive bool
+}
+
+// confirm prints a summary of the changesets that applying the batch spec
+// creates or updates, and returns nil if they should be applied. That's the
+// case if the token in the token file matches the changes, or if the user
+// confirms them interactively. Otherwise, the token that confirms the changes
+// is printed, and an error of the kind cmderrors.KindNotConfirmed is
+// returned, so that src doesn't exit successfully without applying anything.
+func (c *batchConfirmation) confirm(namespace, rawSpec string, specs []*batcheslib.ChangesetSpec, repos []*graphql.Repository) error {
+	if err := printChangesetSpecSummary(c.out, specs, repos); err != nil {
+		return err
+	}
+
+	token, err := batchConfirmationToken(namespace, rawSpec, specs)
+	if err != nil {
+		return err
+	}
+
+	if c.tokenFile != "" {
+		data, err := os.ReadFile(c.tokenFile)
+		if err != nil {
+			return errors.Wrap(err, "reading confirmation token")
+		}
+		if strings.TrimSpace(string(data)) != token {
+			return cmderrors.WithKind(
+				errors.Newf("nothing was applied: the changes differ from the ones the confirmation token in %s was created for, review them again and update the token to %s", c.tokenFile, token),
+				cmderrors.KindNotConfirmed,
+			)
+		}
+		c.out.WriteLine(output.Line(output.EmojiSuccess, output.StyleSuccess, "The confirmation token matches the changes."))
+		return nil
+	}
+
+	if !c.interactive {
+		c.out.Write("")
+		c.out.WriteLine(output.Linef("", output.StyleBold, "Confirmation token: %s", token))
+		return notConfirmed("to apply these changes, write the token to a file and run the command again with -confirm-token FILE")
+	}
+
+	c.out.Write("")
+	c.out.Write("Apply these changes? [y/N] ")
+	answer, err := bufio.NewReader(c.stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	default:
+		return notConfirmed("the changes weren't confirmed")
+	}
+}
+
+// notConfirmed returns the error of changes that weren't confirmed.
+func notConfirmed(reason string) error {
+	return cmderrors.WithKind(errors.New("nothing was applied: "+reason), cmderrors.KindNotConfirmed)
+}
+
+// printChangesetSpecSummary prints the repository, branch, title and diff stat
+// of every changeset spec, and their totals.
+func printChangesetSpecSummary(out *output.Output, specs []*batcheslib.ChangesetSpec, repos []*graphql.Repository) error {
+	names := make(map[string]string, len(repos))
+	for _, r := range repos {
+		names[r.ID] = r.Name
+	}
+
+	type line struct {
+		name, text string
+	}
+	var lines []line
+	var total compare.Stat
+	imported := 0
+	for _, spec := range specs {
+		name := names[spec.BaseRepository]
+		if name == "" {
+			name = spec.BaseRepository
+		}
+
+		if spec.ExternalID != "" {
+			imported++
+			lines = append(lines, line{name: name, text: name + " #" + spec.ExternalID + ": imported"})
+			continue
+		}
+
+		var diff strings.Builder
+		for _, commit := range spec.Commits {
+			diff.WriteString(commit.Diff)
+		}
+		stat, err := compare.DiffStat(diff.String())
+		if err != nil {
+			return errors.Wrapf(err, "%s", name)
+		}
+		total.Added += stat.Added
+		total.Deleted += stat.Deleted
+
+		branch := strings.TrimPrefix(spec.HeadRef, "refs/heads/")
+		lines = append(lines, line{name: name, text: name + " " + branch + ": " + spec.Title + " (" + formatDiffStat(stat) + ")"})
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].name < lines[j].name })
+
+	out.Write("")
+	out.WriteLine(output.Line("", output.StyleBold, "Changesets to be created or updated:"))
+	for _, l := range lines {
+		out.Write("  " + l.text)
+	}
+	out.Write("")
+	out.WriteLine(output.Linef("", output.StyleBold, "%d changesets with changes (%s), %d imported", len(specs)-imported, formatDiffStat(total), imported))
+	return nil
+}
+
+// batchConfirmationToken returns the token that confirms applying the given
+// batch spec with the given changeset specs in the namespace. It changes
+// whenever any of them do.
+func batchConfirmationToken(namespace, rawSpec string, specs []*batcheslib.ChangesetSpec) (string, error) {
+	digest, err := changesetSpecsDigest(specs)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	for _, s := range []string{namespace, rawSpec, digest} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32], nil
+}
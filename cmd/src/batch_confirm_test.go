@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+	"github.com/sourcegraph/sourcegraph/lib/output"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func testConfirmationSpecs() ([]*batcheslib.ChangesetSpec, []*graphql.Repository) {
+	repos := []*graphql.Repository{
+		{ID: "repo-1", Name: "github.com/sourcegraph/b"},
+		{ID: "repo-2", Name: "github.com/sourcegraph/a"},
+	}
+	specs := []*batcheslib.ChangesetSpec{
+		{
+			BaseRepository: "repo-1",
+			HeadRef:        "refs/heads/fix",
+			Title:          "Fix b",
+			Commits: []batcheslib.GitCommitDescription{{
+				Diff: "diff --git a/x b/x\n--- a/x\n+++ b/x\n@@ -1 +1,2 @@\n-old\n+new\n+newer\n",
+			}},
+		},
+		{
+			BaseRepository: "repo-2",
+			HeadRef:        "refs/heads/fix",
+			Title:          "Fix a",
+			Commits: []batcheslib.GitCommitDescription{{
+				Diff: "diff --git a/y b/y\n--- a/y\n+++ b/y\n@@ -1,2 +1 @@\n-old\n-older\n+new\n",
+			}},
+		},
+		{BaseRepository: "repo-2", ExternalID: "123"},
+	}
+	return specs, repos
+}
+
+func TestBatchConfirmationToken(t *testing.T) {
+	specs, _ := testConfirmationSpecs()
+
+	token, err := batchConfirmationToken("user", "name: test", specs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token) != 32 {
+		t.Errorf("wrong token length: %q", token)
+	}
+
+	// The order of the changeset specs doesn't matter.
+	reversed := []*batcheslib.ChangesetSpec{specs[2], specs[1], specs[0]}
+	if same, err := batchConfirmationToken("user", "name: test", reversed); err != nil {
+		t.Fatal(err)
+	} else if same != token {
+		t.Errorf("token changed with the order of the changeset specs: %q != %q", same, token)
+	}
+
+	changedSpec := *specs[0]
+	changedSpec.Title = "Fix b differently"
+	for name, tc := range map[string]struct {
+		namespace, rawSpec string
+		specs              []*batcheslib.ChangesetSpec
+	}{
+		"namespace":      {namespace: "org", rawSpec: "name: test", specs: specs},
+		"batch spec":     {namespace: "user", rawSpec: "name: other", specs: specs},
+		"changeset spec": {namespace: "user", rawSpec: "name: test", specs: []*batcheslib.ChangesetSpec{&changedSpec, specs[1], specs[2]}},
+		"fewer specs":    {namespace: "user", rawSpec: "name: test", specs: specs[:2]},
+	} {
+		t.Run(name, func(t *testing.T) {
+			other, err := batchConfirmationToken(tc.namespace, tc.rawSpec, tc.specs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if other == token {
+				t.Error("token didn't change")
+			}
+		})
+	}
+}
+
+func TestPrintChangesetSpecSummary(t *testing.T) {
+	specs, repos := testConfirmationSpecs()
+
+	var buf bytes.Buffer
+	if err := printChangesetSpecSummary(output.NewOutput(&buf, output.OutputOpts{}), specs, repos); err != nil {
+		t.Fatal(err)
+	}
+
+	have := buf.String()
+	want := []string{
+		"Changesets to be created or updated:",
+		"github.com/sourcegraph/a fix: Fix a (+1 -2)",
+		"github.com/sourcegraph/a #123: imported",
+		"github.com/sourcegraph/b fix: Fix b (+2 -1)",
+		"2 changesets with changes (+3 -3), 1 imported",
+	}
+	rest := have
+	for _, line := range want {
+		i := strings.Index(rest, line)
+		if i < 0 {
+			t.Fatalf("missing or out of order %q in summary:\n%s", line, have)
+		}
+		rest = rest[i+len(line):]
+	}
+}
+
+func TestBatchConfirmationConfirm(t *testing.T) {
+	specs, repos := testConfirmationSpecs()
+	token, err := batchConfirmationToken("user", "name: test", specs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeToken := func(t *testing.T, content string) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "token.txt")
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	for name, tc := range map[string]struct {
+		confirmation func(t *testing.T) *batchConfirmation
+		wantErr      bool
+		wantOutput   string
+	}{
+		"matching token file": {
+			confirmation: func(t *testing.T) *batchConfirmation {
+				return &batchConfirmation{tokenFile: writeToken(t, token+"\n")}
+			},
+			wantOutput: "The confirmation token matches the changes.",
+		},
+		"mismatching token file": {
+			confirmation: func(t *testing.T) *batchConfirmation {
+				return &batchConfirmation{tokenFile: writeToken(t, "0123456789abcdef0123456789abcdef\n")}
+			},
+			wantErr: true,
+		},
+		"non-interactive": {
+			confirmation: func(t *testing.T) *batchConfirmation {
+				return &batchConfirmation{}
+			},
+			wantErr:    true,
+			wantOutput: "Confirmation token: " + token,
+		},
+		"confirmed": {
+			confirmation: func(t *testing.T) *batchConfirmation {
+				return &batchConfirmation{interactive: true, stdin: strings.NewReader("yes\n")}
+			},
+			wantOutput: "Apply these changes? [y/N]",
+		},
+		"declined": {
+			confirmation: func(t *testing.T) *batchConfirmation {
+				return &batchConfirmation{interactive: true, stdin: strings.NewReader("n\n")}
+			},
+			wantErr: true,
+		},
+		"end of input": {
+			confirmation: func(t *testing.T) *batchConfirmation {
+				return &batchConfirmation{interactive: true, stdin: strings.NewReader("")}
+			},
+			wantErr: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			c := tc.confirmation(t)
+			c.out = output.NewOutput(&buf, output.OutputOpts{})
+
+			err := c.confirm("user", "name: test", specs, repos)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("unexpected nil error")
+				}
+				if kind := cmderrors.Classify(err); kind != cmderrors.KindNotConfirmed {
+					t.Errorf("wrong kind of error %q: %s", kind, err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(buf.String(), tc.wantOutput) {
+				t.Errorf("output doesn't contain %q:\n%s", tc.wantOutput, buf.String())
+			}
+		})
+	}
+}
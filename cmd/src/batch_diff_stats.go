@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+	"github.com/sourcegraph/sourcegraph/lib/output"
+
+	"github.com/sourcegraph/src-cli/internal/batches/compare"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/ui"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src batch diff-stats' compares the changeset specs generated from a batch spec
+with changeset specs generated earlier, and reports the changesets whose diffs
+changed, that were added, or that disappeared. Use it to check that a change to
+a batch spec, or a new version of src, doesn't change more than intended before
+applying it.
+
+The steps in the batch spec given with -f are executed as with 'src batch
+preview', but nothing is uploaded. Alternatively, -changeset-specs reads
+changeset specs that were already built. -o writes the generated changeset
+specs as JSON lines, to be compared against later.
+
+Changesets are matched by repository and branch.
+
+Usage:
+
+    src batch diff-stats -compare FILE [-f FILE | -changeset-specs FILE] [command options]
+
+Examples:
+
+    $ src batch diff-stats -f batch.spec.yaml -o old-specs.jsonl
+    $ src batch diff-stats -f batch.spec.yaml -compare old-specs.jsonl
+
+    $ src batch diff-stats -changeset-specs new-specs.jsonl -compare old-specs.jsonl -exit-code
+
+`
+
+	flagSet := flag.NewFlagSet("diff-stats", flag.ExitOnError)
+	flags := newBatchExecuteFlags(flagSet, false, batchDefaultCacheDir(), batchDefaultTempDirPrefix())
+
+	var (
+		compareFlag        = flagSet.String("compare", "", "The file with the changeset specs to compare against, as a JSON array or one JSON object per line.")
+		changesetSpecsFlag = flagSet.String("changeset-specs", "", "Read changeset specs from this file instead of executing a batch spec.")
+		outFlag            = flagSet.String("o", "", "Write the generated changeset specs to this file as JSON lines.")
+		exitCodeFlag       = flagSet.Bool("exit-code", false, "Exit with status 1 if any changeset was changed, added or removed.")
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+		if *compareFlag == "" && *outFlag == "" {
+			return cmderrors.Usage("-compare or -o must be provided")
+		}
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+
+		out := newOutput(flagSet.Output(), *verbose)
+		client := cfg.apiClient(flags.api, flagSet.Output())
+
+		var differences bool
+		handle := func(specs []*batcheslib.ChangesetSpec, repos []*graphql.Repository) error {
+			if *outFlag != "" {
+				if err := writeChangesetSpecs(*outFlag, specs); err != nil {
+					return err
+				}
+			}
+			if *compareFlag == "" {
+				return nil
+			}
+
+			oldSpecs, err := readChangesetSpecs(*compareFlag)
+			if err != nil {
+				return err
+			}
+			oldRepos, err := changesetSpecRepositories(ctx, client, unknownRepositorySpecs(oldSpecs, repos))
+			if err != nil {
+				return err
+			}
+
+			differences, err = printChangesetSpecComparison(out, oldSpecs, specs, append(repos, oldRepos...))
+			return err
+		}
+
+		if *changesetSpecsFlag != "" {
+			specs, err := readChangesetSpecs(*changesetSpecsFlag)
+			if err != nil {
+				return err
+			}
+			repos, err := changesetSpecRepositories(ctx, client, specs)
+			if err != nil {
+				return err
+			}
+			if err := handle(specs, repos); err != nil {
+				return err
+			}
+		} else {
+			var execUI ui.ExecUI
+			if flags.textOnly {
+				execUI = &ui.JSONLines{}
+			} else {
+				execUI = &ui.TUI{Out: out, Plain: plainOutput()}
+			}
+
+			err := executeBatchSpec(ctx, executeBatchSpecOpts{
+				flags:       flags,
+				client:      client,
+				ui:          execUI,
+				handleSpecs: handle,
+			})
+			if err != nil {
+				return cmderrors.Reported(err)
+			}
+		}
+
+		if differences && *exitCodeFlag {
+			return cmderrors.ExitCode(1, nil)
+		}
+		return nil
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+// printChangesetSpecComparison compares the old and new changeset specs and
+// prints the changesets that differ. It returns true if any do.
+func printChangesetSpecComparison(out *output.Output, oldSpecs, newSpecs []*batcheslib.ChangesetSpec, repos []*graphql.Repository) (bool, error) {
+	results, err := compare.Changesets(comparableChangesets(oldSpecs), comparableChangesets(newSpecs))
+	if err != nil {
+		return false, err
+	}
+
+	names := make(map[string]string, len(repos))
+	for _, r := range repos {
+		names[r.ID] = r.Name
+	}
+
+	counts := map[compare.Status]int{}
+	for _, r := range results {
+		counts[r.Status]++
+
+		c := r.Changeset()
+		name := names[c.Repository]
+		if name == "" {
+			name = c.Repository
+		}
+		if c.ExternalID != "" {
+			name += " #" + c.ExternalID
+		} else {
+			name += " " + strings.TrimPrefix(c.Branch, "refs/heads/")
+		}
+
+		switch r.Status {
+		case compare.Added:
+			out.WriteLine(output.Linef("+", output.StyleLinesAdded, "%s: added (%s)", name, formatDiffStat(r.NewStat)))
+		case compare.Removed:
+			out.WriteLine(output.Linef("-", output.StyleLinesDeleted, "%s: removed (was %s)", name, formatDiffStat(r.OldStat)))
+		case compare.Changed:
+			out.WriteLine(output.Linef("~", output.StyleWarning, "%s: diff changed (%s, was %s)", name, formatDiffStat(r.NewStat), formatDiffStat(r.OldStat)))
+		default:
+			out.Verbosef("%s: unchanged", name)
+		}
+	}
+
+	out.Write("")
+	out.WriteLine(output.Linef("", output.StyleBold, "%d changed, %d added, %d removed, %d unchanged",
+		counts[compare.Changed], counts[compare.Added], counts[compare.Removed], counts[compare.Unchanged]))
+
+	return counts[compare.Changed]+counts[compare.Added]+counts[compare.Removed] > 0, nil
+}
+
+func formatDiffStat(s compare.Stat) string {
+	return fmt.Sprintf("+%d -%d", s.Added, s.Deleted)
+}
+
+// comparableChangesets converts changeset specs to the changesets compared by
+// the compare package.
+func comparableChangesets(specs []*batcheslib.ChangesetSpec) []*compare.Changeset {
+	changesets := make([]*compare.Changeset, 0, len(specs))
+	for _, spec := range specs {
+		c := &compare.Changeset{
+			Repository: spec.BaseRepository,
+			Branch:     spec.HeadRef,
+			ExternalID: spec.ExternalID,
+		}
+		var diff strings.Builder
+		for _, commit := range spec.Commits {
+			diff.WriteString(commit.Diff)
+		}
+		c.Diff = diff.String()
+		changesets = append(changesets, c)
+	}
+	return changesets
+}
+
+// unknownRepositorySpecs returns the specs whose repository isn't in repos.
+func unknownRepositorySpecs(specs []*batcheslib.ChangesetSpec, repos []*graphql.Repository) []*batcheslib.ChangesetSpec {
+	known := make(map[string]bool, len(repos))
+	for _, r := range repos {
+		known[r.ID] = true
+	}
+
+	var unknown []*batcheslib.ChangesetSpec
+	for _, spec := range specs {
+		if !known[spec.BaseRepository] {
+			unknown = append(unknown, spec)
+		}
+	}
+	return unknown
+}
+
+// writeChangesetSpecs writes the changeset specs to the file, one JSON object
+// per line, so that readChangesetSpecs can read them again.
+func writeChangesetSpecs(file string, specs []*batcheslib.ChangesetSpec) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return errors.Wrapf(err, "creating %s", file)
+	}
+
+	enc := json.NewEncoder(f)
+	for _, spec := range specs {
+		if err := enc.Encode(spec); err != nil {
+			f.Close()
+			return errors.Wrapf(err, "writing %s", file)
+		}
+	}
+	return f.Close()
+}
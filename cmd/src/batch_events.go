@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src batch events' prints the state transitions of the changesets of a batch
+change, such as opened, review-requested, ci-failed, and merged, as
+newline-delimited JSON.
+
+The events leading to the current state of the changesets are printed first.
+With -follow, the changesets are then fetched every -interval, and the
+transitions since the previous fetch are printed as they're observed, so
+that chat bots and other automation can react to them. Errors while
+following are reported on stderr and retried at the next interval.
+
+Every event has the fields time, event, changeset, repository, url (if the
+changeset is published), field (state, reviewState, or checkState), from, and
+to. Changesets that are no longer part of the batch change produce a removed
+event.
+
+Usage:
+
+    src batch events -name NAME [command options]
+
+Examples:
+
+    $ src batch events -name hello-world
+
+    $ src batch events -name hello-world -follow -interval 1m | jq 'select(.event == "ci-failed")'
+
+`
+
+	flagSet := flag.NewFlagSet("events", flag.ExitOnError)
+
+	var (
+		nameFlag      = flagSet.String("name", "", "The name of the batch change.")
+		namespaceFlag = flagSet.String("namespace", "", "The user or organization namespace of the batch change. Default is the currently authenticated user.")
+		followFlag    = flagSet.Bool("follow", false, "Keep printing the transitions of the changesets as they're observed.")
+		intervalFlag  = flagSet.Duration("interval", 30*time.Second, "How often to fetch the changesets with -follow.")
+		apiFlags      = api.NewFlags(flagSet)
+	)
+	flagSet.StringVar(namespaceFlag, "n", "", "Alias for -namespace.")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+		if *nameFlag == "" {
+			return cmderrors.Usage("-name must be provided")
+		}
+		if *intervalFlag <= 0 {
+			return cmderrors.Usage("-interval must be positive")
+		}
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+
+		svc := service.New(&service.Opts{
+			Client: cfg.apiClient(apiFlags, flagSet.Output()),
+		})
+
+		namespace, err := svc.ResolveNamespace(ctx, *namespaceFlag)
+		if err != nil {
+			return err
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		emit := func(events []service.ChangesetEvent) error {
+			for _, e := range events {
+				if err := enc.Encode(e); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		_, changesets, err := svc.FetchChangesets(ctx, namespace, *nameFlag)
+		if err != nil {
+			return err
+		}
+		if err := emit(service.ChangesetEvents(nil, changesets, time.Now().UTC())); err != nil {
+			return err
+		}
+		if !*followFlag {
+			return nil
+		}
+
+		ticker := time.NewTicker(*intervalFlag)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+
+			_, next, err := svc.FetchChangesets(ctx, namespace, *nameFlag)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				fmt.Fprintf(os.Stderr, "fetching changesets: %s\n", err)
+				continue
+			}
+			if err := emit(service.ChangesetEvents(changesets, next, time.Now().UTC())); err != nil {
+				return err
+			}
+			changesets = next
+		}
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
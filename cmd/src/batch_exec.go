@@ -35,10 +35,29 @@ Examples:
 
     $ src batch exec -f batch-spec-with-workspaces.json
 
+    $ src batch exec -f batch-spec-with-workspaces.json -emit-events events.jsonl
+
+Events:
+
+With -emit-events, a JSON object is written on a single line for each of the
+following task lifecycle events. Every event has the fields "type",
+"timestamp", "repository" and "workspace"; steps are numbered starting at 1.
+
+    queued         the task is going to be executed
+    cached         the task's results were found in the cache; if only some
+                   steps were cached, "step" is the last step skipped
+    downloading    the repository archive is being downloaded
+    step-started   the step "step" has started
+    step-finished  the step "step" has finished with "exitCode"; "error" is
+                   set if it failed, and "exitCode" is -1 if the container
+                   didn't run to completion
+    done           the task is finished; "error" is set if it failed
+
 `
 
 	flagSet := flag.NewFlagSet("exec", flag.ExitOnError)
 	flags := newBatchExecuteFlags(flagSet, true, batchDefaultCacheDir(), batchDefaultTempDirPrefix())
+	metricsAddrFlag := addMetricsAddrFlag(flagSet)
 
 	handler := func(args []string) error {
 		if err := flagSet.Parse(args); err != nil {
@@ -49,16 +68,23 @@ Examples:
 			return cmderrors.Usage("additional arguments not allowed")
 		}
 
-		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		stopMetrics, err := serveMetrics(*metricsAddrFlag)
+		if err != nil {
+			return err
+		}
+		defer stopMetrics()
+
+		ctx, stop, cancel := contextStopOnInterrupt(context.Background(), flags.gracePeriod)
 		defer cancel()
 
-		err := executeBatchSpecInWorkspaces(ctx, executeBatchSpecOpts{
+		err = executeBatchSpecInWorkspaces(ctx, executeBatchSpecOpts{
 			flags:  flags,
 			client: cfg.apiClient(flags.api, flagSet.Output()),
 			ui:     &ui.JSONLines{},
+			stop:   stop,
 		})
 		if err != nil {
-			return cmderrors.ExitCode(1, nil)
+			return cmderrors.Reported(err)
 		}
 
 		return nil
@@ -82,10 +108,46 @@ func executeBatchSpecInWorkspaces(ctx context.Context, opts executeBatchSpecOpts
 		}
 	}()
 
+	sandbox, err := executor.ParseSandboxProfile(opts.flags.sandbox)
+	if err != nil {
+		return cmderrors.Usage(err.Error())
+	}
+
+	unlock, err := lockBatchCache(ctx, opts.flags.cacheDir, opts.flags.waitForLock)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if uerr := unlock(); uerr != nil && err == nil {
+			err = uerr
+		}
+	}()
+
+	tempDir, removeTempDir, err := batchRunTempDir(opts.flags.tempDir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if rerr := removeTempDir(); rerr != nil && err == nil {
+			err = rerr
+		}
+	}()
+
+	tracker, cleanup, err := batchRunTracker(opts.flags.cacheDir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := cleanup(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
 	svc := service.New(&service.Opts{
 		AllowUnsupported: opts.flags.allowUnsupported,
 		AllowIgnored:     opts.flags.allowIgnored,
 		Client:           opts.client,
+		RegistryMirror:   opts.flags.registryMirror,
 	})
 	if err := svc.DetermineFeatureFlags(ctx); err != nil {
 		return err
@@ -116,7 +178,7 @@ func executeBatchSpecInWorkspaces(ctx context.Context, opts executeBatchSpecOpts
 		var multiErr *multierror.Error
 		if errors.As(err, &multiErr) {
 			opts.ui.ParsingBatchSpecFailure(multiErr)
-			return cmderrors.ExitCode(2, nil)
+			return cmderrors.Reported(cmderrors.WithKind(multiErr, cmderrors.KindValidation))
 		} else {
 			// This shouldn't happen; let's just punt and let the normal
 			// rendering occur.
@@ -129,21 +191,24 @@ func executeBatchSpecInWorkspaces(ctx context.Context, opts executeBatchSpecOpts
 
 	if svc.HasDockerImages(batchSpec) {
 		opts.ui.PreparingContainerImages()
-		images, err := svc.EnsureDockerImages(ctx, batchSpec, opts.ui.PreparingContainerImagesProgress)
+		images, err := svc.EnsureDockerImages(ctx, batchSpec, opts.flags.pullParallelism, opts.ui.PreparingContainerImagesProgress)
 		if err != nil {
 			return err
 		}
 		opts.ui.PreparingContainerImagesSuccess()
 
 		opts.ui.DeterminingWorkspaceCreatorType()
-		workspaceCreator = workspace.NewCreator(ctx, opts.flags.workspace, opts.flags.cacheDir, opts.flags.tempDir, images)
-		if workspaceCreator.Type() == workspace.CreatorTypeVolume {
+		workspaceCreator = workspace.NewCreator(ctx, opts.flags.workspace, opts.flags.cacheDir, tempDir, images, tracker)
+		if workspaceCreator.Type() != workspace.CreatorTypeBind {
 			_, err = svc.EnsureImage(ctx, workspace.DockerVolumeWorkspaceImage)
 			if err != nil {
 				return err
 			}
 		}
 		opts.ui.DeterminingWorkspaceCreatorTypeSuccess(workspaceCreator.Type())
+		if err := checkRemoteWorkspace(workspaceCreator, sandbox, nil); err != nil {
+			return err
+		}
 	}
 
 	// EXECUTION OF TASKS
@@ -156,7 +221,10 @@ func executeBatchSpecInWorkspaces(ctx context.Context, opts executeBatchSpecOpts
 		Parallelism:   opts.flags.parallelism,
 		Timeout:       opts.flags.timeout,
 		KeepLogs:      opts.flags.keepLogs,
-		TempDir:       opts.flags.tempDir,
+		TempDir:       tempDir,
+		Sandbox:       sandbox,
+		Tracker:       tracker,
+		Stop:          opts.stop,
 	})
 
 	opts.ui.CheckingCache()
@@ -168,6 +236,17 @@ func executeBatchSpecInWorkspaces(ctx context.Context, opts executeBatchSpecOpts
 	opts.ui.CheckingCacheSuccess(len(cachedSpecs), len(uncachedTasks))
 
 	taskExecUI := opts.ui.ExecutingTasks(*verbose, opts.flags.parallelism)
+	if opts.flags.emitEvents != "" {
+		w, err := batchOpenEventsFlag(opts.flags.emitEvents)
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+
+		events := ui.NewTaskEventStream(w)
+		events.Cached(cachedTasks(tasks, uncachedTasks))
+		taskExecUI = events.Wrap(taskExecUI)
+	}
 	freshSpecs, _, err := coord.Execute(ctx, uncachedTasks, batchSpec, taskExecUI)
 	if err == nil || opts.flags.skipErrors {
 		if err == nil {
@@ -178,32 +257,42 @@ func executeBatchSpecInWorkspaces(ctx context.Context, opts executeBatchSpecOpts
 	} else {
 		if err != nil {
 			taskExecUI.Failed(err)
-			return err
+			return executionError(err)
 		}
 	}
 
 	specs := append(cachedSpecs, freshSpecs...)
 
-	ids := make([]graphql.ChangesetSpecID, len(specs))
-
+	if err := unlock(); err != nil {
+		return err
+	}
 	if len(specs) > 0 {
-		opts.ui.UploadingChangesetSpecs(len(specs))
-
-		for i, spec := range specs {
-			id, err := svc.CreateChangesetSpec(ctx, spec)
-			if err != nil {
-				return err
-			}
-			ids[i] = id
-			opts.ui.UploadingChangesetSpecsProgress(i+1, len(specs))
+		// The uploaded changeset specs are attached by the server, so they're
+		// never reused and don't need to be recorded.
+		if _, err := createChangesetSpecs(ctx, opts, svc, specs, nil); err != nil {
+			return err
 		}
-
-		opts.ui.UploadingChangesetSpecsSuccess(ids)
 	}
 
 	return nil
 }
 
+// cachedTasks returns the tasks that are not included in uncached.
+func cachedTasks(tasks, uncached []*executor.Task) []*executor.Task {
+	isUncached := make(map[*executor.Task]bool, len(uncached))
+	for _, t := range uncached {
+		isUncached[t] = true
+	}
+
+	var cached []*executor.Task
+	for _, t := range tasks {
+		if !isUncached[t] {
+			cached = append(cached, t)
+		}
+	}
+	return cached
+}
+
 func loadWorkspaceExecutionInput(file string) (batcheslib.WorkspacesExecutionInput, error) {
 	var input batcheslib.WorkspacesExecutionInput
 
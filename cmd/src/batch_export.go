@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src batch export' exports a batch change, so that it can be imported on
+another Sourcegraph instance with 'src batch import'.
+
+The export contains the current batch spec of the batch change and the specs
+and states of its changesets. Repositories are referenced by name, so they need
+to exist under the same name on the instance the batch change is imported on.
+
+Usage:
+
+    src batch export -name NAME [command options]
+
+Examples:
+
+    $ src batch export -name hello-world -o hello-world.json
+
+    $ src batch export -name hello-world -namespace myorg > hello-world.json
+
+`
+
+	flagSet := flag.NewFlagSet("export", flag.ExitOnError)
+
+	var (
+		nameFlag      = flagSet.String("name", "", "The name of the batch change to export.")
+		namespaceFlag = flagSet.String("namespace", "", "The user or organization namespace of the batch change. Default is the currently authenticated user.")
+		outputFlag    = flagSet.String("o", "", "The file to write the export to. Default is standard output.")
+		apiFlags      = api.NewFlags(flagSet)
+	)
+	flagSet.StringVar(namespaceFlag, "n", "", "Alias for -namespace.")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+		if *nameFlag == "" {
+			return cmderrors.Usage("-name must be provided")
+		}
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+
+		svc := service.New(&service.Opts{
+			Client: cfg.apiClient(apiFlags, flagSet.Output()),
+		})
+
+		namespace, err := svc.ResolveNamespace(ctx, *namespaceFlag)
+		if err != nil {
+			return err
+		}
+
+		export, err := svc.ExportBatchChange(ctx, namespace, *nameFlag)
+		if err != nil {
+			return err
+		}
+
+		var w io.Writer = os.Stdout
+		if *outputFlag != "" {
+			f, err := os.Create(*outputFlag)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(export); err != nil {
+			return err
+		}
+
+		if *outputFlag != "" {
+			fmt.Printf("Exported batch change %q with %d changesets to %s.\n", export.Name, len(export.Changesets), *outputFlag)
+		}
+		return nil
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sourcegraph/src-cli/internal/batches/history"
+)
+
+var batchHistoryCommands commander
+
+func init() {
+	usage := `'src batch history' shows the batch specs applied with 'src batch apply' on
+this machine, which are recorded with the instance, the URL of the batch
+change, the version of src, how long the run took, and how many repositories
+and changeset specs it had.
+
+Usage:
+
+	src batch history command [command options]
+
+The commands are:
+
+	list    lists the recorded runs
+	show    shows a recorded run and its batch spec
+
+Use "src batch history [command] -h" for more information about a command.
+`
+
+	flagSet := flag.NewFlagSet("history", flag.ExitOnError)
+	handler := func(args []string) error {
+		batchHistoryCommands.run(flagSet, "src batch history", usage, args)
+		return nil
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Println(usage)
+		},
+	})
+}
+
+// batchDefaultHistoryDir returns the directory the history of src batch apply
+// is kept in by default. Unlike the cache directory, it's not meant to be
+// cleared.
+func batchDefaultHistoryDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "sourcegraph", "batch-history")
+}
+
+// recordBatchApply completes the history entry of a run of src batch apply
+// that returned err, and records it in the history in dir. Failing to record
+// the run is only reported, since the run itself is done.
+func recordBatchApply(dir string, e *history.Entry, err error) {
+	if dir == "" {
+		return
+	}
+
+	e.Duration = time.Since(e.Started)
+	switch {
+	case err != nil:
+		e.Result = history.ResultFailed
+		e.Error = err.Error()
+	case e.BatchChangeURL != "":
+		e.Result = history.ResultApplied
+	default:
+		e.Result = history.ResultNotApplied
+	}
+
+	if err := history.Open(dir).Record(e); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: recording the run in the batch apply history: %s\n", err)
+	}
+}
+
+// batchHistoryColumns are the columns of the table output of src batch
+// history list.
+var batchHistoryColumns = []tableColumn{
+	{name: "id", template: "{{.ID}}"},
+	{name: "started", template: `{{.Started.Format "2006-01-02T15:04:05Z07:00"}}`},
+	{name: "duration", template: "{{.Duration}}"},
+	{name: "instance", template: "{{.Instance}}"},
+	{name: "src-version", template: "{{.SrcVersion}}"},
+	{name: "name", template: "{{.Name}}"},
+	{name: "namespace", template: "{{.Namespace}}"},
+	{name: "spec-file", template: "{{.SpecFile}}"},
+	{name: "spec-hash", template: "{{.SpecHash}}"},
+	{name: "repositories", template: "{{.Repositories}}"},
+	{name: "workspaces", template: "{{.Workspaces}}"},
+	{name: "changeset-specs", template: "{{.ChangesetSpecs}}"},
+	{name: "result", template: "{{.Result}}"},
+	{name: "batch-change", template: "{{.BatchChangeURL}}"},
+	{name: "error", template: "{{.Error}}"},
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sourcegraph/src-cli/internal/batches/history"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+Examples:
+
+  List the runs of 'src batch apply', oldest first:
+
+    	$ src batch history list
+
+  List the runs of the last week that applied the batch spec named hello-world:
+
+    	$ src batch history list -since=168h -name=hello-world
+
+  List the failed runs as a table with their errors:
+
+    	$ src batch history list -result=failed -columns=id,started,name,error
+
+  Print the last run as JSON:
+
+    	$ src batch history list -last=1 -f='{{.|json}}'
+
+`
+
+	flagSet := flag.NewFlagSet("list", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch history %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		historyDirFlag = flagSet.String("history-dir", batchDefaultHistoryDir(), "The directory of the history of applied batch specs.")
+		sinceFlag      = flagSet.Duration("since", 0, `Only list the runs that started within this duration. (e.g. "24h")`)
+		nameFlag       = flagSet.String("name", "", "Only list the runs of batch specs with this name.")
+		instanceFlag   = flagSet.String("instance", "", "Only list the runs against the Sourcegraph instance with this endpoint.")
+		resultFlag     = flagSet.String("result", "", `Only list the runs with this result: "applied", "not-applied" or "failed".`)
+		lastFlag       = flagSet.Int("last", 0, "Only list the n most recent runs. (use 0 for all)")
+		formatFlag     = flagSet.String("f", `{{.ID}}  {{.Started.Format "2006-01-02T15:04:05Z07:00"}}  {{padRight .Result 11 " "}}  {{.Name}}  {{.Instance}}`, `Format for the output, using the syntax of Go package text/template. (e.g. "{{.ID}}: {{.BatchChangeURL}}" or "{{.|json}}")`)
+		tableFlags     = newTableFlags(flagSet, batchHistoryColumns, "id", "started", "result", "name", "changeset-specs", "batch-change")
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+		switch history.Result(*resultFlag) {
+		case "", history.ResultApplied, history.ResultNotApplied, history.ResultFailed:
+		default:
+			return cmderrors.Usagef("invalid -result %q", *resultFlag)
+		}
+
+		tmpl, err := parseTemplate(*formatFlag)
+		if err != nil {
+			return err
+		}
+		tbl, err := tableFlags.table(flagSet)
+		if err != nil {
+			return err
+		}
+
+		entries, err := history.Open(*historyDirFlag).List()
+		if err != nil {
+			return err
+		}
+
+		var matching []*history.Entry
+		for _, e := range entries {
+			if *sinceFlag > 0 && time.Since(e.Started) > *sinceFlag {
+				continue
+			}
+			if *nameFlag != "" && e.Name != *nameFlag {
+				continue
+			}
+			if *instanceFlag != "" && e.Instance != strings.TrimSuffix(*instanceFlag, "/") {
+				continue
+			}
+			if *resultFlag != "" && string(e.Result) != *resultFlag {
+				continue
+			}
+			matching = append(matching, e)
+		}
+		if *lastFlag > 0 && len(matching) > *lastFlag {
+			matching = matching[len(matching)-*lastFlag:]
+		}
+
+		for _, e := range matching {
+			if tbl != nil {
+				if err := tbl.add(e); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := execTemplate(tmpl, e); err != nil {
+				return err
+			}
+		}
+		if tbl != nil {
+			return tbl.write(os.Stdout)
+		}
+		return nil
+	}
+
+	batchHistoryCommands = append(batchHistoryCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/batches/history"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+Examples:
+
+  Show the last run of 'src batch apply':
+
+    	$ src batch history show
+
+  Show a run by (a prefix of) its ID, as listed by 'src batch history list':
+
+    	$ src batch history show 3f9a1c
+
+  Print the batch spec that was applied in a run, to apply it again:
+
+    	$ src batch history show -spec 3f9a1c > again.batch.yaml
+
+`
+
+	flagSet := flag.NewFlagSet("show", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch history %s':\n", flagSet.Name())
+		fmt.Fprintf(flag.CommandLine.Output(), "  src batch history %s [options] [ID|last]\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		historyDirFlag = flagSet.String("history-dir", batchDefaultHistoryDir(), "The directory of the history of applied batch specs.")
+		specFlag       = flagSet.Bool("spec", false, "Only print the batch spec that was applied.")
+		formatFlag     = flagSet.String("f", `ID:              {{.ID}}
+Started:         {{.Started.Format "2006-01-02T15:04:05Z07:00"}} ({{.Duration}})
+Instance:        {{.Instance}}
+src version:     {{.SrcVersion}}
+Batch spec:      {{.Name}}{{with .SpecFile}} ({{.}}){{end}}
+Spec hash:       {{.SpecHash}}
+Namespace:       {{or .Namespace "(user)"}}
+Repositories:    {{.Repositories}}
+Workspaces:      {{.Workspaces}}
+Changeset specs: {{.ChangesetSpecs}}
+Result:          {{.Result}}{{with .BatchChangeURL}}
+Batch change:    {{.}}{{end}}{{with .Error}}
+Error:           {{.}}{{end}}`, `Format for the output, using the syntax of Go package text/template. (e.g. "{{.BatchChangeURL}}" or "{{.|json}}")`)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		ref := "last"
+		switch flagSet.NArg() {
+		case 0:
+		case 1:
+			ref = flagSet.Arg(0)
+		default:
+			return cmderrors.Usage("expected at most one ID")
+		}
+
+		tmpl, err := parseTemplate(*formatFlag)
+		if err != nil {
+			return err
+		}
+
+		h := history.Open(*historyDirFlag)
+		e, err := h.Find(ref)
+		if err != nil {
+			if errors.Is(err, history.ErrNotFound) && ref != "last" {
+				return cmderrors.Usagef("no run with ID %q in the batch apply history", ref)
+			}
+			return err
+		}
+
+		if *specFlag {
+			spec, err := h.Spec(e)
+			if err != nil {
+				return err
+			}
+			fmt.Print(spec)
+			return nil
+		}
+		return execTemplate(tmpl, e)
+	}
+
+	batchHistoryCommands = append(batchHistoryCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
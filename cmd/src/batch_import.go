@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src batch import' recreates a batch change exported with 'src batch export'
+on this Sourcegraph instance.
+
+Changesets that were published or tracked on the exporting instance are
+imported as tracked changesets referencing the same changesets on the code
+host, so that no duplicates are opened. Changesets that weren't published are
+recreated from their specs. Deleted changesets are skipped.
+
+Usage:
+
+    src batch import -f FILE [command options]
+
+Examples:
+
+    $ src batch import -f hello-world.json
+
+    $ src batch import -f hello-world.json -namespace myorg -apply
+
+`
+
+	flagSet := flag.NewFlagSet("import", flag.ExitOnError)
+
+	var (
+		fileFlag      = flagSet.String("f", "", "The export file to import.")
+		namespaceFlag = flagSet.String("namespace", "", "The user or organization namespace to create the batch change in. Default is the currently authenticated user.")
+		applyFlag     = flagSet.Bool("apply", false, "Apply the batch spec instead of only creating a preview.")
+		apiFlags      = api.NewFlags(flagSet)
+	)
+	flagSet.StringVar(namespaceFlag, "n", "", "Alias for -namespace.")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+		if *fileFlag == "" {
+			return cmderrors.Usage("-f must be provided")
+		}
+
+		data, err := os.ReadFile(*fileFlag)
+		if err != nil {
+			return err
+		}
+		var export service.BatchChangeExport
+		if err := json.Unmarshal(data, &export); err != nil {
+			return errors.Wrapf(err, "parsing %s", *fileFlag)
+		}
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+
+		svc := service.New(&service.Opts{
+			Client: cfg.apiClient(apiFlags, flagSet.Output()),
+		})
+		if err := svc.DetermineFeatureFlags(ctx); err != nil {
+			return err
+		}
+
+		namespace, err := svc.ResolveNamespace(ctx, *namespaceFlag)
+		if err != nil {
+			return err
+		}
+
+		specs, err := svc.ImportChangesetSpecs(ctx, &export)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]graphql.ChangesetSpecID, len(specs))
+		for i, spec := range specs {
+			id, err := svc.CreateChangesetSpec(ctx, spec)
+			if err != nil {
+				return err
+			}
+			ids[i] = id
+		}
+		fmt.Printf("Created %d changeset specs.\n", len(ids))
+
+		id, url, err := svc.CreateBatchSpec(ctx, namespace, export.BatchSpec, ids)
+		if err != nil {
+			return err
+		}
+
+		if !*applyFlag {
+			fmt.Printf("To preview or apply the batch spec, go to:\n%s%s\n", cfg.Endpoint, url)
+			return nil
+		}
+
+		batch, err := svc.ApplyBatchChange(ctx, id)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Batch change applied:\n%s%s\n", cfg.Endpoint, batch.URL)
+		return nil
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+	"github.com/sourcegraph/src-cli/internal/siteconfig"
+)
+
+func init() {
+	usage := `
+'src batch migrate-spec' rewrites the deprecated constructs of a batch spec to
+their current equivalents, for upgrades across versions of src and Sourcegraph,
+and prints the changes as a diff:
+
+- the campaign.name and campaign.description template variables become
+  batch_change.name and batch_change.description
+- quoted "true" and "false" values of changesetTemplate.published become
+  booleans
+
+Constructs that can't be rewritten without changing what the batch spec does
+are listed as needing manual attention: published: false, transformChanges,
+and template expressions with unknown variables.
+
+The rest of the file, including comments and formatting, is kept as it is.
+
+Usage:
+
+    src batch migrate-spec -f FILE [-w]
+
+Examples:
+
+    $ src batch migrate-spec -f batch.spec.yaml
+
+    $ src batch migrate-spec -f batch.spec.yaml -w
+
+`
+
+	flagSet := flag.NewFlagSet("migrate-spec", flag.ExitOnError)
+	fileFlag := flagSet.String("f", "", "The batch spec file to read.")
+	writeFlag := flagSet.Bool("w", false, "Write the migrated batch spec back to the file given with -f.")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+		if *writeFlag && (*fileFlag == "" || *fileFlag == "-") {
+			return cmderrors.Usage("-w requires a file given with -f")
+		}
+
+		f, err := batchOpenFileFlag(fileFlag)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return errors.Wrap(err, "reading batch spec")
+		}
+
+		migration, err := service.MigrateSpec(data)
+		if err != nil {
+			return err
+		}
+
+		name := *fileFlag
+		if name == "" || name == "-" {
+			name = "batch spec"
+		}
+		if len(migration.Changes) == 0 {
+			fmt.Println("Nothing to migrate.")
+		} else {
+			fmt.Print(siteconfig.Diff(name, name+" (migrated)", string(data), string(migration.Spec)))
+			fmt.Println()
+			for _, c := range migration.Changes {
+				fmt.Printf("line %d: %s\n", c.Line, c.Message)
+			}
+		}
+
+		if len(migration.Manual) > 0 {
+			fmt.Printf("\n%d constructs need manual attention:\n", len(migration.Manual))
+			for _, c := range migration.Manual {
+				fmt.Printf("line %d: %s\n", c.Line, c.Message)
+			}
+		}
+
+		if *writeFlag && len(migration.Changes) > 0 {
+			if err := os.WriteFile(*fileFlag, migration.Spec, 0644); err != nil {
+				return errors.Wrap(err, "writing batch spec")
+			}
+			fmt.Printf("\nMigrated batch spec written to %s.\n", *fileFlag)
+		}
+		return nil
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
@@ -7,8 +7,6 @@ import (
 
 	"github.com/sourcegraph/src-cli/internal/batches/ui"
 	"github.com/sourcegraph/src-cli/internal/cmderrors"
-
-	"github.com/sourcegraph/sourcegraph/lib/output"
 )
 
 func init() {
@@ -38,15 +36,15 @@ Examples:
 			return cmderrors.Usage("additional arguments not allowed")
 		}
 
-		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		ctx, stop, cancel := contextStopOnInterrupt(context.Background(), flags.gracePeriod)
 		defer cancel()
 
 		var execUI ui.ExecUI
 		if flags.textOnly {
 			execUI = &ui.JSONLines{}
 		} else {
-			out := output.NewOutput(flagSet.Output(), output.OutputOpts{Verbose: *verbose})
-			execUI = &ui.TUI{Out: out}
+			out := newOutput(flagSet.Output(), *verbose)
+			execUI = &ui.TUI{Out: out, Plain: plainOutput()}
 		}
 
 		err := executeBatchSpec(ctx, executeBatchSpecOpts{
@@ -56,10 +54,11 @@ Examples:
 			// Do not apply the uploaded batch spec
 			applyBatchSpec: false,
 
-			ui: execUI,
+			ui:   execUI,
+			stop: stop,
 		})
 		if err != nil {
-			return cmderrors.ExitCode(1, nil)
+			return cmderrors.Reported(err)
 		}
 
 		return nil
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src batch publish' publishes the changesets of a batch change on the code
+host, or turns draft changesets into regular ones.
+
+Only changesets whose changeset template doesn't set the "published" field can
+be published this way. To open changesets as drafts first and publish them
+later, apply the batch spec without a "published" field, run this command with
+-draft, and then run it again without -draft.
+
+With -interactive, the changesets that can be published are listed, and the
+ones to publish are selected by their numbers, ranges of numbers such as 2-5,
+glob patterns matching their repositories, or "all", and confirmed before
+they're published. With -record FILE, every bulk operation that publishes
+changesets is appended to FILE as a line of JSON, so that it can be traced
+which changesets were published when.
+
+With -publish-rate N, a bulk operation publishing at most N changesets is
+started each minute. The pace is fixed: it doesn't adapt to the rate limit
+status of the code host, which Sourcegraph doesn't expose. Changesets published
+by 'src batch apply' through the "published" field aren't paced.
+
+Usage:
+
+    src batch publish -name NAME [command options]
+
+Examples:
+
+    $ src batch publish -name hello-world -draft
+
+    $ src batch publish -name hello-world
+
+    $ src batch publish -name hello-world -publish-rate 50
+
+    $ src batch publish -name hello-world -repos github.com/sourcegraph/src-cli,github.com/sourcegraph/sourcegraph
+
+    $ src batch publish -name hello-world -interactive -publish-rate 50 -record publications.jsonl
+
+`
+
+	flagSet := flag.NewFlagSet("publish", flag.ExitOnError)
+
+	var (
+		nameFlag      = flagSet.String("name", "", "The name of the batch change.")
+		namespaceFlag = flagSet.String("namespace", "", "The user or organization namespace of the batch change. Default is the currently authenticated user.")
+		draftFlag     = flagSet.Bool("draft", false, "Publish unpublished changesets as drafts.")
+		reposFlag     = flagSet.String("repos", "", "Comma-separated list of repositories to publish changesets in. Default is all repositories.")
+		rateFlag      = flagSet.Int("publish-rate", 0, "The maximum number of changesets to publish per minute, to stay within the API rate limits of the code host. Default is to publish all changesets at once.")
+		interactive   = flagSet.Bool("interactive", false, "Select the changesets to publish interactively.")
+		recordFlag    = flagSet.String("record", "", "Append the bulk operations that publish changesets to this file as JSON lines.")
+		apiFlags      = api.NewFlags(flagSet)
+	)
+	flagSet.StringVar(namespaceFlag, "n", "", "Alias for -namespace.")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+		if *nameFlag == "" {
+			return cmderrors.Usage("-name must be provided")
+		}
+
+		var repos []string
+		if *reposFlag != "" {
+			repos = strings.Split(*reposFlag, ",")
+		}
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+
+		svc := service.New(&service.Opts{
+			Client: cfg.apiClient(apiFlags, flagSet.Output()),
+		})
+
+		namespace, err := svc.ResolveNamespace(ctx, *namespaceFlag)
+		if err != nil {
+			return err
+		}
+
+		batchChange, changesets, err := svc.FetchChangesets(ctx, namespace, *nameFlag)
+		if err != nil {
+			return err
+		}
+
+		publishable := service.PublishableChangesets(changesets, *draftFlag, repos)
+		if len(publishable) == 0 {
+			fmt.Println("No changesets to publish.")
+			return nil
+		}
+
+		if *interactive {
+			publishable, err = selectChangesetsToPublish(os.Stdin, os.Stdout, publishable, *draftFlag)
+			if err != nil {
+				return err
+			}
+			if len(publishable) == 0 {
+				fmt.Println("Nothing was published.")
+				return nil
+			}
+		}
+
+		var record *json.Encoder
+		if *recordFlag != "" {
+			f, err := os.OpenFile(*recordFlag, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+			if err != nil {
+				return errors.Wrap(err, "opening record file")
+			}
+			defer f.Close()
+			record = json.NewEncoder(f)
+		}
+		repositories := make(map[string]string, len(publishable))
+		for _, cs := range publishable {
+			repositories[cs.ID] = cs.Repository
+		}
+
+		ids := make([]string, len(publishable))
+		for i, cs := range publishable {
+			ids[i] = cs.ID
+		}
+
+		suffix := ""
+		if *draftFlag {
+			suffix = " as drafts"
+		}
+		var recordErr error
+		err = svc.PublishChangesetsPaced(ctx, batchChange, ids, *draftFlag, *rateFlag, func(op service.PublishOperation, done, total int) {
+			fmt.Printf("Started publishing %d/%d changesets%s.\n", done, total, suffix)
+			if record == nil || recordErr != nil {
+				return
+			}
+			entry := publicationRecord{
+				Time:          time.Now().UTC(),
+				BatchChange:   batchChange,
+				BulkOperation: op.ID,
+				Draft:         *draftFlag,
+			}
+			for _, id := range op.Changesets {
+				entry.Changesets = append(entry.Changesets, publishedChangeset{ID: id, Repository: repositories[id]})
+			}
+			recordErr = record.Encode(entry)
+		})
+		if err != nil {
+			return err
+		}
+		return errors.Wrap(recordErr, "recording publication")
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+// publicationRecord is a line of the file given with -record.
+type publicationRecord struct {
+	Time          time.Time            `json:"time"`
+	BatchChange   string               `json:"batchChange"`
+	BulkOperation string               `json:"bulkOperation"`
+	Draft         bool                 `json:"draft"`
+	Changesets    []publishedChangeset `json:"changesets"`
+}
+
+type publishedChangeset struct {
+	ID         string `json:"id"`
+	Repository string `json:"repository"`
+}
+
+// selectChangesetsToPublish lists the changesets on out and returns the ones
+// the user selects and confirms on in. If nothing is selected or the selection
+// isn't confirmed, no changesets are returned. Invalid selections are asked
+// for again.
+func selectChangesetsToPublish(in io.Reader, out io.Writer, changesets []service.Changeset, draft bool) ([]service.Changeset, error) {
+	fmt.Fprintf(out, "Changesets that can be published:\n\n")
+	for i, cs := range changesets {
+		fmt.Fprintf(out, "%4d  %-11s  %s\n", i+1, cs.State, cs.Repository)
+	}
+	fmt.Fprintln(out)
+
+	suffix := ""
+	if draft {
+		suffix = " as drafts"
+	}
+
+	r := bufio.NewReader(in)
+	for {
+		fmt.Fprint(out, "Select changesets by number, range (2-5), repository pattern or all; leave empty to cancel: ")
+		selection, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if selection == "" {
+			return nil, nil
+		}
+
+		selected, err := service.SelectChangesets(changesets, selection)
+		if err != nil {
+			fmt.Fprintf(out, "%s\n", err)
+			continue
+		}
+
+		fmt.Fprintf(out, "Publish %d changesets%s? [y/N] ", len(selected), suffix)
+		answer, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		switch strings.ToLower(answer) {
+		case "y", "yes":
+			return selected, nil
+		}
+		return nil, nil
+	}
+}
+
+// readLine reads a line from r with surrounding whitespace removed. At the end
+// of the input, the empty string is returned.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 
 	"github.com/cockroachdb/errors"
-	"github.com/sourcegraph/sourcegraph/lib/output"
 
 	"github.com/sourcegraph/src-cli/internal/api"
 	"github.com/sourcegraph/src-cli/internal/batches/graphql"
@@ -32,9 +31,11 @@ Examples:
 	flagSet := flag.NewFlagSet("repositories", flag.ExitOnError)
 
 	var (
-		fileFlag = flagSet.String("f", "", "The batch spec file to read.")
-		apiFlags = api.NewFlags(flagSet)
+		fileFlag   = flagSet.String("f", "", "The batch spec file to read.")
+		apiFlags   = api.NewFlags(flagSet)
+		paramsFlag stringSliceFlag
 	)
+	addBatchParamFlag(flagSet, &paramsFlag)
 
 	handler := func(args []string) error {
 		if err := flagSet.Parse(args); err != nil {
@@ -50,10 +51,10 @@ Examples:
 			return err
 		}
 
-		out := output.NewOutput(flagSet.Output(), output.OutputOpts{Verbose: *verbose})
-		spec, _, err := parseBatchSpec(fileFlag, svc)
+		out := newOutput(flagSet.Output(), *verbose)
+		spec, _, err := parseBatchSpec(fileFlag, paramsFlag, svc)
 		if err != nil {
-			ui := &ui.TUI{Out: out}
+			ui := &ui.TUI{Out: out, Plain: plainOutput()}
 			ui.ParsingBatchSpecFailure(err)
 			return err
 		}
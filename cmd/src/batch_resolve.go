@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src batch resolve' prints the given batch spec with the fragments it includes
+merged into it and its library steps replaced by regular steps, as it's sent to
+Sourcegraph.
+
+Usage:
+
+    src batch resolve -f FILE
+
+Examples:
+
+    $ src batch resolve -f batch.spec.yaml
+
+`
+
+	flagSet := flag.NewFlagSet("resolve", flag.ExitOnError)
+	fileFlag := flagSet.String("f", "", "The batch spec file to read.")
+	var paramsFlag stringSliceFlag
+	addBatchParamFlag(flagSet, &paramsFlag)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+
+		f, err := batchOpenFileFlag(fileFlag)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return errors.Wrap(err, "reading batch spec")
+		}
+
+		data, err = resolveBatchSpec(data, *fileFlag, paramsFlag)
+		if err != nil {
+			return err
+		}
+
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src batch revert' creates a new batch change that reverts the merged
+changesets of an existing batch change.
+
+For every merged changeset, a changeset is created that undoes its diff on the
+default branch of the repository. The new batch change is named after the
+original one, with a "-revert" suffix, and can be previewed before it is
+applied.
+
+Usage:
+
+    src batch revert -name NAME [command options]
+
+Examples:
+
+    $ src batch revert -name hello-world
+
+    $ src batch revert -name hello-world -namespace myorg -branch undo-hello-world
+
+`
+
+	flagSet := flag.NewFlagSet("revert", flag.ExitOnError)
+
+	var (
+		nameFlag      = flagSet.String("name", "", "The name of the batch change to revert.")
+		namespaceFlag = flagSet.String("namespace", "", "The user or organization namespace of the batch change. Default is the currently authenticated user.")
+		branchFlag    = flagSet.String("branch", "", `The branch to create in each repository. Default is the name of the batch change with a "revert-" prefix.`)
+		applyFlag     = flagSet.Bool("apply", false, "Apply the batch spec instead of only creating a preview.")
+		apiFlags      = api.NewFlags(flagSet)
+	)
+	flagSet.StringVar(namespaceFlag, "n", "", "Alias for -namespace.")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+		if *nameFlag == "" {
+			return cmderrors.Usage("-name must be provided")
+		}
+
+		branch := *branchFlag
+		if branch == "" {
+			branch = "revert-" + *nameFlag
+		}
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+
+		svc := service.New(&service.Opts{
+			Client: cfg.apiClient(apiFlags, flagSet.Output()),
+		})
+		if err := svc.DetermineFeatureFlags(ctx); err != nil {
+			return err
+		}
+
+		namespace, err := svc.ResolveNamespace(ctx, *namespaceFlag)
+		if err != nil {
+			return err
+		}
+
+		changesets, err := svc.FetchMergedChangesets(ctx, namespace, *nameFlag)
+		if err != nil {
+			return err
+		}
+		if len(changesets) == 0 {
+			return errors.Newf("batch change %q has no merged changesets to revert", *nameFlag)
+		}
+
+		specs, err := svc.BuildRevertChangesetSpecs(changesets, service.RevertOpts{
+			BatchChange: *nameFlag,
+			Branch:      branch,
+		})
+		if err != nil {
+			return err
+		}
+
+		ids := make([]graphql.ChangesetSpecID, len(specs))
+		for i, spec := range specs {
+			id, err := svc.CreateChangesetSpec(ctx, spec)
+			if err != nil {
+				return err
+			}
+			ids[i] = id
+		}
+		fmt.Printf("Created %d changeset specs.\n", len(ids))
+
+		id, url, err := svc.CreateBatchSpec(ctx, namespace, revertBatchSpec(*nameFlag), ids)
+		if err != nil {
+			return err
+		}
+
+		if !*applyFlag {
+			fmt.Printf("To preview or apply the batch spec, go to:\n%s%s\n", cfg.Endpoint, url)
+			return nil
+		}
+
+		batch, err := svc.ApplyBatchChange(ctx, id)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Batch change applied:\n%s%s\n", cfg.Endpoint, batch.URL)
+		return nil
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+// revertBatchSpec returns the raw batch spec that is uploaded together with the
+// changeset specs reverting the batch change with the given name.
+func revertBatchSpec(name string) string {
+	return fmt.Sprintf("name: %s-revert\ndescription: Reverts the merged changesets of batch change %s.\n", name, name)
+}
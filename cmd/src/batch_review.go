@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/compare"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/ui"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src batch review' executes the steps in a batch spec like 'src batch preview',
+and then shows the diff of each changeset to be approved or skipped before the
+batch spec is uploaded. Skipped changesets aren't uploaded, so that risky
+automated changes can be curated by hand.
+
+The diffs are shown with $PAGER, or 'less -R' if it's not set. After each diff,
+answer with:
+
+    y    approve the changeset
+    n    skip the changeset
+    d    show the diff again
+    a    approve this and all remaining changesets
+    q    quit without uploading anything
+
+Once all changesets are reviewed, the batch spec is uploaded with the approved
+changesets, ready to be previewed and applied, or applied right away with
+-apply. If the batch change already has changesets in the repositories of
+skipped changesets, applying the batch spec closes them.
+
+The results of earlier executions are cached, so a batch spec can be executed
+with 'src batch preview' or 'src batch exec' first and reviewed later without
+executing the steps again.
+
+Usage:
+
+    src batch review -f FILE [command options]
+
+Examples:
+
+    $ src batch review -f batch.spec.yaml
+
+    $ PAGER=delta src batch review -f batch.spec.yaml -apply
+
+`
+
+	flagSet := flag.NewFlagSet("review", flag.ExitOnError)
+	flags := newBatchExecuteFlags(flagSet, false, batchDefaultCacheDir(), batchDefaultTempDirPrefix())
+	applyFlag := flagSet.Bool("apply", false, "Apply the batch spec with the approved changesets instead of only uploading it.")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+		if flags.textOnly {
+			return cmderrors.Usage("-text-only can't be used with 'src batch review'")
+		}
+		if flags.file == "" || flags.file == "-" {
+			return cmderrors.Usage("the batch spec must be read from a file given with -f, as the review is read from stdin")
+		}
+		if !isatty.IsTerminal(os.Stdin.Fd()) || !isatty.IsTerminal(os.Stdout.Fd()) {
+			return cmderrors.Usage("'src batch review' must be run in a terminal")
+		}
+
+		ctx, stop, cancel := contextStopOnInterrupt(context.Background(), flags.gracePeriod)
+		defer cancel()
+
+		out := newOutput(flagSet.Output(), *verbose)
+		review := &batchReview{
+			in:  bufio.NewReader(os.Stdin),
+			out: os.Stdout,
+			page: func(diff string) error {
+				pager, pagerSet := os.LookupEnv("PAGER")
+				pagerCmd, err := pagerCommand(pager, pagerSet)
+				if err != nil {
+					return err
+				}
+				if pagerCmd == nil {
+					_, err := io.WriteString(os.Stdout, diff)
+					return err
+				}
+				pagerCmd.Env = envSetDefault(os.Environ(), "LESS", "FRX")
+				pagerCmd.Stdin = strings.NewReader(diff)
+				pagerCmd.Stdout = os.Stdout
+				pagerCmd.Stderr = os.Stderr
+				return pagerCmd.Run()
+			},
+		}
+
+		err := executeBatchSpec(ctx, executeBatchSpecOpts{
+			flags:  flags,
+			client: cfg.apiClient(flags.api, flagSet.Output()),
+
+			applyBatchSpec: *applyFlag,
+
+			ui:     &ui.TUI{Out: out, Plain: plainOutput()},
+			stop:   stop,
+			review: review.review,
+		})
+		if err != nil {
+			return cmderrors.Reported(err)
+		}
+
+		return nil
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+// batchReview is the interactive review of 'src batch review', which shows
+// the diff of each changeset spec and asks whether to approve or skip it.
+type batchReview struct {
+	in  *bufio.Reader
+	out io.Writer
+	// page shows a diff to the user.
+	page func(diff string) error
+}
+
+// review asks for each of the given changeset specs whether it should be
+// uploaded, in the order of their repositories, and returns the approved ones.
+// Specs of imported changesets have no diff and are approved without asking.
+// ok is false if the user quits the review, or doesn't confirm the approved
+// specs.
+func (r *batchReview) review(specs []*batcheslib.ChangesetSpec, repos []*graphql.Repository) (approved []*batcheslib.ChangesetSpec, ok bool, err error) {
+	names := make(map[string]string, len(repos))
+	for _, repo := range repos {
+		names[repo.ID] = repo.Name
+	}
+	name := func(spec *batcheslib.ChangesetSpec) string {
+		if n := names[spec.BaseRepository]; n != "" {
+			return n
+		}
+		return spec.BaseRepository
+	}
+
+	var pending []*batcheslib.ChangesetSpec
+	for _, spec := range specs {
+		if spec.ExternalID != "" {
+			approved = append(approved, spec)
+		} else {
+			pending = append(pending, spec)
+		}
+	}
+	sort.SliceStable(pending, func(i, j int) bool { return name(pending[i]) < name(pending[j]) })
+
+	skipped := 0
+	approveAll := false
+	for i, spec := range pending {
+		if approveAll {
+			approved = append(approved, spec)
+			continue
+		}
+
+		diff := specDiff(spec)
+		stat, err := compare.DiffStat(diff)
+		if err != nil {
+			return nil, false, err
+		}
+		fmt.Fprintf(r.out, "\n[%d/%d] %s %s: %s (%s)\n", i+1, len(pending), name(spec), strings.TrimPrefix(spec.HeadRef, "refs/heads/"), spec.Title, formatDiffStat(stat))
+		if err := r.page(colorizeDiff(diff)); err != nil {
+			return nil, false, err
+		}
+
+	ask:
+		for {
+			answer, err := r.prompt("Approve this changeset? [y]es, [n]o, [d]iff again, approve [a]ll remaining, [q]uit: ")
+			if err != nil {
+				return nil, false, err
+			}
+			switch answer {
+			case "y", "yes":
+				approved = append(approved, spec)
+				break ask
+			case "n", "no":
+				skipped++
+				break ask
+			case "d", "diff":
+				if err := r.page(colorizeDiff(diff)); err != nil {
+					return nil, false, err
+				}
+			case "a", "all":
+				approved = append(approved, spec)
+				approveAll = true
+				break ask
+			case "q", "quit":
+				fmt.Fprintln(r.out, "Review stopped, nothing was uploaded.")
+				return nil, false, nil
+			}
+		}
+	}
+
+	fmt.Fprintf(r.out, "\nApproved %d changesets, skipped %d.\n", len(approved), skipped)
+	if skipped == 0 {
+		return approved, true, nil
+	}
+	answer, err := r.prompt(fmt.Sprintf("Upload the batch spec with the %d approved changesets? [y/N] ", len(approved)))
+	if err != nil {
+		return nil, false, err
+	}
+	switch answer {
+	case "y", "yes":
+		return approved, true, nil
+	}
+	fmt.Fprintln(r.out, "Nothing was uploaded.")
+	return nil, false, nil
+}
+
+// prompt asks the question and returns the answer in lower case. At the end of
+// the input, "q" is returned, so that the review stops.
+func (r *batchReview) prompt(question string) (string, error) {
+	fmt.Fprint(r.out, question)
+	line, err := r.in.ReadString('\n')
+	if err == io.EOF && line == "" {
+		fmt.Fprintln(r.out)
+		return "q", nil
+	}
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.ToLower(strings.TrimSpace(line)), nil
+}
+
+// specDiff returns the diff of all commits of the changeset spec.
+func specDiff(spec *batcheslib.ChangesetSpec) string {
+	var diff strings.Builder
+	for _, commit := range spec.Commits {
+		diff.WriteString(commit.Diff)
+	}
+	return diff.String()
+}
+
+// colorizeDiff colors the added and deleted lines and the hunk headers of the
+// diff, unless colors are disabled.
+func colorizeDiff(diff string) string {
+	if colorDisabled {
+		return diff
+	}
+
+	lines := strings.SplitAfter(diff, "\n")
+	for i, line := range lines {
+		var color string
+		switch {
+		case strings.HasPrefix(line, "+++ "), strings.HasPrefix(line, "--- "):
+			continue
+		case strings.HasPrefix(line, "+"):
+			color = ansiColors["batch-review-added"]
+		case strings.HasPrefix(line, "-"):
+			color = ansiColors["batch-review-deleted"]
+		case strings.HasPrefix(line, "@@"):
+			color = ansiColors["batch-review-hunk"]
+		default:
+			continue
+		}
+		content := strings.TrimSuffix(line, "\n")
+		lines[i] = color + content + ansiColors["nc"] + line[len(content):]
+	}
+	return strings.Join(lines, "")
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+)
+
+func TestBatchReview(t *testing.T) {
+	repos := []*graphql.Repository{
+		{ID: "repo-1", Name: "github.com/sourcegraph/b"},
+		{ID: "repo-2", Name: "github.com/sourcegraph/a"},
+		{ID: "repo-3", Name: "github.com/sourcegraph/c"},
+		{ID: "repo-4", Name: "github.com/sourcegraph/imported"},
+	}
+	spec := func(repo string) *batcheslib.ChangesetSpec {
+		return &batcheslib.ChangesetSpec{
+			BaseRepository: repo,
+			HeadRef:        "refs/heads/fix",
+			Title:          "Fix",
+			Commits: []batcheslib.GitCommitDescription{{
+				Diff: "diff --git a/x b/x\n--- a/x\n+++ b/x\n@@ -1 +1 @@\n-old " + repo + "\n+new " + repo + "\n",
+			}},
+		}
+	}
+	specs := []*batcheslib.ChangesetSpec{
+		spec("repo-1"),
+		spec("repo-2"),
+		spec("repo-3"),
+		{BaseRepository: "repo-4", ExternalID: "123"},
+	}
+
+	for name, tc := range map[string]struct {
+		input  string
+		want   []string
+		wantOK bool
+		paged  int
+	}{
+		"approve all":       {input: "y\ny\ny\n", want: []string{"repo-4", "repo-2", "repo-1", "repo-3"}, wantOK: true, paged: 3},
+		"skip and confirm":  {input: "y\nn\ny\ny\n", want: []string{"repo-4", "repo-2", "repo-3"}, wantOK: true, paged: 3},
+		"skip and cancel":   {input: "y\nn\ny\n\n", wantOK: false, paged: 3},
+		"approve remaining": {input: "n\na\ny\n", want: []string{"repo-4", "repo-1", "repo-3"}, wantOK: true, paged: 2},
+		"diff again":        {input: "d\nwhat\ny\ny\ny\n", want: []string{"repo-4", "repo-2", "repo-1", "repo-3"}, wantOK: true, paged: 4},
+		"quit":              {input: "y\nq\n", wantOK: false, paged: 2},
+		"end of input":      {input: "y\n", wantOK: false, paged: 2},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			paged := 0
+			r := &batchReview{
+				in:  bufio.NewReader(strings.NewReader(tc.input)),
+				out: &out,
+				page: func(diff string) error {
+					paged++
+					return nil
+				},
+			}
+
+			approved, ok, err := r.review(specs, repos)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tc.wantOK {
+				t.Errorf("wrong ok %v, output:\n%s", ok, out.String())
+			}
+			var have []string
+			for _, spec := range approved {
+				have = append(have, spec.BaseRepository)
+			}
+			if diff := cmp.Diff(tc.want, have); diff != "" {
+				t.Errorf("wrong approved specs (-want +have):\n%s", diff)
+			}
+			if paged != tc.paged {
+				t.Errorf("diffs shown %d times, want %d", paged, tc.paged)
+			}
+		})
+	}
+}
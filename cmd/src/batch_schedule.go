@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+	"github.com/sourcegraph/sourcegraph/lib/output"
+
+	"github.com/sourcegraph/src-cli/internal/batches/schedule"
+	"github.com/sourcegraph/src-cli/internal/batches/ui"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src batch schedule' keeps running and applies a batch spec on a recurring
+schedule, for teams that don't have a CI system to run 'src batch apply'
+periodically.
+
+On every run, the batch spec is executed as with 'src batch apply'. The batch
+change is only applied if the resulting changesets differ from those of the
+previous successful run.
+
+The schedule is a cron expression with five fields: minute, hour, day of month,
+month, and day of week. The times are in the local time zone.
+
+With -status-addr, the state of the schedule is served as JSON over HTTP. With
+-metrics-addr, Prometheus metrics, such as the number of executed tasks and
+the cache hit rate, are served on /metrics.
+
+Usage:
+
+    src batch schedule -f FILE -cron EXPR [command options]
+
+Examples:
+
+  Apply the batch spec every Monday at 3am:
+
+    $ src batch schedule -f batch.spec.yaml -cron "0 3 * * 1"
+
+  Apply the batch spec every hour, serving the status on port 8080:
+
+    $ src batch schedule -f batch.spec.yaml -cron "0 * * * *" -status-addr :8080
+
+`
+
+	flagSet := flag.NewFlagSet("schedule", flag.ExitOnError)
+	flags := newBatchExecuteFlags(flagSet, false, batchDefaultCacheDir(), batchDefaultTempDirPrefix())
+
+	var (
+		cronFlag        = flagSet.String("cron", "", "The cron expression that determines when the batch spec is applied.")
+		statusAddrFlag  = flagSet.String("status-addr", "", "If set, the address to serve the status of the schedule on, such as :8080.")
+		metricsAddrFlag = addMetricsAddrFlag(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+		if *cronFlag == "" {
+			return cmderrors.Usage("-cron is required")
+		}
+		if flags.file == "" || flags.file == "-" {
+			return cmderrors.Usage("-f must be a file, since the batch spec is read on every run")
+		}
+
+		cron, err := schedule.ParseCron(*cronFlag)
+		if err != nil {
+			return cmderrors.Usage(err.Error())
+		}
+
+		ctx, stop, cancel := contextStopOnInterrupt(context.Background(), flags.gracePeriod)
+		defer cancel()
+
+		// The schedule ends on the first interrupt, while a run that's in
+		// progress gets the grace period to finish its steps.
+		scheduleCtx, cancelSchedule := context.WithCancel(ctx)
+		defer cancelSchedule()
+		go func() {
+			select {
+			case <-stop:
+				cancelSchedule()
+			case <-scheduleCtx.Done():
+			}
+		}()
+
+		out := newOutput(flagSet.Output(), *verbose)
+		client := cfg.apiClient(flags.api, flagSet.Output())
+
+		var lastDigest string
+		runner := schedule.NewRunner(cron, func(context.Context) (bool, error) {
+			var execUI ui.ExecUI
+			if flags.textOnly {
+				execUI = &ui.JSONLines{}
+			} else {
+				execUI = &ui.TUI{Out: out, Plain: plainOutput()}
+			}
+
+			var digest string
+			err := executeBatchSpec(ctx, executeBatchSpecOpts{
+				flags:  flags,
+				client: client,
+
+				applyBatchSpec: true,
+
+				ui:   execUI,
+				stop: stop,
+
+				skipUnchanged: func(d string) bool {
+					digest = d
+					return d == lastDigest
+				},
+			})
+			if err != nil {
+				return false, err
+			}
+			if digest == lastDigest {
+				out.Write("Changesets are unchanged since the previous run, not applying the batch spec.")
+				return false, nil
+			}
+			lastDigest = digest
+			return true, nil
+		})
+
+		stopMetrics, err := serveMetrics(*metricsAddrFlag)
+		if err != nil {
+			return err
+		}
+		defer stopMetrics()
+
+		if *statusAddrFlag != "" {
+			srv := &http.Server{Addr: *statusAddrFlag, Handler: runner}
+			go func() {
+				if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					out.WriteLine(output.Linef(output.EmojiWarning, output.StyleWarning, "Status endpoint failed: %s", err))
+				}
+			}()
+			defer srv.Close()
+		}
+
+		out.Writef("Applying %s on schedule %q. Next run at %s.", flags.file, cron, cron.Next(time.Now()).Format("2006-01-02 15:04"))
+		runner.Start(scheduleCtx)
+		return nil
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+// changesetSpecsDigest returns a digest of the given changeset specs that
+// doesn't depend on their order.
+func changesetSpecsDigest(specs []*batcheslib.ChangesetSpec) (string, error) {
+	encoded := make([][]byte, len(specs))
+	for i, spec := range specs {
+		data, err := json.Marshal(spec)
+		if err != nil {
+			return "", errors.Wrap(err, "encoding changeset spec")
+		}
+		encoded[i] = data
+	}
+	sort.Slice(encoded, func(i, j int) bool {
+		return bytes.Compare(encoded[i], encoded[j]) < 0
+	})
+
+	h := sha256.New()
+	for _, data := range encoded {
+		h.Write(data)
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
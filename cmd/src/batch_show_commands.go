@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches"
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+// showBatchStepCommands writes the `docker run` invocations of the steps in
+// the workspaces of the given repository to w as a shell script, without
+// executing them, so that the steps can be reproduced outside of src.
+func showBatchStepCommands(ctx context.Context, w io.Writer, svc *service.Service, spec *batcheslib.BatchSpec, sandbox executor.SandboxProfile, repoName string) error {
+	repos, err := svc.ResolveRepositories(ctx, spec)
+	if err != nil {
+		_, unsupported := err.(batches.UnsupportedRepoSet)
+		_, ignored := err.(batches.IgnoredRepoSet)
+		if !unsupported && !ignored {
+			return errors.Wrap(err, "resolving repositories")
+		}
+	}
+
+	var matching []*graphql.Repository
+	for _, repo := range repos {
+		if repo.Name == repoName {
+			matching = append(matching, repo)
+		}
+	}
+	if len(matching) == 0 {
+		return cmderrors.WithKind(errors.Newf("the batch spec doesn't apply to repository %s", repoName), cmderrors.KindValidation)
+	}
+
+	workspaces, err := svc.DetermineWorkspaces(ctx, matching, spec)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "#!/bin/sh")
+	fmt.Fprintln(w, "# Templates are rendered without the outputs and results of previous steps.")
+	fmt.Fprintln(w, "# src runs the scripts with /bin/bash instead of /bin/sh if the image has it.")
+	fmt.Fprintln(w, "set -e")
+
+	for _, task := range svc.BuildTasks(ctx, spec, workspaces) {
+		commands, err := executor.StepCommands(task, sandbox)
+		if err != nil {
+			return errors.Wrapf(err, "%s", task.Repository.Name)
+		}
+
+		where := task.Repository.Name + " at " + task.Repository.Rev()
+		if task.Path != "" {
+			where += ", workspace " + task.Path
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "# The steps in %s.\n", where)
+		fmt.Fprintf(w, ": \"${WORKSPACE:?must be set to a checkout of %s at %s}\"\n", task.Repository.Name, task.Repository.Rev())
+
+		for _, c := range commands {
+			fmt.Fprintln(w)
+			if c.Skipped {
+				fmt.Fprintf(w, "# Step %d is skipped: its if: condition is false.\n", c.Step)
+				continue
+			}
+
+			fmt.Fprintf(w, "# Step %d: %s\n", c.Step, c.Container)
+			for _, name := range c.FileSecrets {
+				fmt.Fprintf(w, ": \"${%s:?must be set to the secret in the fromFile: dotenv file of step %d}\"\n", name, c.Step)
+			}
+			writeHeredoc(w, c.ScriptFile, c.Script)
+			for _, f := range c.Files {
+				writeHeredoc(w, f.Name, f.Content)
+			}
+
+			quoted := make([]string, 0, len(c.Args)+1)
+			quoted = append(quoted, "docker")
+			for _, arg := range c.Args {
+				quoted = append(quoted, shellQuoteStepArg(arg))
+			}
+			fmt.Fprintln(w, strings.Join(quoted, " "))
+		}
+	}
+	return nil
+}
+
+// writeHeredoc writes a shell command that writes content to the file name.
+func writeHeredoc(w io.Writer, name, content string) {
+	delimiter := "SRC_EOF"
+	for strings.Contains(content, delimiter) {
+		delimiter += "_"
+	}
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	fmt.Fprintf(w, "cat > %s <<'%s'\n%s%s\n", shellQuote(name), delimiter, content, delimiter)
+}
+
+// stepCommandPlaceholders matches the placeholders in the arguments of
+// executor.StepCommand, which are left to the shell to expand.
+var stepCommandPlaceholders = regexp.MustCompile(regexp.QuoteMeta(executor.StepCommandWorkspace) + "|" + regexp.QuoteMeta(executor.StepCommandDir))
+
+// shellQuoteStepArg quotes arg for the shell, except for the placeholders in
+// it.
+func shellQuoteStepArg(arg string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range stepCommandPlaceholders.FindAllStringIndex(arg, -1) {
+		if loc[0] > last {
+			b.WriteString(shellQuote(arg[last:loc[0]]))
+		}
+		b.WriteString(`"` + arg[loc[0]:loc[1]] + `"`)
+		last = loc[1]
+	}
+	if last < len(arg) || last == 0 {
+		b.WriteString(shellQuote(arg[last:]))
+	}
+	return b.String()
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes s for the shell, unless it doesn't need to be quoted.
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
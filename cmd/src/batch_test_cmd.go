@@ -0,0 +1,422 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/hashicorp/go-multierror"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+	"github.com/sourcegraph/sourcegraph/lib/output"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/repozip"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/batches/spectest"
+	"github.com/sourcegraph/src-cli/internal/batches/ui"
+	"github.com/sourcegraph/src-cli/internal/batches/workspace"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src batch test' executes a batch spec against fixture repositories and
+compares the diffs and the outputs of the steps to golden files, so that batch
+specs can be tested in CI the way unit tests are.
+
+The fixtures are defined in a test file. A fixture is either a local directory,
+which doesn't need to be a git repository, or a repository on Sourcegraph at an
+optional revision:
+
+    spec: hello-world.batch.yaml                  # relative to the test file
+    fixtures:
+      - dir: testdata/go-module                   # a local directory
+        repository: github.com/example/go-module  # optional, the name it's executed as
+        path: cmd/app                             # optional, the workspace
+        diff: testdata/go-module.diff
+        outputs: testdata/go-module.json          # optional
+      - repository: github.com/sourcegraph/src-cli@3.31.0
+        diff: testdata/src-cli.diff
+
+Every run executes the steps anew. The batch spec is executed against local
+directories only, unless a fixture names a repository on Sourcegraph.
+
+Run with -update to write the golden files of the fixtures from their results,
+and review the changes before committing them.
+
+Usage:
+
+    src batch test [-f FILE] [command options]
+
+Examples:
+
+    $ src batch test -f hello-world.test.yaml
+
+    $ src batch test -f hello-world.test.yaml -run 'go-.*' -update
+
+`
+
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+
+	var (
+		fileFlag        = flagSet.String("f", "batch.test.yaml", "The test file to read.")
+		updateFlag      = flagSet.Bool("update", false, "Write the diffs and outputs of the fixtures to their golden files instead of comparing them.")
+		runFlag         = flagSet.String("run", "", "Only test the fixtures whose name matches this regular expression.")
+		parallelismFlag = flagSet.Int("j", runtime.GOMAXPROCS(0), "The maximum number of parallel jobs. Default is GOMAXPROCS.")
+		timeoutFlag     = flagSet.Duration("timeout", 60*time.Minute, "The maximum duration a single batch spec step can take.")
+		keepLogsFlag    = flagSet.Bool("keep-logs", false, "Retain logs after executing steps.")
+		workspaceFlag   = flagSet.String("workspace", "auto", `Workspace mode to use ("auto", "bind", "volume", or "remote")`)
+		sandboxFlag     = flagSet.String("sandbox", string(executor.SandboxDefault), `Sandbox profile of the step containers ("strict", "default", or "off").`)
+		gracePeriodFlag = flagSet.Duration("grace-period", batchDefaultGracePeriod, "How long the running steps may take to finish after an interrupt before they're stopped.")
+		tempDirFlag     = flagSet.String("tmp", batchDefaultTempDirPrefix(), "Directory for storing temporary data, such as log files and the results of the fixtures. Default is /tmp. Can also be set with environment variable SRC_BATCH_TMP_DIR.")
+		params          stringSliceFlag
+		apiFlags        = api.NewFlags(flagSet)
+	)
+	addBatchParamFlag(flagSet, &params)
+	flagSet.BoolVar(verbose, "v", false, "print verbose output")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+
+		run, err := regexp.Compile(*runFlag)
+		if err != nil {
+			return cmderrors.Usagef("invalid -run: %s", err)
+		}
+		sandbox, err := executor.ParseSandboxProfile(*sandboxFlag)
+		if err != nil {
+			return cmderrors.Usage(err.Error())
+		}
+
+		tf, err := spectest.Parse(*fileFlag)
+		if err != nil {
+			return cmderrors.WithKind(err, cmderrors.KindValidation)
+		}
+		var fixtures []spectest.Fixture
+		for _, f := range tf.Fixtures {
+			if run.MatchString(f.Name) {
+				fixtures = append(fixtures, f)
+			}
+		}
+		if len(fixtures) == 0 {
+			return cmderrors.Usagef("no fixtures in %s match -run %q", *fileFlag, *runFlag)
+		}
+
+		ctx, stop, cancel := contextStopOnInterrupt(context.Background(), *gracePeriodFlag)
+		defer cancel()
+
+		out := newOutput(flagSet.Output(), *verbose)
+		results, err := runBatchSpecTests(ctx, batchTestOpts{
+			spec:        tf.Spec,
+			params:      params,
+			fixtures:    fixtures,
+			client:      cfg.apiClient(apiFlags, flagSet.Output()),
+			ui:          &ui.TUI{Out: out, Plain: plainOutput()},
+			parallelism: *parallelismFlag,
+			timeout:     *timeoutFlag,
+			keepLogs:    *keepLogsFlag,
+			workspace:   *workspaceFlag,
+			sandbox:     sandbox,
+			tempDir:     *tempDirFlag,
+			stop:        stop,
+		})
+		if err != nil {
+			return cmderrors.Reported(err)
+		}
+
+		return reportBatchSpecTests(out, results, *updateFlag)
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+type batchTestOpts struct {
+	spec     string
+	params   []string
+	fixtures []spectest.Fixture
+	client   api.Client
+	ui       ui.ExecUI
+
+	parallelism int
+	timeout     time.Duration
+	keepLogs    bool
+	workspace   string
+	sandbox     executor.SandboxProfile
+	tempDir     string
+	stop        <-chan struct{}
+}
+
+// batchTestResult is the result of executing the batch spec in a fixture.
+type batchTestResult struct {
+	fixture spectest.Fixture
+	result  executor.TaskResult
+	// failed is true if the steps failed in the fixture.
+	failed bool
+}
+
+// runBatchSpecTests executes the batch spec in the fixtures. The results aren't
+// cached, so that the steps are always executed anew.
+func runBatchSpecTests(ctx context.Context, opts batchTestOpts) (results []batchTestResult, err error) {
+	defer func() {
+		if err != nil {
+			opts.ui.ExecutionError(err)
+		}
+	}()
+
+	if err := os.MkdirAll(opts.tempDir, 0755); err != nil {
+		return nil, err
+	}
+	cacheDir, err := os.MkdirTemp(opts.tempDir, "src-batch-test-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(cacheDir)
+
+	tracker, cleanup, err := batchRunTracker(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := cleanup(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	svc := service.New(&service.Opts{Client: opts.client})
+
+	// Sourcegraph is only needed to resolve and fetch the repositories of
+	// fixtures that aren't local directories.
+	remote := false
+	for _, f := range opts.fixtures {
+		remote = remote || !f.IsLocal()
+	}
+	if remote {
+		err = svc.DetermineFeatureFlags(ctx)
+	} else {
+		err = svc.EnableAllFeatures()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkExecutable("git", "version"); err != nil {
+		return nil, err
+	}
+	if err := checkExecutable("docker", "version"); err != nil {
+		return nil, err
+	}
+
+	opts.ui.ParsingBatchSpec()
+	batchSpec, _, err := parseBatchSpec(&opts.spec, opts.params, svc)
+	if err != nil {
+		var multiErr *multierror.Error
+		if errors.As(err, &multiErr) {
+			opts.ui.ParsingBatchSpecFailure(multiErr)
+			return nil, cmderrors.Reported(cmderrors.WithKind(multiErr, cmderrors.KindValidation))
+		}
+		return nil, err
+	}
+	opts.ui.ParsingBatchSpecSuccess()
+
+	var workspaceCreator workspace.Creator
+	if svc.HasDockerImages(batchSpec) {
+		opts.ui.PreparingContainerImages()
+		images, err := svc.EnsureDockerImages(ctx, batchSpec, 4, opts.ui.PreparingContainerImagesProgress)
+		if err != nil {
+			return nil, err
+		}
+		opts.ui.PreparingContainerImagesSuccess()
+
+		opts.ui.DeterminingWorkspaceCreatorType()
+		workspaceCreator = workspace.NewCreator(ctx, opts.workspace, cacheDir, opts.tempDir, images, tracker)
+		if workspaceCreator.Type() != workspace.CreatorTypeBind {
+			if _, err := svc.EnsureImage(ctx, workspace.DockerVolumeWorkspaceImage); err != nil {
+				return nil, err
+			}
+		}
+		opts.ui.DeterminingWorkspaceCreatorTypeSuccess(workspaceCreator.Type())
+		if err := checkRemoteWorkspace(workspaceCreator, opts.sandbox, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	opts.ui.ResolvingRepositories()
+	dirs := map[repozip.RepoRevision]string{}
+	repos := make([]*graphql.Repository, len(opts.fixtures))
+	for i, f := range opts.fixtures {
+		if repos[i], err = resolveBatchTestFixture(ctx, svc, f); err != nil {
+			return nil, errors.Wrapf(err, "fixture %s", f.Name)
+		}
+		if f.IsLocal() {
+			dirs[repozip.RepoRevision{RepoName: repos[i].Name, Commit: repos[i].Rev()}] = f.Dir
+		}
+	}
+	opts.ui.ResolvingRepositoriesDone(repos, nil, nil)
+
+	opts.ui.DeterminingWorkspaces()
+	var (
+		workspaces []service.RepoWorkspace
+		// taskFixtures are the indexes of the fixtures of the workspaces.
+		taskFixtures []int
+	)
+	results = make([]batchTestResult, len(opts.fixtures))
+	for i, f := range opts.fixtures {
+		results[i].fixture = f
+
+		ws, ok, err := svc.WorkspaceAt(batchSpec, repos[i], f.Path)
+		if err != nil {
+			return nil, err
+		}
+		// A fixture no steps are run in has an empty result.
+		if ok {
+			workspaces = append(workspaces, ws)
+			taskFixtures = append(taskFixtures, i)
+		}
+	}
+	opts.ui.DeterminingWorkspacesSuccess(len(workspaces))
+
+	coord := svc.NewCoordinator(executor.NewCoordinatorOpts{
+		Creator:             workspaceCreator,
+		RepoArchiveRegistry: repozip.NewLocalArchiveRegistry(dirs, cacheDir, repozip.NewArchiveRegistry(opts.client, cacheDir, true)),
+		CacheDir:            cacheDir,
+		SkipErrors:          true,
+		Parallelism:         opts.parallelism,
+		Timeout:             opts.timeout,
+		KeepLogs:            opts.keepLogs,
+		TempDir:             opts.tempDir,
+		Sandbox:             opts.sandbox,
+		Tracker:             tracker,
+		Stop:                opts.stop,
+	})
+
+	// Changesets to import aren't part of the results of the fixtures.
+	testSpec := *batchSpec
+	testSpec.ImportChangesets = nil
+
+	tasks := svc.BuildTasks(ctx, &testSpec, workspaces)
+	taskExecUI := opts.ui.ExecutingTasks(*verbose, opts.parallelism)
+	_, logFiles, err := coord.Execute(ctx, tasks, &testSpec, taskExecUI)
+	if errors.Is(err, context.Canceled) {
+		// The results of an interrupted run are incomplete.
+		taskExecUI.Failed(err)
+		return nil, err
+	}
+	if err == nil {
+		taskExecUI.Success()
+	} else {
+		// The fixtures whose steps failed are reported with the others.
+		opts.ui.ExecutingTasksSkippingErrors(err)
+	}
+	if len(logFiles) > 0 && opts.keepLogs {
+		opts.ui.LogFilesKept(logFiles)
+	}
+
+	for i, task := range tasks {
+		result, found, err := coord.CachedResult(ctx, task)
+		if err != nil {
+			return nil, err
+		}
+		results[taskFixtures[i]].result = result
+		results[taskFixtures[i]].failed = !found
+	}
+	return results, nil
+}
+
+// resolveBatchTestFixture returns the repository of the fixture. Local
+// directories are given a commit derived from their path, so that fixtures
+// with the same repository name but different directories get their own
+// archives.
+func resolveBatchTestFixture(ctx context.Context, svc *service.Service, f spectest.Fixture) (*graphql.Repository, error) {
+	name, rev := f.RepoAndRev()
+	if !f.IsLocal() {
+		repos, err := svc.ResolveRepositoriesOn(ctx, &batcheslib.OnQueryOrRepository{Repository: name, Branch: rev})
+		if err != nil {
+			return nil, err
+		}
+		return repos[0], nil
+	}
+
+	if fi, err := os.Stat(f.Dir); err != nil {
+		return nil, err
+	} else if !fi.IsDir() {
+		return nil, errors.Newf("%s is not a directory", f.Dir)
+	}
+
+	sum := sha1.Sum([]byte(f.Dir))
+	commit := hex.EncodeToString(sum[:])
+	return &graphql.Repository{
+		ID:            "local:" + f.Dir,
+		Name:          name,
+		DefaultBranch: &graphql.Branch{Name: "main", Target: graphql.Target{OID: commit}},
+	}, nil
+}
+
+// reportBatchSpecTests compares the results to the golden files of the
+// fixtures, or writes them if update is true, and prints whether each fixture
+// passed.
+func reportBatchSpecTests(out *output.Output, results []batchTestResult, update bool) error {
+	failed := 0
+	for _, r := range results {
+		name := r.fixture.Name
+		if r.failed {
+			out.WriteLine(output.Linef(output.EmojiFailure, output.StyleWarning, "FAIL %s: executing the steps failed", name))
+			failed++
+			continue
+		}
+
+		if update {
+			if err := r.fixture.Update(r.result.Diff, r.result.Outputs); err != nil {
+				return errors.Wrapf(err, "fixture %s", name)
+			}
+			out.WriteLine(output.Linef(output.EmojiSuccess, output.StyleSuccess, "UPDATED %s", name))
+			continue
+		}
+
+		mismatches, err := r.fixture.Check(r.result.Diff, r.result.Outputs)
+		if err != nil {
+			return errors.Wrapf(err, "fixture %s", name)
+		}
+		if len(mismatches) == 0 {
+			out.WriteLine(output.Linef(output.EmojiSuccess, output.StyleSuccess, "PASS %s", name))
+			continue
+		}
+
+		out.WriteLine(output.Linef(output.EmojiFailure, output.StyleWarning, "FAIL %s", name))
+		for _, m := range mismatches {
+			block := out.Block(output.Linef("", output.StyleBold, "%s doesn't match the result:", m.File))
+			for _, line := range strings.Split(strings.TrimSuffix(m.Diff, "\n"), "\n") {
+				block.Write(line)
+			}
+			block.Close()
+		}
+		failed++
+	}
+
+	if failed > 0 {
+		return errors.Newf("%d of %d fixtures failed", failed, len(results))
+	}
+	return nil
+}
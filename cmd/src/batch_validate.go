@@ -29,6 +29,8 @@ Examples:
 	flagSet := flag.NewFlagSet("validate", flag.ExitOnError)
 	apiFlags := api.NewFlags(flagSet)
 	fileFlag := flagSet.String("f", "", "The batch spec file to read.")
+	var paramsFlag stringSliceFlag
+	addBatchParamFlag(flagSet, &paramsFlag)
 
 	handler := func(args []string) error {
 		ctx := context.Background()
@@ -41,8 +43,8 @@ Examples:
 			return cmderrors.Usage("additional arguments not allowed")
 		}
 
-		out := output.NewOutput(flagSet.Output(), output.OutputOpts{Verbose: *verbose})
-		ui := &ui.TUI{Out: out}
+		out := newOutput(flagSet.Output(), *verbose)
+		ui := &ui.TUI{Out: out, Plain: plainOutput()}
 		svc := service.New(&service.Opts{
 			Client: cfg.apiClient(apiFlags, flagSet.Output()),
 		})
@@ -52,7 +54,7 @@ Examples:
 			return err
 		}
 
-		if _, _, err := parseBatchSpec(fileFlag, svc); err != nil {
+		if _, _, err := parseBatchSpec(fileFlag, paramsFlag, svc); err != nil {
 			ui.ParsingBatchSpecFailure(err)
 			return err
 		}
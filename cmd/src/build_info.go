@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+	"github.com/sourcegraph/src-cli/internal/version"
+)
+
+func init() {
+	usage := `
+'src build-info' prints how this src binary was built: its version, the Go
+version, the OS and architecture it was built for, the C library it depends
+on and the build settings recorded by the Go toolchain. It also prints the
+release artifact that matches this platform and where to download it from the
+configured Sourcegraph instance.
+
+Usage:
+
+    src build-info [-json]
+
+Examples:
+
+  Print the build information:
+
+    	$ src build-info
+
+  Print the build information as JSON:
+
+    	$ src build-info -json
+`
+
+	flagSet := flag.NewFlagSet("build-info", flag.ExitOnError)
+	jsonFlag := flagSet.Bool("json", false, "Print the build information as JSON.")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if flagSet.NArg() != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+
+		info := version.ReadBuildInfo()
+		downloadURL := srcDownloadURL(info.Artifact)
+
+		if *jsonFlag {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(struct {
+				version.BuildInfo
+				DownloadURL string `json:"downloadURL"`
+			}{info, downloadURL})
+		}
+
+		fmt.Printf("Version:      %s\n", info.Version)
+		fmt.Printf("Go version:   %s\n", info.GoVersion)
+		fmt.Printf("OS/Arch:      %s/%s\n", info.OS, info.Arch)
+		fmt.Printf("C library:    %s\n", info.Libc)
+		fmt.Printf("Artifact:     %s\n", info.Artifact)
+		fmt.Printf("Download URL: %s\n", downloadURL)
+		if len(info.Settings) > 0 {
+			fmt.Println("Build settings:")
+			for _, s := range info.Settings {
+				fmt.Printf("    %s=%s\n", s.Key, s.Value)
+			}
+		}
+		return nil
+	}
+
+	// Register the command.
+	commands = append(commands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+// srcDownloadURL returns the URL of the given src release artifact on the
+// configured Sourcegraph instance.
+func srcDownloadURL(artifact string) string {
+	return strings.TrimSuffix(cfg.Endpoint, "/") + "/.api/src-cli/" + artifact
+}
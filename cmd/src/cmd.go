@@ -95,18 +95,7 @@ func (c commander) run(flagSet *flag.FlagSet, cmdName, usageText string, args []
 
 		// Execute the subcommand.
 		if err := cmd.handler(flagSet.Args()[1:]); err != nil {
-			if _, ok := err.(*cmderrors.UsageError); ok {
-				log.Printf("error: %s\n\n", err)
-				cmd.flagSet.Usage()
-				os.Exit(2)
-			}
-			if e, ok := err.(*cmderrors.ExitCodeError); ok {
-				if e.HasError() {
-					log.Println(e)
-				}
-				os.Exit(e.Code())
-			}
-			log.Fatal(err)
+			exitWithError(cmd, err)
 		}
 		os.Exit(0)
 	}
@@ -114,6 +103,32 @@ func (c commander) run(flagSet *flag.FlagSet, cmdName, usageText string, args []
 	log.Fatalf("Run '%s help' for usage.", cmdName)
 }
 
+// exitWithError reports the error a command failed with and exits with the
+// exit code of its kind. With -error-json, the error is written as JSON
+// instead of text.
+func exitWithError(cmd *command, err error) {
+	if *errorJSON {
+		if jerr := cmderrors.WriteJSON(os.Stderr, err); jerr != nil {
+			log.Println(err)
+		}
+		os.Exit(cmderrors.ExitCodeOf(err))
+	}
+
+	if _, ok := err.(*cmderrors.UsageError); ok {
+		log.Printf("error: %s\n\n", err)
+		cmd.flagSet.Usage()
+		os.Exit(cmderrors.UsageExitCode)
+	}
+	if e, ok := err.(*cmderrors.ExitCodeError); ok {
+		if e.HasError() && !e.Reported() {
+			log.Println(e)
+		}
+		os.Exit(e.Code())
+	}
+	log.Println(err)
+	os.Exit(cmderrors.ExitCodeOf(err))
+}
+
 func didYouMeanOtherCommand(actual string, suggested []string) *command {
 	fullSuggestions := make([]string, len(suggested))
 	for i, s := range suggested {
@@ -126,3 +141,16 @@ func didYouMeanOtherCommand(actual string, suggested []string) *command {
 		usageFunc: func() { log.Println(msg) },
 	}
 }
+
+// stringSliceFlag is a flag that can be given multiple times, collecting all
+// its values.
+type stringSliceFlag []string
+
+func (f *stringSliceFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringSliceFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+var codyCommands commander
+
+func init() {
+	usage := `'src cody' is a tool that manages Cody on a Sourcegraph instance.
+
+Usage:
+
+	src cody command [command options]
+
+The commands are:
+
+	context    manages the repositories indexed for Cody context
+
+Use "src cody [command] -h" for more information about a command.
+`
+
+	flagSet := flag.NewFlagSet("cody", flag.ExitOnError)
+	handler := func(args []string) error {
+		codyCommands.run(flagSet, "src cody", usage, args)
+		return nil
+	}
+
+	// Register the command.
+	commands = append(commands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Println(usage)
+		},
+	})
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+)
+
+var codyContextCommands commander
+
+func init() {
+	usage := `'src cody context' is a tool that manages the indexing jobs that create the embeddings of repositories, which Cody uses as context, on a Sourcegraph instance with Cody enabled. It requires a site admin.
+
+Usage:
+
+	src cody context command [command options]
+
+The commands are:
+
+	list       lists indexing jobs
+	schedule   schedules repositories for indexing
+	cancel     cancels indexing jobs
+
+Use "src cody context [command] -h" for more information about a command.
+`
+
+	flagSet := flag.NewFlagSet("context", flag.ExitOnError)
+	handler := func(args []string) error {
+		codyContextCommands.run(flagSet, "src cody context", usage, args)
+		return nil
+	}
+
+	// Register the command.
+	codyCommands = append(codyCommands, &command{
+		flagSet: flagSet,
+		aliases: []string{"embeddings"},
+		handler: handler,
+		usageFunc: func() {
+			fmt.Println(usage)
+		},
+	})
+}
+
+const repoEmbeddingJobFragment = `
+fragment RepoEmbeddingJobFields on RepoEmbeddingJob {
+    id
+    state
+    failureMessage
+    queuedAt
+    startedAt
+    finishedAt
+    cancel
+    repo {
+        name
+    }
+    revision {
+        oid
+    }
+}
+`
+
+type RepoEmbeddingJob struct {
+	ID             string
+	State          string
+	FailureMessage *string
+	QueuedAt       time.Time
+	StartedAt      *time.Time
+	FinishedAt     *time.Time
+	Cancel         bool
+	Repo           *struct {
+		Name string
+	}
+	Revision *struct {
+		Oid string
+	}
+}
+
+// RepoName returns the name of the repository of the job, or the empty string
+// if the repository was deleted.
+func (j *RepoEmbeddingJob) RepoName() string {
+	if j.Repo == nil {
+		return ""
+	}
+	return j.Repo.Name
+}
+
+// Done returns whether the job will not change anymore.
+func (j *RepoEmbeddingJob) Done() bool {
+	switch j.State {
+	case "COMPLETED", "ERRORED", "FAILED", "CANCELED":
+		return true
+	}
+	return false
+}
+
+// listRepoEmbeddingJobs returns the indexing jobs of the repositories
+// matching query, newest first, optionally only those in the given state, or
+// all if first is -1.
+func listRepoEmbeddingJobs(ctx context.Context, client api.Client, query, state string, first int) ([]*RepoEmbeddingJob, error) {
+	gql := `query RepoEmbeddingJobs($first: Int!, $after: String, $query: String, $state: String) {
+    repoEmbeddingJobs(first: $first, after: $after, query: $query, state: $state) {
+        nodes {
+            ...RepoEmbeddingJobFields
+        }
+        pageInfo {
+            hasNextPage
+            endCursor
+        }
+    }
+}` + repoEmbeddingJobFragment
+
+	var (
+		jobs  []*RepoEmbeddingJob
+		after *string
+	)
+	for {
+		pageSize := 100
+		if first >= 0 && first-len(jobs) < pageSize {
+			pageSize = first - len(jobs)
+		}
+		if pageSize == 0 {
+			return jobs, nil
+		}
+
+		var result struct {
+			RepoEmbeddingJobs struct {
+				Nodes    []*RepoEmbeddingJob
+				PageInfo struct {
+					HasNextPage bool
+					EndCursor   *string
+				}
+			}
+		}
+		if ok, err := client.NewRequest(gql, map[string]interface{}{
+			"first": pageSize,
+			"after": after,
+			"query": api.NullString(query),
+			"state": api.NullString(state),
+		}).Do(ctx, &result); err != nil || !ok {
+			return nil, err
+		}
+
+		jobs = append(jobs, result.RepoEmbeddingJobs.Nodes...)
+		if !result.RepoEmbeddingJobs.PageInfo.HasNextPage {
+			return jobs, nil
+		}
+		after = result.RepoEmbeddingJobs.PageInfo.EndCursor
+	}
+}
+
+// latestRepoEmbeddingJob returns the newest indexing job of the repository,
+// or nil if it was never indexed.
+func latestRepoEmbeddingJob(ctx context.Context, client api.Client, repo string) (*RepoEmbeddingJob, error) {
+	jobs, err := listRepoEmbeddingJobs(ctx, client, repo, "", -1)
+	if err != nil {
+		return nil, err
+	}
+	// The query matches repositories whose names contain it.
+	for _, job := range jobs {
+		if job.RepoName() == repo {
+			return job, nil
+		}
+	}
+	return nil, nil
+}
+
+// repoEmbeddingJobPollInterval is how often waitForRepoEmbeddingJobs checks
+// whether the jobs are done.
+var repoEmbeddingJobPollInterval = 5 * time.Second
+
+// waitForRepoEmbeddingJobs waits until the newest indexing jobs of the
+// repositories are done and differ from the ones in before, calling done for
+// each of them as they finish. latest returns the newest job of a repository.
+func waitForRepoEmbeddingJobs(ctx context.Context, repos []string, before map[string]*RepoEmbeddingJob, latest func(repo string) (*RepoEmbeddingJob, error), done func(repo string, job *RepoEmbeddingJob), timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	pending := make(map[string]bool, len(repos))
+	for _, repo := range repos {
+		pending[repo] = true
+	}
+
+	ticker := time.NewTicker(repoEmbeddingJobPollInterval)
+	defer ticker.Stop()
+	for len(pending) > 0 {
+		select {
+		case <-ctx.Done():
+			return errors.Newf("%d repositories weren't indexed within %s, their jobs may still be queued", len(pending), timeout)
+		case <-ticker.C:
+		}
+
+		for _, repo := range repos {
+			if !pending[repo] {
+				continue
+			}
+			job, err := latest(repo)
+			if err != nil {
+				return err
+			}
+			if job == nil || !job.Done() {
+				continue
+			}
+			if prev := before[repo]; prev != nil && prev.ID == job.ID {
+				continue
+			}
+			delete(pending, repo)
+			done(repo, job)
+		}
+	}
+	return nil
+}
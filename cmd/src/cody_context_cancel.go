@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+Examples:
+
+  Cancel the indexing of a repository:
+
+    	$ src cody context cancel -repo=github.com/sourcegraph/sourcegraph
+
+  Cancel an indexing job by its ID:
+
+    	$ src cody context cancel -id=$(src cody context list -query=github.com/sourcegraph/sourcegraph -state=QUEUED -first=1 -f='{{.ID}}')
+
+`
+
+	flagSet := flag.NewFlagSet("cancel", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src cody context %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		idFlag   = flagSet.String("id", "", "The ID of the indexing job to cancel.")
+		repoFlag = flagSet.String("repo", "", "The name of the repository whose queued or running indexing job to cancel.")
+		apiFlags = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if (*idFlag == "") == (*repoFlag == "") {
+			return cmderrors.Usage("exactly one of -id and -repo is required")
+		}
+
+		ctx := context.Background()
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+
+		id := *idFlag
+		if *repoFlag != "" {
+			job, err := latestRepoEmbeddingJob(ctx, client, *repoFlag)
+			if err != nil {
+				return err
+			}
+			if job == nil || job.Done() {
+				return errors.Newf("repository %q has no queued or running indexing job", *repoFlag)
+			}
+			id = job.ID
+		}
+
+		query := `mutation CancelRepoEmbeddingJob($job: ID!) {
+    cancelRepoEmbeddingJob(job: $job) {
+        alwaysNil
+    }
+}`
+		var result struct{}
+		if ok, err := client.NewRequest(query, map[string]interface{}{
+			"job": id,
+		}).Do(ctx, &result); err != nil || !ok {
+			return err
+		}
+
+		fmt.Printf("Indexing job %s canceled.\n", id)
+		return nil
+	}
+
+	// Register the command.
+	codyContextCommands = append(codyContextCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
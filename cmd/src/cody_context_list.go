@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+)
+
+func init() {
+	usage := `
+Examples:
+
+  List the indexing jobs of all repositories:
+
+    	$ src cody context list
+
+  List the failed indexing jobs of repositories whose names match the query, with their errors:
+
+    	$ src cody context list -query='github.com/sourcegraph/' -state=ERRORED -f='{{.RepoName}}: {{.FailureMessage}}'
+
+  List the indexing jobs as tab-separated values, oldest first:
+
+    	$ src cody context list -sort=queued-at -tsv
+
+`
+
+	flagSet := flag.NewFlagSet("list", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src cody context %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		firstFlag  = flagSet.Int("first", 1000, "Returns the first n indexing jobs from the list, newest first. (use -1 for unlimited)")
+		queryFlag  = flagSet.String("query", "", `Returns the indexing jobs of repositories whose names match the query. (e.g. "github.com/sourcegraph/")`)
+		stateFlag  = flagSet.String("state", "", `Returns only indexing jobs in this state: QUEUED, PROCESSING, COMPLETED, ERRORED, FAILED, or CANCELED.`)
+		formatFlag = flagSet.String("f", "{{.RepoName}}: {{.State}}", `Format for the output, using the syntax of Go package text/template. (e.g. "{{.RepoName}}@{{.Revision.Oid}}: {{.State}}" or "{{.|json}}")`)
+		tableFlags = newTableFlags(flagSet, repoEmbeddingJobColumns, "repo", "state", "queued-at", "finished-at")
+		apiFlags   = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+
+		tmpl, err := parseTemplate(*formatFlag)
+		if err != nil {
+			return err
+		}
+		tbl, err := tableFlags.table(flagSet)
+		if err != nil {
+			return err
+		}
+
+		jobs, err := listRepoEmbeddingJobs(context.Background(), client, *queryFlag, *stateFlag, *firstFlag)
+		if err != nil {
+			return err
+		}
+		for _, job := range jobs {
+			if tbl != nil {
+				if err := tbl.add(job); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := execTemplate(tmpl, job); err != nil {
+				return err
+			}
+		}
+		if tbl != nil {
+			return tbl.write(os.Stdout)
+		}
+		return nil
+	}
+
+	// Register the command.
+	codyContextCommands = append(codyContextCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
+
+// repoEmbeddingJobColumns are the columns of the table output of src cody context list.
+var repoEmbeddingJobColumns = []tableColumn{
+	{name: "id", template: "{{.ID}}"},
+	{name: "repo", template: "{{.RepoName}}"},
+	{name: "revision", template: "{{with .Revision}}{{.Oid}}{{end}}"},
+	{name: "state", template: "{{.State}}"},
+	{name: "queued-at", template: `{{.QueuedAt.Format "2006-01-02T15:04:05Z07:00"}}`},
+	{name: "started-at", template: `{{with .StartedAt}}{{.Format "2006-01-02T15:04:05Z07:00"}}{{end}}`},
+	{name: "finished-at", template: `{{with .FinishedAt}}{{.Format "2006-01-02T15:04:05Z07:00"}}{{end}}`},
+	{name: "failure", template: "{{with .FailureMessage}}{{.}}{{end}}"},
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+Usage:
+
+    	src cody context schedule [command options] REPO...
+
+Examples:
+
+  Schedule repositories for indexing:
+
+    	$ src cody context schedule github.com/sourcegraph/sourcegraph github.com/sourcegraph/src-cli
+
+  Reindex a repository from scratch and wait until it's done:
+
+    	$ src cody context schedule -force -wait github.com/sourcegraph/sourcegraph
+
+  Schedule the repositories listed in a file:
+
+    	$ xargs src cody context schedule < repos.txt
+
+`
+
+	flagSet := flag.NewFlagSet("schedule", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src cody context %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		forceFlag   = flagSet.Bool("force", false, "Index the repositories from scratch instead of updating their existing embeddings.")
+		waitFlag    = flagSet.Bool("wait", false, "Wait until the repositories are indexed.")
+		timeoutFlag = flagSet.Duration("timeout", time.Hour, "How long to wait for the indexing with -wait.")
+		apiFlags    = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		repos := flagSet.Args()
+		if len(repos) == 0 {
+			return cmderrors.Usage("at least one repository is required")
+		}
+
+		ctx := context.Background()
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+
+		latest := func(repo string) (*RepoEmbeddingJob, error) {
+			return latestRepoEmbeddingJob(ctx, client, repo)
+		}
+
+		before := map[string]*RepoEmbeddingJob{}
+		if *waitFlag {
+			for _, repo := range repos {
+				job, err := latest(repo)
+				if err != nil {
+					return err
+				}
+				before[repo] = job
+			}
+		}
+
+		query := `mutation ScheduleRepositoriesForEmbedding($repoNames: [String!]!, $forceReset: Boolean) {
+    scheduleRepositoriesForEmbedding(repoNames: $repoNames, forceReset: $forceReset) {
+        alwaysNil
+    }
+}`
+		var result struct{}
+		if ok, err := client.NewRequest(query, map[string]interface{}{
+			"repoNames":  repos,
+			"forceReset": *forceFlag,
+		}).Do(ctx, &result); err != nil || !ok {
+			return err
+		}
+		fmt.Printf("Scheduled %d repositories for indexing.\n", len(repos))
+		if !*waitFlag {
+			return nil
+		}
+
+		failed := 0
+		err := waitForRepoEmbeddingJobs(ctx, repos, before, latest, func(repo string, job *RepoEmbeddingJob) {
+			if job.State != "COMPLETED" {
+				failed++
+			}
+			if job.FailureMessage != nil && *job.FailureMessage != "" {
+				fmt.Printf("%s: %s: %s\n", repo, job.State, *job.FailureMessage)
+				return
+			}
+			fmt.Printf("%s: %s\n", repo, job.State)
+		}, *timeoutFlag)
+		if err != nil {
+			return err
+		}
+		if failed > 0 {
+			return errors.Newf("%d of %d repositories weren't indexed", failed, len(repos))
+		}
+		return nil
+	}
+
+	// Register the command.
+	codyContextCommands = append(codyContextCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
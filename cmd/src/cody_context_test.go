@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWaitForRepoEmbeddingJobs(t *testing.T) {
+	defer func(interval time.Duration) { repoEmbeddingJobPollInterval = interval }(repoEmbeddingJobPollInterval)
+	repoEmbeddingJobPollInterval = time.Millisecond
+
+	// The jobs returned by the successive polls of each repository.
+	polls := map[string][]*RepoEmbeddingJob{
+		"a": {
+			{ID: "1", State: "COMPLETED"},
+			{ID: "2", State: "QUEUED"},
+			{ID: "2", State: "PROCESSING"},
+			{ID: "2", State: "COMPLETED"},
+		},
+		"b": {
+			nil,
+			{ID: "3", State: "ERRORED"},
+		},
+	}
+	before := map[string]*RepoEmbeddingJob{"a": {ID: "1", State: "COMPLETED"}}
+	latest := func(repo string) (*RepoEmbeddingJob, error) {
+		job := polls[repo][0]
+		if len(polls[repo]) > 1 {
+			polls[repo] = polls[repo][1:]
+		}
+		return job, nil
+	}
+
+	var done []string
+	err := waitForRepoEmbeddingJobs(context.Background(), []string{"a", "b"}, before, latest, func(repo string, job *RepoEmbeddingJob) {
+		done = append(done, repo+": "+job.State)
+	}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"b: ERRORED", "a: COMPLETED"}, done); diff != "" {
+		t.Errorf("wrong finished jobs (-want +have):\n%s", diff)
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		queued := func(string) (*RepoEmbeddingJob, error) { return &RepoEmbeddingJob{ID: "4", State: "QUEUED"}, nil }
+		if err := waitForRepoEmbeddingJobs(context.Background(), []string{"a"}, nil, queued, func(string, *RepoEmbeddingJob) {}, 10*time.Millisecond); err == nil {
+			t.Error("unexpected nil error")
+		}
+	})
+}
@@ -44,6 +44,11 @@ var ansiColors = map[string]string{
 	"search-alert-proposed-title":       "",
 	"search-alert-proposed-query":       fg256Color(69),
 	"search-alert-proposed-description": "",
+
+	// Batch review specific colors.
+	"batch-review-added":   fg256Color(2),
+	"batch-review-deleted": fg256Color(124),
+	"batch-review-hunk":    fg256Color(68),
 }
 
 // Borrowed from https://github.com/acarl005/stripansi/blob/master/stripansi.go
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"os"
 
 	"github.com/sourcegraph/src-cli/internal/api"
 	"golang.org/x/net/context"
@@ -20,6 +21,10 @@ Examples:
 
     	$ src config list -subject=$(src users get -f '{{.ID}}' -username=alice)
 
+  List the subjects of the settings cascade with the authors of their latest settings:
+
+    	$ src config list -columns=settings-url,author,updated-at
+
 `
 
 	flagSet := flag.NewFlagSet("list", flag.ExitOnError)
@@ -31,6 +36,7 @@ Examples:
 	var (
 		subjectFlag = flagSet.String("subject", "", "The ID of the settings subject whose settings to list. (default: authenticated user)")
 		formatFlag  = flagSet.String("f", "", `Format for the output, using the syntax of Go package text/template. (e.g. "{{.|json}}")`)
+		tableFlags  = newTableFlags(flagSet, settingsSubjectColumns, "settings-url", "updated-at", "author")
 		apiFlags    = api.NewFlags(flagSet)
 	)
 
@@ -55,6 +61,10 @@ Examples:
 		if err != nil {
 			return err
 		}
+		tbl, err := tableFlags.table(flagSet)
+		if err != nil {
+			return err
+		}
 
 		var query string
 		var queryVars map[string]interface{}
@@ -85,6 +95,16 @@ Examples:
 		} else if result.SettingsSubject != nil {
 			cascade = &result.SettingsSubject.SettingsCascade
 		}
+		if tbl != nil {
+			if cascade != nil {
+				for _, subject := range cascade.Subjects {
+					if err := tbl.add(subject); err != nil {
+						return err
+					}
+				}
+			}
+			return tbl.write(os.Stdout)
+		}
 		return execTemplate(tmpl, cascade)
 	}
 
@@ -95,3 +115,14 @@ Examples:
 		usageFunc: usageFunc,
 	})
 }
+
+// settingsSubjectColumns are the columns of the table output of src config
+// list, which has a row for each subject in the settings cascade.
+var settingsSubjectColumns = []tableColumn{
+	{name: "id", template: "{{.ID}}"},
+	{name: "settings-url", template: "{{.SettingsURL}}"},
+	{name: "can-administer", template: "{{.ViewerCanAdminister}}"},
+	{name: "settings-id", template: "{{with .LatestSettings}}{{.ID}}{{end}}"},
+	{name: "author", template: "{{with .LatestSettings}}{{with .Author}}{{.Username}}{{end}}{{end}}"},
+	{name: "updated-at", template: "{{with .LatestSettings}}{{.CreatedAt}}{{end}}"},
+}
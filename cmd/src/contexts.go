@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+)
+
+var contextsCommands commander
+
+func init() {
+	usage := `'src contexts' is a tool that manages search contexts on a Sourcegraph instance.
+
+Usage:
+
+	src contexts command [command options]
+
+The commands are:
+
+	list       lists search contexts
+	create     creates a search context
+	update     updates a search context
+	delete     deletes a search context
+	export     exports search contexts to a YAML file
+	import     creates or updates the search contexts of a YAML file
+
+Use "src contexts [command] -h" for more information about a command.
+`
+
+	flagSet := flag.NewFlagSet("contexts", flag.ExitOnError)
+	handler := func(args []string) error {
+		contextsCommands.run(flagSet, "src contexts", usage, args)
+		return nil
+	}
+
+	// Register the command.
+	commands = append(commands, &command{
+		flagSet: flagSet,
+		aliases: []string{"context"},
+		handler: handler,
+		usageFunc: func() {
+			fmt.Println(usage)
+		},
+	})
+}
+
+const searchContextFragment = `
+fragment SearchContextFields on SearchContext {
+    id
+    name
+    spec
+    namespace {
+        id
+        namespaceName
+    }
+    description
+    public
+    autoDefined
+    query
+    repositories {
+        repository {
+            name
+        }
+        revisions
+    }
+    updatedAt
+    viewerCanManage
+}
+`
+
+type SearchContext struct {
+	ID        string
+	Name      string
+	Spec      string
+	Namespace *struct {
+		ID            string
+		NamespaceName string
+	}
+	Description  string
+	Public       bool
+	AutoDefined  bool
+	Query        string
+	Repositories []struct {
+		Repository struct {
+			Name string
+		}
+		Revisions []string
+	}
+	UpdatedAt       string
+	ViewerCanManage bool
+}
+
+// SearchContextDefinition is a search context as it's written to and read
+// from YAML files.
+type SearchContextDefinition struct {
+	Name string `yaml:"name"`
+	// Namespace is the name of the user or organization that owns the search
+	// context. Search contexts without a namespace belong to the instance.
+	Namespace    string                              `yaml:"namespace,omitempty"`
+	Description  string                              `yaml:"description,omitempty"`
+	Public       bool                                `yaml:"public"`
+	Query        string                              `yaml:"query,omitempty"`
+	Repositories []SearchContextRepositoryDefinition `yaml:"repositories,omitempty"`
+}
+
+// SearchContextRepositoryDefinition is a repository of a search context, and
+// the revisions of it that are searched.
+type SearchContextRepositoryDefinition struct {
+	Repository string   `yaml:"repository"`
+	Revisions  []string `yaml:"revisions,omitempty"`
+}
+
+// Spec returns the spec of the search context, which identifies it in search
+// queries and in the API.
+func (d *SearchContextDefinition) Spec() string {
+	if d.Namespace == "" {
+		return d.Name
+	}
+	return "@" + d.Namespace + "/" + d.Name
+}
+
+// Definition returns the definition of the search context.
+func (c *SearchContext) Definition() SearchContextDefinition {
+	def := SearchContextDefinition{
+		Name:        c.Name,
+		Description: c.Description,
+		Public:      c.Public,
+		Query:       c.Query,
+	}
+	if c.Namespace != nil {
+		def.Namespace = c.Namespace.NamespaceName
+	}
+	for _, repo := range c.Repositories {
+		def.Repositories = append(def.Repositories, SearchContextRepositoryDefinition{
+			Repository: repo.Repository.Name,
+			Revisions:  repo.Revisions,
+		})
+	}
+	return def
+}
+
+// parseSearchContextRepository parses a repository given on the command line
+// as REPO or REPO@REV1:REV2, like in search queries.
+func parseSearchContextRepository(s string) SearchContextRepositoryDefinition {
+	idx := strings.Index(s, "@")
+	if idx == -1 {
+		return SearchContextRepositoryDefinition{Repository: s}
+	}
+	return SearchContextRepositoryDefinition{
+		Repository: s[:idx],
+		Revisions:  strings.Split(s[idx+1:], ":"),
+	}
+}
+
+// readSearchContextDefinitions reads the search contexts of a YAML file, which
+// holds either a list of search contexts or a single one.
+func readSearchContextDefinitions(path string) ([]SearchContextDefinition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var defs []SearchContextDefinition
+	if err := yaml.Unmarshal(data, &defs); err != nil {
+		var def SearchContextDefinition
+		if err := yaml.Unmarshal(data, &def); err != nil {
+			return nil, errors.Wrapf(err, "parsing %s", path)
+		}
+		defs = []SearchContextDefinition{def}
+	}
+	for i, def := range defs {
+		if def.Name == "" {
+			return nil, errors.Newf("%s: search context %d has no name", path, i+1)
+		}
+	}
+	return defs, nil
+}
+
+// getSearchContext returns the search context with the given spec, or nil if
+// there is none. Unlike searchContextBySpec, which fails for search contexts
+// that don't exist, it looks the search context up in the list of search
+// contexts matching its name.
+func getSearchContext(ctx context.Context, client api.Client, spec string) (*SearchContext, error) {
+	name := spec[strings.LastIndex(spec, "/")+1:]
+	contexts, err := listSearchContexts(ctx, client, name, -1)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range contexts {
+		if c.Spec == spec {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
+
+// listSearchContexts returns the search contexts matching query, or all if
+// first is -1.
+func listSearchContexts(ctx context.Context, client api.Client, query string, first int) ([]*SearchContext, error) {
+	gql := `query SearchContexts($first: Int!, $after: String, $query: String) {
+    searchContexts(first: $first, after: $after, query: $query) {
+        nodes {
+            ...SearchContextFields
+        }
+        pageInfo {
+            hasNextPage
+            endCursor
+        }
+    }
+}` + searchContextFragment
+
+	var (
+		contexts []*SearchContext
+		after    *string
+	)
+	for {
+		pageSize := 100
+		if first >= 0 && first-len(contexts) < pageSize {
+			pageSize = first - len(contexts)
+		}
+		if pageSize == 0 {
+			return contexts, nil
+		}
+
+		var result struct {
+			SearchContexts struct {
+				Nodes    []*SearchContext
+				PageInfo struct {
+					HasNextPage bool
+					EndCursor   *string
+				}
+			}
+		}
+		if ok, err := client.NewRequest(gql, map[string]interface{}{
+			"first": pageSize,
+			"after": after,
+			"query": api.NullString(query),
+		}).Do(ctx, &result); err != nil || !ok {
+			return nil, err
+		}
+
+		contexts = append(contexts, result.SearchContexts.Nodes...)
+		if !result.SearchContexts.PageInfo.HasNextPage {
+			return contexts, nil
+		}
+		after = result.SearchContexts.PageInfo.EndCursor
+	}
+}
+
+// searchContextInputs resolves the namespace and the repositories of the
+// search context to their IDs, and returns the inputs of the mutations that
+// create and update search contexts.
+func searchContextInputs(ctx context.Context, client api.Client, def SearchContextDefinition) (input map[string]interface{}, repositories []map[string]interface{}, err error) {
+	input = map[string]interface{}{
+		"name":        def.Name,
+		"description": def.Description,
+		"public":      def.Public,
+		"query":       def.Query,
+	}
+
+	if def.Namespace != "" {
+		var result struct {
+			NamespaceByName *struct{ ID string }
+		}
+		if ok, err := client.NewRequest(`query NamespaceByName($name: String!) {
+    namespaceByName(name: $name) {
+        id
+    }
+}`, map[string]interface{}{"name": def.Namespace}).Do(ctx, &result); err != nil || !ok {
+			return nil, nil, err
+		}
+		if result.NamespaceByName == nil {
+			return nil, nil, errors.Newf("namespace %q not found", def.Namespace)
+		}
+		input["namespace"] = result.NamespaceByName.ID
+	}
+
+	repositories = []map[string]interface{}{}
+	for _, repo := range def.Repositories {
+		var result struct {
+			Repository *struct{ ID string }
+		}
+		if ok, err := client.NewRequest(`query RepositoryID($name: String!) {
+    repository(name: $name) {
+        id
+    }
+}`, map[string]interface{}{"name": repo.Repository}).Do(ctx, &result); err != nil || !ok {
+			return nil, nil, err
+		}
+		if result.Repository == nil {
+			return nil, nil, errors.Newf("repository %q not found", repo.Repository)
+		}
+
+		revisions := repo.Revisions
+		if len(revisions) == 0 {
+			revisions = []string{"HEAD"}
+		}
+		repositories = append(repositories, map[string]interface{}{
+			"repositoryID": result.Repository.ID,
+			"revisions":    revisions,
+		})
+	}
+
+	return input, repositories, nil
+}
+
+func createSearchContext(ctx context.Context, client api.Client, def SearchContextDefinition) (*SearchContext, error) {
+	input, repositories, err := searchContextInputs(ctx, client, def)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `mutation CreateSearchContext(
+  $searchContext: SearchContextInput!,
+  $repositories: [SearchContextRepositoryRevisionsInput!]!,
+) {
+  createSearchContext(
+    searchContext: $searchContext,
+    repositories: $repositories,
+  ) {
+    ...SearchContextFields
+  }
+}` + searchContextFragment
+
+	var result struct {
+		CreateSearchContext *SearchContext
+	}
+	if ok, err := client.NewRequest(query, map[string]interface{}{
+		"searchContext": input,
+		"repositories":  repositories,
+	}).Do(ctx, &result); err != nil || !ok {
+		return nil, err
+	}
+	return result.CreateSearchContext, nil
+}
+
+func updateSearchContext(ctx context.Context, client api.Client, id string, def SearchContextDefinition) (*SearchContext, error) {
+	input, repositories, err := searchContextInputs(ctx, client, def)
+	if err != nil {
+		return nil, err
+	}
+	// The namespace of a search context can't be changed.
+	delete(input, "namespace")
+
+	query := `mutation UpdateSearchContext(
+  $id: ID!,
+  $searchContext: SearchContextEditInput!,
+  $repositories: [SearchContextRepositoryRevisionsInput!]!,
+) {
+  updateSearchContext(
+    id: $id,
+    searchContext: $searchContext,
+    repositories: $repositories,
+  ) {
+    ...SearchContextFields
+  }
+}` + searchContextFragment
+
+	var result struct {
+		UpdateSearchContext *SearchContext
+	}
+	if ok, err := client.NewRequest(query, map[string]interface{}{
+		"id":            id,
+		"searchContext": input,
+		"repositories":  repositories,
+	}).Do(ctx, &result); err != nil || !ok {
+		return nil, err
+	}
+	return result.UpdateSearchContext, nil
+}
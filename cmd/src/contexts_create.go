@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+Examples:
+
+  Create a search context of the repositories matching a query:
+
+    	$ src contexts create -name=frontend -namespace=my-org -query='repo:^github\.com/my-org/web- lang:typescript'
+
+  Create a search context of repositories at specific revisions:
+
+    	$ src contexts create -name=release -repo=github.com/my-org/api@release-1.0 -repo=github.com/my-org/web@main:release-1.0
+
+  Create a search context defined in a YAML file:
+
+    	$ src contexts create -file=frontend.yaml
+
+`
+
+	flagSet := flag.NewFlagSet("create", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src contexts %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		repoFlags       stringSliceFlag
+		fileFlag        = flagSet.String("file", "", "A YAML file defining the search context, as written by 'src contexts export'. Replaces the other flags.")
+		nameFlag        = flagSet.String("name", "", "The name of the search context. (required)")
+		namespaceFlag   = flagSet.String("namespace", "", "The user or organization that owns the search context. If empty, it belongs to the instance, which requires a site admin.")
+		descriptionFlag = flagSet.String("description", "", "The description of the search context.")
+		publicFlag      = flagSet.Bool("public", false, "Whether the search context is visible to all users.")
+		queryFlag       = flagSet.String("query", "", "The search query that defines the repositories of the search context.")
+		apiFlags        = api.NewFlags(flagSet)
+	)
+	flagSet.Var(&repoFlags, "repo", "A repository of the search context, as REPO or REPO@REV1:REV2. Can be given multiple times.")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		var def SearchContextDefinition
+		if *fileFlag != "" {
+			defs, err := readSearchContextDefinitions(*fileFlag)
+			if err != nil {
+				return err
+			}
+			if len(defs) != 1 {
+				return cmderrors.Usagef("%s defines %d search contexts, use 'src contexts import' instead", *fileFlag, len(defs))
+			}
+			def = defs[0]
+		} else {
+			if *nameFlag == "" {
+				return cmderrors.Usage("-name or -file is required")
+			}
+			def = SearchContextDefinition{
+				Name:        *nameFlag,
+				Namespace:   *namespaceFlag,
+				Description: *descriptionFlag,
+				Public:      *publicFlag,
+				Query:       *queryFlag,
+			}
+			for _, repo := range repoFlags {
+				def.Repositories = append(def.Repositories, parseSearchContextRepository(repo))
+			}
+		}
+
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+		created, err := createSearchContext(context.Background(), client, def)
+		if err != nil || created == nil {
+			return err
+		}
+
+		fmt.Printf("Search context %q created.\n", created.Spec)
+		return nil
+	}
+
+	// Register the command.
+	contextsCommands = append(contextsCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
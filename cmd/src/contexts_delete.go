@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+Examples:
+
+  Delete a search context:
+
+    	$ src contexts delete -spec=@my-org/frontend
+
+`
+
+	flagSet := flag.NewFlagSet("delete", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src contexts %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		specFlag = flagSet.String("spec", "", `The spec of the search context to delete, e.g. "@my-org/frontend". (required)`)
+		apiFlags = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if *specFlag == "" {
+			return cmderrors.Usage("-spec is required")
+		}
+
+		ctx := context.Background()
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+
+		existing, err := getSearchContext(ctx, client, *specFlag)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return errors.Newf("search context %q not found", *specFlag)
+		}
+
+		query := `mutation DeleteSearchContext(
+  $id: ID!
+) {
+  deleteSearchContext(
+    id: $id
+  ) {
+    alwaysNil
+  }
+}`
+
+		var result struct {
+			DeleteSearchContext struct{}
+		}
+		if ok, err := client.NewRequest(query, map[string]interface{}{
+			"id": existing.ID,
+		}).Do(ctx, &result); err != nil || !ok {
+			return err
+		}
+
+		fmt.Printf("Search context %q deleted.\n", existing.Spec)
+		return nil
+	}
+
+	// Register the command.
+	contextsCommands = append(contextsCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+)
+
+func init() {
+	usage := `
+Examples:
+
+  Export all search contexts to a YAML file:
+
+    	$ src contexts export -o=contexts.yaml
+
+  Export the search contexts whose names match the query to stdout:
+
+    	$ src contexts export -query=frontend
+
+Search contexts that Sourcegraph defines automatically, such as those of users,
+aren't exported.
+`
+
+	flagSet := flag.NewFlagSet("export", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src contexts %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		queryFlag  = flagSet.String("query", "", `Exports search contexts whose names match the query. (e.g. "frontend")`)
+		outputFlag = flagSet.String("o", "", "The YAML file to write the search contexts to. Defaults to stdout.")
+		apiFlags   = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+
+		contexts, err := listSearchContexts(context.Background(), client, *queryFlag, -1)
+		if err != nil {
+			return err
+		}
+
+		defs := []SearchContextDefinition{}
+		for _, c := range contexts {
+			if c.AutoDefined {
+				continue
+			}
+			defs = append(defs, c.Definition())
+		}
+
+		data, err := yaml.Marshal(defs)
+		if err != nil {
+			return err
+		}
+		if *outputFlag == "" {
+			_, err = os.Stdout.Write(data)
+			return err
+		}
+		if err := os.WriteFile(*outputFlag, data, 0o644); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Exported %d search contexts to %s.\n", len(defs), *outputFlag)
+		return nil
+	}
+
+	// Register the command.
+	contextsCommands = append(contextsCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"reflect"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+Examples:
+
+  Create or update the search contexts defined in a YAML file:
+
+    	$ src contexts import -file=contexts.yaml
+
+The file contains a list of search contexts, as written by 'src contexts export':
+
+    - name: frontend
+      namespace: my-org
+      description: The web frontends
+      public: true
+      query: repo:^github\.com/my-org/web- lang:typescript
+    - name: release
+      repositories:
+        - repository: github.com/my-org/api
+          revisions: [release-1.0]
+
+Search contexts that don't exist yet are created, the others are updated to
+match the file.
+`
+
+	flagSet := flag.NewFlagSet("import", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src contexts %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		fileFlag = flagSet.String("file", "", "The YAML file defining the search contexts. (required)")
+		apiFlags = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if *fileFlag == "" {
+			return cmderrors.Usage("-file is required")
+		}
+
+		defs, err := readSearchContextDefinitions(*fileFlag)
+		if err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+
+		for _, def := range defs {
+			existing, err := getSearchContext(ctx, client, def.Spec())
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case existing == nil:
+				if _, err := createSearchContext(ctx, client, def); err != nil {
+					return err
+				}
+				fmt.Printf("Search context %q created.\n", def.Spec())
+
+			case searchContextsEqual(existing.Definition(), def):
+				fmt.Printf("Search context %q unchanged.\n", def.Spec())
+
+			default:
+				if _, err := updateSearchContext(ctx, client, existing.ID, def); err != nil {
+					return err
+				}
+				fmt.Printf("Search context %q updated.\n", def.Spec())
+			}
+		}
+		return nil
+	}
+
+	// Register the command.
+	contextsCommands = append(contextsCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
+
+// searchContextsEqual returns true if the definitions describe the same search
+// context. Repositories without revisions search their default branch, HEAD.
+func searchContextsEqual(a, b SearchContextDefinition) bool {
+	normalize := func(def SearchContextDefinition) SearchContextDefinition {
+		repos := make([]SearchContextRepositoryDefinition, 0, len(def.Repositories))
+		for _, repo := range def.Repositories {
+			if len(repo.Revisions) == 0 {
+				repo.Revisions = []string{"HEAD"}
+			}
+			repos = append(repos, repo)
+		}
+		def.Repositories = repos
+		return def
+	}
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
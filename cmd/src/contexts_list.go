@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+)
+
+func init() {
+	usage := `
+Examples:
+
+  List search contexts:
+
+    	$ src contexts list
+
+  List search contexts whose names match the query, with their descriptions:
+
+    	$ src contexts list -query='frontend' -f='{{.Spec}}: {{.Description}}'
+
+  List search contexts as a table, sorted by their number of repositories:
+
+    	$ src contexts list -columns=spec,repositories -sort=-repositories
+
+`
+
+	flagSet := flag.NewFlagSet("list", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src contexts %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		firstFlag  = flagSet.Int("first", 1000, "Returns the first n search contexts from the list. (use -1 for unlimited)")
+		queryFlag  = flagSet.String("query", "", `Returns search contexts whose names match the query. (e.g. "frontend")`)
+		formatFlag = flagSet.String("f", "{{.Spec}}", `Format for the output, using the syntax of Go package text/template. (e.g. "{{.Spec}}: {{.Query}}" or "{{.|json}}")`)
+		tableFlags = newTableFlags(flagSet, searchContextColumns, "spec", "public", "repositories", "updated-at")
+		apiFlags   = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+
+		tmpl, err := parseTemplate(*formatFlag)
+		if err != nil {
+			return err
+		}
+		tbl, err := tableFlags.table(flagSet)
+		if err != nil {
+			return err
+		}
+
+		contexts, err := listSearchContexts(context.Background(), client, *queryFlag, *firstFlag)
+		if err != nil {
+			return err
+		}
+		for _, c := range contexts {
+			if tbl != nil {
+				if err := tbl.add(c); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := execTemplate(tmpl, c); err != nil {
+				return err
+			}
+		}
+		if tbl != nil {
+			return tbl.write(os.Stdout)
+		}
+		return nil
+	}
+
+	// Register the command.
+	contextsCommands = append(contextsCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
+
+// searchContextColumns are the columns of the table output of src contexts list.
+var searchContextColumns = []tableColumn{
+	{name: "id", template: "{{.ID}}"},
+	{name: "spec", template: "{{.Spec}}"},
+	{name: "name", template: "{{.Name}}"},
+	{name: "namespace", template: "{{with .Namespace}}{{.NamespaceName}}{{end}}"},
+	{name: "description", template: "{{.Description}}"},
+	{name: "public", template: "{{.Public}}"},
+	{name: "auto-defined", template: "{{.AutoDefined}}"},
+	{name: "query", template: "{{.Query}}"},
+	{name: "repositories", template: "{{len .Repositories}}"},
+	{name: "updated-at", template: "{{.UpdatedAt}}"},
+}
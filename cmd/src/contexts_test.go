@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadSearchContextDefinitions(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("list", func(t *testing.T) {
+		defs, err := readSearchContextDefinitions(write("list.yaml", `
+- name: frontend
+  namespace: my-org
+  public: true
+  query: repo:^github\.com/my-org/web-
+- name: release
+  repositories:
+    - repository: github.com/my-org/api
+      revisions: [release-1.0]
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []SearchContextDefinition{
+			{Name: "frontend", Namespace: "my-org", Public: true, Query: `repo:^github\.com/my-org/web-`},
+			{Name: "release", Repositories: []SearchContextRepositoryDefinition{{Repository: "github.com/my-org/api", Revisions: []string{"release-1.0"}}}},
+		}
+		if diff := cmp.Diff(want, defs); diff != "" {
+			t.Errorf("wrong definitions (-want +have):\n%s", diff)
+		}
+		if have := defs[0].Spec(); have != "@my-org/frontend" {
+			t.Errorf("wrong spec %q", have)
+		}
+	})
+
+	t.Run("single", func(t *testing.T) {
+		defs, err := readSearchContextDefinitions(write("single.yaml", "name: frontend\ndescription: The web frontends\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []SearchContextDefinition{{Name: "frontend", Description: "The web frontends"}}
+		if diff := cmp.Diff(want, defs); diff != "" {
+			t.Errorf("wrong definitions (-want +have):\n%s", diff)
+		}
+	})
+
+	t.Run("missing name", func(t *testing.T) {
+		if _, err := readSearchContextDefinitions(write("invalid.yaml", "- description: nameless\n")); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
+
+func TestSearchContextsEqual(t *testing.T) {
+	a := SearchContextDefinition{Name: "api", Repositories: []SearchContextRepositoryDefinition{parseSearchContextRepository("github.com/my-org/api")}}
+	b := SearchContextDefinition{Name: "api", Repositories: []SearchContextRepositoryDefinition{parseSearchContextRepository("github.com/my-org/api@HEAD")}}
+	if !searchContextsEqual(a, b) {
+		t.Error("expected repositories without revisions to equal HEAD")
+	}
+
+	c := SearchContextDefinition{Name: "api", Repositories: []SearchContextRepositoryDefinition{parseSearchContextRepository("github.com/my-org/api@main:release")}}
+	if searchContextsEqual(a, c) {
+		t.Error("expected different revisions to differ")
+	}
+	if diff := cmp.Diff([]string{"main", "release"}, c.Repositories[0].Revisions); diff != "" {
+		t.Errorf("wrong revisions (-want +have):\n%s", diff)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+Examples:
+
+  Change the query of a search context:
+
+    	$ src contexts update -spec=@my-org/frontend -query='repo:^github\.com/my-org/web- lang:typescript'
+
+  Replace the repositories of a search context:
+
+    	$ src contexts update -spec=release -repo=github.com/my-org/api@release-1.1
+
+  Update a search context to its definition in a YAML file:
+
+    	$ src contexts update -file=frontend.yaml
+
+`
+
+	flagSet := flag.NewFlagSet("update", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src contexts %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		repoFlags       stringSliceFlag
+		specFlag        = flagSet.String("spec", "", `The spec of the search context to update, e.g. "@my-org/frontend". Defaults to the spec of the search context of -file.`)
+		fileFlag        = flagSet.String("file", "", "A YAML file defining the search context, as written by 'src contexts export'. Replaces the other flags.")
+		nameFlag        = flagSet.String("name", "", "The new name of the search context.")
+		descriptionFlag = flagSet.String("description", "", "The new description of the search context.")
+		publicFlag      = flagSet.Bool("public", false, "Whether the search context is visible to all users.")
+		queryFlag       = flagSet.String("query", "", "The new search query that defines the repositories of the search context.")
+		apiFlags        = api.NewFlags(flagSet)
+	)
+	flagSet.Var(&repoFlags, "repo", "A repository of the search context, as REPO or REPO@REV1:REV2, replacing the current ones. Can be given multiple times.")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		var fileDef *SearchContextDefinition
+		if *fileFlag != "" {
+			defs, err := readSearchContextDefinitions(*fileFlag)
+			if err != nil {
+				return err
+			}
+			if len(defs) != 1 {
+				return cmderrors.Usagef("%s defines %d search contexts, use 'src contexts import' instead", *fileFlag, len(defs))
+			}
+			fileDef = &defs[0]
+			if *specFlag == "" {
+				*specFlag = fileDef.Spec()
+			}
+		}
+		if *specFlag == "" {
+			return cmderrors.Usage("-spec or -file is required")
+		}
+
+		ctx := context.Background()
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+
+		existing, err := getSearchContext(ctx, client, *specFlag)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return errors.Newf("search context %q not found", *specFlag)
+		}
+
+		def := existing.Definition()
+		if fileDef != nil {
+			def = *fileDef
+		}
+		// Only the flags that are given change the search context.
+		flagSet.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "name":
+				def.Name = *nameFlag
+			case "description":
+				def.Description = *descriptionFlag
+			case "public":
+				def.Public = *publicFlag
+			case "query":
+				def.Query = *queryFlag
+			case "repo":
+				def.Repositories = nil
+				for _, repo := range repoFlags {
+					def.Repositories = append(def.Repositories, parseSearchContextRepository(repo))
+				}
+			}
+		})
+
+		updated, err := updateSearchContext(ctx, client, existing.ID, def)
+		if err != nil || updated == nil {
+			return err
+		}
+
+		fmt.Printf("Search context %q updated.\n", updated.Spec)
+		return nil
+	}
+
+	// Register the command.
+	contextsCommands = append(contextsCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
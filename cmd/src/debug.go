@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+	"github.com/sourcegraph/src-cli/internal/debug"
+)
+
+var debugCommands commander
+
+func init() {
+	usage := `'src debug' gathers information about a Sourcegraph deployment into a zip
+archive that can be shared with Sourcegraph support.
+
+The archive may contain sensitive information, such as the site configuration.
+Review its contents before sharing it. With -anonymize, host names, internal IP
+addresses, user names and repository names are replaced with pseudonyms, and
+the mapping to translate them back is written to a separate file.
+
+The amount of information that is collected is selected with -profile:
+minimal only describes the deployment and includes the logs of the last hour,
+standard, the default, adds resource usage, disk usage and all logs, and full
+adds node and database details and traces.
+
+Usage:
+
+	src debug command [command options]
+
+The commands are:
+
+	kube    gathers information about a Kubernetes deployment, optionally
+	        from several kubeconfig contexts
+	serv    gathers information about a single-container sourcegraph/server
+	        deployment and the Docker daemon it runs on (alias: docker)
+	diff    compares two debug archives of the same deployment
+
+Use "src debug [command] -h" for more information about a command.
+
+`
+
+	flagSet := flag.NewFlagSet("debug", flag.ExitOnError)
+	handler := func(args []string) error {
+		debugCommands.run(flagSet, "src debug", usage, args)
+		return nil
+	}
+
+	// Register the command.
+	commands = append(commands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: func() { fmt.Println(usage) },
+	})
+}
+
+// newDebugProfileFlag adds the -profile flag shared by the debug commands,
+// which selects how much is collected.
+func newDebugProfileFlag(flagSet *flag.FlagSet) *string {
+	return flagSet.String("profile", debug.ProfileStandard.String(), "How much to collect: "+strings.Join(debug.ProfileNames(), ", ")+". minimal only describes the deployment and includes the logs of the last hour, full adds node and database details and traces.")
+}
+
+// parseDebugProfile parses the value of the -profile flag.
+func parseDebugProfile(name string) (debug.Profile, error) {
+	profile, err := debug.ParseProfile(name)
+	if err != nil {
+		return profile, cmderrors.Usagef("invalid -profile: %s", err)
+	}
+	return profile, nil
+}
+
+// debugLogFlags are the flags shared by the debug commands that control the
+// collection of container logs.
+type debugLogFlags struct {
+	since       *time.Duration
+	timestamps  *bool
+	maxLogBytes *int
+	policy      *string
+	top         *int
+}
+
+func newDebugLogFlags(flagSet *flag.FlagSet) *debugLogFlags {
+	return &debugLogFlags{
+		since:       flagSet.Duration("logs-since", 0, "Only include container logs newer than a relative duration like 30m or 2h. Default is all logs."),
+		timestamps:  flagSet.Bool("timestamps", false, "Prefix each line of the container logs with its timestamp."),
+		maxLogBytes: flagSet.Int("max-log-bytes", 0, "Truncate each log file to at most this many bytes, according to -log-policy. Default is no limit."),
+		policy:      flagSet.String("log-policy", string(debug.LogPolicyHeadTail), "Which part of a log larger than -max-log-bytes to keep: head-tail, head or tail."),
+		top:         flagSet.Int("log-top", 20, "List this many of the most frequent messages of each log larger than -max-log-bytes in an additional -top.txt file. 0 disables the list."),
+	}
+}
+
+// options returns the log options for the given profile. With the minimal
+// profile, only recent logs are collected unless -logs-since is given.
+func (f *debugLogFlags) options(profile debug.Profile) (debug.LogOptions, error) {
+	policy, err := debug.ParseLogPolicy(*f.policy)
+	if err != nil {
+		return debug.LogOptions{}, cmderrors.Usagef("invalid -log-policy: %s", err)
+	}
+
+	opts := debug.LogOptions{
+		Since:       *f.since,
+		Timestamps:  *f.timestamps,
+		MaxBytes:    *f.maxLogBytes,
+		Policy:      policy,
+		TopMessages: *f.top,
+	}
+	if profile == debug.ProfileMinimal && opts.Since == 0 {
+		opts.Since = debug.MinimalLogsSince
+	}
+	return opts, nil
+}
+
+// debugTraceFlags are the flags shared by the debug commands that control the
+// collection of traces from Jaeger.
+type debugTraceFlags struct {
+	enabled     *bool
+	service     *string
+	since       *time.Duration
+	minDuration *time.Duration
+	limit       *int
+}
+
+func newDebugTraceFlags(flagSet *flag.FlagSet) *debugTraceFlags {
+	return &debugTraceFlags{
+		enabled:     flagSet.Bool("traces", false, "Include the slowest recent traces from the Jaeger instance of the deployment. Requires site admin access."),
+		service:     flagSet.String("traces-service", "frontend", "The service whose traces are collected with -traces."),
+		since:       flagSet.Duration("traces-since", time.Hour, "Only collect traces started in this duration before now."),
+		minDuration: flagSet.Duration("traces-min-duration", time.Second, "Only collect traces that took at least this long."),
+		limit:       flagSet.Int("traces-limit", 20, "The number of traces to collect."),
+	}
+}
+
+// files returns the trace files to include in the archive, if traces are
+// enabled or the profile is full.
+func (f *debugTraceFlags) files(ctx context.Context, client api.Client, profile debug.Profile) []*debug.File {
+	if !*f.enabled && profile < debug.ProfileFull {
+		return nil
+	}
+	return []*debug.File{debug.TraceFile(ctx, client, debug.TraceOptions{
+		Service:     *f.service,
+		Since:       *f.since,
+		MinDuration: *f.minDuration,
+		Limit:       *f.limit,
+	})}
+}
+
+// debugAnonymizeFlags are the flags shared by the debug commands that control
+// the anonymization of the archive.
+type debugAnonymizeFlags struct {
+	enabled *bool
+	mapping *string
+}
+
+func newDebugAnonymizeFlags(flagSet *flag.FlagSet) *debugAnonymizeFlags {
+	return &debugAnonymizeFlags{
+		enabled: flagSet.Bool("anonymize", false, "Replace host names, internal IP addresses, user names and repository names in the archive with consistent pseudonyms."),
+		mapping: flagSet.String("anonymize-mapping", "", "The file to write the mapping from pseudonyms to the original values to, with -anonymize. Default is the name of the archive with a -mapping.json suffix."),
+	}
+}
+
+// anonymizer returns the Anonymizer to apply to the files of the archive, or
+// nil if anonymization is disabled.
+func (f *debugAnonymizeFlags) anonymizer() *debug.Anonymizer {
+	if !*f.enabled {
+		return nil
+	}
+	return debug.NewAnonymizer()
+}
+
+// writeMapping writes the mapping of the anonymizer next to the archive at
+// archivePath, unless a different path was given. It's never included in the
+// archive itself, so that it can be kept by whoever shares the archive.
+func (f *debugAnonymizeFlags) writeMapping(anonymizer *debug.Anonymizer, archivePath string) error {
+	if anonymizer == nil {
+		return nil
+	}
+
+	path := *f.mapping
+	if path == "" {
+		path = strings.TrimSuffix(archivePath, filepath.Ext(archivePath)) + "-mapping.json"
+	}
+
+	data, err := json.MarshalIndent(anonymizer.Mapping(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return errors.Wrap(err, "writing anonymization mapping")
+	}
+
+	fmt.Printf("Anonymization mapping written to %s. It isn't part of the archive: keep it to translate the pseudonyms back.\n", path)
+	return nil
+}
+
+// createDebugArchive creates the zip file at path, failing if it already
+// exists, and returns an Archive writing to it. The base directory within the
+// archive is the file name without its extension.
+func createDebugArchive(path string) (*debug.Archive, func() error, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, nil, errors.Newf("file %s already exists", path)
+		}
+		return nil, nil, errors.Wrapf(err, "failed to create file %s", path)
+	}
+
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	archive := debug.NewArchive(f, base)
+
+	closeFn := func() error {
+		if err := archive.Close(); err != nil {
+			f.Close()
+			return errors.Wrap(err, "finishing archive")
+		}
+		return f.Close()
+	}
+	return archive, closeFn, nil
+}
+
+// writeDebugFiles adds the given files to the archive, printing a warning for
+// each file that couldn't be collected. If anonymizer isn't nil, the files are
+// anonymized before they're added.
+func writeDebugFiles(archive *debug.Archive, files []*debug.File, anonymizer *debug.Anonymizer) error {
+	for _, f := range files {
+		if anonymizer != nil {
+			f = anonymizer.File(f)
+		}
+		if f.Err != nil {
+			fmt.Fprintf(os.Stderr, "warning: collecting %s: %s\n", f.Path, f.Err)
+		}
+		if err := archive.Add(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
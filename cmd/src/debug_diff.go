@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	humanize "github.com/dustin/go-humanize"
+
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+	"github.com/sourcegraph/src-cli/internal/debug"
+)
+
+func init() {
+	usage := `
+'src debug diff' compares two debug archives of the same deployment, such as
+the archives of last week and of today, and prints what changed in between:
+
+- the Kubernetes resources that were added, removed, or whose spec changed,
+  such as the images, replicas and resources of the deployments and stateful
+  sets, and the ingresses and network policies
+- the containers that crash now and didn't before, or restarted more often
+- the changed fields of the site configuration and of the config maps
+- the containers whose CPU or memory usage changed by more than 25%
+
+Pods are matched by their workload, as their names change when they're
+replaced. The archives should be collected with the standard profile or
+above: the files that are missing from one of them are listed as skipped.
+
+Usage:
+
+    src debug diff [command options] OLD.zip NEW.zip
+
+Examples:
+
+    $ src debug diff debug-last-week.zip debug.zip
+
+    $ src debug diff -json debug-last-week.zip debug.zip
+
+`
+
+	flagSet := flag.NewFlagSet("diff", flag.ExitOnError)
+	jsonFlag := flagSet.Bool("json", false, "Print the changes as JSON.")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if flagSet.NArg() != 2 {
+			return cmderrors.Usage("expected the old and the new debug archive")
+		}
+
+		old, err := debug.ReadBundle(flagSet.Arg(0))
+		if err != nil {
+			return err
+		}
+		new, err := debug.ReadBundle(flagSet.Arg(1))
+		if err != nil {
+			return err
+		}
+
+		diff := debug.DiffBundles(old, new)
+		if *jsonFlag {
+			data, err := json.MarshalIndent(diff, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+		printDebugDiff(os.Stdout, diff)
+		return nil
+	}
+
+	debugCommands = append(debugCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src debug %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+// printDebugDiff prints the changes between two debug archives, by section.
+func printDebugDiff(w io.Writer, diff *debug.BundleDiff) {
+	if diff.Empty() {
+		fmt.Fprintln(w, "No changes found.")
+	}
+
+	if len(diff.Resources) > 0 {
+		fmt.Fprintln(w, "Changed resources:")
+		for _, r := range diff.Resources {
+			fmt.Fprintf(w, "  %s%s %s %s\n", debugDiffContext(r.Context), r.Kind, r.Name, r.Change)
+			for _, f := range r.Fields {
+				fmt.Fprintf(w, "      %s\n", formatFieldChange(f))
+			}
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(diff.Crashes) > 0 {
+		fmt.Fprintln(w, "Crashing containers:")
+		for _, c := range diff.Crashes {
+			fmt.Fprintf(w, "  %s%s/%s: %s, %d restarts (was %d)\n", debugDiffContext(c.Context), c.Workload, c.Container, c.Reason, c.Restarts, c.OldRestarts)
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(diff.Config) > 0 {
+		fmt.Fprintln(w, "Configuration drift:")
+		for _, c := range diff.Config {
+			fmt.Fprintf(w, "  %s%s: %s\n", debugDiffContext(c.Context), c.Source, formatFieldChange(c.FieldChange))
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(diff.Usage) > 0 {
+		fmt.Fprintln(w, "Resource usage:")
+		for _, u := range diff.Usage {
+			fmt.Fprintf(w, "  %s%s/%s: CPU %dm -> %dm, memory %s -> %s\n", debugDiffContext(u.Context), u.Workload, u.Container,
+				u.OldCPU, u.NewCPU, humanize.IBytes(uint64(u.OldMemory)), humanize.IBytes(uint64(u.NewMemory)))
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(diff.Skipped) > 0 {
+		fmt.Fprintln(w, "Skipped:")
+		for _, s := range diff.Skipped {
+			fmt.Fprintf(w, "  %s\n", s)
+		}
+	}
+}
+
+func debugDiffContext(context string) string {
+	if context == "" {
+		return ""
+	}
+	return "[" + context + "] "
+}
+
+// maxDebugDiffValue is the length of the values printed by 'src debug diff'
+// above which they're shortened.
+const maxDebugDiffValue = 80
+
+// formatFieldChange formats a changed field as PATH: OLD -> NEW, with the
+// values as JSON.
+func formatFieldChange(f debug.FieldChange) string {
+	value := func(v interface{}) string {
+		if v == nil {
+			return "(none)"
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		if len(data) > maxDebugDiffValue {
+			return string(data[:maxDebugDiffValue]) + "..."
+		}
+		return string(data)
+	}
+	s := value(f.Old) + " -> " + value(f.New)
+	if f.Path != "" {
+		s = f.Path + ": " + s
+	}
+	return s
+}
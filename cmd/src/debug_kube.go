@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"regexp"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+	"github.com/sourcegraph/src-cli/internal/debug"
+)
+
+func init() {
+	usage := `
+'src debug kube' gathers information about a Sourcegraph deployment on
+Kubernetes.
+
+The archive contains the state of the pods, services, events and persistent
+volume claims in the namespace, the resource usage of the pods, the logs of
+all their containers, and the site configuration. It also contains the
+resources that route traffic to and scale the deployment: endpoints,
+ingresses, Gateway API gateways and routes, network policies, deployments,
+stateful sets, daemon sets, horizontal pod autoscalers, and the config maps,
+with values that look like secrets redacted.
+
+Debug information can be collected from several clusters at once, for example
+when executors run in a separate cluster, by passing -context multiple times or
+by using -all-contexts. The files of each context are then placed in their own
+directory in the archive.
+
+kubectl is run with -kubeconfig and -kubectl-path when given, so that the
+right cluster can be selected without changing the environment. Before
+collecting, the context, cluster and namespace of each target are printed.
+
+On OpenShift, -openshift additionally collects the routes and image streams in
+the namespace, and the security context constraints that admitted its pods or
+are granted to its service accounts, which plain Kubernetes resources don't
+show. The resources are requested with their API groups, so this works with
+kubectl as well as with oc, which can be used with -kubectl-path oc.
+
+Usage:
+
+    src debug kube [command options]
+
+Examples:
+
+    $ src debug kube -o debug.zip
+
+    $ src debug kube -namespace sourcegraph -logs-since 1h
+
+    $ src debug kube -context production -context executors
+
+    $ src debug kube -kubeconfig ~/.kube/prod.yaml -kube-context admin -kubectl-path /opt/bin/kubectl
+
+    $ src debug kube -all-contexts -context-match '^prod-'
+
+    $ src debug kube -openshift -kubectl-path oc -namespace sourcegraph
+
+    $ src debug kube -traces -traces-since 30m -traces-limit 50
+
+    $ src debug kube -anonymize -anonymize-mapping ~/acme-mapping.json
+
+    $ src debug kube -profile full
+
+`
+
+	flagSet := flag.NewFlagSet("kube", flag.ExitOnError)
+
+	var (
+		contextFlags     stringSliceFlag
+		outFlag          = flagSet.String("o", "debug.zip", "The name of the zip archive to create.")
+		kubeconfigFlag   = flagSet.String("kubeconfig", "", "The kubeconfig file to use. Default is the kubeconfig of kubectl, which respects $KUBECONFIG.")
+		kubectlPathFlag  = flagSet.String("kubectl-path", "", "The kubectl binary to run. Default is kubectl from $PATH.")
+		namespaceFlag    = flagSet.String("namespace", "", "The namespace of the Sourcegraph deployment. Default is the namespace of the kubeconfig context.")
+		allContextsFlag  = flagSet.Bool("all-contexts", false, "Collect from all contexts in the kubeconfig.")
+		contextMatchFlag = flagSet.String("context-match", "", "With -all-contexts, only collect from contexts whose name matches this regular expression.")
+		openShiftFlag    = flagSet.Bool("openshift", false, "Also collect OpenShift routes, image streams and security context constraints.")
+		noConfigFlag     = flagSet.Bool("no-config", false, "Don't include the site configuration in the archive.")
+		profileFlag      = newDebugProfileFlag(flagSet)
+		traceFlags       = newDebugTraceFlags(flagSet)
+		logFlags         = newDebugLogFlags(flagSet)
+		anonymizeFlags   = newDebugAnonymizeFlags(flagSet)
+		apiFlags         = api.NewFlags(flagSet)
+	)
+	flagSet.Var(&contextFlags, "context", "The kubeconfig context to collect from. Can be given multiple times. Default is the current context.")
+	flagSet.Var(&contextFlags, "kube-context", "Alias for -context.")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+		profile, err := parseDebugProfile(*profileFlag)
+		if err != nil {
+			return err
+		}
+		logOpts, err := logFlags.options(profile)
+		if err != nil {
+			return err
+		}
+		if *allContextsFlag && len(contextFlags) > 0 {
+			return cmderrors.Usage("-context and -all-contexts are mutually exclusive")
+		}
+		if *contextMatchFlag != "" && !*allContextsFlag {
+			return cmderrors.Usage("-context-match requires -all-contexts")
+		}
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+
+		kubectl := debug.Kubectl{Path: *kubectlPathFlag, Kubeconfig: *kubeconfigFlag}
+
+		contexts := []string(contextFlags)
+		if *allContextsFlag {
+			if contexts, err = matchingKubeContexts(ctx, kubectl, *contextMatchFlag); err != nil {
+				return err
+			}
+		}
+
+		var targets []debug.KubeTarget
+		for _, c := range contexts {
+			targets = append(targets, debug.KubeTarget{Kubectl: kubectl, Context: c, Namespace: *namespaceFlag, OpenShift: *openShiftFlag})
+		}
+		if len(targets) == 0 {
+			targets = []debug.KubeTarget{{Kubectl: kubectl, Namespace: *namespaceFlag, OpenShift: *openShiftFlag}}
+		}
+
+		for _, target := range targets {
+			info, err := debug.DescribeKubeTarget(ctx, target)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Collecting from context %s: cluster %s (%s), namespace %s.\n", info.Context, info.Cluster, info.Server, info.Namespace)
+		}
+
+		archive, closeArchive, err := createDebugArchive(*outFlag)
+		if err != nil {
+			return err
+		}
+
+		files := debug.KubeContextFiles(ctx, targets, profile, logOpts)
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+		if !*noConfigFlag {
+			files = append(files, debug.SiteConfigFile(ctx, client))
+		}
+		files = append(files, traceFlags.files(ctx, client, profile)...)
+
+		anonymizer := anonymizeFlags.anonymizer()
+		if err := writeDebugFiles(archive, files, anonymizer); err != nil {
+			closeArchive()
+			return err
+		}
+		if err := closeArchive(); err != nil {
+			return err
+		}
+
+		fmt.Printf("Debug archive written to %s.\n", *outFlag)
+		return anonymizeFlags.writeMapping(anonymizer, *outFlag)
+	}
+
+	debugCommands = append(debugCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src debug %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+// matchingKubeContexts returns the kubeconfig contexts whose name matches the
+// given regular expression. An empty expression matches all contexts.
+func matchingKubeContexts(ctx context.Context, kubectl debug.Kubectl, expr string) ([]string, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, cmderrors.Usagef("invalid -context-match: %s", err)
+	}
+
+	all, err := debug.ListKubeContexts(ctx, kubectl)
+	if err != nil {
+		return nil, err
+	}
+
+	var contexts []string
+	for _, c := range all {
+		if re.MatchString(c) {
+			contexts = append(contexts, c)
+		}
+	}
+	if len(contexts) == 0 {
+		return nil, errors.Newf("no kubeconfig context matches %q", expr)
+	}
+	return contexts, nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+	"github.com/sourcegraph/src-cli/internal/debug"
+)
+
+func init() {
+	usage := `
+'src debug serv' gathers information about a single-container
+sourcegraph/server deployment.
+
+Besides the container's logs and its state as seen by Docker, the archive
+contains the disk usage of /var/opt/sourcegraph, the logs of the internal
+services under /var/log, the status of the embedded Postgres database, and the
+site configuration.
+
+It also describes the Docker daemon: its version and info, the disk usage of
+its images, containers and volumes (docker system df), the volumes of the
+container, the free space of the file systems in the container, and the
+daemon logs from journald on Linux or from the Docker Desktop log files on
+macOS and Windows, where they're accessible.
+
+'src debug docker' is an alias for 'src debug serv'.
+
+Usage:
+
+    src debug serv [command options]
+
+Examples:
+
+    $ src debug serv -o debug.zip
+
+    $ src debug serv -container sourcegraph -no-config
+
+    $ src debug serv -logs-since 2h -timestamps -max-log-bytes 10000000
+
+    $ src debug serv -max-log-bytes 10000000 -log-policy tail -log-top 50
+
+    $ src debug serv -traces -traces-since 30m -traces-limit 50
+
+    $ src debug serv -anonymize
+
+    $ src debug serv -profile minimal
+
+`
+
+	flagSet := flag.NewFlagSet("serv", flag.ExitOnError)
+
+	var (
+		outFlag        = flagSet.String("o", "debug.zip", "The name of the zip archive to create.")
+		containerFlag  = flagSet.String("container", "", "The name of the sourcegraph/server container. Default is the only running container with the sourcegraph/server image.")
+		noConfigFlag   = flagSet.Bool("no-config", false, "Don't include the site configuration in the archive.")
+		profileFlag    = newDebugProfileFlag(flagSet)
+		traceFlags     = newDebugTraceFlags(flagSet)
+		logFlags       = newDebugLogFlags(flagSet)
+		anonymizeFlags = newDebugAnonymizeFlags(flagSet)
+		apiFlags       = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+		profile, err := parseDebugProfile(*profileFlag)
+		if err != nil {
+			return err
+		}
+		logOpts, err := logFlags.options(profile)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+
+		container := *containerFlag
+		if container == "" {
+			if container, err = debug.FindServContainer(ctx); err != nil {
+				return err
+			}
+		}
+
+		archive, closeArchive, err := createDebugArchive(*outFlag)
+		if err != nil {
+			return err
+		}
+
+		files := debug.ServFiles(ctx, container, profile, logOpts)
+		if profile >= debug.ProfileStandard {
+			files = append(files, debug.DockerFiles(ctx, container, logOpts)...)
+		}
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+		if !*noConfigFlag {
+			files = append(files, debug.SiteConfigFile(ctx, client))
+		}
+		files = append(files, traceFlags.files(ctx, client, profile)...)
+
+		anonymizer := anonymizeFlags.anonymizer()
+		if err := writeDebugFiles(archive, files, anonymizer); err != nil {
+			closeArchive()
+			return err
+		}
+		if err := closeArchive(); err != nil {
+			return err
+		}
+
+		fmt.Printf("Debug archive written to %s.\n", *outFlag)
+		return anonymizeFlags.writeMapping(anonymizer, *outFlag)
+	}
+
+	debugCommands = append(debugCommands, &command{
+		flagSet: flagSet,
+		aliases: []string{"docker"},
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src debug %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
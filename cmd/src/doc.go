@@ -40,7 +40,7 @@ Examples:
 			return err
 		}
 
-		out := output.NewOutput(flagSet.Output(), output.OutputOpts{Verbose: *verbose})
+		out := newOutput(flagSet.Output(), *verbose)
 		if *outputFlag == "" {
 			out.WriteLine(output.Line(output.EmojiFailure, output.StyleWarning, "output directory must be set via -o"))
 			flagSet.Usage()
@@ -4,6 +4,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"os"
 
 	"github.com/sourcegraph/src-cli/internal/api"
 )
@@ -24,6 +25,10 @@ Examples:
 
     	$ src extensions list -first='-1'
 
+  List extensions as a table without a header, most recently updated first:
+
+    	$ src extensions list -columns=extension-id,updated-at -sort=-updated-at -no-header
+
 `
 
 	flagSet := flag.NewFlagSet("list", flag.ExitOnError)
@@ -36,6 +41,7 @@ Examples:
 		firstFlag  = flagSet.Int("first", 1000, "Returns the first n extensions from the list. (use -1 for unlimited)")
 		queryFlag  = flagSet.String("query", "", `Returns extensions whose extension IDs match the query. (e.g. "myextension")`)
 		formatFlag = flagSet.String("f", "{{.ExtensionID}}", `Format for the output, using the syntax of Go package text/template. (e.g. "{{.ExtensionID}}: {{.Manifest.Description}} ({{.RemoteURL}})" or "{{.|json}}")`)
+		tableFlags = newTableFlags(flagSet, extensionColumns, "extension-id", "title", "updated-at")
 		apiFlags   = api.NewFlags(flagSet)
 	)
 
@@ -48,6 +54,10 @@ Examples:
 		if err != nil {
 			return err
 		}
+		tbl, err := tableFlags.table(flagSet)
+		if err != nil {
+			return err
+		}
 
 		client := cfg.apiClient(apiFlags, flagSet.Output())
 
@@ -82,10 +92,19 @@ Examples:
 		}
 
 		for _, extension := range result.ExtensionRegistry.Extensions.Nodes {
+			if tbl != nil {
+				if err := tbl.add(extension); err != nil {
+					return err
+				}
+				continue
+			}
 			if err := execTemplate(tmpl, extension); err != nil {
 				return err
 			}
 		}
+		if tbl != nil {
+			return tbl.write(os.Stdout)
+		}
 		return nil
 	}
 
@@ -96,3 +115,18 @@ Examples:
 		usageFunc: usageFunc,
 	})
 }
+
+// extensionColumns are the columns of the table output of src extensions list.
+var extensionColumns = []tableColumn{
+	{name: "id", template: "{{.ID}}"},
+	{name: "extension-id", template: "{{.ExtensionID}}"},
+	{name: "name", template: "{{.Name}}"},
+	{name: "title", template: "{{.Manifest.Title}}"},
+	{name: "description", template: "{{.Manifest.Description}}"},
+	{name: "registry", template: "{{.RegistryName}}"},
+	{name: "local", template: "{{.IsLocal}}"},
+	{name: "url", template: "{{.URL}}"},
+	{name: "remote-url", template: "{{.RemoteURL}}"},
+	{name: "created-at", template: "{{.CreatedAt}}"},
+	{name: "updated-at", template: "{{.UpdatedAt}}"},
+}
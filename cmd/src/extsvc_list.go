@@ -4,6 +4,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"os"
 
 	"github.com/sourcegraph/src-cli/internal/api"
 )
@@ -20,6 +21,10 @@ Examples:
 
     	$ src extsvc list -f '{{.ID}}'
 
+  List external services as a table, sorted by kind:
+
+    	$ src extsvc list -sort=kind
+
 `
 
 	flagSet := flag.NewFlagSet("list", flag.ExitOnError)
@@ -31,6 +36,7 @@ Examples:
 	var (
 		firstFlag  = flagSet.Int("first", -1, "Return only the first n external services. (use -1 for unlimited)")
 		formatFlag = flagSet.String("f", "", `Format for the output, using the syntax of Go package text/template. (e.g. "{{.|json}}")`)
+		tableFlags = newTableFlags(flagSet, externalServiceColumns, "id", "kind", "display-name")
 		apiFlags   = api.NewFlags(flagSet)
 	)
 
@@ -55,6 +61,10 @@ Examples:
 		if err != nil {
 			return err
 		}
+		tbl, err := tableFlags.table(flagSet)
+		if err != nil {
+			return err
+		}
 
 		ctx := context.Background()
 		client := cfg.apiClient(apiFlags, flagSet.Output())
@@ -66,6 +76,14 @@ Examples:
 		if ok, err := client.NewRequest(externalServicesListQuery, queryVars).Do(ctx, &result); err != nil || !ok {
 			return err
 		}
+		if tbl != nil {
+			for _, node := range result.ExternalServices.Nodes {
+				if err := tbl.add(node); err != nil {
+					return err
+				}
+			}
+			return tbl.write(os.Stdout)
+		}
 		return execTemplate(tmpl, result.ExternalServices)
 	}
 
@@ -106,3 +124,13 @@ type externalServicesListResult struct {
 		}
 	}
 }
+
+// externalServiceColumns are the columns of the table output of src extsvc
+// list.
+var externalServiceColumns = []tableColumn{
+	{name: "id", template: "{{.id}}"},
+	{name: "kind", template: "{{.kind}}"},
+	{name: "display-name", template: "{{.displayName}}"},
+	{name: "created-at", template: "{{.createdAt}}"},
+	{name: "updated-at", template: "{{.updatedAt}}"},
+}
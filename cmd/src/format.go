@@ -93,6 +93,9 @@ func parseTemplate(text string) (*template.Template, error) {
 		"htmlToPlainText":                   searchTemplateFuncs["htmlToPlainText"],
 		"buildVersionHasNewSearchInterface": searchTemplateFuncs["buildVersionHasNewSearchInterface"],
 		"renderResult":                      searchTemplateFuncs["renderResult"],
+		"searchUseFileMatchRenderer":        searchTemplateFuncs["searchUseFileMatchRenderer"],
+		"searchRenderFileMatch":             searchTemplateFuncs["searchRenderFileMatch"],
+		"hyperlink":                         searchTemplateFuncs["hyperlink"],
 
 		// Register stream-search specific template functions.
 		"streamSearchSequentialLineNumber": streamSearchTemplateFuncs["streamSearchSequentialLineNumber"],
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+var gitserverCommands commander
+
+func init() {
+	usage := `'src gitserver' is a tool that remediates issues with the gitserver shards, which store the repositories of a Sourcegraph instance. It requires a site admin.
+
+Usage:
+
+	src gitserver command [command options]
+
+The commands are:
+
+	reclone       reclones repositories from their code hosts
+	disk-usage    reports the disk usage of the gitserver shards
+	corrupt       lists repositories whose clones are corrupt
+
+Use "src gitserver [command] -h" for more information about a command.
+`
+
+	flagSet := flag.NewFlagSet("gitserver", flag.ExitOnError)
+	handler := func(args []string) error {
+		gitserverCommands.run(flagSet, "src gitserver", usage, args)
+		return nil
+	}
+
+	// Register the command.
+	commands = append(commands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Println(usage)
+		},
+	})
+}
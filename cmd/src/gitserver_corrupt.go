@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+)
+
+func init() {
+	usage := `
+Examples:
+
+  List the repositories whose clones are corrupt:
+
+    	$ src gitserver corrupt
+
+  List them with the reason of their latest corruption:
+
+    	$ src gitserver corrupt -f='{{.Name}}: {{with .LatestCorruption}}{{.Reason}}{{end}}'
+
+`
+
+	flagSet := flag.NewFlagSet("corrupt", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src gitserver %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		firstFlag  = flagSet.Int("first", 1000, "Returns the first n corrupt repositories. (use -1 for unlimited)")
+		formatFlag = flagSet.String("f", "{{.Name}}", `Format for the output, using the syntax of Go package text/template. (e.g. "{{.Name}} {{.CorruptionLogs|json}}" or "{{.|json}}")`)
+		apiFlags   = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+
+		tmpl, err := parseTemplate(*formatFlag)
+		if err != nil {
+			return err
+		}
+
+		repos, err := listCorruptRepositories(ctx, client, *firstFlag)
+		if err != nil {
+			return err
+		}
+		for _, repo := range repos {
+			if err := execTemplate(tmpl, repo); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Register the command.
+	gitserverCommands = append(gitserverCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
+
+// CorruptRepository is a repository whose clone gitserver found to be
+// corrupt.
+type CorruptRepository struct {
+	Name           string              `json:"name"`
+	CorruptionLogs []RepoCorruptionLog `json:"corruptionLogs"`
+}
+
+type RepoCorruptionLog struct {
+	Timestamp time.Time `json:"timestamp"`
+	Reason    string    `json:"reason"`
+}
+
+// LatestCorruption returns the latest logged corruption of the repository,
+// or nil if none is logged.
+func (r *CorruptRepository) LatestCorruption() *RepoCorruptionLog {
+	var latest *RepoCorruptionLog
+	for i, log := range r.CorruptionLogs {
+		if latest == nil || log.Timestamp.After(latest.Timestamp) {
+			latest = &r.CorruptionLogs[i]
+		}
+	}
+	return latest
+}
+
+// listCorruptRepositories returns the repositories whose clones are corrupt,
+// or all if first is -1.
+func listCorruptRepositories(ctx context.Context, client api.Client, first int) ([]*CorruptRepository, error) {
+	query := `query CorruptRepositories($first: Int!, $after: String) {
+    repositories(first: $first, after: $after, corrupted: true) {
+        nodes {
+            name
+            mirrorInfo {
+                corruptionLogs {
+                    timestamp
+                    reason
+                }
+            }
+        }
+        pageInfo {
+            hasNextPage
+            endCursor
+        }
+    }
+}`
+
+	var (
+		repos []*CorruptRepository
+		after *string
+	)
+	for {
+		pageSize := 100
+		if first >= 0 && first-len(repos) < pageSize {
+			pageSize = first - len(repos)
+		}
+		if pageSize == 0 {
+			return repos, nil
+		}
+
+		var result struct {
+			Repositories struct {
+				Nodes []struct {
+					Name       string
+					MirrorInfo struct {
+						CorruptionLogs []RepoCorruptionLog
+					}
+				}
+				PageInfo struct {
+					HasNextPage bool
+					EndCursor   *string
+				}
+			}
+		}
+		if ok, err := client.NewRequest(query, map[string]interface{}{
+			"first": pageSize,
+			"after": after,
+		}).Do(ctx, &result); err != nil || !ok {
+			return nil, err
+		}
+
+		for _, node := range result.Repositories.Nodes {
+			repos = append(repos, &CorruptRepository{
+				Name:           node.Name,
+				CorruptionLogs: node.MirrorInfo.CorruptionLogs,
+			})
+		}
+		if !result.Repositories.PageInfo.HasNextPage {
+			return repos, nil
+		}
+		after = result.Repositories.PageInfo.EndCursor
+	}
+}
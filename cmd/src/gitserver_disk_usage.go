@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/cockroachdb/errors"
+	humanize "github.com/dustin/go-humanize"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+)
+
+func init() {
+	usage := `
+Examples:
+
+  Report the disk usage of the gitserver shards:
+
+    	$ src gitserver disk-usage
+
+  Fail if a shard has less than 10% of its disk space free, e.g. in a cron job:
+
+    	$ src gitserver disk-usage -min-free=10
+
+`
+
+	flagSet := flag.NewFlagSet("disk-usage", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src gitserver %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		minFreeFlag = flagSet.Float64("min-free", 0, "Fail if a shard has less than this percentage of its disk space free.")
+		formatFlag  = flagSet.String("f", "{{.Address}}: {{.Used}} of {{.Total}} used ({{printf \"%.1f\" .UsedPercent}}%), {{.Free}} free", `Format for the output, using the syntax of Go package text/template. (e.g. "{{.Address}} {{.FreeDiskSpaceBytes}}" or "{{.|json}}")`)
+		apiFlags    = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+
+		tmpl, err := parseTemplate(*formatFlag)
+		if err != nil {
+			return err
+		}
+
+		query := `query GitserverDiskUsage {
+    gitservers {
+        nodes {
+            id
+            address
+            freeDiskSpaceBytes
+            totalDiskSpaceBytes
+        }
+    }
+}`
+		var result struct {
+			Gitservers struct {
+				Nodes []*Gitserver
+			}
+		}
+		if ok, err := client.NewQuery(query).Do(ctx, &result); err != nil || !ok {
+			return err
+		}
+
+		var low []string
+		for _, g := range result.Gitservers.Nodes {
+			if err := execTemplate(tmpl, g); err != nil {
+				return err
+			}
+			if 100-g.UsedPercent() < *minFreeFlag {
+				low = append(low, g.Address)
+			}
+		}
+		if len(low) > 0 {
+			return errors.Newf("%d gitserver shards have less than %g%% of their disk space free: %v", len(low), *minFreeFlag, low)
+		}
+		return nil
+	}
+
+	// Register the command.
+	gitserverCommands = append(gitserverCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
+
+// Gitserver is a gitserver shard. The disk space is reported as strings, as
+// it can exceed the range of a GraphQL Int.
+type Gitserver struct {
+	ID                  string `json:"id"`
+	Address             string `json:"address"`
+	FreeDiskSpaceBytes  uint64 `json:"freeDiskSpaceBytes,string"`
+	TotalDiskSpaceBytes uint64 `json:"totalDiskSpaceBytes,string"`
+}
+
+// Free returns the free disk space in human-readable form.
+func (g *Gitserver) Free() string { return humanize.Bytes(g.FreeDiskSpaceBytes) }
+
+// Total returns the size of the disk in human-readable form.
+func (g *Gitserver) Total() string { return humanize.Bytes(g.TotalDiskSpaceBytes) }
+
+// Used returns the used disk space in human-readable form.
+func (g *Gitserver) Used() string {
+	if g.FreeDiskSpaceBytes > g.TotalDiskSpaceBytes {
+		return humanize.Bytes(0)
+	}
+	return humanize.Bytes(g.TotalDiskSpaceBytes - g.FreeDiskSpaceBytes)
+}
+
+// UsedPercent returns the percentage of the disk space that is used.
+func (g *Gitserver) UsedPercent() float64 {
+	if g.TotalDiskSpaceBytes == 0 || g.FreeDiskSpaceBytes > g.TotalDiskSpaceBytes {
+		return 0
+	}
+	return float64(g.TotalDiskSpaceBytes-g.FreeDiskSpaceBytes) / float64(g.TotalDiskSpaceBytes) * 100
+}
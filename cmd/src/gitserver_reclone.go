@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/cockroachdb/errors"
+	multierror "github.com/hashicorp/go-multierror"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+Examples:
+
+  Reclone one or more repositories:
+
+    	$ src gitserver reclone github.com/my/repo github.com/my/repo2
+
+  Reclone all repositories whose clones are corrupt:
+
+    	$ src gitserver corrupt | xargs src gitserver reclone
+
+`
+
+	flagSet := flag.NewFlagSet("reclone", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src gitserver %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	apiFlags := api.NewFlags(flagSet)
+
+	recloneRepository := func(ctx context.Context, client api.Client, repoName string) error {
+		repoID, err := fetchRepositoryID(ctx, client, repoName)
+		if err != nil {
+			return err
+		}
+
+		query := `mutation RecloneRepository($repoID: ID!) {
+    recloneRepository(repo: $repoID) {
+        alwaysNil
+    }
+}`
+		var result struct{}
+		if ok, err := client.NewRequest(query, map[string]interface{}{
+			"repoID": repoID,
+		}).Do(ctx, &result); err != nil || !ok {
+			return err
+		}
+
+		fmt.Printf("Repository %q is being recloned.\n", repoName)
+		return nil
+	}
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if flagSet.NArg() == 0 {
+			return cmderrors.Usage("at least one repository is required")
+		}
+
+		ctx := context.Background()
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+
+		var errs *multierror.Error
+		for _, repoName := range flagSet.Args() {
+			if err := recloneRepository(ctx, client, repoName); err != nil {
+				errs = multierror.Append(errs, errors.Wrapf(err, "Failed to reclone repository %q", repoName))
+			}
+		}
+		return errs.ErrorOrNil()
+	}
+
+	// Register the command.
+	gitserverCommands = append(gitserverCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
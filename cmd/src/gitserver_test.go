@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestGitserverDiskUsage(t *testing.T) {
+	var g Gitserver
+	if err := json.Unmarshal([]byte(`{"address": "gitserver-0:3178", "freeDiskSpaceBytes": "250000000000", "totalDiskSpaceBytes": "1000000000000"}`), &g); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := g.UsedPercent(), 75.0; have != want {
+		t.Errorf("wrong used percentage: want %g, have %g", want, have)
+	}
+	if have, want := g.Used(), "750 GB"; have != want {
+		t.Errorf("wrong used space: want %q, have %q", want, have)
+	}
+
+	if have := (&Gitserver{}).UsedPercent(); have != 0 {
+		t.Errorf("wrong used percentage of unknown disk: %g", have)
+	}
+}
+
+func TestLatestCorruption(t *testing.T) {
+	now := time.Now()
+	repo := &CorruptRepository{CorruptionLogs: []RepoCorruptionLog{
+		{Timestamp: now.Add(-time.Hour), Reason: "old"},
+		{Timestamp: now, Reason: "new"},
+		{Timestamp: now.Add(-2 * time.Hour), Reason: "older"},
+	}}
+	if latest := repo.LatestCorruption(); latest == nil || latest.Reason != "new" {
+		t.Errorf("wrong latest corruption: %+v", latest)
+	}
+	if latest := (&CorruptRepository{}).LatestCorruption(); latest != nil {
+		t.Errorf("unexpected latest corruption: %+v", latest)
+	}
+}
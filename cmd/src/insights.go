@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+var insightsCommands commander
+
+func init() {
+	usage := `'src insights' is a tool that manages code insights on a Sourcegraph instance.
+
+Usage:
+
+	src insights command [command options]
+
+The commands are:
+
+	create-from-search    creates a search-based insight
+
+Use "src insights [command] -h" for more information about a command.
+`
+
+	flagSet := flag.NewFlagSet("insights", flag.ExitOnError)
+	handler := func(args []string) error {
+		insightsCommands.run(flagSet, "src insights", usage, args)
+		return nil
+	}
+
+	// Register the command.
+	commands = append(commands, &command{
+		flagSet: flagSet,
+		aliases: []string{"insight"},
+		handler: handler,
+		usageFunc: func() {
+			fmt.Println(usage)
+		},
+	})
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+	"github.com/sourcegraph/src-cli/internal/insights"
+)
+
+func init() {
+	usage := `
+'src insights create-from-search' creates a search-based insight, which plots
+the number of results of search queries over time, from flags or from a YAML
+definition file, so that dashboards can be provisioned as code.
+
+Each -query adds a series to the insight, labeled by the -label and colored
+by the -color given in the same position, if any. The queries are run in the
+repositories given by -repos, or in all repositories if it's not given.
+
+A definition file has the following format:
+
+    title: Go versions
+    repositories: [github.com/sourcegraph/src-cli]  # optional, default is all
+    interval: 1w                                    # optional, default is 1mo
+    dashboards: [RGFzaGJvYXJkOjE=]                  # optional
+    series:
+      - label: Go 1.16
+        query: file:go.mod ^go 1.16
+      - label: Go 1.17
+        query: file:go.mod ^go 1.17
+        color: "#37b24d"                            # optional
+
+-title, -repos, -interval, and -dashboard override the values of the file.
+
+Usage:
+
+    src insights create-from-search -title TITLE -query QUERY [-query QUERY...] [command options]
+    src insights create-from-search -file FILE [command options]
+
+Examples:
+
+  Create an insight tracking TODOs in two repositories every week:
+
+    	$ src insights create-from-search -title 'TODOs' -query 'TODO' -repos github.com/a/b,github.com/c/d -interval 1w
+
+  Create an insight with two series in all repositories:
+
+    	$ src insights create-from-search -title 'Go versions' -query 'file:go.mod ^go 1.16' -label 'Go 1.16' -query 'file:go.mod ^go 1.17' -label 'Go 1.17'
+
+  Create the insight defined in a file and add it to a dashboard:
+
+    	$ src insights create-from-search -file go-versions.yaml -dashboard RGFzaGJvYXJkOjE=
+
+  Print the GraphQL input of the insight without creating it:
+
+    	$ src insights create-from-search -file go-versions.yaml -dry-run
+`
+
+	flagSet := flag.NewFlagSet("create-from-search", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src insights %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+
+	var (
+		fileFlag     = flagSet.String("file", "", "The YAML definition file of the insight, or - for stdin.")
+		titleFlag    = flagSet.String("title", "", "The title of the insight.")
+		reposFlag    = flagSet.String("repos", "", "The comma-separated repositories to run the queries in. Default is all repositories.")
+		intervalFlag = flagSet.String("interval", "", fmt.Sprintf("The time between data points, such as 1d, 1w, or 3mo. Default is %s.", insights.DefaultInterval))
+		dryRunFlag   = flagSet.Bool("dry-run", false, "Print the GraphQL input of the insight instead of creating it.")
+		queries      stringSliceFlag
+		labels       stringSliceFlag
+		colors       stringSliceFlag
+		dashboards   stringSliceFlag
+		apiFlags     = api.NewFlags(flagSet)
+	)
+	flagSet.Var(&queries, "query", "The search query of a series. Can be given multiple times.")
+	flagSet.Var(&labels, "label", "The label of the series of the -query in the same position. Defaults to the query. Can be given multiple times.")
+	flagSet.Var(&colors, "color", "The line color of the series of the -query in the same position, as a CSS color. Can be given multiple times.")
+	flagSet.Var(&dashboards, "dashboard", "The ID of a dashboard to add the insight to. Can be given multiple times.")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+
+		var def *insights.Definition
+		if *fileFlag != "" {
+			if len(queries) > 0 || len(labels) > 0 || len(colors) > 0 {
+				return cmderrors.Usage("-query, -label, and -color cannot be used together with -file")
+			}
+			data, err := readInsightDefinition(*fileFlag)
+			if err != nil {
+				return err
+			}
+			if def, err = insights.ParseDefinition(data); err != nil {
+				return cmderrors.WithKind(err, cmderrors.KindValidation)
+			}
+		} else {
+			if len(labels) > len(queries) || len(colors) > len(queries) {
+				return cmderrors.Usage("-label and -color must not be given more often than -query")
+			}
+			def = &insights.Definition{}
+			for i, query := range queries {
+				s := insights.Series{Query: query}
+				if i < len(labels) {
+					s.Label = labels[i]
+				}
+				if i < len(colors) {
+					s.Color = colors[i]
+				}
+				def.Series = append(def.Series, s)
+			}
+		}
+
+		if *titleFlag != "" {
+			def.Title = *titleFlag
+		}
+		if *reposFlag != "" {
+			def.Repositories = nil
+			for _, repo := range strings.Split(*reposFlag, ",") {
+				def.Repositories = append(def.Repositories, strings.TrimSpace(repo))
+			}
+		}
+		if *intervalFlag != "" {
+			def.Interval = *intervalFlag
+		}
+		if len(dashboards) > 0 {
+			def.Dashboards = dashboards
+		}
+
+		if err := def.Validate(); err != nil {
+			if *fileFlag == "" {
+				return cmderrors.Usage(err.Error())
+			}
+			return cmderrors.WithKind(err, cmderrors.KindValidation)
+		}
+		input, err := def.Input()
+		if err != nil {
+			return err
+		}
+
+		if *dryRunFlag {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(input)
+		}
+
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+		id, err := createSearchInsight(context.Background(), client, input)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Insight %s created with ID %s.\n", def, id)
+		return nil
+	}
+
+	insightsCommands = append(insightsCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
+
+// readInsightDefinition reads the definition file at path, or stdin if path is
+// -.
+func readInsightDefinition(path string) ([]byte, error) {
+	if path == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		return data, errors.Wrap(err, "reading insight definition from stdin")
+	}
+	data, err := os.ReadFile(path)
+	return data, errors.Wrap(err, "reading insight definition")
+}
+
+const createSearchInsightMutation = `mutation CreateSearchInsight($input: LineChartSearchInsightInput!) {
+	createLineChartSearchInsight(input: $input) {
+		view {
+			id
+		}
+	}
+}`
+
+// createSearchInsight creates a search-based insight from the given
+// LineChartSearchInsightInput and returns the ID of its view.
+func createSearchInsight(ctx context.Context, client api.Client, input map[string]interface{}) (string, error) {
+	var result struct {
+		CreateLineChartSearchInsight struct {
+			View struct {
+				ID string
+			}
+		}
+	}
+	if ok, err := client.NewRequest(createSearchInsightMutation, map[string]interface{}{
+		"input": input,
+	}).Do(ctx, &result); err != nil || !ok {
+		return "", err
+	}
+	return result.CreateLineChartSearchInsight.View.ID, nil
+}
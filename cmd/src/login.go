@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/sourcegraph/src-cli/internal/api"
 	"github.com/sourcegraph/src-cli/internal/cmderrors"
@@ -123,5 +124,159 @@ func loginCmd(ctx context.Context, cfg *config, client api.Client, endpointArg s
 	fmt.Fprintln(out)
 	fmt.Fprintf(out, "✔️  Authenticated as %s on %s\n", result.CurrentUser.Username, endpointArg)
 	fmt.Fprintln(out)
+
+	printLoginDetails(out, fetchLoginDetails(ctx, client))
 	return nil
 }
+
+// loginDetails are the details of the authenticated user and the instance
+// that src login reports. Details are nil if they couldn't be determined, for
+// example because the instance doesn't support the query.
+type loginDetails struct {
+	SiteAdmin *bool
+	// Scopes are the scopes of the access token that was used most recently,
+	// which is the one src login used, unless the user has several tokens in
+	// use at the same time: Sourcegraph doesn't tell which token a request
+	// used.
+	Scopes       []string
+	Product      string
+	LicenseTags  []string
+	BatchChanges *bool
+}
+
+const loginUserQuery = `query LoginUser {
+    currentUser {
+        siteAdmin
+        accessTokens(first: 100) {
+            nodes {
+                scopes
+                lastUsedAt
+            }
+        }
+    }
+}`
+
+const loginLicenseQuery = `query LoginLicense {
+    site {
+        productSubscription {
+            productNameWithBrand
+            license {
+                tags
+            }
+        }
+    }
+}`
+
+const loginBatchChangesQuery = `query LoginBatchChanges {
+    enterpriseLicenseHasFeature(feature: "batch-changes")
+}`
+
+// fetchLoginDetails queries the details reported by src login. Failing
+// queries leave their details unset.
+func fetchLoginDetails(ctx context.Context, client api.Client) loginDetails {
+	var details loginDetails
+
+	var user struct {
+		CurrentUser *struct {
+			SiteAdmin    *bool
+			AccessTokens *struct {
+				Nodes []struct {
+					Scopes     []string
+					LastUsedAt *time.Time
+				}
+			}
+		}
+	}
+	if ok, err := client.NewRequest(loginUserQuery, nil).Do(ctx, &user); err == nil && ok && user.CurrentUser != nil {
+		details.SiteAdmin = user.CurrentUser.SiteAdmin
+		if user.CurrentUser.AccessTokens != nil {
+			var lastUsed time.Time
+			for _, token := range user.CurrentUser.AccessTokens.Nodes {
+				if token.LastUsedAt != nil && !token.LastUsedAt.Before(lastUsed) {
+					lastUsed = *token.LastUsedAt
+					details.Scopes = token.Scopes
+				}
+			}
+		}
+	}
+
+	var license struct {
+		Site *struct {
+			ProductSubscription *struct {
+				ProductNameWithBrand string
+				License              *struct{ Tags []string }
+			}
+		}
+	}
+	if ok, err := client.NewRequest(loginLicenseQuery, nil).Do(ctx, &license); err == nil && ok && license.Site != nil && license.Site.ProductSubscription != nil {
+		details.Product = license.Site.ProductSubscription.ProductNameWithBrand
+		if license.Site.ProductSubscription.License != nil {
+			details.LicenseTags = license.Site.ProductSubscription.License.Tags
+		}
+	}
+
+	var batchChanges struct {
+		EnterpriseLicenseHasFeature *bool
+	}
+	if ok, err := client.NewRequest(loginBatchChangesQuery, nil).Do(ctx, &batchChanges); err == nil && ok {
+		details.BatchChanges = batchChanges.EnterpriseLicenseHasFeature
+	}
+
+	return details
+}
+
+// printLoginDetails prints the known details, and warnings about what keeps
+// common commands from working.
+func printLoginDetails(out io.Writer, details loginDetails) {
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+
+	var lines, warnings []string
+	if details.SiteAdmin != nil {
+		lines = append(lines, fmt.Sprintf("Site admin:    %s", yesNo(*details.SiteAdmin)))
+	}
+	if details.Scopes != nil {
+		lines = append(lines, fmt.Sprintf("Token scopes:  %s", strings.Join(details.Scopes, ", ")))
+		if !containsString(details.Scopes, "user:all") {
+			warnings = append(warnings, "The access token doesn't have the user:all scope, which all commands, including src batch and src lsif upload, require. Create a token with the user:all scope.")
+		}
+	}
+	if details.Product != "" {
+		product := details.Product
+		if len(details.LicenseTags) > 0 {
+			product += fmt.Sprintf(" (license tags: %s)", strings.Join(details.LicenseTags, ", "))
+		}
+		lines = append(lines, fmt.Sprintf("Product:       %s", product))
+	}
+	if details.BatchChanges != nil {
+		lines = append(lines, fmt.Sprintf("Batch Changes: %s", map[bool]string{true: "licensed", false: "not licensed"}[*details.BatchChanges]))
+		if !*details.BatchChanges {
+			warnings = append(warnings, "Batch Changes isn't licensed on this instance, so src batch can only create batch changes with up to 5 changesets.")
+		}
+	}
+
+	if len(lines) == 0 {
+		return
+	}
+	for _, line := range lines {
+		fmt.Fprintf(out, "   %s\n", line)
+	}
+	fmt.Fprintln(out)
+	for _, warning := range warnings {
+		fmt.Fprintf(out, "⚠️  Warning: %s\n", warning)
+		fmt.Fprintln(out)
+	}
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
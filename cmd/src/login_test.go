@@ -92,4 +92,33 @@ func TestLogin(t *testing.T) {
 			t.Errorf("got output %q, want %q", out, wantOut)
 		}
 	})
+
+	t.Run("valid with details", func(t *testing.T) {
+		// Dummy HTTP server to return the user, token and license details.
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			switch {
+			case strings.Contains(string(body), "LoginUser"):
+				fmt.Fprintln(w, `{"data":{"currentUser":{"siteAdmin":false,"accessTokens":{"nodes":[{"scopes":["user:all"],"lastUsedAt":"2021-01-01T00:00:00Z"},{"scopes":["site-admin:sudo"],"lastUsedAt":"2021-06-01T00:00:00Z"},{"scopes":["user:all"],"lastUsedAt":null}]}}}}`)
+			case strings.Contains(string(body), "LoginLicense"):
+				fmt.Fprintln(w, `{"data":{"site":{"productSubscription":{"productNameWithBrand":"Sourcegraph Enterprise","license":{"tags":["dev"]}}}}}`)
+			case strings.Contains(string(body), "LoginBatchChanges"):
+				fmt.Fprintln(w, `{"data":{"enterpriseLicenseHasFeature":false}}`)
+			default:
+				fmt.Fprintln(w, `{"data":{"currentUser":{"username":"alice"}}}`)
+			}
+		}))
+		defer s.Close()
+
+		endpoint := s.URL
+		out, err := check(t, &config{Endpoint: endpoint, AccessToken: "x"}, endpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantOut := "✔️  Authenticated as alice on $ENDPOINT\n\n   Site admin:    no\n   Token scopes:  site-admin:sudo\n   Product:       Sourcegraph Enterprise (license tags: dev)\n   Batch Changes: not licensed\n\n⚠️  Warning: The access token doesn't have the user:all scope, which all commands, including src batch and src lsif upload, require. Create a token with the user:all scope.\n\n⚠️  Warning: Batch Changes isn't licensed on this instance, so src batch can only create batch changes with up to 5 changesets."
+		wantOut = strings.ReplaceAll(wantOut, "$ENDPOINT", endpoint)
+		if out != wantOut {
+			t.Errorf("got output %q, want %q", out, wantOut)
+		}
+	})
 }
@@ -16,7 +16,8 @@ Usage:
 
 The commands are:
 
-	upload     uploads an LSIF dump file
+	upload          uploads an LSIF dump file
+	flush-queue     retries uploads queued by 'src lsif upload -queue-dir'
 
 Use "src lsif [command] -h" for more information about a command.
 `
@@ -30,7 +31,7 @@ Use "src lsif [command] -h" for more information about a command.
 	// Register the command.
 	commands = append(commands, &command{
 		flagSet: flagSet,
-		aliases: []string{"lsif"},
+		aliases: []string{"code-intel"},
 		handler: handler,
 		usageFunc: func() {
 			fmt.Println(usage)
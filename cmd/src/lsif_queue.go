@@ -0,0 +1,181 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/sourcegraph/lib/codeintel/upload"
+	"github.com/sourcegraph/sourcegraph/lib/output"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+	"github.com/sourcegraph/src-cli/internal/codeintel"
+)
+
+func init() {
+	usage := `
+'src lsif flush-queue' retries the uploads that failed and were stored in a
+queue directory by 'src lsif upload -queue-dir'. Uploads that succeed are
+removed from the queue; uploads that fail again stay in it.
+
+Examples:
+
+  Retry all queued uploads:
+
+    	$ src lsif flush-queue -queue-dir=~/.lsif-queue
+
+  List the queued uploads without retrying them:
+
+    	$ src lsif flush-queue -queue-dir=~/.lsif-queue -list
+`
+
+	flagSet := flag.NewFlagSet("flush-queue", flag.ExitOnError)
+
+	var (
+		queueDirFlag    = flagSet.String("queue-dir", "", "The queue directory given to 'src lsif upload -queue-dir'.")
+		listFlag        = flagSet.Bool("list", false, "Only list the queued uploads.")
+		gitHubTokenFlag = flagSet.String("github-token", "", `A GitHub access token with 'public_repo' scope that Sourcegraph uses to verify you have access to the repository.`)
+		maxPayloadFlag  = flagSet.Int64("max-payload-size", 100, `The maximum upload size (in megabytes). Indexes exceeding this limit will be uploaded over multiple HTTP requests.`)
+		apiFlags        = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+		if *queueDirFlag == "" {
+			return cmderrors.Usage("-queue-dir is required")
+		}
+
+		queue, err := codeintel.OpenQueue(*queueDirFlag)
+		if err != nil {
+			return err
+		}
+		entries, err := queue.List()
+		if err != nil {
+			return err
+		}
+
+		out := newOutput(flagSet.Output(), *verbose)
+		if len(entries) == 0 {
+			out.Write("No queued uploads.")
+			return nil
+		}
+
+		if *listFlag {
+			for _, e := range entries {
+				out.Writef("%s: %s@%s root=%q indexer=%s, %d failed attempts, last error: %s", e.ID, e.Repo, e.Commit, e.Root, e.Indexer, e.Attempts, e.LastError)
+			}
+			return nil
+		}
+		if err := cfg.checkWritable("upload LSIF data"); err != nil {
+			return err
+		}
+
+		client := api.NewClient(api.ClientOpts{
+			Out:   io.Discard,
+			Flags: apiFlags,
+		})
+
+		failed := 0
+		for _, e := range entries {
+			opts := upload.UploadOptions{
+				UploadRecordOptions: upload.UploadRecordOptions{
+					Repo:              e.Repo,
+					Commit:            e.Commit,
+					Root:              e.Root,
+					Indexer:           e.Indexer,
+					AssociatedIndexID: e.AssociatedIndexID,
+				},
+				SourcegraphInstanceOptions: upload.SourcegraphInstanceOptions{
+					SourcegraphURL:      cfg.Endpoint,
+					AccessToken:         cfg.AccessToken,
+					AdditionalHeaders:   cfg.AdditionalHeaders,
+					MaxRetries:          5,
+					RetryInterval:       time.Second,
+					Path:                "/.api/lsif/upload",
+					GitHubToken:         *gitHubTokenFlag,
+					MaxPayloadSizeBytes: *maxPayloadFlag * 1000 * 1000,
+				},
+			}
+
+			uploadID, err := upload.UploadIndex(e.File, client, opts)
+			if err != nil {
+				failed++
+				e.Attempts++
+				e.LastError = err.Error()
+				if err := queue.Update(e); err != nil {
+					return err
+				}
+				out.WriteLine(output.Linef(output.EmojiFailure, output.StyleWarning, "%s@%s (root %q): %s", e.Repo, e.Commit, e.Root, err))
+				continue
+			}
+
+			if err := queue.Remove(e.ID); err != nil {
+				return err
+			}
+			out.WriteLine(output.Linef(output.EmojiSuccess, output.StyleSuccess, "%s@%s (root %q): uploaded as %d", e.Repo, e.Commit, e.Root, uploadID))
+		}
+
+		if failed > 0 {
+			return errors.Newf("%d of %d queued uploads failed and remain in the queue", failed, len(entries))
+		}
+		return nil
+	}
+
+	lsifCommands = append(lsifCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src lsif %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+// queueLSIFUpload stores the index file of a failed upload in the queue given
+// by -queue-dir, so that it can be retried with 'src lsif flush-queue'. It
+// reports whether -queue-dir is set; if it is, the returned error is the
+// error of queueing the upload, not of the upload itself. A notice is written
+// to out, unless it's nil.
+//
+// Uploads rejected because of missing credentials aren't queued, as retrying
+// them can't succeed.
+func queueLSIFUpload(out *output.Output, file string, opts upload.UploadRecordOptions, uploadErr error) (bool, error) {
+	if lsifUploadFlags.queueDir == "" || uploadErr == upload.ErrUnauthorized {
+		return false, nil
+	}
+
+	queue, err := codeintel.OpenQueue(lsifUploadFlags.queueDir)
+	if err != nil {
+		return true, err
+	}
+	id, err := queue.Add(file, codeintel.QueuedUpload{
+		Repo:              opts.Repo,
+		Commit:            opts.Commit,
+		Root:              opts.Root,
+		Indexer:           opts.Indexer,
+		AssociatedIndexID: opts.AssociatedIndexID,
+		QueuedAt:          time.Now(),
+		Attempts:          1,
+		LastError:         uploadErr.Error(),
+	})
+	if err != nil {
+		return true, errors.Wrapf(err, "upload failed (%s) and could not be queued", uploadErr)
+	}
+
+	if out != nil {
+		out.WriteLine(output.Linef(output.EmojiWarning, output.StyleWarning, "Upload failed: %s", uploadErr))
+		out.WriteLine(output.Linef(output.EmojiLightbulb, output.StyleItalic, "Queued as %s in %s. Retry with 'src lsif flush-queue -queue-dir=%s'.", id, lsifUploadFlags.queueDir, lsifUploadFlags.queueDir))
+	}
+	return true, nil
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/lib/output"
 
 	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/codeintel"
 )
 
 func init() {
@@ -31,6 +32,23 @@ Examples:
 
     	$ src lsif upload -root=cmd/
 
+  Upload the LSIF dumps of all Go modules below cmd/, each with its own root:
+
+    	$ src lsif upload -root='cmd/**' -file=dump.lsif
+
+  Upload the LSIF dumps that the indexers of a CI build write to out/ while
+  they finish, exiting once no new dumps appear for ten minutes:
+
+    	$ src lsif upload -watch=out/ -idle-timeout=10m
+
+  Upload an LSIF dump from a CI build, reading the commit from the environment:
+
+    	$ src lsif upload -commit-from-env=auto
+
+  Upload an LSIF dump, keeping it for a later retry if the upload fails:
+
+    	$ src lsif upload -queue-dir=~/.lsif-queue
+
   Upload an LSIF dump when lsifEnforceAuth is enabled:
 
     	$ src lsif upload -github-token=BAZ
@@ -66,14 +84,32 @@ func handleLSIFUpload(args []string) error {
 	if err != nil {
 		return handleLSIFUploadError(nil, err)
 	}
+	if err := cfg.checkWritable("upload LSIF data"); err != nil {
+		return err
+	}
+
+	if lsifUploadFlags.watch != "" {
+		return handleLSIFWatchUpload(out)
+	}
+	if codeintel.IsRootPattern(lsifUploadFlags.root) {
+		return handleLSIFMultiUpload(out)
+	}
 
 	client := api.NewClient(api.ClientOpts{
 		Out:   io.Discard,
 		Flags: lsifUploadFlags.apiFlags,
 	})
 
-	uploadID, err := upload.UploadIndex(lsifUploadFlags.file, client, lsifUploadOptions(out))
+	opts := lsifUploadOptions(out)
+	uploadID, err := upload.UploadIndex(lsifUploadFlags.file, client, opts)
 	if err != nil {
+		queueOut := out
+		if queueOut == nil && !lsifUploadFlags.json {
+			queueOut = emergencyOutput()
+		}
+		if queued, queueErr := queueLSIFUpload(queueOut, lsifUploadFlags.file, opts.UploadRecordOptions, err); queued {
+			return queueErr
+		}
 		return handleLSIFUploadError(out, err)
 	}
 
@@ -115,19 +151,17 @@ func handleLSIFUpload(args []string) error {
 }
 
 // lsifUploadOutput returns an output object that should be used to print the progres
-// of requests made during this upload. If -json, -no-progress, or -trace>0 is given,
-// then no output object is defined.
+// of requests made during this upload. If -json, -no-progress, -trace>0, or -plain
+// is given, then no output object is defined.
 //
-// For -no-progress and -trace>0 conditions, emergency loggers will be used to display
+// For -no-progress, -trace>0, and -plain conditions, emergency loggers will be used to display
 // inferred arguments and the URL at which processing status is shown.
 func lsifUploadOutput() (out *output.Output) {
-	if lsifUploadFlags.json || lsifUploadFlags.noProgress || lsifUploadFlags.verbosity > 0 {
+	if lsifUploadFlags.json || lsifUploadFlags.noProgress || lsifUploadFlags.verbosity > 0 || plainOutput() {
 		return nil
 	}
 
-	return output.NewOutput(flag.CommandLine.Output(), output.OutputOpts{
-		Verbose: true,
-	})
+	return newOutput(flag.CommandLine.Output(), true)
 }
 
 // lsifUploadOptions creates a set of upload options given the values in the flags.
@@ -236,5 +270,5 @@ func handleLSIFUploadError(out *output.Output, err error) error {
 
 // emergencyOutput creates a default Output object writing to standard out.
 func emergencyOutput() *output.Output {
-	return output.NewOutput(os.Stdout, output.OutputOpts{})
+	return newOutput(os.Stdout, false)
 }
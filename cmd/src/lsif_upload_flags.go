@@ -4,7 +4,9 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/cockroachdb/errors"
 
@@ -23,6 +25,13 @@ var lsifUploadFlags struct {
 	root              string
 	indexer           string
 	associatedIndexID int
+	parallelism       int
+	commitFromEnv     string
+
+	// Watch mode
+	watch       string
+	idleTimeout time.Duration
+	checkpoint  string
 
 	// SourcegraphInstanceOptions
 	uploadRoute      string
@@ -31,6 +40,7 @@ var lsifUploadFlags struct {
 
 	// Output and error behavior
 	ignoreUploadFailures bool
+	queueDir             string
 	noProgress           bool
 	verbosity            int
 	json                 bool
@@ -52,10 +62,17 @@ func init() {
 	// UploadRecordOptions
 	lsifUploadFlagSet.StringVar(&lsifUploadFlags.repo, "repo", "", `The name of the repository (e.g. github.com/gorilla/mux). By default, derived from the origin remote.`)
 	lsifUploadFlagSet.StringVar(&lsifUploadFlags.commit, "commit", "", `The 40-character hash of the commit. Defaults to the currently checked-out commit.`)
-	lsifUploadFlagSet.StringVar(&lsifUploadFlags.root, "root", "", `The path in the repository that matches the LSIF projectRoot (e.g. cmd/project1). Defaults to the directory where the dump file is located.`)
+	lsifUploadFlagSet.StringVar(&lsifUploadFlags.commitFromEnv, "commit-from-env", "", fmt.Sprintf(`Read the commit, and the repository unless -repo is given, from the environment variables of a CI provider instead of the git clone. One of auto, %s.`, strings.Join(codeintel.CIProviderNames(), ", ")))
+	lsifUploadFlagSet.StringVar(&lsifUploadFlags.root, "root", "", `The path in the repository that matches the LSIF projectRoot (e.g. cmd/project1). Defaults to the directory where the dump file is located. If this is a glob pattern (e.g. 'cmd/*' or '**'), all dump files with the name given by -file in matching directories are uploaded, each with its directory as root.`)
 	lsifUploadFlagSet.StringVar(&lsifUploadFlags.indexer, "indexer", "", `The name of the indexer that generated the dump. This will override the 'toolInfo.name' field in the metadata vertex of the LSIF dump file. This must be supplied if the indexer does not set this field (in which case the upload will fail with an explicit message).`)
+	lsifUploadFlagSet.IntVar(&lsifUploadFlags.parallelism, "j", 4, `The maximum number of concurrent uploads when -root is a glob pattern.`)
 	lsifUploadFlagSet.IntVar(&lsifUploadFlags.associatedIndexID, "associated-index-id", -1, "ID of the associated index record for this upload. For internal use only.")
 
+	// Watch mode
+	lsifUploadFlagSet.StringVar(&lsifUploadFlags.watch, "watch", "", `Watch the given directory for index files and upload each one as soon as it's completely written, until no new index files appear for -idle-timeout. Index files are the files whose name matches the base name of -file, which can be a glob pattern and defaults to '*.lsif' in this mode. The root of each index is its directory relative to the watched directory, below -root.`)
+	lsifUploadFlagSet.DurationVar(&lsifUploadFlags.idleTimeout, "idle-timeout", 5*time.Minute, `With -watch, how long to wait for new index files before exiting.`)
+	lsifUploadFlagSet.StringVar(&lsifUploadFlags.checkpoint, "checkpoint", "", `With -watch, the file that records the uploaded index files, so that index files with the same content are only uploaded once for a commit, even if the command is restarted. Defaults to .src-lsif-checkpoint.json in the watched directory.`)
+
 	// SourcegraphInstanceOptions
 	lsifUploadFlagSet.StringVar(&lsifUploadFlags.uploadRoute, "upload-route", "/.api/lsif/upload", "The path of the upload route. For internal use only.")
 	lsifUploadFlagSet.StringVar(&lsifUploadFlags.gitHubToken, "github-token", "", `A GitHub access token with 'public_repo' scope that Sourcegraph uses to verify you have access to the repository.`)
@@ -63,6 +80,7 @@ func init() {
 
 	// Output and error behavior
 	lsifUploadFlagSet.BoolVar(&lsifUploadFlags.ignoreUploadFailures, "ignore-upload-failure", false, `Exit with status code zero on upload failure.`)
+	lsifUploadFlagSet.StringVar(&lsifUploadFlags.queueDir, "queue-dir", "", `If set, failed uploads are stored in this directory instead of failing the command, so that they can be retried later with 'src lsif flush-queue'.`)
 	lsifUploadFlagSet.BoolVar(&lsifUploadFlags.noProgress, "no-progress", false, `Do not display progress updates.`)
 	lsifUploadFlagSet.IntVar(&lsifUploadFlags.verbosity, "trace", 0, "-trace=0 shows no logs; -trace=1 shows requests and response metadata; -trace=2 shows headers, -trace=3 shows response body")
 	lsifUploadFlagSet.BoolVar(&lsifUploadFlags.json, "json", false, `Output relevant state in JSON on success.`)
@@ -127,16 +145,23 @@ type argumentInferenceError struct {
 //
 // Note: This function must not be called before lsifUploadFlagSet.Parse.
 func inferMissingLSIFUploadFlags() (inferErrors []argumentInferenceError) {
-	if _, err := os.Stat(lsifUploadFlags.file); os.IsNotExist(err) {
+	// With a root pattern, the file, root, and indexer are determined for each
+	// discovered index file separately.
+	discover := codeintel.IsRootPattern(lsifUploadFlags.root) || lsifUploadFlags.watch != ""
+
+	if _, err := os.Stat(lsifUploadFlags.file); os.IsNotExist(err) && !discover {
 		inferErrors = append(inferErrors, argumentInferenceError{"file", err})
 	}
 
-	if err := inferUnsetFlag("repo", &lsifUploadFlags.repo, codeintel.InferRepo); err != nil {
+	if err := inferUnsetFlag("repo", &lsifUploadFlags.repo, inferRepo); err != nil {
 		inferErrors = append(inferErrors, *err)
 	}
-	if err := inferUnsetFlag("commit", &lsifUploadFlags.commit, codeintel.InferCommit); err != nil {
+	if err := inferUnsetFlag("commit", &lsifUploadFlags.commit, inferCommit); err != nil {
 		inferErrors = append(inferErrors, *err)
 	}
+	if discover {
+		return inferErrors
+	}
 	if err := inferUnsetFlag("root", &lsifUploadFlags.root, inferIndexRoot); err != nil {
 		inferErrors = append(inferErrors, *err)
 	}
@@ -180,6 +205,31 @@ func isFlagSet(fs *flag.FlagSet, name string) (found bool) {
 	return found
 }
 
+// inferRepo returns the repository from the environment of the CI provider given
+// by -commit-from-env, if it's set and the provider exposes the repository, and
+// from the git clone otherwise.
+//
+// Note: This function must not be called before lsifUploadFlagSet.Parse.
+func inferRepo() (string, error) {
+	if lsifUploadFlags.commitFromEnv != "" {
+		if repo, err := codeintel.RepoFromEnv(lsifUploadFlags.commitFromEnv, os.Getenv); err == nil {
+			return repo, nil
+		}
+	}
+	return codeintel.InferRepo()
+}
+
+// inferCommit returns the commit from the environment of the CI provider given
+// by -commit-from-env, if it's set, and from the git clone otherwise.
+//
+// Note: This function must not be called before lsifUploadFlagSet.Parse.
+func inferCommit() (string, error) {
+	if lsifUploadFlags.commitFromEnv != "" {
+		return codeintel.CommitFromEnv(lsifUploadFlags.commitFromEnv, os.Getenv)
+	}
+	return codeintel.InferCommit()
+}
+
 // inferIndexRoot returns the root directory based on the configured index file path.
 //
 // Note: This function must not be called before lsifUploadFlagSet.Parse.
@@ -204,12 +254,33 @@ func readIndexerName() (string, error) {
 //
 // Note: This function must not be called before lsifUploadFlagSet.Parse.
 func validateLSIFUploadFlags() error {
-	lsifUploadFlags.root = codeintel.SanitizeRoot(lsifUploadFlags.root)
+	if !codeintel.IsRootPattern(lsifUploadFlags.root) {
+		lsifUploadFlags.root = codeintel.SanitizeRoot(lsifUploadFlags.root)
+	}
 
 	if strings.HasPrefix(lsifUploadFlags.root, "..") {
 		return errors.New("root must not be outside of repository")
 	}
 
+	if lsifUploadFlags.watch != "" {
+		if codeintel.IsRootPattern(lsifUploadFlags.root) {
+			return errors.New("root must not be a glob pattern with -watch")
+		}
+		if lsifUploadFlags.idleTimeout <= 0 {
+			return errors.New("idle-timeout must be positive")
+		}
+		if !isFlagSet(lsifUploadFlagSet, "file") {
+			lsifUploadFlags.file = "*.lsif"
+		}
+		if lsifUploadFlags.checkpoint == "" {
+			lsifUploadFlags.checkpoint = filepath.Join(lsifUploadFlags.watch, ".src-lsif-checkpoint.json")
+		}
+	}
+
+	if lsifUploadFlags.parallelism < 1 {
+		return errors.New("j must be at least 1")
+	}
+
 	if lsifUploadFlags.maxPayloadSizeMb < 25 {
 		return errors.New("max-payload-size must be at least 25 (MB)")
 	}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/sourcegraph/lib/codeintel/upload"
+	"github.com/sourcegraph/sourcegraph/lib/output"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/codeintel"
+)
+
+// lsifMultiUploadResult is the outcome of uploading one of several indexes
+// found in discovery mode.
+type lsifMultiUploadResult struct {
+	Root      string `json:"root"`
+	File      string `json:"file"`
+	Indexer   string `json:"indexer"`
+	UploadID  int    `json:"uploadId,omitempty"`
+	UploadURL string `json:"uploadUrl,omitempty"`
+	State     string `json:"state,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// handleLSIFMultiUpload uploads all index files in the repository whose
+// directory matches the -root pattern, each with its directory as root.
+func handleLSIFMultiUpload(out *output.Output) error {
+	topLevel, err := codeintel.InferTopLevel()
+	if err != nil {
+		return handleLSIFUploadError(out, err)
+	}
+
+	indexes, err := codeintel.DiscoverIndexes(topLevel, filepath.Base(lsifUploadFlags.file), lsifUploadFlags.root)
+	if err != nil {
+		return handleLSIFUploadError(out, errors.Wrap(err, "discovering index files"))
+	}
+	if len(indexes) == 0 {
+		return handleLSIFUploadError(out, errors.Newf("no %s files found in directories matching %q", filepath.Base(lsifUploadFlags.file), lsifUploadFlags.root))
+	}
+
+	client := api.NewClient(api.ClientOpts{
+		Out:   io.Discard,
+		Flags: lsifUploadFlags.apiFlags,
+	})
+
+	results := make([]lsifMultiUploadResult, len(indexes))
+	sem := make(chan struct{}, lsifUploadFlags.parallelism)
+	var wg sync.WaitGroup
+	for i, index := range indexes {
+		wg.Add(1)
+		go func(i int, index codeintel.Index) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i] = uploadDiscoveredIndex(client, index)
+		}(i, index)
+	}
+	wg.Wait()
+
+	// Look up the processing state of the uploads. This is best effort: the
+	// uploads succeeded even if the state can't be determined.
+	apiClient := cfg.apiClient(lsifUploadFlags.apiFlags, io.Discard)
+	failed := 0
+	for i := range results {
+		if results[i].Error != "" {
+			failed++
+			continue
+		}
+		if results[i].UploadID == 0 {
+			continue
+		}
+		if state, err := lsifUploadState(apiClient, results[i].UploadID); err == nil {
+			results[i].State = state
+		}
+	}
+
+	if lsifUploadFlags.json {
+		serialized, err := json.Marshal(results)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(serialized))
+	} else {
+		printLSIFMultiUploadResults(results)
+	}
+
+	if failed > 0 {
+		return handleLSIFUploadError(out, errors.Newf("%d of %d uploads failed", failed, len(results)))
+	}
+	return nil
+}
+
+func uploadDiscoveredIndex(client api.Client, index codeintel.Index) lsifMultiUploadResult {
+	result := lsifMultiUploadResult{
+		Root:    index.Root,
+		File:    index.File,
+		Indexer: lsifUploadFlags.indexer,
+	}
+
+	if result.Indexer == "" {
+		f, err := os.Open(index.File)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		result.Indexer, err = upload.ReadIndexerName(f)
+		f.Close()
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+	}
+
+	// Progress bars of concurrent uploads would overwrite each other, so the
+	// results are only printed once all uploads are done.
+	opts := lsifUploadOptions(nil)
+	opts.UploadRecordOptions.Root = index.Root
+	opts.UploadRecordOptions.Indexer = result.Indexer
+
+	uploadID, err := upload.UploadIndex(index.File, client, opts)
+	if err != nil {
+		if queued, queueErr := queueLSIFUpload(nil, index.File, opts.UploadRecordOptions, err); queued && queueErr == nil {
+			result.State = "QUEUED LOCALLY"
+			return result
+		}
+		if err == upload.ErrUnauthorized {
+			err = errorWithHint{err: err, hint: errUnauthorizedHint}
+		}
+		result.Error = err.Error()
+		return result
+	}
+
+	result.UploadID = uploadID
+	result.UploadURL, _ = makeLSIFUploadURL(uploadID)
+	return result
+}
+
+const lsifUploadStateQuery = `query LSIFUploadState($id: ID!) {
+	node(id: $id) {
+		... on LSIFUpload {
+			state
+		}
+	}
+}`
+
+// lsifUploadState returns the processing state of the upload with the given
+// internal identifier.
+func lsifUploadState(client api.Client, uploadID int) (string, error) {
+	var result struct {
+		Node struct {
+			State string
+		}
+	}
+	id := base64.URLEncoding.EncodeToString([]byte(fmt.Sprintf(`LSIFUpload:"%d"`, uploadID)))
+	if ok, err := client.NewRequest(lsifUploadStateQuery, map[string]interface{}{"id": id}).Do(context.Background(), &result); err != nil || !ok {
+		return "", err
+	}
+	return result.Node.State, nil
+}
+
+// printLSIFMultiUploadResults prints a table with a row for each upload.
+func printLSIFMultiUploadResults(results []lsifMultiUploadResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ROOT\tINDEXER\tUPLOAD ID\tSTATE\tURL")
+	for _, r := range results {
+		root := r.Root
+		if root == "" {
+			root = "."
+		}
+		if r.Error != "" {
+			fmt.Fprintf(w, "%s\t%s\t-\tFAILED\t%s\n", root, r.Indexer, r.Error)
+			continue
+		}
+		if r.UploadID == 0 {
+			fmt.Fprintf(w, "%s\t%s\t-\t%s\t\n", root, r.Indexer, r.State)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", root, r.Indexer, r.UploadID, r.State, r.UploadURL)
+	}
+	w.Flush()
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/sourcegraph/lib/output"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/codeintel"
+)
+
+// lsifWatchPollInterval is how often the directory given by -watch is checked
+// for new index files.
+var lsifWatchPollInterval = 2 * time.Second
+
+// handleLSIFWatchUpload uploads the index files that appear in the directory
+// given by -watch, each as soon as it's completely written, until no new
+// index files appear for -idle-timeout.
+func handleLSIFWatchUpload(out *output.Output) error {
+	watcher, err := codeintel.NewWatcher(lsifUploadFlags.watch, filepath.Base(lsifUploadFlags.file))
+	if err != nil {
+		return handleLSIFUploadError(out, err)
+	}
+	checkpoint, err := codeintel.OpenWatchCheckpoint(lsifUploadFlags.checkpoint)
+	if err != nil {
+		return handleLSIFUploadError(out, err)
+	}
+
+	ctx, cancel := contextCancelOnInterrupt(context.Background())
+	defer cancel()
+
+	client := api.NewClient(api.ClientOpts{
+		Out:   io.Discard,
+		Flags: lsifUploadFlags.apiFlags,
+	})
+
+	if out == nil && !lsifUploadFlags.json {
+		out = emergencyOutput()
+	}
+	if out != nil {
+		out.WriteLine(output.Linef(output.EmojiLightbulb, output.StyleItalic, "Watching %s for %s files", lsifUploadFlags.watch, filepath.Base(lsifUploadFlags.file)))
+	}
+
+	var (
+		seen, uploaded, failed int
+		lastActivity           = time.Now()
+	)
+	for ctx.Err() == nil && time.Since(lastActivity) < lsifUploadFlags.idleTimeout {
+		ready, active, err := watcher.Poll()
+		if err != nil {
+			return handleLSIFUploadError(out, errors.Wrap(err, "watching for index files"))
+		}
+		if active || len(ready) > 0 {
+			lastActivity = time.Now()
+		}
+
+		for _, index := range ready {
+			if ctx.Err() != nil {
+				break
+			}
+			seen++
+
+			root := path.Join(lsifUploadFlags.root, index.Dir)
+			if root == "." {
+				root = ""
+			}
+			key := codeintel.WatchCheckpointKey(lsifUploadFlags.repo, lsifUploadFlags.commit, index.SHA256)
+			if prev, ok := checkpoint.Get(key); ok {
+				if out != nil {
+					out.Writef("Skipping %s: the same index was already uploaded from %s", index.File, prev.File)
+				}
+				continue
+			}
+
+			result := uploadDiscoveredIndex(client, codeintel.Index{File: index.File, Root: root})
+			if result.Error != "" {
+				failed++
+			} else {
+				uploaded++
+				if err := checkpoint.Add(key, codeintel.CheckpointedUpload{
+					File:       index.File,
+					Root:       result.Root,
+					Indexer:    result.Indexer,
+					UploadID:   result.UploadID,
+					State:      result.State,
+					UploadedAt: time.Now().UTC(),
+				}); err != nil {
+					return handleLSIFUploadError(out, errors.Wrap(err, "writing checkpoint"))
+				}
+			}
+			if err := printLSIFWatchUploadResult(out, result); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(lsifWatchPollInterval):
+		}
+	}
+
+	if out != nil {
+		out.Writef("Uploaded %d index files, %d failed, %d already uploaded.", uploaded, failed, seen-uploaded-failed)
+	}
+	if failed > 0 {
+		return handleLSIFUploadError(out, errors.Newf("%d of %d uploads failed", failed, uploaded+failed))
+	}
+	if seen == 0 && ctx.Err() == nil {
+		return handleLSIFUploadError(out, errors.Newf("no %s files appeared in %s within %s", filepath.Base(lsifUploadFlags.file), lsifUploadFlags.watch, lsifUploadFlags.idleTimeout))
+	}
+	return nil
+}
+
+// printLSIFWatchUploadResult prints the result of an upload in watch mode as
+// soon as it's done, as a line of JSON with -json.
+func printLSIFWatchUploadResult(out *output.Output, result lsifMultiUploadResult) error {
+	if lsifUploadFlags.json {
+		serialized, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(serialized))
+		return nil
+	}
+
+	root := result.Root
+	if root == "" {
+		root = "."
+	}
+	switch {
+	case result.Error != "":
+		out.WriteLine(output.Linef(output.EmojiFailure, output.StyleWarning, "Failed to upload %s (root %s): %s", result.File, root, result.Error))
+	case result.UploadID == 0:
+		out.WriteLine(output.Linef(output.EmojiWarning, output.StyleWarning, "Failed to upload %s (root %s), queued it to be retried later", result.File, root))
+	default:
+		out.WriteLine(output.Linef(output.EmojiSuccess, output.StyleSuccess, "Uploaded %s (root %s, %s): %s", result.File, root, result.Indexer, result.UploadURL))
+	}
+	return nil
+}
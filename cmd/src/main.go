@@ -29,28 +29,69 @@ Environment variables
 The options are:
 
 	-v                               print verbose output
+	-plain                           print plain, line-oriented output without colors, spinners or progress bars
+	-error-json                      print errors as JSON lines to stderr, see "Exit codes" below
+	-read-only                       refuse to change Sourcegraph or code hosts, see "Read-only mode" below
 
 The commands are:
 
 	search          search for results on Sourcegraph
 	api             interacts with the Sourcegraph GraphQL API
 	repos,repo      manages repositories
+	gitserver       reclones repositories and checks gitserver disk usage and corruption
 	users,user      manages users
 	orgs,org        manages organizations
+	tokens,token    mints short-lived access tokens with narrow scopes
+	permissions     debugs repository permissions
+	contexts        manages search contexts
+	cody            manages Cody context indexing
+	insights        manages code insights
 	config          manages global, org, and user settings
+	admin           administers the site configuration and inspects outbound requests
 	extsvc          manages external services
 	extensions,ext  manages extensions (experimental)
 	batch           manages batch changes
 	lsif            manages LSIF data
 	serve-git       serves your local git repositories over HTTP for Sourcegraph to pull
+	debug           gathers information about a Sourcegraph deployment for troubleshooting
+	sbom            verifies the signatures and SBOMs of the images of a Sourcegraph deployment
+	scout           recommends resource changes for a Sourcegraph deployment
+	build-info      display how src was built and which release artifact matches this platform
 	version         display and compare the src-cli version against the recommended version for your instance
 
+Exit codes:
+
+	1    failure
+	2    usage error, or errors returned by the GraphQL API
+	3    missing or rejected access token
+	4    invalid input, such as a batch spec that fails validation
+	5    failure executing batch spec steps
+	6    partial failure, such as changeset specs of which some failed to upload
+	7    Sourcegraph could not be reached
+	8    the Sourcegraph instance does not support the request
+	9    nothing was done because the changes were not confirmed
+	130  interrupted
+
+With -error-json, errors are written to stderr as JSON objects with the
+fields "kind", "exitCode", "message" and, for multiple errors, "errors".
+
+Read-only mode:
+
+	With -read-only, or "readOnly": true in the config file, commands fail
+	with exit code 2 before they run a GraphQL mutation, send a request
+	other than GET to the Sourcegraph API, or upload LSIF data, so that
+	production credentials can be used to explore safely. Querying and
+	-get-curl still work.
+
 Use "src [command] -h" for more information about a command.
 
 `
 
 var (
-	verbose = flag.Bool("v", false, "print verbose output")
+	verbose   = flag.Bool("v", false, "print verbose output")
+	plain     = flag.Bool("plain", false, "print plain, line-oriented output without colors, spinners or progress bars")
+	errorJSON = flag.Bool("error-json", false, `print errors as JSON lines to stderr, see "Exit codes" in the usage`)
+	readOnly  = flag.Bool("read-only", false, `refuse to run GraphQL mutations and other requests that change Sourcegraph or code hosts, see "Read-only mode" in the usage`)
 
 	// The following arguments are deprecated which is why they are no longer documented
 	configPath = flag.String("config", "", "")
@@ -75,6 +116,9 @@ type config struct {
 	Endpoint          string            `json:"endpoint"`
 	AccessToken       string            `json:"accessToken"`
 	AdditionalHeaders map[string]string `json:"additionalHeaders"`
+	// ReadOnly is set by "readOnly" in the config file or the -read-only
+	// flag.
+	ReadOnly bool `json:"readOnly"`
 
 	ConfigFilePath string
 }
@@ -87,9 +131,20 @@ func (c *config) apiClient(flags *api.Flags, out io.Writer) api.Client {
 		AdditionalHeaders: c.AdditionalHeaders,
 		Flags:             flags,
 		Out:               out,
+		ReadOnly:          c.ReadOnly,
 	})
 }
 
+// checkWritable returns an api.ReadOnlyError for the operation if src is in
+// read-only mode. Commands call it before changes that don't go through the
+// API client, which refuses mutations by itself.
+func (c *config) checkWritable(operation string) error {
+	if c.ReadOnly {
+		return &api.ReadOnlyError{Operation: operation}
+	}
+	return nil
+}
+
 var testHomeDir string // used by tests to mock the user's $HOME
 
 // readConfig reads the config file from the given path.
@@ -152,6 +207,10 @@ func readConfig() (*config, error) {
 
 	cfg.AdditionalHeaders = parseAdditionalHeaders()
 
+	if readOnly != nil && *readOnly {
+		cfg.ReadOnly = true
+	}
+
 	// Lastly, apply endpoint flag if set
 	if endpoint != nil && *endpoint != "" {
 		cfg.Endpoint = *endpoint
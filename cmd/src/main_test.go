@@ -17,6 +17,7 @@ func TestReadConfig(t *testing.T) {
 		envFooHeader string
 		envEndpoint  string
 		flagEndpoint string
+		flagReadOnly bool
 		want         *config
 		wantErr      string
 	}{
@@ -139,6 +140,29 @@ func TestReadConfig(t *testing.T) {
 				AdditionalHeaders: map[string]string{"foo": "bar"},
 			},
 		},
+		{
+			name: "read-only config file",
+			fileContents: &config{
+				Endpoint:    "https://example.com/",
+				AccessToken: "deadbeef",
+				ReadOnly:    true,
+			},
+			want: &config{
+				Endpoint:          "https://example.com",
+				AccessToken:       "deadbeef",
+				AdditionalHeaders: map[string]string{},
+				ReadOnly:          true,
+			},
+		},
+		{
+			name:         "read-only flag",
+			flagReadOnly: true,
+			want: &config{
+				Endpoint:          "https://sourcegraph.com",
+				AdditionalHeaders: map[string]string{},
+				ReadOnly:          true,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -166,6 +190,11 @@ func TestReadConfig(t *testing.T) {
 				t.Cleanup(func() { endpoint = nil })
 			}
 
+			if test.flagReadOnly {
+				*readOnly = true
+				t.Cleanup(func() { *readOnly = false })
+			}
+
 			if test.fileContents != nil {
 				oldConfigPath := *configPath
 				t.Cleanup(func() { *configPath = oldConfigPath })
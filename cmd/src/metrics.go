@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/metrics"
+)
+
+// addMetricsAddrFlag adds the -metrics-addr flag of long-running commands to
+// flagSet.
+func addMetricsAddrFlag(flagSet *flag.FlagSet) *string {
+	return flagSet.String("metrics-addr", "", "If set, the address to serve Prometheus metrics on under /metrics, such as :9090.")
+}
+
+// serveMetrics serves the metrics of src on addr, unless it's empty, until the
+// returned function is called.
+func serveMetrics(addr string) (stop func(), err error) {
+	if addr == "" {
+		return func() {}, nil
+	}
+
+	srv, err := metrics.Default.Serve(addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "serving metrics")
+	}
+	return func() { srv.Close() }, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"os"
 
 	"github.com/sourcegraph/src-cli/internal/api"
 )
@@ -24,6 +25,10 @@ Examples:
 
     	$ src orgs list -query='myquery'
 
+  List organizations as a table, largest first:
+
+    	$ src orgs list -sort=-members
+
 `
 
 	flagSet := flag.NewFlagSet("list", flag.ExitOnError)
@@ -36,6 +41,7 @@ Examples:
 		firstFlag  = flagSet.Int("first", 1000, "Returns the first n organizations from the list. (use -1 for unlimited)")
 		queryFlag  = flagSet.String("query", "", `Returns organizations whose names match the query. (e.g. "alice")`)
 		formatFlag = flagSet.String("f", "{{.Name}}", `Format for the output, using the syntax of Go package text/template. (e.g. "{{.ID}}: {{.Name}} ({{.DisplayName}})" or "{{.|json}}")`)
+		tableFlags = newTableFlags(flagSet, orgColumns, "name", "display-name", "members")
 		apiFlags   = api.NewFlags(flagSet)
 	)
 
@@ -50,6 +56,10 @@ Examples:
 		if err != nil {
 			return err
 		}
+		tbl, err := tableFlags.table(flagSet)
+		if err != nil {
+			return err
+		}
 
 		query := `query Organizations(
   $first: Int,
@@ -78,10 +88,19 @@ Examples:
 		}
 
 		for _, org := range result.Organizations.Nodes {
+			if tbl != nil {
+				if err := tbl.add(org); err != nil {
+					return err
+				}
+				continue
+			}
 			if err := execTemplate(tmpl, org); err != nil {
 				return err
 			}
 		}
+		if tbl != nil {
+			return tbl.write(os.Stdout)
+		}
 		return nil
 	}
 
@@ -92,3 +111,11 @@ Examples:
 		usageFunc: usageFunc,
 	})
 }
+
+// orgColumns are the columns of the table output of src orgs list.
+var orgColumns = []tableColumn{
+	{name: "id", template: "{{.ID}}"},
+	{name: "name", template: "{{.Name}}"},
+	{name: "display-name", template: "{{.DisplayName}}"},
+	{name: "members", template: "{{len .Members.Nodes}}"},
+}
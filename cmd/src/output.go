@@ -0,0 +1,27 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/sourcegraph/sourcegraph/lib/output"
+
+	"github.com/sourcegraph/src-cli/internal/terminal"
+)
+
+// plainOutput returns true if output should be plain and line-oriented, either
+// because -plain is set or because src runs in CI, on a dumb terminal or with
+// a locale that isn't UTF-8.
+func plainOutput() bool {
+	return *plain || terminal.Plain(os.Getenv)
+}
+
+// newOutput returns an Output that writes to w. If output is plain, ANSI
+// escape codes are removed, and so are the characters that can't be displayed
+// if the locale isn't UTF-8.
+func newOutput(w io.Writer, verbose bool) *output.Output {
+	if plainOutput() {
+		w = terminal.NewPlainWriter(w, !terminal.UTF8(os.Getenv))
+	}
+	return output.NewOutput(w, output.OutputOpts{Verbose: verbose})
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+)
+
+var permissionsCommands commander
+
+func init() {
+	usage := `'src permissions' is a tool that debugs repository permissions on a Sourcegraph instance. It requires a site admin.
+
+Usage:
+
+	src permissions command [command options]
+
+The commands are:
+
+	check      reports whether a user can see a repository, and why
+	sync       schedules a permissions sync of a user or a repository
+
+Use "src permissions [command] -h" for more information about a command.
+`
+
+	flagSet := flag.NewFlagSet("permissions", flag.ExitOnError)
+	handler := func(args []string) error {
+		permissionsCommands.run(flagSet, "src permissions", usage, args)
+		return nil
+	}
+
+	// Register the command.
+	commands = append(commands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Println(usage)
+		},
+	})
+}
+
+// PermissionsInfo is the state of the permissions sync of a user or a
+// repository.
+type PermissionsInfo struct {
+	Permissions []string
+	SyncedAt    *time.Time
+	UpdatedAt   time.Time
+}
+
+const permissionsUserQuery = `query PermissionsUser($username: String!) {
+    user(username: $username) {
+        id
+        username
+        siteAdmin
+        externalAccounts(first: 100) {
+            nodes {
+                serviceType
+                serviceID
+                accountID
+            }
+        }
+        permissionsInfo {
+            permissions
+            syncedAt
+            updatedAt
+        }
+    }
+}`
+
+// PermissionsUser is a user and the state of its permissions.
+type PermissionsUser struct {
+	ID               string
+	Username         string
+	SiteAdmin        bool
+	ExternalAccounts struct {
+		Nodes []struct {
+			ServiceType string
+			ServiceID   string
+			AccountID   string
+		}
+	}
+	PermissionsInfo *PermissionsInfo
+}
+
+func getPermissionsUser(ctx context.Context, client api.Client, username string) (*PermissionsUser, error) {
+	var result struct {
+		User *PermissionsUser
+	}
+	if ok, err := client.NewRequest(permissionsUserQuery, map[string]interface{}{
+		"username": username,
+	}).Do(ctx, &result); err != nil || !ok {
+		return nil, err
+	}
+	if result.User == nil {
+		return nil, errors.Newf("user %q not found", username)
+	}
+	return result.User, nil
+}
+
+const permissionsRepositoryQuery = `query PermissionsRepository($name: String!) {
+    repository(name: $name) {
+        id
+        name
+        isPrivate
+        externalRepository {
+            serviceType
+            serviceID
+        }
+        permissionsInfo {
+            permissions
+            syncedAt
+            updatedAt
+        }
+    }
+}`
+
+// PermissionsRepository is a repository and the state of its permissions.
+type PermissionsRepository struct {
+	ID                 string
+	Name               string
+	IsPrivate          bool
+	ExternalRepository struct {
+		ServiceType string
+		ServiceID   string
+	}
+	PermissionsInfo *PermissionsInfo
+}
+
+func getPermissionsRepository(ctx context.Context, client api.Client, name string) (*PermissionsRepository, error) {
+	var result struct {
+		Repository *PermissionsRepository
+	}
+	if ok, err := client.NewRequest(permissionsRepositoryQuery, map[string]interface{}{
+		"name": name,
+	}).Do(ctx, &result); err != nil || !ok {
+		return nil, err
+	}
+	if result.Repository == nil {
+		return nil, errors.Newf("repository %q not found", name)
+	}
+	return result.Repository, nil
+}
+
+// formatSyncedAt formats the time of the last permissions sync.
+func formatSyncedAt(info *PermissionsInfo) string {
+	if info == nil || info.SyncedAt == nil {
+		return "never"
+	}
+	return fmt.Sprintf("%s (%s ago)", info.SyncedAt.Format(time.RFC3339), time.Since(*info.SyncedAt).Truncate(time.Second))
+}
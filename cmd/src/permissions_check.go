@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+Examples:
+
+  Check whether a user can see a repository, and why:
+
+    	$ src permissions check -user=alice -repo=github.com/org/private
+
+  If the permissions are outdated, sync them and check again:
+
+    	$ src permissions sync -user=alice -wait
+    	$ src permissions check -user=alice -repo=github.com/org/private
+
+`
+
+	flagSet := flag.NewFlagSet("check", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src permissions %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		userFlag = flagSet.String("user", "", "The username of the user. (required)")
+		repoFlag = flagSet.String("repo", "", "The name of the repository. (required)")
+		apiFlags = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if *userFlag == "" || *repoFlag == "" {
+			return cmderrors.Usage("both -user and -repo are required")
+		}
+
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+		return permissionsCheck(context.Background(), client, *userFlag, *repoFlag, os.Stdout)
+	}
+
+	// Register the command.
+	permissionsCommands = append(permissionsCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
+
+const authorizedUserRepositoriesQuery = `query AuthorizedUserRepositories($username: String!, $after: String) {
+    authorizedUserRepositories(username: $username, first: 1000, after: $after) {
+        nodes {
+            name
+        }
+        pageInfo {
+            hasNextPage
+            endCursor
+        }
+    }
+}`
+
+// userAuthorizedForRepository returns true if the synced permissions of the
+// user include the repository.
+func userAuthorizedForRepository(ctx context.Context, client api.Client, username, repo string) (bool, error) {
+	var after *string
+	for {
+		var result struct {
+			AuthorizedUserRepositories struct {
+				Nodes    []struct{ Name string }
+				PageInfo struct {
+					HasNextPage bool
+					EndCursor   *string
+				}
+			}
+		}
+		if ok, err := client.NewRequest(authorizedUserRepositoriesQuery, map[string]interface{}{
+			"username": username,
+			"after":    after,
+		}).Do(ctx, &result); err != nil || !ok {
+			return false, err
+		}
+
+		for _, node := range result.AuthorizedUserRepositories.Nodes {
+			if node.Name == repo {
+				return true, nil
+			}
+		}
+		if !result.AuthorizedUserRepositories.PageInfo.HasNextPage {
+			return false, nil
+		}
+		after = result.AuthorizedUserRepositories.PageInfo.EndCursor
+	}
+}
+
+func permissionsCheck(ctx context.Context, client api.Client, username, repoName string, out io.Writer) error {
+	user, err := getPermissionsUser(ctx, client, username)
+	if err != nil {
+		return err
+	}
+	repo, err := getPermissionsRepository(ctx, client, repoName)
+	if err != nil {
+		return err
+	}
+	authorized, err := userAuthorizedForRepository(ctx, client, user.Username, repo.Name)
+	if err != nil {
+		return err
+	}
+
+	visible, reasons := explainRepositoryAccess(user, repo, authorized)
+
+	codeHost := repo.ExternalRepository.ServiceType + " " + repo.ExternalRepository.ServiceID
+	visibility := "public"
+	if repo.IsPrivate {
+		visibility = "private"
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "   User:                           %s (site admin: %t)\n", user.Username, user.SiteAdmin)
+	fmt.Fprintf(out, "   Repository:                     %s (%s, on %s)\n", repo.Name, visibility, codeHost)
+	fmt.Fprintf(out, "   User permissions synced:        %s\n", formatSyncedAt(user.PermissionsInfo))
+	fmt.Fprintf(out, "   Repository permissions synced:  %s\n", formatSyncedAt(repo.PermissionsInfo))
+	var accounts []string
+	for _, account := range user.ExternalAccounts.Nodes {
+		accounts = append(accounts, fmt.Sprintf("%s %s (%s)", account.ServiceType, account.ServiceID, account.AccountID))
+	}
+	if len(accounts) == 0 {
+		accounts = []string{"none"}
+	}
+	fmt.Fprintf(out, "   Providers of the user:          %s\n", strings.Join(accounts, ", "))
+	fmt.Fprintln(out)
+
+	if visible {
+		fmt.Fprintf(out, "✔️  %s can see %s: %s.\n", user.Username, repo.Name, strings.Join(reasons, ", and "))
+		fmt.Fprintln(out)
+		return nil
+	}
+
+	fmt.Fprintf(out, "❌ %s can't see %s.\n", user.Username, repo.Name)
+	fmt.Fprintln(out)
+	for _, reason := range reasons {
+		fmt.Fprintf(out, "   - %s\n", reason)
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "🛠  To sync the permissions again, run 'src permissions sync -user=%s -wait' or 'src permissions sync -repo=%s -wait'.\n", user.Username, repo.Name)
+	fmt.Fprintln(out)
+	return cmderrors.ExitCode1
+}
+
+// explainRepositoryAccess returns whether the user can see the repository, and
+// the reasons why, or why not. authorized is whether the synced permissions of
+// the user include the repository.
+func explainRepositoryAccess(user *PermissionsUser, repo *PermissionsRepository, authorized bool) (visible bool, reasons []string) {
+	if !repo.IsPrivate {
+		reasons = append(reasons, "the repository is public")
+	}
+	if user.SiteAdmin {
+		reasons = append(reasons, "the user is a site admin")
+	}
+	if authorized {
+		reasons = append(reasons, fmt.Sprintf("the code host granted access in the permissions sync at %s", formatSyncedAt(user.PermissionsInfo)))
+	}
+	if len(reasons) > 0 {
+		return true, reasons
+	}
+
+	hasAccount := false
+	for _, account := range user.ExternalAccounts.Nodes {
+		if account.ServiceType == repo.ExternalRepository.ServiceType && account.ServiceID == repo.ExternalRepository.ServiceID {
+			hasAccount = true
+		}
+	}
+	if !hasAccount {
+		reasons = append(reasons, fmt.Sprintf("the user hasn't connected an account on %s %s, the code host of the repository, so its permissions don't apply to the user", repo.ExternalRepository.ServiceType, repo.ExternalRepository.ServiceID))
+	}
+	if user.PermissionsInfo == nil || user.PermissionsInfo.SyncedAt == nil {
+		reasons = append(reasons, "the permissions of the user have never been synced")
+	}
+	if repo.PermissionsInfo == nil || repo.PermissionsInfo.SyncedAt == nil {
+		reasons = append(reasons, "the permissions of the repository have never been synced")
+	}
+	if len(reasons) == 0 {
+		reasons = append(reasons, fmt.Sprintf("the code host didn't grant access in the last permissions sync of the user, at %s, or of the repository, at %s", formatSyncedAt(user.PermissionsInfo), formatSyncedAt(repo.PermissionsInfo)))
+	}
+	return false, reasons
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExplainRepositoryAccess(t *testing.T) {
+	syncedAt := time.Now()
+	synced := &PermissionsInfo{SyncedAt: &syncedAt}
+	githubAccount := struct {
+		ServiceType string
+		ServiceID   string
+		AccountID   string
+	}{ServiceType: "github", ServiceID: "https://github.com/", AccountID: "1"}
+	privateRepo := func(info *PermissionsInfo) *PermissionsRepository {
+		repo := &PermissionsRepository{Name: "github.com/org/private", IsPrivate: true, PermissionsInfo: info}
+		repo.ExternalRepository.ServiceType = "github"
+		repo.ExternalRepository.ServiceID = "https://github.com/"
+		return repo
+	}
+
+	for name, tc := range map[string]struct {
+		user        *PermissionsUser
+		repo        *PermissionsRepository
+		authorized  bool
+		wantVisible bool
+		wantReasons []string
+	}{
+		"public repository": {
+			user:        &PermissionsUser{Username: "alice"},
+			repo:        &PermissionsRepository{Name: "github.com/org/public"},
+			wantVisible: true,
+			wantReasons: []string{"the repository is public"},
+		},
+		"site admin": {
+			user:        &PermissionsUser{Username: "alice", SiteAdmin: true},
+			repo:        privateRepo(nil),
+			wantVisible: true,
+			wantReasons: []string{"the user is a site admin"},
+		},
+		"never synced": {
+			user:        &PermissionsUser{Username: "alice"},
+			repo:        privateRepo(nil),
+			wantVisible: false,
+			wantReasons: []string{
+				"the user hasn't connected an account on github https://github.com/, the code host of the repository, so its permissions don't apply to the user",
+				"the permissions of the user have never been synced",
+				"the permissions of the repository have never been synced",
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			visible, reasons := explainRepositoryAccess(tc.user, tc.repo, tc.authorized)
+			if visible != tc.wantVisible {
+				t.Errorf("wrong visibility: want %t, have %t", tc.wantVisible, visible)
+			}
+			if diff := cmp.Diff(tc.wantReasons, reasons); diff != "" {
+				t.Errorf("wrong reasons (-want +have):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("synced without access", func(t *testing.T) {
+		user := &PermissionsUser{Username: "alice", PermissionsInfo: synced}
+		user.ExternalAccounts.Nodes = append(user.ExternalAccounts.Nodes, githubAccount)
+		visible, reasons := explainRepositoryAccess(user, privateRepo(synced), false)
+		if visible || len(reasons) != 1 {
+			t.Fatalf("unexpected result: visible=%t reasons=%q", visible, reasons)
+		}
+
+		visible, _ = explainRepositoryAccess(user, privateRepo(synced), true)
+		if !visible {
+			t.Error("expected authorized user to see repository")
+		}
+	})
+}
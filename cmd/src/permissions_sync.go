@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+Examples:
+
+  Schedule a permissions sync of a user:
+
+    	$ src permissions sync -user=alice
+
+  Sync the permissions of a repository and wait until the sync is done:
+
+    	$ src permissions sync -repo=github.com/org/private -wait
+
+`
+
+	flagSet := flag.NewFlagSet("sync", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src permissions %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		userFlag    = flagSet.String("user", "", "The username of the user whose permissions to sync.")
+		repoFlag    = flagSet.String("repo", "", "The name of the repository whose permissions to sync.")
+		waitFlag    = flagSet.Bool("wait", false, "Wait until the sync is done.")
+		timeoutFlag = flagSet.Duration("timeout", 10*time.Minute, "How long to wait for the sync with -wait.")
+		apiFlags    = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if (*userFlag == "") == (*repoFlag == "") {
+			return cmderrors.Usage("exactly one of -user and -repo is required")
+		}
+
+		ctx := context.Background()
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+
+		var (
+			name     string
+			mutation string
+			vars     map[string]interface{}
+			// info returns the current state of the permissions.
+			info func() (*PermissionsInfo, error)
+		)
+		if *userFlag != "" {
+			user, err := getPermissionsUser(ctx, client, *userFlag)
+			if err != nil {
+				return err
+			}
+			name = user.Username
+			mutation = `mutation ScheduleUserPermissionsSync($id: ID!) {
+    scheduleUserPermissionsSync(user: $id) {
+        alwaysNil
+    }
+}`
+			vars = map[string]interface{}{"id": user.ID}
+			info = func() (*PermissionsInfo, error) {
+				user, err := getPermissionsUser(ctx, client, *userFlag)
+				if err != nil {
+					return nil, err
+				}
+				return user.PermissionsInfo, nil
+			}
+		} else {
+			repo, err := getPermissionsRepository(ctx, client, *repoFlag)
+			if err != nil {
+				return err
+			}
+			name = repo.Name
+			mutation = `mutation ScheduleRepositoryPermissionsSync($id: ID!) {
+    scheduleRepositoryPermissionsSync(repository: $id) {
+        alwaysNil
+    }
+}`
+			vars = map[string]interface{}{"id": repo.ID}
+			info = func() (*PermissionsInfo, error) {
+				repo, err := getPermissionsRepository(ctx, client, *repoFlag)
+				if err != nil {
+					return nil, err
+				}
+				return repo.PermissionsInfo, nil
+			}
+		}
+
+		before, err := info()
+		if err != nil {
+			return err
+		}
+
+		var result struct{}
+		if ok, err := client.NewRequest(mutation, vars).Do(ctx, &result); err != nil || !ok {
+			return err
+		}
+		fmt.Printf("Scheduled a permissions sync of %s.\n", name)
+		if !*waitFlag {
+			return nil
+		}
+
+		after, err := waitForPermissionsSync(ctx, before, info, *timeoutFlag)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Permissions of %s synced at %s.\n", name, formatSyncedAt(after))
+		return nil
+	}
+
+	// Register the command.
+	permissionsCommands = append(permissionsCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
+
+// permissionsSyncPollInterval is how often waitForPermissionsSync checks
+// whether the sync is done.
+var permissionsSyncPollInterval = 2 * time.Second
+
+// waitForPermissionsSync waits until the permissions were synced after the
+// state before, and returns the new state.
+func waitForPermissionsSync(ctx context.Context, before *PermissionsInfo, info func() (*PermissionsInfo, error), timeout time.Duration) (*PermissionsInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(permissionsSyncPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, errors.Newf("permissions weren't synced within %s, the sync may still be queued", timeout)
+		case <-ticker.C:
+		}
+
+		current, err := info()
+		if err != nil {
+			return nil, err
+		}
+		if current == nil || current.SyncedAt == nil {
+			continue
+		}
+		if before == nil || before.SyncedAt == nil || current.SyncedAt.After(*before.SyncedAt) {
+			return current, nil
+		}
+	}
+}
@@ -20,9 +20,12 @@ Usage:
 
 The commands are:
 
-	get        gets a repository
-	list       lists repositories
-	delete 	   deletes repositories
+	get               gets a repository
+	list              lists repositories
+	delete            deletes repositories
+	stats             reports statistics of the repositories
+	export-archive    downloads archives of the repositories matching a query
+	import-org        adds the repositories of a GitHub organization or GitLab group to its code host connection
 
 Use "src repos [command] -h" for more information about a command.
 `
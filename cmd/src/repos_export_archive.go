@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/archive"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src repos export-archive' downloads an archive of every repository matching a
+search query at the head of its default branch, for example to build a corpus
+for offline analysis.
+
+Archives are named after the repository and commit. Archives that already exist
+in the output directory are skipped, so an interrupted export can be resumed by
+running the same command again. A manifest.json listing the repositories,
+commits and archives is written to the output directory.
+
+Usage:
+
+    src repos export-archive -query QUERY -o DIR [command options]
+
+Examples:
+
+    $ src repos export-archive -query 'repo:^github\.com/sourcegraph/' -o corpus
+
+    $ src repos export-archive -query 'lang:go fork:no' -o corpus -format tar -j 8
+
+`
+
+	flagSet := flag.NewFlagSet("export-archive", flag.ExitOnError)
+
+	var (
+		queryFlag       = flagSet.String("query", "", "The search query that selects the repositories. type:repo is added to it.")
+		outputFlag      = flagSet.String("o", "", "The directory to write the archives to.")
+		formatFlag      = flagSet.String("format", "zip", "The archive format: zip or tar.")
+		parallelismFlag = flagSet.Int("j", 4, "The number of archives to download in parallel.")
+		apiFlags        = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+		if *queryFlag == "" {
+			return cmderrors.Usage("-query must be provided")
+		}
+		if *outputFlag == "" {
+			return cmderrors.Usage("-o must be provided")
+		}
+		if _, ok := archive.Formats[*formatFlag]; !ok {
+			return cmderrors.Usage("-format must be zip or tar")
+		}
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+
+		repos, err := searchArchiveRepos(ctx, client, *queryFlag)
+		if err != nil {
+			return err
+		}
+		if len(repos) == 0 {
+			return errors.New("no repositories match the query")
+		}
+
+		if err := os.MkdirAll(*outputFlag, 0755); err != nil {
+			return err
+		}
+
+		fmt.Printf("Exporting %d repositories to %s.\n", len(repos), *outputFlag)
+		done := 0
+		results := archive.DownloadAll(ctx, client, repos, *outputFlag, *formatFlag, *parallelismFlag, func(r archive.Result) {
+			done++
+			switch {
+			case r.Error != "":
+				fmt.Printf("[%d/%d] %s: %s\n", done, len(repos), r.Name, r.Error)
+			case r.Downloaded:
+				fmt.Printf("[%d/%d] %s: downloaded\n", done, len(repos), r.Name)
+			default:
+				fmt.Printf("[%d/%d] %s: already exported\n", done, len(repos), r.Name)
+			}
+		})
+
+		manifest, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(*outputFlag, "manifest.json"), manifest, 0644); err != nil {
+			return err
+		}
+
+		failed := 0
+		for _, r := range results {
+			if r.Error != "" {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return errors.Newf("%d of %d archives could not be downloaded; run the command again to retry them", failed, len(repos))
+		}
+		return nil
+	}
+
+	reposCommands = append(reposCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src repos %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+var archiveQueryCountRegex = regexp.MustCompile(`\bcount:(\d+|all)\b`)
+
+const archiveReposQuery = `
+query ArchiveRepositories($query: String!) {
+    search(query: $query, version: V2) {
+        results {
+            results {
+                __typename
+                ... on Repository {
+                    name
+                    defaultBranch {
+                        target {
+                            oid
+                        }
+                    }
+                }
+            }
+        }
+    }
+}
+`
+
+// searchArchiveRepos returns the repositories matching the search query at
+// the head of their default branch. Repositories without a default branch,
+// such as empty ones, are omitted.
+func searchArchiveRepos(ctx context.Context, client api.Client, query string) ([]archive.Repo, error) {
+	query += " type:repo"
+	if !archiveQueryCountRegex.MatchString(query) {
+		query += " count:999999"
+	}
+
+	var result struct {
+		Search struct {
+			Results struct {
+				Results []struct {
+					Typename      string `json:"__typename"`
+					Name          string
+					DefaultBranch *struct {
+						Target struct{ OID string }
+					}
+				}
+			}
+		}
+	}
+	if ok, err := client.NewRequest(archiveReposQuery, map[string]interface{}{
+		"query": query,
+	}).Do(ctx, &result); err != nil || !ok {
+		return nil, err
+	}
+
+	var repos []archive.Repo
+	for _, r := range result.Search.Results.Results {
+		if r.Typename != "Repository" || r.DefaultBranch == nil {
+			continue
+		}
+		repos = append(repos, archive.Repo{Name: r.Name, Commit: r.DefaultBranch.Target.OID})
+	}
+	return repos, nil
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sourcegraph/jsonx"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src repos import-org' adds the repositories of a GitHub organization or a
+GitLab group to the code host connection of the code host on the Sourcegraph
+instance, by adding them to the "repos" of a GitHub connection or the
+"projects" of a GitLab connection.
+
+The repositories are listed with the API of the code host, with the token given
+by -token, $GITHUB_TOKEN or $GITLAB_TOKEN, and can be filtered by their topics,
+visibility and name. Archived repositories and forks are only added with
+-archived and -forks. Repositories that are already in the connection are left
+as they are.
+
+The connection is the only GitHub or GitLab connection with the URL of the code
+host, or the one given by -extsvc-id. Use -dry-run to print the repositories
+that would be added without changing it.
+
+Usage:
+
+    src repos import-org -github-org ORG | -gitlab-group GROUP [command options]
+
+Examples:
+
+    $ src repos import-org -github-org acme -topic internal -dry-run
+
+    $ src repos import-org -github-org acme -url https://github.example.com -visibility private -match '^acme/svc-'
+
+    $ src repos import-org -gitlab-group acme/backend -extsvc-id 'RXh0ZXJuYWxTZXJ2aWNlOjQ='
+
+`
+
+	flagSet := flag.NewFlagSet("import-org", flag.ExitOnError)
+	var (
+		githubOrgFlag   = flagSet.String("github-org", "", "The GitHub organization to import the repositories of.")
+		gitlabGroupFlag = flagSet.String("gitlab-group", "", "The GitLab group to import the projects of, including its subgroups.")
+		urlFlag         = flagSet.String("url", "", "The URL of the code host. Default is https://github.com or https://gitlab.com.")
+		tokenFlag       = flagSet.String("token", "", "The token to list the repositories with. Default is $GITHUB_TOKEN or $GITLAB_TOKEN.")
+		topicFlags      stringSliceFlag
+		visibilityFlag  = flagSet.String("visibility", "all", "Only import repositories with this visibility: all, public, private or internal.")
+		matchFlag       = flagSet.String("match", "", "Only import repositories whose name, such as acme/repo, matches this regular expression.")
+		archivedFlag    = flagSet.Bool("archived", false, "Also import archived repositories.")
+		forksFlag       = flagSet.Bool("forks", false, "Also import forks.")
+		extsvcIDFlag    = flagSet.String("extsvc-id", "", "The ID of the code host connection to add the repositories to.")
+		dryRunFlag      = flagSet.Bool("dry-run", false, "Print the repositories that would be added without adding them.")
+		apiFlags        = api.NewFlags(flagSet)
+	)
+	flagSet.Var(&topicFlags, "topic", "Only import repositories with this topic. Can be given multiple times to require all of them.")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+		var kind, owner, tokenEnv, defaultURL string
+		switch {
+		case *githubOrgFlag != "" && *gitlabGroupFlag != "":
+			return cmderrors.Usage("only one of -github-org and -gitlab-group may be given")
+		case *githubOrgFlag != "":
+			kind, owner, tokenEnv, defaultURL = codeHostGitHub, *githubOrgFlag, "GITHUB_TOKEN", "https://github.com"
+		case *gitlabGroupFlag != "":
+			kind, owner, tokenEnv, defaultURL = codeHostGitLab, *gitlabGroupFlag, "GITLAB_TOKEN", "https://gitlab.com"
+		default:
+			return cmderrors.Usage("one of -github-org or -gitlab-group must be given")
+		}
+
+		filter := orgRepoFilter{
+			topics:     topicFlags,
+			visibility: *visibilityFlag,
+			archived:   *archivedFlag,
+			forks:      *forksFlag,
+		}
+		switch filter.visibility {
+		case "all", "public", "private", "internal":
+		default:
+			return cmderrors.Usagef("invalid -visibility %q: must be all, public, private or internal", filter.visibility)
+		}
+		if *matchFlag != "" {
+			var err error
+			if filter.match, err = regexp.Compile(*matchFlag); err != nil {
+				return cmderrors.Usagef("invalid -match: %s", err)
+			}
+		}
+
+		codeHostURL := *urlFlag
+		if codeHostURL == "" {
+			codeHostURL = defaultURL
+		}
+		token := *tokenFlag
+		if token == "" {
+			token = os.Getenv(tokenEnv)
+		}
+		if token == "" {
+			return cmderrors.Usagef("a token must be given with -token or $%s", tokenEnv)
+		}
+
+		ctx := context.Background()
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+
+		svc, err := importOrgExternalService(ctx, client, kind, codeHostURL, *extsvcIDFlag)
+		if err != nil {
+			return err
+		}
+
+		codeHost := &codeHostClient{
+			client: http.DefaultClient,
+			kind:   kind,
+			config: codeHostConfig{URL: codeHostURL, Token: token},
+		}
+		repos, err := codeHost.listOrgRepos(ctx, owner)
+		if err != nil {
+			return errors.Wrapf(err, "listing the repositories of %s", owner)
+		}
+		names := filter.apply(repos)
+		if len(names) == 0 {
+			fmt.Printf("No repositories of %s match the filters.\n", owner)
+			return nil
+		}
+
+		config, added, err := addConfigRepos(kind, svc.Config, names)
+		if err != nil {
+			return err
+		}
+		if len(added) == 0 {
+			fmt.Printf("All %d matching repositories of %s are already in %s.\n", len(names), owner, svc.DisplayName)
+			return nil
+		}
+
+		if *dryRunFlag {
+			fmt.Printf("Would add %d of %d matching repositories of %s to %s (%s):\n", len(added), len(names), owner, svc.DisplayName, svc.ID)
+		} else {
+			var result struct{}
+			if ok, err := client.NewRequest(externalServicesUpdateMutation, map[string]interface{}{
+				"input": map[string]interface{}{
+					"id":     svc.ID,
+					"config": config,
+				},
+			}).Do(ctx, &result); err != nil || !ok {
+				return err
+			}
+			fmt.Printf("Added %d of %d matching repositories of %s to %s (%s):\n", len(added), len(names), owner, svc.DisplayName, svc.ID)
+		}
+		for _, name := range added {
+			fmt.Println("  " + name)
+		}
+		return nil
+	}
+
+	// Register the command.
+	reposCommands = append(reposCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src repos %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+// importOrgExternalService returns the external service with the given ID, or
+// the only one of the kind of code host with the URL if the ID is empty.
+func importOrgExternalService(ctx context.Context, client api.Client, kind, codeHostURL, id string) (*externalService, error) {
+	extsvcKind := strings.ToUpper(kind)
+	if id != "" {
+		svc, err := lookupExternalService(ctx, client, id, "")
+		if err != nil {
+			return nil, err
+		}
+		if svc.Kind != extsvcKind {
+			return nil, cmderrors.Usagef("external service %s is a %s connection, not %s", id, svc.Kind, extsvcKind)
+		}
+		return svc, nil
+	}
+
+	var result struct {
+		ExternalServices struct {
+			Nodes []*externalService
+		}
+	}
+	if ok, err := client.NewRequest(externalServicesListQuery, map[string]interface{}{
+		"first": 99999,
+	}).Do(ctx, &result); err != nil || !ok {
+		return nil, err
+	}
+
+	var matches []*externalService
+	for _, svc := range result.ExternalServices.Nodes {
+		if svc.Kind != extsvcKind {
+			continue
+		}
+		var config codeHostConfig
+		if err := jsonxUnmarshal(svc.Config, &config); err != nil {
+			continue
+		}
+		if sameCodeHostURL(config.URL, codeHostURL) {
+			matches = append(matches, svc)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, errors.Newf("there is no %s connection for %s, add one first", extsvcKind, codeHostURL)
+	case 1:
+		return matches[0], nil
+	}
+	ids := make([]string, 0, len(matches))
+	for _, svc := range matches {
+		ids = append(ids, fmt.Sprintf("%s (%s)", svc.ID, svc.DisplayName))
+	}
+	return nil, cmderrors.Usagef("there is more than one %s connection for %s, select one with -extsvc-id: %s", extsvcKind, codeHostURL, strings.Join(ids, ", "))
+}
+
+// sameCodeHostURL returns whether the URLs are of the same code host.
+func sameCodeHostURL(a, b string) bool {
+	normalize := func(s string) string {
+		u, err := url.Parse(strings.TrimSpace(s))
+		if err != nil {
+			return s
+		}
+		return strings.ToLower(u.Host) + strings.TrimSuffix(u.Path, "/")
+	}
+	return normalize(a) == normalize(b)
+}
+
+// orgRepo is a repository of a GitHub organization or a GitLab group.
+type orgRepo struct {
+	codeHostRepo
+
+	Topics []string `json:"topics"`
+	// TagList are the topics of GitLab projects before GitLab 14.
+	TagList    []string `json:"tag_list"`
+	Visibility string   `json:"visibility"`
+	Private    bool     `json:"private"`
+	Archived   bool     `json:"archived"`
+	// Fork is whether a GitHub repository is a fork.
+	Fork bool `json:"fork"`
+	// ForkedFromProject is the project a GitLab project is a fork of.
+	ForkedFromProject json.RawMessage `json:"forked_from_project"`
+}
+
+func (r *orgRepo) visibility() string {
+	switch {
+	case r.Visibility != "":
+		return r.Visibility
+	case r.Private:
+		return "private"
+	}
+	return "public"
+}
+
+func (r *orgRepo) isFork() bool {
+	return r.Fork || (len(r.ForkedFromProject) > 0 && string(r.ForkedFromProject) != "null")
+}
+
+// listOrgRepos lists all repositories of the GitHub organization or the
+// GitLab group, including its subgroups.
+func (c *codeHostClient) listOrgRepos(ctx context.Context, owner string) ([]*orgRepo, error) {
+	var path string
+	switch c.kind {
+	case codeHostGitHub:
+		path = "/orgs/" + url.PathEscape(owner) + "/repos?type=all&per_page=100"
+	case codeHostGitLab:
+		path = "/groups/" + url.PathEscape(owner) + "/projects?include_subgroups=true&per_page=100"
+	default:
+		return nil, errors.Newf("listing the repositories of %s is not supported", c.kind)
+	}
+
+	var repos []*orgRepo
+	next := c.apiURL() + path
+	for next != "" {
+		var page []*orgRepo
+		header, err := c.getURL(ctx, next, &page)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, page...)
+		next = nextPageURL(header)
+	}
+	return repos, nil
+}
+
+var linkNextPattern = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// nextPageURL returns the URL of the next page in the Link header, which both
+// GitHub and GitLab paginate with, or "" if it's the last page.
+func nextPageURL(header http.Header) string {
+	for _, link := range header.Values("Link") {
+		if m := linkNextPattern.FindStringSubmatch(link); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// orgRepoFilter selects the repositories of an organization to import.
+type orgRepoFilter struct {
+	topics     []string
+	visibility string
+	match      *regexp.Regexp
+	archived   bool
+	forks      bool
+}
+
+// apply returns the sorted names of the repositories that match the filter.
+func (f orgRepoFilter) apply(repos []*orgRepo) []string {
+	var names []string
+	for _, r := range repos {
+		if (r.Archived && !f.archived) || (r.isFork() && !f.forks) {
+			continue
+		}
+		if f.visibility != "" && f.visibility != "all" && r.visibility() != f.visibility {
+			continue
+		}
+		name := r.name()
+		if f.match != nil && !f.match.MatchString(name) {
+			continue
+		}
+		if !hasTopics(append(append([]string(nil), r.Topics...), r.TagList...), f.topics) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func hasTopics(have, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			if strings.EqualFold(h, w) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// addConfigRepos adds the repositories to the "repos" of a GitHub connection
+// config, or the "projects" of a GitLab connection config, and returns the
+// config and the names of the repositories that weren't in it yet.
+func addConfigRepos(kind, input string, names []string) (string, []string, error) {
+	// Known issue: Comments are not retained in the existing array value.
+	var root map[string]interface{}
+	if err := jsonxUnmarshal(input, &root); err != nil {
+		return "", nil, err
+	}
+	if root == nil {
+		return "", nil, errors.New("existing JSONx external service configuration is invalid (not an object)")
+	}
+
+	property := "repos"
+	if kind == codeHostGitLab {
+		property = "projects"
+	}
+	var values []interface{}
+	existing := map[string]bool{}
+	if v, ok := root[property]; ok {
+		if values, ok = v.([]interface{}); !ok {
+			return "", nil, errors.Newf("existing JSONx external service configuration is invalid (%s is not an array)", property)
+		}
+		for _, v := range values {
+			switch v := v.(type) {
+			case string:
+				existing[strings.ToLower(v)] = true
+			case map[string]interface{}:
+				if name, ok := v["name"].(string); ok {
+					existing[strings.ToLower(name)] = true
+				}
+			}
+		}
+	}
+
+	var added []string
+	for _, name := range names {
+		if existing[strings.ToLower(name)] {
+			continue
+		}
+		existing[strings.ToLower(name)] = true
+		added = append(added, name)
+		if kind == codeHostGitLab {
+			values = append(values, map[string]interface{}{"name": name})
+		} else {
+			values = append(values, name)
+		}
+	}
+	if len(added) == 0 {
+		return input, nil, nil
+	}
+
+	edits, _, err := jsonx.ComputePropertyEdit(
+		input,
+		jsonx.PropertyPath(property),
+		values,
+		nil,
+		jsonx.FormatOptions{InsertSpaces: true, TabSize: 2},
+	)
+	if err != nil {
+		return "", nil, err
+	}
+	config, err := jsonx.ApplyEdits(input, edits...)
+	return config, added, err
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestListOrgRepos(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/orgs/acme/repos" {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Header().Set("Link", fmt.Sprintf(`<%s/api/v3/orgs/acme/repos?page=2>; rel="next", <%s/api/v3/orgs/acme/repos?page=2>; rel="last"`, ts.URL, ts.URL))
+			_, _ = w.Write([]byte(`[{"full_name": "acme/one", "topics": ["internal"]}]`))
+		case "2":
+			_, _ = w.Write([]byte(`[{"full_name": "acme/two", "private": true}]`))
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	c := &codeHostClient{client: ts.Client(), kind: codeHostGitHub, config: codeHostConfig{URL: ts.URL, Token: "secret"}}
+	repos, err := c.listOrgRepos(context.Background(), "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var filter orgRepoFilter
+	if diff := cmp.Diff([]string{"acme/one", "acme/two"}, filter.apply(repos)); diff != "" {
+		t.Errorf("wrong repos (-want +have):\n%s", diff)
+	}
+}
+
+func TestOrgRepoFilter(t *testing.T) {
+	repo := func(name string, f func(r *orgRepo)) *orgRepo {
+		r := &orgRepo{}
+		r.FullName = name
+		if f != nil {
+			f(r)
+		}
+		return r
+	}
+	repos := []*orgRepo{
+		repo("acme/api", func(r *orgRepo) { r.Topics = []string{"internal", "go"} }),
+		repo("acme/web", func(r *orgRepo) { r.Topics = []string{"Internal"}; r.Visibility = "private" }),
+		repo("acme/old", func(r *orgRepo) { r.Topics = []string{"internal"}; r.Archived = true }),
+		repo("acme/fork", func(r *orgRepo) { r.Topics = []string{"internal"}; r.Fork = true }),
+		repo("acme/docs", nil),
+	}
+
+	for name, tc := range map[string]struct {
+		filter orgRepoFilter
+		want   []string
+	}{
+		"all": {
+			filter: orgRepoFilter{visibility: "all"},
+			want:   []string{"acme/api", "acme/docs", "acme/web"},
+		},
+		"topic": {
+			filter: orgRepoFilter{topics: []string{"internal"}},
+			want:   []string{"acme/api", "acme/web"},
+		},
+		"topics": {
+			filter: orgRepoFilter{topics: []string{"internal", "go"}},
+			want:   []string{"acme/api"},
+		},
+		"visibility": {
+			filter: orgRepoFilter{visibility: "public"},
+			want:   []string{"acme/api", "acme/docs"},
+		},
+		"match": {
+			filter: orgRepoFilter{match: regexp.MustCompile(`/(api|old)$`)},
+			want:   []string{"acme/api"},
+		},
+		"archived and forks": {
+			filter: orgRepoFilter{topics: []string{"internal"}, archived: true, forks: true},
+			want:   []string{"acme/api", "acme/fork", "acme/old", "acme/web"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.filter.apply(repos)); diff != "" {
+				t.Errorf("wrong repos (-want +have):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAddConfigRepos(t *testing.T) {
+	t.Run("github", func(t *testing.T) {
+		input := `{
+  // The GitHub connection.
+  "url": "https://github.com",
+  "repos": ["acme/api"],
+}`
+		config, added, err := addConfigRepos(codeHostGitHub, input, []string{"acme/API", "acme/web"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"acme/web"}, added); diff != "" {
+			t.Errorf("wrong added repos (-want +have):\n%s", diff)
+		}
+		want := `{
+  // The GitHub connection.
+  "url": "https://github.com",
+  "repos": [
+    "acme/api",
+    "acme/web"
+  ],
+}`
+		if diff := cmp.Diff(want, config); diff != "" {
+			t.Errorf("wrong config (-want +have):\n%s", diff)
+		}
+	})
+
+	t.Run("gitlab", func(t *testing.T) {
+		input := `{"url": "https://gitlab.com", "projects": [{"id": 42}, {"name": "acme/api"}]}`
+		config, added, err := addConfigRepos(codeHostGitLab, input, []string{"acme/api", "acme/web"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"acme/web"}, added); diff != "" {
+			t.Errorf("wrong added repos (-want +have):\n%s", diff)
+		}
+		var have struct {
+			Projects []map[string]interface{}
+		}
+		if err := jsonxUnmarshal(config, &have); err != nil {
+			t.Fatal(err)
+		}
+		want := []map[string]interface{}{{"id": float64(42)}, {"name": "acme/api"}, {"name": "acme/web"}}
+		if diff := cmp.Diff(want, have.Projects); diff != "" {
+			t.Errorf("wrong projects (-want +have):\n%s", diff)
+		}
+	})
+
+	t.Run("nothing to add", func(t *testing.T) {
+		input := `{"url": "https://github.com", "repos": ["acme/api"]}`
+		config, added, err := addConfigRepos(codeHostGitHub, input, []string{"acme/api"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(added) != 0 || config != input {
+			t.Errorf("unexpected change: added %v, config %s", added, config)
+		}
+	})
+}
+
+func TestSameCodeHostURL(t *testing.T) {
+	if !sameCodeHostURL("https://GitHub.com/", "https://github.com") {
+		t.Error("expected URLs to be of the same code host")
+	}
+	if sameCodeHostURL("https://github.com", "https://github.example.com") {
+		t.Error("expected URLs to be of different code hosts")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/sourcegraph/src-cli/internal/api"
@@ -29,6 +30,10 @@ Examples:
 
     	$ src repos list -query='myquery'
 
+  List repositories as a table of their names and languages, sorted by language:
+
+    	$ src repos list -columns=name,language -sort=language
+
 `
 
 	flagSet := flag.NewFlagSet("list", flag.ExitOnError)
@@ -49,6 +54,7 @@ Examples:
 		descendingFlag       = flagSet.Bool("descending", false, "Whether or not results should be in descending order.")
 		namesWithoutHostFlag = flagSet.Bool("names-without-host", false, "Whether or not repository names should be printed without the hostname (or other first path component). If set, -f is ignored.")
 		formatFlag           = flagSet.String("f", "{{.Name}}", `Format for the output, using the syntax of Go package text/template. (e.g. "{{.ID}}: {{.Name}}") or "{{.|json}}")`)
+		tableFlags           = newTableFlags(flagSet, repositoryColumns, "name", "default-branch", "created-at")
 		apiFlags             = api.NewFlags(flagSet)
 	)
 
@@ -63,6 +69,10 @@ Examples:
 		if err != nil {
 			return err
 		}
+		tbl, err := tableFlags.table(flagSet)
+		if err != nil {
+			return err
+		}
 
 		query := `query Repositories(
   $first: Int,
@@ -120,6 +130,12 @@ Examples:
 		}
 
 		for _, repo := range result.Repositories.Nodes {
+			if tbl != nil {
+				if err := tbl.add(repo); err != nil {
+					return err
+				}
+				continue
+			}
 			if *namesWithoutHostFlag {
 				firstSlash := strings.Index(repo.Name, "/")
 				fmt.Println(repo.Name[firstSlash+len("/"):])
@@ -130,6 +146,9 @@ Examples:
 				return err
 			}
 		}
+		if tbl != nil {
+			return tbl.write(os.Stdout)
+		}
 		return nil
 	}
 
@@ -140,3 +159,16 @@ Examples:
 		usageFunc: usageFunc,
 	})
 }
+
+// repositoryColumns are the columns of the table output of src repos list.
+var repositoryColumns = []tableColumn{
+	{name: "id", template: "{{.ID}}"},
+	{name: "name", template: "{{.Name}}"},
+	{name: "url", template: "{{.URL}}"},
+	{name: "description", template: "{{.Description}}"},
+	{name: "language", template: "{{.Language}}"},
+	{name: "default-branch", template: "{{.DefaultBranch.DisplayName}}"},
+	{name: "service-type", template: "{{.ExternalRepository.ServiceType}}"},
+	{name: "created-at", template: `{{.CreatedAt.Format "2006-01-02T15:04:05Z07:00"}}`},
+	{name: "updated-at", template: `{{with .UpdatedAt}}{{.Format "2006-01-02T15:04:05Z07:00"}}{{end}}`},
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	humanize "github.com/dustin/go-humanize"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src repos stats' aggregates statistics of the repositories of an instance, for
+capacity reviews: the number of repositories by code host and by language, the
+breakdown of their clone and index status, and the total size of their clones.
+It requires a site admin to see the clone sizes.
+
+All repositories are fetched in pages, which takes a while on large instances.
+
+Examples:
+
+  Print the statistics of all repositories:
+
+    	$ src repos stats
+
+  Print the statistics of the repositories of an organization as JSON:
+
+    	$ src repos stats -query='github.com/sourcegraph/' -json
+
+`
+
+	flagSet := flag.NewFlagSet("stats", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src repos %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		queryFlag     = flagSet.String("query", "", `Only include repositories whose names match the query. (e.g. "myorg/")`)
+		languagesFlag = flagSet.Int("languages", 10, "The number of languages listed, the others are counted as other. (use -1 for all)")
+		jsonFlag      = flagSet.Bool("json", false, "Print the statistics as JSON.")
+		apiFlags      = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if flagSet.NArg() != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+
+		repos, err := listRepoStatsRepositories(ctx, client, *queryFlag)
+		if err != nil {
+			return err
+		}
+		stats := aggregateRepoStats(repos, *languagesFlag)
+
+		if *jsonFlag {
+			data, err := marshalIndent(stats)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+		return stats.write(os.Stdout)
+	}
+
+	// Register the command.
+	reposCommands = append(reposCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
+
+// repoStatsRepository is what 'src repos stats' aggregates of a repository.
+type repoStatsRepository struct {
+	Name               string
+	Language           string
+	ExternalRepository struct {
+		ServiceType string
+		ServiceID   string
+	}
+	MirrorInfo struct {
+		Cloned          bool
+		CloneInProgress bool
+		LastError       *string
+		// ByteSize is a string, as it can exceed the range of a GraphQL Int.
+		ByteSize uint64 `json:",string"`
+	}
+	TextSearchIndex *struct {
+		Status *struct {
+			UpdatedAt string
+		}
+	}
+}
+
+func listRepoStatsRepositories(ctx context.Context, client api.Client, query string) ([]*repoStatsRepository, error) {
+	gql := `query RepositoryStats($first: Int!, $after: String, $query: String) {
+    repositories(first: $first, after: $after, query: $query) {
+        nodes {
+            name
+            language
+            externalRepository {
+                serviceType
+                serviceID
+            }
+            mirrorInfo {
+                cloned
+                cloneInProgress
+                lastError
+                byteSize
+            }
+            textSearchIndex {
+                status {
+                    updatedAt
+                }
+            }
+        }
+        pageInfo {
+            hasNextPage
+            endCursor
+        }
+    }
+}`
+
+	var (
+		repos []*repoStatsRepository
+		after *string
+	)
+	for {
+		var result struct {
+			Repositories struct {
+				Nodes    []*repoStatsRepository
+				PageInfo struct {
+					HasNextPage bool
+					EndCursor   *string
+				}
+			}
+		}
+		if ok, err := client.NewRequest(gql, map[string]interface{}{
+			"first": 500,
+			"after": after,
+			"query": api.NullString(query),
+		}).Do(ctx, &result); err != nil || !ok {
+			return nil, err
+		}
+
+		repos = append(repos, result.Repositories.Nodes...)
+		if !result.Repositories.PageInfo.HasNextPage {
+			return repos, nil
+		}
+		after = result.Repositories.PageInfo.EndCursor
+	}
+}
+
+// repoStats are the aggregated statistics of repositories.
+type repoStats struct {
+	Total int `json:"total"`
+	// TotalGitSize is the total size of the clones, in bytes.
+	TotalGitSize uint64           `json:"totalGitSize"`
+	CodeHosts    []repoStatsCount `json:"codeHosts"`
+	Languages    []repoStatsCount `json:"languages"`
+	CloneStatus  []repoStatsCount `json:"cloneStatus"`
+	IndexStatus  []repoStatsCount `json:"indexStatus"`
+}
+
+// repoStatsCount is the number of repositories in a group, and the size of
+// their clones.
+type repoStatsCount struct {
+	Name    string `json:"name"`
+	Count   int    `json:"count"`
+	GitSize uint64 `json:"gitSize"`
+}
+
+// The clone and index statuses of repositories.
+const (
+	repoStatusCloned     = "cloned"
+	repoStatusCloning    = "cloning"
+	repoStatusNotCloned  = "not cloned"
+	repoStatusFailed     = "failed"
+	repoStatusIndexed    = "indexed"
+	repoStatusNotIndexed = "not indexed"
+)
+
+// aggregateRepoStats aggregates the statistics of the repositories. Only the
+// given number of languages with the most repositories are listed, the others
+// are counted as "other", unless languages is negative.
+func aggregateRepoStats(repos []*repoStatsRepository, languages int) *repoStats {
+	codeHosts := map[string]*repoStatsCount{}
+	langs := map[string]*repoStatsCount{}
+	clone := map[string]*repoStatsCount{}
+	index := map[string]*repoStatsCount{}
+	add := func(groups map[string]*repoStatsCount, name string, size uint64) {
+		c, ok := groups[name]
+		if !ok {
+			c = &repoStatsCount{Name: name}
+			groups[name] = c
+		}
+		c.Count++
+		c.GitSize += size
+	}
+
+	stats := &repoStats{Total: len(repos)}
+	for _, r := range repos {
+		size := r.MirrorInfo.ByteSize
+		stats.TotalGitSize += size
+
+		host := r.ExternalRepository.ServiceID
+		if host == "" {
+			host = "unknown"
+		}
+		if r.ExternalRepository.ServiceType != "" {
+			host = r.ExternalRepository.ServiceType + " " + host
+		}
+		add(codeHosts, host, size)
+
+		lang := r.Language
+		if lang == "" {
+			lang = "unknown"
+		}
+		add(langs, lang, size)
+
+		switch {
+		case r.MirrorInfo.Cloned:
+			add(clone, repoStatusCloned, size)
+		case r.MirrorInfo.CloneInProgress:
+			add(clone, repoStatusCloning, size)
+		case r.MirrorInfo.LastError != nil && *r.MirrorInfo.LastError != "":
+			add(clone, repoStatusFailed, size)
+		default:
+			add(clone, repoStatusNotCloned, size)
+		}
+
+		if r.TextSearchIndex != nil && r.TextSearchIndex.Status != nil {
+			add(index, repoStatusIndexed, size)
+		} else {
+			add(index, repoStatusNotIndexed, size)
+		}
+	}
+
+	stats.CodeHosts = sortedRepoStatsCounts(codeHosts)
+	stats.Languages = sortedRepoStatsCounts(langs)
+	if languages >= 0 && len(stats.Languages) > languages {
+		other := repoStatsCount{Name: "other"}
+		for _, c := range stats.Languages[languages:] {
+			other.Count += c.Count
+			other.GitSize += c.GitSize
+		}
+		stats.Languages = append(stats.Languages[:languages], other)
+	}
+	stats.CloneStatus = sortedRepoStatsCounts(clone)
+	stats.IndexStatus = sortedRepoStatsCounts(index)
+	return stats
+}
+
+// sortedRepoStatsCounts returns the counts, the largest first.
+func sortedRepoStatsCounts(groups map[string]*repoStatsCount) []repoStatsCount {
+	counts := make([]repoStatsCount, 0, len(groups))
+	for _, c := range groups {
+		counts = append(counts, *c)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Name < counts[j].Name
+	})
+	return counts
+}
+
+// write prints the statistics as tables.
+func (s *repoStats) write(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Repositories:\t%d\n", s.Total)
+	fmt.Fprintf(w, "Total git size:\t%s\n", humanize.Bytes(s.TotalGitSize))
+
+	for _, section := range []struct {
+		title  string
+		counts []repoStatsCount
+	}{
+		{"CODE HOST", s.CodeHosts},
+		{"LANGUAGE", s.Languages},
+		{"CLONE STATUS", s.CloneStatus},
+		{"INDEX STATUS", s.IndexStatus},
+	} {
+		fmt.Fprintf(w, "\n%s\tREPOSITORIES\tPERCENT\tGIT SIZE\n", section.title)
+		for _, c := range section.counts {
+			percent := 0.0
+			if s.Total > 0 {
+				percent = float64(c.Count) / float64(s.Total) * 100
+			}
+			fmt.Fprintf(w, "%s\t%d\t%.1f%%\t%s\n", c.Name, c.Count, percent, humanize.Bytes(c.GitSize))
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAggregateRepoStats(t *testing.T) {
+	var repos []*repoStatsRepository
+	if err := json.Unmarshal([]byte(`[
+  {"name": "github.com/a/go", "language": "Go", "externalRepository": {"serviceType": "github", "serviceID": "https://github.com/"},
+   "mirrorInfo": {"cloned": true, "byteSize": "3000"}, "textSearchIndex": {"status": {"updatedAt": "2021-10-01T00:00:00Z"}}},
+  {"name": "github.com/a/go2", "language": "Go", "externalRepository": {"serviceType": "github", "serviceID": "https://github.com/"},
+   "mirrorInfo": {"cloned": true, "byteSize": "1000"}, "textSearchIndex": {"status": null}},
+  {"name": "gitlab.com/a/ts", "language": "TypeScript", "externalRepository": {"serviceType": "gitlab", "serviceID": "https://gitlab.com/"},
+   "mirrorInfo": {"cloneInProgress": true, "byteSize": "0"}},
+  {"name": "gitlab.com/a/py", "language": "Python", "externalRepository": {"serviceType": "gitlab", "serviceID": "https://gitlab.com/"},
+   "mirrorInfo": {"lastError": "repository not found", "byteSize": "0"}},
+  {"name": "gitlab.com/a/empty", "language": "", "externalRepository": {"serviceType": "gitlab", "serviceID": "https://gitlab.com/"},
+   "mirrorInfo": {"byteSize": "0"}}
+]`), &repos); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := aggregateRepoStats(repos, 2)
+	want := &repoStats{
+		Total:        5,
+		TotalGitSize: 4000,
+		CodeHosts: []repoStatsCount{
+			{Name: "gitlab https://gitlab.com/", Count: 3},
+			{Name: "github https://github.com/", Count: 2, GitSize: 4000},
+		},
+		Languages: []repoStatsCount{
+			{Name: "Go", Count: 2, GitSize: 4000},
+			{Name: "Python", Count: 1},
+			{Name: "other", Count: 2},
+		},
+		CloneStatus: []repoStatsCount{
+			{Name: repoStatusCloned, Count: 2, GitSize: 4000},
+			{Name: repoStatusCloning, Count: 1},
+			{Name: repoStatusFailed, Count: 1},
+			{Name: repoStatusNotCloned, Count: 1},
+		},
+		IndexStatus: []repoStatsCount{
+			{Name: repoStatusNotIndexed, Count: 4, GitSize: 1000},
+			{Name: repoStatusIndexed, Count: 1, GitSize: 3000},
+		},
+	}
+	if diff := cmp.Diff(want, stats); diff != "" {
+		t.Errorf("wrong stats (-want +have):\n%s", diff)
+	}
+
+	var buf bytes.Buffer
+	if err := stats.write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	wantOut := `Repositories:    5
+Total git size:  4.0 kB
+
+CODE HOST                   REPOSITORIES  PERCENT  GIT SIZE
+gitlab https://gitlab.com/  3             60.0%    0 B
+github https://github.com/  2             40.0%    4.0 kB
+
+LANGUAGE  REPOSITORIES  PERCENT  GIT SIZE
+Go        2             40.0%    4.0 kB
+Python    1             20.0%    0 B
+other     2             40.0%    0 B
+
+CLONE STATUS  REPOSITORIES  PERCENT  GIT SIZE
+cloned        2             40.0%    4.0 kB
+cloning       1             20.0%    0 B
+failed        1             20.0%    0 B
+not cloned    1             20.0%    0 B
+
+INDEX STATUS  REPOSITORIES  PERCENT  GIT SIZE
+not indexed   4             80.0%    1.0 kB
+indexed       1             20.0%    3.0 kB
+`
+	if diff := cmp.Diff(wantOut, buf.String()); diff != "" {
+		t.Errorf("wrong output (-want +have):\n%s", diff)
+	}
+}
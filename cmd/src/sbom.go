@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+var sbomCommands commander
+
+func init() {
+	usage := `'src sbom' verifies the supply chain of a Sourcegraph deployment.
+
+Usage:
+
+	src sbom command [command options]
+
+The commands are:
+
+	verify    verifies the signatures and SBOM attestations of the images of a
+	          running deployment
+
+Use "src sbom [command] -h" for more information about a command.
+
+`
+
+	flagSet := flag.NewFlagSet("sbom", flag.ExitOnError)
+	handler := func(args []string) error {
+		sbomCommands.run(flagSet, "src sbom", usage, args)
+		return nil
+	}
+
+	// Register the command.
+	commands = append(commands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: func() { fmt.Println(usage) },
+	})
+}
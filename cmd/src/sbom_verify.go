@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+	"github.com/sourcegraph/src-cli/internal/sbom"
+)
+
+func init() {
+	usage := `
+'src sbom verify' lists the container images of a running Sourcegraph
+deployment and verifies their signatures and SBOM attestations with cosign,
+which must be installed.
+
+Images that aren't published by Sourcegraph, such as those of sidecars added to
+the deployment, are reported as unexpected. The command fails if any image
+isn't verified.
+
+Usage:
+
+    src sbom verify -deployment TYPE -key KEY [command options]
+
+Examples:
+
+    $ src sbom verify -deployment kubernetes -namespace sourcegraph -key cosign.pub
+
+    $ src sbom verify -deployment docker -key https://example.com/cosign.pub -json
+
+`
+
+	flagSet := flag.NewFlagSet("verify", flag.ExitOnError)
+
+	var (
+		deploymentFlag = flagSet.String("deployment", "", "The deployment type: kubernetes, or docker for docker-compose and single-container deployments.")
+		namespaceFlag  = flagSet.String("namespace", "", "The Kubernetes namespace of the deployment. Default is the namespace of the current context.")
+		keyFlag        = flagSet.String("key", "", "The path or URL of the public key to verify the images with, as accepted by 'cosign verify --key'.")
+		jsonFlag       = flagSet.Bool("json", false, "Print the results as JSON.")
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+		if *deploymentFlag != sbom.DeploymentKubernetes && *deploymentFlag != sbom.DeploymentDocker {
+			return cmderrors.Usagef("-deployment must be %s or %s", sbom.DeploymentKubernetes, sbom.DeploymentDocker)
+		}
+		if *keyFlag == "" {
+			return cmderrors.Usage("-key is required")
+		}
+		if err := checkExecutable("cosign", "version"); err != nil {
+			return err
+		}
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+
+		images, err := sbom.ListImages(ctx, *deploymentFlag, *namespaceFlag)
+		if err != nil {
+			return err
+		}
+		results := sbom.Verify(ctx, images, *keyFlag)
+
+		if *jsonFlag {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(results); err != nil {
+				return err
+			}
+		} else {
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "IMAGE\tSTATUS\tDETAIL")
+			for _, r := range results {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", r.Image, r.Status, r.Detail)
+			}
+			w.Flush()
+		}
+
+		failed := 0
+		for _, r := range results {
+			if r.Status != sbom.StatusVerified {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return errors.Newf("%d of %d images could not be verified", failed, len(results))
+		}
+		return nil
+	}
+
+	sbomCommands = append(sbomCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src sbom %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+var scoutCommands commander
+
+func init() {
+	usage := `'src scout' inspects a Sourcegraph deployment and recommends changes to it.
+
+Usage:
+
+	src scout command [command options]
+
+The commands are:
+
+	resources    compares the resources of the containers of a deployment with
+	             the sizing guidelines for the instance
+
+Use "src scout [command] -h" for more information about a command.
+
+`
+
+	flagSet := flag.NewFlagSet("scout", flag.ExitOnError)
+	handler := func(args []string) error {
+		scoutCommands.run(flagSet, "src scout", usage, args)
+		return nil
+	}
+
+	// Register the command.
+	commands = append(commands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: func() { fmt.Println(usage) },
+	})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+	"github.com/sourcegraph/src-cli/internal/scout"
+)
+
+func init() {
+	usage := `
+'src scout resources' reads the resource limits and the live usage of the
+containers of a running Sourcegraph deployment, compares them with the sizing
+guidelines for the number of users and repositories of the instance, and prints
+the containers that are under- or over-provisioned.
+
+Live usage is read with 'kubectl top' or 'docker stats'. On Kubernetes this
+requires the metrics server; without it, only the limits are compared.
+
+Usage:
+
+    src scout resources -deployment TYPE [command options]
+
+Examples:
+
+    $ src scout resources -deployment kubernetes -namespace sourcegraph
+
+    $ src scout resources -deployment docker -json
+
+`
+
+	flagSet := flag.NewFlagSet("resources", flag.ExitOnError)
+
+	var (
+		deploymentFlag = flagSet.String("deployment", "", "The deployment type: kubernetes, or docker for docker-compose and single-container deployments.")
+		namespaceFlag  = flagSet.String("namespace", "", "The Kubernetes namespace of the deployment. Default is the namespace of the current context.")
+		jsonFlag       = flagSet.Bool("json", false, "Print the size and recommendations as JSON.")
+		apiFlags       = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+
+		var (
+			containers []*scout.Container
+			err        error
+		)
+		switch *deploymentFlag {
+		case "kubernetes":
+			if err := checkExecutable("kubectl", "version", "--client"); err != nil {
+				return err
+			}
+			containers, err = scout.KubeContainers(ctx, *namespaceFlag)
+		case "docker":
+			if err := checkExecutable("docker", "version"); err != nil {
+				return err
+			}
+			containers, err = scout.DockerContainers(ctx)
+		default:
+			return cmderrors.Usage("-deployment must be kubernetes or docker")
+		}
+		if err != nil {
+			return err
+		}
+
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+		users, repos, err := scout.InstanceCounts(ctx, client)
+		if err != nil {
+			return err
+		}
+		size := scout.SizeFor(users, repos)
+		recs := scout.Recommend(containers, size)
+
+		if *jsonFlag {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(struct {
+				Users           int                    `json:"users"`
+				Repositories    int                    `json:"repositories"`
+				Size            scout.Size             `json:"size"`
+				Recommendations []scout.Recommendation `json:"recommendations"`
+			}{users, repos, size, recs})
+		}
+
+		fmt.Printf("%d users and %d repositories: size %s\n\n", users, repos, size.Name)
+		if len(recs) == 0 {
+			fmt.Println("All containers match the sizing guidelines.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "CONTAINER\tRESOURCE\tSTATUS\tCURRENT\tRECOMMENDED\tREASON")
+		for _, r := range recs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.Container, r.Resource, r.Kind, r.Current, r.Recommended, r.Reason)
+		}
+		return w.Flush()
+	}
+
+	scoutCommands = append(scoutCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src scout %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
@@ -19,6 +19,7 @@ import (
 
 	"github.com/sourcegraph/src-cli/internal/api"
 	"github.com/sourcegraph/src-cli/internal/cmderrors"
+	"github.com/sourcegraph/src-cli/internal/searchquery"
 	"github.com/sourcegraph/src-cli/internal/streaming"
 )
 
@@ -36,6 +37,28 @@ Examples:
 
     	$ src search -json 'repogroup:sample error'
 
+  Build the query from flags, without having to know the query syntax:
+
+    	$ src search -repo github.com/sourcegraph/src-cli -lang go -file cmd/ 'fmt.Println'
+
+    	$ src search -type commit -author 'Jane Doe' -after '2 weeks ago' -print-query fix
+
+  Show two lines of context around matches, syntax highlighted:
+
+    	$ src search -C 2 -highlight 'repogroup:sample error'
+
+  Run a catalog of queries and print a combined report (see 'src search batch -h'):
+
+    	$ src search batch -f queries.yaml
+
+  Drop matches in vendored, minified and generated files, and in tests:
+
+    	$ src search -skip-generated -exclude-path '_test\.go$' 'fmt.Sprintf'
+
+  Show matches that are identical in forks only once:
+
+    	$ src search -dedupe-forks 'AKIA[0-9A-Z]{16} patterntype:regexp'
+
 Other tips:
 
   Make 'type:diff' searches have colored diffs by installing https://colordiff.org
@@ -47,6 +70,8 @@ Other tips:
 
   Force color output on (not on by default when piped to other programs) by setting COLOR=t
 
+  Results are paginated with $PAGER, or 'less -R' if it's not set. Disable pagination with -less=false or PAGER=cat.
+
   Query syntax: https://about.sourcegraph.com/docs/search/query-syntax/
 `
 
@@ -55,38 +80,86 @@ Other tips:
 		jsonFlag        = flagSet.Bool("json", false, "Whether or not to output results as JSON.")
 		explainJSONFlag = flagSet.Bool("explain-json", false, "Explain the JSON output schema and exit.")
 		apiFlags        = api.NewFlags(flagSet)
-		lessFlag        = flagSet.Bool("less", true, "Pipe output to 'less -R' (only if stdout is terminal, and not json flag).")
+		lessFlag        = flagSet.Bool("less", true, "Pipe output to $PAGER, or 'less -R' if it's not set (only if stdout is terminal, and not json flag).")
 		streamFlag      = flagSet.Bool("stream", false, "Consume results as stream. Streaming search only supports a subset of flags and parameters: trace, insecure-skip-verify, display, json.")
 		display         = flagSet.Int("display", -1, "Limit the number of results that are displayed. Only supported together with stream flag. Statistics continue to report all results.")
+		contextFlag     = flagSet.Int("C", 0, "Show this many lines of context around each matching line.")
+		beforeFlag      = flagSet.Int("B", -1, "Show this many lines of context before each matching line. Overrides -C.")
+		afterFlag       = flagSet.Int("A", -1, "Show this many lines of context after each matching line. Overrides -C.")
+		highlightFlag   = flagSet.Bool("highlight", false, "Syntax highlight the matching lines of files, based on their language (only if color output is enabled).")
+		hyperlinksFlag  = flagSet.Bool("hyperlinks", false, "Link results to the web UI using terminal hyperlink escape sequences.")
+		printQueryFlag  = flagSet.Bool("print-query", false, "Print the query built from the arguments and query flags, and exit.")
+		dedupeForksFlag = flagSet.Bool("dedupe-forks", false, "Show matches that are identical in a repository and its forks once, with the number of forks they're also in.")
+		queryFlags      = newSearchQueryFlags(flagSet)
+		filterFlags     = newSearchFilterFlags(flagSet)
 	)
 
 	handler := func(args []string) error {
+		if len(args) > 0 && args[0] == "batch" {
+			return runSearchBatch(args[1:])
+		}
 		if err := flagSet.Parse(args); err != nil {
 			return err
 		}
 
+		if *explainJSONFlag {
+			fmt.Println(searchJSONExplanation)
+			return nil
+		}
+
+		if flagSet.NArg() > 1 || (flagSet.NArg() == 0 && !queryFlags.isSet()) {
+			return cmderrors.Usage("expected exactly one argument: the search query")
+		}
+		queryString, err := searchquery.Build(flagSet.Arg(0), queryFlags.options())
+		if err != nil {
+			return cmderrors.Usage(err.Error())
+		}
+
+		if *printQueryFlag {
+			fmt.Println(queryString)
+			return nil
+		}
+
+		filter, err := filterFlags.filter()
+		if err != nil {
+			return cmderrors.Usage(err.Error())
+		}
+
 		if *streamFlag {
+			if *dedupeForksFlag {
+				return cmderrors.Usage("-dedupe-forks isn't supported together with -stream")
+			}
+			if filter.NeedsContent() {
+				return cmderrors.Usage("-max-file-size isn't supported together with -stream, which doesn't return the content of files")
+			}
 			opts := streaming.Opts{
 				Display: *display,
 				Trace:   apiFlags.Trace(),
 				Json:    *jsonFlag,
+				Filter:  filter,
 			}
 			client := cfg.apiClient(apiFlags, flagSet.Output())
-			return streamSearch(flagSet.Arg(0), opts, client, os.Stdout)
+			return streamSearch(queryString, opts, client, os.Stdout)
 		}
 
-		if *explainJSONFlag {
-			fmt.Println(searchJSONExplanation)
-			return nil
+		searchRenderOptions.ContextBefore = *contextFlag
+		if *beforeFlag >= 0 {
+			searchRenderOptions.ContextBefore = *beforeFlag
 		}
-
-		if flagSet.NArg() != 1 {
-			return cmderrors.Usage("expected exactly one argument: the search query")
+		searchRenderOptions.ContextAfter = *contextFlag
+		if *afterFlag >= 0 {
+			searchRenderOptions.ContextAfter = *afterFlag
 		}
-		queryString := flagSet.Arg(0)
+		searchRenderOptions.Highlight = *highlightFlag
+		searchRenderOptions.Hyperlinks = *hyperlinksFlag
 
-		// For pagination, pipe our own output to 'less -R'
-		if *lessFlag && !*jsonFlag && isatty.IsTerminal(os.Stdout.Fd()) {
+		// For pagination, pipe our own output to the pager.
+		pager, pagerSet := os.LookupEnv("PAGER")
+		pagerCmd, err := pagerCommand(pager, pagerSet)
+		if err != nil {
+			return err
+		}
+		if *lessFlag && pagerCmd != nil && !*jsonFlag && isatty.IsTerminal(os.Stdout.Fd()) {
 			cmdPath, err := os.Executable()
 			if err != nil {
 				return err
@@ -109,11 +182,13 @@ Other tips:
 				return err
 			}
 
-			lessCmd := exec.Command("less", "-R")
-			lessCmd.Stdin = io.MultiReader(srcStdout, srcStderr)
-			lessCmd.Stderr = os.Stderr
-			lessCmd.Stdout = os.Stdout
-			return lessCmd.Run()
+			// Like git, make less interpret color escape sequences if it's
+			// used without -R.
+			pagerCmd.Env = envSetDefault(os.Environ(), "LESS", "FRX")
+			pagerCmd.Stdin = io.MultiReader(srcStdout, srcStderr)
+			pagerCmd.Stderr = os.Stderr
+			pagerCmd.Stdout = os.Stdout
+			return pagerCmd.Run()
 		}
 
 		client := cfg.apiClient(apiFlags, flagSet.Output())
@@ -122,6 +197,7 @@ Other tips:
 				repository {
 					name
 					url
+					isFork @include(if: $dedupeForks)
 				}
 				file {
 					name
@@ -131,6 +207,10 @@ Other tips:
 					commit {
 						oid
 					}
+					highlight(disableTimeout: false, isLightTheme: false) @include(if: $highlight) {
+						aborted
+						html
+					}
 				}
 				lineMatches {
 					preview
@@ -176,6 +256,7 @@ Other tips:
 				commit {
 					repository {
 						name
+						isFork @include(if: $dedupeForks)
 					}
 					oid
 					url
@@ -201,7 +282,7 @@ Other tips:
 			}
 		  }
 
-		  query ($query: String!) {
+		  query ($query: String!, $highlight: Boolean!, $dedupeForks: Boolean!) {
 			site {
 				buildVersion
 			}
@@ -247,7 +328,9 @@ Other tips:
 		}
 
 		if ok, err := client.NewRequest(query, map[string]interface{}{
-			"query": api.NullString(queryString),
+			"query":       api.NullString(queryString),
+			"highlight":   searchRenderOptions.Highlight && !colorDisabled,
+			"dedupeForks": *dedupeForksFlag,
 		}).Do(context.Background(), &result); err != nil || !ok {
 			return err
 		}
@@ -258,6 +341,10 @@ Other tips:
 			Site:                result.Site,
 			searchResults:       result.Search.Results,
 		}
+		improved.Results, improved.FilteredOut = filterSearchResults(filter, improved.Results)
+		if *dedupeForksFlag {
+			improved.Results, improved.ForkDuplicates = dedupeForkResults(improved.Results)
+		}
 
 		if *jsonFlag {
 			// Print the formatted JSON.
@@ -305,6 +392,12 @@ type searchResultsImproved struct {
 	SourcegraphEndpoint string
 	Query               string
 	Site                struct{ BuildVersion string }
+	// ForkDuplicates is the number of matches that -dedupe-forks dropped
+	// because they're identical to a match in another repository.
+	ForkDuplicates int `json:",omitempty"`
+	// FilteredOut is the number of results that the client-side filters
+	// dropped.
+	FilteredOut int `json:",omitempty"`
 	searchResults
 }
 
@@ -540,6 +633,9 @@ var searchTemplateFuncs = map[string]interface{}{
 	},
 	"htmlToPlainText":                   htmlToPlainText,
 	"buildVersionHasNewSearchInterface": buildVersionHasNewSearchInterface,
+	"searchUseFileMatchRenderer":        searchUseFileMatchRenderer,
+	"searchRenderFileMatch":             searchRenderFileMatch,
+	"hyperlink":                         hyperlink,
 	"renderResult": func(searchResult map[string]interface{}) string {
 		searchResultBody := searchResult["body"].(map[string]interface{})
 		html := searchResultBody["html"].(string)
@@ -571,6 +667,8 @@ const searchResultsTemplate = `{{- /* ignore this line for template formatting s
 	{{- .ResultCount -}}{{if .LimitHit}}+{{end}} results{{- color "nc" -}}
 	{{- " for " -}}{{- color "search-query"}}"{{.Query}}"{{color "nc" -}}
 	{{- " in " -}}{{color "success"}}{{msDuration .ElapsedMilliseconds}}{{color "nc" -}}
+	{{- with .ForkDuplicates}}{{color "warning"}} ({{.}} identical matches in forks hidden){{color "nc"}}{{end -}}
+	{{- with .FilteredOut}}{{color "warning"}} ({{.}} results filtered out){{color "nc"}}{{end -}}
 
 {{- /* The cloning / missing / timed out repos warnings */ -}}
 	{{- with .Cloning}}{{color "warning"}}{{"\n"}}({{len .}}) still cloning:{{color "nc"}} {{join (repoNames .) ", "}}{{end -}}
@@ -592,27 +690,32 @@ const searchResultsTemplate = `{{- /* ignore this line for template formatting s
 		{{- if eq .__typename "FileMatch" -}}
 			{{- /* Link to the result */ -}}
 			{{- color "search-border"}}{{"("}}{{color "nc" -}}
-			{{- color "search-link"}}{{$.SourcegraphEndpoint}}{{.file.url}}{{color "nc" -}}
+			{{- color "search-link"}}{{hyperlink (print $.SourcegraphEndpoint .file.url) (print $.SourcegraphEndpoint .file.url)}}{{color "nc" -}}
 			{{- color "search-border"}}{{")\n"}}{{color "nc" -}}
 			{{- color "nc" -}}
 
 			{{- /* Repository and file name */ -}}
-			{{- color "search-repository"}}{{.repository.name}}{{color "nc" -}}
+			{{- color "search-repository"}}{{hyperlink (print $.SourcegraphEndpoint .repository.url) .repository.name}}{{color "nc" -}}
 			{{- " › " -}}
-			{{- color "search-filename"}}{{.file.name}}{{color "nc" -}}
+			{{- color "search-filename"}}{{hyperlink (print $.SourcegraphEndpoint .file.url) .file.name}}{{color "nc" -}}
 			{{- color "success"}}{{" ("}}{{len .lineMatches}}{{" matches)"}}{{color "nc" -}}
+			{{- with .forks}}{{color "search-border"}}{{" (also in "}}{{len .}}{{" forks)"}}{{color "nc"}}{{end -}}
 			{{- "\n" -}}
 			{{- color "search-border"}}{{"--------------------------------------------------------------------------------\n"}}{{color "nc"}}
 
 			{{- /* Line matches */ -}}
-			{{- $lineMatches := .lineMatches -}}
-			{{- $content := .file.content -}}
-			{{- range $index, $match := $lineMatches -}}
-				{{- if not (searchSequentialLineNumber $lineMatches $index) -}}
-					{{- color "search-border"}}{{"  ------------------------------------------------------------------------------\n"}}{{color "nc"}}
+			{{- if searchUseFileMatchRenderer -}}
+				{{- searchRenderFileMatch . -}}
+			{{- else -}}
+				{{- $lineMatches := .lineMatches -}}
+				{{- $content := .file.content -}}
+				{{- range $index, $match := $lineMatches -}}
+					{{- if not (searchSequentialLineNumber $lineMatches $index) -}}
+						{{- color "search-border"}}{{"  ------------------------------------------------------------------------------\n"}}{{color "nc"}}
+					{{- end -}}
+					{{- "  "}}{{color "search-line-numbers"}}{{pad (addFloat $match.lineNumber 1) 6 " "}}{{color "nc" -}}
+					{{- color "search-border"}}{{" |  "}}{{color "nc"}}{{searchHighlightMatch $content $.Query $match}}
 				{{- end -}}
-				{{- "  "}}{{color "search-line-numbers"}}{{pad (addFloat $match.lineNumber 1) 6 " "}}{{color "nc" -}}
-				{{- color "search-border"}}{{" |  "}}{{color "nc"}}{{searchHighlightMatch $content $.Query $match}}
 			{{- end -}}
 		{{- end -}}
 
@@ -626,6 +729,7 @@ const searchResultsTemplate = `{{- /* ignore this line for template formatting s
 
 			{{- /* Repository > author name "commit subject" (time ago) */ -}}
 			{{- color "search-commit-subject"}}{{(htmlToPlainText .label.html)}}{{color "nc" -}}
+			{{- with .forks}}{{color "search-border"}}{{" (also in "}}{{len .}}{{" forks)"}}{{color "nc"}}{{end -}}
 			{{- "\n" -}}
 			{{- color "search-border"}}{{"--------------------------------------------------------------------------------\n"}}{{color "nc"}}
 			{{- $matches := .matches -}}
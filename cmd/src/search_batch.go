@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+	"github.com/sourcegraph/src-cli/internal/streaming"
+)
+
+const searchBatchUsage = `
+'src search batch' runs a catalog of search queries, such as detection queries
+that security teams run nightly, and prints a combined JSON report with the
+number of matches and sample matches of each query.
+
+The queries are run with the streaming search API, a limited number at a time
+and at a limited rate, so that a large catalog doesn't overload the instance.
+
+The catalog is a YAML file:
+
+    parallelism: 4           # optional, overrides -parallelism
+    samples: 3               # optional, overrides -samples
+    queries:
+      - name: aws-access-keys
+        query: 'AKIA[0-9A-Z]{16} patterntype:regexp'
+      - name: private-keys
+        query: '"BEGIN RSA PRIVATE KEY" patterntype:literal'
+        samples: 10          # optional, overrides the catalog default
+
+The command exits with status 6 if any query failed, after printing the report
+of all queries.
+
+Usage:
+
+    src search batch -f FILE [command options]
+
+Examples:
+
+    $ src search batch -f queries.yaml
+
+    $ src search batch -f queries.yaml -parallelism 2 -rate 30 -o report.json
+
+To search for the word batch instead, use 'src search -- batch'.
+`
+
+// searchBatchCatalog is a catalog of queries for 'src search batch'.
+type searchBatchCatalog struct {
+	Parallelism int                `yaml:"parallelism"`
+	Samples     *int               `yaml:"samples"`
+	Queries     []searchBatchQuery `yaml:"queries"`
+}
+
+type searchBatchQuery struct {
+	Name    string `yaml:"name"`
+	Query   string `yaml:"query"`
+	Samples *int   `yaml:"samples"`
+}
+
+func parseSearchBatchCatalog(data []byte) (*searchBatchCatalog, error) {
+	var catalog searchBatchCatalog
+	if err := yaml.Unmarshal(data, &catalog); err != nil {
+		return nil, errors.Wrap(err, "parsing the query catalog")
+	}
+	if len(catalog.Queries) == 0 {
+		return nil, errors.New("the query catalog has no queries")
+	}
+	names := map[string]bool{}
+	for i, q := range catalog.Queries {
+		if strings.TrimSpace(q.Query) == "" {
+			return nil, errors.Newf("query %d has no query", i+1)
+		}
+		if q.Name == "" {
+			catalog.Queries[i].Name = q.Query
+		}
+		if names[catalog.Queries[i].Name] {
+			return nil, errors.Newf("query %d: duplicate name %q", i+1, catalog.Queries[i].Name)
+		}
+		names[catalog.Queries[i].Name] = true
+	}
+	return &catalog, nil
+}
+
+// searchBatchReport is the report of 'src search batch'.
+type searchBatchReport struct {
+	StartedAt time.Time                 `json:"startedAt"`
+	Queries   []*searchBatchQueryReport `json:"queries"`
+}
+
+type searchBatchQueryReport struct {
+	Name       string `json:"name"`
+	Query      string `json:"query"`
+	MatchCount int    `json:"matchCount"`
+	// LimitHit is set when the search stopped before finding all matches, so
+	// MatchCount is a lower bound.
+	LimitHit   bool                `json:"limitHit"`
+	DurationMs int                 `json:"durationMs"`
+	Alert      string              `json:"alert,omitempty"`
+	Error      string              `json:"error,omitempty"`
+	Samples    []searchBatchSample `json:"samples"`
+}
+
+// searchBatchSample is a sample match of a query.
+type searchBatchSample struct {
+	Repository string `json:"repository,omitempty"`
+	Path       string `json:"path,omitempty"`
+	LineNumber int    `json:"lineNumber,omitempty"`
+	Line       string `json:"line,omitempty"`
+	// Label describes commit matches.
+	Label string `json:"label,omitempty"`
+}
+
+// runSearchBatch runs 'src search batch' with the arguments after "batch".
+func runSearchBatch(args []string) error {
+	flagSet := flag.NewFlagSet("batch", flag.ExitOnError)
+	flagSet.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src search batch':\n")
+		flagSet.PrintDefaults()
+		fmt.Println(searchBatchUsage)
+	}
+	var (
+		fileFlag        = flagSet.String("f", "", "The YAML file with the catalog of queries. (required)")
+		parallelismFlag = flagSet.Int("parallelism", 4, "The number of queries that are run at the same time.")
+		rateFlag        = flagSet.Float64("rate", 60, "The maximum number of queries started per minute. (0 for no limit)")
+		samplesFlag     = flagSet.Int("samples", 3, "The number of sample matches reported per query.")
+		outputFlag      = flagSet.String("o", "", "Write the report to this file instead of stdout.")
+		apiFlags        = api.NewFlags(flagSet)
+	)
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 0 {
+		return cmderrors.Usage("additional arguments not allowed")
+	}
+	if *fileFlag == "" {
+		return cmderrors.Usage("-f is required")
+	}
+	if *rateFlag < 0 || *samplesFlag < 0 {
+		return cmderrors.Usage("-rate and -samples must not be negative")
+	}
+
+	data, err := os.ReadFile(*fileFlag)
+	if err != nil {
+		return err
+	}
+	catalog, err := parseSearchBatchCatalog(data)
+	if err != nil {
+		return cmderrors.WithKind(err, cmderrors.KindValidation)
+	}
+	parallelism := *parallelismFlag
+	if catalog.Parallelism > 0 {
+		parallelism = catalog.Parallelism
+	}
+	if parallelism <= 0 {
+		return cmderrors.Usage("-parallelism must be positive")
+	}
+	samples := *samplesFlag
+	if catalog.Samples != nil {
+		samples = *catalog.Samples
+	}
+
+	ctx, cancel := contextCancelOnInterrupt(context.Background())
+	defer cancel()
+	client := cfg.apiClient(apiFlags, flagSet.Output())
+
+	var interval time.Duration
+	if *rateFlag > 0 {
+		interval = time.Duration(float64(time.Minute) / *rateFlag)
+	}
+	report := runSearchBatchQueries(ctx, client, catalog, parallelism, interval, samples)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	var out io.Writer = os.Stdout
+	if *outputFlag != "" {
+		f, err := os.Create(*outputFlag)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, q := range report.Queries {
+		if q.Error != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return cmderrors.WithKind(errors.Newf("%d of %d queries failed", failed, len(report.Queries)), cmderrors.KindPartialFailure)
+	}
+	return nil
+}
+
+// runSearchBatchQueries runs the queries of the catalog, parallelism at a
+// time, starting one every interval at most, and returns their report in the
+// order of the catalog.
+func runSearchBatchQueries(ctx context.Context, client api.Client, catalog *searchBatchCatalog, parallelism int, interval time.Duration, samples int) *searchBatchReport {
+	report := &searchBatchReport{
+		StartedAt: time.Now().UTC(),
+		Queries:   make([]*searchBatchQueryReport, len(catalog.Queries)),
+	}
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, q := range catalog.Queries {
+		if i > 0 && tick != nil {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+			}
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		n := samples
+		if q.Samples != nil {
+			n = *q.Samples
+		}
+		wg.Add(1)
+		go func(i int, q searchBatchQuery) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			report.Queries[i] = runSearchBatchQuery(ctx, client, q, n)
+		}(i, q)
+	}
+	wg.Wait()
+
+	// Queries that weren't started because of an interrupt are left out.
+	queries := report.Queries[:0]
+	for _, q := range report.Queries {
+		if q != nil {
+			queries = append(queries, q)
+		}
+	}
+	report.Queries = queries
+	return report
+}
+
+func runSearchBatchQuery(ctx context.Context, client api.Client, q searchBatchQuery, samples int) *searchBatchQueryReport {
+	r := &searchBatchQueryReport{Name: q.Name, Query: q.Query, Samples: []searchBatchSample{}}
+
+	var errs []string
+	received := 0
+	decoder := streaming.Decoder{
+		OnProgress: func(p *streaming.Progress) {
+			if !p.Done {
+				return
+			}
+			r.MatchCount = p.MatchCount
+			r.DurationMs = p.DurationMs
+			r.LimitHit = isLimitHit(p)
+		},
+		OnMatches: func(matches []streaming.EventMatch) {
+			received += len(matches)
+			for _, m := range matches {
+				if len(r.Samples) >= samples {
+					return
+				}
+				r.Samples = append(r.Samples, searchBatchSamples(m, samples-len(r.Samples))...)
+			}
+		},
+		OnAlert: func(alert *streaming.EventAlert) {
+			r.Alert = alert.Title
+		},
+		OnError: func(e *streaming.EventError) {
+			errs = append(errs, e.Message)
+		},
+	}
+
+	start := time.Now()
+	if err := streaming.SearchContext(ctx, q.Query, streaming.Opts{Display: samples}, client, decoder); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if r.DurationMs == 0 {
+		r.DurationMs = int(time.Since(start).Milliseconds())
+	}
+	if r.MatchCount < received {
+		r.MatchCount = received
+	}
+	r.Error = strings.Join(errs, "; ")
+	return r
+}
+
+// searchBatchSamples returns at most n samples of a match: one per matching
+// line of content matches, and one for other matches.
+func searchBatchSamples(m streaming.EventMatch, n int) []searchBatchSample {
+	var samples []searchBatchSample
+	switch m := m.(type) {
+	case *streaming.EventContentMatch:
+		for _, l := range m.LineMatches {
+			if len(samples) == n {
+				break
+			}
+			samples = append(samples, searchBatchSample{
+				Repository: m.Repository,
+				Path:       m.Path,
+				LineNumber: int(l.LineNumber) + 1,
+				Line:       l.Line,
+			})
+		}
+	case *streaming.EventPathMatch:
+		samples = append(samples, searchBatchSample{Repository: m.Repository, Path: m.Path})
+	case *streaming.EventSymbolMatch:
+		samples = append(samples, searchBatchSample{Repository: m.Repository, Path: m.Path})
+	case *streaming.EventRepoMatch:
+		samples = append(samples, searchBatchSample{Repository: m.Repository})
+	case *streaming.EventCommitMatch:
+		samples = append(samples, searchBatchSample{Label: m.Label})
+	}
+	return samples
+}
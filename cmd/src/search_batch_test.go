@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/streaming"
+)
+
+func TestParseSearchBatchCatalog(t *testing.T) {
+	catalog, err := parseSearchBatchCatalog([]byte(`
+samples: 1
+queries:
+  - name: keys
+    query: AKIA
+  - query: password
+    samples: 5
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := catalog.Queries[1].Name, "password"; have != want {
+		t.Errorf("wrong default name: have %q, want %q", have, want)
+	}
+
+	for name, data := range map[string]string{
+		"no queries":      `queries: []`,
+		"empty query":     "queries:\n  - name: a\n",
+		"duplicate names": "queries:\n  - {name: a, query: x}\n  - {name: a, query: y}\n",
+	} {
+		if _, err := parseSearchBatchCatalog([]byte(data)); err == nil {
+			t.Errorf("%s: want error, have none", name)
+		}
+	}
+}
+
+func TestRunSearchBatchQueries(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writer, _ := streaming.NewWriter(w)
+		switch r.URL.Query().Get("q") {
+		case "AKIA":
+			writer.Event("matches", []streaming.EventMatch{
+				&streaming.EventContentMatch{
+					Type:       streaming.ContentMatchType,
+					Repository: "org/a",
+					Path:       "config.yml",
+					LineMatches: []streaming.EventLineMatch{
+						{Line: "key: AKIA1", LineNumber: 2},
+						{Line: "key: AKIA2", LineNumber: 5},
+					},
+				},
+				&streaming.EventRepoMatch{Type: streaming.RepoMatchType, Repository: "org/b"},
+			})
+			writer.Event("progress", streaming.Progress{
+				Done:       true,
+				MatchCount: 10,
+				Skipped:    []streaming.Skipped{{Reason: streaming.ShardMatchLimit}},
+			})
+		case "broken(":
+			writer.Event("error", streaming.EventError{Message: "invalid query"})
+		}
+		writer.Event("done", nil)
+	}))
+	defer s.Close()
+
+	cfg = &config{Endpoint: s.URL}
+	defer func() { cfg = nil }()
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	client := cfg.apiClient(api.NewFlags(flagSet), flagSet.Output())
+
+	one := 1
+	catalog := &searchBatchCatalog{Queries: []searchBatchQuery{
+		{Name: "keys", Query: "AKIA"},
+		{Name: "keys, one sample", Query: "AKIA", Samples: &one},
+		{Name: "broken", Query: "broken("},
+	}}
+	report := runSearchBatchQueries(context.Background(), client, catalog, 2, 0, 5)
+
+	want := []*searchBatchQueryReport{
+		{
+			Name: "keys", Query: "AKIA", MatchCount: 10, LimitHit: true,
+			Samples: []searchBatchSample{
+				{Repository: "org/a", Path: "config.yml", LineNumber: 3, Line: "key: AKIA1"},
+				{Repository: "org/a", Path: "config.yml", LineNumber: 6, Line: "key: AKIA2"},
+				{Repository: "org/b"},
+			},
+		},
+		{
+			Name: "keys, one sample", Query: "AKIA", MatchCount: 10, LimitHit: true,
+			Samples: []searchBatchSample{
+				{Repository: "org/a", Path: "config.yml", LineNumber: 3, Line: "key: AKIA1"},
+			},
+		},
+		{Name: "broken", Query: "broken(", Error: "invalid query", Samples: []searchBatchSample{}},
+	}
+	if diff := cmp.Diff(want, report.Queries, cmpopts.IgnoreFields(searchBatchQueryReport{}, "DurationMs")); diff != "" {
+		t.Errorf("wrong report (-want +have):\n%s", diff)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"regexp"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/searchfilter"
+)
+
+// searchFilterFlags are the flags of 'src search' that drop results
+// client-side, after they're returned by the instance.
+type searchFilterFlags struct {
+	includePath, excludePath *string
+	maxFileSize              byteSizeFlag
+	skipBinary               *bool
+	skipGenerated            *bool
+}
+
+func newSearchFilterFlags(flagSet *flag.FlagSet) *searchFilterFlags {
+	f := &searchFilterFlags{
+		includePath:   flagSet.String("include-path", "", "Only show file results whose path matches this regular expression, filtered client-side."),
+		excludePath:   flagSet.String("exclude-path", "", "Drop file results whose path matches this regular expression, filtered client-side."),
+		skipBinary:    flagSet.Bool("skip-binary", false, "Drop results in files that look binary."),
+		skipGenerated: flagSet.Bool("skip-generated", false, "Drop results in vendored, minified, lock and generated files, recognized by their path and content like GitHub's linguist does."),
+	}
+	flagSet.Var(&f.maxFileSize, "max-file-size", `Drop results in files larger than this size, such as "100KB". Not supported together with -stream.`)
+	return f
+}
+
+// filter returns the filter of the flags, or nil if none of them is set.
+func (f *searchFilterFlags) filter() (*searchfilter.Filter, error) {
+	filter := &searchfilter.Filter{
+		MaxSize:       int(f.maxFileSize.bytes),
+		SkipBinary:    *f.skipBinary,
+		SkipGenerated: *f.skipGenerated,
+	}
+	var err error
+	if *f.includePath != "" {
+		if filter.Include, err = regexp.Compile(*f.includePath); err != nil {
+			return nil, errors.Wrap(err, "invalid -include-path")
+		}
+	}
+	if *f.excludePath != "" {
+		if filter.Exclude, err = regexp.Compile(*f.excludePath); err != nil {
+			return nil, errors.Wrap(err, "invalid -exclude-path")
+		}
+	}
+	if !filter.IsSet() {
+		return nil, nil
+	}
+	return filter, nil
+}
+
+// filterSearchResults returns the results of the GraphQL search API that the
+// filter keeps, and the number of dropped results. Results that aren't file
+// matches are always kept.
+func filterSearchResults(f *searchfilter.Filter, results []map[string]interface{}) ([]map[string]interface{}, int) {
+	if !f.IsSet() {
+		return results, 0
+	}
+	kept := make([]map[string]interface{}, 0, len(results))
+	for _, r := range results {
+		if r["__typename"] == "FileMatch" {
+			file, _ := r["file"].(map[string]interface{})
+			path, _ := file["path"].(string)
+			content, _ := file["content"].(string)
+			if !f.KeepFile(path, content) {
+				continue
+			}
+		}
+		kept = append(kept, r)
+	}
+	return kept, len(results) - len(kept)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestFilterSearchResults(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := newSearchFilterFlags(flagSet)
+	if err := flagSet.Parse([]string{"-exclude-path", `_test\.go$`, "-max-file-size", "1KB"}); err != nil {
+		t.Fatal(err)
+	}
+	filter, err := flags.filter()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileMatch := func(path, content string) map[string]interface{} {
+		return map[string]interface{}{
+			"__typename": "FileMatch",
+			"file":       map[string]interface{}{"path": path, "content": content},
+		}
+	}
+	results := []map[string]interface{}{
+		fileMatch("main.go", "package main"),
+		fileMatch("main_test.go", "package main"),
+		fileMatch("data.go", string(make([]byte, 2000))),
+		{"__typename": "Repository", "name": "org/repo"},
+	}
+
+	kept, dropped := filterSearchResults(filter, results)
+	if dropped != 2 || len(kept) != 2 || kept[0]["file"].(map[string]interface{})["path"] != "main.go" {
+		t.Errorf("wrong results: kept %v, dropped %d", kept, dropped)
+	}
+
+	if f, err := newSearchFilterFlags(flag.NewFlagSet("test", flag.ContinueOnError)).filter(); err != nil || f != nil {
+		t.Errorf("want no filter without flags, have %v, %v", f, err)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// dedupeForkResults groups the file and commit matches that are identical
+// across a repository and its forks, so that audits of instances that index
+// many forks aren't buried in copies of the same match. Each group is reported
+// once, by the match in a repository that isn't a fork if there is one, and the
+// first match otherwise. The names of the repositories of the other matches
+// are added to it as "forks". It returns the remaining results, in their
+// original order, and the number of matches that were dropped.
+func dedupeForkResults(results []map[string]interface{}) ([]map[string]interface{}, int) {
+	type group struct {
+		canonical int
+		forks     []string
+	}
+	groups := map[string]*group{}
+	keep := make([]bool, len(results))
+	dropped := 0
+
+	for i, r := range results {
+		key, ok := forkDedupeKey(r)
+		if !ok {
+			keep[i] = true
+			continue
+		}
+		g, ok := groups[key]
+		if !ok {
+			groups[key] = &group{canonical: i}
+			keep[i] = true
+			continue
+		}
+
+		dropped++
+		current := results[g.canonical]
+		if resultRepoIsFork(current) && !resultRepoIsFork(r) {
+			// Prefer the match in the upstream repository.
+			keep[g.canonical], keep[i] = false, true
+			g.forks = append(g.forks, resultRepoName(current))
+			g.canonical = i
+		} else {
+			g.forks = append(g.forks, resultRepoName(r))
+		}
+	}
+
+	for _, g := range groups {
+		if len(g.forks) > 0 {
+			sort.Strings(g.forks)
+			results[g.canonical]["forks"] = g.forks
+		}
+	}
+
+	deduped := make([]map[string]interface{}, 0, len(results)-dropped)
+	for i, r := range results {
+		if keep[i] {
+			deduped = append(deduped, r)
+		}
+	}
+	return deduped, dropped
+}
+
+// forkDedupeKey returns the key that identical matches in a repository and
+// its forks share: the path and the matching lines of file matches, and the
+// commit of commit matches. Other results aren't deduplicated.
+func forkDedupeKey(r map[string]interface{}) (string, bool) {
+	switch r["__typename"] {
+	case "FileMatch":
+		file, _ := r["file"].(map[string]interface{})
+		path, _ := file["path"].(string)
+		var b strings.Builder
+		b.WriteString("file\x00" + path)
+		lineMatches, _ := r["lineMatches"].([]interface{})
+		for _, raw := range lineMatches {
+			m, _ := raw.(map[string]interface{})
+			line, _ := m["lineNumber"].(float64)
+			preview, _ := m["preview"].(string)
+			b.WriteString("\x00" + strconv.Itoa(int(line)) + ":" + preview)
+		}
+		return b.String(), true
+	case "CommitSearchResult":
+		commit, _ := r["commit"].(map[string]interface{})
+		oid, _ := commit["oid"].(string)
+		if oid == "" {
+			return "", false
+		}
+		return "commit\x00" + oid, true
+	}
+	return "", false
+}
+
+func resultRepository(r map[string]interface{}) map[string]interface{} {
+	if r["__typename"] == "CommitSearchResult" {
+		commit, _ := r["commit"].(map[string]interface{})
+		repo, _ := commit["repository"].(map[string]interface{})
+		return repo
+	}
+	repo, _ := r["repository"].(map[string]interface{})
+	return repo
+}
+
+func resultRepoName(r map[string]interface{}) string {
+	name, _ := resultRepository(r)["name"].(string)
+	return name
+}
+
+func resultRepoIsFork(r map[string]interface{}) bool {
+	isFork, _ := resultRepository(r)["isFork"].(bool)
+	return isFork
+}
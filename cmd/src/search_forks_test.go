@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDedupeForkResults(t *testing.T) {
+	fileMatch := func(repo string, isFork bool, path string, lines ...string) map[string]interface{} {
+		var lineMatches []interface{}
+		for i, l := range lines {
+			lineMatches = append(lineMatches, map[string]interface{}{"lineNumber": float64(i), "preview": l})
+		}
+		return map[string]interface{}{
+			"__typename":  "FileMatch",
+			"repository":  map[string]interface{}{"name": repo, "isFork": isFork},
+			"file":        map[string]interface{}{"path": path},
+			"lineMatches": lineMatches,
+		}
+	}
+	commitMatch := func(repo string, isFork bool, oid string) map[string]interface{} {
+		return map[string]interface{}{
+			"__typename": "CommitSearchResult",
+			"commit": map[string]interface{}{
+				"oid":        oid,
+				"repository": map[string]interface{}{"name": repo, "isFork": isFork},
+			},
+		}
+	}
+
+	results := []map[string]interface{}{
+		// The fork is ranked first, but the upstream match is kept.
+		fileMatch("github.com/b/lib", true, "main.go", "secret := 1"),
+		fileMatch("github.com/a/lib", false, "main.go", "secret := 1"),
+		fileMatch("github.com/c/lib", true, "main.go", "secret := 1"),
+		// The match differs in this fork, so it's kept.
+		fileMatch("github.com/d/lib", true, "main.go", "secret := 2"),
+		fileMatch("github.com/a/lib", false, "other.go", "secret := 1"),
+		commitMatch("github.com/a/lib", false, "abc"),
+		commitMatch("github.com/b/lib", true, "abc"),
+		{"__typename": "Repository", "name": "github.com/a/lib"},
+		{"__typename": "Repository", "name": "github.com/b/lib"},
+	}
+
+	have, dropped := dedupeForkResults(results)
+	if dropped != 3 {
+		t.Errorf("wrong number of dropped results: have %d, want 3", dropped)
+	}
+
+	type result struct {
+		Repo, Type string
+		Forks      []string
+	}
+	var summary []result
+	for _, r := range have {
+		name := resultRepoName(r)
+		if name == "" {
+			name, _ = r["name"].(string)
+		}
+		forks, _ := r["forks"].([]string)
+		summary = append(summary, result{Repo: name, Type: r["__typename"].(string), Forks: forks})
+	}
+	want := []result{
+		{Repo: "github.com/a/lib", Type: "FileMatch", Forks: []string{"github.com/b/lib", "github.com/c/lib"}},
+		{Repo: "github.com/d/lib", Type: "FileMatch"},
+		{Repo: "github.com/a/lib", Type: "FileMatch"},
+		{Repo: "github.com/a/lib", Type: "CommitSearchResult", Forks: []string{"github.com/b/lib"}},
+		{Repo: "github.com/a/lib", Type: "Repository"},
+		{Repo: "github.com/b/lib", Type: "Repository"},
+	}
+	if diff := cmp.Diff(want, summary); diff != "" {
+		t.Errorf("wrong results (-want +have):\n%s", diff)
+	}
+}
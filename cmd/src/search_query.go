@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/sourcegraph/src-cli/internal/searchquery"
+)
+
+// searchQueryFlags are the flags of 'src search' that are compiled into the
+// search query.
+type searchQueryFlags struct {
+	repos, files, langs, authors stringSliceFlag
+
+	after, before, typ, patternType *string
+	caseSensitive                   *bool
+	count                           *int
+}
+
+func newSearchQueryFlags(flagSet *flag.FlagSet) *searchQueryFlags {
+	f := &searchQueryFlags{
+		after:         flagSet.String("after", "", `Only search commits and diffs after this time, such as "2 weeks ago" or "2021-10-01".`),
+		before:        flagSet.String("before", "", "Only search commits and diffs before this time."),
+		typ:           flagSet.String("type", "", "The type of results: repo, path, file, commit, diff or symbol."),
+		patternType:   flagSet.String("patterntype", "", "How the search pattern is interpreted: literal, regexp or structural."),
+		caseSensitive: flagSet.Bool("case-sensitive", false, "Match the search pattern case sensitively."),
+		count:         flagSet.Int("count", 0, "The maximum number of results."),
+	}
+	flagSet.Var(&f.repos, "repo", "Only search the repository with this exact name. Can be given multiple times.")
+	flagSet.Var(&f.files, "file", "Only search files whose path contains this string. Can be given multiple times.")
+	flagSet.Var(&f.langs, "lang", "Only search files in this language. Can be given multiple times.")
+	flagSet.Var(&f.authors, "author", "Only search commits and diffs whose author name or email contains this string. Can be given multiple times.")
+	return f
+}
+
+// isSet returns true if any of the query flags is set, in which case the query
+// argument of 'src search' is optional.
+func (f *searchQueryFlags) isSet() bool {
+	o := f.options()
+	return len(o.Repos) > 0 || len(o.Files) > 0 || len(o.Langs) > 0 || len(o.Authors) > 0 ||
+		o.After != "" || o.Before != "" || o.Type != "" || o.PatternType != "" || o.CaseSensitive || o.Count != 0
+}
+
+func (f *searchQueryFlags) options() searchquery.Options {
+	return searchquery.Options{
+		Repos:         f.repos,
+		Files:         f.files,
+		Langs:         f.langs,
+		Authors:       f.authors,
+		After:         *f.after,
+		Before:        *f.before,
+		Type:          *f.typ,
+		PatternType:   *f.patternType,
+		CaseSensitive: *f.caseSensitive,
+		Count:         *f.count,
+	}
+}
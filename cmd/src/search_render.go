@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/kballard/go-shellquote"
+	"golang.org/x/net/html"
+)
+
+// searchRenderOptions configures the rendering of file matches by
+// 'src search'. It's set from the command line flags before the results are
+// rendered.
+var searchRenderOptions struct {
+	// ContextBefore and ContextAfter are the number of lines shown before and
+	// after each matching line.
+	ContextBefore, ContextAfter int
+	// Highlight is true if the matching lines are syntax highlighted, using
+	// the highlighted HTML returned by the API.
+	Highlight bool
+	// Hyperlinks is true if links to the web UI are wrapped in OSC 8 escape
+	// sequences, so that terminals render them as hyperlinks.
+	Hyperlinks bool
+}
+
+// pagerCommand returns the command that paginates the output of 'src search',
+// based on the value of $PAGER. It's nil if paging is disabled by setting
+// $PAGER to "cat" or an empty string.
+func pagerCommand(pager string, set bool) (*exec.Cmd, error) {
+	if !set {
+		pager = "less -R"
+	}
+	args, err := shellquote.Split(pager)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid $PAGER %q", pager)
+	}
+	if len(args) == 0 || args[0] == "cat" {
+		return nil, nil
+	}
+	return exec.Command(args[0], args[1:]...), nil
+}
+
+// hyperlink wraps text in an OSC 8 escape sequence linking to url, if
+// hyperlinks are enabled.
+func hyperlink(url, text string) string {
+	if !searchRenderOptions.Hyperlinks || colorDisabled || url == "" {
+		return text
+	}
+	return "\033]8;;" + url + "\033\\" + text + "\033]8;;\033\\"
+}
+
+// searchUseFileMatchRenderer returns true if file matches are rendered by
+// searchRenderFileMatch, instead of by showing the preview of each line
+// match.
+func searchUseFileMatchRenderer() bool {
+	return searchRenderOptions.ContextBefore > 0 || searchRenderOptions.ContextAfter > 0 || (searchRenderOptions.Highlight && !colorDisabled)
+}
+
+// lineMatch is a matching line of a file match.
+type lineMatch struct {
+	// line is the 0-indexed line number.
+	line int
+	// ranges are the character offsets and lengths of the matches.
+	ranges [][2]int
+}
+
+// searchRenderFileMatch renders the line matches of a FileMatch result with
+// their surrounding context lines and, if available, syntax highlighting.
+func searchRenderFileMatch(fileMatch map[string]interface{}) string {
+	file, _ := fileMatch["file"].(map[string]interface{})
+	content, _ := file["content"].(string)
+
+	var syntax [][]syntaxSegment
+	if h, ok := file["highlight"].(map[string]interface{}); ok && searchRenderOptions.Highlight && !colorDisabled {
+		if aborted, _ := h["aborted"].(bool); !aborted {
+			code, _ := h["html"].(string)
+			syntax = parseHighlightedHTML(code)
+		}
+	}
+
+	var matches []lineMatch
+	rawMatches, _ := fileMatch["lineMatches"].([]interface{})
+	for _, raw := range rawMatches {
+		m := raw.(map[string]interface{})
+		lm := lineMatch{line: int(m["lineNumber"].(float64))}
+		for _, ol := range m["offsetAndLengths"].([]interface{}) {
+			ol := ol.([]interface{})
+			lm.ranges = append(lm.ranges, [2]int{int(ol[0].(float64)), int(ol[1].(float64))})
+		}
+		matches = append(matches, lm)
+	}
+
+	return renderFileMatchLines(content, syntax, matches, searchRenderOptions.ContextBefore, searchRenderOptions.ContextAfter)
+}
+
+// renderFileMatchLines renders the matching lines of the file content, with
+// before and after lines of context around each of them. Gaps between the
+// shown lines are marked with a separator. syntax holds the highlighted
+// segments of each line and may be nil.
+func renderFileMatchLines(content string, syntax [][]syntaxSegment, matches []lineMatch, before, after int) string {
+	lines := strings.Split(content, "\n")
+
+	ranges := map[int][][2]int{}
+	shown := map[int]bool{}
+	for _, m := range matches {
+		if m.line < 0 || m.line >= len(lines) {
+			continue
+		}
+		ranges[m.line] = append(ranges[m.line], m.ranges...)
+		for l := m.line - before; l <= m.line+after; l++ {
+			if l >= 0 && l < len(lines) {
+				shown[l] = true
+			}
+		}
+	}
+
+	numbers := make([]int, 0, len(shown))
+	for l := range shown {
+		numbers = append(numbers, l)
+	}
+	sort.Ints(numbers)
+
+	var b strings.Builder
+	for i, l := range numbers {
+		if i > 0 && l != numbers[i-1]+1 {
+			b.WriteString(ansiColors["search-border"] + "  ------------------------------------------------------------------------------\n" + ansiColors["nc"])
+		}
+
+		separator := " :  "
+		if _, ok := ranges[l]; ok {
+			separator = " |  "
+		}
+		b.WriteString("  " + ansiColors["search-line-numbers"] + fmt.Sprintf("%6d", l+1) + ansiColors["nc"])
+		b.WriteString(ansiColors["search-border"] + separator + ansiColors["nc"])
+
+		segments := []syntaxSegment{{text: lines[l]}}
+		if l < len(syntax) {
+			segments = syntax[l]
+		}
+		b.WriteString(colorLine(segments, ranges[l]))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// syntaxSegment is a run of text of a highlighted line that shares the same
+// color.
+type syntaxSegment struct {
+	text string
+	// color is the ANSI escape sequence of the color, or empty for the
+	// default color.
+	color string
+}
+
+// colorLine renders the segments of a line, highlighting the characters in
+// the given match ranges.
+func colorLine(segments []syntaxSegment, matches [][2]int) string {
+	inMatch := func(i int) bool {
+		for _, m := range matches {
+			if i >= m[0] && i < m[0]+m[1] {
+				return true
+			}
+		}
+		return false
+	}
+
+	var (
+		b        strings.Builder
+		i        int
+		matching bool
+	)
+	for _, s := range segments {
+		if !matching {
+			b.WriteString(s.color)
+		}
+		for _, r := range s.text {
+			if m := inMatch(i); m != matching {
+				if m {
+					b.WriteString(ansiColors["search-match"])
+				} else {
+					b.WriteString(ansiColors["nc"] + s.color)
+				}
+				matching = m
+			}
+			b.WriteRune(r)
+			i++
+		}
+		if s.color != "" && !matching {
+			b.WriteString(ansiColors["nc"])
+		}
+	}
+	if matching {
+		b.WriteString(ansiColors["nc"])
+	}
+	return b.String()
+}
+
+// parseHighlightedHTML converts the highlighted HTML table of a file returned
+// by the API into colored segments, one slice per line. Colors are taken from
+// the inline styles of the spans and rendered as 24-bit ANSI colors.
+func parseHighlightedHTML(code string) [][]syntaxSegment {
+	var (
+		lines  [][]syntaxSegment
+		colors []string
+		inCode bool
+	)
+
+	z := html.NewTokenizer(strings.NewReader(code))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return lines
+
+		case html.StartTagToken:
+			tn, hasAttr := z.TagName()
+			attrs := map[string]string{}
+			for more := hasAttr; more; {
+				var k, v []byte
+				k, v, more = z.TagAttr()
+				attrs[string(k)] = string(v)
+			}
+			switch string(tn) {
+			case "td":
+				inCode = attrs["class"] == "code"
+				if inCode {
+					lines = append(lines, nil)
+					colors = colors[:0]
+				}
+			case "span":
+				colors = append(colors, styleColor(attrs["style"]))
+			}
+
+		case html.EndTagToken:
+			tn, _ := z.TagName()
+			switch string(tn) {
+			case "td":
+				inCode = false
+			case "span":
+				if len(colors) > 0 {
+					colors = colors[:len(colors)-1]
+				}
+			}
+
+		case html.TextToken:
+			if !inCode {
+				continue
+			}
+			text := strings.TrimRight(string(z.Text()), "\n")
+			if text == "" {
+				continue
+			}
+			var color string
+			for i := len(colors) - 1; i >= 0; i-- {
+				if colors[i] != "" {
+					color = colors[i]
+					break
+				}
+			}
+			lines[len(lines)-1] = append(lines[len(lines)-1], syntaxSegment{text: text, color: color})
+		}
+	}
+}
+
+// styleColor returns the ANSI escape sequence of the "color: #rrggbb"
+// declaration of an inline style, or an empty string if there is none.
+func styleColor(style string) string {
+	for _, decl := range strings.Split(style, ";") {
+		kv := strings.SplitN(decl, ":", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) != "color" {
+			continue
+		}
+		v := strings.TrimPrefix(strings.TrimSpace(kv[1]), "#")
+		if len(v) != 6 {
+			return ""
+		}
+		rgb, err := strconv.ParseUint(v, 16, 32)
+		if err != nil {
+			return ""
+		}
+		return fmt.Sprintf("\033[38;2;%d;%d;%dm", rgb>>16, (rgb>>8)&0xff, rgb&0xff)
+	}
+	return ""
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPagerCommand(t *testing.T) {
+	for _, tc := range []struct {
+		pager string
+		set   bool
+		want  []string
+	}{
+		{"", false, []string{"less", "-R"}},
+		{"", true, nil},
+		{"cat", true, nil},
+		{"most -s", true, []string{"most", "-s"}},
+		{"'my pager' --color", true, []string{"my pager", "--color"}},
+	} {
+		cmd, err := pagerCommand(tc.pager, tc.set)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var have []string
+		if cmd != nil {
+			have = cmd.Args
+		}
+		if diff := cmp.Diff(tc.want, have); diff != "" {
+			t.Errorf("wrong command for PAGER=%q (-want +have):\n%s", tc.pager, diff)
+		}
+	}
+}
+
+func TestRenderFileMatchLines(t *testing.T) {
+	content := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello\")\n}\n\nfunc other() {\n\tfmt.Println(\"bye\")\n}"
+	matches := []lineMatch{
+		{line: 5, ranges: [][2]int{{1, 3}}},
+		{line: 9, ranges: [][2]int{{1, 3}}},
+	}
+
+	match, nc := ansiColors["search-match"], ansiColors["nc"]
+	border, numbers := ansiColors["search-border"], ansiColors["search-line-numbers"]
+	line := func(n, sep, text string) string {
+		return "  " + numbers + n + nc + border + sep + nc + text + "\n"
+	}
+
+	have := renderFileMatchLines(content, nil, matches, 1, 0)
+	want := line("     5", " :  ", "func main() {") +
+		line("     6", " |  ", "\t"+match+"fmt"+nc+".Println(\"hello\")") +
+		border + "  ------------------------------------------------------------------------------\n" + nc +
+		line("     9", " :  ", "func other() {") +
+		line("    10", " |  ", "\t"+match+"fmt"+nc+".Println(\"bye\")")
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Errorf("wrong output (-want +have):\n%s", diff)
+	}
+}
+
+func TestParseHighlightedHTML(t *testing.T) {
+	code := `<table><tbody>` +
+		`<tr><td class="line" data-line="1"></td><td class="code"><div><span style="color:#b48ead;">func</span><span style="color:#c0c5ce;"> main() &lt;</span></div></td></tr>` +
+		`<tr><td class="line" data-line="2"></td><td class="code"><div><span>x</span></div></td></tr>` +
+		`</tbody></table>`
+
+	want := [][]syntaxSegment{
+		{
+			{text: "func", color: "\033[38;2;180;142;173m"},
+			{text: " main() <", color: "\033[38;2;192;197;206m"},
+		},
+		{
+			{text: "x"},
+		},
+	}
+	if diff := cmp.Diff(want, parseHighlightedHTML(code), cmp.AllowUnexported(syntaxSegment{})); diff != "" {
+		t.Errorf("wrong segments (-want +have):\n%s", diff)
+	}
+
+	match, nc := ansiColors["search-match"], ansiColors["nc"]
+	have := colorLine(want[0], [][2]int{{5, 4}})
+	wantLine := "\033[38;2;180;142;173mfunc" + nc + "\033[38;2;192;197;206m " + match + "main" + nc + "\033[38;2;192;197;206m() <" + nc
+	if diff := cmp.Diff(wantLine, have); diff != "" {
+		t.Errorf("wrong colored line (-want +have):\n%s", diff)
+	}
+}
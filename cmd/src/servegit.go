@@ -17,10 +17,13 @@ func init() {
 		fmt.Fprintf(flag.CommandLine.Output(), `'src serve-git' serves your local git repositories over HTTP for Sourcegraph to pull.
 
 USAGE
-  src [-v] serve-git [-list] [-addr :3434] [path/to/dir]
+  src [-v] serve-git [-list] [-addr :3434] [-depth 0] [path/to/dir]
 
 By default 'src serve-git' will recursively serve your current directory on the address ':3434'.
 
+Working trees, bare repositories and the submodules of working trees are served. Symlinked
+directories are followed. '-depth' limits how many directories deep repositories are searched for.
+
 'src serve-git -list' will not start up the server. Instead it will write to stdout a list of
 repository names it would serve.
 
@@ -28,8 +31,10 @@ Documentation at https://docs.sourcegraph.com/admin/external_service/src_serve_g
 `)
 	}
 	var (
-		addrFlag = flagSet.String("addr", ":3434", "Address on which to serve (end with : for unused port)")
-		listFlag = flagSet.Bool("list", false, "list found repository names")
+		addrFlag        = flagSet.String("addr", ":3434", "Address on which to serve (end with : for unused port)")
+		listFlag        = flagSet.Bool("list", false, "list found repository names")
+		depthFlag       = flagSet.Int("depth", 0, "How many directories deep to search for repositories (0 for no limit)")
+		metricsAddrFlag = addMetricsAddrFlag(flagSet)
 	)
 
 	handler := func(args []string) error {
@@ -61,6 +66,7 @@ Documentation at https://docs.sourcegraph.com/admin/external_service/src_serve_g
 		s := &servegit.Serve{
 			Addr:  *addrFlag,
 			Root:  repoDir,
+			Depth: *depthFlag,
 			Info:  log.New(os.Stderr, "serve-git: ", log.LstdFlags),
 			Debug: dbug,
 		}
@@ -76,6 +82,12 @@ Documentation at https://docs.sourcegraph.com/admin/external_service/src_serve_g
 			return nil
 		}
 
+		stopMetrics, err := serveMetrics(*metricsAddrFlag)
+		if err != nil {
+			return err
+		}
+		defer stopMetrics()
+
 		return s.Start()
 	}
 
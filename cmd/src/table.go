@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+// tableColumn is a column of the table output of list commands. Its values
+// are rendered from the listed items with a template, using the same syntax
+// and functions as -f.
+type tableColumn struct {
+	name     string
+	template string
+}
+
+// tableFlags are the flags shared by list commands to print their results as
+// a table, instead of once per item with the -f template.
+type tableFlags struct {
+	columns  *string
+	sort     *string
+	noHeader *bool
+	tsv      *bool
+
+	available []tableColumn
+	defaults  []string
+}
+
+// newTableFlags adds the table flags to flagSet. available are the columns
+// the items can be printed with, and defaults the names of the columns that
+// are printed if -columns isn't given.
+func newTableFlags(flagSet *flag.FlagSet, available []tableColumn, defaults ...string) *tableFlags {
+	names := make([]string, len(available))
+	for i, c := range available {
+		names[i] = c.name
+	}
+	return &tableFlags{
+		columns:   flagSet.String("columns", "", fmt.Sprintf("Print a table with these comma-separated columns instead of using -f. Available columns: %s. (default %q)", strings.Join(names, ", "), strings.Join(defaults, ","))),
+		sort:      flagSet.String("sort", "", "Print a table sorted by this column. Prefix the column with - to sort in descending order."),
+		noHeader:  flagSet.Bool("no-header", false, "Print a table without the header line."),
+		tsv:       flagSet.Bool("tsv", false, "Print a table as tab-separated values, for awk and cut."),
+		available: available,
+		defaults:  defaults,
+	}
+}
+
+// table returns the table to add the listed items to, or nil if none of the
+// table flags is set and the items are to be printed with the -f template.
+// It must be called after flagSet was parsed.
+func (f *tableFlags) table(flagSet *flag.FlagSet) (*table, error) {
+	var set, formatSet bool
+	flagSet.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "columns", "sort", "no-header", "tsv":
+			set = true
+		case "f":
+			formatSet = true
+		}
+	})
+	if !set {
+		return nil, nil
+	}
+	if formatSet {
+		return nil, cmderrors.Usage("-f cannot be combined with -columns, -sort, -no-header or -tsv")
+	}
+
+	names := f.defaults
+	if *f.columns != "" {
+		names = nil
+		for _, name := range strings.Split(*f.columns, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			return nil, cmderrors.Usage("-columns must name at least one column")
+		}
+	}
+
+	t := &table{header: !*f.noHeader, tsv: *f.tsv, sortColumn: -1}
+	for _, name := range names {
+		c, err := f.column(name)
+		if err != nil {
+			return nil, err
+		}
+		t.columns = append(t.columns, c)
+	}
+
+	if sortBy := *f.sort; sortBy != "" {
+		if strings.HasPrefix(sortBy, "-") {
+			t.descending = true
+			sortBy = sortBy[1:]
+		}
+		c, err := f.column(sortBy)
+		if err != nil {
+			return nil, err
+		}
+		// The sort column is rendered like the others, but only printed if
+		// it was selected.
+		t.sortColumn = len(t.columns)
+		t.columns = append(t.columns, c)
+	}
+	return t, nil
+}
+
+// column returns the parsed column of the given name.
+func (f *tableFlags) column(name string) (parsedColumn, error) {
+	var names []string
+	for _, c := range f.available {
+		if c.name == name {
+			tmpl, err := parseTemplate(c.template)
+			if err != nil {
+				return parsedColumn{}, err
+			}
+			return parsedColumn{name: c.name, tmpl: tmpl}, nil
+		}
+		names = append(names, c.name)
+	}
+	return parsedColumn{}, cmderrors.Usagef("unknown column %q, available columns are: %s", name, strings.Join(names, ", "))
+}
+
+type parsedColumn struct {
+	name string
+	tmpl *template.Template
+}
+
+// table collects the rows of the table output of a list command.
+type table struct {
+	columns    []parsedColumn
+	header     bool
+	tsv        bool
+	sortColumn int
+	descending bool
+
+	rows [][]string
+}
+
+// add renders the columns of item as a row of the table.
+func (t *table) add(item interface{}) error {
+	row := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		var buf bytes.Buffer
+		if err := c.tmpl.Execute(&buf, item); err != nil {
+			return err
+		}
+		row[i] = buf.String()
+	}
+	t.rows = append(t.rows, row)
+	return nil
+}
+
+// write sorts the rows and writes the table to w, aligned in columns, or as
+// tab-separated values with -tsv. Tabs and line breaks in the values are
+// escaped as \t and \n, so that every row is a single line.
+func (t *table) write(w io.Writer) error {
+	printed := len(t.columns)
+	if t.sortColumn >= 0 {
+		printed = t.sortColumn
+		sort.SliceStable(t.rows, func(i, j int) bool {
+			a, b := t.rows[i][t.sortColumn], t.rows[j][t.sortColumn]
+			if t.descending {
+				a, b = b, a
+			}
+			return lessTableValue(a, b)
+		})
+	}
+
+	out := w
+	var tw *tabwriter.Writer
+	if !t.tsv {
+		tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		out = tw
+	}
+
+	writeRow := func(values []string) error {
+		escaped := make([]string, len(values))
+		for i, v := range values {
+			escaped[i] = tableValueEscaper.Replace(v)
+		}
+		_, err := fmt.Fprintln(out, strings.Join(escaped, "\t"))
+		return err
+	}
+
+	if t.header {
+		names := make([]string, printed)
+		for i, c := range t.columns[:printed] {
+			names[i] = strings.ToUpper(c.name)
+		}
+		if err := writeRow(names); err != nil {
+			return err
+		}
+	}
+	for _, row := range t.rows {
+		if err := writeRow(row[:printed]); err != nil {
+			return err
+		}
+	}
+
+	if tw != nil {
+		return tw.Flush()
+	}
+	return nil
+}
+
+var tableValueEscaper = strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r")
+
+// lessTableValue compares two values of a column numerically if both are
+// numbers, and as strings otherwise.
+func lessTableValue(a, b string) bool {
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		return x < y
+	}
+	return a < b
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+var testTableColumns = []tableColumn{
+	{name: "name", template: "{{.Name}}"},
+	{name: "size", template: "{{.Size}}"},
+	{name: "description", template: "{{.Description}}"},
+}
+
+type testTableItem struct {
+	Name        string
+	Size        int
+	Description string
+}
+
+var testTableItems = []testTableItem{
+	{Name: "b", Size: 10, Description: "tabs\tand\nlines"},
+	{Name: "a", Size: 9},
+	{Name: "c", Size: 100, Description: "c"},
+}
+
+func TestTable(t *testing.T) {
+	for name, tc := range map[string]struct {
+		args []string
+		want string
+	}{
+		"default columns": {
+			args: []string{"-no-header"},
+			want: "b  10\na  9\nc  100\n",
+		},
+		"columns and header": {
+			args: []string{"-columns", "size, name"},
+			want: "SIZE  NAME\n10    b\n9     a\n100   c\n",
+		},
+		"sort by string": {
+			args: []string{"-sort", "name", "-no-header"},
+			want: "a  9\nb  10\nc  100\n",
+		},
+		"sort numerically in descending order by a column that isn't printed": {
+			args: []string{"-columns", "name", "-sort", "-size"},
+			want: "NAME\nc\nb\na\n",
+		},
+		"tsv": {
+			args: []string{"-columns", "name,description", "-tsv"},
+			want: "NAME\tDESCRIPTION\nb\ttabs\\tand\\nlines\na\t\nc\tc\n",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+			flags := newTableFlags(flagSet, testTableColumns, "name", "size")
+			if err := flagSet.Parse(tc.args); err != nil {
+				t.Fatal(err)
+			}
+
+			tbl, err := flags.table(flagSet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, item := range testTableItems {
+				if err := tbl.add(item); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var buf bytes.Buffer
+			if err := tbl.write(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, buf.String()); diff != "" {
+				t.Errorf("wrong output (-want +have):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTableFlags(t *testing.T) {
+	parse := func(args ...string) (*table, error) {
+		flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+		flagSet.SetOutput(io.Discard)
+		flagSet.String("f", "{{.Name}}", "")
+		flags := newTableFlags(flagSet, testTableColumns, "name")
+		if err := flagSet.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		return flags.table(flagSet)
+	}
+
+	if tbl, err := parse("-f", "{{.Size}}"); tbl != nil || err != nil {
+		t.Errorf("want no table without table flags, have %v, %v", tbl, err)
+	}
+
+	for name, args := range map[string][]string{
+		"-f and table flags": {"-f", "{{.Size}}", "-tsv"},
+		"unknown column":     {"-columns", "name,color"},
+		"unknown sort":       {"-sort", "-color"},
+		"no columns":         {"-columns", " , "},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parse(args...); cmderrors.Classify(err) != cmderrors.KindUsage {
+				t.Errorf("want usage error, have %v", err)
+			}
+		})
+	}
+}
+
+// TestListColumns checks that the columns of the list commands render the
+// types they list, including their nil pointers.
+func TestListColumns(t *testing.T) {
+	now := time.Now()
+	for name, tc := range map[string]struct {
+		columns []tableColumn
+		item    interface{}
+	}{
+		"repos":            {repositoryColumns, Repository{UpdatedAt: &now}},
+		"repos nil":        {repositoryColumns, Repository{}},
+		"users":            {userColumns, User{Emails: []UserEmail{{Email: "a@example.com"}, {Email: "b@example.com"}}}},
+		"orgs":             {orgColumns, Org{}},
+		"extensions":       {extensionColumns, &Extension{}},
+		"contexts":         {searchContextColumns, &SearchContext{}},
+		"cody context":     {repoEmbeddingJobColumns, &RepoEmbeddingJob{StartedAt: &now}},
+		"cody context nil": {repoEmbeddingJobColumns, &RepoEmbeddingJob{}},
+		"extsvc":           {externalServiceColumns, map[string]interface{}{"id": "RXh0", "kind": "GITHUB", "displayName": "GitHub", "createdAt": "2021-10-01T00:00:00Z", "updatedAt": "2021-10-02T00:00:00Z"}},
+		"config":           {settingsSubjectColumns, SettingsSubject{LatestSettings: &Settings{Author: &User{}}}},
+		"config nil":       {settingsSubjectColumns, SettingsSubject{}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+			var names []string
+			for _, c := range tc.columns {
+				names = append(names, c.name)
+			}
+			flags := newTableFlags(flagSet, tc.columns)
+			if err := flagSet.Parse([]string{"-columns", strings.Join(names, ",")}); err != nil {
+				t.Fatal(err)
+			}
+			tbl, err := flags.table(flagSet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tbl.add(tc.item); err != nil {
+				t.Fatal(err)
+			}
+			for i, value := range tbl.rows[0] {
+				if value == "<nil>" || value == "<no value>" {
+					t.Errorf("column %s renders %s", tc.columns[i].name, value)
+				}
+			}
+		})
+	}
+}
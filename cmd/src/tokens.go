@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+var tokensCommands commander
+
+func init() {
+	usage := `'src tokens' manages access tokens on a Sourcegraph instance.
+
+Usage:
+
+	src tokens command [command options]
+
+The commands are:
+
+	mint       creates a short-lived access token with narrow scopes, such as
+	           for CI jobs
+
+Use "src tokens [command] -h" for more information about a command.
+`
+
+	flagSet := flag.NewFlagSet("tokens", flag.ExitOnError)
+	handler := func(args []string) error {
+		tokensCommands.run(flagSet, "src tokens", usage, args)
+		return nil
+	}
+
+	// Register the command.
+	commands = append(commands, &command{
+		flagSet: flagSet,
+		aliases: []string{"token"},
+		handler: handler,
+		usageFunc: func() {
+			fmt.Println(usage)
+		},
+	})
+}
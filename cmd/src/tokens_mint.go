@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+// tokenScopes are the scopes of Sourcegraph access tokens, by name, with what a
+// token with the scope can be used for.
+var tokenScopes = map[string]string{
+	"user:all":        "everything the user can do, such as applying batch specs or uploading code intelligence indexes",
+	"site-admin:sudo": "acting as any user, if the user is a site admin",
+}
+
+func init() {
+	usage := `
+'src tokens mint' creates an access token that expires after a while, using
+the access token src is configured with. The token is printed to stdout, to be
+stored as the secret of a CI job, so that a leaked secret can only be used until
+it expires.
+
+The scopes are:
+
+` + tokenScopesUsage() + `
+Sourcegraph doesn't have scopes that are narrower than user:all, such as a scope
+for batch changes only, so tokens can't be limited to one kind of task.
+
+The Sourcegraph instance must support access tokens that expire. Tokens are
+never minted without an expiry: if the instance doesn't support it, src fails.
+
+Site admins can mint tokens for other users, such as a service account, with
+-user.
+
+Usage:
+
+    src tokens mint -scope SCOPE[,SCOPE...] [-ttl DURATION] [-user USERNAME] [-note NOTE]
+
+Examples:
+
+    $ src tokens mint -scope user:all -ttl 1h
+
+    $ SRC_ACCESS_TOKEN=$(src tokens mint -scope user:all -user ci-bot) src code-intel upload
+
+`
+
+	flagSet := flag.NewFlagSet("mint", flag.ExitOnError)
+	var (
+		scopeFlag = flagSet.String("scope", "", "The comma-separated scopes of the token. (required)")
+		ttlFlag   = flagSet.Duration("ttl", 24*time.Hour, "How long the token is valid for.")
+		userFlag  = flagSet.String("user", "", "The username of the user to mint the token for. Default is the currently authenticated user.")
+		noteFlag  = flagSet.String("note", "", `The note of the token, shown in the access tokens of the user. Default is "src tokens mint" and the scopes.`)
+		apiFlags  = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+		scopes, err := parseTokenScopes(*scopeFlag)
+		if err != nil {
+			return err
+		}
+		if *ttlFlag < time.Minute {
+			return cmderrors.Usage("-ttl must be at least 1m")
+		}
+		note := *noteFlag
+		if note == "" {
+			note = "src tokens mint: " + strings.Join(scopes, ", ")
+		}
+
+		ctx := context.Background()
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+
+		expiring, err := supportsExpiringAccessTokens(ctx, client)
+		if err != nil {
+			return err
+		}
+		if !expiring {
+			return cmderrors.WithKind(errors.New("the Sourcegraph instance doesn't support access tokens that expire, so no token was minted"), cmderrors.KindIncompatible)
+		}
+
+		userID, username, err := tokenUser(ctx, client, *userFlag)
+		if err != nil {
+			return err
+		}
+
+		var result struct {
+			CreateAccessToken struct {
+				Token string
+			}
+		}
+		if ok, err := client.NewRequest(`
+mutation MintAccessToken($user: ID!, $scopes: [String!]!, $note: String!, $durationSeconds: Int!) {
+    createAccessToken(user: $user, scopes: $scopes, note: $note, durationSeconds: $durationSeconds) {
+        token
+    }
+}
+`, map[string]interface{}{
+			"user":            userID,
+			"scopes":          scopes,
+			"note":            note,
+			"durationSeconds": int(ttlFlag.Seconds()),
+		}).Do(ctx, &result); err != nil || !ok {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "Minted a token for %s with the scopes %s, valid until %s. Revoke it at %s/users/%s/settings/tokens.\n",
+			username, strings.Join(scopes, ", "), time.Now().Add(*ttlFlag).Format(time.RFC3339), cfg.Endpoint, username)
+		fmt.Println(result.CreateAccessToken.Token)
+		return nil
+	}
+
+	// Register the command.
+	tokensCommands = append(tokensCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src tokens %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+// tokenScopesUsage lists the scopes of tokenScopes for the usage.
+func tokenScopesUsage() string {
+	names := make([]string, 0, len(tokenScopes))
+	for name := range tokenScopes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "    %-16s %s\n", name, tokenScopes[name])
+	}
+	return b.String()
+}
+
+// parseTokenScopes parses the comma-separated scopes of the -scope flag.
+func parseTokenScopes(value string) ([]string, error) {
+	var scopes []string
+	seen := map[string]bool{}
+	for _, scope := range strings.Split(value, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope == "" || seen[scope] {
+			continue
+		}
+		if _, ok := tokenScopes[scope]; !ok {
+			return nil, cmderrors.Usagef("unknown scope %q: must be user:all or site-admin:sudo", scope)
+		}
+		seen[scope] = true
+		scopes = append(scopes, scope)
+	}
+	if len(scopes) == 0 {
+		return nil, cmderrors.Usage("-scope must be provided")
+	}
+	return scopes, nil
+}
+
+// supportsExpiringAccessTokens returns whether the createAccessToken mutation
+// of the instance accepts a duration.
+func supportsExpiringAccessTokens(ctx context.Context, client api.Client) (bool, error) {
+	var result struct {
+		Type *struct {
+			Fields []struct {
+				Name string
+				Args []struct {
+					Name string
+				}
+			}
+		} `json:"__type"`
+	}
+	if ok, err := client.NewRequest(`
+query AccessTokenMutation {
+    __type(name: "Mutation") {
+        fields {
+            name
+            args {
+                name
+            }
+        }
+    }
+}
+`, nil).Do(ctx, &result); err != nil || !ok {
+		return false, err
+	}
+	if result.Type == nil {
+		return false, nil
+	}
+	for _, field := range result.Type.Fields {
+		if field.Name != "createAccessToken" {
+			continue
+		}
+		for _, arg := range field.Args {
+			if arg.Name == "durationSeconds" {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// tokenUser returns the ID and username of the user with the given username,
+// or of the currently authenticated user if it's empty.
+func tokenUser(ctx context.Context, client api.Client, username string) (id, name string, err error) {
+	if username == "" {
+		var result struct {
+			CurrentUser *struct {
+				ID       string
+				Username string
+			}
+		}
+		if ok, err := client.NewRequest(`query { currentUser { id username } }`, nil).Do(ctx, &result); err != nil || !ok {
+			return "", "", err
+		}
+		if result.CurrentUser == nil {
+			return "", "", cmderrors.WithKind(errors.New("not authenticated: an access token is required to mint tokens"), cmderrors.KindAuth)
+		}
+		return result.CurrentUser.ID, result.CurrentUser.Username, nil
+	}
+
+	var result struct {
+		User *struct {
+			ID string
+		}
+	}
+	if ok, err := client.NewRequest(`query User($username: String!) { user(username: $username) { id } }`, map[string]interface{}{
+		"username": username,
+	}).Do(ctx, &result); err != nil || !ok {
+		return "", "", err
+	}
+	if result.User == nil {
+		return "", "", errors.Newf("user %q not found", username)
+	}
+	return result.User.ID, username, nil
+}
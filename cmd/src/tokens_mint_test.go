@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseTokenScopes(t *testing.T) {
+	for name, tc := range map[string]struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		"one":        {value: "user:all", want: []string{"user:all"}},
+		"several":    {value: "site-admin:sudo, user:all", want: []string{"site-admin:sudo", "user:all"}},
+		"duplicates": {value: "user:all,user:all,", want: []string{"user:all"}},
+		"empty":      {value: "", wantErr: true},
+		"unknown":    {value: "user:all,batches", wantErr: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			have, err := parseTokenScopes(tc.value)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got scopes %v", have)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, have); diff != "" {
+				t.Errorf("wrong scopes (-want +have):\n%s", diff)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"os"
 
 	"github.com/sourcegraph/src-cli/internal/api"
 )
@@ -28,6 +29,10 @@ Examples:
 
     	$ src users list -tag=foo
 
+  List users as tab-separated usernames and emails, site admins first:
+
+    	$ src users list -columns=username,emails,site-admin -sort=-site-admin -tsv
+
 `
 
 	flagSet := flag.NewFlagSet("list", flag.ExitOnError)
@@ -41,6 +46,7 @@ Examples:
 		queryFlag  = flagSet.String("query", "", `Returns users whose names match the query. (e.g. "alice")`)
 		tagFlag    = flagSet.String("tag", "", `Returns users with the given tag.`)
 		formatFlag = flagSet.String("f", "{{.Username}}", `Format for the output, using the syntax of Go package text/template. (e.g. "{{.ID}}: {{.Username}} ({{.DisplayName}})" or "{{.|json}}")`)
+		tableFlags = newTableFlags(flagSet, userColumns, "username", "display-name", "site-admin")
 		apiFlags   = api.NewFlags(flagSet)
 	)
 
@@ -56,6 +62,10 @@ Examples:
 		if err != nil {
 			return err
 		}
+		tbl, err := tableFlags.table(flagSet)
+		if err != nil {
+			return err
+		}
 		vars := map[string]interface{}{
 			"first": api.NullInt(*firstFlag),
 			"query": api.NullString(*queryFlag),
@@ -93,10 +103,19 @@ first: $first,
 		}
 
 		for _, user := range result.Users.Nodes {
+			if tbl != nil {
+				if err := tbl.add(user); err != nil {
+					return err
+				}
+				continue
+			}
 			if err := execTemplate(tmpl, user); err != nil {
 				return err
 			}
 		}
+		if tbl != nil {
+			return tbl.write(os.Stdout)
+		}
 		return nil
 	}
 
@@ -107,3 +126,14 @@ first: $first,
 		usageFunc: usageFunc,
 	})
 }
+
+// userColumns are the columns of the table output of src users list.
+var userColumns = []tableColumn{
+	{name: "id", template: "{{.ID}}"},
+	{name: "username", template: "{{.Username}}"},
+	{name: "display-name", template: "{{.DisplayName}}"},
+	{name: "site-admin", template: "{{.SiteAdmin}}"},
+	{name: "emails", template: "{{range $i, $e := .Emails}}{{if $i}},{{end}}{{$e.Email}}{{end}}"},
+	{name: "orgs", template: "{{range $i, $o := .Organizations.Nodes}}{{if $i}},{{end}}{{$o.Name}}{{end}}"},
+	{name: "url", template: "{{.URL}}"},
+}
@@ -44,6 +44,10 @@ type validationSpec struct {
 	} `yaml:"externalService"`
 }
 
+// validateCommands are the subcommands of 'src validate', which validate
+// something other than a Sourcegraph instance set up by a script.
+var validateCommands commander
+
 type validator struct {
 	client    *vdClient
 	apiClient api.Client
@@ -59,6 +63,15 @@ Usage:
 	src validate [options] src-validate.yml
 or
     cat src-validate.yml | src validate [options]
+or
+	src validate command [command options]
+
+The commands are:
+
+	code-host    tests a code host connection config before it's added
+	smoke        tests an instance end to end after it was deployed
+
+Use "src validate [command] -h" for more information about a command.
 
 Please visit https://docs.sourcegraph.com/admin/validation for documentation of the validate command.
 `
@@ -80,6 +93,13 @@ Please visit https://docs.sourcegraph.com/admin/validation for documentation of
 			return err
 		}
 
+		for _, cmd := range validateCommands {
+			if cmd.matches(flagSet.Arg(0)) {
+				validateCommands.run(flagSet, "src validate", usage, args)
+				return nil
+			}
+		}
+
 		client := cfg.apiClient(apiFlags, flagSet.Output())
 
 		vd := &validator{
@@ -685,6 +705,9 @@ func (c *vdClient) graphQL(token, query string, variables map[string]interface{}
 func (vd *validator) createFirstAdmin(vspec *validationSpec) error {
 	client, err := vd.signIn(cfg.Endpoint, vspec.FirstAdmin.Email, vspec.FirstAdmin.Password)
 	if err != nil {
+		if err := cfg.checkWritable("initialize the site admin"); err != nil {
+			return err
+		}
 		client, err = vd.siteAdminInit(cfg.Endpoint, vspec.FirstAdmin.Email, vspec.FirstAdmin.Username,
 			vspec.FirstAdmin.Password)
 		if err != nil {
@@ -698,6 +721,13 @@ func (vd *validator) createFirstAdmin(vspec *validationSpec) error {
 
 func (vd *validator) graphQL(query string, variables map[string]interface{}, target interface{}) error {
 	if vd.client != nil {
+		// The client of the first admin doesn't go through the API client,
+		// which refuses mutations in read-only mode.
+		if cfg.ReadOnly {
+			if err := api.MutationError(query); err != nil {
+				return err
+			}
+		}
 		return vd.client.graphQL("", query, variables, target)
 	}
 
@@ -0,0 +1,600 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	isatty "github.com/mattn/go-isatty"
+
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src validate code-host' tests a code host connection config, the JSON config
+of an external service, against the code host without adding it to Sourcegraph,
+and prints a report of the checks that passed and failed:
+
+    auth         the token of the config is accepted by the code host
+    rate-limit   the token has at least -min-rate-limit API requests left
+    repos        a sample of the repositories that the config selects can be
+                 listed with the token
+    webhooks     if the config has webhooks, the URL they're delivered to on
+                 Sourcegraph is reachable from where src runs. It's checked
+                 with a GET request, unless -post-webhook is set
+
+The config must be for GitHub, GitLab or Bitbucket Server. The kind of code host
+is taken from -kind, or from the URL of the config for github.com and
+gitlab.com.
+
+src exits with a non-zero exit code if any check failed, so that a new
+connection can be tested before it's applied to a production instance.
+
+With -post-webhook, the webhooks check posts a test webhook without a valid
+signature to the URL, which Sourcegraph rejects, rather than only checking that
+the URL can be reached. This can't be used in read-only mode.
+
+Usage:
+
+    src validate code-host [-kind KIND] config.json
+or
+    cat config.json | src validate code-host [-kind KIND]
+
+Examples:
+
+    $ src validate code-host github.json
+
+    $ src validate code-host -kind gitlab -sample 20 gitlab.example.com.json
+
+`
+
+	flagSet := flag.NewFlagSet("code-host", flag.ExitOnError)
+	var (
+		kindFlag         = flagSet.String("kind", "", "The kind of code host: github, gitlab or bitbucketServer. Default is taken from the URL of the config.")
+		sampleFlag       = flagSet.Int("sample", 5, "The number of repositories to list.")
+		minRateLimitFlag = flagSet.Int("min-rate-limit", 500, "The number of API requests the token must have left.")
+		webhookURLFlag   = flagSet.String("webhook-url", "", "The URL that webhooks are delivered to. Default is the URL for the kind of code host on the Sourcegraph instance.")
+		timeoutFlag      = flagSet.Duration("timeout", 30*time.Second, "How long to wait for each check.")
+		postWebhookFlag  = flagSet.Bool("post-webhook", false, "Post a test webhook without a valid signature to the webhook URL, instead of only checking it with a GET request.")
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		var data []byte
+		var err error
+		if len(flagSet.Args()) == 1 {
+			if data, err = os.ReadFile(flagSet.Arg(0)); err != nil {
+				return err
+			}
+		} else if len(flagSet.Args()) > 1 {
+			return cmderrors.Usage("only one config may be given")
+		} else if !isatty.IsTerminal(os.Stdin.Fd()) {
+			// stdin is a pipe not a terminal
+			if data, err = io.ReadAll(os.Stdin); err != nil {
+				return err
+			}
+		} else {
+			return cmderrors.Usage("a config must be given")
+		}
+
+		var config codeHostConfig
+		if err := jsonxUnmarshal(string(data), &config); err != nil {
+			return cmderrors.WithKind(errors.Wrap(err, "parsing config"), cmderrors.KindValidation)
+		}
+		if config.URL == "" {
+			return cmderrors.WithKind(errors.New("the config has no url"), cmderrors.KindValidation)
+		}
+		kind, err := codeHostKind(*kindFlag, config.URL)
+		if err != nil {
+			return err
+		}
+		if *sampleFlag < 1 {
+			return cmderrors.Usage("-sample must be at least 1")
+		}
+		if *postWebhookFlag {
+			if err := cfg.checkWritable("post a test webhook"); err != nil {
+				return err
+			}
+		}
+
+		webhookURL := *webhookURLFlag
+		if webhookURL == "" {
+			webhookURL = cfg.Endpoint + codeHostWebhookPaths[kind]
+		}
+
+		v := &codeHostValidator{
+			codeHostClient: codeHostClient{client: http.DefaultClient, kind: kind, config: config},
+			sample:         *sampleFlag,
+			minRateLimit:   *minRateLimitFlag,
+			webhookURL:     webhookURL,
+			postWebhook:    *postWebhookFlag,
+			timeout:        *timeoutFlag,
+		}
+		checks := v.run(context.Background())
+
+		failed := 0
+		for _, c := range checks {
+			fmt.Printf("%-4s  %-10s  %s\n", strings.ToUpper(string(c.status)), c.name, c.message)
+			if c.status == checkFailed {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return cmderrors.WithKind(errors.Newf("%d of %d checks failed", failed, len(checks)), cmderrors.KindValidation)
+		}
+		return nil
+	}
+
+	// Register the command.
+	validateCommands = append(validateCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src validate %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+// The kinds of code hosts that 'src validate code-host' can test.
+const (
+	codeHostGitHub          = "github"
+	codeHostGitLab          = "gitlab"
+	codeHostBitbucketServer = "bitbucketServer"
+)
+
+// codeHostWebhookPaths are the paths on Sourcegraph that the webhooks of the
+// configs of the kinds of code hosts are delivered to.
+var codeHostWebhookPaths = map[string]string{
+	codeHostGitHub:          "/.api/github-webhooks",
+	codeHostGitLab:          "/.api/gitlab-webhooks",
+	codeHostBitbucketServer: "/.api/bitbucket-server-webhooks",
+}
+
+// codeHostKind returns the kind of code host given by -kind, or the kind of
+// the code host at rawURL if it's empty.
+func codeHostKind(kind, rawURL string) (string, error) {
+	if kind != "" {
+		// Also accept the kinds of external services, such as BITBUCKETSERVER
+		// or BITBUCKET_SERVER.
+		for k := range codeHostWebhookPaths {
+			if strings.EqualFold(strings.ReplaceAll(kind, "_", ""), k) {
+				return k, nil
+			}
+		}
+		return "", cmderrors.Usagef("unsupported kind of code host %q: must be github, gitlab or bitbucketServer", kind)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", cmderrors.WithKind(errors.Wrap(err, "parsing url of the config"), cmderrors.KindValidation)
+	}
+	switch strings.ToLower(u.Hostname()) {
+	case "github.com":
+		return codeHostGitHub, nil
+	case "gitlab.com":
+		return codeHostGitLab, nil
+	}
+	return "", cmderrors.Usagef("the kind of code host at %s can't be determined, set -kind", rawURL)
+}
+
+// codeHostConfig are the fields of the configs of the kinds of code hosts
+// that the checks use.
+type codeHostConfig struct {
+	URL      string `json:"url"`
+	Token    string `json:"token"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+
+	// GitHub and Bitbucket Server.
+	Repos           []string `json:"repos"`
+	RepositoryQuery []string `json:"repositoryQuery"`
+	// GitHub.
+	Orgs []string `json:"orgs"`
+	// GitLab.
+	Projects []struct {
+		Name string `json:"name"`
+		ID   int    `json:"id"`
+	} `json:"projects"`
+	ProjectQuery []string `json:"projectQuery"`
+
+	Webhooks json.RawMessage `json:"webhooks"`
+	Plugin   *struct {
+		Webhooks json.RawMessage `json:"webhooks"`
+	} `json:"plugin"`
+}
+
+// hasWebhooks returns whether webhooks are configured.
+func (c *codeHostConfig) hasWebhooks() bool {
+	configured := func(raw json.RawMessage) bool {
+		s := strings.TrimSpace(string(raw))
+		return s != "" && s != "null" && s != "[]" && s != "{}"
+	}
+	return configured(c.Webhooks) || (c.Plugin != nil && configured(c.Plugin.Webhooks))
+}
+
+type checkStatus string
+
+const (
+	checkPassed  checkStatus = "pass"
+	checkFailed  checkStatus = "fail"
+	checkSkipped checkStatus = "skip"
+)
+
+// codeHostCheck is the result of a check of a code host connection config.
+type codeHostCheck struct {
+	name    string
+	status  checkStatus
+	message string
+}
+
+// codeHostClient makes requests to the API of the code host of a code host
+// connection config, with its token.
+type codeHostClient struct {
+	client *http.Client
+	kind   string
+	config codeHostConfig
+}
+
+// codeHostValidator checks a code host connection config against the code
+// host.
+type codeHostValidator struct {
+	codeHostClient
+	sample       int
+	minRateLimit int
+	webhookURL   string
+	// postWebhook is whether the webhook URL is checked by posting a test
+	// webhook to it, which is only done when asked for by -post-webhook.
+	postWebhook bool
+	timeout     time.Duration
+}
+
+// run runs the checks. The checks that need the token are skipped if it isn't
+// accepted.
+func (v *codeHostValidator) run(ctx context.Context) []codeHostCheck {
+	withTimeout := func(f func(ctx context.Context) codeHostCheck) codeHostCheck {
+		ctx, cancel := context.WithTimeout(ctx, v.timeout)
+		defer cancel()
+		return f(ctx)
+	}
+
+	var header http.Header
+	auth := withTimeout(func(ctx context.Context) codeHostCheck {
+		var c codeHostCheck
+		c, header = v.checkAuth(ctx)
+		return c
+	})
+	checks := []codeHostCheck{auth}
+	if auth.status == checkFailed {
+		checks = append(checks,
+			codeHostCheck{name: "rate-limit", status: checkSkipped, message: "the token wasn't accepted"},
+			codeHostCheck{name: "repos", status: checkSkipped, message: "the token wasn't accepted"},
+		)
+	} else {
+		checks = append(checks, v.checkRateLimit(header), withTimeout(v.checkRepos))
+	}
+	return append(checks, withTimeout(v.checkWebhooks))
+}
+
+func (v *codeHostValidator) checkAuth(ctx context.Context) (codeHostCheck, http.Header) {
+	c := codeHostCheck{name: "auth"}
+
+	var path, username string
+	var user struct {
+		Login    string `json:"login"`
+		Username string `json:"username"`
+		Name     string `json:"name"`
+	}
+	switch v.kind {
+	case codeHostGitHub, codeHostGitLab:
+		path = "/user"
+	case codeHostBitbucketServer:
+		if v.config.Username == "" {
+			c.status, c.message = checkFailed, "the config has no username"
+			return c, nil
+		}
+		path = "/users/" + url.PathEscape(v.config.Username)
+	}
+	header, err := v.get(ctx, path, &user)
+	if err != nil {
+		c.status, c.message = checkFailed, err.Error()
+		return c, nil
+	}
+	for _, name := range []string{user.Login, user.Username, user.Name} {
+		if name != "" {
+			username = name
+			break
+		}
+	}
+	c.status, c.message = checkPassed, "authenticated as "+username
+	return c, header
+}
+
+// checkRateLimit checks the rate limit headers of the response to the
+// authenticated request.
+func (v *codeHostValidator) checkRateLimit(header http.Header) codeHostCheck {
+	c := codeHostCheck{name: "rate-limit"}
+
+	var remaining, limit, reset string
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		if remaining = header.Get(prefix + "Remaining"); remaining != "" {
+			limit, reset = header.Get(prefix+"Limit"), header.Get(prefix+"Reset")
+			break
+		}
+	}
+	if remaining == "" {
+		c.status, c.message = checkSkipped, "the code host doesn't report a rate limit"
+		return c
+	}
+	n, err := strconv.Atoi(remaining)
+	if err != nil {
+		c.status, c.message = checkFailed, fmt.Sprintf("invalid rate limit %q", remaining)
+		return c
+	}
+
+	c.message = fmt.Sprintf("%d of %s requests left", n, limit)
+	if epoch, err := strconv.ParseInt(reset, 10, 64); err == nil {
+		c.message += ", resets at " + time.Unix(epoch, 0).Format(time.RFC3339)
+	}
+	if n < v.minRateLimit {
+		c.status, c.message = checkFailed, fmt.Sprintf("%s, fewer than %d", c.message, v.minRateLimit)
+		return c
+	}
+	c.status = checkPassed
+	return c
+}
+
+// checkRepos lists a sample of the repositories that the config selects, from
+// the first way of selecting them that it uses.
+func (v *codeHostValidator) checkRepos(ctx context.Context) codeHostCheck {
+	c := codeHostCheck{name: "repos"}
+	names, err := v.sampleRepos(ctx)
+	if err != nil {
+		c.status, c.message = checkFailed, err.Error()
+		return c
+	}
+	if len(names) == 0 {
+		c.status, c.message = checkFailed, "the config selects no repositories that the token can access"
+		return c
+	}
+	c.status, c.message = checkPassed, fmt.Sprintf("listed %s", strings.Join(names, ", "))
+	return c
+}
+
+func (v *codeHostValidator) sampleRepos(ctx context.Context) ([]string, error) {
+	getRepos := func(paths []string) ([]string, error) {
+		if len(paths) > v.sample {
+			paths = paths[:v.sample]
+		}
+		var names []string
+		for _, path := range paths {
+			var repo codeHostRepo
+			if _, err := v.get(ctx, path, &repo); err != nil {
+				return names, err
+			}
+			names = append(names, repo.name())
+		}
+		return names, nil
+	}
+	listRepos := func(path string) ([]string, error) {
+		sep := "?"
+		if strings.Contains(path, "?") {
+			sep = "&"
+		}
+		var repos []codeHostRepo
+		var page struct {
+			Values []codeHostRepo `json:"values"`
+		}
+		var target interface{} = &repos
+		if v.kind == codeHostBitbucketServer {
+			path += sep + "limit=" + strconv.Itoa(v.sample)
+			target = &page
+		} else {
+			path += sep + "per_page=" + strconv.Itoa(v.sample)
+		}
+		if _, err := v.get(ctx, path, target); err != nil {
+			return nil, err
+		}
+		repos = append(repos, page.Values...)
+		if len(repos) > v.sample {
+			repos = repos[:v.sample]
+		}
+		names := make([]string, 0, len(repos))
+		for _, r := range repos {
+			names = append(names, r.name())
+		}
+		return names, nil
+	}
+
+	query := func(queries []string) string {
+		for _, q := range queries {
+			if q != "none" {
+				return q
+			}
+		}
+		return ""
+	}
+
+	switch v.kind {
+	case codeHostGitHub:
+		switch {
+		case len(v.config.Repos) > 0:
+			paths := make([]string, 0, len(v.config.Repos))
+			for _, r := range v.config.Repos {
+				paths = append(paths, "/repos/"+r)
+			}
+			return getRepos(paths)
+		case len(v.config.Orgs) > 0:
+			return listRepos("/orgs/" + url.PathEscape(v.config.Orgs[0]) + "/repos")
+		}
+		return listRepos("/user/repos")
+
+	case codeHostGitLab:
+		switch {
+		case len(v.config.Projects) > 0:
+			paths := make([]string, 0, len(v.config.Projects))
+			for _, p := range v.config.Projects {
+				if p.Name != "" {
+					paths = append(paths, "/projects/"+url.PathEscape(p.Name))
+				} else {
+					paths = append(paths, "/projects/"+strconv.Itoa(p.ID))
+				}
+			}
+			return getRepos(paths)
+		case query(v.config.ProjectQuery) != "":
+			return listRepos("/" + strings.TrimPrefix(query(v.config.ProjectQuery), "/"))
+		}
+		return listRepos("/projects?membership=true")
+
+	case codeHostBitbucketServer:
+		if len(v.config.Repos) > 0 {
+			paths := make([]string, 0, len(v.config.Repos))
+			for _, r := range v.config.Repos {
+				parts := strings.SplitN(r, "/", 2)
+				if len(parts) != 2 {
+					return nil, errors.Newf("invalid repository %q in repos: must be PROJECT/REPO", r)
+				}
+				paths = append(paths, "/projects/"+url.PathEscape(parts[0])+"/repos/"+url.PathEscape(parts[1]))
+			}
+			return getRepos(paths)
+		}
+		if q := query(v.config.RepositoryQuery); strings.HasPrefix(q, "?") {
+			return listRepos("/repos" + q)
+		}
+		return listRepos("/repos")
+	}
+	return nil, errors.Newf("unsupported kind of code host %q", v.kind)
+}
+
+// codeHostRepo is a repository in the responses of the APIs of the kinds of
+// code hosts.
+type codeHostRepo struct {
+	// GitHub.
+	FullName string `json:"full_name"`
+	// GitLab.
+	PathWithNamespace string `json:"path_with_namespace"`
+	// Bitbucket Server.
+	Slug    string `json:"slug"`
+	Project struct {
+		Key string `json:"key"`
+	} `json:"project"`
+}
+
+func (r codeHostRepo) name() string {
+	switch {
+	case r.FullName != "":
+		return r.FullName
+	case r.PathWithNamespace != "":
+		return r.PathWithNamespace
+	}
+	return r.Project.Key + "/" + r.Slug
+}
+
+// checkWebhooks checks that the URL webhooks are delivered to responds
+// rather than not being found or failing. By default, it's checked with a GET
+// request, which Sourcegraph doesn't act on. With postWebhook, a webhook
+// without a valid signature is posted to it, which Sourcegraph rejects.
+func (v *codeHostValidator) checkWebhooks(ctx context.Context) codeHostCheck {
+	c := codeHostCheck{name: "webhooks"}
+	if !v.config.hasWebhooks() {
+		c.status, c.message = checkSkipped, "the config has no webhooks"
+		return c
+	}
+
+	method, body := "GET", io.Reader(nil)
+	if v.postWebhook {
+		method, body = "POST", strings.NewReader("{}")
+	}
+	req, err := http.NewRequestWithContext(ctx, method, v.webhookURL, body)
+	if err != nil {
+		c.status, c.message = checkFailed, err.Error()
+		return c
+	}
+	if v.postWebhook {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		c.status, c.message = checkFailed, fmt.Sprintf("%s is unreachable: %s", v.webhookURL, err)
+		return c
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode >= 500 {
+		c.status, c.message = checkFailed, fmt.Sprintf("%s responded with %s", v.webhookURL, resp.Status)
+		return c
+	}
+	c.status, c.message = checkPassed, fmt.Sprintf("%s is reachable", v.webhookURL)
+	return c
+}
+
+// apiURL returns the URL of the API of the code host.
+func (c *codeHostClient) apiURL() string {
+	base := strings.TrimSuffix(c.config.URL, "/")
+	switch c.kind {
+	case codeHostGitHub:
+		if u, err := url.Parse(base); err == nil && strings.EqualFold(u.Hostname(), "github.com") {
+			return "https://api.github.com"
+		}
+		return base + "/api/v3"
+	case codeHostGitLab:
+		return base + "/api/v4"
+	case codeHostBitbucketServer:
+		return base + "/rest/api/1.0"
+	}
+	return base
+}
+
+// get makes an authenticated request to the path of the API of the code host
+// and decodes the response into target.
+func (c *codeHostClient) get(ctx context.Context, path string, target interface{}) (http.Header, error) {
+	return c.getURL(ctx, c.apiURL()+path, target)
+}
+
+// getURL is like get, but for the URL of the API, such as the URL of the next
+// page in a Link header.
+func (c *codeHostClient) getURL(ctx context.Context, rawURL string, target interface{}) (http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.kind == codeHostBitbucketServer {
+		password := c.config.Token
+		if password == "" {
+			password = c.config.Password
+		}
+		req.SetBasicAuth(c.config.Username, password)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+c.config.Token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, errors.Newf("GET %s: %s: %s", req.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return nil, errors.Wrapf(err, "GET %s: decoding response", req.URL.Path)
+	}
+	return resp.Header, nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCodeHostValidator(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"message": "Bad credentials"}`))
+			return
+		}
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4000")
+		_, _ = w.Write([]byte(`{"login": "alice"}`))
+	})
+	mux.HandleFunc("/api/v3/orgs/sourcegraph/repos", func(w http.ResponseWriter, r *http.Request) {
+		if have, want := r.URL.Query().Get("per_page"), "2"; have != want {
+			t.Errorf("wrong per_page: have %q, want %q", have, want)
+		}
+		_, _ = w.Write([]byte(`[{"full_name": "sourcegraph/src-cli"}, {"full_name": "sourcegraph/sourcegraph"}]`))
+	})
+	mux.HandleFunc("/api/v3/repos/sourcegraph/src-cli", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"full_name": "sourcegraph/src-cli"}`))
+	})
+	var webhookMethod string
+	mux.HandleFunc("/.api/github-webhooks", func(w http.ResponseWriter, r *http.Request) {
+		webhookMethod = r.Method
+		if r.Method != "POST" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	run := func(t *testing.T, config string, minRateLimit int, webhookPath string, postWebhook bool) []codeHostCheck {
+		t.Helper()
+		v := &codeHostValidator{
+			codeHostClient: codeHostClient{client: ts.Client(), kind: codeHostGitHub},
+			sample:         2,
+			minRateLimit:   minRateLimit,
+			webhookURL:     ts.URL + webhookPath,
+			postWebhook:    postWebhook,
+			timeout:        10 * time.Second,
+		}
+		if err := json.Unmarshal([]byte(config), &v.config); err != nil {
+			t.Fatal(err)
+		}
+		v.config.URL = ts.URL
+		webhookMethod = ""
+		return v.run(context.Background())
+	}
+	statuses := func(checks []codeHostCheck) map[string]checkStatus {
+		m := map[string]checkStatus{}
+		for _, c := range checks {
+			m[c.name] = c.status
+		}
+		return m
+	}
+
+	t.Run("passing", func(t *testing.T) {
+		checks := run(t, `{"token": "secret", "orgs": ["sourcegraph"], "webhooks": [{"org": "sourcegraph", "secret": "s"}]}`, 500, "/.api/github-webhooks", false)
+		want := map[string]checkStatus{"auth": checkPassed, "rate-limit": checkPassed, "repos": checkPassed, "webhooks": checkPassed}
+		if diff := cmp.Diff(want, statuses(checks)); diff != "" {
+			t.Fatalf("wrong statuses (-want +have):\n%s", diff)
+		}
+		if have, want := checks[2].message, "listed sourcegraph/src-cli, sourcegraph/sourcegraph"; have != want {
+			t.Errorf("wrong repos message: have %q, want %q", have, want)
+		}
+		if webhookMethod != "GET" {
+			t.Errorf("webhook URL checked with %s, want GET", webhookMethod)
+		}
+	})
+
+	t.Run("posted webhook", func(t *testing.T) {
+		checks := run(t, `{"token": "secret", "orgs": ["sourcegraph"], "webhooks": [{"org": "sourcegraph", "secret": "s"}]}`, 500, "/.api/github-webhooks", true)
+		if have := statuses(checks)["webhooks"]; have != checkPassed {
+			t.Errorf("wrong webhooks status %q: %s", have, checks[3].message)
+		}
+		if webhookMethod != "POST" {
+			t.Errorf("webhook posted with %s, want POST", webhookMethod)
+		}
+	})
+
+	t.Run("repos and no webhooks", func(t *testing.T) {
+		checks := run(t, `{"token": "secret", "repos": ["sourcegraph/src-cli"]}`, 500, "/.api/github-webhooks", false)
+		want := map[string]checkStatus{"auth": checkPassed, "rate-limit": checkPassed, "repos": checkPassed, "webhooks": checkSkipped}
+		if diff := cmp.Diff(want, statuses(checks)); diff != "" {
+			t.Fatalf("wrong statuses (-want +have):\n%s", diff)
+		}
+	})
+
+	t.Run("low rate limit and unknown webhook URL", func(t *testing.T) {
+		checks := run(t, `{"token": "secret", "orgs": ["sourcegraph"], "webhooks": [{"org": "sourcegraph", "secret": "s"}]}`, 4500, "/.api/nope", false)
+		want := map[string]checkStatus{"auth": checkPassed, "rate-limit": checkFailed, "repos": checkPassed, "webhooks": checkFailed}
+		if diff := cmp.Diff(want, statuses(checks)); diff != "" {
+			t.Fatalf("wrong statuses (-want +have):\n%s", diff)
+		}
+	})
+
+	t.Run("bad token", func(t *testing.T) {
+		checks := run(t, `{"token": "wrong", "orgs": ["sourcegraph"]}`, 500, "/.api/github-webhooks", false)
+		want := map[string]checkStatus{"auth": checkFailed, "rate-limit": checkSkipped, "repos": checkSkipped, "webhooks": checkSkipped}
+		if diff := cmp.Diff(want, statuses(checks)); diff != "" {
+			t.Fatalf("wrong statuses (-want +have):\n%s", diff)
+		}
+	})
+}
+
+func TestCodeHostKind(t *testing.T) {
+	for _, tc := range []struct {
+		kind, url string
+		want      string
+		wantErr   bool
+	}{
+		{url: "https://github.com", want: codeHostGitHub},
+		{url: "https://gitlab.com/", want: codeHostGitLab},
+		{url: "https://git.example.com", wantErr: true},
+		{kind: "BITBUCKET_SERVER", url: "https://git.example.com", want: codeHostBitbucketServer},
+		{kind: "GitLab", url: "https://github.com", want: codeHostGitLab},
+		{kind: "gerrit", url: "https://git.example.com", wantErr: true},
+	} {
+		have, err := codeHostKind(tc.kind, tc.url)
+		if tc.wantErr != (err != nil) {
+			t.Errorf("codeHostKind(%q, %q): unexpected error %v", tc.kind, tc.url, err)
+		}
+		if have != tc.want {
+			t.Errorf("codeHostKind(%q, %q): have %q, want %q", tc.kind, tc.url, have, tc.want)
+		}
+	}
+}
@@ -0,0 +1,535 @@
+package main
+
+import (
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+// smokeDefaultCloneURL is the repository that 'src validate smoke' adds when
+// no canary repository is given.
+const smokeDefaultCloneURL = "https://github.com/sourcegraph/src-cli"
+
+func init() {
+	usage := `
+'src validate smoke' tests a Sourcegraph instance end to end after it was
+deployed, and prints a report of the checks that passed and failed:
+
+    repo       a throwaway repository is added, or the canary repository
+               given by -repo exists
+    clone      the repository is cloned
+    index      the repository is indexed for search, if indexed search is
+               enabled
+    search     a search for -pattern in the repository has results
+    hover      code intelligence has a hover at the position given by -hover
+    cleanup    the throwaway repository is removed again
+
+The throwaway repository is added with a code host connection of its own, which
+is deleted when done, from -clone-url. A canary repository is one that is kept
+on the instance for this purpose, and is left as it is.
+
+With -junit, the report is also written to a file as JUnit XML, so that deploy
+pipelines can gate on it. src exits with a non-zero exit code if any check
+failed.
+
+Usage:
+
+    src validate smoke [-repo NAME | -clone-url URL] [command options]
+
+Examples:
+
+    $ src validate smoke -junit smoke.xml
+
+    $ src validate smoke -repo github.com/acme/canary -pattern 'func main' -hover main.go:12:6
+
+`
+
+	flagSet := flag.NewFlagSet("smoke", flag.ExitOnError)
+	var (
+		repoFlag     = flagSet.String("repo", "", "The name of a canary repository on the instance to test with, instead of adding a throwaway repository.")
+		cloneURLFlag = flagSet.String("clone-url", "", "The clone URL of the throwaway repository. (default "+smokeDefaultCloneURL+")")
+		patternFlag  = flagSet.String("pattern", "package", "The literal pattern to search for in the repository.")
+		hoverFlag    = flagSet.String("hover", "", "The position to ask code intelligence for a hover at, as path:line:character, with line and character starting at 1. The hover check is skipped if it isn't given.")
+		junitFlag    = flagSet.String("junit", "", "Write the report as JUnit XML to this file.")
+		timeoutFlag  = flagSet.Duration("timeout", 10*time.Minute, "How long to wait for the repository to be cloned, indexed and searchable.")
+		apiFlags     = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+		if *repoFlag != "" && *cloneURLFlag != "" {
+			return cmderrors.Usage("only one of -repo and -clone-url may be given")
+		}
+		opts := smokeOptions{
+			repo:     *repoFlag,
+			cloneURL: *cloneURLFlag,
+			pattern:  *patternFlag,
+			timeout:  *timeoutFlag,
+		}
+		if opts.repo == "" && opts.cloneURL == "" {
+			opts.cloneURL = smokeDefaultCloneURL
+		}
+		if *hoverFlag != "" {
+			pos, err := parseHoverPosition(*hoverFlag)
+			if err != nil {
+				return err
+			}
+			opts.hover = pos
+		}
+
+		vd := &validator{
+			apiClient: cfg.apiClient(apiFlags, flagSet.Output()),
+		}
+		start := time.Now()
+		checks := vd.smoke(opts)
+
+		failed := 0
+		for _, c := range checks {
+			fmt.Printf("%-4s  %-10s  %s\n", strings.ToUpper(string(c.status)), c.name, c.message)
+			if c.status == checkFailed {
+				failed++
+			}
+		}
+		if *junitFlag != "" {
+			f, err := os.Create(*junitFlag)
+			if err != nil {
+				return err
+			}
+			if err := writeSmokeJUnit(f, checks, start); err != nil {
+				f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+		}
+		if failed > 0 {
+			return cmderrors.WithKind(errors.Newf("%d of %d checks failed", failed, len(checks)), cmderrors.KindValidation)
+		}
+		return nil
+	}
+
+	// Register the command.
+	validateCommands = append(validateCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src validate %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+type smokeOptions struct {
+	// repo is the name of the canary repository, or empty to add a
+	// throwaway repository from cloneURL.
+	repo     string
+	cloneURL string
+	pattern  string
+	hover    *hoverPosition
+	timeout  time.Duration
+}
+
+// hoverPosition is a position in a file of the repository, with line and
+// character starting at 0.
+type hoverPosition struct {
+	path            string
+	line, character int
+}
+
+// parseHoverPosition parses the path:line:character of -hover.
+func parseHoverPosition(value string) (*hoverPosition, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) < 3 {
+		return nil, cmderrors.Usagef("invalid -hover %q: must be path:line:character", value)
+	}
+	n := len(parts)
+	line, err := strconv.Atoi(parts[n-2])
+	if err != nil || line < 1 {
+		return nil, cmderrors.Usagef("invalid line in -hover %q", value)
+	}
+	character, err := strconv.Atoi(parts[n-1])
+	if err != nil || character < 1 {
+		return nil, cmderrors.Usagef("invalid character in -hover %q", value)
+	}
+	return &hoverPosition{path: strings.Join(parts[:n-2], ":"), line: line - 1, character: character - 1}, nil
+}
+
+// smokeCheck is the result of a check of 'src validate smoke'.
+type smokeCheck struct {
+	name     string
+	status   checkStatus
+	message  string
+	duration time.Duration
+}
+
+// smoke runs the checks of 'src validate smoke'. The checks after one that
+// failed are skipped, except for the cleanup.
+func (vd *validator) smoke(opts smokeOptions) []smokeCheck {
+	var checks []smokeCheck
+	failed := false
+	check := func(name string, f func() (checkStatus, string)) {
+		if failed {
+			checks = append(checks, smokeCheck{name: name, status: checkSkipped, message: "an earlier check failed"})
+			return
+		}
+		start := time.Now()
+		status, message := f()
+		checks = append(checks, smokeCheck{name: name, status: status, message: message, duration: time.Since(start)})
+		failed = status == checkFailed
+	}
+	deadline := time.Now().Add(opts.timeout)
+
+	repo, extSvcID := opts.repo, ""
+	check("repo", func() (checkStatus, string) {
+		if repo != "" {
+			exists, err := vd.repoExists(repo)
+			if err != nil {
+				return checkFailed, err.Error()
+			}
+			if !exists {
+				return checkFailed, fmt.Sprintf("canary repository %s doesn't exist", repo)
+			}
+			return checkPassed, fmt.Sprintf("canary repository %s exists", repo)
+		}
+
+		var err error
+		repo, extSvcID, err = vd.addThrowawayRepo(opts.cloneURL)
+		if err != nil {
+			return checkFailed, err.Error()
+		}
+		return checkPassed, fmt.Sprintf("added throwaway repository %s", repo)
+	})
+
+	check("clone", func() (checkStatus, string) {
+		err := pollUntil(deadline, func() (bool, error) {
+			names, err := vd.listClonedRepos([]string{repo})
+			return len(names) == 1, err
+		})
+		if err != nil {
+			return checkFailed, fmt.Sprintf("%s wasn't cloned: %s", repo, err)
+		}
+		return checkPassed, fmt.Sprintf("%s is cloned", repo)
+	})
+
+	check("index", func() (checkStatus, string) {
+		enabled := true
+		err := pollUntil(deadline, func() (bool, error) {
+			var indexed bool
+			var err error
+			enabled, indexed, err = vd.textSearchIndexed(repo)
+			return !enabled || indexed, err
+		})
+		if err != nil {
+			return checkFailed, fmt.Sprintf("%s wasn't indexed: %s", repo, err)
+		}
+		if !enabled {
+			return checkSkipped, "indexed search is disabled"
+		}
+		return checkPassed, fmt.Sprintf("%s is indexed", repo)
+	})
+
+	check("search", func() (checkStatus, string) {
+		query := fmt.Sprintf("repo:^%s$ %s", regexp.QuoteMeta(repo), opts.pattern)
+		var count int
+		err := pollUntil(deadline, func() (bool, error) {
+			var err error
+			count, err = vd.searchMatchCount(query)
+			return count > 0, err
+		})
+		if err != nil {
+			return checkFailed, fmt.Sprintf("%q has no results: %s", query, err)
+		}
+		return checkPassed, fmt.Sprintf("%q has %d results", query, count)
+	})
+
+	check("hover", func() (checkStatus, string) {
+		if opts.hover == nil {
+			return checkSkipped, "no -hover position given"
+		}
+		text, err := vd.hover(repo, opts.hover)
+		if err != nil {
+			return checkFailed, err.Error()
+		}
+		return checkPassed, fmt.Sprintf("hover at %s:%d:%d: %s", opts.hover.path, opts.hover.line+1, opts.hover.character+1, firstLine(text))
+	})
+
+	// The cleanup runs even if a check failed.
+	start := time.Now()
+	cleanup := smokeCheck{name: "cleanup"}
+	switch {
+	case opts.repo != "":
+		cleanup.status, cleanup.message = checkSkipped, "the canary repository is kept"
+	case extSvcID == "":
+		cleanup.status, cleanup.message = checkSkipped, "no throwaway repository was added"
+	default:
+		if err := vd.deleteExternalService(extSvcID); err != nil {
+			cleanup.status, cleanup.message = checkFailed, fmt.Sprintf("deleting the code host connection %s of %s: %s", extSvcID, repo, err)
+		} else {
+			cleanup.status, cleanup.message = checkPassed, fmt.Sprintf("removed throwaway repository %s", repo)
+		}
+	}
+	cleanup.duration = time.Since(start)
+	return append(checks, cleanup)
+}
+
+// pollUntil calls f until it returns true or an error, or until the deadline
+// passes.
+func pollUntil(deadline time.Time, f func() (bool, error)) error {
+	for {
+		done, err := f()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.New("timed out")
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+// addThrowawayRepo adds a code host connection for the repository at the
+// clone URL, and returns the name of the repository and the ID of the
+// connection.
+func (vd *validator) addThrowawayRepo(cloneURL string) (repo, extSvcID string, err error) {
+	u, err := url.Parse(cloneURL)
+	if err != nil || u.Host == "" {
+		return "", "", errors.Newf("invalid clone URL %q", cloneURL)
+	}
+	path := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	if path == "" {
+		return "", "", errors.Newf("invalid clone URL %q: no repository path", cloneURL)
+	}
+
+	var vspec validationSpec
+	vspec.ExternalService.Kind = "OTHER"
+	vspec.ExternalService.DisplayName = "src validate smoke " + time.Now().UTC().Format(time.RFC3339)
+	vspec.ExternalService.Config = map[string]interface{}{
+		"url":   (&url.URL{Scheme: u.Scheme, User: u.User, Host: u.Host}).String(),
+		"repos": []string{path},
+	}
+	extSvcID, err = vd.addExternalService(&vspec)
+	if err != nil {
+		return "", "", errors.Wrap(err, "adding code host connection")
+	}
+	return u.Host + "/" + path, extSvcID, nil
+}
+
+const vdRepoExistsQuery = `
+query RepoExists($name: String!) {
+  repository(name: $name) {
+    id
+  }
+}`
+
+func (vd *validator) repoExists(name string) (bool, error) {
+	var resp struct {
+		Repository *struct {
+			ID string `json:"id"`
+		} `json:"repository"`
+	}
+
+	err := vd.graphQL(vdRepoExistsQuery, map[string]interface{}{
+		"name": name,
+	}, &resp)
+
+	return resp.Repository != nil, err
+}
+
+const vdTextSearchIndexQuery = `
+query TextSearchIndex($name: String!) {
+  repository(name: $name) {
+    textSearchIndex {
+      status {
+        updatedAt
+      }
+    }
+  }
+}`
+
+// textSearchIndexed returns whether indexed search is enabled for the
+// repository, and whether it's indexed.
+func (vd *validator) textSearchIndexed(name string) (enabled, indexed bool, err error) {
+	var resp struct {
+		Repository *struct {
+			TextSearchIndex *struct {
+				Status *struct {
+					UpdatedAt string `json:"updatedAt"`
+				} `json:"status"`
+			} `json:"textSearchIndex"`
+		} `json:"repository"`
+	}
+
+	if err := vd.graphQL(vdTextSearchIndexQuery, map[string]interface{}{
+		"name": name,
+	}, &resp); err != nil {
+		return false, false, err
+	}
+	if resp.Repository == nil || resp.Repository.TextSearchIndex == nil {
+		return false, false, nil
+	}
+	return true, resp.Repository.TextSearchIndex.Status != nil, nil
+}
+
+const vdHoverQuery = `
+query Hover($name: String!, $path: String!, $line: Int!, $character: Int!) {
+  repository(name: $name) {
+    commit(rev: "HEAD") {
+      blob(path: $path) {
+        lsif {
+          hover(line: $line, character: $character) {
+            markdown {
+              text
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+// hover returns the text of the code intelligence hover at the position in
+// the repository.
+func (vd *validator) hover(name string, pos *hoverPosition) (string, error) {
+	var resp struct {
+		Repository *struct {
+			Commit *struct {
+				Blob *struct {
+					LSIF *struct {
+						Hover *struct {
+							Markdown struct {
+								Text string `json:"text"`
+							} `json:"markdown"`
+						} `json:"hover"`
+					} `json:"lsif"`
+				} `json:"blob"`
+			} `json:"commit"`
+		} `json:"repository"`
+	}
+
+	if err := vd.graphQL(vdHoverQuery, map[string]interface{}{
+		"name":      name,
+		"path":      pos.path,
+		"line":      pos.line,
+		"character": pos.character,
+	}, &resp); err != nil {
+		return "", err
+	}
+
+	switch {
+	case resp.Repository == nil || resp.Repository.Commit == nil:
+		return "", errors.Newf("%s has no HEAD commit", name)
+	case resp.Repository.Commit.Blob == nil:
+		return "", errors.Newf("%s doesn't exist in %s", pos.path, name)
+	case resp.Repository.Commit.Blob.LSIF == nil:
+		return "", errors.Newf("no code intelligence for %s in %s", pos.path, name)
+	case resp.Repository.Commit.Blob.LSIF.Hover == nil || resp.Repository.Commit.Blob.LSIF.Hover.Markdown.Text == "":
+		return "", errors.Newf("no hover at %s:%d:%d", pos.path, pos.line+1, pos.character+1)
+	}
+	return resp.Repository.Commit.Blob.LSIF.Hover.Markdown.Text, nil
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+// writeSmokeJUnit writes the checks as a JUnit test suite that started at
+// start.
+func writeSmokeJUnit(w io.Writer, checks []smokeCheck, start time.Time) error {
+	const suiteName = "src validate smoke"
+	suite := junitTestSuite{
+		Name:      suiteName,
+		Tests:     len(checks),
+		Timestamp: start.UTC().Format("2006-01-02T15:04:05"),
+	}
+	var total time.Duration
+	for _, c := range checks {
+		tc := junitTestCase{
+			Name:      c.name,
+			ClassName: suiteName,
+			Time:      junitSeconds(c.duration),
+		}
+		switch c.status {
+		case checkFailed:
+			suite.Failures++
+			tc.Failure = &junitMessage{Message: c.message}
+		case checkSkipped:
+			suite.Skipped++
+			tc.Skipped = &junitMessage{Message: c.message}
+		default:
+			tc.SystemOut = c.message
+		}
+		total += c.duration
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Time = junitSeconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
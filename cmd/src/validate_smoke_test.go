@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriteSmokeJUnit(t *testing.T) {
+	checks := []smokeCheck{
+		{name: "repo", status: checkPassed, message: "added throwaway repository github.com/sourcegraph/src-cli", duration: 1500 * time.Millisecond},
+		{name: "clone", status: checkFailed, message: `github.com/sourcegraph/src-cli wasn't cloned: timed out`, duration: 2 * time.Second},
+		{name: "search", status: checkSkipped, message: "an earlier check failed"},
+	}
+	var buf bytes.Buffer
+	if err := writeSmokeJUnit(&buf, checks, time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="src validate smoke" tests="3" failures="1" skipped="1" time="3.500" timestamp="2021-10-01T12:00:00">
+    <testcase name="repo" classname="src validate smoke" time="1.500">
+      <system-out>added throwaway repository github.com/sourcegraph/src-cli</system-out>
+    </testcase>
+    <testcase name="clone" classname="src validate smoke" time="2.000">
+      <failure message="github.com/sourcegraph/src-cli wasn&#39;t cloned: timed out"></failure>
+    </testcase>
+    <testcase name="search" classname="src validate smoke" time="0.000">
+      <skipped message="an earlier check failed"></skipped>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("wrong JUnit XML (-want +have):\n%s", diff)
+	}
+}
+
+func TestParseHoverPosition(t *testing.T) {
+	pos, err := parseHoverPosition("cmd/src/main.go:12:6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(&hoverPosition{path: "cmd/src/main.go", line: 11, character: 5}, pos, cmp.AllowUnexported(hoverPosition{})); diff != "" {
+		t.Errorf("wrong position (-want +have):\n%s", diff)
+	}
+
+	for _, value := range []string{"main.go", "main.go:12", "main.go:0:1", "main.go:1:x"} {
+		if _, err := parseHoverPosition(value); err == nil {
+			t.Errorf("parseHoverPosition(%q): expected error", value)
+		}
+	}
+}
@@ -39,6 +39,10 @@ Examples:
 			return nil
 		}
 		fmt.Printf("Recommended version: %s or later\n", recommendedVersion)
+		if recommendedVersion != version.BuildTag {
+			info := version.ReadBuildInfo()
+			fmt.Printf("Download for %s/%s: %s\n", info.OS, info.Arch, srcDownloadURL(info.Artifact))
+		}
 		return nil
 	}
 
@@ -10,20 +10,43 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	ioaux "github.com/jig/teereadcloser"
 	"github.com/kballard/go-shellquote"
 	"github.com/mattn/go-isatty"
+
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+	"github.com/sourcegraph/src-cli/internal/metrics"
+)
+
+var requestDuration = metrics.Default.NewHistogram(
+	"src_api_request_duration_seconds",
+	"Duration of requests to the Sourcegraph API, by type (graphql or http) and status code.",
+	metrics.DefaultBuckets,
+	"type", "code",
 )
 
+// observeRequest records the duration of a request that started at start.
+func observeRequest(typ string, start time.Time, resp *http.Response, err error) {
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	requestDuration.Observe(time.Since(start).Seconds(), typ, code)
+}
+
 // Client instances provide methods to create API requests.
 type Client interface {
 	// NewQuery is a convenience method to create a GraphQL request without
 	// variables.
 	NewQuery(query string) Request
 
-	// NewRequest creates a GraphQL request.
+	// NewRequest creates a GraphQL request. Requests with large variables,
+	// such as diffs, are gzip compressed.
 	NewRequest(query string, vars map[string]interface{}) Request
 
 	// NewGzippedRequest creates a GraphQL request with gzip compression turned on.
@@ -63,8 +86,18 @@ type Request interface {
 type client struct {
 	opts       ClientOpts
 	httpClient *http.Client
+
+	// gzipUnsupported is set to 1 once the instance, or a proxy in front of
+	// it, rejected a gzip compressed request, so that later requests are sent
+	// uncompressed right away.
+	gzipUnsupported int32
 }
 
+// gzipMinSize is the size of request bodies above which requests are gzip
+// compressed even if they weren't created with NewGzippedRequest. Smaller
+// bodies aren't worth the overhead.
+const gzipMinSize = 16 * 1024
+
 // request is the internal concrete type implementing Request.
 type request struct {
 	client *client
@@ -86,6 +119,11 @@ type ClientOpts struct {
 	// Out is the writer that will be used when outputting diagnostics, such as
 	// curl commands when -get-curl is enabled.
 	Out io.Writer
+
+	// ReadOnly makes the client refuse GraphQL mutations and HTTP requests
+	// other than GET, HEAD and OPTIONS with a ReadOnlyError, so that
+	// production credentials can be used without changing anything.
+	ReadOnly bool
 }
 
 // NewClient creates a new API client.
@@ -113,6 +151,7 @@ func NewClient(opts ClientOpts) Client {
 			AdditionalHeaders: opts.AdditionalHeaders,
 			Flags:             flags,
 			Out:               opts.Out,
+			ReadOnly:          opts.ReadOnly,
 		},
 		httpClient: httpClient,
 	}
@@ -144,7 +183,13 @@ func (c *client) NewGzippedQuery(query string) Request {
 }
 
 func (c *client) Do(req *http.Request) (*http.Response, error) {
-	return c.httpClient.Do(req)
+	if err := c.checkReadOnlyRequest(req); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	observeRequest("http", start, resp, err)
+	return resp, err
 }
 
 func (c *client) NewHTTPRequest(ctx context.Context, method, p string, body io.Reader) (*http.Request, error) {
@@ -184,6 +229,10 @@ func (r *request) do(ctx context.Context, result interface{}) (bool, error) {
 		return false, err
 	}
 
+	if err := r.client.checkReadOnlyQuery(r.query); err != nil {
+		return false, err
+	}
+
 	if *r.client.opts.Flags.dump {
 		fmt.Fprintf(r.client.opts.Out, "<-- query:\n%s\n\n", r.query)
 		if len(r.vars) > 0 {
@@ -208,25 +257,34 @@ func (r *request) do(ctx context.Context, result interface{}) (bool, error) {
 		return false, err
 	}
 
-	var bufBody io.Reader = bytes.NewBuffer(reqBody)
-	if r.gzip {
-		bufBody = gzipReader(bufBody)
-	}
-
-	// Create the HTTP request.
-	req, err := r.client.NewHTTPRequest(ctx, "POST", ".api/graphql", bufBody)
+	// Perform the request. If the compressed request is rejected with 415
+	// Unsupported Media Type, because a proxy in front of the instance doesn't
+	// support compression, or the instance failed to decode it as JSON
+	// because it's too old to support compression, it is sent again without
+	// compression. Other errors, such as a 400 for an invalid query or a 500,
+	// are returned as they are: the request may have been executed, and
+	// mutations mustn't be sent twice.
+	compress := (r.gzip || len(reqBody) >= gzipMinSize) && atomic.LoadInt32(&r.client.gzipUnsupported) == 0
+	resp, err := r.send(ctx, reqBody, compress)
 	if err != nil {
 		return false, err
 	}
-
-	if r.gzip {
-		req.Header.Set("Content-Encoding", "gzip")
-	}
-
-	// Perform the request.
-	resp, err := r.client.httpClient.Do(req)
-	if err != nil {
-		return false, err
+	if compress {
+		rejected, err := gzipRejected(resp)
+		if err != nil {
+			resp.Body.Close()
+			return false, err
+		}
+		if rejected {
+			resp.Body.Close()
+			resp, err = r.send(ctx, reqBody, false)
+			if err != nil {
+				return false, err
+			}
+			if resp.StatusCode == http.StatusOK {
+				atomic.StoreInt32(&r.client.gzipUnsupported, 1)
+			}
+		}
 	}
 	defer resp.Body.Close()
 
@@ -251,7 +309,7 @@ func (r *request) do(ctx context.Context, result interface{}) (bool, error) {
 		if err != nil {
 			return false, err
 		}
-		return false, fmt.Errorf("error: %s\n\n%s", resp.Status, body)
+		return false, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status, Body: body}
 	}
 
 	body := resp.Body
@@ -273,6 +331,55 @@ func (r *request) do(ctx context.Context, result interface{}) (bool, error) {
 	return true, nil
 }
 
+// gzipDecodeError is the error of instances that don't support compressed
+// requests, which fail to decode the gzip header as JSON.
+const gzipDecodeError = `invalid character '\x1f' looking for beginning of value`
+
+// gzipRejected returns whether the response is a rejection of a compressed
+// request that wasn't executed, because the instance or a proxy in front of it
+// couldn't decode it. The body of error responses that are read to find out is
+// replaced, so that it can be read again.
+func gzipRejected(resp *http.Response) (bool, error) {
+	switch resp.StatusCode {
+	case http.StatusUnsupportedMediaType:
+		return true, nil
+	case http.StatusBadRequest, http.StatusInternalServerError:
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return false, err
+		}
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return bytes.Contains(body, []byte(gzipDecodeError)), nil
+	default:
+		return false, nil
+	}
+}
+
+// send sends the JSON encoded request body to the GraphQL endpoint, gzip
+// compressed if compress is true. Responses are compressed whenever the
+// instance supports it, since the transport of the HTTP client asks for gzip
+// encoding and transparently decompresses the response.
+func (r *request) send(ctx context.Context, reqBody []byte, compress bool) (*http.Response, error) {
+	var body io.Reader = bytes.NewReader(reqBody)
+	if compress {
+		body = gzipReader(body)
+	}
+
+	req, err := r.client.NewHTTPRequest(ctx, "POST", ".api/graphql", body)
+	if err != nil {
+		return nil, err
+	}
+	if compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	start := time.Now()
+	resp, err := r.client.httpClient.Do(req)
+	observeRequest("graphql", start, resp, err)
+	return resp, err
+}
+
 // Do executes the request. Successful requests will be unmarshalled into the
 // given result. If GraphQL errors are returned, then the returned error will be
 // an instance of GraphQlErrors. Other errors (such as HTTP or network errors)
@@ -326,3 +433,16 @@ func (r *request) curlCmd() (string, error) {
 	s += fmt.Sprintf("   %s", shellquote.Join(r.client.opts.Endpoint+"/.api/graphql"))
 	return s, nil
 }
+
+// StatusKind returns the kind of failure of a request that failed with the
+// given HTTP status code.
+func StatusKind(code int) cmderrors.Kind {
+	switch code {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return cmderrors.KindAuth
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return cmderrors.KindNetwork
+	default:
+		return cmderrors.KindFailure
+	}
+}
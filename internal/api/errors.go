@@ -2,9 +2,12 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 
 	"github.com/cockroachdb/errors"
 	"github.com/hashicorp/go-multierror"
+
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
 )
 
 // GraphQlErrors contains one or more GraphQlError instances.
@@ -28,6 +31,9 @@ func (gg GraphQlErrors) Error() string {
 	return errors.Wrap(errs.ErrorOrNil(), "GraphQL errors").Error()
 }
 
+// Kind returns the kind of failure GraphQL errors are.
+func (gg GraphQlErrors) Kind() cmderrors.Kind { return cmderrors.KindGraphQL }
+
 // GraphQlError wraps a raw JSON error returned from a GraphQL endpoint.
 type GraphQlError struct{ v interface{} }
 
@@ -70,7 +76,23 @@ func (g *GraphQlError) Extensions() (map[string]interface{}, error) {
 	return nil, errors.Errorf("unexpected extensions of type %T", e["extensions"])
 }
 
+// StatusError is returned for requests that fail with an HTTP status other
+// than 200 OK, before reaching the GraphQL endpoint.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("error: %s\n\n%s", e.Status, e.Body)
+}
+
+// Kind returns the kind of failure the status is.
+func (e *StatusError) Kind() cmderrors.Kind { return StatusKind(e.StatusCode) }
+
 var (
 	_ error = &GraphQlError{}
 	_ error = GraphQlErrors{}
+	_ error = &StatusError{}
 )
@@ -2,7 +2,12 @@ package api
 
 import (
 	"encoding/json"
+	"net/http"
 	"testing"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
 )
 
 func TestGraphQLError_Code(t *testing.T) {
@@ -117,3 +122,21 @@ func TestGraphQLError_Code(t *testing.T) {
 	}
 
 }
+
+func TestStatusError(t *testing.T) {
+	for code, want := range map[int]cmderrors.Kind{
+		http.StatusUnauthorized:        cmderrors.KindAuth,
+		http.StatusBadRequest:          cmderrors.KindFailure,
+		http.StatusInternalServerError: cmderrors.KindFailure,
+		http.StatusBadGateway:          cmderrors.KindNetwork,
+	} {
+		err := errors.Wrap(&StatusError{StatusCode: code, Status: http.StatusText(code)}, "request")
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != code {
+			t.Errorf("%d: StatusError not found in %v", code, err)
+		}
+		if have := cmderrors.Classify(&StatusError{StatusCode: code}); have != want {
+			t.Errorf("%d: wrong kind: want %q, have %q", code, want, have)
+		}
+	}
+}
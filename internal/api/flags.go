@@ -18,6 +18,13 @@ func (f *Flags) Trace() bool {
 	return *(f.trace)
 }
 
+func (f *Flags) GetCurl() bool {
+	if f.getCurl == nil {
+		return false
+	}
+	return *(f.getCurl)
+}
+
 // NewFlags instantiates a new Flags structure and attaches flags to the given
 // flag set.
 func NewFlags(flagSet *flag.FlagSet) *Flags {
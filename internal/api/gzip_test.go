@@ -3,7 +3,13 @@ package api
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -32,3 +38,134 @@ func TestGzipReader(t *testing.T) {
 		t.Errorf("unexpected gzipped contents (-want +got):\n%s", diff)
 	}
 }
+
+func TestRequestCompression(t *testing.T) {
+	for name, tc := range map[string]struct {
+		query         string
+		gzip          bool
+		vars          map[string]interface{}
+		rejectGzip    int
+		rejectBody    string
+		wantEncodings []string
+		wantErr       bool
+		// wantGzipUnsupported is whether compression is turned off for
+		// later requests.
+		wantGzipUnsupported bool
+	}{
+		"small request": {
+			vars:          map[string]interface{}{"a": "b"},
+			wantEncodings: []string{"", ""},
+		},
+		"large request": {
+			vars:          map[string]interface{}{"diff": strings.Repeat("+x\n", gzipMinSize)},
+			wantEncodings: []string{"gzip", "gzip"},
+		},
+		"gzipped request": {
+			gzip:          true,
+			vars:          map[string]interface{}{"a": "b"},
+			wantEncodings: []string{"gzip", "gzip"},
+		},
+		"gzip rejected": {
+			gzip:       true,
+			vars:       map[string]interface{}{"a": "b"},
+			rejectGzip: http.StatusUnsupportedMediaType,
+			// The first request is sent again uncompressed, the second
+			// isn't compressed at all.
+			wantEncodings:       []string{"gzip", "", ""},
+			wantGzipUnsupported: true,
+		},
+		"gzip not decoded": {
+			vars:       map[string]interface{}{"diff": strings.Repeat("+x\n", gzipMinSize)},
+			rejectGzip: http.StatusInternalServerError,
+			rejectBody: gzipDecodeError,
+			// Instances that don't support compression fail to decode the
+			// request, which is sent again uncompressed.
+			wantEncodings:       []string{"gzip", "", ""},
+			wantGzipUnsupported: true,
+		},
+		"large mutation failed": {
+			query:      "mutation",
+			vars:       map[string]interface{}{"diff": strings.Repeat("+x\n", gzipMinSize)},
+			rejectGzip: http.StatusInternalServerError,
+			// The mutation may have been executed, so it isn't sent again,
+			// and compression stays on.
+			wantEncodings: []string{"gzip", "gzip"},
+			wantErr:       true,
+		},
+		"bad request": {
+			gzip:       true,
+			vars:       map[string]interface{}{"a": "b"},
+			rejectGzip: http.StatusBadRequest,
+			// Other errors are returned without sending the request
+			// again, and compression stays on.
+			wantEncodings: []string{"gzip", "gzip"},
+			wantErr:       true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var encodings []string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				encoding := r.Header.Get("Content-Encoding")
+				encodings = append(encodings, encoding)
+				if encoding == "gzip" && tc.rejectGzip != 0 {
+					http.Error(w, tc.rejectBody, tc.rejectGzip)
+					return
+				}
+
+				body := io.Reader(r.Body)
+				if encoding == "gzip" {
+					gr, err := gzip.NewReader(r.Body)
+					if err != nil {
+						t.Error(err)
+						http.Error(w, err.Error(), http.StatusInternalServerError)
+						return
+					}
+					body = gr
+				}
+				var req struct{ Query string }
+				if err := json.NewDecoder(body).Decode(&req); err != nil {
+					t.Error(err)
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				fmt.Fprintf(w, `{"data":{"query":%q}}`, req.Query)
+			}))
+			defer ts.Close()
+
+			query := tc.query
+			if query == "" {
+				query = "query"
+			}
+			client := NewClient(ClientOpts{Endpoint: ts.URL, Out: io.Discard})
+			for i := 0; i < 2; i++ {
+				var req Request
+				if tc.gzip {
+					req = client.NewGzippedRequest(query, tc.vars)
+				} else {
+					req = client.NewRequest(query, tc.vars)
+				}
+				var result struct{ Query string }
+				ok, err := req.Do(context.Background(), &result)
+				if tc.wantErr {
+					if err == nil {
+						t.Fatal("unexpected nil error")
+					}
+					continue
+				}
+				if err != nil || !ok {
+					t.Fatalf("unexpected result: ok=%t err=%v", ok, err)
+				}
+				if result.Query != query {
+					t.Fatalf("wrong result: %q", result.Query)
+				}
+			}
+
+			if diff := cmp.Diff(tc.wantEncodings, encodings); diff != "" {
+				t.Errorf("wrong request encodings (-want +have):\n%s", diff)
+			}
+			if have := client.(*client).gzipUnsupported == 1; have != tc.wantGzipUnsupported {
+				t.Errorf("wrong gzipUnsupported: want %t, have %t", tc.wantGzipUnsupported, have)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"unicode"
+
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+// ReadOnlyError is returned for requests that would change the Sourcegraph
+// instance, or the code hosts connected to it, while src is in read-only
+// mode.
+type ReadOnlyError struct {
+	// Operation describes what was refused, like "run the mutation
+	// ApplyBatchChange".
+	Operation string
+}
+
+func (e *ReadOnlyError) Error() string {
+	return fmt.Sprintf("refusing to %s: src is in read-only mode, which is enabled by -read-only or \"readOnly\" in the config", e.Operation)
+}
+
+// Kind makes read-only errors usage errors, since the invocation asks for
+// something the configuration forbids.
+func (e *ReadOnlyError) Kind() cmderrors.Kind { return cmderrors.KindUsage }
+
+// checkReadOnlyQuery returns a ReadOnlyError if the GraphQL query contains a
+// mutation and the client is read-only.
+func (c *client) checkReadOnlyQuery(query string) error {
+	if !c.opts.ReadOnly {
+		return nil
+	}
+	return MutationError(query)
+}
+
+// MutationError returns a ReadOnlyError if the GraphQL query contains a
+// mutation, and nil otherwise.
+func MutationError(query string) error {
+	name, ok := MutationName(query)
+	if !ok {
+		return nil
+	}
+	if name == "" {
+		return &ReadOnlyError{Operation: "run a GraphQL mutation"}
+	}
+	return &ReadOnlyError{Operation: "run the mutation " + name}
+}
+
+// checkReadOnlyRequest returns a ReadOnlyError if the HTTP request could
+// change something and the client is read-only. Only requests with safe
+// methods are allowed, since the REST endpoints don't tell whether they
+// change anything.
+func (c *client) checkReadOnlyRequest(req *http.Request) error {
+	if !c.opts.ReadOnly {
+		return nil
+	}
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions:
+		return nil
+	}
+	return &ReadOnlyError{Operation: fmt.Sprintf("send a %s request to %s", req.Method, req.URL.Path)}
+}
+
+// MutationName returns the name of the first mutation in the GraphQL document
+// query, and whether it contains a mutation at all. The name is empty for
+// anonymous mutations.
+//
+// The document isn't validated: only its top-level definitions are looked at,
+// skipping comments, strings, and the contents of selection sets and argument
+// lists, so that fields or arguments named mutation don't count.
+func MutationName(query string) (string, bool) {
+	var (
+		depth int
+		// definitionStart is whether the next name starts a definition, and
+		// isn't the name of an operation or a fragment.
+		definitionStart = true
+		inMutation      bool
+	)
+
+	runes := []rune(query)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '#':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '"':
+			i = skipString(runes, i)
+		case r == '{' || r == '(' || r == '[':
+			if inMutation && depth == 0 {
+				return "", true
+			}
+			depth++
+		case r == '}' || r == ')' || r == ']':
+			depth--
+			if depth == 0 && r == '}' {
+				definitionStart = true
+			}
+		case depth == 0 && (r == '_' || unicode.IsLetter(r)):
+			start := i
+			for i+1 < len(runes) && (runes[i+1] == '_' || unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1])) {
+				i++
+			}
+			name := string(runes[start : i+1])
+			if inMutation {
+				return name, true
+			}
+			if definitionStart {
+				inMutation = name == "mutation"
+				definitionStart = false
+			}
+		}
+	}
+	return "", inMutation
+}
+
+// skipString returns the index of the closing quote of the string or block
+// string starting at runes[start].
+func skipString(runes []rune, start int) int {
+	if start+2 < len(runes) && runes[start+1] == '"' && runes[start+2] == '"' {
+		for i := start + 3; i+2 < len(runes); i++ {
+			if runes[i] == '\\' {
+				i++
+				continue
+			}
+			if runes[i] == '"' && runes[i+1] == '"' && runes[i+2] == '"' {
+				return i + 2
+			}
+		}
+		return len(runes)
+	}
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		case '\n':
+			return i
+		}
+	}
+	return len(runes)
+}
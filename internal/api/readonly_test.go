@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func TestMutationName(t *testing.T) {
+	for name, tc := range map[string]struct {
+		query    string
+		wantName string
+		wantOK   bool
+	}{
+		"anonymous query": {query: `{ currentUser { username } }`},
+		"named query":     {query: `query CurrentUser { currentUser { username } }`},
+		"field named mutation": {
+			query: `query Q($mutation: String) { mutation: repository(name: "mutation") { name } }`,
+		},
+		"query named mutation": {query: `query mutation { currentUser { id } }`},
+		"comment":              {query: "# mutation Foo {\n{ currentUser { id } }"},
+		"string": {
+			query: `query { search(query: "}mutation Foo { x }") { results { matchCount } } }`,
+		},
+		"block string": {
+			query: "query { search(query: \"\"\"}\nmutation Foo { x }\"\"\") { results { matchCount } } }",
+		},
+		"named mutation": {
+			query:    `mutation ApplyBatchChange($batchSpec: ID!) { applyBatchChange(batchSpec: $batchSpec) { id } }`,
+			wantName: "ApplyBatchChange",
+			wantOK:   true,
+		},
+		"anonymous mutation": {
+			query:  `mutation { deleteUser(user: "VXNlcjox") { alwaysNil } }`,
+			wantOK: true,
+		},
+		"anonymous mutation with variables": {
+			query:  `mutation($id: ID!) { deleteUser(user: $id) { alwaysNil } }`,
+			wantOK: true,
+		},
+		"mutation after fragment": {
+			query: `fragment F on User { id }
+# a comment
+mutation CreateUser { createUser(username: "a") { user { ...F } } }`,
+			wantName: "CreateUser",
+			wantOK:   true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			haveName, haveOK := MutationName(tc.query)
+			if haveName != tc.wantName || haveOK != tc.wantOK {
+				t.Errorf("wrong result: have (%q, %v), want (%q, %v)", haveName, haveOK, tc.wantName, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestReadOnlyClient(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = io.WriteString(w, `{"data": {}}`)
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	client := NewClient(ClientOpts{Endpoint: ts.URL, Out: io.Discard, ReadOnly: true})
+
+	if _, err := client.NewQuery(`query { currentUser { id } }`).Do(ctx, &struct{}{}); err != nil {
+		t.Errorf("unexpected error for query: %v", err)
+	}
+
+	_, err := client.NewQuery(`mutation DeleteUser { deleteUser(user: "a") { alwaysNil } }`).Do(ctx, &struct{}{})
+	var readOnlyErr *ReadOnlyError
+	if !errors.As(err, &readOnlyErr) || readOnlyErr.Operation != "run the mutation DeleteUser" {
+		t.Errorf("wrong error for mutation: %v", err)
+	}
+	if kind := cmderrors.Classify(err); kind != cmderrors.KindUsage {
+		t.Errorf("wrong kind: %s", kind)
+	}
+
+	req, err := client.NewHTTPRequest(ctx, "GET", ".api/src-cli/version", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Errorf("unexpected error for GET request: %v", err)
+	} else {
+		resp.Body.Close()
+	}
+
+	req, err = client.NewHTTPRequest(ctx, "POST", ".api/lsif/upload", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Do(req); !errors.As(err, &readOnlyErr) || readOnlyErr.Operation != "send a POST request to /.api/lsif/upload" {
+		t.Errorf("wrong error for POST request: %v", err)
+	}
+
+	if requests != 2 {
+		t.Errorf("want 2 requests to reach the server, have %d", requests)
+	}
+}
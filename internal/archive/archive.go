@@ -0,0 +1,140 @@
+// Package archive downloads archives of repositories from a Sourcegraph
+// instance.
+package archive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Formats are the archive formats served by the raw API, keyed by file
+// extension.
+var Formats = map[string]string{
+	"tar": "application/x-tar",
+	"zip": "application/zip",
+}
+
+// Repo is a repository at a specific commit.
+type Repo struct {
+	Name   string `json:"name"`
+	Commit string `json:"commit"`
+}
+
+// HTTPClient provides an interface to run API requests.
+type HTTPClient interface {
+	NewHTTPRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error)
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Path returns the path of the archive of the repository in dir. It contains
+// the commit, so that archives of other revisions are downloaded again.
+func Path(dir string, repo Repo, format string) string {
+	return filepath.Join(dir, strings.ReplaceAll(repo.Name, "/", "-")+"-"+repo.Commit+"."+format)
+}
+
+// Download downloads the archive of the repository to dest, unless it
+// already exists, in which case downloaded is false. The archive is written
+// to a temporary file first, so that an interrupted download is never
+// mistaken for a complete archive.
+func Download(ctx context.Context, client HTTPClient, repo Repo, dest, format string) (downloaded bool, err error) {
+	accept, ok := Formats[format]
+	if !ok {
+		return false, errors.Newf("unsupported archive format %q", format)
+	}
+
+	if _, err := os.Stat(dest); err == nil {
+		return false, nil
+	} else if !os.IsNotExist(err) {
+		return false, err
+	}
+
+	req, err := client.NewHTTPRequest(ctx, "GET", repo.Name+"@"+repo.Commit+"/-/raw", nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", accept)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unable to fetch archive (HTTP %d from %s)", resp.StatusCode, req.URL.String())
+	}
+
+	tmp := dest + ".part"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp)
+
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return false, err
+	}
+	if err := f.Close(); err != nil {
+		return false, err
+	}
+	return true, os.Rename(tmp, dest)
+}
+
+// Result is the outcome of downloading the archive of a repository.
+type Result struct {
+	Repo
+	File       string `json:"file"`
+	Downloaded bool   `json:"downloaded"`
+	Error      string `json:"error,omitempty"`
+}
+
+// DownloadAll downloads the archives of the repositories to dir, with at most
+// parallelism downloads at a time. Archives that already exist are skipped, so
+// that an interrupted export can be resumed. The progress function, if not
+// nil, is called after each repository.
+func DownloadAll(ctx context.Context, client HTTPClient, repos []Repo, dir, format string, parallelism int, progress func(Result)) []Result {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	var (
+		results = make([]Result, len(repos))
+		sem     = make(chan struct{}, parallelism)
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+	)
+	for i, repo := range repos {
+		wg.Add(1)
+		go func(i int, repo Repo) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			res := Result{Repo: repo, File: Path(dir, repo, format)}
+			downloaded, err := Download(ctx, client, repo, res.File, format)
+			res.Downloaded = downloaded
+			if err != nil {
+				res.Error = err.Error()
+			}
+			results[i] = res
+
+			if progress != nil {
+				mu.Lock()
+				progress(res)
+				mu.Unlock()
+			}
+		}(i, repo)
+	}
+	wg.Wait()
+
+	return results
+}
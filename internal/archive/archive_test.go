@@ -0,0 +1,100 @@
+package archive
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type testClient struct {
+	url string
+}
+
+func (c *testClient) NewHTTPRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	return http.NewRequestWithContext(ctx, method, c.url+"/"+path, body)
+}
+
+func (c *testClient) Do(req *http.Request) (*http.Response, error) {
+	return http.DefaultClient.Do(req)
+}
+
+func TestDownloadAll(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.Header.Get("Accept") != "application/zip" {
+			t.Errorf("wrong Accept header: %q", r.Header.Get("Accept"))
+		}
+		switch r.URL.Path {
+		case "/github.com/a/b@c0ffee/-/raw":
+			io.WriteString(w, "zip of a/b")
+		case "/github.com/a/c@f00b4r/-/raw":
+			io.WriteString(w, "zip of a/c")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	client := &testClient{url: ts.URL}
+	repos := []Repo{
+		{Name: "github.com/a/b", Commit: "c0ffee"},
+		{Name: "github.com/a/c", Commit: "f00b4r"},
+		{Name: "github.com/a/missing", Commit: "deadbeef"},
+	}
+
+	// An archive of a previous, interrupted run is kept.
+	if err := os.WriteFile(Path(dir, repos[1], "zip"), []byte("existing"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	results := DownloadAll(context.Background(), client, repos, dir, "zip", 2, nil)
+
+	if results[0].Error != "" || !results[0].Downloaded {
+		t.Errorf("unexpected result for a/b: %+v", results[0])
+	}
+	if results[1].Error != "" || results[1].Downloaded {
+		t.Errorf("unexpected result for a/c: %+v", results[1])
+	}
+	if results[2].Error == "" {
+		t.Errorf("unexpected nil error for missing repository")
+	}
+	if have := atomic.LoadInt32(&requests); have != 2 {
+		t.Errorf("wrong number of requests: have=%d want=2", have)
+	}
+
+	for file, want := range map[string]string{
+		"github.com-a-b-c0ffee.zip": "zip of a/b",
+		"github.com-a-c-f00b4r.zip": "existing",
+	} {
+		have, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(want, string(have)); diff != "" {
+			t.Errorf("wrong content of %s (-want +have):\n%s", file, diff)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("unexpected files left in %s: %v", dir, entries)
+	}
+}
+
+func TestDownloadUnsupportedFormat(t *testing.T) {
+	if _, err := Download(context.Background(), &testClient{}, Repo{Name: "a"}, "a.rar", "rar"); err == nil {
+		t.Error("unexpected nil error for unsupported format")
+	}
+}
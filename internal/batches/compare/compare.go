@@ -0,0 +1,147 @@
+// Package compare compares two sets of changesets generated from batch specs,
+// for example by two versions of a batch spec, to find the changesets that
+// were added, removed, or whose diffs changed.
+package compare
+
+import (
+	"sort"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sourcegraph/go-diff/diff"
+)
+
+// Changeset is the part of a changeset spec that is compared.
+type Changeset struct {
+	// Repository is the ID of the base repository.
+	Repository string
+	// Branch is the head ref of the changeset. It's empty for imported
+	// changesets.
+	Branch string
+	// ExternalID is the ID of imported changesets on the code host.
+	ExternalID string
+	// Diff is the unified diff of all commits of the changeset.
+	Diff string
+}
+
+func (c *Changeset) key() string {
+	if c.ExternalID != "" {
+		return c.Repository + "\x00external\x00" + c.ExternalID
+	}
+	return c.Repository + "\x00" + c.Branch
+}
+
+// Status describes how a changeset differs between the two sets.
+type Status string
+
+const (
+	Unchanged Status = "unchanged"
+	Changed   Status = "changed"
+	Added     Status = "added"
+	Removed   Status = "removed"
+)
+
+// Stat is the number of lines added and deleted by a diff.
+type Stat struct {
+	Added   int
+	Deleted int
+}
+
+// Result is the comparison of a changeset in the old and the new set. Old is
+// nil for added changesets, and New for removed ones.
+type Result struct {
+	Old, New *Changeset
+	Status   Status
+
+	OldStat, NewStat Stat
+}
+
+// Changeset returns the new version of the changeset, or the old one if it
+// was removed.
+func (r Result) Changeset() *Changeset {
+	if r.New != nil {
+		return r.New
+	}
+	return r.Old
+}
+
+// Changesets compares the old and the new set of changesets. Changesets are
+// matched by their repository and branch, or their external ID if they're
+// imported. The results are sorted by repository and branch.
+func Changesets(old, new []*Changeset) ([]Result, error) {
+	byKey := map[string]*Result{}
+	var keys []string
+	result := func(c *Changeset) *Result {
+		k := c.key()
+		if r, ok := byKey[k]; ok {
+			return r
+		}
+		r := &Result{}
+		byKey[k] = r
+		keys = append(keys, k)
+		return r
+	}
+
+	for _, c := range old {
+		r := result(c)
+		if r.Old != nil {
+			return nil, errors.Newf("duplicate old changeset for branch %q in repository %q", c.Branch, c.Repository)
+		}
+		r.Old = c
+	}
+	for _, c := range new {
+		r := result(c)
+		if r.New != nil {
+			return nil, errors.Newf("duplicate new changeset for branch %q in repository %q", c.Branch, c.Repository)
+		}
+		r.New = c
+	}
+
+	sort.Strings(keys)
+	results := make([]Result, 0, len(keys))
+	for _, k := range keys {
+		r := byKey[k]
+
+		var err error
+		if r.Old != nil {
+			if r.OldStat, err = DiffStat(r.Old.Diff); err != nil {
+				return nil, errors.Wrapf(err, "parsing old diff in repository %q", r.Old.Repository)
+			}
+		}
+		if r.New != nil {
+			if r.NewStat, err = DiffStat(r.New.Diff); err != nil {
+				return nil, errors.Wrapf(err, "parsing new diff in repository %q", r.New.Repository)
+			}
+		}
+
+		switch {
+		case r.Old == nil:
+			r.Status = Added
+		case r.New == nil:
+			r.Status = Removed
+		case r.Old.Diff != r.New.Diff:
+			r.Status = Changed
+		default:
+			r.Status = Unchanged
+		}
+		results = append(results, *r)
+	}
+	return results, nil
+}
+
+// DiffStat counts the lines added and deleted by the unified diff.
+func DiffStat(raw string) (Stat, error) {
+	fileDiffs, err := diff.ParseMultiFileDiff([]byte(raw))
+	if err != nil {
+		return Stat{}, err
+	}
+
+	var stat Stat
+	for _, fd := range fileDiffs {
+		s := fd.Stat()
+		// go-diff counts a deleted line followed by an added one as a
+		// single changed line.
+		stat.Added += int(s.Added + s.Changed)
+		stat.Deleted += int(s.Deleted + s.Changed)
+	}
+	return stat, nil
+}
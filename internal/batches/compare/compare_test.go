@@ -0,0 +1,76 @@
+package compare
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const diffA = `diff --git README.md README.md
+--- README.md
++++ README.md
+@@ -1,2 +1,2 @@
+ # Title
+-old
++new
+`
+
+const diffB = `diff --git README.md README.md
+--- README.md
++++ README.md
+@@ -1,2 +1,3 @@
+ # Title
+-old
++new
++more
+`
+
+func TestChangesets(t *testing.T) {
+	old := []*Changeset{
+		{Repository: "repo-1", Branch: "refs/heads/a", Diff: diffA},
+		{Repository: "repo-2", Branch: "refs/heads/a", Diff: diffA},
+		{Repository: "repo-3", Branch: "refs/heads/a", Diff: diffA},
+		{Repository: "repo-4", ExternalID: "123"},
+	}
+	new := []*Changeset{
+		{Repository: "repo-4", ExternalID: "123"},
+		{Repository: "repo-2", Branch: "refs/heads/a", Diff: diffB},
+		{Repository: "repo-1", Branch: "refs/heads/a", Diff: diffA},
+		{Repository: "repo-5", Branch: "refs/heads/a", Diff: diffB},
+	}
+
+	results, err := Changesets(old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type summary struct {
+		Repository string
+		Status     Status
+		Old, New   Stat
+	}
+	var have []summary
+	for _, r := range results {
+		have = append(have, summary{r.Changeset().Repository, r.Status, r.OldStat, r.NewStat})
+	}
+	want := []summary{
+		{"repo-1", Unchanged, Stat{1, 1}, Stat{1, 1}},
+		{"repo-2", Changed, Stat{1, 1}, Stat{2, 1}},
+		{"repo-3", Removed, Stat{1, 1}, Stat{}},
+		{"repo-4", Unchanged, Stat{}, Stat{}},
+		{"repo-5", Added, Stat{}, Stat{2, 1}},
+	}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Fatalf("wrong results (-want +have):\n%s", diff)
+	}
+}
+
+func TestChangesets_Duplicate(t *testing.T) {
+	specs := []*Changeset{
+		{Repository: "repo-1", Branch: "refs/heads/a", Diff: diffA},
+		{Repository: "repo-1", Branch: "refs/heads/a", Diff: diffB},
+	}
+	if _, err := Changesets(nil, specs); err == nil {
+		t.Fatal("unexpected nil error")
+	}
+}
@@ -0,0 +1,139 @@
+package diff
+
+import (
+	"path"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gobwas/glob"
+	godiff "github.com/sourcegraph/go-diff/diff"
+)
+
+// FileFilter selects the files of a diff by their paths, using include and
+// exclude globs.
+//
+// Globs without a slash are matched against the base name of a file, so that
+// "*.lock" matches lockfiles in any directory. Other globs are matched
+// against the path of the file relative to the repository root, where "*"
+// doesn't match slashes and "**" does. A trailing slash matches everything in
+// a directory: "vendor/" is the same as "vendor/**".
+type FileFilter struct {
+	include []glob.Glob
+	exclude []glob.Glob
+}
+
+// NewFileFilter returns a FileFilter that selects the files matching one of
+// the include globs, or all files if there are none, unless they match one
+// of the exclude globs. If there are no globs at all, it returns nil, which
+// selects all files.
+func NewFileFilter(include, exclude []string) (*FileFilter, error) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil, nil
+	}
+
+	compile := func(patterns []string) ([]glob.Glob, error) {
+		globs := make([]glob.Glob, 0, len(patterns))
+		for _, p := range patterns {
+			if strings.HasSuffix(p, "/") {
+				p += "**"
+			}
+			g, err := glob.Compile(strings.TrimPrefix(p, "/"), '/')
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid glob %q", p)
+			}
+			globs = append(globs, g)
+		}
+		return globs, nil
+	}
+
+	f := &FileFilter{}
+	var err error
+	if f.include, err = compile(include); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = compile(exclude); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Match returns true if the file at the given path is selected by the filter.
+func (f *FileFilter) Match(name string) bool {
+	if f == nil {
+		return true
+	}
+
+	matchAny := func(globs []glob.Glob) bool {
+		for _, g := range globs {
+			if g.Match(name) || g.Match(path.Base(name)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(f.include) > 0 && !matchAny(f.include) {
+		return false
+	}
+	return !matchAny(f.exclude)
+}
+
+// Apply returns the unified diff with only the files selected by the filter.
+// A renamed file is kept if either its old or its new path is selected.
+func (f *FileFilter) Apply(raw string) (string, error) {
+	if f == nil || raw == "" {
+		return raw, nil
+	}
+
+	fileDiffs, err := godiff.ParseMultiFileDiff([]byte(raw))
+	if err != nil {
+		return "", errors.Wrap(err, "parsing diff")
+	}
+
+	var kept []*godiff.FileDiff
+	for _, fd := range fileDiffs {
+		for _, name := range []string{fileDiffPath(fd.OrigName), fileDiffPath(fd.NewName)} {
+			if name != "" && f.Match(name) {
+				kept = append(kept, fd)
+				break
+			}
+		}
+	}
+	if len(kept) == len(fileDiffs) {
+		return raw, nil
+	}
+	if len(kept) == 0 {
+		return "", nil
+	}
+
+	printed, err := godiff.PrintMultiFileDiff(kept)
+	if err != nil {
+		return "", errors.Wrap(err, "printing diff")
+	}
+	return string(printed), nil
+}
+
+// Paths returns the paths selected by the filter.
+func (f *FileFilter) Paths(paths []string) []string {
+	if f == nil {
+		return paths
+	}
+
+	selected := []string{}
+	for _, p := range paths {
+		if f.Match(p) {
+			selected = append(selected, p)
+		}
+	}
+	return selected
+}
+
+// fileDiffPath returns the path of a file in a diff, which is empty for
+// /dev/null. The diffs of batch changes are created with --no-prefix, so the
+// path doesn't start with a/ or b/.
+func fileDiffPath(name string) string {
+	if name == "/dev/null" {
+		return ""
+	}
+	return name
+}
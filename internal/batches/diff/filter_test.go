@@ -0,0 +1,95 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFileFilter(t *testing.T) {
+	const (
+		readme = `diff --git README.md README.md
+index 3363c39..88f1836 100644
+--- README.md
++++ README.md
+@@ -1,1 +1,1 @@
+-# README
++# Readme
+`
+		lockfile = `diff --git web/yarn.lock web/yarn.lock
+index 3363c39..88f1836 100644
+--- web/yarn.lock
++++ web/yarn.lock
+@@ -1,1 +1,1 @@
+-a
++b
+`
+		vendored = `diff --git vendor/github.com/a/b/b.go vendor/github.com/a/b/b.go
+deleted file mode 100644
+index 3363c39..0000000
+--- vendor/github.com/a/b/b.go
++++ /dev/null
+@@ -1,1 +0,0 @@
+-package b
+`
+	)
+	all := readme + lockfile + vendored
+
+	for name, tc := range map[string]struct {
+		include, exclude []string
+		want             string
+	}{
+		"no globs": {
+			want: all,
+		},
+		"exclude by base name and directory": {
+			exclude: []string{"*.lock", "vendor/"},
+			want:    readme,
+		},
+		"include": {
+			include: []string{"web/**"},
+			want:    lockfile,
+		},
+		"include and exclude": {
+			include: []string{"*.md", "vendor/**/*.go"},
+			exclude: []string{"vendor/github.com/a/**"},
+			want:    readme,
+		},
+		"nothing selected": {
+			include: []string{"*.go"},
+			exclude: []string{"*.go"},
+			want:    "",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			f, err := NewFileFilter(tc.include, tc.exclude)
+			if err != nil {
+				t.Fatal(err)
+			}
+			have, err := f.Apply(all)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, have); diff != "" {
+				t.Errorf("wrong diff (-want +have):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("paths", func(t *testing.T) {
+		f, err := NewFileFilter(nil, []string{"*.lock"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		have := f.Paths([]string{"README.md", "web/yarn.lock"})
+		if diff := cmp.Diff([]string{"README.md"}, have); diff != "" {
+			t.Errorf("wrong paths (-want +have):\n%s", diff)
+		}
+	})
+
+	t.Run("invalid glob", func(t *testing.T) {
+		if _, err := NewFileFilter([]string{"[a"}, nil); err == nil {
+			t.Error("unexpected nil error")
+		}
+	})
+}
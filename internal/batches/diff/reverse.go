@@ -0,0 +1,202 @@
+// Package diff contains helpers to manipulate the unified diffs that are
+// produced by executing batch specs and stored in changeset specs.
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Reverse returns a unified diff that undoes the changes in the given unified
+// diff: applying the result to the new version of the files yields the
+// original version.
+func Reverse(raw string) (string, error) {
+	var out strings.Builder
+	lines := strings.SplitAfter(raw, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		next := ""
+		if i+1 < len(lines) {
+			next = lines[i+1]
+		}
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			out.WriteString(reverseGitHeader(line))
+
+		case strings.HasPrefix(line, "--- ") && strings.HasPrefix(next, "+++ "):
+			orig, new := strings.TrimPrefix(line, "--- "), strings.TrimPrefix(next, "+++ ")
+			out.WriteString("--- " + swapPrefix(new, "b/", "a/"))
+			out.WriteString("+++ " + swapPrefix(orig, "a/", "b/"))
+			i++
+
+		case strings.HasPrefix(line, "new file mode "):
+			out.WriteString("deleted file mode " + strings.TrimPrefix(line, "new file mode "))
+		case strings.HasPrefix(line, "deleted file mode "):
+			out.WriteString("new file mode " + strings.TrimPrefix(line, "deleted file mode "))
+
+		case strings.HasPrefix(line, "old mode ") && strings.HasPrefix(next, "new mode "):
+			out.WriteString("old mode " + strings.TrimPrefix(next, "new mode "))
+			out.WriteString("new mode " + strings.TrimPrefix(line, "old mode "))
+			i++
+		case strings.HasPrefix(line, "rename from ") && strings.HasPrefix(next, "rename to "):
+			out.WriteString("rename from " + strings.TrimPrefix(next, "rename to "))
+			out.WriteString("rename to " + strings.TrimPrefix(line, "rename from "))
+			i++
+
+		case strings.HasPrefix(line, "index "):
+			out.WriteString(reverseIndex(line))
+
+		case strings.HasPrefix(line, "@@ "):
+			n, err := reverseHunk(&out, lines[i:])
+			if err != nil {
+				return "", err
+			}
+			i += n - 1
+
+		default:
+			out.WriteString(line)
+		}
+	}
+
+	return out.String(), nil
+}
+
+// reverseGitHeader swaps the paths in a `diff --git a/x b/y` line. Diffs
+// produced by src-cli don't use the a/ and b/ prefixes, and both paths are
+// the same unless the file was renamed, so a line that can't be split
+// unambiguously is returned as is.
+func reverseGitHeader(line string) string {
+	header := strings.TrimSuffix(strings.TrimPrefix(line, "diff --git "), "\n")
+	if i := strings.Index(header, " b/"); strings.HasPrefix(header, "a/") && i >= 0 {
+		return fmt.Sprintf("diff --git a/%s b/%s\n", header[i+3:], header[2:i])
+	}
+	return line
+}
+
+// swapPrefix replaces the from prefix of a file name with to. /dev/null and
+// names without the prefix are returned unchanged.
+func swapPrefix(name, from, to string) string {
+	if strings.HasPrefix(name, from) {
+		return to + strings.TrimPrefix(name, from)
+	}
+	return name
+}
+
+// reverseIndex swaps the blob hashes in an `index abc..def [mode]` line.
+func reverseIndex(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return line
+	}
+	hashes := strings.SplitN(fields[1], "..", 2)
+	if len(hashes) != 2 {
+		return line
+	}
+	fields[1] = hashes[1] + ".." + hashes[0]
+	return strings.Join(fields, " ") + "\n"
+}
+
+// reverseHunk writes the reversed version of the hunk that starts at lines[0]
+// and returns the number of lines the hunk spans.
+func reverseHunk(out *strings.Builder, lines []string) (int, error) {
+	var origStart, origLines, newStart, newLines int
+	header := lines[0]
+	origRange, newRange, section, err := splitHunkHeader(header)
+	if err != nil {
+		return 0, err
+	}
+	if origStart, origLines, err = parseRange(origRange); err != nil {
+		return 0, errors.Wrapf(err, "parsing hunk header %q", strings.TrimSpace(header))
+	}
+	if newStart, newLines, err = parseRange(newRange); err != nil {
+		return 0, errors.Wrapf(err, "parsing hunk header %q", strings.TrimSpace(header))
+	}
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@%s", newStart, newLines, origStart, origLines, section)
+
+	// Within every run of changed lines we want the removed lines to come
+	// first, like in a diff produced by git. A "\ No newline at end of file"
+	// marker belongs to the line before it, so we keep them together.
+	var removed, added []string
+	flush := func() {
+		for _, l := range removed {
+			out.WriteString(l)
+		}
+		for _, l := range added {
+			out.WriteString(l)
+		}
+		removed, added = nil, nil
+	}
+
+	remainingOrig, remainingNew := origLines, newLines
+	n := 1
+	var last *[]string
+	for ; n < len(lines) && (remainingOrig > 0 || remainingNew > 0 || strings.HasPrefix(lines[n], `\`)); n++ {
+		line := lines[n]
+		switch {
+		case strings.HasPrefix(line, `\`):
+			if last != nil && len(*last) > 0 {
+				(*last)[len(*last)-1] += line
+			} else {
+				out.WriteString(line)
+			}
+		case strings.HasPrefix(line, "+"):
+			remainingNew--
+			removed = append(removed, "-"+line[1:])
+			last = &removed
+		case strings.HasPrefix(line, "-"):
+			remainingOrig--
+			added = append(added, "+"+line[1:])
+			last = &added
+		default:
+			remainingOrig--
+			remainingNew--
+			flush()
+			out.WriteString(line)
+			last = nil
+		}
+	}
+	flush()
+
+	if remainingOrig > 0 || remainingNew > 0 {
+		return 0, errors.Newf("hunk %q is truncated", strings.TrimSpace(header))
+	}
+	return n, nil
+}
+
+// splitHunkHeader splits `@@ -1,2 +3,4 @@ section` into its ranges and the
+// remainder of the line, including the line break.
+func splitHunkHeader(header string) (orig, new, section string, err error) {
+	rest := strings.TrimPrefix(header, "@@ ")
+	end := strings.Index(rest, " @@")
+	if end < 0 {
+		return "", "", "", errors.Newf("malformed hunk header %q", strings.TrimSpace(header))
+	}
+	ranges := strings.Fields(rest[:end])
+	if len(ranges) != 2 || !strings.HasPrefix(ranges[0], "-") || !strings.HasPrefix(ranges[1], "+") {
+		return "", "", "", errors.Newf("malformed hunk header %q", strings.TrimSpace(header))
+	}
+	return ranges[0][1:], ranges[1][1:], rest[end+3:], nil
+}
+
+// parseRange parses a `start,lines` hunk range. If lines is omitted, it
+// defaults to 1.
+func parseRange(r string) (start, lines int, err error) {
+	lines = 1
+	if i := strings.Index(r, ","); i >= 0 {
+		if _, err := fmt.Sscanf(r[i+1:], "%d", &lines); err != nil {
+			return 0, 0, err
+		}
+		r = r[:i]
+	}
+	if _, err := fmt.Sscanf(r, "%d", &start); err != nil {
+		return 0, 0, err
+	}
+	return start, lines, nil
+}
@@ -0,0 +1,145 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReverse(t *testing.T) {
+	for name, tc := range map[string]struct {
+		diff string
+		want string
+	}{
+		"modification": {
+			diff: `diff --git README.md README.md
+index 3363c39..88f1836 100644
+--- README.md
++++ README.md
+@@ -1,3 +1,3 @@ section
+ # README
+-
+-This is the readme
++This is the new readme
++
+`,
+			want: `diff --git README.md README.md
+index 88f1836..3363c39 100644
+--- README.md
++++ README.md
+@@ -1,3 +1,3 @@ section
+ # README
+-This is the new readme
+-
++
++This is the readme
+`,
+		},
+		"new file": {
+			diff: `diff --git a/new.txt b/new.txt
+new file mode 100644
+index 0000000..3363c39
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1,2 @@
++one
++two
+`,
+			want: `diff --git a/new.txt b/new.txt
+deleted file mode 100644
+index 3363c39..0000000
+--- a/new.txt
++++ /dev/null
+@@ -1,2 +0,0 @@
+-one
+-two
+`,
+		},
+		"rename and mode change": {
+			diff: `diff --git a/old.sh b/new.sh
+old mode 100644
+new mode 100755
+similarity index 100%
+rename from old.sh
+rename to new.sh
+`,
+			want: `diff --git a/new.sh b/old.sh
+old mode 100755
+new mode 100644
+similarity index 100%
+rename from new.sh
+rename to old.sh
+`,
+		},
+		"no newline at end of file": {
+			diff: `diff --git x.txt x.txt
+--- x.txt
++++ x.txt
+@@ -1 +1 @@
+-this is x
+\ No newline at end of file
++this is x (or is it?)
+`,
+			want: `diff --git x.txt x.txt
+--- x.txt
++++ x.txt
+@@ -1,1 +1,1 @@
+-this is x (or is it?)
++this is x
+\ No newline at end of file
+`,
+		},
+		"multiple files and hunks": {
+			diff: `diff --git a.txt a.txt
+--- a.txt
++++ a.txt
+@@ -1,2 +1,2 @@
+-a
++A
+ b
+@@ -10 +10,2 @@
+ j
++k
+diff --git b.txt b.txt
+--- b.txt
++++ b.txt
+@@ -1 +1 @@
+--- not a header
++++ not a header either
+`,
+			want: `diff --git a.txt a.txt
+--- a.txt
++++ a.txt
+@@ -1,2 +1,2 @@
+-A
++a
+ b
+@@ -10,2 +10,1 @@
+ j
+-k
+diff --git b.txt b.txt
+--- b.txt
++++ b.txt
+@@ -1,1 +1,1 @@
+-++ not a header either
++-- not a header
+`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			have, err := Reverse(tc.diff)
+			if err != nil {
+				t.Fatalf("unexpected error: %+v", err)
+			}
+			if diff := cmp.Diff(tc.want, have); diff != "" {
+				t.Errorf("wrong diff (-want +have):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("truncated hunk", func(t *testing.T) {
+		if _, err := Reverse("--- a.txt\n+++ a.txt\n@@ -1,3 +1,3 @@\n a\n"); err == nil {
+			t.Error("unexpected nil error")
+		}
+	})
+}
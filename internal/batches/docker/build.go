@@ -0,0 +1,108 @@
+package docker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/cockroachdb/errors"
+)
+
+// buildImageRepository is the repository name used for images built from
+// local build contexts.
+const buildImageRepository = "src-batch-build"
+
+// BuildContext describes a local Docker build context that is built into an
+// image before steps that reference it are executed.
+type BuildContext struct {
+	// Context is the path to the build context directory.
+	Context string
+	// Dockerfile is the path to the Dockerfile, relative to Context. If empty,
+	// Docker's default of Context/Dockerfile is used.
+	Dockerfile string
+}
+
+// Tag returns the name of the image that is built from the build context.
+//
+// The tag is derived from the content of the Dockerfile and of every file
+// within the context that isn't excluded by its .dockerignore file, which
+// means that an unchanged context maps to an image that has already been
+// built, while any edit that Docker would see results in a fresh build.
+func (bc BuildContext) Tag() (string, error) {
+	h := sha256.New()
+
+	dockerfile := bc.Dockerfile
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+	// The Dockerfile may be outside of the context, and is used even if the
+	// .dockerignore file excludes it.
+	data, err := os.ReadFile(filepath.Join(bc.Context, dockerfile))
+	if err != nil {
+		return "", errors.Wrap(err, "reading Dockerfile")
+	}
+	fmt.Fprintf(h, "dockerfile:%s\x00%d\x00", filepath.ToSlash(bc.Dockerfile), len(data))
+	h.Write(data)
+
+	ignore, err := readDockerignore(bc.Context)
+	if err != nil {
+		return "", errors.Wrapf(err, "reading .dockerignore of build context %q", bc.Context)
+	}
+	skipDirs := !ignore.hasExceptions()
+
+	err = filepath.WalkDir(bc.Context, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(bc.Context, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if ignore.excludes(filepath.ToSlash(rel)) {
+			if d.IsDir() && skipDirs {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%o\x00%d\x00", filepath.ToSlash(rel), info.Mode().Perm(), info.Size())
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(h, f)
+		return err
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "hashing build context %q", bc.Context)
+	}
+
+	return buildImageRepository + ":" + hex.EncodeToString(h.Sum(nil))[:16], nil
+}
+
+// buildArgs returns the arguments to `docker` that build the context into an
+// image with the given tag.
+func (bc BuildContext) buildArgs(tag string) []string {
+	args := []string{"image", "build", "--tag", tag}
+	if bc.Dockerfile != "" {
+		args = append(args, "--file", filepath.Join(bc.Context, bc.Dockerfile))
+	}
+	return append(args, bc.Context)
+}
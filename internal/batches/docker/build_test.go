@@ -0,0 +1,183 @@
+package docker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/src-cli/internal/exec/expect"
+)
+
+func TestBuildContext_Tag(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("Dockerfile", "FROM alpine:3\n")
+	writeFile("script.sh", "echo hello\n")
+
+	bc := BuildContext{Context: dir}
+	first, err := bc.Tag()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(first, buildImageRepository+":") {
+		t.Errorf("unexpected tag: %q", first)
+	}
+
+	again, err := bc.Tag()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != again {
+		t.Errorf("tag not stable: first=%q second=%q", first, again)
+	}
+
+	writeFile("script.sh", "echo goodbye\n")
+	changed, err := bc.Tag()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed == first {
+		t.Errorf("tag did not change after editing context: %q", changed)
+	}
+
+	writeFile("Other.Dockerfile", "FROM alpine:3\n")
+	other, err := BuildContext{Context: dir, Dockerfile: "Other.Dockerfile"}.Tag()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == changed {
+		t.Errorf("tag did not change with different Dockerfile: %q", other)
+	}
+
+	if _, err := (BuildContext{Context: filepath.Join(dir, "missing")}).Tag(); err == nil {
+		t.Error("unexpected nil error for missing context")
+	}
+	if _, err := (BuildContext{Context: dir, Dockerfile: "Missing.Dockerfile"}).Tag(); err == nil {
+		t.Error("unexpected nil error for missing Dockerfile")
+	}
+}
+
+func TestBuildContext_Tag_Dockerignore(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("context/Dockerfile", "FROM alpine:3\n")
+	writeFile("context/script.sh", "echo hello\n")
+	writeFile("context/.dockerignore", "node_modules\n*.log\n!keep.log\n")
+	writeFile("context/node_modules/lib/index.js", "1\n")
+	writeFile("context/build.log", "1\n")
+	writeFile("context/keep.log", "1\n")
+	writeFile("codemod.Dockerfile", "FROM alpine:3\n")
+
+	bc := BuildContext{Context: filepath.Join(dir, "context")}
+	tag := func() string {
+		t.Helper()
+		tag, err := bc.Tag()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tag
+	}
+	first := tag()
+
+	writeFile("context/node_modules/lib/index.js", "2\n")
+	writeFile("context/build.log", "2\n")
+	if have := tag(); have != first {
+		t.Errorf("tag changed after editing excluded files: %q", have)
+	}
+
+	writeFile("context/keep.log", "2\n")
+	included := tag()
+	if included == first {
+		t.Error("tag did not change after editing a file included by an exception")
+	}
+
+	// A Dockerfile outside of the context contributes its contents.
+	bc.Dockerfile = filepath.Join("..", "codemod.Dockerfile")
+	outside := tag()
+	writeFile("codemod.Dockerfile", "FROM alpine:3.15\n")
+	if have := tag(); have == outside {
+		t.Errorf("tag did not change after editing the Dockerfile outside of the context: %q", have)
+	}
+}
+
+func TestImage_EnsureBuild(t *testing.T) {
+	ctx := context.Background()
+	bc := &BuildContext{Context: "ctx", Dockerfile: "Dockerfile.codemod"}
+
+	for name, tc := range map[string]struct {
+		expectations []*expect.Expectation
+		wantErr      bool
+	}{
+		"already built": {
+			expectations: []*expect.Expectation{inspectSuccess("foo", "digest")},
+		},
+		"build required": {
+			expectations: []*expect.Expectation{
+				inspectFailure("foo"),
+				buildBehaviour("foo", expect.Success),
+				inspectSuccess("foo", "digest"),
+			},
+		},
+		"build failed": {
+			expectations: []*expect.Expectation{
+				inspectFailure("foo"),
+				buildBehaviour("foo", expect.Behaviour{ExitCode: 1}),
+			},
+			wantErr: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			expect.Commands(t, tc.expectations...)
+
+			image := &image{name: "foo", build: bc}
+			err := image.Ensure(ctx)
+			if tc.wantErr && err == nil {
+				t.Error("unexpected nil error")
+			} else if !tc.wantErr && err != nil {
+				t.Errorf("unexpected error: %+v", err)
+			}
+		})
+	}
+}
+
+func TestImageCache_RegisterBuild(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM alpine:3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := NewImageCache()
+	tag, err := cache.RegisterBuild(BuildContext{Context: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img := cache.Get(tag).(*image)
+	if img.build == nil || img.build.Context != dir {
+		t.Errorf("image not backed by build context: %+v", img)
+	}
+}
+
+func buildBehaviour(name string, behaviour expect.Behaviour) *expect.Expectation {
+	return expect.NewGlob(
+		behaviour,
+		"docker", "image", "build", "--tag", name,
+		"--file", filepath.Join("ctx", "Dockerfile.codemod"), "ctx",
+	)
+}
@@ -6,12 +6,23 @@ import "sync"
 type ImageCache struct {
 	images   map[string]Image
 	imagesMu sync.Mutex
+
+	mirror string
 }
 
 // NewImageCache creates a new image cache.
 func NewImageCache() *ImageCache {
+	return NewMirroredImageCache("")
+}
+
+// NewMirroredImageCache creates a new image cache whose images are pulled
+// through the given registry mirror, such as a pull-through cache, if they are
+// on Docker Hub. The mirror is given as a host, optionally followed by a path
+// prefix, as in "mirror.example.com:5000/dockerhub".
+func NewMirroredImageCache(mirror string) *ImageCache {
 	return &ImageCache{
 		images: make(map[string]Image),
+		mirror: mirror,
 	}
 }
 
@@ -26,7 +37,25 @@ func (ic *ImageCache) Get(name string) Image {
 		return image
 	}
 
-	image := &image{name: name}
+	image := &image{name: name, mirror: ic.mirror}
 	ic.images[name] = image
 	return image
 }
+
+// RegisterBuild registers the given build context with the cache and returns
+// the name of the image it produces. Subsequent calls to Get with that name
+// return an image that is built, rather than pulled, when it is ensured.
+func (ic *ImageCache) RegisterBuild(bc BuildContext) (string, error) {
+	tag, err := bc.Tag()
+	if err != nil {
+		return "", err
+	}
+
+	ic.imagesMu.Lock()
+	defer ic.imagesMu.Unlock()
+
+	if _, ok := ic.images[tag]; !ok {
+		ic.images[tag] = &image{name: tag, build: &bc}
+	}
+	return tag, nil
+}
@@ -0,0 +1,157 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// dockerignore are the patterns of the .dockerignore file of a build context,
+// which exclude files from the context that is sent to the Docker daemon.
+type dockerignore []dockerignorePattern
+
+type dockerignorePattern struct {
+	re *regexp.Regexp
+	// exception is true for patterns starting with "!", which include files
+	// that earlier patterns excluded.
+	exception bool
+}
+
+// readDockerignore reads the .dockerignore file in the directory of the build
+// context. It's empty if there is none.
+func readDockerignore(dir string) (dockerignore, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ".dockerignore"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return parseDockerignore(data)
+}
+
+// parseDockerignore parses the patterns of a .dockerignore file, following the
+// syntax that Docker uses: a pattern per line, which is matched like
+// filepath.Match against the slash-separated path relative to the context,
+// except that "**" matches any number of directories. Lines starting with "#"
+// are comments, and patterns starting with "!" are exceptions.
+func parseDockerignore(data []byte) (dockerignore, error) {
+	var patterns dockerignore
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var p dockerignorePattern
+		if strings.HasPrefix(line, "!") {
+			p.exception = true
+			line = strings.TrimSpace(line[1:])
+		}
+		line = path.Clean(filepath.ToSlash(line))
+		if len(line) > 1 && line[0] == '/' {
+			line = line[1:]
+		}
+
+		re, err := regexp.Compile(dockerignoreRegexp(line))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid .dockerignore pattern %q", line)
+		}
+		p.re = re
+		patterns = append(patterns, p)
+	}
+	return patterns, scanner.Err()
+}
+
+// dockerignoreRegexp translates a .dockerignore pattern into a regular
+// expression.
+func dockerignoreRegexp(pattern string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case inClass:
+			if c == ']' {
+				inClass = false
+			}
+			if c == '\\' && i+1 < len(pattern) {
+				i++
+				b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+				continue
+			}
+			b.WriteByte(c)
+		case c == '[':
+			inClass = true
+			b.WriteByte(c)
+			if i+1 < len(pattern) && (pattern[i+1] == '!' || pattern[i+1] == '^') {
+				i++
+				b.WriteByte('^')
+			}
+		case c == '*' && i+1 < len(pattern) && pattern[i+1] == '*':
+			i++
+			// "**/" matches any number of directories, including none.
+			if i+1 < len(pattern) && pattern[i+1] == '/' {
+				i++
+			}
+			if i+1 == len(pattern) {
+				b.WriteString(".*")
+			} else {
+				b.WriteString("(.*/)?")
+			}
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// excludes returns whether the file or directory at the slash-separated path
+// relative to the context is excluded. Like Docker, a path is matched by a
+// pattern if it or any of its parent directories matches it, and the last
+// pattern that matches decides.
+func (d dockerignore) excludes(rel string) bool {
+	excluded := false
+	for _, p := range d {
+		if p.exception != excluded {
+			// The pattern can't change the outcome.
+			continue
+		}
+		for dir := rel; ; dir = path.Dir(dir) {
+			if p.re.MatchString(dir) {
+				excluded = !p.exception
+				break
+			}
+			if !strings.Contains(dir, "/") {
+				break
+			}
+		}
+	}
+	return excluded
+}
+
+// hasExceptions returns whether any pattern is an exception, which can
+// include files in directories that are excluded.
+func (d dockerignore) hasExceptions() bool {
+	for _, p := range d {
+		if p.exception {
+			return true
+		}
+	}
+	return false
+}
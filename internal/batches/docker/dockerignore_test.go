@@ -0,0 +1,68 @@
+package docker
+
+import (
+	"testing"
+)
+
+func TestDockerignore_Excludes(t *testing.T) {
+	for name, tc := range map[string]struct {
+		dockerignore string
+		excluded     []string
+		included     []string
+	}{
+		"empty": {
+			included: []string{"Dockerfile", "a/b/c"},
+		},
+		"comments and blank lines": {
+			dockerignore: "# node_modules\n\n  \n",
+			included:     []string{"node_modules", "# node_modules"},
+		},
+		"directory": {
+			dockerignore: "node_modules\n/dist/\n",
+			excluded:     []string{"node_modules", "node_modules/lib/index.js", "dist/app.js"},
+			included:     []string{"web/node_modules", "distribution"},
+		},
+		"star": {
+			dockerignore: "*.log\n*/tmp\n",
+			excluded:     []string{"build.log", "web/tmp", "web/tmp/file"},
+			included:     []string{"web/build.log", "a/b/tmp"},
+		},
+		"double star": {
+			dockerignore: "**/*.log\ndocs/**\n",
+			excluded:     []string{"build.log", "a/b/build.log", "docs/a/b"},
+			included:     []string{"build.logs", "docs"},
+		},
+		"question mark and class": {
+			dockerignore: "file?.txt\n[!a]*.md\n",
+			excluded:     []string{"file1.txt", "README.md"},
+			included:     []string{"file10.txt", "api.md"},
+		},
+		"exceptions": {
+			dockerignore: "*.md\n!README*.md\nREADME-secret.md\n",
+			excluded:     []string{"CHANGELOG.md", "README-secret.md"},
+			included:     []string{"README.md", "README-public.md"},
+		},
+		"escaped metacharacters": {
+			dockerignore: "a+b.(c)\n",
+			excluded:     []string{"a+b.(c)"},
+			included:     []string{"aab.(c)", "a+bx(c)"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			d, err := parseDockerignore([]byte(tc.dockerignore))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, path := range tc.excluded {
+				if !d.excludes(path) {
+					t.Errorf("%q isn't excluded", path)
+				}
+			}
+			for _, path := range tc.included {
+				if d.excludes(path) {
+					t.Errorf("%q is excluded", path)
+				}
+			}
+		})
+	}
+}
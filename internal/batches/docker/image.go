@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"runtime"
 	"strings"
 	"sync"
 
@@ -35,6 +36,13 @@ type Image interface {
 type image struct {
 	name string
 
+	// build is set if the image is built from a local build context instead
+	// of being pulled from a registry.
+	build *BuildContext
+	// mirror is the registry mirror that images from Docker Hub are pulled
+	// through, if any. See ImageCache.
+	mirror string
+
 	// There are lots of once fields below: basically, we're going to try fairly
 	// hard to prevent performing the same operations on the same image over and
 	// over, since some of them are expensive.
@@ -62,6 +70,8 @@ func (image *image) Digest(ctx context.Context) (string, error) {
 
 // Ensure ensures that the image has been pulled by Docker. Note that it does
 // not attempt to pull a newer version of the image if it exists locally.
+//
+// Images that are backed by a build context are built instead of pulled.
 func (image *image) Ensure(ctx context.Context) error {
 	image.ensureOnce.Do(func() {
 		image.ensureErr = func() (err error) {
@@ -79,14 +89,22 @@ func (image *image) Ensure(ctx context.Context) error {
 			// tag don't exist locally, regardless of the format.
 			var digest string
 			if digest, err = inspectDigest(); err != nil {
-				// Let's try pulling the image.
-				if err := exec.CommandContext(ctx, "docker", "image", "pull", image.name).Run(); err != nil {
-					return errors.Wrap(err, "pulling image")
+				if image.build != nil {
+					// Let's try building the image.
+					out, err := exec.CommandContext(ctx, "docker", image.build.buildArgs(image.name)...).CombinedOutput()
+					if err != nil {
+						return errors.Wrapf(err, "building image from %q:\n%s", image.build.Context, out)
+					}
+				} else {
+					// Let's try pulling the image.
+					if err := image.pull(ctx); err != nil {
+						return err
+					}
 				}
 				// And try again to get the image digest.
 				digest, err = inspectDigest()
 				if err != nil {
-					return errors.Wrap(err, "not found after pulling image")
+					return errors.Wrap(err, "not found after pulling or building image")
 				}
 			}
 
@@ -103,6 +121,79 @@ func (image *image) Ensure(ctx context.Context) error {
 	return image.ensureErr
 }
 
+// hostArch is the architecture of the host, which is overridden in tests.
+var hostArch = runtime.GOARCH
+
+// pull pulls the image. If a registry mirror is configured and the image is
+// on Docker Hub, it's pulled from the mirror and tagged with its original
+// name, falling back to Docker Hub if the mirror fails.
+func (image *image) pull(ctx context.Context) error {
+	mirrored, ok := mirrorName(image.name, image.mirror)
+	if !ok {
+		return pullImage(ctx, image.name)
+	}
+
+	mirrorErr := pullImage(ctx, mirrored)
+	if mirrorErr == nil {
+		out, err := exec.CommandContext(ctx, "docker", "image", "tag", mirrored, image.name).CombinedOutput()
+		if err != nil {
+			return errors.Wrapf(err, "tagging image pulled from mirror %q:\n%s", image.mirror, out)
+		}
+		return nil
+	}
+
+	if err := pullImage(ctx, image.name); err != nil {
+		return errors.Wrapf(err, "pulling from mirror %q failed (%s), and so did pulling from Docker Hub", image.mirror, mirrorErr)
+	}
+	return nil
+}
+
+// pullImage pulls the image with the given name. Many images used in batch
+// specs are only published for amd64, so if there is no arm64 variant of the
+// image on an arm64 host, the amd64 variant is pulled instead and run under
+// emulation.
+func pullImage(ctx context.Context, name string) error {
+	out, err := exec.CommandContext(ctx, "docker", "image", "pull", name).CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if hostArch != "arm64" || !bytes.Contains(out, []byte("no matching manifest")) {
+		return errors.Wrap(err, "pulling image")
+	}
+
+	out, err = exec.CommandContext(ctx, "docker", "image", "pull", "--platform", "linux/amd64", name).CombinedOutput()
+	if err != nil {
+		return errors.Wrapf(err, "pulling linux/amd64 image, since there is no linux/arm64 image:\n%s", out)
+	}
+	return nil
+}
+
+// mirrorName returns the name of the image in the given registry mirror, if
+// the image is on Docker Hub. Images of other registries aren't mirrored.
+func mirrorName(name, mirror string) (string, bool) {
+	mirror = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(mirror, "https://"), "http://"), "/")
+	if mirror == "" {
+		return "", false
+	}
+
+	path := name
+	if i := strings.Index(name, "/"); i >= 0 {
+		// The first component of the name is a registry if it looks like a
+		// host name, as in the Docker CLI.
+		if host := name[:i]; strings.ContainsAny(host, ".:") || host == "localhost" {
+			if host != "docker.io" && host != "index.docker.io" {
+				return "", false
+			}
+			path = name[i+1:]
+		}
+	}
+	if !strings.Contains(path, "/") {
+		// Official images are in the library namespace.
+		path = "library/" + path
+	}
+	return mirror + "/" + path, true
+}
+
 // UIDGID returns the user and group the container is configured to run as.
 func (image *image) UIDGID(ctx context.Context) (UIDGID, error) {
 	image.uidGidOnce.Do(func() {
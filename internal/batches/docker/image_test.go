@@ -83,6 +83,7 @@ func TestImage_Ensure(t *testing.T) {
 
 	for name, tc := range map[string]struct {
 		expectations []*expect.Expectation
+		arch         string
 		image        *image
 		wantErr      bool
 	}{
@@ -108,10 +109,71 @@ func TestImage_Ensure(t *testing.T) {
 			image:   &image{name: "foo"},
 			wantErr: true,
 		},
+		"amd64 image on arm64": {
+			expectations: []*expect.Expectation{
+				inspectFailure("foo"),
+				pullNoMatchingManifest("foo"),
+				expect.NewGlob(
+					expect.Behaviour{ExitCode: 0},
+					"docker", "image", "pull", "--platform", "linux/amd64", "foo",
+				),
+				inspectSuccess("foo", "digest"),
+			},
+			arch:    "arm64",
+			image:   &image{name: "foo"},
+			wantErr: false,
+		},
+		"pull through mirror": {
+			expectations: []*expect.Expectation{
+				inspectFailure("foo"),
+				pullSuccess("mirror.example.com/library/foo"),
+				expect.NewGlob(
+					expect.Behaviour{ExitCode: 0},
+					"docker", "image", "tag", "mirror.example.com/library/foo", "foo",
+				),
+				inspectSuccess("foo", "digest"),
+			},
+			image:   &image{name: "foo", mirror: "mirror.example.com"},
+			wantErr: false,
+		},
+		"mirror failed": {
+			expectations: []*expect.Expectation{
+				inspectFailure("foo"),
+				pullFailure("mirror.example.com/library/foo"),
+				pullSuccess("foo"),
+				inspectSuccess("foo", "digest"),
+			},
+			image:   &image{name: "foo", mirror: "mirror.example.com"},
+			wantErr: false,
+		},
+		"not on Docker Hub": {
+			expectations: []*expect.Expectation{
+				inspectFailure("ghcr.io/foo/bar"),
+				pullSuccess("ghcr.io/foo/bar"),
+				inspectSuccess("ghcr.io/foo/bar", "digest"),
+			},
+			image:   &image{name: "ghcr.io/foo/bar", mirror: "mirror.example.com"},
+			wantErr: false,
+		},
+		"no matching manifest on amd64": {
+			expectations: []*expect.Expectation{
+				inspectFailure("foo"),
+				pullNoMatchingManifest("foo"),
+			},
+			arch:    "amd64",
+			image:   &image{name: "foo"},
+			wantErr: true,
+		},
 	} {
 		t.Run(name, func(t *testing.T) {
 			expect.Commands(t, tc.expectations...)
 
+			if tc.arch != "" {
+				oldArch := hostArch
+				hostArch = tc.arch
+				t.Cleanup(func() { hostArch = oldArch })
+			}
+
 			// We'll call Ensure twice to make sure the memoisation works.
 			test := func() error {
 				have := tc.image.Ensure(ctx)
@@ -280,6 +342,28 @@ func TestImage_UIDGID(t *testing.T) {
 	}
 }
 
+func TestMirrorName(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		mirror string
+		want   string
+	}{
+		{name: "alpine:3", mirror: "", want: ""},
+		{name: "alpine:3", mirror: "mirror.example.com", want: "mirror.example.com/library/alpine:3"},
+		{name: "sourcegraph/src-cli", mirror: "https://mirror.example.com:5000/hub/", want: "mirror.example.com:5000/hub/sourcegraph/src-cli"},
+		{name: "docker.io/library/alpine", mirror: "mirror.example.com", want: "mirror.example.com/library/alpine"},
+		{name: "docker.io/alpine", mirror: "mirror.example.com", want: "mirror.example.com/library/alpine"},
+		{name: "ghcr.io/foo/bar", mirror: "mirror.example.com", want: ""},
+		{name: "localhost/foo", mirror: "mirror.example.com", want: ""},
+		{name: "registry:5000/foo", mirror: "mirror.example.com", want: ""},
+	} {
+		have, ok := mirrorName(tc.name, tc.mirror)
+		if ok != (tc.want != "") || have != tc.want {
+			t.Errorf("wrong name for %q with mirror %q: have=%q want=%q", tc.name, tc.mirror, have, tc.want)
+		}
+	}
+}
+
 func TestUIDGID(t *testing.T) {
 	have := UIDGID{UID: 1000, GID: 0}.String()
 	want := "1000:0"
@@ -313,6 +397,16 @@ func pullFailure(name string) *expect.Expectation {
 	)
 }
 
+func pullNoMatchingManifest(name string) *expect.Expectation {
+	return expect.NewGlob(
+		expect.Behaviour{
+			Stderr:   []byte("no matching manifest for linux/arm64/v8 in the manifest list entries\n"),
+			ExitCode: 1,
+		},
+		"docker", "image", "pull", name,
+	)
+}
+
 func pullSuccess(name string) *expect.Expectation {
 	return expect.NewGlob(
 		expect.Behaviour{ExitCode: 0},
@@ -0,0 +1,107 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/exec"
+)
+
+// RemoteHost returns the host of the Docker daemon if it's on another
+// machine, as set with DOCKER_HOST or the current Docker context, or an empty
+// string if the daemon is on this machine.
+//
+// Files on this machine can't be bind mounted into containers on a remote
+// daemon, so they have to be streamed to it instead.
+func RemoteHost(ctx context.Context) string {
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		out, err := exec.CommandContext(ctx, "docker", "context", "inspect", "--format", "{{.Endpoints.docker.Host}}").Output()
+		if err != nil {
+			return ""
+		}
+		host = strings.TrimSpace(string(out))
+	}
+
+	if !isRemoteHost(host) {
+		return ""
+	}
+	return host
+}
+
+// isRemoteHost returns whether the Docker host, as in DOCKER_HOST, is on
+// another machine. Sockets and named pipes are always local, and so are TCP
+// connections to the loopback interface.
+func isRemoteHost(host string) bool {
+	u, err := url.Parse(host)
+	if err != nil {
+		return false
+	}
+
+	switch u.Scheme {
+	case "ssh":
+		return true
+	case "tcp", "http", "https":
+		name := u.Hostname()
+		if name == "" || name == "localhost" {
+			return false
+		}
+		if ip := net.ParseIP(name); ip != nil && ip.IsLoopback() {
+			return false
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// TarFiles returns a tar archive of the given files on this machine, by their
+// path in the archive, which can be streamed to a Docker daemon with `docker
+// cp -` or extracted with `tar -x` in a container. The files are readable by
+// every user, so that they can be read by whatever user the container runs
+// as.
+func TarFiles(files map[string]string) (io.Reader, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range names {
+		data, err := os.ReadFile(files[name])
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading %s", name)
+		}
+
+		mode := int64(0644)
+		if info, err := os.Stat(files[name]); err == nil && info.Mode().Perm()&0111 != 0 {
+			mode = 0755
+		}
+		hdr := &tar.Header{
+			Name: strings.TrimPrefix(name, "/"),
+			Mode: mode,
+			Size: int64(len(data)),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
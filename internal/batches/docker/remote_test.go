@@ -0,0 +1,83 @@
+package docker
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIsRemoteHost(t *testing.T) {
+	for host, want := range map[string]bool{
+		"":                                 false,
+		"unix:///var/run/docker.sock":      false,
+		"npipe:////./pipe/docker_engine":   false,
+		"tcp://localhost:2375":             false,
+		"tcp://127.0.0.1:2375":             false,
+		"tcp://[::1]:2375":                 false,
+		"tcp://docker.example.com:2376":    true,
+		"tcp://10.0.0.12:2375":             true,
+		"ssh://builder@docker.example.com": true,
+	} {
+		if have := isRemoteHost(host); have != want {
+			t.Errorf("isRemoteHost(%q): want %v, have %v", host, want, have)
+		}
+	}
+}
+
+func TestTarFiles(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script")
+	if err := os.WriteFile(script, []byte("echo hello\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tool := filepath.Join(dir, "tool")
+	if err := os.WriteFile(tool, []byte("#!/bin/sh\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := TarFiles(map[string]string{
+		"/tmp/script":       script,
+		"/usr/local/bin/do": tool,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type entry struct {
+		Name    string
+		Mode    int64
+		Content string
+	}
+	var have []entry
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		have = append(have, entry{hdr.Name, hdr.Mode, string(content)})
+	}
+
+	want := []entry{
+		{"tmp/script", 0644, "echo hello\n"},
+		{"usr/local/bin/do", 0755, "#!/bin/sh\n"},
+	}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Errorf("unexpected archive (-want +have):\n%s", diff)
+	}
+
+	if _, err := TarFiles(map[string]string{"missing": filepath.Join(dir, "missing")}); err == nil {
+		t.Error("unexpected nil error for missing file")
+	}
+}
@@ -0,0 +1,174 @@
+package executor
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gobwas/glob"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/repozip"
+)
+
+// hasCacheKeyPaths returns whether the cache keys of the first step of the
+// task, and possibly the ones after it, are based on the content of files.
+func (t *Task) hasCacheKeyPaths() bool {
+	return len(t.Steps) > 0 && len(t.CacheKeyPaths[t.specStepIndex(0)]) > 0
+}
+
+// cacheKeyContent returns the digest of the files that the cache key of the
+// step with the given index in Steps is based on instead of the revision of
+// the repository, or "" if it's based on the revision.
+func (t *Task) cacheKeyContent(i int) string {
+	if i < 0 || i >= len(t.cacheKeyContents) {
+		return ""
+	}
+	return t.cacheKeyContents[i]
+}
+
+// withoutRevision returns a copy of the repository without the commits it's
+// at, for cache keys that don't depend on them.
+func withoutRevision(repo *graphql.Repository) *graphql.Repository {
+	r := *repo
+	r.Commit = graphql.Target{}
+	r.Branch.Target = graphql.Target{}
+	if r.DefaultBranch != nil {
+		r.DefaultBranch = &graphql.Branch{Name: r.DefaultBranch.Name}
+	}
+	return &r
+}
+
+// fetchCacheKeyContents fetches the archive of the task's repository and
+// computes the digests of the files matching the cacheKeyPaths of its steps,
+// which its cache keys are based on. The archive is returned, so that it can
+// be held while the task is executed instead of being fetched again; the
+// caller has to close it.
+func fetchCacheKeyContents(ctx context.Context, archives repozip.ArchiveRegistry, task *Task) (repozip.Archive, error) {
+	archive := archives.Checkout(repozip.RepoRevision{RepoName: task.Repository.Name, Commit: task.Repository.Rev()}, task.ArchivePathToFetch())
+	if err := archive.Ensure(ctx); err != nil {
+		return nil, errors.Wrap(err, "fetching repository archive")
+	}
+
+	paths := make([][]string, len(task.Steps))
+	for i := range task.Steps {
+		paths[i] = task.CacheKeyPaths[task.specStepIndex(i)]
+	}
+	contents, err := contentDigests(archive.Path(), task.Path, paths)
+	if err != nil {
+		archive.Close()
+		return nil, errors.Wrap(err, "computing the cache key")
+	}
+	task.cacheKeyContents = contents
+	return archive, nil
+}
+
+// contentDigests returns a digest for each step, by index, of the files in the
+// repository archive at zipPath that match the cacheKeyPaths of the step and
+// of the steps before it. The paths are relative to the workspace path. As a
+// step's result depends on the results of the steps before it, only the steps
+// whose preceding steps all have cacheKeyPaths get a digest; the others get
+// "", and so do the ones after them.
+func contentDigests(zipPath, workspacePath string, paths [][]string) ([]string, error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	prefix := ""
+	if workspacePath != "" {
+		prefix = strings.Trim(workspacePath, "/") + "/"
+	}
+	files := map[string]*zip.File{}
+	var names []string
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || !strings.HasPrefix(f.Name, prefix) {
+			continue
+		}
+		name := strings.TrimPrefix(f.Name, prefix)
+		files[name] = f
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// fileDigests are the digests of the contents of the files matched so far.
+	fileDigests := map[string]string{}
+	digests := make([]string, len(paths))
+	var patterns []string
+	var globs []glob.Glob
+	for i, stepPaths := range paths {
+		if len(stepPaths) == 0 {
+			break
+		}
+		for _, p := range stepPaths {
+			g, err := compilePathGlob(p)
+			if err != nil {
+				return nil, err
+			}
+			patterns = append(patterns, p)
+			globs = append(globs, g)
+		}
+
+		h := sha256.New()
+		fmt.Fprintf(h, "%q\n", patterns)
+		for _, name := range names {
+			if !matchesAny(globs, name) {
+				continue
+			}
+			digest, ok := fileDigests[name]
+			if !ok {
+				if digest, err = fileDigest(files[name]); err != nil {
+					return nil, errors.Wrapf(err, "reading %s", name)
+				}
+				fileDigests[name] = digest
+			}
+			fmt.Fprintf(h, "%s\x00%s\n", name, digest)
+		}
+		digests[i] = base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:16])
+	}
+	return digests, nil
+}
+
+// compilePathGlob compiles a glob pattern of cacheKeyPaths, in which * doesn't
+// match slashes and a leading **/ also matches no directory at all.
+func compilePathGlob(pattern string) (glob.Glob, error) {
+	compiled := pattern
+	if strings.HasPrefix(pattern, "**/") {
+		compiled = "{**/,}" + strings.TrimPrefix(pattern, "**/")
+	}
+	g, err := glob.Compile(compiled, '/')
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid cacheKeyPaths pattern %q", pattern)
+	}
+	return g, nil
+}
+
+func matchesAny(globs []glob.Glob, name string) bool {
+	for _, g := range globs {
+		if g.Match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func fileDigest(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, rc); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,228 @@
+package executor
+
+import (
+	"archive/zip"
+	"context"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/mock"
+	"github.com/sourcegraph/src-cli/internal/batches/repozip"
+)
+
+func writeTestZip(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "repo.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	for name, content := range files {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestContentDigests(t *testing.T) {
+	files := map[string]string{
+		"main.go":            "package main",
+		"pkg/lib.go":         "package pkg",
+		"README.md":          "# Hello",
+		"service/main.go":    "package main",
+		"service/go.mod":     "module service",
+		"service/README.md":  "# Service",
+		"service/pkg/lib.go": "package pkg",
+	}
+	digests := func(t *testing.T, files map[string]string, workspace string, paths ...[]string) []string {
+		t.Helper()
+		have, err := contentDigests(writeTestZip(t, files), workspace, paths)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return have
+	}
+	with := func(name, content string) map[string]string {
+		changed := map[string]string{}
+		for k, v := range files {
+			changed[k] = v
+		}
+		changed[name] = content
+		return changed
+	}
+
+	goFiles := []string{"**/*.go"}
+	initial := digests(t, files, "", goFiles, []string{"go.mod"}, nil, []string{"*.md"})
+	if len(initial) != 4 || initial[0] == "" || initial[1] == "" || initial[2] != "" || initial[3] != "" {
+		t.Fatalf("wrong digests %q", initial)
+	}
+
+	t.Run("unrelated change", func(t *testing.T) {
+		have := digests(t, with("README.md", "# Changed"), "", goFiles, []string{"go.mod"})
+		if have[0] != initial[0] || have[1] != initial[1] {
+			t.Errorf("digests changed: %q", have)
+		}
+	})
+
+	t.Run("matching change", func(t *testing.T) {
+		// **/ also matches files at the root.
+		have := digests(t, with("main.go", "package changed"), "", goFiles, []string{"go.mod"})
+		if have[0] == initial[0] || have[1] == initial[1] {
+			t.Errorf("digests didn't change: %q", have)
+		}
+	})
+
+	t.Run("change matched by later step", func(t *testing.T) {
+		have := digests(t, with("go.mod", "module changed"), "", goFiles, []string{"go.mod"})
+		if have[0] != initial[0] || have[1] == initial[1] {
+			t.Errorf("wrong digests: %q", have)
+		}
+	})
+
+	t.Run("different patterns", func(t *testing.T) {
+		have := digests(t, files, "", []string{"**/*.go", "*.txt"})
+		if have[0] == initial[0] {
+			t.Errorf("digest didn't change: %q", have)
+		}
+	})
+
+	t.Run("workspace", func(t *testing.T) {
+		initial := digests(t, files, "service", []string{"*.go"})
+		if have := digests(t, with("main.go", "package changed"), "service", []string{"*.go"}); have[0] != initial[0] {
+			t.Errorf("change outside of the workspace changed digest: %q", have)
+		}
+		if have := digests(t, with("service/pkg/lib.go", "package changed"), "service", []string{"*.go"}); have[0] != initial[0] {
+			t.Errorf("change in subdirectory changed digest: %q", have)
+		}
+		if have := digests(t, with("service/main.go", "package changed"), "service", []string{"*.go"}); have[0] == initial[0] {
+			t.Errorf("change in workspace didn't change digest: %q", have)
+		}
+	})
+}
+
+func TestCacheKeyContent(t *testing.T) {
+	ctx := context.Background()
+	cache := ExecutionDiskCache{Dir: t.TempDir()}
+
+	steps := []batcheslib.Step{{Run: "gofmt -w .", Container: "golang"}}
+	task := func(commit string, contents []string) *Task {
+		return &Task{
+			Repository: &graphql.Repository{
+				ID:            "repo-1",
+				Name:          "github.com/sourcegraph/src-cli",
+				DefaultBranch: &graphql.Branch{Name: "main", Target: graphql.Target{OID: commit}},
+			},
+			Steps:            steps,
+			cacheKeyContents: contents,
+		}
+	}
+	set := func(task *Task, diff string) {
+		t.Helper()
+		if err := cache.Set(ctx, task.cacheKey(), executionResult{Diff: diff}); err != nil {
+			t.Fatal(err)
+		}
+		if err := cache.SetStepResult(ctx, StepsCacheKey{Task: task, StepIndex: 0}, stepExecutionResult{Diff: []byte(diff)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	get := func(task *Task) (string, bool) {
+		t.Helper()
+		result, found, err := cache.Get(ctx, task.cacheKey())
+		if err != nil {
+			t.Fatal(err)
+		}
+		stepResult, stepFound, err := cache.GetStepResult(ctx, StepsCacheKey{Task: task, StepIndex: 0})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found != stepFound || (found && result.Diff != string(stepResult.Diff)) {
+			t.Fatalf("task and step results differ: found=%t, stepFound=%t", found, stepFound)
+		}
+		return result.Diff, found
+	}
+
+	set(task("a", nil), "without content")
+	if _, found := get(task("b", nil)); found {
+		t.Error("result without content found at another revision")
+	}
+
+	set(task("a", []string{"x"}), "with content")
+	if diff, found := get(task("b", []string{"x"})); !found || diff != "with content" {
+		t.Errorf("result with content not found at another revision with the same content: found=%t, diff=%q", found, diff)
+	}
+	if _, found := get(task("b", []string{"y"})); found {
+		t.Error("result with content found for other content")
+	}
+}
+
+func TestExecutor_CacheKeyPathsCheckCache(t *testing.T) {
+	ctx := context.Background()
+	ts := httptest.NewServer(mock.NewZipArchivesMux(t, nil, mock.RepoArchive{
+		RepoName: testRepo1.Name,
+		Commit:   testRepo1.Rev(),
+		Files:    map[string]string{"go.mod": "module example.com/x\n"},
+	}))
+	defer ts.Close()
+
+	client := api.NewClient(api.ClientOpts{Endpoint: ts.URL, Out: io.Discard})
+	// The archives are removed once they're no longer used, as with
+	// CleanArchives.
+	archives := repozip.NewArchiveRegistry(client, t.TempDir(), true)
+
+	var checked []string
+	x := newExecutor(newExecutorOpts{
+		RepoArchiveRegistry: archives,
+		Logger:              mock.LogNoOpManager{},
+		Parallelism:         1,
+		Timeout:             30 * time.Second,
+		CheckCache: func(ctx context.Context, task *Task) (executionResult, bool, error) {
+			checked = append(checked, task.cacheKeyContent(0))
+			return executionResult{Diff: "cached"}, true, nil
+		},
+	})
+
+	task := &Task{
+		Repository:    testRepo1,
+		Steps:         []batcheslib.Step{{Run: "go mod tidy", Container: "golang"}},
+		CacheKeyPaths: map[int][]string{0: {"go.mod"}},
+	}
+	x.Start(ctx, []*Task{task}, newDummyTaskExecutionUI())
+	results, err := x.Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(checked) != 1 || checked[0] == "" {
+		t.Fatalf("cache checked with contents %q, want once with a digest", checked)
+	}
+	if len(results) != 1 || results[0].result.Diff != "cached" {
+		t.Fatalf("want the cached result, have %+v", results)
+	}
+	if have := archives.CacheStats().Downloaded; have != 1 {
+		t.Errorf("archive downloaded %d times, want once", have)
+	}
+	archive := archives.Checkout(repozip.RepoRevision{RepoName: testRepo1.Name, Commit: testRepo1.Rev()}, "")
+	if _, err := os.Stat(archive.Path()); !os.IsNotExist(err) {
+		t.Errorf("archive wasn't removed after it was used: %v", err)
+	}
+}
@@ -7,15 +7,58 @@ import (
 	"github.com/cockroachdb/errors"
 	"github.com/sourcegraph/go-diff/diff"
 	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+	"github.com/sourcegraph/sourcegraph/lib/batches/git"
 	"github.com/sourcegraph/sourcegraph/lib/batches/template"
 
 	"github.com/sourcegraph/src-cli/internal/batches"
+	batchesdiff "github.com/sourcegraph/src-cli/internal/batches/diff"
 	"github.com/sourcegraph/src-cli/internal/batches/util"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
 )
 
-var errOptionalPublishedUnsupported = batcheslib.NewValidationError(errors.New(`This Sourcegraph version requires the "published" field to be specified in the batch spec; upgrade to version 3.30.0 or later to be able to omit the published field and control publication from the UI.`))
+var errOptionalPublishedUnsupported = cmderrors.WithKind(batcheslib.NewValidationError(errors.New(`This Sourcegraph version requires the "published" field to be specified in the batch spec; upgrade to version 3.30.0 or later to be able to omit the published field and control publication from the UI.`)), cmderrors.KindIncompatible)
+
+// changesetSpecsOpts are the options of createChangesetSpecs.
+type changesetSpecsOpts struct {
+	features batches.FeatureFlags
+	// fileFilter selects the files of the diff that are included. It may be
+	// nil.
+	fileFilter *batchesdiff.FileFilter
+	// commitPerStep is true if every step that changed files becomes its own
+	// commit, provided the result contains the diffs of the steps.
+	commitPerStep bool
+	// stepCommitMessages are the commit message templates of the steps, by
+	// index in the steps of the batch spec. Steps without one use the commit
+	// message of the changeset template.
+	stepCommitMessages map[int]string
+}
+
+// createChangesetSpecs creates the changeset specs for the result of the
+// task. Files not selected by the file filter are removed from the diff and
+// the changed files first, and no changeset specs are created if that leaves
+// the diff empty. The specs are adapted to the code host of the repository,
+// see adaptChangesetSpec.
+func createChangesetSpecs(task *Task, result executionResult, opts changesetSpecsOpts) ([]*batcheslib.ChangesetSpec, error) {
+	features := opts.features
+	if filter := opts.fileFilter; filter != nil {
+		filtered, err := filter.Apply(result.Diff)
+		if err != nil {
+			return nil, errors.Wrapf(err, "filtering diff of %s", task.Repository.Name)
+		}
+		if filtered == "" {
+			return nil, nil
+		}
+		result.Diff = filtered
+		if result.ChangedFiles != nil {
+			result.ChangedFiles = &git.Changes{
+				Modified: filter.Paths(result.ChangedFiles.Modified),
+				Added:    filter.Paths(result.ChangedFiles.Added),
+				Deleted:  filter.Paths(result.ChangedFiles.Deleted),
+				Renamed:  filter.Paths(result.ChangedFiles.Renamed),
+			}
+		}
+	}
 
-func createChangesetSpecs(task *Task, result executionResult, features batches.FeatureFlags) ([]*batcheslib.ChangesetSpec, error) {
 	tmplCtx := &template.ChangesetTemplateContext{
 		BatchChangeAttributes: *task.BatchChangeAttributes,
 		Steps: template.StepsContext{
@@ -70,6 +113,11 @@ func createChangesetSpecs(task *Task, result executionResult, features batches.F
 		return nil, err
 	}
 
+	commits, err := stepCommits(result.StepDiffs, message, tmplCtx, opts)
+	if err != nil {
+		return nil, err
+	}
+
 	newSpec := func(branch, diff string) (*batcheslib.ChangesetSpec, error) {
 		var published interface{} = nil
 		if task.Template.Published != nil {
@@ -85,7 +133,16 @@ func createChangesetSpecs(task *Task, result executionResult, features batches.F
 			return nil, errOptionalPublishedUnsupported
 		}
 
-		return &batcheslib.ChangesetSpec{
+		specCommits := []batcheslib.GitCommitDescription{{Message: message, Diff: diff}}
+		if len(commits) > 0 {
+			specCommits = commits
+		}
+		for i := range specCommits {
+			specCommits[i].AuthorName = authorName
+			specCommits[i].AuthorEmail = authorEmail
+		}
+
+		spec := &batcheslib.ChangesetSpec{
 			BaseRepository: task.Repository.ID,
 
 			BaseRef:        task.Repository.BaseRef(),
@@ -94,22 +151,23 @@ func createChangesetSpecs(task *Task, result executionResult, features batches.F
 			HeadRef:        util.EnsureRefPrefix(branch),
 			Title:          title,
 			Body:           body,
-			Commits: []batcheslib.GitCommitDescription{
-				{
-					Message:     message,
-					AuthorName:  authorName,
-					AuthorEmail: authorEmail,
-					Diff:        diff,
-				},
-			},
-			Published: batcheslib.PublishedValue{Val: published},
-		}, nil
+			Commits:        specCommits,
+			Published:      batcheslib.PublishedValue{Val: published},
+		}
+		if err := adaptChangesetSpec(task, spec); err != nil {
+			return nil, err
+		}
+		return spec, nil
 	}
 
 	var specs []*batcheslib.ChangesetSpec
 
 	groups := groupsForRepository(task.Repository.Name, task.TransformChanges)
 	if len(groups) != 0 {
+		if len(commits) > 0 {
+			return nil, batcheslib.NewValidationError(errors.Newf("commits per step can't be combined with transformChanges groups in repository %s", task.Repository.Name))
+		}
+
 		err := validateGroups(task.Repository.Name, task.Template.Branch, groups)
 		if err != nil {
 			return specs, err
@@ -139,6 +197,39 @@ func createChangesetSpecs(task *Task, result executionResult, features batches.F
 	return specs, nil
 }
 
+// stepCommits returns one commit per diff of a step, with the commit message
+// of the step or the given default message. It returns nil if the changeset
+// spec should have a single commit instead.
+func stepCommits(diffs []stepDiff, message string, tmplCtx *template.ChangesetTemplateContext, opts changesetSpecsOpts) ([]batcheslib.GitCommitDescription, error) {
+	if !opts.commitPerStep || len(diffs) == 0 {
+		return nil, nil
+	}
+
+	var commits []batcheslib.GitCommitDescription
+	for _, d := range diffs {
+		filtered, err := opts.fileFilter.Apply(d.Diff)
+		if err != nil {
+			return nil, errors.Wrapf(err, "filtering diff of step %d", d.StepIndex+1)
+		}
+		if filtered == "" {
+			continue
+		}
+
+		stepMessage := message
+		if tmpl, ok := opts.stepCommitMessages[d.StepIndex]; ok {
+			if stepMessage, err = template.RenderChangesetTemplateField("commitMessage", tmpl, tmplCtx); err != nil {
+				return nil, errors.Wrapf(err, "rendering commit message of step %d", d.StepIndex+1)
+			}
+		}
+
+		commits = append(commits, batcheslib.GitCommitDescription{
+			Message: stepMessage,
+			Diff:    filtered,
+		})
+	}
+	return commits, nil
+}
+
 func groupsForRepository(repoName string, transform *batcheslib.TransformChanges) []batcheslib.Group {
 	groups := []batcheslib.Group{}
 
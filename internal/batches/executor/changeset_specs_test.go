@@ -91,6 +91,9 @@ func TestCreateChangesetSpecs(t *testing.T) {
 		features batches.FeatureFlags
 		result   executionResult
 
+		commitPerStep      bool
+		stepCommitMessages map[int]string
+
 		want    []*batcheslib.ChangesetSpec
 		wantErr string
 	}{
@@ -163,6 +166,90 @@ func TestCreateChangesetSpecs(t *testing.T) {
 			},
 			wantErr: "",
 		},
+		{
+			name:     "commits per step",
+			task:     defaultTask,
+			features: featuresAllEnabled(),
+			result: executionResult{
+				Diff:      "cool diff",
+				StepDiffs: []stepDiff{{StepIndex: 0, Diff: "diff 1"}, {StepIndex: 2, Diff: "diff 2"}},
+			},
+			commitPerStep:      true,
+			stepCommitMessages: map[int]string{2: "Step ${{ repository.name }}"},
+			want: []*batcheslib.ChangesetSpec{
+				specWith(defaultChangesetSpec, func(s *batcheslib.ChangesetSpec) {
+					s.Commits = []batcheslib.GitCommitDescription{
+						{
+							Message:     "git commit message",
+							Diff:        "diff 1",
+							AuthorName:  "Sourcegraph",
+							AuthorEmail: "batch-changes@sourcegraph.com",
+						},
+						{
+							Message:     "Step " + testRepo1.Name,
+							Diff:        "diff 2",
+							AuthorName:  "Sourcegraph",
+							AuthorEmail: "batch-changes@sourcegraph.com",
+						},
+					}
+				}),
+			},
+		},
+		{
+			name: "gerrit",
+			task: taskWith(defaultTask, func(task *Task) {
+				task.Repository.ExternalRepository.ServiceType = "gerrit"
+			}),
+			features: featuresAllEnabled(),
+			result:   defaultResult,
+			want: []*batcheslib.ChangesetSpec{
+				specWith(defaultChangesetSpec, func(s *batcheslib.ChangesetSpec) {
+					s.Commits[0].Message = "git commit message\n\nChange-Id: " + gerritChangeID("the name", testRepo1.Name, "refs/heads/my-branch")
+				}),
+			},
+		},
+		{
+			name: "gerrit with commits per step",
+			task: taskWith(defaultTask, func(task *Task) {
+				task.Repository.ExternalRepository.ServiceType = "gerrit"
+			}),
+			features: featuresAllEnabled(),
+			result: executionResult{
+				Diff:      "cool diff",
+				StepDiffs: []stepDiff{{StepIndex: 0, Diff: "diff 1"}, {StepIndex: 2, Diff: "diff 2"}},
+			},
+			commitPerStep: true,
+			wantErr:       "the changeset in repository github.com/sourcegraph/src-cli has 2 commits, but a changeset on Gerrit is a single change: commits per step can't be used on Gerrit",
+		},
+		{
+			name: "gerrit with a reserved branch",
+			task: taskWith(defaultTask, func(task *Task) {
+				task.Repository.ExternalRepository.ServiceType = "gerrit"
+				task.Template.Branch = "refs/for/main"
+			}),
+			features: featuresAllEnabled(),
+			result:   defaultResult,
+			wantErr:  `branch "refs/for/main" of the changeset in repository github.com/sourcegraph/src-cli can't be used on Gerrit, which reserves refs starting with refs/for/`,
+		},
+		{
+			name: "perforce",
+			task: taskWith(defaultTask, func(task *Task) {
+				task.Repository.ExternalRepository.ServiceType = "perforce"
+			}),
+			features: featuresAllEnabled(),
+			result:   defaultResult,
+			want:     []*batcheslib.ChangesetSpec{defaultChangesetSpec},
+		},
+		{
+			name: "perforce draft",
+			task: taskWith(defaultTask, func(task *Task) {
+				task.Repository.ExternalRepository.ServiceType = "perforce"
+				task.Template.Published = parsePublishedFieldString(t, `"draft"`)
+			}),
+			features: featuresAllEnabled(),
+			result:   defaultResult,
+			wantErr:  "the changeset in repository github.com/sourcegraph/src-cli can't be published as a draft, as Perforce changelists have no draft state",
+		},
 		{
 			name: "publish in UI on an unsupported version",
 			task: taskWith(defaultTask, func(task *Task) {
@@ -177,7 +264,11 @@ func TestCreateChangesetSpecs(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			have, err := createChangesetSpecs(tt.task, tt.result, tt.features)
+			have, err := createChangesetSpecs(tt.task, tt.result, changesetSpecsOpts{
+				features:           tt.features,
+				commitPerStep:      tt.commitPerStep,
+				stepCommitMessages: tt.stepCommitMessages,
+			})
 			if err != nil {
 				if tt.wantErr != "" {
 					if err.Error() != tt.wantErr {
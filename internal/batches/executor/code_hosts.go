@@ -0,0 +1,92 @@
+package executor
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+)
+
+// gerritReservedRefPrefixes are the prefixes of refs that Gerrit treats
+// specially on push, so that branches can't be named after them.
+var gerritReservedRefPrefixes = []string{"refs/for/", "refs/changes/", "refs/meta/", "refs/drafts/", "refs/publish/"}
+
+// adaptChangesetSpec adapts the changeset spec to the code host of the task's
+// repository, where changesets aren't branches with pull requests:
+//
+// On Gerrit, a changeset is a single change that is pushed for review to the
+// base branch. The branch only identifies the changeset, and the commit gets a
+// Change-Id trailer derived from the batch change, the repository and the
+// branch, so that applying the batch spec again updates the same change.
+//
+// On Perforce, a changeset is a shelved changelist with the commit message as
+// its description. Shelved changelists have no draft state, and applying the
+// batch spec again updates the files of the same shelf.
+//
+// A validation error is returned if the changeset can't be represented on the
+// code host.
+func adaptChangesetSpec(task *Task, spec *batcheslib.ChangesetSpec) error {
+	repo := task.Repository.Name
+	switch strings.ToLower(task.Repository.ExternalRepository.ServiceType) {
+	case "gerrit":
+		branch := strings.TrimPrefix(spec.HeadRef, "refs/heads/")
+		for _, prefix := range gerritReservedRefPrefixes {
+			if strings.HasPrefix(branch, prefix) {
+				return batcheslib.NewValidationError(errors.Newf("branch %q of the changeset in repository %s can't be used on Gerrit, which reserves refs starting with %s", branch, repo, prefix))
+			}
+		}
+		if len(spec.Commits) != 1 {
+			return batcheslib.NewValidationError(errors.Newf("the changeset in repository %s has %d commits, but a changeset on Gerrit is a single change: commits per step can't be used on Gerrit", repo, len(spec.Commits)))
+		}
+		changeID := gerritChangeID(task.BatchChangeAttributes.Name, repo, spec.HeadRef)
+		spec.Commits[0].Message = withChangeID(spec.Commits[0].Message, changeID)
+
+	case "perforce":
+		if len(spec.Commits) != 1 {
+			return batcheslib.NewValidationError(errors.Newf("the changeset in repository %s has %d commits, but a changeset on Perforce is a single changelist: commits per step can't be used on Perforce", repo, len(spec.Commits)))
+		}
+		if spec.Published.Val == "draft" {
+			return batcheslib.NewValidationError(errors.Newf("the changeset in repository %s can't be published as a draft, as Perforce changelists have no draft state", repo))
+		}
+	}
+	return nil
+}
+
+// gerritChangeID returns the Change-Id of the Gerrit change of the changeset
+// with the given branch in the repository.
+func gerritChangeID(batchChange, repo, headRef string) string {
+	return fmt.Sprintf("I%x", sha1.Sum([]byte(batchChange+"\x00"+repo+"\x00"+headRef)))
+}
+
+var (
+	changeIDTrailer = regexp.MustCompile(`(?m)^Change-Id: I[0-9a-f]{40}\s*$`)
+	trailerLine     = regexp.MustCompile(`^[A-Za-z0-9-]+: `)
+)
+
+// withChangeID adds the Change-Id trailer to the commit message, unless it has
+// one already. It's added to the trailers at the end of the message, such as
+// Signed-off-by, if there are any.
+func withChangeID(message, changeID string) string {
+	if changeIDTrailer.MatchString(message) {
+		return message
+	}
+
+	message = strings.TrimRight(message, "\n")
+	paragraphs := strings.Split(message, "\n\n")
+	if last := paragraphs[len(paragraphs)-1]; len(paragraphs) > 1 && isTrailers(last) {
+		return message + "\nChange-Id: " + changeID
+	}
+	return message + "\n\nChange-Id: " + changeID
+}
+
+func isTrailers(paragraph string) bool {
+	for _, line := range strings.Split(paragraph, "\n") {
+		if !trailerLine.MatchString(line) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWithChangeID(t *testing.T) {
+	const changeID = "I0123456789abcdef0123456789abcdef01234567"
+
+	for name, tc := range map[string]struct {
+		message string
+		want    string
+	}{
+		"subject only": {
+			message: "Update the library\n",
+			want:    "Update the library\n\nChange-Id: " + changeID,
+		},
+		"with body": {
+			message: "Update the library\n\nThe old one is deprecated.",
+			want:    "Update the library\n\nThe old one is deprecated.\n\nChange-Id: " + changeID,
+		},
+		"with trailers": {
+			message: "Update the library\n\nSigned-off-by: Alice <alice@example.com>\n",
+			want:    "Update the library\n\nSigned-off-by: Alice <alice@example.com>\nChange-Id: " + changeID,
+		},
+		"with a Change-Id": {
+			message: "Update the library\n\nChange-Id: Ifedcba9876543210fedcba9876543210fedcba98\n",
+			want:    "Update the library\n\nChange-Id: Ifedcba9876543210fedcba9876543210fedcba98\n",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, withChangeID(tc.message, changeID)); diff != "" {
+				t.Errorf("wrong message (-want +have):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGerritChangeID(t *testing.T) {
+	id := gerritChangeID("hello-world", "gerrit.example.com/project", "refs/heads/hello-world")
+	if len(id) != 41 || id[0] != 'I' {
+		t.Errorf("invalid Change-Id %q", id)
+	}
+	if other := gerritChangeID("hello-world", "gerrit.example.com/project", "refs/heads/hello-world"); other != id {
+		t.Errorf("Change-Id isn't stable: %q and %q", id, other)
+	}
+	if other := gerritChangeID("hello-world", "gerrit.example.com/other", "refs/heads/hello-world"); other == id {
+		t.Errorf("repositories have the same Change-Id %q", id)
+	}
+}
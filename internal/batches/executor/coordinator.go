@@ -2,25 +2,35 @@ package executor
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/cockroachdb/errors"
 	"github.com/hashicorp/go-multierror"
 
 	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+	"github.com/sourcegraph/sourcegraph/lib/batches/template"
 
 	"github.com/sourcegraph/src-cli/internal/api"
 	"github.com/sourcegraph/src-cli/internal/batches"
+	"github.com/sourcegraph/src-cli/internal/batches/diff"
 	"github.com/sourcegraph/src-cli/internal/batches/docker"
 	"github.com/sourcegraph/src-cli/internal/batches/graphql"
 	"github.com/sourcegraph/src-cli/internal/batches/log"
+	"github.com/sourcegraph/src-cli/internal/batches/reaper"
 	"github.com/sourcegraph/src-cli/internal/batches/repozip"
 	"github.com/sourcegraph/src-cli/internal/batches/workspace"
+	"github.com/sourcegraph/src-cli/internal/metrics"
 )
 
+var cacheLookups = metrics.Default.NewCounter("src_batch_cache_lookups_total", "Lookups of the results of tasks in the execution cache, by result (hit or miss).", "result")
+
 type taskExecutor interface {
 	Start(context.Context, []*Task, TaskExecutionUI)
+	// Wait returns the results of the tasks that succeeded.
 	Wait(context.Context) ([]taskResult, error)
+	Failed() int
+	KeptWorkspaces() []KeptWorkspace
 }
 
 // Coordinates coordinates the execution of Tasks. It makes use of an executor,
@@ -32,6 +42,9 @@ type Coordinator struct {
 	cache      ExecutionCache
 	exec       taskExecutor
 	logManager log.LogManager
+	// archives provide the repository archives that the cache keys of steps
+	// with cacheKeyPaths are computed from.
+	archives repozip.ArchiveRegistry
 }
 
 type repoNameResolver func(ctx context.Context, name string) (*graphql.Repository, error)
@@ -43,6 +56,12 @@ type NewCoordinatorOpts struct {
 	EnsureImage     imageEnsurer
 	Creator         workspace.Creator
 	Client          api.Client
+	// Tracker records the step containers, so that they can be removed
+	// if the execution is interrupted. It may be nil.
+	Tracker *reaper.Tracker
+	// RepoArchiveRegistry provides the archives of the repositories. If
+	// it's nil, they're downloaded from Sourcegraph.
+	RepoArchiveRegistry repozip.ArchiveRegistry
 
 	// Everything that follows are either command-line flags or features.
 
@@ -54,36 +73,86 @@ type NewCoordinatorOpts struct {
 
 	// Used by createChangesetSpecs
 	Features batches.FeatureFlags
+	// FileFilter selects the files of the diffs that are included in the
+	// changeset specs. It may be nil.
+	FileFilter *diff.FileFilter
+	// CommitPerStep is true if every step that changed files becomes its own
+	// commit in the changeset specs. StepCommitMessages are the commit message
+	// templates of the steps, by index in the steps of the batch spec.
+	CommitPerStep      bool
+	StepCommitMessages map[int]string
+	// StepEnvironments are the parts of the environments of the steps that
+	// src resolves itself, by index in the steps of the batch spec.
+	StepEnvironments map[int]StepEnvironment
+	// SerialSteps are the steps that run in only one workspace at a time, by
+	// index in the steps of the batch spec.
+	SerialSteps map[int]bool
 
 	CleanArchives bool
 	Parallelism   int
 	Timeout       time.Duration
 	KeepLogs      bool
 	TempDir       string
+	Sandbox       SandboxProfile
+	// KeepWorkspaces determines which workspaces are retained after their
+	// tasks have been executed.
+	KeepWorkspaces KeepWorkspaces
+	// CredentialOpts are given to `docker run` for every step to forward
+	// credentials of the host, such as by ForwardSSHAgentOpts.
+	CredentialOpts []string
+	// Stop is closed to stop the execution gracefully, such as on the first
+	// interrupt: no more tasks are started, and the tasks that are running
+	// finish, unless the context of Execute is canceled. It may be nil.
+	Stop <-chan struct{}
 }
 
 func NewCoordinator(opts NewCoordinatorOpts) *Coordinator {
 	cache := NewCache(opts.CacheDir)
 	logManager := log.NewManager(opts.TempDir, opts.KeepLogs)
 
-	exec := newExecutor(newExecutorOpts{
-		RepoArchiveRegistry: repozip.NewArchiveRegistry(opts.Client, opts.CacheDir, opts.CleanArchives),
-		EnsureImage:         opts.EnsureImage,
-		Creator:             opts.Creator,
-		Logger:              logManager,
-
-		Parallelism: opts.Parallelism,
-		Timeout:     opts.Timeout,
-		TempDir:     opts.TempDir,
-	})
+	archives := opts.RepoArchiveRegistry
+	if archives == nil {
+		archives = repozip.NewArchiveRegistry(opts.Client, opts.CacheDir, opts.CleanArchives)
+	}
 
-	return &Coordinator{
+	c := &Coordinator{
 		opts: opts,
 
 		cache:      cache,
-		exec:       exec,
 		logManager: logManager,
+		archives:   archives,
 	}
+	c.exec = newExecutor(newExecutorOpts{
+		CheckCache:          c.checkFetchedCache,
+		RepoArchiveRegistry: archives,
+		EnsureImage:         opts.EnsureImage,
+		Creator:             opts.Creator,
+		Logger:              logManager,
+		Tracker:             opts.Tracker,
+
+		Parallelism:    opts.Parallelism,
+		SerialSteps:    opts.SerialSteps,
+		Timeout:        opts.Timeout,
+		TempDir:        opts.TempDir,
+		Sandbox:        opts.Sandbox,
+		KeepWorkspaces: opts.KeepWorkspaces,
+		CredentialOpts: opts.CredentialOpts,
+		Stop:           opts.Stop,
+	})
+
+	return c
+}
+
+// KeptWorkspaces returns the workspaces that were retained after executing
+// their tasks, according to NewCoordinatorOpts.KeepWorkspaces.
+func (c *Coordinator) KeptWorkspaces() []KeptWorkspace {
+	return c.exec.KeptWorkspaces()
+}
+
+// ArchiveCacheStats returns how many repository archives were taken from the
+// cache directory and how many were downloaded so far.
+func (c *Coordinator) ArchiveCacheStats() repozip.CacheStats {
+	return c.archives.CacheStats()
 }
 
 // CheckCache checks whether the internal ExecutionCache contains
@@ -91,15 +160,24 @@ func NewCoordinator(opts NewCoordinatorOpts) *Coordinator {
 // are returned, otherwise the Task, to be executed later.
 func (c *Coordinator) CheckCache(ctx context.Context, tasks []*Task) (uncached []*Task, specs []*batcheslib.ChangesetSpec, err error) {
 	for _, t := range tasks {
+		// The cache of tasks with cacheKeyPaths is checked by the executor,
+		// once it fetched their archives.
+		if t.hasCacheKeyPaths() && t.cacheKeyContents == nil {
+			uncached = append(uncached, t)
+			continue
+		}
+
 		cachedSpecs, found, err := c.checkCacheForTask(ctx, t)
 		if err != nil {
 			return nil, nil, err
 		}
 
 		if !found {
+			cacheLookups.Inc("miss")
 			uncached = append(uncached, t)
 			continue
 		}
+		cacheLookups.Inc("hit")
 
 		specs = append(specs, cachedSpecs...)
 	}
@@ -136,7 +214,7 @@ func (c *Coordinator) checkCacheForTask(ctx context.Context, task *Task) (specs
 		return specs, true, nil
 	}
 
-	specs, err = createChangesetSpecs(task, result, c.opts.Features)
+	specs, err = createChangesetSpecs(task, result, c.changesetSpecsOpts())
 	if err != nil {
 		return specs, false, err
 	}
@@ -144,6 +222,39 @@ func (c *Coordinator) checkCacheForTask(ctx context.Context, task *Task) (specs
 	return specs, true, nil
 }
 
+func (c *Coordinator) changesetSpecsOpts() changesetSpecsOpts {
+	return changesetSpecsOpts{
+		features:           c.opts.Features,
+		fileFilter:         c.opts.FileFilter,
+		commitPerStep:      c.opts.CommitPerStep,
+		stepCommitMessages: c.opts.StepCommitMessages,
+	}
+}
+
+// checkFetchedCache checks the cache of a Task with cacheKeyPaths, once the
+// executor computed the digests its cache keys are based on. If the Task isn't
+// cached, the cached results of its steps are set, as by Execute.
+func (c *Coordinator) checkFetchedCache(ctx context.Context, task *Task) (executionResult, bool, error) {
+	cacheKey := task.cacheKey()
+	if c.opts.ClearCache {
+		if err := c.cache.Clear(ctx, cacheKey); err != nil {
+			return executionResult{}, false, errors.Wrapf(err, "clearing cache for %q", task.Repository.Name)
+		}
+	} else {
+		result, found, err := c.cache.Get(ctx, cacheKey)
+		if err != nil {
+			return executionResult{}, false, errors.Wrapf(err, "checking cache for %q", task.Repository.Name)
+		}
+		if found {
+			cacheLookups.Inc("hit")
+			return result, true, nil
+		}
+		cacheLookups.Inc("miss")
+	}
+
+	return executionResult{}, false, c.setCachedStepResults(ctx, task)
+}
+
 func (c *Coordinator) setCachedStepResults(ctx context.Context, task *Task) error {
 	// We start at the back so that we can find the _last_ cached step,
 	// then restart execution on the following step.
@@ -196,7 +307,7 @@ func (c *Coordinator) cacheAndBuildSpec(ctx context.Context, taskResult taskResu
 	}
 
 	// Build the changeset specs.
-	specs, err := createChangesetSpecs(taskResult.task, taskResult.result, c.opts.Features)
+	specs, err := createChangesetSpecs(taskResult.task, taskResult.result, c.changesetSpecsOpts())
 	if err != nil {
 		return nil, err
 	}
@@ -215,8 +326,12 @@ func (c *Coordinator) Execute(ctx context.Context, tasks []*Task, spec *batchesl
 	)
 
 	// If we are here, that means we didn't find anything in the cache for the
-	// complete task. So, what if we have cached results for the steps?
+	// complete task. So, what if we have cached results for the steps? The
+	// ones of tasks with cacheKeyPaths are set by checkFetchedCache.
 	for _, t := range tasks {
+		if t.hasCacheKeyPaths() && t.cacheKeyContents == nil {
+			continue
+		}
 		if err := c.setCachedStepResults(ctx, t); err != nil {
 			return nil, nil, err
 		}
@@ -227,15 +342,11 @@ func (c *Coordinator) Execute(ctx context.Context, tasks []*Task, spec *batchesl
 	// Run executor
 	c.exec.Start(ctx, tasks, ui)
 	results, err := c.exec.Wait(ctx)
-	if err != nil {
-		if c.opts.SkipErrors {
-			errs = multierror.Append(errs, err)
-		} else {
-			return nil, nil, err
-		}
-	}
 
-	// Write results to cache, build ChangesetSpecs if possible and add to list.
+	// Write results to cache, build ChangesetSpecs if possible and add to
+	// list. The results of the tasks that completed are cached even if
+	// others failed or the execution was interrupted, so that they aren't
+	// executed again.
 	for _, taskResult := range results {
 		taskSpecs, err := c.cacheAndBuildSpec(ctx, taskResult, ui)
 		if err != nil {
@@ -245,7 +356,68 @@ func (c *Coordinator) Execute(ctx context.Context, tasks []*Task, spec *batchesl
 		specs = append(specs, taskSpecs...)
 	}
 
+	if ctx.Err() != nil || c.stopped() {
+		return nil, c.logManager.LogFiles(), &InterruptedError{Completed: len(results), Failed: c.exec.Failed(), Total: len(tasks)}
+	}
+	if err != nil {
+		if c.opts.SkipErrors {
+			errs = multierror.Append(errs, err)
+		} else {
+			return nil, nil, err
+		}
+	}
+
 	// Add external changeset specs.
+	importSpecs, importErrs, err := c.importChangesetSpecs(ctx, spec)
+	if err != nil {
+		return nil, nil, err
+	}
+	specs = append(specs, importSpecs...)
+	if importErrs != nil {
+		errs = multierror.Append(errs, importErrs)
+	}
+
+	return specs, c.logManager.LogFiles(), errs.ErrorOrNil()
+}
+
+func (c *Coordinator) stopped() bool {
+	select {
+	case <-c.opts.Stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// InterruptedError is returned by Execute if the execution was stopped or
+// canceled. The results of the tasks that completed are cached.
+type InterruptedError struct {
+	// Completed is the number of tasks that completed successfully, and
+	// Failed the number of tasks that failed before the interruption. The
+	// failed tasks are executed again by the next execution, together with
+	// the tasks that didn't run.
+	Completed int
+	Failed    int
+	Total     int
+}
+
+func (e *InterruptedError) Error() string {
+	if e.Failed > 0 {
+		return fmt.Sprintf("execution interrupted with %d of %d tasks completed and %d failed", e.Completed, e.Total, e.Failed)
+	}
+	return fmt.Sprintf("execution interrupted with %d of %d tasks completed", e.Completed, e.Total)
+}
+
+// Is makes InterruptedError match context.Canceled, so that it's treated
+// like other interruptions.
+func (e *InterruptedError) Is(target error) bool {
+	return target == context.Canceled
+}
+
+// importChangesetSpecs builds the changeset specs for the importChangesets
+// statements of the given spec. If SkipErrors is set, repositories that can't
+// be resolved are skipped and their errors returned in errs.
+func (c *Coordinator) importChangesetSpecs(ctx context.Context, spec *batcheslib.BatchSpec) (specs []*batcheslib.ChangesetSpec, errs *multierror.Error, err error) {
 	for _, ic := range spec.ImportChangesets {
 		repo, err := c.opts.ResolveRepoName(ctx, ic.Repository)
 		if err != nil {
@@ -271,5 +443,103 @@ func (c *Coordinator) Execute(ctx context.Context, tasks []*Task, spec *batchesl
 		}
 	}
 
-	return specs, c.logManager.LogFiles(), errs.ErrorOrNil()
+	return specs, errs, nil
+}
+
+// TaskResult is the result of executing the steps of a Task.
+type TaskResult struct {
+	// Diff is the diff produced by all steps.
+	Diff string
+	// Outputs are the outputs produced by all steps.
+	Outputs map[string]interface{}
+}
+
+// CachedResult returns the result of the given Task from the ExecutionCache,
+// such as after executing it with Execute. found is false if the result isn't
+// cached.
+func (c *Coordinator) CachedResult(ctx context.Context, task *Task) (result TaskResult, found bool, err error) {
+	cached, found, err := c.cache.Get(ctx, task.cacheKey())
+	if err != nil {
+		return TaskResult{}, false, errors.Wrapf(err, "checking cache for %q", task.Repository.Name)
+	}
+	if !found {
+		return TaskResult{}, false, nil
+	}
+	return TaskResult{Diff: cached.Diff, Outputs: cached.Outputs}, true, nil
+}
+
+// CacheTemplateContexts stores the repositories, file matches and results of
+// the given Tasks of the spec in the ExecutionCache, so that the changeset
+// template can later be rendered again by RenderCachedTemplates. Nothing is
+// stored unless the results of all Tasks are cached.
+func (c *Coordinator) CacheTemplateContexts(ctx context.Context, spec *batcheslib.BatchSpec, tasks []*Task) error {
+	contexts := make([]taskTemplateContext, 0, len(tasks))
+	for _, task := range tasks {
+		result, found, err := c.cache.Get(ctx, task.cacheKey())
+		if err != nil {
+			return errors.Wrapf(err, "checking cache for %q", task.Repository.Name)
+		}
+		if !found {
+			return nil
+		}
+
+		contexts = append(contexts, taskTemplateContext{
+			Repository: task.Repository,
+			Path:       task.Path,
+			Result:     result,
+		})
+	}
+
+	if err := c.cache.SetTemplateContexts(ctx, TemplateContextsCacheKey{BatchSpec: spec}, contexts); err != nil {
+		return errors.Wrap(err, "caching template contexts")
+	}
+	return nil
+}
+
+// RenderCachedTemplates builds the ChangesetSpecs of the given spec from the
+// template contexts stored by CacheTemplateContexts for a spec with the same
+// steps, without resolving repositories or executing any Tasks. Only the
+// changesetTemplate, transformChanges, name and description of the spec may
+// differ. found is false if there are no such template contexts.
+func (c *Coordinator) RenderCachedTemplates(ctx context.Context, spec *batcheslib.BatchSpec) (specs []*batcheslib.ChangesetSpec, repos []*graphql.Repository, found bool, err error) {
+	contexts, found, err := c.cache.GetTemplateContexts(ctx, TemplateContextsCacheKey{BatchSpec: spec})
+	if err != nil || !found {
+		return nil, nil, false, err
+	}
+
+	attributes := &template.BatchChangeAttributes{
+		Name:        spec.Name,
+		Description: spec.Description,
+	}
+
+	seen := map[string]bool{}
+	for _, tc := range contexts {
+		if !seen[tc.Repository.ID] {
+			seen[tc.Repository.ID] = true
+			repos = append(repos, tc.Repository)
+		}
+
+		if tc.Result.Diff == "" {
+			continue
+		}
+
+		task := &Task{
+			Repository:            tc.Repository,
+			Path:                  tc.Path,
+			BatchChangeAttributes: attributes,
+			Template:              spec.ChangesetTemplate,
+			TransformChanges:      spec.TransformChanges,
+		}
+		taskSpecs, err := createChangesetSpecs(task, tc.Result, c.changesetSpecsOpts())
+		if err != nil {
+			return nil, nil, false, err
+		}
+		specs = append(specs, taskSpecs...)
+	}
+
+	importSpecs, importErrs, err := c.importChangesetSpecs(ctx, spec)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return append(specs, importSpecs...), repos, true, importErrs.ErrorOrNil()
 }
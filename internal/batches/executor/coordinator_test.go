@@ -2,7 +2,10 @@ package executor
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
@@ -427,6 +430,225 @@ func TestCoordinator_Execute_StepCaching(t *testing.T) {
 	assertCacheSize(t, cache, wantCacheSize)
 }
 
+func TestCoordinator_Execute_Interrupted(t *testing.T) {
+	srcCLITask := &Task{Repository: testRepo1, Template: testChangesetTemplate, BatchChangeAttributes: &template.BatchChangeAttributes{}}
+	sourcegraphTask := &Task{Repository: testRepo2, Template: testChangesetTemplate, BatchChangeAttributes: &template.BatchChangeAttributes{}}
+	failedTask := &Task{Repository: testRepo2, Path: "failed", Template: testChangesetTemplate, BatchChangeAttributes: &template.BatchChangeAttributes{}}
+
+	// The execution was stopped while srcCLITask was running, after
+	// failedTask failed, so sourcegraphTask was never started.
+	stop := make(chan struct{})
+	close(stop)
+
+	cache := newInMemoryExecutionCache()
+	coord := Coordinator{
+		cache: cache,
+		exec: &dummyExecutor{
+			results: []taskResult{{task: srcCLITask, result: executionResult{Diff: `dummydiff1`}}},
+			failed:  1,
+		},
+		logManager: mock.LogNoOpManager{},
+		opts:       NewCoordinatorOpts{Stop: stop},
+	}
+
+	specs, _, err := coord.Execute(context.Background(), []*Task{srcCLITask, failedTask, sourcegraphTask}, &batcheslib.BatchSpec{}, newDummyTaskExecutionUI())
+	if len(specs) != 0 {
+		t.Errorf("want no changeset specs, have %d", len(specs))
+	}
+	var interrupted *InterruptedError
+	if !errors.As(err, &interrupted) {
+		t.Fatalf("want InterruptedError, have %v", err)
+	}
+	if want := (InterruptedError{Completed: 1, Failed: 1, Total: 3}); *interrupted != want {
+		t.Errorf("wrong error: want %+v, have %+v", want, *interrupted)
+	}
+	if have, want := err.Error(), "execution interrupted with 1 of 3 tasks completed and 1 failed"; have != want {
+		t.Errorf("wrong message: want %q, have %q", want, have)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Error("error doesn't match context.Canceled")
+	}
+
+	// The result of the completed task is cached, so that it's not executed
+	// again.
+	if have := cache.size(); have != 1 {
+		t.Errorf("want 1 cache entry, have %d", have)
+	}
+	if _, found, err := coord.CachedResult(context.Background(), srcCLITask); err != nil || !found {
+		t.Errorf("result of completed task not cached: found=%t err=%v", found, err)
+	}
+}
+
+func TestCoordinator_RenderCachedTemplates(t *testing.T) {
+	cache := newInMemoryExecutionCache()
+	coord := &Coordinator{
+		cache:      cache,
+		logManager: mock.LogNoOpManager{},
+		opts:       NewCoordinatorOpts{Features: featuresAllEnabled()},
+	}
+
+	spec := &batcheslib.BatchSpec{
+		Name:              "my-batch-change",
+		Steps:             []batcheslib.Step{{Run: `echo "one"`}},
+		ChangesetTemplate: testChangesetTemplate,
+	}
+	task := &Task{
+		Repository:            testRepo1,
+		Steps:                 spec.Steps,
+		BatchChangeAttributes: &template.BatchChangeAttributes{Name: spec.Name},
+		Template:              spec.ChangesetTemplate,
+	}
+	result := executionResult{
+		Diff:    "dummydiff",
+		Outputs: map[string]interface{}{"output1": "my-output"},
+	}
+
+	// Nothing is cached before the task has been executed.
+	if err := coord.CacheTemplateContexts(context.Background(), spec, []*Task{task}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, found, err := coord.RenderCachedTemplates(context.Background(), spec); err != nil || found {
+		t.Fatalf("unexpected template contexts: found=%t, err=%v", found, err)
+	}
+
+	if err := cache.Set(context.Background(), task.cacheKey(), result); err != nil {
+		t.Fatal(err)
+	}
+	if err := coord.CacheTemplateContexts(context.Background(), spec, []*Task{task}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changing the changesetTemplate doesn't invalidate the template contexts.
+	modified := *spec
+	modified.ChangesetTemplate = &batcheslib.ChangesetTemplate{
+		Title:     "title ${{ outputs.output1 }}",
+		Body:      "${{ batch_change.name }} in ${{ repository.name }}",
+		Branch:    "my-branch",
+		Commit:    batcheslib.ExpandedGitCommitDescription{Message: "message"},
+		Published: testChangesetTemplate.Published,
+	}
+
+	specs, repos, found, err := coord.RenderCachedTemplates(context.Background(), &modified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found {
+		t.Fatal("template contexts not found")
+	}
+	if len(repos) != 1 || repos[0].ID != testRepo1.ID {
+		t.Fatalf("wrong repositories: %+v", repos)
+	}
+	if len(specs) != 1 {
+		t.Fatalf("wrong number of specs: %d", len(specs))
+	}
+	if have, want := specs[0].Title, "title my-output"; have != want {
+		t.Errorf("wrong title. want=%q, have=%q", want, have)
+	}
+	if have, want := specs[0].Body, "my-batch-change in "+testRepo1.Name; have != want {
+		t.Errorf("wrong body. want=%q, have=%q", want, have)
+	}
+	if have, want := specs[0].HeadRef, "refs/heads/my-branch"; have != want {
+		t.Errorf("wrong head ref. want=%q, have=%q", want, have)
+	}
+	if have, want := specs[0].Commits[0].Diff, "dummydiff"; have != want {
+		t.Errorf("wrong diff. want=%q, have=%q", want, have)
+	}
+
+	// Changing the steps does.
+	modified.Steps = []batcheslib.Step{{Run: `echo "two"`}}
+	if _, _, found, err := coord.RenderCachedTemplates(context.Background(), &modified); err != nil || found {
+		t.Fatalf("unexpected template contexts: found=%t, err=%v", found, err)
+	}
+}
+
+// TestCoordinator_RenderCachedTemplates_FromExecResults renders the changeset
+// specs from the results in the cache directory of an execution by another
+// Coordinator, as with -from-exec-results.
+func TestCoordinator_RenderCachedTemplates_FromExecResults(t *testing.T) {
+	ctx := context.Background()
+	resultsDir := t.TempDir()
+	newCoordinator := func(dir string) *Coordinator {
+		return &Coordinator{
+			cache:      NewCache(dir),
+			logManager: mock.LogNoOpManager{},
+			opts:       NewCoordinatorOpts{Features: featuresAllEnabled()},
+		}
+	}
+
+	spec := &batcheslib.BatchSpec{
+		Name:              "my-batch-change",
+		Steps:             []batcheslib.Step{{Run: `echo "one"`}},
+		ChangesetTemplate: testChangesetTemplate,
+	}
+	tasks := []*Task{
+		{Repository: testRepo1, Steps: spec.Steps},
+		{Repository: testRepo2, Steps: spec.Steps},
+	}
+
+	executed := newCoordinator(resultsDir)
+	for _, task := range tasks {
+		if err := executed.cache.Set(ctx, task.cacheKey(), executionResult{Diff: "diff of " + task.Repository.Name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := executed.CacheTemplateContexts(ctx, spec, tasks); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("matching spec", func(t *testing.T) {
+		specs, repos, found, err := newCoordinator(resultsDir).RenderCachedTemplates(ctx, spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !found {
+			t.Fatal("execution results not found")
+		}
+		if len(repos) != 2 {
+			t.Fatalf("wrong number of repositories: %d", len(repos))
+		}
+
+		var diffs []string
+		for _, s := range specs {
+			diffs = append(diffs, s.Commits[0].Diff)
+		}
+		want := []string{"diff of " + testRepo1.Name, "diff of " + testRepo2.Name}
+		if diff := cmp.Diff(want, diffs); diff != "" {
+			t.Errorf("wrong diffs (-want +have):\n%s", diff)
+		}
+	})
+
+	t.Run("other cache directory", func(t *testing.T) {
+		if _, _, found, err := newCoordinator(t.TempDir()).RenderCachedTemplates(ctx, spec); err != nil || found {
+			t.Fatalf("unexpected execution results: found=%t, err=%v", found, err)
+		}
+	})
+
+	t.Run("spec with other steps", func(t *testing.T) {
+		other := *spec
+		other.Steps = []batcheslib.Step{{Run: `echo "two"`}}
+		if _, _, found, err := newCoordinator(resultsDir).RenderCachedTemplates(ctx, &other); err != nil || found {
+			t.Fatalf("unexpected execution results: found=%t, err=%v", found, err)
+		}
+	})
+
+	t.Run("invalid results", func(t *testing.T) {
+		dir := t.TempDir()
+		path, err := ExecutionDiskCache{Dir: dir}.cacheFilePath(TemplateContextsCacheKey{BatchSpec: spec})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("not json"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, found, err := newCoordinator(dir).RenderCachedTemplates(ctx, spec); err == nil || found {
+			t.Fatalf("unexpected result for invalid execution results: found=%t, err=%v", found, err)
+		}
+	})
+}
+
 // execAndEnsure executes the given Task with the given cache and dummyExecutor
 // in a new Coordinator, setting cb as the startCallback on the executor.
 func execAndEnsure(t *testing.T, coord *Coordinator, exec *dummyExecutor, task *Task, cb startCallback) {
@@ -558,6 +780,7 @@ type dummyExecutor struct {
 
 	results []taskResult
 	waitErr error
+	failed  int
 }
 
 func (d *dummyExecutor) Start(ctx context.Context, ts []*Task, ui TaskExecutionUI) {
@@ -572,6 +795,10 @@ func (d *dummyExecutor) Wait(context.Context) ([]taskResult, error) {
 	return d.results, d.waitErr
 }
 
+func (d *dummyExecutor) Failed() int { return d.failed }
+
+func (d *dummyExecutor) KeptWorkspaces() []KeptWorkspace { return nil }
+
 // inMemoryExecutionCache provides an in-memory cache for testing purposes.
 type inMemoryExecutionCache struct {
 	cache map[string]interface{}
@@ -650,6 +877,29 @@ func (c *inMemoryExecutionCache) SetStepResult(ctx context.Context, key CacheKey
 	return nil
 }
 
+func (c *inMemoryExecutionCache) GetTemplateContexts(ctx context.Context, key CacheKeyer) ([]taskTemplateContext, bool, error) {
+	res, ok, err := c.getCacheItem(key)
+	if err != nil || !ok {
+		return nil, ok, err
+	}
+
+	contexts, ok := res.([]taskTemplateContext)
+	return contexts, ok, nil
+}
+
+func (c *inMemoryExecutionCache) SetTemplateContexts(ctx context.Context, key CacheKeyer, contexts []taskTemplateContext) error {
+	k, err := key.Key()
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cache[k] = contexts
+	return nil
+}
+
 func (c *inMemoryExecutionCache) Clear(ctx context.Context, key CacheKeyer) error {
 	k, err := key.Key()
 	if err != nil {
@@ -12,6 +12,7 @@ import (
 	"github.com/cockroachdb/errors"
 	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
 
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
 	"github.com/sourcegraph/src-cli/internal/batches/util"
 )
 
@@ -28,7 +29,7 @@ type CacheKeyer interface {
 	Slug() string
 }
 
-func resolveStepsEnvironment(steps []batcheslib.Step) ([]map[string]string, error) {
+func resolveStepsEnvironment(steps []batcheslib.Step, extra func(i int) StepEnvironment) ([]map[string]string, error) {
 	// We have to resolve the step environments and include them in the cache
 	// key to ensure that the cache is properly invalidated when an environment
 	// variable changes.
@@ -36,26 +37,30 @@ func resolveStepsEnvironment(steps []batcheslib.Step) ([]map[string]string, erro
 	// Note that we don't base the cache key on the entire global environment:
 	// if an unrelated environment variable changes, that's fine. We're only
 	// interested in the ones that actually make it into the step container.
+	// Secrets are only included by name, so that they can be rotated without
+	// invalidating the cache.
 	global := os.Environ()
 	envs := make([]map[string]string, len(steps))
 	for i, step := range steps {
 		// TODO: This should also render templates inside env vars.
-		env, err := step.Env.Resolve(global)
+		env, err := resolveStepEnv(step, extra(i), global)
 		if err != nil {
 			return nil, errors.Wrapf(err, "resolving environment for step %d", i)
 		}
-		envs[i] = env
+		envs[i] = extra(i).withoutSecrets(env)
 	}
 	return envs, nil
 }
 
-func marshalHash(t *Task, envs []map[string]string) (string, error) {
+func marshalHash(t *Task, envs []map[string]string, content string) (string, error) {
 	raw, err := json.Marshal(struct {
 		*Task
 		Environments []map[string]string
+		Content      string `json:",omitempty"`
 	}{
 		Task:         t,
 		Environments: envs,
+		Content:      content,
 	})
 	if err != nil {
 		return "", err
@@ -82,6 +87,7 @@ func (key StepsCacheKey) Key() (string, error) {
 		Repository:            key.Task.Repository,
 		Path:                  key.Task.Path,
 		OnlyFetchWorkspace:    key.Task.OnlyFetchWorkspace,
+		CommitPerStep:         key.Task.CommitPerStep,
 		BatchChangeAttributes: key.Task.BatchChangeAttributes,
 		Template:              key.Task.Template,
 		TransformChanges:      key.Task.TransformChanges,
@@ -90,13 +96,20 @@ func (key StepsCacheKey) Key() (string, error) {
 
 	taskCopy.Steps = key.Task.Steps[0 : key.StepIndex+1]
 
+	// Steps with cacheKeyPaths are keyed on the content of the files instead
+	// of the revision of the repository.
+	content := key.Task.cacheKeyContent(key.StepIndex)
+	if content != "" {
+		taskCopy.Repository = withoutRevision(taskCopy.Repository)
+	}
+
 	// Resolve environment only for the subset of Steps
-	envs, err := resolveStepsEnvironment(taskCopy.Steps)
+	envs, err := resolveStepsEnvironment(taskCopy.Steps, key.Task.stepEnvironment)
 	if err != nil {
 		return "", err
 	}
 
-	hash, err := marshalHash(taskCopy, envs)
+	hash, err := marshalHash(taskCopy, envs, content)
 	if err != nil {
 		return "", err
 	}
@@ -104,7 +117,7 @@ func (key StepsCacheKey) Key() (string, error) {
 }
 
 func (key StepsCacheKey) Slug() string {
-	return util.SlugForRepo(key.Repository.Name, key.Repository.Rev())
+	return key.Task.cacheSlug(key.Task.cacheKeyContent(key.StepIndex))
 }
 
 // TaskCacheKey implements the CacheKeyer interface for a Task and all its
@@ -116,28 +129,83 @@ type TaskCacheKey struct {
 // Key converts the key into a string form that can be used to uniquely identify
 // the cache key in a more concise form than the entire Task.
 func (key TaskCacheKey) Key() (string, error) {
-	envs, err := resolveStepsEnvironment(key.Task.Steps)
+	envs, err := resolveStepsEnvironment(key.Task.Steps, key.Task.stepEnvironment)
 	if err != nil {
 		return "", err
 	}
 
-	raw, err := json.Marshal(struct {
-		*Task
-		Environments []map[string]string
-	}{
-		Task:         key.Task,
-		Environments: envs,
-	})
+	task := key.Task
+	content := task.cacheKeyContent(len(task.Steps) - 1)
+	if content != "" {
+		taskCopy := *task
+		taskCopy.Repository = withoutRevision(task.Repository)
+		task = &taskCopy
+	}
+	return marshalHash(task, envs, content)
+}
+
+func (key TaskCacheKey) Slug() string {
+	return key.Task.cacheSlug(key.Task.cacheKeyContent(len(key.Task.Steps) - 1))
+}
+
+// contentSlugRevision takes the place of the revision in the slugs of the keys
+// that are based on the content of files instead of the revision.
+const contentSlugRevision = "content"
+
+// cacheSlug returns the slug of the cache keys of the Task, which, like the
+// keys, doesn't depend on the revision of the repository if content is set,
+// so that results are found at other revisions.
+func (t *Task) cacheSlug(content string) string {
+	if content != "" {
+		return util.SlugForRepo(t.Repository.Name, contentSlugRevision)
+	}
+	return util.SlugForRepo(t.Repository.Name, t.Repository.Rev())
+}
+
+// TemplateContextsCacheKey implements the CacheKeyer interface for the parts
+// of a batch spec that determine which tasks are executed and what they
+// produce. The name, description, changesetTemplate and transformChanges are
+// left out, since they only affect how the changeset specs are rendered.
+//
+// Unlike the keys of Tasks, the key doesn't include the values of the
+// environment variables of the steps, only their names as given in the spec,
+// so that the template contexts can be found with -from-exec-results on a
+// machine that doesn't have the environment of the one that executed the
+// spec. The results of the Tasks are still keyed on the values, so changing
+// one executes the Tasks again, which replaces the template contexts.
+type TemplateContextsCacheKey struct {
+	*batcheslib.BatchSpec
+}
+
+// Key converts the key into a string form that can be used to uniquely identify
+// the cache key in a more concise form than the entire BatchSpec.
+func (key TemplateContextsCacheKey) Key() (string, error) {
+	specCopy := *key.BatchSpec
+	specCopy.Name = ""
+	specCopy.Description = ""
+	specCopy.ChangesetTemplate = nil
+	specCopy.TransformChanges = nil
+
+	raw, err := json.Marshal(&specCopy)
 	if err != nil {
 		return "", err
 	}
 
 	hash := sha256.Sum256(raw)
-	return base64.RawURLEncoding.EncodeToString(hash[:16]), nil
+	return base64.RawURLEncoding.EncodeToString(hash[:16]) + "-template-contexts", nil
 }
 
-func (key TaskCacheKey) Slug() string {
-	return util.SlugForRepo(key.Repository.Name, key.Repository.Rev())
+func (key TemplateContextsCacheKey) Slug() string {
+	return "batch-specs"
+}
+
+// taskTemplateContext is what's needed to render the changeset template of a
+// task without executing it again: the repository it was executed in,
+// including the file matches of the search, and the result of its steps.
+type taskTemplateContext struct {
+	Repository *graphql.Repository `json:"repository"`
+	Path       string              `json:"path"`
+	Result     executionResult     `json:"result"`
 }
 
 type ExecutionCache interface {
@@ -147,6 +215,9 @@ type ExecutionCache interface {
 	GetStepResult(ctx context.Context, key CacheKeyer) (result stepExecutionResult, found bool, err error)
 	SetStepResult(ctx context.Context, key CacheKeyer, result stepExecutionResult) error
 
+	GetTemplateContexts(ctx context.Context, key CacheKeyer) (contexts []taskTemplateContext, found bool, err error)
+	SetTemplateContexts(ctx context.Context, key CacheKeyer, contexts []taskTemplateContext) error
+
 	Clear(ctx context.Context, key CacheKeyer) error
 }
 
@@ -217,7 +288,22 @@ func (c ExecutionDiskCache) writeCacheFile(path string, result interface{}) erro
 		return err
 	}
 
-	return os.WriteFile(path, raw, 0600)
+	// Write to a temporary file first, so that readers, including other src
+	// processes, never see a partially written cache file.
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
 }
 
 func (c ExecutionDiskCache) Set(ctx context.Context, key CacheKeyer, result executionResult) error {
@@ -266,6 +352,30 @@ func (c ExecutionDiskCache) SetStepResult(ctx context.Context, key CacheKeyer, r
 	return c.writeCacheFile(path, &result)
 }
 
+func (c ExecutionDiskCache) GetTemplateContexts(ctx context.Context, key CacheKeyer) ([]taskTemplateContext, bool, error) {
+	var contexts []taskTemplateContext
+	path, err := c.cacheFilePath(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	found, err := c.readCacheFile(path, &contexts)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return contexts, found, nil
+}
+
+func (c ExecutionDiskCache) SetTemplateContexts(ctx context.Context, key CacheKeyer, contexts []taskTemplateContext) error {
+	path, err := c.cacheFilePath(key)
+	if err != nil {
+		return err
+	}
+
+	return c.writeCacheFile(path, contexts)
+}
+
 // ExecutionNoOpCache is an implementation of ExecutionCache that does not store or
 // retrieve cache entries.
 type ExecutionNoOpCache struct{}
@@ -289,3 +399,11 @@ func (ExecutionNoOpCache) SetStepResult(ctx context.Context, key CacheKeyer, res
 func (ExecutionNoOpCache) GetStepResult(ctx context.Context, key CacheKeyer) (stepExecutionResult, bool, error) {
 	return stepExecutionResult{}, false, nil
 }
+
+func (ExecutionNoOpCache) SetTemplateContexts(ctx context.Context, key CacheKeyer, contexts []taskTemplateContext) error {
+	return nil
+}
+
+func (ExecutionNoOpCache) GetTemplateContexts(ctx context.Context, key CacheKeyer) ([]taskTemplateContext, bool, error) {
+	return nil, false, nil
+}
@@ -11,15 +11,25 @@ import (
 	"github.com/neelance/parallel"
 
 	"github.com/sourcegraph/src-cli/internal/batches/log"
+	"github.com/sourcegraph/src-cli/internal/batches/reaper"
 	"github.com/sourcegraph/src-cli/internal/batches/repozip"
 	"github.com/sourcegraph/src-cli/internal/batches/util"
 	"github.com/sourcegraph/src-cli/internal/batches/workspace"
+	"github.com/sourcegraph/src-cli/internal/metrics"
+)
+
+var (
+	tasksRunning   = metrics.Default.NewGauge("src_batch_tasks_running", "Tasks of batch specs that are being executed.")
+	tasksCompleted = metrics.Default.NewCounter("src_batch_tasks_completed_total", "Tasks of batch specs that were executed, by result (success or failure).", "result")
 )
 
 type TaskExecutionErr struct {
 	Err        error
 	Logfile    string
 	Repository string
+	// Workspace is the location of the workspace of the task, if it was
+	// kept.
+	Workspace string
 }
 
 func (e TaskExecutionErr) Cause() error {
@@ -27,6 +37,15 @@ func (e TaskExecutionErr) Cause() error {
 }
 
 func (e TaskExecutionErr) Error() string {
+	if e.Workspace != "" {
+		return fmt.Sprintf(
+			"execution in %s failed: %s (see %s for details, workspace kept at %s)",
+			e.Repository,
+			e.Err,
+			e.Logfile,
+			e.Workspace,
+		)
+	}
 	return fmt.Sprintf(
 		"execution in %s failed: %s (see %s for details)",
 		e.Repository,
@@ -55,11 +74,24 @@ type newExecutorOpts struct {
 	RepoArchiveRegistry repozip.ArchiveRegistry
 	EnsureImage         imageEnsurer
 	Logger              log.LogManager
+	Tracker             *reaper.Tracker
+	// CheckCache looks up the cached result of a Task whose cache keys are
+	// based on the content of files, once they're computed. It may be nil.
+	CheckCache func(ctx context.Context, task *Task) (result executionResult, found bool, err error)
 
 	// Config
 	Parallelism int
-	Timeout     time.Duration
-	TempDir     string
+	// SerialSteps are the steps that run in only one workspace at a time,
+	// by index in the steps of the batch spec.
+	SerialSteps    map[int]bool
+	Timeout        time.Duration
+	TempDir        string
+	Sandbox        SandboxProfile
+	KeepWorkspaces KeepWorkspaces
+	CredentialOpts []string
+	// Stop is closed to stop starting tasks. Tasks that already started run
+	// until they finish or the context is canceled.
+	Stop <-chan struct{}
 }
 
 type executor struct {
@@ -67,9 +99,16 @@ type executor struct {
 
 	par           *parallel.Run
 	doneEnqueuing chan struct{}
+	stepLocks     stepLocks
 
+	// results are the results of the tasks that succeeded, and failed the
+	// number of tasks that failed other than by being canceled.
 	results   []taskResult
+	failed    int
 	resultsMu sync.Mutex
+
+	kept   []KeptWorkspace
+	keptMu sync.Mutex
 }
 
 func newExecutor(opts newExecutorOpts) *executor {
@@ -78,23 +117,29 @@ func newExecutor(opts newExecutorOpts) *executor {
 
 		doneEnqueuing: make(chan struct{}),
 		par:           parallel.NewRun(opts.Parallelism),
+		stepLocks:     newStepLocks(opts.SerialSteps),
 	}
 }
 
 // Start starts the execution of the given Tasks in goroutines, calling the
-// given taskStatusHandler to update the progress of the tasks.
+// given taskStatusHandler to update the progress of the tasks. No more tasks
+// are started once the context is canceled or Stop is closed.
 func (x *executor) Start(ctx context.Context, tasks []*Task, ui TaskExecutionUI) {
 	defer func() { close(x.doneEnqueuing) }()
 
 	for _, task := range tasks {
-		select {
-		case <-ctx.Done():
+		if x.stopped(ctx) {
 			return
-		default:
 		}
 
 		x.par.Acquire()
 
+		// Waiting for a free slot may have taken a while.
+		if x.stopped(ctx) {
+			x.par.Release()
+			return
+		}
+
 		go func(task *Task, ui TaskExecutionUI) {
 			defer x.par.Release()
 
@@ -123,7 +168,10 @@ func (x *executor) Wait(ctx context.Context) ([]taskResult, error) {
 
 	select {
 	case <-ctx.Done():
-		return x.results, ctx.Err()
+		// Tasks that are still running may add their results concurrently.
+		x.resultsMu.Lock()
+		defer x.resultsMu.Unlock()
+		return append([]taskResult(nil), x.results...), ctx.Err()
 	case err := <-result:
 		close(result)
 		if err != nil {
@@ -134,9 +182,30 @@ func (x *executor) Wait(ctx context.Context) ([]taskResult, error) {
 	return x.results, nil
 }
 
+func (x *executor) stopped(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return true
+	case <-x.opts.Stop:
+		return true
+	default:
+		return false
+	}
+}
+
 func (x *executor) do(ctx context.Context, task *Task, ui TaskExecutionUI) (err error) {
 	// Ensure that the status is updated when we're done.
+	tasksRunning.Add(1)
 	defer func() {
+		tasksRunning.Add(-1)
+		if err != nil {
+			tasksCompleted.Inc("failure")
+			if ctx.Err() == nil {
+				x.addFailure()
+			}
+		} else {
+			tasksCompleted.Inc("success")
+		}
 		ui.TaskFinished(task, err)
 	}()
 
@@ -148,18 +217,44 @@ func (x *executor) do(ctx context.Context, task *Task, ui TaskExecutionUI) (err
 	if err != nil {
 		return errors.Wrap(err, "creating log file")
 	}
+	// keptWorkspace is the location of the workspace, if it was kept.
+	var keptWorkspace string
 	defer func() {
 		if err != nil {
 			err = TaskExecutionErr{
 				Err:        err,
 				Logfile:    log.Path(),
 				Repository: task.Repository.Name,
+				Workspace:  keptWorkspace,
 			}
 			log.MarkErrored()
 		}
 		log.Close()
 	}()
 
+	// The cache keys of tasks with cacheKeyPaths are based on the content of
+	// files in the archive, so their cache can only be checked once it's
+	// fetched. The archive is held until the steps are executed, so that it's
+	// not fetched again.
+	if task.hasCacheKeyPaths() && task.cacheKeyContents == nil {
+		archive, err := fetchCacheKeyContents(ctx, x.opts.RepoArchiveRegistry, task)
+		if err != nil {
+			return err
+		}
+		defer archive.Close()
+
+		if x.opts.CheckCache != nil {
+			result, found, err := x.opts.CheckCache(ctx, task)
+			if err != nil {
+				return err
+			}
+			if found {
+				x.addResult(task, result, nil)
+				return nil
+			}
+		}
+	}
+
 	// Now checkout the archive.
 	task.Archive = x.opts.RepoArchiveRegistry.Checkout(repozip.RepoRevision{RepoName: task.Repository.Name, Commit: task.Repository.Rev()}, task.ArchivePathToFetch())
 
@@ -174,11 +269,25 @@ func (x *executor) do(ctx context.Context, task *Task, ui TaskExecutionUI) (err
 		wc:          x.opts.Creator,
 		ensureImage: x.opts.EnsureImage,
 		tempDir:     x.opts.TempDir,
+		sandbox:     x.opts.Sandbox,
+		tracker:     x.opts.Tracker,
+		stepLocks:   x.stepLocks,
+
+		keepWorkspaces: x.opts.KeepWorkspaces,
+		credentialOpts: x.opts.CredentialOpts,
 
 		ui: ui.StepsExecutionUI(task),
 	}
 
 	result, stepResults, err := runSteps(runCtx, opts)
+	if keptWorkspace = opts.keptWorkspace; keptWorkspace != "" {
+		x.addKeptWorkspace(KeptWorkspace{
+			Repository: task.Repository.Name,
+			Path:       task.Path,
+			Location:   keptWorkspace,
+			Failed:     err != nil,
+		})
+	}
 	if err != nil {
 		if reachedTimeout(runCtx, err) {
 			err = &errTimeoutReached{timeout: x.opts.Timeout}
@@ -201,6 +310,37 @@ func (x *executor) addResult(task *Task, result executionResult, stepResults []s
 	})
 }
 
+func (x *executor) addFailure() {
+	x.resultsMu.Lock()
+	defer x.resultsMu.Unlock()
+
+	x.failed++
+}
+
+// Failed returns the number of tasks that failed so far, not counting the
+// tasks that failed because the context was canceled.
+func (x *executor) Failed() int {
+	x.resultsMu.Lock()
+	defer x.resultsMu.Unlock()
+
+	return x.failed
+}
+
+func (x *executor) addKeptWorkspace(kept KeptWorkspace) {
+	x.keptMu.Lock()
+	defer x.keptMu.Unlock()
+
+	x.kept = append(x.kept, kept)
+}
+
+// KeptWorkspaces returns the workspaces that were retained so far.
+func (x *executor) KeptWorkspaces() []KeptWorkspace {
+	x.keptMu.Lock()
+	defer x.keptMu.Unlock()
+
+	return append([]KeptWorkspace(nil), x.kept...)
+}
+
 type errTimeoutReached struct{ timeout time.Duration }
 
 func (e *errTimeoutReached) Error() string {
@@ -341,7 +341,7 @@ func TestExecutor_Integration(t *testing.T) {
 
 			// Setup executor
 			opts := newExecutorOpts{
-				Creator:             workspace.NewCreator(context.Background(), "bind", testTempDir, testTempDir, images),
+				Creator:             workspace.NewCreator(context.Background(), "bind", testTempDir, testTempDir, images, nil),
 				RepoArchiveRegistry: repozip.NewArchiveRegistry(client, testTempDir, false),
 				Logger:              mock.LogNoOpManager{},
 				EnsureImage:         imageMapEnsurer(images),
@@ -444,6 +444,9 @@ func TestExecutor_Integration(t *testing.T) {
 			if have, want := len(dummyUI.finishedWithErr), tc.wantFinishedWithErr; have != want {
 				t.Fatalf("wrong number of finished-with-err tasks. want=%d, have=%d", want, have)
 			}
+			if have, want := executor.Failed(), tc.wantFinishedWithErr; have != want {
+				t.Errorf("wrong number of failed tasks. want=%d, have=%d", want, have)
+			}
 		})
 	}
 }
@@ -693,7 +696,7 @@ func testExecuteTasks(t *testing.T, tasks []*Task, archives ...mock.RepoArchive)
 
 	// Setup executor
 	executor := newExecutor(newExecutorOpts{
-		Creator:             workspace.NewCreator(context.Background(), "bind", testTempDir, testTempDir, images),
+		Creator:             workspace.NewCreator(context.Background(), "bind", testTempDir, testTempDir, images, nil),
 		RepoArchiveRegistry: repozip.NewArchiveRegistry(client, testTempDir, false),
 		Logger:              mock.LogNoOpManager{},
 		EnsureImage:         imageMapEnsurer(images),
@@ -715,3 +718,28 @@ func imageMapEnsurer(m map[string]docker.Image) imageEnsurer {
 		return nil, errors.New(fmt.Sprintf("image for %s not found", container))
 	}
 }
+
+func TestExecutor_Stop(t *testing.T) {
+	stop := make(chan struct{})
+	close(stop)
+
+	// No task is started once the execution is stopped, so the executor's
+	// dependencies are never used.
+	x := newExecutor(newExecutorOpts{Parallelism: 1, Stop: stop})
+	ui := newDummyTaskExecutionUI()
+	x.Start(context.Background(), []*Task{{Repository: testRepo1}, {Repository: testRepo2}}, ui)
+
+	results, err := x.Wait(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("want no results, have %d", len(results))
+	}
+	if x.Failed() != 0 {
+		t.Errorf("want no failed tasks, have %d", x.Failed())
+	}
+	if len(ui.started) != 0 {
+		t.Errorf("want no started tasks, have %d", len(ui.started))
+	}
+}
@@ -0,0 +1,135 @@
+package executor
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxSignatureLength is the maximum length of the signature of a
+// TaskExecutionErr.
+const maxSignatureLength = 120
+
+var (
+	errorLinePattern = regexp.MustCompile(`(?i)(\berr(or)?\b|ERR!|\bfatal\b|\bfailed\b|\bexception\b|\bpanic\b)`)
+
+	signatureReplacements = []struct {
+		pattern     *regexp.Regexp
+		replacement string
+	}{
+		{regexp.MustCompile(`[a-z][a-z0-9+.-]*://\S+`), "<url>"},
+		{regexp.MustCompile(`(?:/[\w.@-]+){2,}/?`), "<path>"},
+		{regexp.MustCompile(`\b[0-9a-f]{7,}\b`), "<hash>"},
+		{regexp.MustCompile(`'[^']*'`), "'…'"},
+		{regexp.MustCompile(`"[^"]*"`), `"…"`},
+		{regexp.MustCompile(`\s+`), " "},
+	}
+)
+
+// Signature returns a short description of the cause of the error that is the
+// same for errors with the same cause in different repositories: the first
+// line of the output of the failed step that looks like an error, with
+// URLs, paths, hashes, quoted strings and the repository name removed.
+func (e TaskExecutionErr) Signature() string {
+	lines, i := e.causeLine()
+	sig := lines[i]
+	if e.Repository != "" {
+		sig = strings.ReplaceAll(sig, e.Repository, "<repo>")
+	}
+	for _, r := range signatureReplacements {
+		sig = r.pattern.ReplaceAllString(sig, r.replacement)
+	}
+
+	sig = strings.TrimSpace(sig)
+	if r := []rune(sig); len(r) > maxSignatureLength {
+		sig = string(r[:maxSignatureLength]) + "…"
+	}
+	return sig
+}
+
+// Excerpt returns up to maxLines lines of the output of the failed step
+// around the line the Signature was taken from.
+func (e TaskExecutionErr) Excerpt(maxLines int) string {
+	lines, i := e.causeLine()
+
+	start := i - maxLines/2
+	if start < 0 {
+		start = 0
+	}
+	end := start + maxLines
+	if end > len(lines) {
+		end = len(lines)
+	}
+	return strings.Join(lines[start:end], "\n")
+}
+
+// causeLine returns the non-empty lines of the output of the failed step, or
+// of the error message if there is no output, and the index of the line that
+// describes the cause of the error best.
+func (e TaskExecutionErr) causeLine() ([]string, int) {
+	var outputs []string
+	if stepErr, ok := e.Err.(stepFailedErr); ok {
+		outputs = append(outputs, stepErr.Stderr, stepErr.Stdout)
+	}
+	outputs = append(outputs, e.Err.Error())
+
+	for _, out := range outputs {
+		lines := nonEmptyLines(out)
+		if len(lines) == 0 {
+			continue
+		}
+		for i, line := range lines {
+			if errorLinePattern.MatchString(line) {
+				return lines, i
+			}
+		}
+	}
+
+	// Nothing looks like an error, so the last line of the output is the
+	// best we can do.
+	for _, out := range outputs {
+		if lines := nonEmptyLines(out); len(lines) > 0 {
+			return lines, len(lines) - 1
+		}
+	}
+	return []string{""}, 0
+}
+
+func nonEmptyLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// TaskErrorGroup is a group of TaskExecutionErrs with the same Signature.
+type TaskErrorGroup struct {
+	Signature string
+	Errs      []TaskExecutionErr
+}
+
+// GroupTaskExecutionErrs groups the errors by their Signature. The groups are
+// sorted by size, largest first, and the errors in each group by repository.
+func GroupTaskExecutionErrs(errs []TaskExecutionErr) []TaskErrorGroup {
+	var groups []TaskErrorGroup
+	bySignature := map[string]int{}
+	for _, err := range errs {
+		sig := err.Signature()
+		i, ok := bySignature[sig]
+		if !ok {
+			i = len(groups)
+			bySignature[sig] = i
+			groups = append(groups, TaskErrorGroup{Signature: sig})
+		}
+		groups[i].Errs = append(groups[i].Errs, err)
+	}
+
+	for _, g := range groups {
+		sort.Slice(g.Errs, func(i, j int) bool { return g.Errs[i].Repository < g.Errs[j].Repository })
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return len(groups[i].Errs) > len(groups[j].Errs) })
+	return groups
+}
@@ -0,0 +1,97 @@
+package executor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTaskExecutionErr_Signature(t *testing.T) {
+	tests := map[string]struct {
+		err  TaskExecutionErr
+		want string
+	}{
+		"npm error": {
+			err: TaskExecutionErr{
+				Repository: "github.com/sourcegraph/src-cli",
+				Err: stepFailedErr{
+					Stdout: "installing\n",
+					Stderr: "npm WARN deprecated\nnpm ERR! 404 Not Found - GET https://registry.npmjs.org/left-pad\nnpm ERR! 404 'left-pad@1.0.0' is not in the registry\n",
+					Err:    errors.New("exit status 1"),
+				},
+			},
+			want: "npm ERR! 404 Not Found - GET <url>",
+		},
+		"paths and repository name": {
+			err: TaskExecutionErr{
+				Repository: "github.com/sourcegraph/sourcegraph",
+				Err: stepFailedErr{
+					Stderr: "cp: cannot stat '/work/github.com/sourcegraph/sourcegraph/a.txt': No such file\nfatal: github.com/sourcegraph/sourcegraph at 4b825dc642cb is broken",
+					Err:    errors.New("exit status 1"),
+				},
+			},
+			want: "fatal: <repo> at <hash> is broken",
+		},
+		"no error line": {
+			err: TaskExecutionErr{
+				Err: stepFailedErr{
+					Stdout: "one\ntwo\n",
+					Err:    errors.New("exit status 2"),
+				},
+			},
+			want: "two",
+		},
+		"not a step error": {
+			err:  TaskExecutionErr{Err: &errTimeoutReached{timeout: 0}},
+			want: "Timeout reached. Execution took longer than 0s.",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if have := tt.err.Signature(); have != tt.want {
+				t.Errorf("wrong signature. want=%q, have=%q", tt.want, have)
+			}
+		})
+	}
+}
+
+func TestTaskExecutionErr_Excerpt(t *testing.T) {
+	err := TaskExecutionErr{Err: stepFailedErr{
+		Stderr: "a\nb\nc\nError: d\ne\nf\ng\n",
+		Err:    errors.New("exit status 1"),
+	}}
+
+	if have, want := err.Excerpt(4), "b\nc\nError: d\ne"; have != want {
+		t.Errorf("wrong excerpt. want=%q, have=%q", want, have)
+	}
+}
+
+func TestGroupTaskExecutionErrs(t *testing.T) {
+	npmErr := func(repo string) TaskExecutionErr {
+		return TaskExecutionErr{
+			Repository: repo,
+			Logfile:    repo + ".log",
+			Err:        stepFailedErr{Stderr: "npm ERR! 404 '" + repo + "'", Err: errors.New("exit status 1")},
+		}
+	}
+	timeoutErr := TaskExecutionErr{Repository: "github.com/x/c", Err: &errTimeoutReached{}}
+
+	groups := GroupTaskExecutionErrs([]TaskExecutionErr{timeoutErr, npmErr("github.com/x/b"), npmErr("github.com/x/a")})
+
+	var have []string
+	for _, g := range groups {
+		for _, err := range g.Errs {
+			have = append(have, g.Signature+": "+err.Repository)
+		}
+	}
+	want := []string{
+		"npm ERR! 404 '…': github.com/x/a",
+		"npm ERR! 404 '…': github.com/x/b",
+		"Timeout reached. Execution took longer than 0s.: github.com/x/c",
+	}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Errorf("wrong groups (-want +have):\n%s", diff)
+	}
+}
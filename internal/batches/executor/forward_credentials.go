@@ -0,0 +1,91 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/cockroachdb/errors"
+)
+
+// The paths in step containers that the forwarded credentials are mounted
+// at.
+const (
+	forwardedSSHAgentSocket   = "/run/src/ssh-agent.sock"
+	forwardedKnownHosts       = "/run/src/known_hosts"
+	forwardedGitCredentials   = "/run/src/git-credentials"
+	dockerDesktopSSHAgentSock = "/run/host-services/ssh-auth.sock"
+)
+
+// ForwardSSHAgentOpts returns the options given to `docker run` for every
+// step to make the SSH agent of the host available in the containers, so that
+// steps can fetch private dependencies without keys being baked into images.
+// The known hosts of the host user and the git credentials stored by the
+// `store` credential helper are mounted read-only alongside, if they exist.
+func ForwardSSHAgentOpts() ([]string, error) {
+	return forwardSSHAgentOpts(runtime.GOOS, os.Getenv, os.UserHomeDir, fileExists)
+}
+
+func forwardSSHAgentOpts(goos string, getenv func(string) string, homeDir func() (string, error), exists func(string) bool) ([]string, error) {
+	sock := getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil, errors.New("SSH_AUTH_SOCK is not set: start an SSH agent and add your keys with ssh-add")
+	}
+
+	switch goos {
+	case "linux":
+	case "darwin":
+		// Sockets on the macOS host can't be mounted into containers, but
+		// Docker Desktop provides the agent of the user at this path in its
+		// VM.
+		sock = dockerDesktopSSHAgentSock
+	default:
+		return nil, errors.Newf("forwarding the SSH agent is not supported on %s", goos)
+	}
+
+	opts := []string{
+		"--mount", "type=bind,source=" + sock + ",target=" + forwardedSSHAgentSocket,
+		"-e", "SSH_AUTH_SOCK=" + forwardedSSHAgentSocket,
+	}
+
+	home, err := homeDir()
+	if err != nil {
+		return opts, nil
+	}
+
+	if knownHosts := filepath.Join(home, ".ssh", "known_hosts"); exists(knownHosts) {
+		opts = append(opts,
+			"--mount", "type=bind,source="+knownHosts+",target="+forwardedKnownHosts+",ro",
+			"-e", "GIT_SSH_COMMAND=ssh -o UserKnownHostsFile="+forwardedKnownHosts,
+		)
+	}
+
+	configHome := getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(home, ".config")
+	}
+	for _, credentials := range []string{
+		filepath.Join(home, ".git-credentials"),
+		filepath.Join(configHome, "git", "credentials"),
+	} {
+		if !exists(credentials) {
+			continue
+		}
+		// GIT_CONFIG_COUNT adds configuration without replacing the
+		// gitconfig of the image.
+		opts = append(opts,
+			"--mount", "type=bind,source="+credentials+",target="+forwardedGitCredentials+",ro",
+			"-e", "GIT_CONFIG_COUNT=1",
+			"-e", "GIT_CONFIG_KEY_0=credential.helper",
+			"-e", "GIT_CONFIG_VALUE_0=store --file="+forwardedGitCredentials,
+		)
+		break
+	}
+
+	return opts, nil
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
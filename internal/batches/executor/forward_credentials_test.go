@@ -0,0 +1,105 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestForwardSSHAgentOpts(t *testing.T) {
+	homeDir := func() (string, error) { return "/home/alice", nil }
+	env := func(vars map[string]string) func(string) string {
+		return func(k string) string { return vars[k] }
+	}
+	existing := func(paths ...string) func(string) bool {
+		return func(p string) bool {
+			for _, have := range paths {
+				if p == have {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	agentOpts := []string{
+		"--mount", "type=bind,source=/tmp/ssh-XXX/agent.1,target=/run/src/ssh-agent.sock",
+		"-e", "SSH_AUTH_SOCK=/run/src/ssh-agent.sock",
+	}
+
+	for name, tc := range map[string]struct {
+		goos    string
+		env     map[string]string
+		exists  func(string) bool
+		want    []string
+		wantErr bool
+	}{
+		"agent only": {
+			goos:   "linux",
+			env:    map[string]string{"SSH_AUTH_SOCK": "/tmp/ssh-XXX/agent.1"},
+			exists: existing(),
+			want:   agentOpts,
+		},
+		"known hosts and credentials": {
+			goos:   "linux",
+			env:    map[string]string{"SSH_AUTH_SOCK": "/tmp/ssh-XXX/agent.1"},
+			exists: existing("/home/alice/.ssh/known_hosts", "/home/alice/.git-credentials", "/home/alice/.config/git/credentials"),
+			want: append(append([]string{}, agentOpts...),
+				"--mount", "type=bind,source=/home/alice/.ssh/known_hosts,target=/run/src/known_hosts,ro",
+				"-e", "GIT_SSH_COMMAND=ssh -o UserKnownHostsFile=/run/src/known_hosts",
+				"--mount", "type=bind,source=/home/alice/.git-credentials,target=/run/src/git-credentials,ro",
+				"-e", "GIT_CONFIG_COUNT=1",
+				"-e", "GIT_CONFIG_KEY_0=credential.helper",
+				"-e", "GIT_CONFIG_VALUE_0=store --file=/run/src/git-credentials",
+			),
+		},
+		"XDG credentials": {
+			goos:   "linux",
+			env:    map[string]string{"SSH_AUTH_SOCK": "/tmp/ssh-XXX/agent.1", "XDG_CONFIG_HOME": "/xdg"},
+			exists: existing("/xdg/git/credentials"),
+			want: append(append([]string{}, agentOpts...),
+				"--mount", "type=bind,source=/xdg/git/credentials,target=/run/src/git-credentials,ro",
+				"-e", "GIT_CONFIG_COUNT=1",
+				"-e", "GIT_CONFIG_KEY_0=credential.helper",
+				"-e", "GIT_CONFIG_VALUE_0=store --file=/run/src/git-credentials",
+			),
+		},
+		"Docker Desktop": {
+			goos:   "darwin",
+			env:    map[string]string{"SSH_AUTH_SOCK": "/private/tmp/com.apple.launchd.XXX/Listeners"},
+			exists: existing(),
+			want: []string{
+				"--mount", "type=bind,source=/run/host-services/ssh-auth.sock,target=/run/src/ssh-agent.sock",
+				"-e", "SSH_AUTH_SOCK=/run/src/ssh-agent.sock",
+			},
+		},
+		"no agent": {
+			goos:    "linux",
+			env:     map[string]string{},
+			exists:  existing(),
+			wantErr: true,
+		},
+		"unsupported OS": {
+			goos:    "windows",
+			env:     map[string]string{"SSH_AUTH_SOCK": `\\.\pipe\openssh-ssh-agent`},
+			exists:  existing(),
+			wantErr: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			have, err := forwardSSHAgentOpts(tc.goos, env(tc.env), homeDir, tc.exists)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("unexpected nil error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, have); diff != "" {
+				t.Errorf("wrong options (-want +have):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package executor
+
+import (
+	"github.com/cockroachdb/errors"
+)
+
+// KeepWorkspaces determines which workspaces are retained after their tasks
+// have been executed, so that what the steps left behind can be inspected.
+type KeepWorkspaces string
+
+const (
+	// KeepWorkspacesNever removes all workspaces, which is the default.
+	KeepWorkspacesNever KeepWorkspaces = "never"
+	// KeepWorkspacesOnFailure retains the workspaces of failed tasks.
+	KeepWorkspacesOnFailure KeepWorkspaces = "on-failure"
+	// KeepWorkspacesAlways retains all workspaces.
+	KeepWorkspacesAlways KeepWorkspaces = "always"
+)
+
+// ParseKeepWorkspaces parses the value of the -keep-workspaces flag.
+func ParseKeepWorkspaces(s string) (KeepWorkspaces, error) {
+	switch k := KeepWorkspaces(s); k {
+	case KeepWorkspacesNever, KeepWorkspacesOnFailure, KeepWorkspacesAlways:
+		return k, nil
+	case "":
+		return KeepWorkspacesNever, nil
+	default:
+		return "", errors.Newf("invalid value %q: must be one of %q, %q or %q", s, KeepWorkspacesAlways, KeepWorkspacesOnFailure, KeepWorkspacesNever)
+	}
+}
+
+// keep returns true if the workspace of a task whose execution returned err
+// is to be retained.
+func (k KeepWorkspaces) keep(err error) bool {
+	switch k {
+	case KeepWorkspacesAlways:
+		return true
+	case KeepWorkspacesOnFailure:
+		return err != nil
+	default:
+		return false
+	}
+}
+
+// KeptWorkspace is a workspace that was retained after its task had been
+// executed.
+type KeptWorkspace struct {
+	Repository string
+	// Path is the path of the workspace in the repository. The root of the
+	// repository is the empty string.
+	Path string
+	// Location is where the workspace can be found, as returned by
+	// workspace.Workspace.Keep.
+	Location string
+	Failed   bool
+}
@@ -0,0 +1,43 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/errors"
+)
+
+func TestKeepWorkspaces(t *testing.T) {
+	failed := errors.New("step 1 failed")
+
+	for _, tc := range []struct {
+		value       string
+		want        KeepWorkspaces
+		keepSuccess bool
+		keepFailure bool
+	}{
+		{value: "", want: KeepWorkspacesNever},
+		{value: "never", want: KeepWorkspacesNever},
+		{value: "on-failure", want: KeepWorkspacesOnFailure, keepFailure: true},
+		{value: "always", want: KeepWorkspacesAlways, keepSuccess: true, keepFailure: true},
+	} {
+		t.Run(tc.value, func(t *testing.T) {
+			have, err := ParseKeepWorkspaces(tc.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if have != tc.want {
+				t.Fatalf("wrong value: have=%q want=%q", have, tc.want)
+			}
+			if keep := have.keep(nil); keep != tc.keepSuccess {
+				t.Errorf("wrong result for success: have=%t want=%t", keep, tc.keepSuccess)
+			}
+			if keep := have.keep(failed); keep != tc.keepFailure {
+				t.Errorf("wrong result for failure: have=%t want=%t", keep, tc.keepFailure)
+			}
+		})
+	}
+
+	if _, err := ParseKeepWorkspaces("sometimes"); err == nil {
+		t.Error("unexpected nil error for invalid value")
+	}
+}
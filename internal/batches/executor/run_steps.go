@@ -8,6 +8,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"sort"
 	"strings"
 	"time"
 
@@ -18,7 +19,9 @@ import (
 	"github.com/sourcegraph/sourcegraph/lib/batches/git"
 	"github.com/sourcegraph/sourcegraph/lib/batches/template"
 
+	"github.com/sourcegraph/src-cli/internal/batches/docker"
 	"github.com/sourcegraph/src-cli/internal/batches/log"
+	"github.com/sourcegraph/src-cli/internal/batches/reaper"
 	"github.com/sourcegraph/src-cli/internal/batches/util"
 	"github.com/sourcegraph/src-cli/internal/batches/workspace"
 
@@ -39,6 +42,17 @@ type stepExecutionResult struct {
 	// PreviousStepResult is the StepResult of the step before Step, if Step !=
 	// 0.
 	PreviousStepResult template.StepResult `json:"previousStepResult"`
+	// StepDiffs are the diffs of the steps up to and including Step, if the
+	// Task commits per step.
+	StepDiffs []stepDiff `json:"stepDiffs,omitempty"`
+}
+
+// stepDiff is the diff produced by a single step, without the changes of the
+// steps before it.
+type stepDiff struct {
+	// StepIndex is the index of the step in the steps of the batch spec.
+	StepIndex int    `json:"stepIndex"`
+	Diff      string `json:"diff"`
 }
 
 type executionResult struct {
@@ -51,6 +65,10 @@ type executionResult struct {
 	// Outputs are the outputs produced by all steps.
 	Outputs map[string]interface{} `json:"outputs"`
 
+	// StepDiffs are the diffs of the steps that changed files, if the Task
+	// commits per step. Applied in order, they add up to Diff.
+	StepDiffs []stepDiff `json:"stepDiffs,omitempty"`
+
 	// Path relative to the repository's root directory in which the steps
 	// have been executed.
 	// No leading slashes. Root directory is blank string.
@@ -65,11 +83,30 @@ type executionOpts struct {
 
 	tempDir string
 
+	sandbox SandboxProfile
+	tracker *reaper.Tracker
+	// stepLocks are held while serial steps run.
+	stepLocks stepLocks
+	// credentialOpts are given to `docker run` to forward credentials of the
+	// host into the containers of steps.
+	credentialOpts []string
+
+	keepWorkspaces KeepWorkspaces
+	// keptWorkspace is set by runSteps to the location of the workspace, if
+	// it was kept.
+	keptWorkspace string
+
 	logger log.TaskLogger
 
 	ui StepsExecutionUI
 }
 
+// remote returns whether the workspaces are on a Docker daemon on another
+// machine.
+func (opts *executionOpts) remote() bool {
+	return opts.wc.Type() == workspace.CreatorTypeRemote
+}
+
 func runSteps(ctx context.Context, opts *executionOpts) (result executionResult, stepResults []stepExecutionResult, err error) {
 	opts.ui.ArchiveDownloadStarted()
 	err = opts.task.Archive.Ensure(ctx)
@@ -84,7 +121,14 @@ func runSteps(ctx context.Context, opts *executionOpts) (result executionResult,
 	if err != nil {
 		return executionResult{}, nil, errors.Wrap(err, "creating workspace")
 	}
-	defer workspace.Close(ctx)
+	defer func() {
+		if opts.keepWorkspaces.keep(err) {
+			opts.keptWorkspace = workspace.Keep()
+			opts.logger.Logf("Keeping workspace at %s", opts.keptWorkspace)
+			return
+		}
+		workspace.Close(ctx)
+	}()
 	opts.ui.WorkspaceInitializationFinished()
 
 	var (
@@ -96,6 +140,9 @@ func runSteps(ctx context.Context, opts *executionOpts) (result executionResult,
 		}
 		previousStepResult template.StepResult
 		startStep          int
+		// checkpoint is the tree that the diff of the next step is taken
+		// against, if the Task commits per step.
+		checkpoint string
 	)
 
 	if opts.task.CachedResultFound {
@@ -114,11 +161,13 @@ func runSteps(ctx context.Context, opts *executionOpts) (result executionResult,
 
 			execResult.Diff = string(opts.task.CachedResult.Diff)
 			execResult.ChangedFiles = &changes
+			execResult.StepDiffs = opts.task.CachedResult.StepDiffs
 			stepResults = append(stepResults, opts.task.CachedResult)
 
 			return execResult, stepResults, nil
 		}
 
+		execResult.StepDiffs = opts.task.CachedResult.StepDiffs
 		opts.ui.SkippingStepsUpto(startStep)
 	}
 
@@ -157,6 +206,12 @@ func runSteps(ctx context.Context, opts *executionOpts) (result executionResult,
 			continue
 		}
 
+		if opts.task.CommitPerStep && checkpoint == "" {
+			if checkpoint, err = workspace.Checkpoint(ctx); err != nil {
+				return execResult, nil, errors.Wrap(err, "recording workspace state")
+			}
+		}
+
 		// We need to grab the digest for the exact image we're using.
 		img, err := opts.ensureImage(ctx, step.Container)
 		if err != nil {
@@ -166,7 +221,12 @@ func runSteps(ctx context.Context, opts *executionOpts) (result executionResult,
 		if err != nil {
 			return execResult, nil, err
 		}
+		release, err := opts.stepLocks.acquire(ctx, opts.task.specStepIndex(i))
+		if err != nil {
+			return execResult, nil, err
+		}
 		stdoutBuffer, stderrBuffer, err := executeSingleStep(ctx, opts, workspace, i, step, digest, &stepContext)
+		release()
 		defer func() {
 			if err != nil {
 				exitCode := -1
@@ -196,15 +256,32 @@ func runSteps(ctx context.Context, opts *executionOpts) (result executionResult,
 		}
 
 		// Get the current diff and store that away as the per-step result.
-		stepDiff, err := workspace.Diff(ctx)
+		cumulativeDiff, err := workspace.Diff(ctx)
 		if err != nil {
 			return execResult, nil, errors.Wrap(err, "getting diff produced by step")
 		}
+		if opts.task.CommitPerStep {
+			own, err := workspace.DiffSince(ctx, checkpoint)
+			if err != nil {
+				return execResult, nil, errors.Wrap(err, "getting diff produced by step")
+			}
+			if len(own) > 0 {
+				execResult.StepDiffs = append(execResult.StepDiffs, stepDiff{
+					StepIndex: opts.task.specStepIndex(i),
+					Diff:      string(own),
+				})
+			}
+			if checkpoint, err = workspace.Checkpoint(ctx); err != nil {
+				return execResult, nil, errors.Wrap(err, "recording workspace state")
+			}
+		}
+
 		stepResult := stepExecutionResult{
 			StepIndex:          i,
-			Diff:               stepDiff,
+			Diff:               cumulativeDiff,
 			Outputs:            make(map[string]interface{}),
 			PreviousStepResult: stepContext.PreviousStep,
+			StepDiffs:          append([]stepDiff(nil), execResult.StepDiffs...),
 		}
 		for k, v := range execResult.Outputs {
 			stepResult.Outputs[k] = v
@@ -245,7 +322,7 @@ func executeSingleStep(
 	// ----------
 	opts.ui.StepPreparingStart(i + 1)
 
-	cidFile, cleanup, err := createCidFile(ctx, opts.tempDir, util.SlugForRepo(opts.task.Repository.Name, opts.task.Repository.Rev()))
+	cidFile, cleanup, err := createCidFile(ctx, opts.tempDir, util.SlugForRepo(opts.task.Repository.Name, opts.task.Repository.Rev()), opts.tracker)
 	if err != nil {
 		opts.ui.StepPreparingFailed(i+1, err)
 		return bytes.Buffer{}, bytes.Buffer{}, err
@@ -276,7 +353,7 @@ func executeSingleStep(
 	defer cleanup()
 
 	// Resolve step.Env given the current environment.
-	stepEnv, err := step.Env.Resolve(os.Environ())
+	stepEnv, err := resolveStepEnv(step, opts.task.stepEnvironment(i), os.Environ())
 	if err != nil {
 		err = errors.Wrap(err, "resolving step environment")
 		opts.ui.StepPreparingFailed(i+1, err)
@@ -295,40 +372,50 @@ func executeSingleStep(
 	// ----------
 	// EXECUTION
 	// ----------
-	opts.ui.StepStarted(i+1, runScript, env)
+	opts.ui.StepStarted(i+1, runScript, opts.task.stepEnvironment(i).withoutSecrets(env))
 
 	workspaceOpts, err := workspace.DockerRunOpts(ctx, workDir)
 	if err != nil {
 		return bytes.Buffer{}, bytes.Buffer{}, errors.Wrap(err, "getting Docker options for workspace")
 	}
 
+	sandboxOpts, err := opts.sandbox.dockerRunOpts(workspaceOpts)
+	if err != nil {
+		return bytes.Buffer{}, bytes.Buffer{}, errors.Wrapf(err, "running image %q", step.Container)
+	}
+
 	// Where should we execute the steps.run script?
 	scriptWorkDir := workDir
 	if opts.task.Path != "" {
 		scriptWorkDir = workDir + "/" + opts.task.Path
 	}
 
-	args := append([]string{
-		"run",
-		"--rm",
-		"--init",
-		"--cidfile", cidFile,
-		"--workdir", scriptWorkDir,
-		"--mount", fmt.Sprintf("type=bind,source=%s,target=%s,ro", runScriptFile, containerTemp),
-	}, workspaceOpts...)
-
+	files := make(map[string]string, len(filesToMount))
 	for target, source := range filesToMount {
-		args = append(args, "--mount", fmt.Sprintf("type=bind,source=%s,target=%s,ro", source.Name(), target))
-	}
-
-	for k, v := range env {
-		args = append(args, "-e", k+"="+v)
+		files[target] = source.Name()
+	}
+
+	runOpts := stepRunOpts{
+		cidFile:        cidFile,
+		workDir:        scriptWorkDir,
+		scriptFile:     runScriptFile,
+		scriptTarget:   containerTemp,
+		workspaceOpts:  workspaceOpts,
+		sandboxOpts:    sandboxOpts,
+		credentialOpts: opts.credentialOpts,
+		files:          files,
+		env:            env,
+		shell:          shell,
+		image:          imageDigest,
+		remote:         opts.remote(),
+	}
+
+	cmd := exec.CommandContext(ctx, "docker", runOpts.args()...)
+	if runOpts.remote {
+		if cmd, err = createRemoteStepContainer(ctx, runOpts); err != nil {
+			return bytes.Buffer{}, bytes.Buffer{}, errors.Wrapf(err, "creating container of image %q", step.Container)
+		}
 	}
-
-	args = append(args, "--entrypoint", shell)
-
-	cmd := exec.CommandContext(ctx, "docker", args...)
-	cmd.Args = append(cmd.Args, "--", imageDigest, containerTemp)
 	if dir := workspace.WorkDir(); dir != nil {
 		cmd.Dir = *dir
 	}
@@ -393,6 +480,120 @@ func executeSingleStep(
 	return stdoutBuffer, stderrBuffer, nil
 }
 
+// stepRunOpts are the parts of the `docker run` invocation that executes a
+// step.
+type stepRunOpts struct {
+	// cidFile is the file Docker writes the container ID to. It's omitted if
+	// it's empty.
+	cidFile string
+	// workDir is the directory in the container the script is run in.
+	workDir string
+	// scriptFile is the file on the host containing the script, which is
+	// mounted at scriptTarget in the container.
+	scriptFile   string
+	scriptTarget string
+
+	workspaceOpts  []string
+	sandboxOpts    []string
+	credentialOpts []string
+
+	// files are the files on the host mounted into the container, by their
+	// path in the container.
+	files map[string]string
+	env   map[string]string
+	// hostEnv are the names of environment variables that are passed from
+	// the environment of docker instead of being set to a value.
+	hostEnv []string
+
+	shell string
+	image string
+
+	// remote is set if the Docker daemon is on another machine, which the
+	// script and the files can't be mounted from. The container is then
+	// only created by args, and they're copied into it before it's started.
+	remote bool
+}
+
+// args returns the arguments to docker that execute the step. Mounted files
+// and environment variables are sorted, so that the arguments are stable.
+func (o stepRunOpts) args() []string {
+	args := []string{"run", "--rm", "--init"}
+	if o.remote {
+		args[0] = "create"
+	}
+	if o.cidFile != "" {
+		args = append(args, "--cidfile", o.cidFile)
+	}
+	args = append(args, "--workdir", o.workDir)
+	if !o.remote {
+		args = append(args, "--mount", fmt.Sprintf("type=bind,source=%s,target=%s,ro", o.scriptFile, o.scriptTarget))
+	}
+	args = append(args, o.workspaceOpts...)
+	args = append(args, o.sandboxOpts...)
+	args = append(args, o.credentialOpts...)
+
+	if !o.remote {
+		for _, target := range sortedKeys(o.files) {
+			args = append(args, "--mount", fmt.Sprintf("type=bind,source=%s,target=%s,ro", o.files[target], target))
+		}
+	}
+
+	for _, k := range sortedKeys(o.env) {
+		args = append(args, "-e", k+"="+o.env[k])
+	}
+	for _, k := range o.hostEnv {
+		args = append(args, "-e", k)
+	}
+
+	return append(args, "--entrypoint", o.shell, "--", o.image, o.scriptTarget)
+}
+
+// copiedFiles returns the files on the host that are copied into the
+// container of a remote step, by their path in the container.
+func (o stepRunOpts) copiedFiles() map[string]string {
+	files := map[string]string{o.scriptTarget: o.scriptFile}
+	for target, source := range o.files {
+		files[target] = source
+	}
+	return files
+}
+
+// createRemoteStepContainer creates the container of a step on a remote
+// Docker daemon and streams the script and files of the step into it, like
+// a build context. It returns the command that starts the container and
+// attaches to its output.
+func createRemoteStepContainer(ctx context.Context, o stepRunOpts) (*exec.Cmd, error) {
+	var stderr bytes.Buffer
+	create := exec.CommandContext(ctx, "docker", o.args()...)
+	create.Stderr = &stderr
+	out, err := create.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "docker create:\n%s", stderr.String())
+	}
+	container := strings.TrimSpace(string(out))
+
+	archive, err := docker.TarFiles(o.copiedFiles())
+	if err != nil {
+		return nil, errors.Wrap(err, "archiving step files")
+	}
+	cp := exec.CommandContext(ctx, "docker", "cp", "-", container+":/")
+	cp.Stdin = archive
+	if out, err := cp.CombinedOutput(); err != nil {
+		return nil, errors.Wrapf(err, "copying step files into container:\n%s", string(out))
+	}
+
+	return exec.CommandContext(ctx, "docker", "start", "--attach", container), nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func setOutputs(stepOutputs batcheslib.Outputs, global map[string]interface{}, stepCtx *template.StepContext) error {
 	for name, output := range stepOutputs {
 		var value bytes.Buffer
@@ -554,8 +755,8 @@ func createRunScriptFile(ctx context.Context, tempDir string, stepRun string, st
 // createCidFile creates a temporary file that will contain the container ID
 // when executing steps.
 // It returns the location of the file and a function that cleans up the
-// file.
-func createCidFile(ctx context.Context, tempDir string, repoSlug string) (string, func(), error) {
+// file. The file is recorded by tracker until the container is removed.
+func createCidFile(ctx context.Context, tempDir string, repoSlug string, tracker *reaper.Tracker) (string, func(), error) {
 	// Find a location that we can use for a cidfile, which will contain the
 	// container ID that is used below. We can then use this to remove the
 	// container on a successful run, rather than leaving it dangling.
@@ -572,16 +773,25 @@ func createCidFile(ctx context.Context, tempDir string, repoSlug string) (string
 		return "", nil, errors.Wrap(err, "removing cidfile")
 	}
 
+	tracker.TrackCIDFile(cidFile.Name())
+
 	// Since we went to all that effort, we can now defer a function that
 	// uses the cidfile to clean up after this function is done.
 	cleanup := func() {
 		cid, err := os.ReadFile(cidFile.Name())
-		_ = os.Remove(cidFile.Name())
 		if err == nil {
 			ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 			defer cancel()
 			_ = exec.CommandContext(ctx, "docker", "rm", "-f", "--", string(cid)).Run()
 		}
+
+		// If the execution was interrupted, the container couldn't be
+		// removed with the cancelled context, so it's left to the tracker.
+		if ctx.Err() != nil {
+			return
+		}
+		_ = os.Remove(cidFile.Name())
+		tracker.UntrackCIDFile(cidFile.Name())
 	}
 
 	return cidFile.Name(), cleanup, nil
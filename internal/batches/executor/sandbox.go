@@ -0,0 +1,85 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// SandboxProfile determines how strictly the containers in which steps are
+// executed are isolated from the host.
+type SandboxProfile string
+
+const (
+	// SandboxOff lifts the seccomp and AppArmor confinement Docker applies
+	// by default, for steps that need system calls it blocks.
+	SandboxOff SandboxProfile = "off"
+	// SandboxDefault runs containers with Docker's default settings.
+	SandboxDefault SandboxProfile = "default"
+	// SandboxStrict runs containers as a non-root user, with a read-only root
+	// filesystem except for the workspace and /tmp, without capabilities and
+	// without the ability to gain privileges.
+	SandboxStrict SandboxProfile = "strict"
+)
+
+// ParseSandboxProfile parses the value of the -sandbox flag.
+func ParseSandboxProfile(s string) (SandboxProfile, error) {
+	switch p := SandboxProfile(s); p {
+	case SandboxOff, SandboxDefault, SandboxStrict:
+		return p, nil
+	case "":
+		return SandboxDefault, nil
+	default:
+		return "", errors.Newf("invalid sandbox profile %q: must be one of %q, %q or %q", s, SandboxStrict, SandboxDefault, SandboxOff)
+	}
+}
+
+// dockerRunOpts returns the options given to `docker run` for the profile.
+// workspaceOpts are the options of the workspace the step is executed in, so
+// that strict can check the user the container runs as.
+func (p SandboxProfile) dockerRunOpts(workspaceOpts []string) ([]string, error) {
+	switch p {
+	case SandboxOff:
+		return []string{
+			"--security-opt", "seccomp=unconfined",
+			"--security-opt", "apparmor=unconfined",
+		}, nil
+
+	case SandboxStrict:
+		user := workspaceUser(workspaceOpts)
+		opts := []string{
+			"--read-only",
+			"--tmpfs", "/tmp:rw,exec",
+			"--security-opt", "no-new-privileges",
+			"--cap-drop", "ALL",
+		}
+		if user == "" {
+			// Bind mounted workspaces are owned by the user running src, so
+			// the steps run as the same user to be able to write to them.
+			user = fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid())
+			opts = append(opts, "--user", user)
+		}
+		if uid, err := strconv.Atoi(strings.SplitN(user, ":", 2)[0]); err == nil && uid == 0 {
+			return nil, errors.New("the strict sandbox profile doesn't allow steps to run as root: use an image that runs as a non-root user, or run src as a non-root user")
+		}
+		return opts, nil
+
+	default:
+		return nil, nil
+	}
+}
+
+// workspaceUser returns the value of the --user option in the given `docker
+// run` options, or an empty string if there is none.
+func workspaceUser(opts []string) string {
+	var user string
+	for i, opt := range opts {
+		if opt == "--user" && i+1 < len(opts) {
+			user = opts[i+1]
+		}
+	}
+	return user
+}
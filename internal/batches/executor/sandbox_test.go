@@ -0,0 +1,79 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSandboxProfile_DockerRunOpts(t *testing.T) {
+	strict := []string{
+		"--read-only",
+		"--tmpfs", "/tmp:rw,exec",
+		"--security-opt", "no-new-privileges",
+		"--cap-drop", "ALL",
+	}
+
+	t.Run("default", func(t *testing.T) {
+		have, err := SandboxDefault.dockerRunOpts(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(have) != 0 {
+			t.Fatalf("unexpected options: %v", have)
+		}
+	})
+
+	t.Run("strict volume workspace", func(t *testing.T) {
+		have, err := SandboxStrict.dockerRunOpts([]string{"--user", "1000:1000", "--mount", "type=volume,source=v,target=/work"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(strict, have); diff != "" {
+			t.Fatalf("wrong options (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("strict volume workspace as root", func(t *testing.T) {
+		if _, err := SandboxStrict.dockerRunOpts([]string{"--user", "0:0"}); err == nil {
+			t.Fatal("unexpected nil error")
+		}
+	})
+
+	t.Run("strict bind workspace", func(t *testing.T) {
+		if os.Getuid() == 0 {
+			t.Skip("running as root")
+		}
+		have, err := SandboxStrict.dockerRunOpts([]string{"--mount", "type=bind,source=/tmp/ws,target=/work"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := append(strict, "--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
+		if diff := cmp.Diff(want, have); diff != "" {
+			t.Fatalf("wrong options (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestParseSandboxProfile(t *testing.T) {
+	for in, want := range map[string]SandboxProfile{
+		"":        SandboxDefault,
+		"default": SandboxDefault,
+		"strict":  SandboxStrict,
+		"off":     SandboxOff,
+	} {
+		have, err := ParseSandboxProfile(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have != want {
+			t.Errorf("wrong profile for %q: want=%q, have=%q", in, want, have)
+		}
+	}
+
+	if _, err := ParseSandboxProfile("paranoid"); err == nil {
+		t.Fatal("unexpected nil error")
+	}
+}
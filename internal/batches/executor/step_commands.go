@@ -0,0 +1,163 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/sourcegraph/lib/batches/template"
+
+	"github.com/sourcegraph/src-cli/internal/batches/util"
+)
+
+// The placeholders in the arguments of a StepCommand for the paths on the
+// host that only exist when the step is executed.
+const (
+	// StepCommandWorkspace is the directory containing the repository, which
+	// is mounted into the container as the workspace.
+	StepCommandWorkspace = "${WORKSPACE}"
+	// StepCommandDir is the directory that StepCommand.ScriptFile and the
+	// files of StepCommand.Files are written to.
+	StepCommandDir = "${PWD}"
+)
+
+// stepCommandShell is the shell that StepCommands run the scripts with. When
+// the steps are executed, /bin/bash is used instead if the image contains it,
+// which takes running a container to find out.
+const stepCommandShell = "/bin/sh"
+
+// StepCommand is the `docker run` invocation that executes a step of a Task,
+// so that the step can be reproduced and debugged outside of src.
+type StepCommand struct {
+	// Step is the number of the step in the batch spec, starting at 1.
+	Step      int
+	Container string
+	// Skipped is set if the `if:` condition of the step is false, in which
+	// case there is no command.
+	Skipped bool
+
+	// Script is the rendered `run:` script, which is to be written to
+	// ScriptFile, relative to StepCommandDir.
+	Script     string
+	ScriptFile string
+	// Files are the rendered `files:` of the step, which are to be written
+	// to the given paths, relative to StepCommandDir.
+	Files []StepCommandFile
+
+	// Args are the arguments to docker.
+	Args []string
+	// FileSecrets are the names of the secrets read from `fromFile:` dotenv
+	// files, which are passed by name like the variables from the environment
+	// of src, but have to be set to the values in the files first.
+	FileSecrets []string
+}
+
+// StepCommandFile is a file that is mounted into the container of a step.
+type StepCommandFile struct {
+	Name    string
+	Content string
+}
+
+// StepCommands returns the `docker run` invocations that execute the steps of
+// the task, without executing them. The commands differ from the actual ones
+// in that the workspace and the files are given by the placeholders
+// StepCommandWorkspace and StepCommandDir, and the image by its name instead
+// of its digest. Templates are rendered as for the first step: the outputs and
+// results of previous steps are empty.
+//
+// Environment variables that are set to the value they have in the
+// environment of src, and secrets, are passed by name, so that their values
+// aren't printed. Secrets read from dotenv files are listed in FileSecrets.
+func StepCommands(task *Task, sandbox SandboxProfile) ([]StepCommand, error) {
+	stepContext := template.StepContext{
+		BatchChange: *task.BatchChangeAttributes,
+		Repository:  util.NewTemplatingRepo(task.Repository.Name, task.Repository.FileMatches),
+		Outputs:     map[string]interface{}{},
+		Steps:       template.StepsContext{Path: task.Path},
+	}
+
+	workspaceOpts := []string{"--mount", fmt.Sprintf("type=bind,source=%s,target=%s", StepCommandWorkspace, workDir)}
+	sandboxOpts, err := sandbox.dockerRunOpts(workspaceOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	scriptWorkDir := workDir
+	if task.Path != "" {
+		scriptWorkDir = workDir + "/" + task.Path
+	}
+
+	commands := make([]StepCommand, 0, len(task.Steps))
+	for i, step := range task.Steps {
+		n := task.specStepIndex(i) + 1
+		command := StepCommand{Step: n, Container: step.Container}
+
+		cond, err := template.EvalStepCondition(step.IfCondition(), &stepContext)
+		if err != nil {
+			return nil, errors.Wrapf(err, "step %d: evaluating step condition", n)
+		}
+		if !cond {
+			command.Skipped = true
+			commands = append(commands, command)
+			continue
+		}
+
+		var script bytes.Buffer
+		if err := template.RenderStepTemplate("step-run", step.Run, &script, &stepContext); err != nil {
+			return nil, errors.Wrapf(err, "step %d: parsing step run", n)
+		}
+		command.Script = script.String()
+		command.ScriptFile = fmt.Sprintf("step-%d.sh", n)
+
+		rendered, err := template.RenderStepMap(step.Files, &stepContext)
+		if err != nil {
+			return nil, errors.Wrapf(err, "step %d: parsing step files", n)
+		}
+		files := make(map[string]string, len(rendered))
+		for j, target := range sortedKeys(rendered) {
+			name := fmt.Sprintf("step-%d-file-%d", n, j+1)
+			command.Files = append(command.Files, StepCommandFile{Name: name, Content: rendered[target]})
+			files[target] = StepCommandDir + "/" + name
+		}
+
+		extra := task.stepEnvironment(i)
+		stepEnv, err := resolveStepEnv(step, extra, os.Environ())
+		if err != nil {
+			return nil, errors.Wrapf(err, "step %d: resolving step environment", n)
+		}
+		env, err := template.RenderStepMap(stepEnv, &stepContext)
+		if err != nil {
+			return nil, errors.Wrapf(err, "step %d: parsing step environment", n)
+		}
+		var hostEnv []string
+		for _, k := range sortedKeys(env) {
+			value, ok := os.LookupEnv(k)
+			fromHost := ok && value == env[k]
+			if !fromHost && !extra.Secrets[k] {
+				continue
+			}
+			if _, fromFile := extra.Files[k]; fromFile && !fromHost {
+				command.FileSecrets = append(command.FileSecrets, k)
+			}
+			hostEnv = append(hostEnv, k)
+			delete(env, k)
+		}
+
+		command.Args = stepRunOpts{
+			workDir:       scriptWorkDir,
+			scriptFile:    StepCommandDir + "/" + command.ScriptFile,
+			scriptTarget:  fmt.Sprintf("/tmp/step-%d.sh", n),
+			workspaceOpts: workspaceOpts,
+			sandboxOpts:   sandboxOpts,
+			files:         files,
+			env:           env,
+			hostEnv:       hostEnv,
+			shell:         stepCommandShell,
+			image:         step.Container,
+		}.args()
+		commands = append(commands, command)
+	}
+	return commands, nil
+}
@@ -0,0 +1,179 @@
+package executor
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+	"github.com/sourcegraph/sourcegraph/lib/batches/template"
+)
+
+func TestStepCommands(t *testing.T) {
+	task := &Task{
+		Repository: testRepo1,
+		Path:       "sub",
+		Steps: []batcheslib.Step{
+			{
+				Run:       `echo ${{ repository.name }}`,
+				Container: "alpine:3",
+				Files: map[string]string{
+					"/tmp/b.txt": "b",
+					"/tmp/a.txt": "${{ repository.name }}",
+				},
+			},
+			{Run: "echo skipped", Container: "alpine:3", If: "false"},
+		},
+		StepIndexes:           []int{0, 2},
+		BatchChangeAttributes: &template.BatchChangeAttributes{},
+	}
+
+	have, err := StepCommands(task, SandboxOff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []StepCommand{
+		{
+			Step:       1,
+			Container:  "alpine:3",
+			Script:     "echo " + testRepo1.Name,
+			ScriptFile: "step-1.sh",
+			Files: []StepCommandFile{
+				{Name: "step-1-file-1", Content: testRepo1.Name},
+				{Name: "step-1-file-2", Content: "b"},
+			},
+			Args: []string{
+				"run", "--rm", "--init",
+				"--workdir", "/work/sub",
+				"--mount", "type=bind,source=${PWD}/step-1.sh,target=/tmp/step-1.sh,ro",
+				"--mount", "type=bind,source=${WORKSPACE},target=/work",
+				"--security-opt", "seccomp=unconfined",
+				"--security-opt", "apparmor=unconfined",
+				"--mount", "type=bind,source=${PWD}/step-1-file-1,target=/tmp/a.txt,ro",
+				"--mount", "type=bind,source=${PWD}/step-1-file-2,target=/tmp/b.txt,ro",
+				"--entrypoint", "/bin/sh",
+				"--", "alpine:3", "/tmp/step-1.sh",
+			},
+		},
+		{Step: 3, Container: "alpine:3", Skipped: true},
+	}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Errorf("wrong commands (-want +have):\n%s", diff)
+	}
+}
+
+func TestStepRunOptsArgs(t *testing.T) {
+	opts := stepRunOpts{
+		cidFile:       "/tmp/cid",
+		workDir:       "/work",
+		scriptFile:    "/tmp/script",
+		scriptTarget:  "/tmp/tmp.abc",
+		workspaceOpts: []string{"--mount", "type=bind,source=/tmp/ws,target=/work"},
+		env:           map[string]string{"B": "2", "A": "1"},
+		hostEnv:       []string{"TOKEN"},
+		shell:         "/bin/bash",
+		image:         "sha256:abc",
+	}
+
+	want := []string{
+		"run", "--rm", "--init",
+		"--cidfile", "/tmp/cid",
+		"--workdir", "/work",
+		"--mount", "type=bind,source=/tmp/script,target=/tmp/tmp.abc,ro",
+		"--mount", "type=bind,source=/tmp/ws,target=/work",
+		"-e", "A=1",
+		"-e", "B=2",
+		"-e", "TOKEN",
+		"--entrypoint", "/bin/bash",
+		"--", "sha256:abc", "/tmp/tmp.abc",
+	}
+	if diff := cmp.Diff(want, opts.args()); diff != "" {
+		t.Errorf("wrong arguments (-want +have):\n%s", diff)
+	}
+}
+
+func TestStepRunOptsArgs_Remote(t *testing.T) {
+	opts := stepRunOpts{
+		cidFile:       "/tmp/cid",
+		workDir:       "/work",
+		scriptFile:    "/tmp/script",
+		scriptTarget:  "/tmp/tmp.abc",
+		workspaceOpts: []string{"--user", "0:0", "--mount", "type=volume,source=VOLUME,target=/work"},
+		files:         map[string]string{"/etc/config.yaml": "/tmp/file"},
+		env:           map[string]string{"A": "1"},
+		shell:         "/bin/sh",
+		image:         "sha256:abc",
+		remote:        true,
+	}
+
+	// Nothing on the host is mounted into a container on a remote Docker
+	// host: the script and files are copied into it instead.
+	want := []string{
+		"create", "--rm", "--init",
+		"--cidfile", "/tmp/cid",
+		"--workdir", "/work",
+		"--user", "0:0",
+		"--mount", "type=volume,source=VOLUME,target=/work",
+		"-e", "A=1",
+		"--entrypoint", "/bin/sh",
+		"--", "sha256:abc", "/tmp/tmp.abc",
+	}
+	if diff := cmp.Diff(want, opts.args()); diff != "" {
+		t.Errorf("wrong arguments (-want +have):\n%s", diff)
+	}
+
+	wantFiles := map[string]string{
+		"/tmp/tmp.abc":     "/tmp/script",
+		"/etc/config.yaml": "/tmp/file",
+	}
+	if diff := cmp.Diff(wantFiles, opts.copiedFiles()); diff != "" {
+		t.Errorf("wrong copied files (-want +have):\n%s", diff)
+	}
+}
+
+func TestStepCommands_FileSecrets(t *testing.T) {
+	t.Setenv("SRC_TEST_HOST_SECRET", "host")
+
+	var step batcheslib.Step
+	if err := json.Unmarshal([]byte(`{"run": "echo", "container": "alpine:3", "env": ["SRC_TEST_HOST_SECRET"]}`), &step); err != nil {
+		t.Fatal(err)
+	}
+	task := &Task{
+		Repository: testRepo1,
+		Steps:      []batcheslib.Step{step},
+		Environments: map[int]StepEnvironment{
+			0: {
+				Files: map[string]string{
+					"SRC_TEST_FILE_SECRET": "s3cr3t",
+					"SRC_TEST_FILE_VALUE":  "value",
+				},
+				Secrets: map[string]bool{"SRC_TEST_HOST_SECRET": true, "SRC_TEST_FILE_SECRET": true},
+			},
+		},
+		BatchChangeAttributes: &template.BatchChangeAttributes{},
+	}
+
+	have, err := StepCommands(task, SandboxOff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(have) != 1 {
+		t.Fatalf("wrong number of commands: %d", len(have))
+	}
+	if diff := cmp.Diff([]string{"SRC_TEST_FILE_SECRET"}, have[0].FileSecrets); diff != "" {
+		t.Errorf("wrong file secrets (-want +have):\n%s", diff)
+	}
+
+	var env []string
+	for i, arg := range have[0].Args {
+		if arg == "-e" {
+			env = append(env, have[0].Args[i+1])
+		}
+	}
+	want := []string{"SRC_TEST_FILE_VALUE=value", "SRC_TEST_FILE_SECRET", "SRC_TEST_HOST_SECRET"}
+	if diff := cmp.Diff(want, env); diff != "" {
+		t.Errorf("wrong env (-want +have):\n%s", diff)
+	}
+}
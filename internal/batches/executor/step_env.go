@@ -0,0 +1,56 @@
+package executor
+
+import (
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+)
+
+// StepEnvironment is the part of the environment of a step that src resolves
+// itself instead of the batch spec parser.
+type StepEnvironment struct {
+	// Files are the variables read from the dotenv files given by fromFile.
+	Files map[string]string
+	// Secrets are the names of the variables whose values are secrets, which
+	// are left out of cache keys and hidden in the output of src.
+	Secrets map[string]bool
+}
+
+// secretValue replaces the values of secrets in cache keys and output.
+const secretValue = "<secret>"
+
+// resolveStepEnv returns the environment of the step: its env resolved
+// against global, on top of the variables read from files.
+func resolveStepEnv(step batcheslib.Step, extra StepEnvironment, global []string) (map[string]string, error) {
+	env, err := step.Env.Resolve(global)
+	if err != nil {
+		return nil, err
+	}
+	if len(extra.Files) == 0 {
+		return env, nil
+	}
+
+	merged := make(map[string]string, len(extra.Files)+len(env))
+	for k, v := range extra.Files {
+		merged[k] = v
+	}
+	for k, v := range env {
+		merged[k] = v
+	}
+	return merged, nil
+}
+
+// withoutSecrets returns a copy of env in which the values of secrets are
+// replaced, or env itself if there are no secrets.
+func (e StepEnvironment) withoutSecrets(env map[string]string) map[string]string {
+	if len(e.Secrets) == 0 {
+		return env
+	}
+
+	masked := make(map[string]string, len(env))
+	for k, v := range env {
+		if e.Secrets[k] {
+			v = secretValue
+		}
+		masked[k] = v
+	}
+	return masked
+}
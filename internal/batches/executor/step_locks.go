@@ -0,0 +1,34 @@
+package executor
+
+import "context"
+
+// stepLocks make sure that each serial step runs in only one workspace at a
+// time, across all the tasks of an executor. They're keyed by the index of the
+// step in the steps of the batch spec.
+type stepLocks map[int]chan struct{}
+
+func newStepLocks(serialSteps map[int]bool) stepLocks {
+	locks := stepLocks{}
+	for i, serial := range serialSteps {
+		if serial {
+			locks[i] = make(chan struct{}, 1)
+		}
+	}
+	return locks
+}
+
+// acquire waits until the step with the given index in the steps of the batch
+// spec can run, if it's serial. The returned function must be called once the
+// step finished.
+func (l stepLocks) acquire(ctx context.Context, specIndex int) (release func(), err error) {
+	lock, ok := l[specIndex]
+	if !ok {
+		return func() {}, nil
+	}
+	select {
+	case lock <- struct{}{}:
+		return func() { <-lock }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
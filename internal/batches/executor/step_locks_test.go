@@ -0,0 +1,56 @@
+package executor
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStepLocks(t *testing.T) {
+	locks := newStepLocks(map[int]bool{1: true, 2: false})
+
+	// Serial steps run one at a time, other steps in parallel.
+	for step, wantMax := range map[int]int32{0: 4, 1: 1, 2: 4} {
+		var running, max int32
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				release, err := locks.acquire(context.Background(), step)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer release()
+
+				n := atomic.AddInt32(&running, 1)
+				for {
+					m := atomic.LoadInt32(&max)
+					if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+						break
+					}
+				}
+				time.Sleep(20 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+			}()
+		}
+		wg.Wait()
+		if max != wantMax {
+			t.Errorf("step %d: want at most %d running at a time, have %d", step, wantMax, max)
+		}
+	}
+
+	release, err := locks.acquire(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := locks.acquire(ctx, 1); err == nil {
+		t.Error("want error when the context is canceled while waiting")
+	}
+}
@@ -21,6 +21,25 @@ type Task struct {
 	OnlyFetchWorkspace bool
 
 	Steps []batcheslib.Step
+	// StepIndexes are the indexes of Steps in the steps of the batch spec,
+	// which differ if some steps are skipped in this repository. nil means
+	// that they're the same.
+	StepIndexes []int `json:"-"`
+	// Environments are the parts of the environments of the steps that src
+	// resolves itself, by index in the steps of the batch spec. They're
+	// included in cache keys through the resolved environments.
+	Environments map[int]StepEnvironment `json:"-"`
+	// CacheKeyPaths are the glob patterns of the files that the cache keys of
+	// the steps are based on instead of the revision of the repository, by
+	// index in the steps of the batch spec. cacheKeyContents are the digests
+	// of those files, by index in Steps, once they're computed by the
+	// executor.
+	CacheKeyPaths    map[int][]string `json:"-"`
+	cacheKeyContents []string
+
+	// CommitPerStep is true if the diff of each step is recorded on its own,
+	// so that every step that changes files becomes a separate commit.
+	CommitPerStep bool `json:"commitPerStep,omitempty"`
 
 	// TODO(mrnugget): this should just be a single BatchSpec field instead, if
 	// we can make it work with caching
@@ -41,6 +60,21 @@ func (t *Task) ArchivePathToFetch() string {
 	return ""
 }
 
+// specStepIndex returns the index in the steps of the batch spec of the step
+// with the given index in Steps.
+func (t *Task) specStepIndex(i int) int {
+	if t.StepIndexes == nil {
+		return i
+	}
+	return t.StepIndexes[i]
+}
+
+// stepEnvironment returns the StepEnvironment of the step with the given index
+// in Steps.
+func (t *Task) stepEnvironment(i int) StepEnvironment {
+	return t.Environments[t.specStepIndex(i)]
+}
+
 func (t *Task) cacheKey() TaskCacheKey {
 	return TaskCacheKey{t}
 }
@@ -0,0 +1,215 @@
+// Package history records the runs of src batch apply on this machine, so
+// that it can be looked up later which batch spec was applied where and when,
+// and what came of it.
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Result is the outcome of a run.
+type Result string
+
+const (
+	// ResultApplied is a run that applied the batch spec.
+	ResultApplied Result = "applied"
+	// ResultNotApplied is a run that succeeded without applying the batch
+	// spec, because the changes weren't confirmed.
+	ResultNotApplied Result = "not-applied"
+	// ResultFailed is a run that failed.
+	ResultFailed Result = "failed"
+)
+
+// Entry is a run of src batch apply.
+type Entry struct {
+	// ID identifies the entry in the history. It's set by Record.
+	ID       string        `json:"id"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+	// Instance is the endpoint of the Sourcegraph instance.
+	Instance   string `json:"instance"`
+	SrcVersion string `json:"srcVersion"`
+
+	// Spec is the raw batch spec. It's stored separately from the entries,
+	// by its hash, so that the same spec is only stored once.
+	Spec     string `json:"-"`
+	SpecHash string `json:"specHash,omitempty"`
+	SpecFile string `json:"specFile,omitempty"`
+	Name     string `json:"name,omitempty"`
+	// Namespace is the namespace as given with -namespace. It's empty for
+	// the namespace of the user.
+	Namespace string `json:"namespace,omitempty"`
+
+	Repositories   int `json:"repositories"`
+	Workspaces     int `json:"workspaces"`
+	ChangesetSpecs int `json:"changesetSpecs"`
+
+	BatchChangeURL string `json:"batchChangeURL,omitempty"`
+	Result         Result `json:"result"`
+	Error          string `json:"error,omitempty"`
+}
+
+// ErrNotFound is returned by Find if no entry matches.
+var ErrNotFound = errors.New("no matching entry in the batch apply history")
+
+// History is the history of runs in a directory. The entries are appended to
+// a JSON lines file, and the batch specs are stored next to it.
+type History struct {
+	dir string
+}
+
+// Open returns the history in dir. The directory is created once the first
+// entry is recorded.
+func Open(dir string) *History {
+	return &History{dir: dir}
+}
+
+func (h *History) entriesPath() string {
+	return filepath.Join(h.dir, "history.jsonl")
+}
+
+func (h *History) specPath(hash string) string {
+	return filepath.Join(h.dir, "specs", hash+".yaml")
+}
+
+// Record appends the entry to the history, setting its ID and the hash of its
+// spec.
+func (h *History) Record(e *Entry) error {
+	if err := os.MkdirAll(filepath.Join(h.dir, "specs"), 0700); err != nil {
+		return errors.Wrap(err, "creating history directory")
+	}
+
+	if e.Spec != "" {
+		sum := sha256.Sum256([]byte(e.Spec))
+		e.SpecHash = hex.EncodeToString(sum[:])
+		if err := writeFileOnce(h.specPath(e.SpecHash), []byte(e.Spec)); err != nil {
+			return errors.Wrap(err, "storing batch spec")
+		}
+	}
+	e.ID = newID(e)
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(h.entriesPath(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return errors.Wrap(err, "opening history")
+	}
+	// A single write of the whole line keeps the entries of concurrent runs
+	// from interleaving.
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return errors.Wrap(err, "writing history")
+	}
+	return f.Close()
+}
+
+// newID returns an ID for the entry, derived from when it started and its
+// spec, which is short enough to be typed.
+func newID(e *Entry) string {
+	sum := sha256.Sum256([]byte(e.Started.UTC().Format(time.RFC3339Nano) + e.SpecHash + e.Instance))
+	return hex.EncodeToString(sum[:])[:10]
+}
+
+// writeFileOnce writes the file, unless it already exists. The file is
+// written to a temporary file first, so that it's never seen incomplete.
+func writeFileOnce(path string, data []byte) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".spec-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// List returns the entries of the history, oldest first. An empty or missing
+// history has no entries.
+func (h *History) List() ([]*Entry, error) {
+	data, err := os.ReadFile(h.entriesPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "reading history")
+	}
+
+	var entries []*Entry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, errors.Wrapf(err, "reading %s:%d", h.entriesPath(), line)
+		}
+		entries = append(entries, &e)
+	}
+	return entries, scanner.Err()
+}
+
+// Find returns the entry whose ID starts with ref, or the most recent entry
+// if ref is "last". It returns ErrNotFound if there is no such entry, and an
+// error if the prefix is ambiguous.
+func (h *History) Find(ref string) (*Entry, error) {
+	entries, err := h.List()
+	if err != nil {
+		return nil, err
+	}
+	if ref == "last" {
+		if len(entries) == 0 {
+			return nil, ErrNotFound
+		}
+		return entries[len(entries)-1], nil
+	}
+
+	var found *Entry
+	for _, e := range entries {
+		if ref == "" || !strings.HasPrefix(e.ID, ref) {
+			continue
+		}
+		if found != nil && found.ID != e.ID {
+			return nil, errors.Newf("%q matches more than one entry of the batch apply history", ref)
+		}
+		found = e
+	}
+	if found == nil {
+		return nil, ErrNotFound
+	}
+	return found, nil
+}
+
+// Spec returns the batch spec of the entry.
+func (h *History) Spec(e *Entry) (string, error) {
+	if e.SpecHash == "" {
+		return "", errors.New("the batch spec wasn't recorded, since it couldn't be read")
+	}
+	data, err := os.ReadFile(h.specPath(e.SpecHash))
+	if err != nil {
+		return "", errors.Wrap(err, "reading batch spec")
+	}
+	return string(data), nil
+}
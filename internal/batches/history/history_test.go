@@ -0,0 +1,123 @@
+package history
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHistory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "history")
+	h := Open(dir)
+
+	entries, err := h.List()
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("want empty history, have %v, %v", entries, err)
+	}
+	if _, err := h.Find("last"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("want ErrNotFound, have %v", err)
+	}
+
+	started := time.Date(2021, 10, 12, 9, 30, 0, 0, time.UTC)
+	spec := "name: hello-world\n"
+	applied := &Entry{
+		Started:        started,
+		Duration:       90 * time.Second,
+		Instance:       "https://sourcegraph.example.com",
+		SrcVersion:     "3.33.0",
+		Spec:           spec,
+		SpecFile:       "hello.batch.yaml",
+		Name:           "hello-world",
+		Repositories:   3,
+		Workspaces:     4,
+		ChangesetSpecs: 2,
+		BatchChangeURL: "https://sourcegraph.example.com/users/alice/batch-changes/hello-world",
+		Result:         ResultApplied,
+	}
+	failed := &Entry{
+		Started:  started.Add(time.Hour),
+		Instance: "https://sourcegraph.example.com",
+		Spec:     spec,
+		Result:   ResultFailed,
+		Error:    "executing steps: exit status 1",
+	}
+	unparsable := &Entry{
+		Started: started.Add(2 * time.Hour),
+		Result:  ResultFailed,
+		Error:   "parsing batch spec",
+	}
+	for _, e := range []*Entry{applied, failed, unparsable} {
+		if err := h.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if applied.ID == "" || applied.ID == failed.ID {
+		t.Errorf("wrong IDs: %q and %q", applied.ID, failed.ID)
+	}
+	if applied.SpecHash == "" || applied.SpecHash != failed.SpecHash {
+		t.Errorf("wrong spec hashes: %q and %q", applied.SpecHash, failed.SpecHash)
+	}
+	if specs, err := os.ReadDir(filepath.Join(dir, "specs")); err != nil || len(specs) != 1 {
+		t.Errorf("want the spec stored once, have %v, %v", specs, err)
+	}
+
+	entries, err = h.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The specs aren't part of the entries.
+	for _, e := range []*Entry{applied, failed, unparsable} {
+		e.Spec = ""
+	}
+	if diff := cmp.Diff([]*Entry{applied, failed, unparsable}, entries); diff != "" {
+		t.Errorf("wrong entries (-want +have):\n%s", diff)
+	}
+
+	last, err := h.Find("last")
+	if err != nil || last.ID != unparsable.ID {
+		t.Errorf("wrong last entry: %+v, %v", last, err)
+	}
+	found, err := h.Find(applied.ID[:6])
+	if err != nil || found.ID != applied.ID {
+		t.Fatalf("wrong entry for prefix: %+v, %v", found, err)
+	}
+	if have, err := h.Spec(found); err != nil || have != spec {
+		t.Errorf("wrong spec: %q, %v", have, err)
+	}
+	if _, err := h.Spec(last); err == nil {
+		t.Error("want error for entry without spec")
+	}
+	if _, err := h.Find("zzz"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("want ErrNotFound, have %v", err)
+	}
+}
+
+func TestHistory_FindAmbiguous(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "history.jsonl"), []byte("{\"id\": \"abc123\"}\n{\"id\": \"abd456\"}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	h := Open(dir)
+	if _, err := h.Find("ab"); err == nil || !strings.Contains(err.Error(), "more than one") {
+		t.Errorf("want ambiguity error, have %v", err)
+	}
+	if e, err := h.Find("abd"); err != nil || e.ID != "abd456" {
+		t.Errorf("wrong entry: %+v, %v", e, err)
+	}
+}
+
+func TestHistory_Corrupt(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "history.jsonl"), []byte("{\"id\": \"a\"}\n\nnot json\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(dir).List(); err == nil || !strings.Contains(err.Error(), "history.jsonl:3") {
+		t.Errorf("want error with line number, have %v", err)
+	}
+}
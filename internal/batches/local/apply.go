@@ -0,0 +1,166 @@
+// Package local applies the diffs of changeset specs to local clones of their
+// repositories, instead of creating changesets on the code host.
+package local
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/sourcegraph/src-cli/internal/exec"
+)
+
+// Changeset is a changeset to apply to a local clone.
+type Changeset struct {
+	// Repository is the name of the repository on Sourcegraph.
+	Repository string
+	// BaseRef and BaseRev are the ref and commit the branch is created from.
+	BaseRef string
+	BaseRev string
+	// Branch is the name of the branch that is created, without the
+	// refs/heads/ prefix.
+	Branch  string
+	Commits []Commit
+}
+
+// Commit is a commit of a changeset.
+type Commit struct {
+	Message     string
+	AuthorName  string
+	AuthorEmail string
+	// Diff is the diff of the commit, without a/ and b/ prefixes.
+	Diff string
+}
+
+// PathMapping maps repository names to the directories of their local
+// clones. Keys ending in "/" are prefixes: the rest of the repository name is
+// joined to their directory.
+type PathMapping map[string]string
+
+// LoadPathMapping reads a path mapping from a YAML file, such as:
+//
+//	github.com/sourcegraph/src-cli: ~/work/src-cli
+//	github.com/sourcegraph/: ~/src/
+//
+// A leading ~ in directories is expanded to the home directory.
+func LoadPathMapping(path string) (PathMapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m PathMapping
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, errors.Wrapf(err, "parsing path mapping %s", path)
+	}
+
+	for repo, dir := range m {
+		if dir == "~" || strings.HasPrefix(dir, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, err
+			}
+			m[repo] = filepath.Join(home, strings.TrimPrefix(dir, "~"))
+		}
+	}
+	return m, nil
+}
+
+// Dir returns the local directory of the repository. Exact matches take
+// precedence over prefixes, and longer prefixes over shorter ones.
+func (m PathMapping) Dir(repo string) (string, bool) {
+	if dir, ok := m[repo]; ok {
+		return dir, true
+	}
+
+	prefixes := make([]string, 0, len(m))
+	for prefix := range m {
+		if strings.HasSuffix(prefix, "/") && strings.HasPrefix(repo, prefix) {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	if len(prefixes) == 0 {
+		return "", false
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+	return filepath.Join(m[prefixes[0]], filepath.FromSlash(strings.TrimPrefix(repo, prefixes[0]))), true
+}
+
+// Options configures Apply.
+type Options struct {
+	// NoCommit leaves the changes staged on the new branch, instead of
+	// creating the commits of the changeset.
+	NoCommit bool
+}
+
+// Apply creates the branch of the changeset in the local clone in dir, from
+// its base commit, and applies the diffs of its commits to it. The clone must
+// not have uncommitted changes. If the base commit isn't in the clone, the
+// base ref is fetched from origin.
+func Apply(ctx context.Context, dir string, cs Changeset, opts Options) error {
+	git := func(stdin string, args ...string) (string, error) {
+		return runGit(ctx, dir, nil, stdin, args...)
+	}
+
+	status, err := git("", "status", "--porcelain")
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(status) != "" {
+		return errors.Newf("%s has uncommitted changes", dir)
+	}
+
+	if _, err := git("", "cat-file", "-e", cs.BaseRev+"^{commit}"); err != nil {
+		if _, err := git("", "fetch", "origin", strings.TrimPrefix(cs.BaseRef, "refs/heads/")); err != nil {
+			return errors.Wrapf(err, "base commit %s not found", cs.BaseRev)
+		}
+	}
+
+	if _, err := git("", "checkout", "-b", cs.Branch, cs.BaseRev); err != nil {
+		return err
+	}
+
+	for _, c := range cs.Commits {
+		if _, err := git(c.Diff, "apply", "-p0", "--index", "-"); err != nil {
+			return err
+		}
+		if opts.NoCommit {
+			continue
+		}
+
+		var env []string
+		if c.AuthorName != "" {
+			env = append(env, "GIT_AUTHOR_NAME="+c.AuthorName)
+		}
+		if c.AuthorEmail != "" {
+			env = append(env, "GIT_AUTHOR_EMAIL="+c.AuthorEmail)
+		}
+		if _, err := runGit(ctx, dir, env, "", "commit", "--quiet", "--allow-empty", "--message", c.Message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runGit(ctx context.Context, dir string, env []string, stdin string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Stdin = strings.NewReader(stdin)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", errors.Wrapf(err, "'git %s' failed: %s", strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
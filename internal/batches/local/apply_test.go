@@ -0,0 +1,98 @@
+package local
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPathMappingDir(t *testing.T) {
+	m := PathMapping{
+		"github.com/sourcegraph/src-cli": "/work/src-cli",
+		"github.com/sourcegraph/":        "/src",
+		"github.com/":                    "/github",
+	}
+
+	for repo, want := range map[string]string{
+		"github.com/sourcegraph/src-cli":     "/work/src-cli",
+		"github.com/sourcegraph/sourcegraph": "/src/sourcegraph",
+		"github.com/golang/go":               "/github/golang/go",
+		"gitlab.com/a/b":                     "",
+	} {
+		have, ok := m.Dir(repo)
+		if ok != (want != "") || have != filepath.FromSlash(want) {
+			t.Errorf("wrong directory for %s: have=%q want=%q", repo, have, want)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		out, err := runGit(context.Background(), dir, []string{
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		}, "", args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(out)
+	}
+
+	git("init", "--quiet")
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "README.md")
+	git("commit", "--quiet", "--message", "initial")
+	base := git("rev-parse", "HEAD")
+
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	err := Apply(context.Background(), dir, Changeset{
+		Repository: "github.com/a/b",
+		BaseRef:    "refs/heads/main",
+		BaseRev:    base,
+		Branch:     "hello-world",
+		Commits: []Commit{{
+			Message:     "Say hello world",
+			AuthorName:  "Jane Doe",
+			AuthorEmail: "jane@example.com",
+			Diff:        "diff --git README.md README.md\n--- README.md\n+++ README.md\n@@ -1 +1 @@\n-hello\n+hello world\n",
+		}},
+	}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have := git("rev-parse", "--abbrev-ref", "HEAD"); have != "hello-world" {
+		t.Errorf("wrong branch: %s", have)
+	}
+	if have := git("log", "-1", "--format=%an <%ae> %s"); have != "Jane Doe <jane@example.com> Say hello world" {
+		t.Errorf("wrong commit: %s", have)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "README.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello world\n" {
+		t.Errorf("wrong content: %q", data)
+	}
+
+	// The clone now has uncommitted changes.
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("dirty\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Apply(context.Background(), dir, Changeset{BaseRev: base, Branch: "other"}, Options{}); err == nil {
+		t.Error("unexpected nil error for dirty clone")
+	}
+}
@@ -0,0 +1,196 @@
+// Package lockfile implements locks on files and directories shared by
+// concurrent src processes, such as the cache directory of batch specs. A lock
+// is a file that records the process holding it, so that locks of processes
+// that died without releasing them can be recovered.
+package lockfile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Owner is the content of a lock file.
+type Owner struct {
+	PID      int       `json:"pid"`
+	Hostname string    `json:"hostname"`
+	Acquired time.Time `json:"acquired"`
+}
+
+// LockedError is returned by TryAcquire if the lock is held by a running
+// process.
+type LockedError struct {
+	Path string
+	// Owner is the process holding the lock. It's the zero value if the lock
+	// file couldn't be read, because it's still being written.
+	Owner Owner
+}
+
+func (e *LockedError) Error() string {
+	if e.Owner.PID == 0 {
+		return fmt.Sprintf("%s is locked by another process", e.Path)
+	}
+	return fmt.Sprintf("%s is locked by process %d on %s since %s", e.Path, e.Owner.PID, e.Owner.Hostname, e.Owner.Acquired.Format(time.RFC3339))
+}
+
+// Lock is an acquired lock.
+type Lock struct {
+	path  string
+	owner Owner
+}
+
+// PollInterval is how often Acquire checks whether the lock was released.
+var PollInterval = time.Second
+
+// unreadableStaleAfter is how long a lock file that can't be read is assumed
+// to still be being written, before it's considered left behind by a process
+// that died while writing it.
+const unreadableStaleAfter = 10 * time.Second
+
+// TryAcquire acquires the lock at path, creating the directory containing it
+// if needed. If the lock is held by a running process, a *LockedError is
+// returned. Locks held by processes on the same host that are no longer
+// running are stale and taken over. Locks of other hosts, which can happen if
+// the directory is on a network file system, are never considered stale.
+func TryAcquire(path string) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, errors.Wrap(err, "creating lock directory")
+	}
+
+	hostname, _ := os.Hostname()
+	owner := Owner{PID: os.Getpid(), Hostname: hostname, Acquired: time.Now().UTC()}
+	data, err := json.Marshal(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	// The second attempt is after removing a stale lock.
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			_, err = f.Write(data)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, errors.Wrap(err, "writing lock file")
+			}
+			return &Lock{path: path, owner: owner}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, errors.Wrap(err, "creating lock file")
+		}
+
+		held, stale, err := readOwner(path, hostname)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// Released in the meantime.
+				continue
+			}
+			return nil, err
+		}
+		if !stale {
+			return nil, &LockedError{Path: path, Owner: held}
+		}
+		if err := removeStale(path, held); err != nil {
+			return nil, err
+		}
+	}
+	return nil, &LockedError{Path: path}
+}
+
+// Acquire acquires the lock at path like TryAcquire, but waits for it to be
+// released if it's held by a running process. onWait is called with the
+// holder of the lock the first time Acquire has to wait. If ctx is canceled
+// before the lock is acquired, its error is returned.
+func Acquire(ctx context.Context, path string, onWait func(Owner)) (*Lock, error) {
+	waiting := false
+	for {
+		lock, err := TryAcquire(path)
+		var locked *LockedError
+		if !errors.As(err, &locked) {
+			return lock, err
+		}
+		if !waiting && onWait != nil {
+			onWait(locked.Owner)
+		}
+		waiting = true
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(PollInterval):
+		}
+	}
+}
+
+// Release releases the lock. Releasing a lock that was taken over by another
+// process, because it was considered stale, doesn't remove the new lock.
+func (l *Lock) Release() error {
+	if l == nil {
+		return nil
+	}
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var owner Owner
+	if err := json.Unmarshal(data, &owner); err != nil || !owner.Acquired.Equal(l.owner.Acquired) || owner.PID != l.owner.PID {
+		return nil
+	}
+	return os.Remove(l.path)
+}
+
+// readOwner reads the lock file at path and returns its owner, and whether
+// the lock is stale.
+func readOwner(path, hostname string) (owner Owner, stale bool, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Owner{}, false, err
+	}
+	if err := json.Unmarshal(data, &owner); err != nil {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return Owner{}, false, err
+		}
+		return Owner{}, time.Since(fi.ModTime()) > unreadableStaleAfter, nil
+	}
+	return owner, owner.Hostname == hostname && !ProcessRunning(owner.PID), nil
+}
+
+// removeStale removes the stale lock of owner at path. Another process can
+// take over the same stale lock at the same time, so the lock file is first
+// moved out of the way, and restored if it turns out to be the new lock of
+// the other process.
+func removeStale(path string, owner Owner) error {
+	moved := path + ".stale." + strconv.Itoa(os.Getpid())
+	if err := os.Rename(path, moved); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrap(err, "removing stale lock file")
+	}
+
+	data, err := os.ReadFile(moved)
+	if err != nil {
+		return err
+	}
+	var have Owner
+	if json.Unmarshal(data, &have) == nil && (have.PID != owner.PID || !have.Acquired.Equal(owner.Acquired)) {
+		// Restore the lock, unless yet another process acquired it since.
+		if err := os.Link(moved, path); err != nil && !os.IsExist(err) {
+			return err
+		}
+	}
+	return os.Remove(moved)
+}
@@ -0,0 +1,169 @@
+package lockfile
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+func TestTryAcquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", "lock")
+
+	lock, err := TryAcquire(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = TryAcquire(path)
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("want LockedError, have %v", err)
+	}
+	if locked.Owner.PID != os.Getpid() {
+		t.Errorf("wrong owner PID: want %d, have %d", os.Getpid(), locked.Owner.PID)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("lock file still exists: %v", err)
+	}
+
+	lock, err = TryAcquire(path)
+	if err != nil {
+		t.Fatalf("acquiring released lock: %v", err)
+	}
+	lock.Release()
+}
+
+func TestTryAcquire_Stale(t *testing.T) {
+	hostname, _ := os.Hostname()
+
+	t.Run("process not running", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "lock")
+		writeOwner(t, path, Owner{PID: exitedPID(t), Hostname: hostname, Acquired: time.Now()})
+
+		lock, err := TryAcquire(path)
+		if err != nil {
+			t.Fatalf("stale lock wasn't taken over: %v", err)
+		}
+		if lock.owner.PID != os.Getpid() {
+			t.Errorf("wrong owner: %+v", lock.owner)
+		}
+	})
+
+	t.Run("other host", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "lock")
+		writeOwner(t, path, Owner{PID: exitedPID(t), Hostname: hostname + "-other", Acquired: time.Now()})
+
+		var locked *LockedError
+		if _, err := TryAcquire(path); !errors.As(err, &locked) {
+			t.Fatalf("want LockedError, have %v", err)
+		}
+	})
+
+	t.Run("unreadable", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "lock")
+		if err := os.WriteFile(path, []byte("{"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		var locked *LockedError
+		if _, err := TryAcquire(path); !errors.As(err, &locked) {
+			t.Fatalf("want LockedError for a lock file being written, have %v", err)
+		}
+
+		old := time.Now().Add(-time.Minute)
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := TryAcquire(path); err != nil {
+			t.Fatalf("stale lock wasn't taken over: %v", err)
+		}
+	})
+}
+
+func TestAcquire(t *testing.T) {
+	defer func(d time.Duration) { PollInterval = d }(PollInterval)
+	PollInterval = 10 * time.Millisecond
+
+	path := filepath.Join(t.TempDir(), "lock")
+	held, err := TryAcquire(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waited := make(chan Owner, 1)
+	go func() {
+		owner := <-waited
+		if owner.PID != os.Getpid() {
+			t.Errorf("wrong owner PID: %d", owner.PID)
+		}
+		held.Release()
+	}()
+
+	lock, err := Acquire(context.Background(), path, func(owner Owner) { waited <- owner })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := Acquire(ctx, path, nil); err != context.DeadlineExceeded {
+		t.Fatalf("want deadline exceeded, have %v", err)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRelease_TakenOver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+	lock, err := TryAcquire(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another process considered the lock stale and took it over.
+	other := Owner{PID: lock.owner.PID + 1, Hostname: lock.owner.Hostname, Acquired: time.Now()}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	writeOwner(t, path, other)
+
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("lock of the other process was removed: %v", err)
+	}
+}
+
+func writeOwner(t *testing.T, path string, owner Owner) {
+	t.Helper()
+	data, err := json.Marshal(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// exitedPID returns the PID of a process that is no longer running.
+func exitedPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package lockfile
+
+import (
+	"os"
+	"syscall"
+)
+
+// ProcessRunning returns true if a process with the given PID exists.
+func ProcessRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// Signal 0 performs the existence and permission checks without sending
+	// a signal. EPERM means that the process exists, but belongs to someone
+	// else.
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,14 @@
+package lockfile
+
+import "os"
+
+// ProcessRunning returns true if a process with the given PID exists. On
+// Windows, FindProcess fails if it doesn't.
+func ProcessRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = p.Release()
+	return true
+}
@@ -0,0 +1,256 @@
+// Package reaper keeps track of the Docker containers, Docker volumes and
+// temporary directories created while executing a batch spec, so that they
+// can be removed when the execution is interrupted, either right away or by a
+// later `src batch cleanup`.
+package reaper
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/sourcegraph/src-cli/internal/batches/lockfile"
+	"github.com/sourcegraph/src-cli/internal/exec"
+)
+
+// State is the content of the state file of a run.
+type State struct {
+	// PID is the process ID of the src process executing the run.
+	PID     int       `json:"pid"`
+	Started time.Time `json:"started"`
+
+	// CIDFiles are the files that Docker writes the IDs of the step
+	// containers to.
+	CIDFiles   []string `json:"cidFiles,omitempty"`
+	Containers []string `json:"containers,omitempty"`
+	Volumes    []string `json:"volumes,omitempty"`
+	Dirs       []string `json:"dirs,omitempty"`
+}
+
+// Tracker records the resources of a run in its state file. All methods can
+// be called on a nil *Tracker, in which case they do nothing.
+type Tracker struct {
+	path string
+
+	mu    sync.Mutex
+	state State
+}
+
+// NewTracker creates the state file of a new run in dir.
+func NewTracker(dir string) (*Tracker, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "creating run state directory")
+	}
+
+	t := &Tracker{
+		path:  filepath.Join(dir, strconv.Itoa(os.Getpid())+".json"),
+		state: State{PID: os.Getpid(), Started: time.Now()},
+	}
+	return t, t.write()
+}
+
+// TrackCIDFile records the container ID file of a step container.
+func (t *Tracker) TrackCIDFile(path string) { t.update(cidFiles, path, true) }
+
+// UntrackCIDFile removes a container ID file recorded by TrackCIDFile.
+func (t *Tracker) UntrackCIDFile(path string) { t.update(cidFiles, path, false) }
+
+// TrackContainer records a container.
+func (t *Tracker) TrackContainer(id string) { t.update(containers, id, true) }
+
+// UntrackContainer removes a container recorded by TrackContainer.
+func (t *Tracker) UntrackContainer(id string) { t.update(containers, id, false) }
+
+// TrackVolume records a Docker volume.
+func (t *Tracker) TrackVolume(name string) { t.update(volumes, name, true) }
+
+// UntrackVolume removes a Docker volume recorded by TrackVolume.
+func (t *Tracker) UntrackVolume(name string) { t.update(volumes, name, false) }
+
+// TrackDir records a temporary directory.
+func (t *Tracker) TrackDir(path string) { t.update(dirs, path, true) }
+
+// UntrackDir removes a temporary directory recorded by TrackDir.
+func (t *Tracker) UntrackDir(path string) { t.update(dirs, path, false) }
+
+func cidFiles(s *State) *[]string   { return &s.CIDFiles }
+func containers(s *State) *[]string { return &s.Containers }
+func volumes(s *State) *[]string    { return &s.Volumes }
+func dirs(s *State) *[]string       { return &s.Dirs }
+
+// update adds item to, or removes it from, the given field of the state and
+// writes the state file.
+func (t *Tracker) update(field func(*State) *[]string, item string, add bool) {
+	if t == nil || item == "" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	list := field(&t.state)
+	if add {
+		*list = append(*list, item)
+	} else {
+		for i, have := range *list {
+			if have == item {
+				*list = append((*list)[:i], (*list)[i+1:]...)
+				break
+			}
+		}
+	}
+
+	// The state file is only there to clean up after interrupted runs, so
+	// failing to write it shouldn't fail the run.
+	_ = t.writeLocked()
+}
+
+func (t *Tracker) write() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.writeLocked()
+}
+
+func (t *Tracker) writeLocked() error {
+	data, err := json.Marshal(t.state)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so that an interrupted write doesn't
+	// leave a truncated state file behind.
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.path)
+}
+
+// Cleanup removes all resources still recorded by the Tracker and its state
+// file. It's called when the run finishes, including when it's interrupted,
+// so ctx should not be the context of the run.
+func (t *Tracker) Cleanup(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := removeResources(ctx, t.state); err != nil {
+		return err
+	}
+	t.state = State{PID: t.state.PID, Started: t.state.Started}
+	return os.Remove(t.path)
+}
+
+// CleanupStale removes the resources of the runs in dir whose process is no
+// longer running, and returns their states.
+func CleanupStale(ctx context.Context, dir string) ([]State, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var (
+		cleaned []State
+		errs    *multierror.Error
+	)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		var state State
+		if err := json.Unmarshal(data, &state); err != nil {
+			errs = multierror.Append(errs, errors.Wrapf(err, "reading run state file %s", path))
+			continue
+		}
+
+		if state.PID != os.Getpid() && lockfile.ProcessRunning(state.PID) {
+			continue
+		}
+
+		if err := removeResources(ctx, state); err != nil {
+			errs = multierror.Append(errs, errors.Wrapf(err, "cleaning up run of process %d", state.PID))
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		cleaned = append(cleaned, state)
+	}
+
+	sort.Slice(cleaned, func(i, j int) bool { return cleaned[i].Started.Before(cleaned[j].Started) })
+	return cleaned, errs.ErrorOrNil()
+}
+
+// removeResources removes the containers, volumes and directories of the
+// given state. Resources that are already gone are ignored.
+func removeResources(ctx context.Context, state State) error {
+	var errs *multierror.Error
+
+	ids := append([]string{}, state.Containers...)
+	for _, cidFile := range state.CIDFiles {
+		cid, err := os.ReadFile(cidFile)
+		if err == nil && len(cid) > 0 {
+			ids = append(ids, strings.TrimSpace(string(cid)))
+		}
+		if err := os.Remove(cidFile); err != nil && !os.IsNotExist(err) {
+			errs = multierror.Append(errs, err)
+		}
+	}
+
+	// Containers have to be removed before the volumes mounted in them.
+	if len(ids) > 0 {
+		args := append([]string{"rm", "-f", "--"}, ids...)
+		if out, err := exec.CommandContext(ctx, "docker", args...).CombinedOutput(); err != nil && !notFound(out) {
+			errs = multierror.Append(errs, errors.Wrapf(err, "removing containers: %s", out))
+		}
+	}
+
+	if len(state.Volumes) > 0 {
+		args := append([]string{"volume", "rm", "-f", "--"}, state.Volumes...)
+		if out, err := exec.CommandContext(ctx, "docker", args...).CombinedOutput(); err != nil && !notFound(out) {
+			errs = multierror.Append(errs, errors.Wrapf(err, "removing volumes: %s", out))
+		}
+	}
+
+	for _, dir := range state.Dirs {
+		if err := os.RemoveAll(dir); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// notFound returns true if the output of a failed `docker rm` only complains
+// about resources that don't exist anymore.
+func notFound(out []byte) bool {
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line != "" && !strings.Contains(strings.ToLower(line), "no such") {
+			return false
+		}
+	}
+	return true
+}
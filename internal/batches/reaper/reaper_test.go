@@ -0,0 +1,108 @@
+package reaper
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sourcegraph/src-cli/internal/exec/expect"
+)
+
+func TestMain(m *testing.M) {
+	code := expect.Handle(m)
+	os.Exit(code)
+}
+
+func TestTracker(t *testing.T) {
+	dir := t.TempDir()
+	workspace := filepath.Join(dir, "workspace")
+	if err := os.Mkdir(workspace, 0700); err != nil {
+		t.Fatal(err)
+	}
+	cidFile := filepath.Join(dir, "cid")
+	if err := os.WriteFile(cidFile, []byte("container-2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tracker, err := NewTracker(filepath.Join(dir, "runs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracker.TrackContainer("container-1")
+	tracker.TrackCIDFile(cidFile)
+	tracker.TrackVolume("volume-1")
+	tracker.TrackVolume("volume-2")
+	tracker.TrackDir(workspace)
+	tracker.UntrackVolume("volume-1")
+
+	var state State
+	data, err := os.ReadFile(tracker.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Containers) != 1 || len(state.CIDFiles) != 1 || len(state.Volumes) != 1 || len(state.Dirs) != 1 {
+		t.Fatalf("wrong state: %+v", state)
+	}
+
+	expect.Commands(t,
+		expect.NewGlob(expect.Success, "docker", "rm", "-f", "--", "container-1", "container-2"),
+		expect.NewGlob(expect.Success, "docker", "volume", "rm", "-f", "--", "volume-2"),
+	)
+	if err := tracker.Cleanup(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{tracker.path, workspace, cidFile} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed", path)
+		}
+	}
+}
+
+func TestTracker_Nil(t *testing.T) {
+	var tracker *Tracker
+	tracker.TrackVolume("volume")
+	if err := tracker.Cleanup(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCleanupStale(t *testing.T) {
+	dir := t.TempDir()
+
+	// The state file of this very process can only be left over from an
+	// earlier process that had the same PID.
+	data, err := json.Marshal(State{PID: os.Getpid(), Volumes: []string{"volume-1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "stale.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	expect.Commands(t,
+		expect.NewGlob(expect.Behaviour{ExitCode: 1, Stderr: []byte("Error: No such volume: volume-1")}, "docker", "volume", "rm", "-f", "--", "volume-1"),
+	)
+	cleaned, err := CleanupStale(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cleaned) != 1 {
+		t.Fatalf("wrong number of cleaned runs: %d", len(cleaned))
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("state file was not removed")
+	}
+
+	cleaned, err = CleanupStale(context.Background(), filepath.Join(dir, "missing"))
+	if err != nil || len(cleaned) != 0 {
+		t.Fatalf("unexpected result for missing directory: %v, %v", cleaned, err)
+	}
+}
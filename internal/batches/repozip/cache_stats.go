@@ -0,0 +1,50 @@
+package repozip
+
+import (
+	"sync"
+)
+
+// CacheStats are the numbers of repository archives that were taken from the
+// cache directory, and that were downloaded, by an ArchiveRegistry.
+type CacheStats struct {
+	// Hits are the archives taken from the cache without asking Sourcegraph.
+	Hits int
+	// NotModified are the cached archives that Sourcegraph confirmed to be
+	// unchanged, as their ETag still matched.
+	NotModified int
+	// Downloaded are the archives that were downloaded, including the cached
+	// archives that changed.
+	Downloaded int
+	// Resumed are the downloads that continued an interrupted download,
+	// rather than starting over.
+	Resumed int
+	// BytesDownloaded is the size of the downloads.
+	BytesDownloaded int64
+}
+
+// Total returns the number of archives that were used.
+func (s CacheStats) Total() int {
+	return s.Hits + s.NotModified + s.Downloaded
+}
+
+// cacheStats is the CacheStats of an ArchiveRegistry, which are updated
+// concurrently by its archives.
+type cacheStats struct {
+	mu    sync.Mutex
+	stats CacheStats
+}
+
+func (s *cacheStats) update(f func(stats *CacheStats)) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f(&s.stats)
+}
+
+func (s *cacheStats) get() CacheStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
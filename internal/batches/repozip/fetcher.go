@@ -1,7 +1,11 @@
 package repozip
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,12 +14,16 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/cockroachdb/errors"
 
 	"github.com/sourcegraph/src-cli/internal/batches/util"
+	"github.com/sourcegraph/src-cli/internal/metrics"
 )
 
+var bytesDownloaded = metrics.Default.NewCounter("src_batch_archive_downloaded_bytes_total", "Bytes of repository archives and files downloaded from Sourcegraph.")
+
 type RepoRevision struct {
 	RepoName string
 	Commit   string
@@ -29,6 +37,10 @@ type ArchiveRegistry interface {
 	// Users need to call `Ensure()` on the Archive before using it and
 	// `Close()` once // they're done using it.
 	Checkout(repo RepoRevision, path string) Archive
+
+	// CacheStats returns how many archives were taken from the cache and how
+	// many were downloaded so far.
+	CacheStats() CacheStats
 }
 
 // Archive implementations represent a downloaded repository archive.
@@ -75,6 +87,8 @@ type archiveRegistry struct {
 
 	zipsMu sync.Mutex
 	zips   map[string]*repoArchive
+
+	stats cacheStats
 }
 
 func (rf *archiveRegistry) Checkout(repo RepoRevision, path string) Archive {
@@ -86,6 +100,10 @@ func (rf *archiveRegistry) Checkout(repo RepoRevision, path string) Archive {
 	return zip
 }
 
+func (rf *archiveRegistry) CacheStats() CacheStats {
+	return rf.stats.get()
+}
+
 // additionalWorkspaceFiles is a list of files the Archive *tries* to fetch
 // when the desired archive is subdirectory in the given repository. It makes
 // sense to also fetch these files, even if the steps are executed in a
@@ -117,6 +135,7 @@ func (rf *archiveRegistry) zipFor(repo RepoRevision, workspacePath string) *repo
 			client:        rf.client,
 			deleteOnClose: rf.deleteZips,
 			pathInRepo:    workspacePath,
+			stats:         &rf.stats,
 		}
 
 		if workspacePath != "" {
@@ -174,6 +193,8 @@ type repoArchive struct {
 	pathInRepo string
 
 	client HTTPClient
+	// stats are the CacheStats of the registry, which may be nil.
+	stats *cacheStats
 
 	// zipPath is the path of the downloaded ZIP archive on the local filesystem.
 	zipPath string
@@ -208,7 +229,7 @@ func (rz *repoArchive) Close() error {
 				}
 			}
 		}
-		return os.Remove(rz.zipPath)
+		return removeWithETag(rz.zipPath)
 	}
 
 	return nil
@@ -254,7 +275,7 @@ func (rz *repoArchive) fetchArchiveAndFiles(ctx context.Context) (err error) {
 			// If the context got cancelled, or we ran out of disk space, or ...
 			// while we were downloading the file, we remove the partially
 			// downloaded file.
-			os.Remove(rz.zipPath)
+			removeWithETag(rz.zipPath)
 
 			for _, addFile := range rz.additionalFiles {
 				os.Remove(addFile.localPath)
@@ -266,8 +287,22 @@ func (rz *repoArchive) fetchArchiveAndFiles(ctx context.Context) (err error) {
 	if err != nil {
 		return err
 	}
+	if exists {
+		// Archives of earlier runs may have been cut short, for example by
+		// running out of disk space.
+		if err := verifyZip(rz.zipPath); err != nil {
+			if err := quarantine(rz.zipPath); err != nil {
+				return err
+			}
+			exists = false
+		}
+	}
 
-	if !exists {
+	if exists && readETag(rz.zipPath) == "" {
+		// Archives are cached by revision, so they're used as they are if
+		// there's no ETag to revalidate them with.
+		rz.stats.update(func(s *CacheStats) { s.Hits++ })
+	} else {
 		// Unlike the mkdirAll() calls elsewhere in this file, this is only
 		// giving us a temporary place on the filesystem to keep the archive.
 		// Since it's never mounted into the containers being run, we can keep
@@ -276,7 +311,7 @@ func (rz *repoArchive) fetchArchiveAndFiles(ctx context.Context) (err error) {
 			return err
 		}
 
-		ok, err := fetchRepositoryFile(ctx, rz.client, rz.repo, rz.pathInRepo, rz.zipPath)
+		ok, err := fetchRepositoryFile(ctx, rz.client, rz.repo, rz.pathInRepo, rz.zipPath, rz.stats)
 		if err != nil {
 			return errors.Wrap(err, "fetching ZIP archive")
 		}
@@ -296,7 +331,7 @@ func (rz *repoArchive) fetchArchiveAndFiles(ctx context.Context) (err error) {
 			continue
 		}
 
-		ok, err := fetchRepositoryFile(ctx, rz.client, rz.repo, addFile.filename, addFile.localPath)
+		ok, err := fetchRepositoryFile(ctx, rz.client, rz.repo, addFile.filename, addFile.localPath, nil)
 		if err != nil {
 			return errors.Wrapf(err, "fetching %s for repository archive", addFile.filename)
 		}
@@ -308,45 +343,336 @@ func (rz *repoArchive) fetchArchiveAndFiles(ctx context.Context) (err error) {
 	return nil
 }
 
-// fetchRepositoryInFile fetches the given `pathInRepo` using the Sourcegraph's
+// maxFetchAttempts is the number of times a file is downloaded before giving
+// up. Interrupted downloads are resumed where they stopped, and corrupt
+// downloads are quarantined and downloaded again.
+const maxFetchAttempts = 3
+
+// fetchRepositoryFile fetches the given `pathInRepo` using the Sourcegraph's
 // raw endpoint and writes it to `dest`.
 // If `pathInRepo` is empty and `dest` ends in `.zip` a ZIP archive of the
 // whole repository is downloaded.
-func fetchRepositoryFile(ctx context.Context, client HTTPClient, repo RepoRevision, pathInRepo string, dest string) (bool, error) {
+//
+// The file is downloaded to `dest` with a .part suffix first, and only moved
+// to `dest` once it's verified against the checksum and size reported by the
+// server, and, for ZIP archives, once it can be opened. If the download is
+// interrupted, the next attempt, or the next run of src, resumes it with a
+// range request, provided the ETag of the file didn't change.
+//
+// The ETags of ZIP archives are kept next to them. If `dest` exists and has
+// one, it's only downloaded again if Sourcegraph reports that it changed, and
+// kept as it is if Sourcegraph can't be reached.
+func fetchRepositoryFile(ctx context.Context, client HTTPClient, repo RepoRevision, pathInRepo string, dest string, stats *cacheStats) (bool, error) {
 	endpoint := repositoryRawFileEndpoint(repo, pathInRepo)
+	part := dest + ".part"
+	isZip := strings.HasSuffix(dest, ".zip")
+
+	var cachedETag string
+	if isZip {
+		if exists, err := fileExists(dest); err != nil {
+			return false, err
+		} else if exists {
+			cachedETag = readETag(dest)
+		}
+	}
+
+	var err error
+	for attempt := 0; attempt < maxFetchAttempts; attempt++ {
+		var d *download
+		d, err = downloadFile(ctx, client, endpoint, part, isZip, cachedETag, stats)
+		if err != nil {
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
+			var statusErr *statusError
+			if errors.As(err, &statusErr) {
+				return false, err
+			}
+			if cachedETag != "" {
+				// Sourcegraph can't be reached to revalidate the cached
+				// file, use it as it is.
+				stats.update(func(s *CacheStats) { s.Hits++ })
+				return true, nil
+			}
+			// The download was interrupted, resume it.
+			continue
+		}
+		if d == nil {
+			return false, nil
+		}
+		if d.notModified {
+			stats.update(func(s *CacheStats) { s.NotModified++ })
+			return true, nil
+		}
+
+		if err = d.verify(part); err != nil {
+			if qErr := quarantine(part); qErr != nil {
+				return false, qErr
+			}
+			continue
+		}
+		stats.update(func(s *CacheStats) { s.Downloaded++ })
+		if err := os.Rename(part, dest); err != nil {
+			return false, err
+		}
+		if err := os.Rename(etagPath(part), etagPath(dest)); os.IsNotExist(err) {
+			// There's no ETag to revalidate the file with.
+			os.Remove(etagPath(dest))
+		} else if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return false, err
+}
+
+// download is a completed download, with the size and checksum the server
+// reported for it.
+type download struct {
+	// size is the size of the file, or -1 if it's unknown.
+	size int64
+	// sha256 is the SHA-256 checksum of the file, or nil if it's unknown.
+	sha256 []byte
+	isZip  bool
+	// etag is the ETag of the file, or "" if it's unknown.
+	etag string
+	// notModified is true if the file wasn't downloaded, since it didn't
+	// change since it was downloaded with the ETag that was sent.
+	notModified bool
+}
+
+// statusError is a response with an unexpected status, which isn't resolved
+// by downloading the file again.
+type statusError struct {
+	status int
+	url    string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unable to fetch archive (HTTP %d from %s)", e.status, e.url)
+}
+
+// downloadFile downloads endpoint to part, resuming the download if part
+// already exists. It returns nil if the file doesn't exist. If cachedETag is
+// set, the file is only downloaded if its ETag changed; otherwise, the
+// download is marked as notModified.
+func downloadFile(ctx context.Context, client HTTPClient, endpoint, part string, isZip bool, cachedETag string, stats *cacheStats) (*download, error) {
+	var offset int64
+	var partETag string
+	if cachedETag != "" {
+		// The whole file is downloaded again if it changed.
+		removeWithETag(part)
+	} else if fi, err := os.Stat(part); err == nil {
+		offset = fi.Size()
+		partETag = readETag(part)
+	}
+
 	req, err := client.NewHTTPRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-
-	if strings.HasSuffix(dest, ".zip") {
+	if isZip {
 		req.Header.Set("Accept", "application/zip")
 	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		// Weak ETags can't be used to resume downloads.
+		if partETag != "" && !strings.HasPrefix(partETag, "W/") {
+			req.Header.Set("If-Range", partETag)
+		}
+	}
+	if cachedETag != "" {
+		req.Header.Set("If-None-Match", cachedETag)
+	}
+	// Ask for the checksum of the file, for servers that only send it when
+	// asked to.
+	req.Header.Set("Want-Digest", "sha-256")
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		if resp.StatusCode == http.StatusNotFound {
-			return false, nil
+	d := &download{size: -1, sha256: parseDigest(resp.Header), isZip: isZip, etag: resp.Header.Get("ETag")}
+	flags := os.O_WRONLY | os.O_CREATE
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// The server doesn't support range requests, the file changed, or
+		// there was nothing to resume: start from the beginning.
+		flags |= os.O_TRUNC
+		d.size = resp.ContentLength
+
+	case http.StatusPartialContent:
+		start, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			// Start over with the next attempt.
+			removeWithETag(part)
+			return nil, errors.Newf("unexpected Content-Range %q", resp.Header.Get("Content-Range"))
+		}
+		if partETag != "" && d.etag != "" && d.etag != partETag {
+			removeWithETag(part)
+			return nil, errors.New("the file changed since the download was interrupted")
+		}
+		flags |= os.O_APPEND
+		d.size = total
+		stats.update(func(s *CacheStats) { s.Resumed++ })
+
+	case http.StatusNotModified:
+		if cachedETag == "" {
+			return nil, &statusError{status: resp.StatusCode, url: req.URL.String()}
+		}
+		d.notModified = true
+		return d, nil
+
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The partial download is as large as the file, or larger. It can't
+		// be trusted, so start over with the next attempt.
+		removeWithETag(part)
+		return nil, errors.New("partial download is larger than the file")
+
+	case http.StatusNotFound:
+		removeWithETag(part)
+		return nil, nil
+
+	default:
+		return nil, &statusError{status: resp.StatusCode, url: req.URL.String()}
+	}
+
+	// The ETag is recorded before downloading, so that an interrupted
+	// download can be resumed in the next run of src.
+	if isZip {
+		if err := writeETag(part, d.etag); err != nil {
+			return nil, err
 		}
-		return false, fmt.Errorf("unable to fetch archive (HTTP %d from %s)", resp.StatusCode, req.URL.String())
 	}
 
-	f, err := os.Create(dest)
+	f, err := os.OpenFile(part, flags, 0600)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	defer f.Close()
 
-	if _, err := io.Copy(f, resp.Body); err != nil {
-		return false, err
+	n, err := io.Copy(f, resp.Body)
+	bytesDownloaded.Add(float64(n))
+	stats.update(func(s *CacheStats) { s.BytesDownloaded += n })
+	if err != nil {
+		return nil, err
+	}
+	return d, f.Close()
+}
+
+// verify checks the downloaded file at path against the size and checksum
+// reported by the server, and that ZIP archives can be opened.
+func (d *download) verify(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
 	}
+	defer f.Close()
 
-	return true, nil
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+	if d.size >= 0 && n != d.size {
+		return errors.Newf("downloaded %d bytes, expected %d", n, d.size)
+	}
+	if d.sha256 != nil && !bytes.Equal(h.Sum(nil), d.sha256) {
+		return errors.New("checksum mismatch")
+	}
+	if d.isZip {
+		return verifyZip(path)
+	}
+	return nil
+}
+
+// verifyZip checks that the ZIP archive at path can be opened, which fails
+// for truncated archives.
+func verifyZip(path string) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return errors.Wrap(err, "invalid ZIP archive")
+	}
+	return r.Close()
+}
+
+// quarantine moves a corrupt file into the quarantine directory next to it,
+// where it can be inspected, so that it's downloaded again.
+func quarantine(path string) error {
+	dir := filepath.Join(filepath.Dir(path), "quarantine")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	name := strings.TrimSuffix(filepath.Base(path), ".part")
+	os.Remove(etagPath(path))
+	return os.Rename(path, filepath.Join(dir, fmt.Sprintf("%s.%d", name, time.Now().UnixNano())))
+}
+
+// etagPath returns the path of the file that the ETag of the downloaded file
+// at path is kept in.
+func etagPath(path string) string {
+	return path + ".etag"
+}
+
+// readETag returns the ETag of the downloaded file at path, or "" if it's
+// unknown.
+func readETag(path string) string {
+	data, err := os.ReadFile(etagPath(path))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// writeETag records the ETag of the downloaded file at path, or removes the
+// recorded one if etag is empty.
+func writeETag(path, etag string) error {
+	if etag == "" {
+		if err := os.Remove(etagPath(path)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(etagPath(path), []byte(etag+"\n"), 0600)
+}
+
+// removeWithETag removes the downloaded file at path and its ETag.
+func removeWithETag(path string) error {
+	os.Remove(etagPath(path))
+	return os.Remove(path)
+}
+
+// parseDigest returns the SHA-256 checksum in the Digest header of the
+// response, or nil if there is none.
+func parseDigest(header http.Header) []byte {
+	for _, value := range header.Values("Digest") {
+		for _, digest := range strings.Split(value, ",") {
+			idx := strings.Index(digest, "=")
+			if idx == -1 || !strings.EqualFold(strings.TrimSpace(digest[:idx]), "sha-256") {
+				continue
+			}
+			sum, err := base64.StdEncoding.DecodeString(strings.TrimSpace(digest[idx+1:]))
+			if err == nil && len(sum) == sha256.Size {
+				return sum
+			}
+		}
+	}
+	return nil
+}
+
+// parseContentRange returns the start of the range and the size of the file
+// in a Content-Range header, which is -1 if it's unknown.
+func parseContentRange(value string) (start, total int64, ok bool) {
+	var end int64
+	if _, err := fmt.Sscanf(value, "bytes %d-%d/%d", &start, &end, &total); err == nil {
+		return start, total, true
+	}
+	if _, err := fmt.Sscanf(value, "bytes %d-%d/*", &start, &end); err == nil {
+		return start, -1, true
+	}
+	return 0, 0, false
 }
 
 func repositoryRawFileEndpoint(repo RepoRevision, pathInRepo string) string {
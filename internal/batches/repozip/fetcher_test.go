@@ -1,13 +1,19 @@
 package repozip
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -322,3 +328,282 @@ func dirContains(dir, filename string) (bool, error) {
 
 	return false, nil
 }
+
+func TestFetchRepositoryFile(t *testing.T) {
+	repo := RepoRevision{RepoName: "github.com/sourcegraph/src-cli", Commit: "d34db33f"}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(bytes.Repeat([]byte("# Welcome to the README\n"), 1000))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	archive := buf.Bytes()
+	sum := sha256.Sum256(archive)
+	digest := "sha-256=" + base64.StdEncoding.EncodeToString(sum[:])
+
+	// newServer returns a server that serves the archive with the given
+	// handler for each request, in order.
+	newServer := func(t *testing.T, handlers ...func(w http.ResponseWriter, r *http.Request)) (*httptest.Server, *[]string) {
+		var ranges []string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ranges = append(ranges, r.Header.Get("Range"))
+			if len(ranges) > len(handlers) {
+				t.Errorf("unexpected request %d", len(ranges))
+				return
+			}
+			handlers[len(ranges)-1](w, r)
+		}))
+		t.Cleanup(ts.Close)
+		return ts, &ranges
+	}
+	serve := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Digest", digest)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(archive))
+	}
+
+	fetch := func(t *testing.T, ts *httptest.Server, dest string) {
+		t.Helper()
+		client := api.NewClient(api.ClientOpts{Endpoint: ts.URL, Out: &bytes.Buffer{}})
+		ok, err := fetchRepositoryFile(context.Background(), client, repo, "", dest, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			t.Fatal("archive not found")
+		}
+		data, err := os.ReadFile(dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, archive) {
+			t.Fatal("wrong archive contents")
+		}
+	}
+
+	t.Run("resumed", func(t *testing.T) {
+		ts, ranges := newServer(t,
+			func(w http.ResponseWriter, r *http.Request) {
+				// Send half of the archive, and then drop the connection.
+				w.Header().Set("Content-Length", strconv.Itoa(len(archive)))
+				w.Write(archive[:len(archive)/2])
+				w.(http.Flusher).Flush()
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+			},
+			serve,
+		)
+
+		fetch(t, ts, filepath.Join(t.TempDir(), "archive.zip"))
+		if want := []string{"", fmt.Sprintf("bytes=%d-", len(archive)/2)}; !cmp.Equal(want, *ranges) {
+			t.Errorf("wrong ranges requested (-want +have):\n%s", cmp.Diff(want, *ranges))
+		}
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		ts, _ := newServer(t,
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Digest", digest)
+				corrupt := append([]byte(nil), archive...)
+				corrupt[10] ^= 0xff
+				w.Write(corrupt)
+			},
+			serve,
+		)
+
+		dir := t.TempDir()
+		fetch(t, ts, filepath.Join(dir, "archive.zip"))
+		quarantined, err := os.ReadDir(filepath.Join(dir, "quarantine"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(quarantined) != 1 || !strings.HasPrefix(quarantined[0].Name(), "archive.zip.") {
+			t.Errorf("corrupt archive not quarantined: %v", quarantined)
+		}
+	})
+
+	t.Run("truncated archive in cache", func(t *testing.T) {
+		ts, _ := newServer(t, serve)
+
+		dir := t.TempDir()
+		rz := &repoArchive{
+			zipPath: filepath.Join(dir, "archive.zip"),
+			repo:    repo,
+			client:  api.NewClient(api.ClientOpts{Endpoint: ts.URL, Out: &bytes.Buffer{}}),
+		}
+		if err := os.WriteFile(rz.zipPath, archive[:len(archive)/2], 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := rz.fetchArchiveAndFiles(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(rz.zipPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, archive) {
+			t.Error("truncated archive wasn't downloaded again")
+		}
+	})
+
+	t.Run("failing", func(t *testing.T) {
+		ts, _ := newServer(t,
+			func(w http.ResponseWriter, r *http.Request) { http.Error(w, "boom", http.StatusInternalServerError) },
+		)
+		client := api.NewClient(api.ClientOpts{Endpoint: ts.URL, Out: &bytes.Buffer{}})
+		if _, err := fetchRepositoryFile(context.Background(), client, repo, "", filepath.Join(t.TempDir(), "archive.zip"), nil); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
+
+func TestFetchRepositoryFileETag(t *testing.T) {
+	repo := RepoRevision{RepoName: "github.com/sourcegraph/src-cli", Commit: "d34db33f"}
+
+	newArchive := func(t *testing.T, content string) []byte {
+		t.Helper()
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		w, err := zw.Create("README.md")
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, content)
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	archive := newArchive(t, strings.Repeat("# Welcome to the README\n", 1000))
+
+	// newServer returns a server that serves the given archive with the
+	// given ETag, and records the conditional headers of the requests.
+	newServer := func(t *testing.T, archive []byte, etag string) (*httptest.Server, *[]http.Header) {
+		var headers []http.Header
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			headers = append(headers, http.Header{
+				"If-None-Match": r.Header.Values("If-None-Match"),
+				"If-Range":      r.Header.Values("If-Range"),
+				"Range":         r.Header.Values("Range"),
+			})
+			w.Header().Set("ETag", etag)
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(archive))
+		}))
+		t.Cleanup(ts.Close)
+		return ts, &headers
+	}
+	fetch := func(t *testing.T, ts *httptest.Server, dest string, stats *cacheStats) {
+		t.Helper()
+		client := api.NewClient(api.ClientOpts{Endpoint: ts.URL, Out: &bytes.Buffer{}})
+		ok, err := fetchRepositoryFile(context.Background(), client, repo, "", dest, stats)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			t.Fatal("archive not found")
+		}
+	}
+
+	t.Run("not modified", func(t *testing.T) {
+		ts, headers := newServer(t, archive, `"v1"`)
+		dest := filepath.Join(t.TempDir(), "archive.zip")
+		var stats cacheStats
+
+		fetch(t, ts, dest, &stats)
+		if have := readETag(dest); have != `"v1"` {
+			t.Errorf("wrong ETag recorded: %q", have)
+		}
+		fetch(t, ts, dest, &stats)
+
+		if have := (*headers)[1].Get("If-None-Match"); have != `"v1"` {
+			t.Errorf("wrong If-None-Match: %q", have)
+		}
+		want := CacheStats{Downloaded: 1, NotModified: 1, BytesDownloaded: int64(len(archive))}
+		if diff := cmp.Diff(want, stats.get()); diff != "" {
+			t.Errorf("wrong stats (-want +have):\n%s", diff)
+		}
+	})
+
+	t.Run("modified", func(t *testing.T) {
+		dest := filepath.Join(t.TempDir(), "archive.zip")
+		ts, _ := newServer(t, archive, `"v1"`)
+		fetch(t, ts, dest, nil)
+
+		changed := newArchive(t, "# Changed\n")
+		ts, _ = newServer(t, changed, `"v2"`)
+		fetch(t, ts, dest, nil)
+
+		data, err := os.ReadFile(dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, changed) {
+			t.Error("changed archive wasn't downloaded")
+		}
+		if have := readETag(dest); have != `"v2"` {
+			t.Errorf("wrong ETag recorded: %q", have)
+		}
+	})
+
+	t.Run("resumed", func(t *testing.T) {
+		ts, headers := newServer(t, archive, `"v1"`)
+		dest := filepath.Join(t.TempDir(), "archive.zip")
+		part := dest + ".part"
+		if err := os.WriteFile(part, archive[:100], 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeETag(part, `"v1"`); err != nil {
+			t.Fatal(err)
+		}
+		var stats cacheStats
+
+		fetch(t, ts, dest, &stats)
+
+		want := http.Header{"If-None-Match": nil, "If-Range": {`"v1"`}, "Range": {"bytes=100-"}}
+		if diff := cmp.Diff(want, (*headers)[0]); diff != "" {
+			t.Errorf("wrong headers (-want +have):\n%s", diff)
+		}
+		if have := stats.get(); have.Resumed != 1 || have.BytesDownloaded != int64(len(archive)-100) {
+			t.Errorf("wrong stats: %+v", have)
+		}
+	})
+
+	t.Run("changed since interrupted", func(t *testing.T) {
+		ts, _ := newServer(t, archive, `"v2"`)
+		dest := filepath.Join(t.TempDir(), "archive.zip")
+		part := dest + ".part"
+		if err := os.WriteFile(part, []byte("something else"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeETag(part, `"v1"`); err != nil {
+			t.Fatal(err)
+		}
+
+		fetch(t, ts, dest, nil)
+		data, err := os.ReadFile(dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, archive) {
+			t.Error("wrong archive contents")
+		}
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		ts, _ := newServer(t, archive, `"v1"`)
+		dest := filepath.Join(t.TempDir(), "archive.zip")
+		fetch(t, ts, dest, nil)
+		ts.Close()
+
+		var stats cacheStats
+		fetch(t, ts, dest, &stats)
+		if have := stats.get(); have != (CacheStats{Hits: 1}) {
+			t.Errorf("wrong stats: %+v", have)
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package repozip
+
+import (
+	"archive/zip"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+)
+
+// NewLocalArchiveRegistry returns an ArchiveRegistry that archives the given
+// local directories, by repository and revision, instead of downloading them
+// from Sourcegraph. Other repositories are checked out of fallback. The
+// archives are created in tempDir, and removed once they're closed.
+func NewLocalArchiveRegistry(dirs map[RepoRevision]string, tempDir string, fallback ArchiveRegistry) ArchiveRegistry {
+	return &localArchiveRegistry{dirs: dirs, tempDir: tempDir, fallback: fallback}
+}
+
+type localArchiveRegistry struct {
+	dirs     map[RepoRevision]string
+	tempDir  string
+	fallback ArchiveRegistry
+}
+
+func (r *localArchiveRegistry) Checkout(repo RepoRevision, path string) Archive {
+	dir, ok := r.dirs[repo]
+	if !ok {
+		return r.fallback.Checkout(repo, path)
+	}
+	return &localArchive{dir: dir, pathInRepo: path, tempDir: r.tempDir}
+}
+
+// CacheStats returns the CacheStats of the fallback, as the local archives
+// aren't cached.
+func (r *localArchiveRegistry) CacheStats() CacheStats {
+	return r.fallback.CacheStats()
+}
+
+var _ Archive = &localArchive{}
+
+// localArchive is a ZIP archive of a local directory, with the same layout as
+// the archives downloaded from Sourcegraph: the files of the workspace are
+// below their path in the repository.
+type localArchive struct {
+	mu sync.Mutex
+
+	dir        string
+	pathInRepo string
+	tempDir    string
+
+	zipPath string
+}
+
+func (a *localArchive) Ensure(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.zipPath != "" {
+		return nil
+	}
+
+	f, err := os.CreateTemp(a.tempDir, "local-archive-*.zip")
+	if err != nil {
+		return err
+	}
+	if err := writeDirZip(ctx, f, a.dir, a.pathInRepo); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return errors.Wrapf(err, "archiving %s", a.dir)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	a.zipPath = f.Name()
+	return nil
+}
+
+func (a *localArchive) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.zipPath == "" {
+		return nil
+	}
+	err := os.Remove(a.zipPath)
+	a.zipPath = ""
+	return err
+}
+
+func (a *localArchive) Path() string {
+	return a.zipPath
+}
+
+// AdditionalFilePaths returns the additionalWorkspaceFiles on the way up from
+// the workspace to the root of the directory, like the archives downloaded
+// from Sourcegraph do.
+func (a *localArchive) AdditionalFilePaths() map[string]string {
+	paths := map[string]string{}
+	if a.pathInRepo == "" {
+		return paths
+	}
+
+	var current string
+	for _, component := range strings.Split(a.pathInRepo, "/") {
+		for _, name := range additionalWorkspaceFiles {
+			filename := path.Join(current, name)
+			localPath := filepath.Join(a.dir, filepath.FromSlash(filename))
+			if fi, err := os.Stat(localPath); err == nil && fi.Mode().IsRegular() {
+				paths[filename] = localPath
+			}
+		}
+		current = path.Join(current, component)
+	}
+	return paths
+}
+
+// writeDirZip writes a ZIP archive of the files below pathInRepo in dir to w.
+// Git directories and files that aren't regular files are skipped.
+func writeDirZip(ctx context.Context, w io.Writer, dir, pathInRepo string) error {
+	zw := zip.NewWriter(w)
+
+	root := filepath.Join(dir, filepath.FromSlash(pathInRepo))
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		name := filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			_, err := zw.Create(name + "/")
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(fi)
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		header.Method = zip.Deflate
+
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(fw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
@@ -0,0 +1,150 @@
+package repozip
+
+import (
+	"archive/zip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type fakeRegistry struct {
+	checkouts []RepoRevision
+}
+
+func (r *fakeRegistry) Checkout(repo RepoRevision, path string) Archive {
+	r.checkouts = append(r.checkouts, repo)
+	return &localArchive{}
+}
+
+func (r *fakeRegistry) CacheStats() CacheStats { return CacheStats{} }
+
+func TestLocalArchiveRegistry(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"README.md":            "# README\n",
+		".gitignore":           "*.out\n",
+		"cmd/.gitattributes":   "* text=auto\n",
+		"cmd/src/main.go":      "package main\n",
+		"cmd/src/testdata/a":   "a\n",
+		"cmd/other/main.go":    "package main\n",
+		".git/HEAD":            "ref: refs/heads/main\n",
+		"cmd/src/.git/config":  "[core]\n",
+		"cmd/src/.hidden-file": "hidden\n",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	local := RepoRevision{RepoName: "github.com/example/local", Commit: "f00"}
+	fallback := &fakeRegistry{}
+	registry := NewLocalArchiveRegistry(map[RepoRevision]string{local: dir}, t.TempDir(), fallback)
+
+	remote := RepoRevision{RepoName: "github.com/example/remote", Commit: "b4r"}
+	registry.Checkout(remote, "")
+	if diff := cmp.Diff([]RepoRevision{remote}, fallback.checkouts); diff != "" {
+		t.Errorf("wrong fallback checkouts (-want +have):\n%s", diff)
+	}
+
+	ctx := context.Background()
+
+	t.Run("root", func(t *testing.T) {
+		archive := registry.Checkout(local, "")
+		if err := archive.Ensure(ctx); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]string{
+			".gitignore":           "*.out\n",
+			"README.md":            "# README\n",
+			"cmd/":                 "",
+			"cmd/.gitattributes":   "* text=auto\n",
+			"cmd/other/":           "",
+			"cmd/other/main.go":    "package main\n",
+			"cmd/src/":             "",
+			"cmd/src/.hidden-file": "hidden\n",
+			"cmd/src/main.go":      "package main\n",
+			"cmd/src/testdata/":    "",
+			"cmd/src/testdata/a":   "a\n",
+		}
+		if diff := cmp.Diff(want, readZip(t, archive.Path())); diff != "" {
+			t.Errorf("wrong archive (-want +have):\n%s", diff)
+		}
+		if diff := cmp.Diff(map[string]string{}, archive.AdditionalFilePaths()); diff != "" {
+			t.Errorf("wrong additional files (-want +have):\n%s", diff)
+		}
+
+		path := archive.Path()
+		if err := archive.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("archive not removed: %v", err)
+		}
+	})
+
+	t.Run("workspace", func(t *testing.T) {
+		archive := registry.Checkout(local, "cmd/src")
+		if err := archive.Ensure(ctx); err != nil {
+			t.Fatal(err)
+		}
+		defer archive.Close()
+
+		want := map[string]string{
+			"cmd/src/":             "",
+			"cmd/src/.hidden-file": "hidden\n",
+			"cmd/src/main.go":      "package main\n",
+			"cmd/src/testdata/":    "",
+			"cmd/src/testdata/a":   "a\n",
+		}
+		if diff := cmp.Diff(want, readZip(t, archive.Path())); diff != "" {
+			t.Errorf("wrong archive (-want +have):\n%s", diff)
+		}
+
+		wantFiles := map[string]string{
+			".gitignore":         filepath.Join(dir, ".gitignore"),
+			"cmd/.gitattributes": filepath.Join(dir, "cmd", ".gitattributes"),
+		}
+		if diff := cmp.Diff(wantFiles, archive.AdditionalFilePaths()); diff != "" {
+			t.Errorf("wrong additional files (-want +have):\n%s", diff)
+		}
+	})
+}
+
+func readZip(t *testing.T, path string) map[string]string {
+	t.Helper()
+
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	files := map[string]string{}
+	var names []string
+	for _, f := range r.File {
+		names = append(names, f.Name)
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(data)
+	}
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("entries not sorted: %v", names)
+	}
+	return files
+}
@@ -0,0 +1,55 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	// A Wednesday.
+	from := time.Date(2021, 10, 20, 12, 34, 56, 0, time.UTC)
+
+	for expr, want := range map[string]time.Time{
+		"* * * * *":      time.Date(2021, 10, 20, 12, 35, 0, 0, time.UTC),
+		"*/15 * * * *":   time.Date(2021, 10, 20, 12, 45, 0, 0, time.UTC),
+		"0 3 * * *":      time.Date(2021, 10, 21, 3, 0, 0, 0, time.UTC),
+		"0 3 * * 1":      time.Date(2021, 10, 25, 3, 0, 0, 0, time.UTC),
+		"0 3 * * 7":      time.Date(2021, 10, 24, 3, 0, 0, 0, time.UTC),
+		"30 9 1 * *":     time.Date(2021, 11, 1, 9, 30, 0, 0, time.UTC),
+		"0 0 1 1 *":      time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		"0 9-17/4 * * *": time.Date(2021, 10, 20, 13, 0, 0, 0, time.UTC),
+		"0 0 29 2 *":     time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		"0 0 1,15 * 5":   time.Date(2021, 10, 22, 0, 0, 0, 0, time.UTC),
+		"0 0 31 2 *":     {},
+	} {
+		t.Run(expr, func(t *testing.T) {
+			c, err := ParseCron(expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if have := c.Next(from); !have.Equal(want) {
+				t.Errorf("wrong next time: have=%s want=%s", have, want)
+			}
+		})
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("unexpected nil error for %q", expr)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/derision-test/glock"
+)
+
+// RunFunc executes a batch spec once. It reports whether the batch change was
+// applied, which is not the case if the changesets didn't change since the
+// previous run.
+type RunFunc func(ctx context.Context) (applied bool, err error)
+
+// Status describes the state of a Runner. It's served as JSON by the status
+// endpoint.
+type Status struct {
+	Schedule string    `json:"schedule"`
+	Running  bool      `json:"running"`
+	NextRun  time.Time `json:"nextRun"`
+	Runs     int       `json:"runs"`
+	Failures int       `json:"failures"`
+
+	LastRunStarted  *time.Time `json:"lastRunStarted,omitempty"`
+	LastRunFinished *time.Time `json:"lastRunFinished,omitempty"`
+	LastError       string     `json:"lastError,omitempty"`
+	LastApplied     *time.Time `json:"lastApplied,omitempty"`
+}
+
+// Runner invokes a RunFunc whenever its cron schedule is due. Runs never
+// overlap: if a run takes longer than the interval, the runs that would have
+// started in the meantime are skipped.
+type Runner struct {
+	cron  *Cron
+	run   RunFunc
+	clock glock.Clock
+
+	mu     sync.Mutex
+	status Status
+}
+
+// NewRunner returns a Runner that calls run on the given schedule.
+func NewRunner(cron *Cron, run RunFunc) *Runner {
+	return newRunner(cron, run, glock.NewRealClock())
+}
+
+func newRunner(cron *Cron, run RunFunc, clock glock.Clock) *Runner {
+	return &Runner{
+		cron:   cron,
+		run:    run,
+		clock:  clock,
+		status: Status{Schedule: cron.String()},
+	}
+}
+
+// Start runs the schedule until ctx is canceled. Errors of individual runs are
+// recorded in the status, but don't stop the schedule.
+func (r *Runner) Start(ctx context.Context) {
+	for {
+		next := r.cron.Next(r.clock.Now())
+		if next.IsZero() {
+			return
+		}
+		r.update(func(s *Status) { s.NextRun = next })
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.clock.After(next.Sub(r.clock.Now())):
+		}
+
+		r.runOnce(ctx)
+	}
+}
+
+func (r *Runner) runOnce(ctx context.Context) {
+	started := r.clock.Now()
+	r.update(func(s *Status) {
+		s.Running = true
+		s.LastRunStarted = &started
+	})
+
+	applied, err := r.run(ctx)
+
+	finished := r.clock.Now()
+	r.update(func(s *Status) {
+		s.Running = false
+		s.Runs++
+		s.LastRunFinished = &finished
+		s.LastError = ""
+		if err != nil {
+			s.Failures++
+			s.LastError = err.Error()
+		}
+		if applied {
+			s.LastApplied = &finished
+		}
+	})
+}
+
+func (r *Runner) update(f func(*Status)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f(&r.status)
+}
+
+// Status returns the current status of the runner.
+func (r *Runner) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// ServeHTTP serves the status of the runner as JSON.
+func (r *Runner) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(r.Status())
+}
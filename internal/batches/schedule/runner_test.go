@@ -0,0 +1,72 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/derision-test/glock"
+)
+
+func TestRunner(t *testing.T) {
+	cron, err := ParseCron("0 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock := glock.NewMockClockAt(time.Date(2021, 10, 20, 12, 30, 0, 0, time.UTC))
+	results := []error{nil, errors.New("boom")}
+	runs := make(chan struct{})
+	r := newRunner(cron, func(ctx context.Context) (bool, error) {
+		err := results[0]
+		results = results[1:]
+		runs <- struct{}{}
+		return err == nil, err
+	}, clock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Start(ctx)
+		close(done)
+	}()
+
+	clock.BlockingAdvance(30 * time.Minute)
+	<-runs
+	clock.BlockingAdvance(time.Hour)
+	<-runs
+
+	// Wait for the runner to block again, so that the status is final.
+	for clock.BlockedOnAfter() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	status := r.Status()
+	if status.Runs != 2 || status.Failures != 1 {
+		t.Errorf("wrong counts: runs=%d failures=%d", status.Runs, status.Failures)
+	}
+	if status.LastError != "boom" {
+		t.Errorf("wrong last error: %q", status.LastError)
+	}
+	if want := time.Date(2021, 10, 20, 13, 0, 0, 0, time.UTC); status.LastApplied == nil || !status.LastApplied.Equal(want) {
+		t.Errorf("wrong last applied time: %v", status.LastApplied)
+	}
+	if want := time.Date(2021, 10, 20, 15, 0, 0, 0, time.UTC); !status.NextRun.Equal(want) {
+		t.Errorf("wrong next run: %v", status.NextRun)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	var served Status
+	if err := json.NewDecoder(rec.Body).Decode(&served); err != nil {
+		t.Fatal(err)
+	}
+	if served.Schedule != "0 * * * *" || served.Runs != 2 {
+		t.Errorf("wrong served status: %+v", served)
+	}
+}
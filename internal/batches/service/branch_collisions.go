@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+)
+
+// BranchCollision is a changeset spec whose branch is already in use in its
+// repository, which makes publishing the changeset fail on the code host or
+// push to a branch that belongs to somebody else.
+type BranchCollision struct {
+	Spec *batcheslib.ChangesetSpec
+	Repo *graphql.Repository
+	// Branch is the branch of the spec, without the refs/heads/ prefix.
+	Branch string
+	// Exists is true if the branch exists in the repository and doesn't belong
+	// to a changeset of the batch change the spec is for.
+	Exists bool
+	// BatchChanges are the other batch changes, as namespace/name, that have
+	// a changeset on the branch.
+	BatchChanges []string
+	// Renamed is the branch the spec was moved to by SuffixBranches, if any.
+	Renamed string
+}
+
+func (c BranchCollision) String() string {
+	var reasons []string
+	if c.Exists {
+		reasons = append(reasons, "already exists")
+	}
+	if len(c.BatchChanges) > 0 {
+		reasons = append(reasons, fmt.Sprintf("is used by changesets of %s", strings.Join(c.BatchChanges, ", ")))
+	}
+	s := fmt.Sprintf("%s: branch %q %s", c.Repo.Name, c.Branch, strings.Join(reasons, " and "))
+	if c.Renamed != "" {
+		s += fmt.Sprintf(", renamed to %q", c.Renamed)
+	}
+	return s
+}
+
+// branchCheckParallelism is the number of branches checked at a time.
+const branchCheckParallelism = 8
+
+// maxBranchSuffix is the highest suffix SuffixBranches tries before giving up.
+const maxBranchSuffix = 100
+
+// CheckBranchCollisions looks up whether the branches of the given changeset
+// specs already exist in their repositories, or are used by changesets of
+// batch changes other than the one with the given name in the given
+// namespace. Branches that belong to changesets of that batch change aren't
+// collisions, since they were pushed by an earlier apply. Specs of imported
+// changesets are skipped.
+func (svc *Service) CheckBranchCollisions(ctx context.Context, namespace, name string, specs []*batcheslib.ChangesetSpec, repos []*graphql.Repository) ([]BranchCollision, error) {
+	byID := make(map[string]*graphql.Repository, len(repos))
+	for _, r := range repos {
+		byID[r.ID] = r
+	}
+
+	var checks []BranchCollision
+	for _, spec := range specs {
+		if spec.Type() == batcheslib.ChangesetSpecDescriptionTypeExisting {
+			continue
+		}
+		repo, ok := byID[spec.HeadRepository]
+		if !ok {
+			return nil, errors.Newf("unknown repository %q", spec.HeadRepository)
+		}
+		checks = append(checks, BranchCollision{
+			Spec:   spec,
+			Repo:   repo,
+			Branch: strings.TrimPrefix(spec.HeadRef, "refs/heads/"),
+		})
+	}
+
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, branchCheckParallelism)
+		errs = make([]error, len(checks))
+	)
+	for i := range checks {
+		wg.Add(1)
+		go func(c *BranchCollision, err *error) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			c.Exists, c.BatchChanges, *err = svc.branchUsage(ctx, namespace, name, c.Repo.ID, c.Branch)
+		}(&checks[i], &errs[i])
+	}
+	wg.Wait()
+
+	var collisions []BranchCollision
+	for i, c := range checks {
+		if errs[i] != nil {
+			return nil, errors.Wrapf(errs[i], "checking branch %q of %s", c.Branch, c.Repo.Name)
+		}
+		if c.Exists || len(c.BatchChanges) > 0 {
+			collisions = append(collisions, c)
+		}
+	}
+	return collisions, nil
+}
+
+// SuffixBranches moves the changeset specs of the given collisions to the
+// first branch named after their original branch with a numeric suffix, such
+// as my-branch-2, that is neither in use in the repository nor by another
+// changeset spec in specs. The specs are modified in place.
+func (svc *Service) SuffixBranches(ctx context.Context, namespace, name string, collisions []BranchCollision, specs []*batcheslib.ChangesetSpec) error {
+	taken := map[string]bool{}
+	for _, spec := range specs {
+		taken[spec.HeadRepository+"\x00"+strings.TrimPrefix(spec.HeadRef, "refs/heads/")] = true
+	}
+
+	for i := range collisions {
+		c := &collisions[i]
+		for n := 2; ; n++ {
+			if n > maxBranchSuffix {
+				return errors.Newf("no free branch found for %q in %s", c.Branch, c.Repo.Name)
+			}
+
+			branch := fmt.Sprintf("%s-%d", c.Branch, n)
+			if taken[c.Repo.ID+"\x00"+branch] {
+				continue
+			}
+			exists, batchChanges, err := svc.branchUsage(ctx, namespace, name, c.Repo.ID, branch)
+			if err != nil {
+				return errors.Wrapf(err, "checking branch %q of %s", branch, c.Repo.Name)
+			}
+			if exists || len(batchChanges) > 0 {
+				continue
+			}
+
+			taken[c.Repo.ID+"\x00"+branch] = true
+			c.Spec.HeadRef = "refs/heads/" + branch
+			c.Renamed = branch
+			break
+		}
+	}
+	return nil
+}
+
+const branchUsageQuery = `
+query BranchUsage($repo: ID!, $rev: String!) {
+    node(id: $repo) {
+        ... on Repository {
+            commit(rev: $rev) {
+                oid
+            }
+            batchChanges(first: 100) {
+                nodes {
+                    name
+                    namespace {
+                        id
+                        namespaceName
+                    }
+                    changesets(first: 100, repo: $repo) {
+                        nodes {
+                            __typename
+                            ... on ExternalChangeset {
+                                currentSpec {
+                                    description {
+                                        __typename
+                                        ... on GitBranchChangesetDescription {
+                                            headRef
+                                        }
+                                    }
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        }
+    }
+}
+`
+
+// branchUsage returns whether the branch exists in the repository without
+// belonging to a changeset of the batch change with the given name in the
+// given namespace, and the other batch changes that have a changeset on it.
+func (svc *Service) branchUsage(ctx context.Context, namespace, name, repoID, branch string) (bool, []string, error) {
+	var result struct {
+		Node *struct {
+			Commit       *struct{ OID string }
+			BatchChanges struct {
+				Nodes []struct {
+					Name      string
+					Namespace struct {
+						ID            string
+						NamespaceName string
+					}
+					Changesets struct {
+						Nodes []struct {
+							Typename    string `json:"__typename"`
+							CurrentSpec *struct {
+								Description struct {
+									Typename string `json:"__typename"`
+									HeadRef  string
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	if ok, err := svc.client.NewRequest(branchUsageQuery, map[string]interface{}{
+		"repo": repoID,
+		"rev":  "refs/heads/" + branch,
+	}).Do(ctx, &result); err != nil || !ok {
+		return false, nil, err
+	}
+	if result.Node == nil {
+		return false, nil, errors.New("no repository found")
+	}
+
+	var (
+		ours   bool
+		others []string
+	)
+	for _, bc := range result.Node.BatchChanges.Nodes {
+		for _, cs := range bc.Changesets.Nodes {
+			if cs.Typename != "ExternalChangeset" || cs.CurrentSpec == nil {
+				continue
+			}
+			if strings.TrimPrefix(cs.CurrentSpec.Description.HeadRef, "refs/heads/") != branch {
+				continue
+			}
+			if bc.Namespace.ID == namespace && bc.Name == name {
+				ours = true
+			} else {
+				others = append(others, bc.Namespace.NamespaceName+"/"+bc.Name)
+			}
+			break
+		}
+	}
+	sort.Strings(others)
+
+	exists := result.Node.Commit != nil && result.Node.Commit.OID != "" && !ours
+	return exists, others, nil
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+)
+
+func TestService_CheckBranchCollisions(t *testing.T) {
+	// The branches that exist in each repository.
+	branches := map[string][]string{
+		"repo-1": {"refs/heads/fix"},
+		"repo-2": {"refs/heads/fix", "refs/heads/fix-2"},
+		"repo-3": {},
+	}
+	// The batch changes with changesets in each repository, and their
+	// branches.
+	batchChanges := map[string]string{
+		"repo-1": `[{"name":"ours","namespace":{"id":"ns-1","namespaceName":"alice"},"changesets":{"nodes":[{"__typename":"ExternalChangeset","currentSpec":{"description":{"__typename":"GitBranchChangesetDescription","headRef":"fix"}}}]}}]`,
+		"repo-2": `[]`,
+		"repo-3": `[{"name":"cleanup","namespace":{"id":"ns-2","namespaceName":"bob"},"changesets":{"nodes":[{"__typename":"ExternalChangeset","currentSpec":{"description":{"__typename":"GitBranchChangesetDescription","headRef":"fix"}}}]}}]`,
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Variables struct {
+				Repo string
+				Rev  string
+			}
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		commit := "null"
+		for _, b := range branches[req.Variables.Repo] {
+			if b == req.Variables.Rev {
+				commit = `{"oid":"deadbeef"}`
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"data":{"node":{"commit":%s,"batchChanges":{"nodes":%s}}}}`, commit, batchChanges[req.Variables.Repo])
+	}))
+	defer ts.Close()
+
+	svc := &Service{client: api.NewClient(api.ClientOpts{Endpoint: ts.URL, Out: &bytes.Buffer{}})}
+
+	repos := []*graphql.Repository{
+		{ID: "repo-1", Name: "github.com/a/one"},
+		{ID: "repo-2", Name: "github.com/a/two"},
+		{ID: "repo-3", Name: "github.com/a/three"},
+	}
+	var specs []*batcheslib.ChangesetSpec
+	for _, r := range repos {
+		specs = append(specs, &batcheslib.ChangesetSpec{
+			BaseRepository: r.ID,
+			HeadRepository: r.ID,
+			HeadRef:        "refs/heads/fix",
+		})
+	}
+	specs = append(specs, &batcheslib.ChangesetSpec{BaseRepository: "repo-1", ExternalID: "1"})
+
+	collisions, err := svc.CheckBranchCollisions(context.Background(), "ns-1", "ours", specs, repos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var have []string
+	for _, c := range collisions {
+		have = append(have, c.String())
+	}
+	want := []string{
+		`github.com/a/two: branch "fix" already exists`,
+		`github.com/a/three: branch "fix" is used by changesets of bob/cleanup`,
+	}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Fatalf("wrong collisions (-want +have):\n%s", diff)
+	}
+
+	if err := svc.SuffixBranches(context.Background(), "ns-1", "ours", collisions, specs); err != nil {
+		t.Fatal(err)
+	}
+	var refs []string
+	for _, spec := range specs[:3] {
+		refs = append(refs, spec.HeadRef)
+	}
+	if diff := cmp.Diff([]string{"refs/heads/fix", "refs/heads/fix-3", "refs/heads/fix-2"}, refs); diff != "" {
+		t.Errorf("wrong branches (-want +have):\n%s", diff)
+	}
+	if have, want := collisions[0].String(), `github.com/a/two: branch "fix" already exists, renamed to "fix-3"`; have != want {
+		t.Errorf("wrong collision: want %q, have %q", want, have)
+	}
+}
@@ -10,7 +10,9 @@ import (
 )
 
 // buildTasks returns *executor.Tasks for all the workspaces determined for the given spec.
-func buildTasks(ctx context.Context, spec *batcheslib.BatchSpec, workspaces []RepoWorkspace) []*executor.Task {
+// If commitPerStep is true, the tasks record the diff of each step.
+// cacheKeyPaths are the cacheKeyPaths of the steps, by index.
+func buildTasks(ctx context.Context, spec *batcheslib.BatchSpec, workspaces []RepoWorkspace, commitPerStep bool, environments map[int]executor.StepEnvironment, cacheKeyPaths map[int][]string) []*executor.Task {
 	tasks := make([]*executor.Task, 0, len(workspaces))
 
 	for _, ws := range workspaces {
@@ -18,7 +20,11 @@ func buildTasks(ctx context.Context, spec *batcheslib.BatchSpec, workspaces []Re
 			Repository:         ws.Repo,
 			Path:               ws.Path,
 			Steps:              ws.Steps,
+			StepIndexes:        ws.StepIndexes,
 			OnlyFetchWorkspace: ws.OnlyFetchWorkspace,
+			CommitPerStep:      commitPerStep,
+			Environments:       environments,
+			CacheKeyPaths:      cacheKeyPaths,
 
 			TransformChanges: spec.TransformChanges,
 			Template:         spec.ChangesetTemplate,
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"path"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gobwas/glob"
+	"gopkg.in/yaml.v3"
+)
+
+// resolveStepCacheKeyPaths removes the `cacheKeyPaths:` fields of the steps
+// from the batch spec:
+//
+//	steps:
+//	  - run: gofmt -w .
+//	    container: golang:1.17
+//	    cacheKeyPaths:
+//	      - "**/*.go"
+//
+// The cached result of a step is usually only used for the same revision of
+// the repository. With cacheKeyPaths, it's used as long as the content of the
+// files matching the glob patterns is the same, so that changes to other files
+// don't execute the step again. The patterns are relative to the workspace,
+// * doesn't match slashes, and ** matches any number of directories. They must
+// match all the files that the step reads and changes: the cached diff is
+// applied to the files as they are.
+//
+// As steps build on the ones before them, this only applies to a step if all
+// the steps before it set cacheKeyPaths too, and the cache key of a step is
+// based on the files matching the patterns of all of them.
+//
+// The patterns are remembered by the Service and added to the tasks it builds.
+func (svc *Service) resolveStepCacheKeyPaths(spec *yaml.Node) (modified bool, err error) {
+	err = forEachStep(spec, func(i int, step *yaml.Node) error {
+		j := mappingIndex(step, "cacheKeyPaths")
+		if j < 0 {
+			return nil
+		}
+		var patterns []string
+		if err := step.Content[j+1].Decode(&patterns); err != nil || len(patterns) == 0 {
+			return errors.Newf("step %d: cacheKeyPaths must be a non-empty list of glob patterns", i+1)
+		}
+		for _, p := range patterns {
+			if err := validateCacheKeyPath(p); err != nil {
+				return errors.Wrapf(err, "step %d", i+1)
+			}
+		}
+		if svc.stepCacheKeyPaths == nil {
+			svc.stepCacheKeyPaths = map[int][]string{}
+		}
+		svc.stepCacheKeyPaths[i] = patterns
+		removeMappingKey(step, j)
+		modified = true
+		return nil
+	})
+	return modified, err
+}
+
+// validateCacheKeyPath returns an error if the pattern isn't a valid glob
+// pattern relative to the workspace.
+func validateCacheKeyPath(pattern string) error {
+	if pattern == "" || path.IsAbs(pattern) {
+		return errors.Newf("invalid cacheKeyPaths pattern %q: must be relative to the workspace", pattern)
+	}
+	for _, elem := range strings.Split(pattern, "/") {
+		if elem == ".." {
+			return errors.Newf("invalid cacheKeyPaths pattern %q: must not leave the workspace", pattern)
+		}
+	}
+	if _, err := glob.Compile(pattern, '/'); err != nil {
+		return errors.Wrapf(err, "invalid cacheKeyPaths pattern %q", pattern)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestResolveStepCacheKeyPaths(t *testing.T) {
+	tests := map[string]specPassTest{
+		"unchanged": {
+			spec: plainSpec,
+			want: plainSpec,
+			check: func(t *testing.T, svc *Service, _ []byte) {
+				if svc.stepCacheKeyPaths != nil {
+					t.Errorf("cache key paths configured: %v", svc.stepCacheKeyPaths)
+				}
+			},
+		},
+		"configured": {
+			spec: `name: test
+steps:
+  - run: gofmt -w .
+    container: golang:1.17
+    cacheKeyPaths:
+      - "**/*.go"
+  - run: ./notify.sh
+    container: alpine:3
+`,
+			want: `name: test
+steps:
+  - run: gofmt -w .
+    container: golang:1.17
+  - run: ./notify.sh
+    container: alpine:3
+`,
+			check: func(t *testing.T, svc *Service, _ []byte) {
+				if diff := cmp.Diff(map[int][]string{0: {"**/*.go"}}, svc.stepCacheKeyPaths); diff != "" {
+					t.Errorf("wrong cache key paths (-want +have):\n%s", diff)
+				}
+			},
+		},
+	}
+	for name, paths := range map[string]string{
+		"empty":       "[]",
+		"not a list":  "true",
+		"absolute":    "[/etc/passwd]",
+		"parent":      "[../*.go]",
+		"invalid":     "['[a']",
+		"empty value": "['']",
+	} {
+		tests[name] = specPassTest{
+			spec:    "steps:\n  - run: echo\n    container: alpine:3\n    cacheKeyPaths: " + paths + "\n",
+			wantErr: []string{"step 1"},
+		}
+	}
+	runSpecPassTests(t, func(svc *Service) specPass { return svc.resolveStepCacheKeyPaths }, tests)
+}
@@ -0,0 +1,366 @@
+package service
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gobwas/glob"
+	"gopkg.in/yaml.v3"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+)
+
+// changesetDependencyRule is a rule of the `changesetDependencies:` field of a
+// batch spec. See resolveChangesetDependencies.
+type changesetDependencyRule struct {
+	// Repository is a glob pattern matching the names of the repositories
+	// whose changesets depend on others.
+	Repository string `yaml:"repository"`
+	// DependsOn are glob patterns matching the names of the repositories
+	// whose changesets must be merged first.
+	DependsOn []string `yaml:"dependsOn"`
+
+	repository glob.Glob
+	dependsOn  []glob.Glob
+}
+
+// resolveChangesetDependencies removes the `changesetDependencies:` field from
+// the batch spec, which declares the order that the changesets must
+// be merged in, such as a library before the repositories that use it:
+//
+//	changesetDependencies:
+//	  - repository: github.com/sourcegraph/*-service
+//	    dependsOn:
+//	      - github.com/sourcegraph/library
+//
+// Both fields take glob patterns matching repository names. The rules are
+// validated and remembered by the Service, which records the dependencies of
+// each changeset in its body with AddChangesetDependencies, so that `src batch
+// changesets merge -in-order` can read them back.
+func (svc *Service) resolveChangesetDependencies(spec *yaml.Node) (modified bool, err error) {
+	idx := mappingIndex(spec, "changesetDependencies")
+	if idx < 0 {
+		return false, nil
+	}
+	node := spec.Content[idx+1]
+	if node.Kind != yaml.SequenceNode {
+		return false, errors.New("changesetDependencies must be a list of rules")
+	}
+
+	rules := make([]*changesetDependencyRule, 0, len(node.Content))
+	for i, item := range node.Content {
+		var rule changesetDependencyRule
+		if err := decodeStrict(item, &rule); err != nil {
+			return false, errors.Wrapf(err, "changesetDependencies[%d]", i)
+		}
+		if err := rule.compile(); err != nil {
+			return false, errors.Wrapf(err, "changesetDependencies[%d]", i)
+		}
+		rules = append(rules, &rule)
+	}
+	svc.changesetDependencies = rules
+	removeMappingKey(spec, idx)
+	return true, nil
+}
+
+func (r *changesetDependencyRule) compile() (err error) {
+	if r.Repository == "" {
+		return errors.New("repository must be set")
+	}
+	if len(r.DependsOn) == 0 {
+		return errors.New("dependsOn must not be empty")
+	}
+	if r.repository, err = glob.Compile(r.Repository); err != nil {
+		return errors.Wrapf(err, "invalid repository pattern %q", r.Repository)
+	}
+	for _, pattern := range r.DependsOn {
+		g, err := glob.Compile(pattern)
+		if err != nil {
+			return errors.Wrapf(err, "invalid dependsOn pattern %q", pattern)
+		}
+		r.dependsOn = append(r.dependsOn, g)
+	}
+	return nil
+}
+
+// AddChangesetDependencies records the repositories whose changesets each of
+// the given changeset specs depends on, according to the rules of the batch
+// spec, at the end of its body. Only repositories with changeset specs are
+// dependencies: the others have nothing left to merge first. A repository
+// doesn't depend on itself. Specs of imported changesets are skipped, as
+// their body can't be changed.
+//
+// An error is returned if the dependencies form a cycle, since then the
+// changesets can't be merged in order.
+func (svc *Service) AddChangesetDependencies(repos []*graphql.Repository, specs []*batcheslib.ChangesetSpec) error {
+	if len(svc.changesetDependencies) == 0 {
+		return nil
+	}
+
+	names := make(map[string]string, len(repos))
+	for _, r := range repos {
+		names[r.ID] = r.Name
+	}
+
+	var withSpecs []string
+	seen := map[string]bool{}
+	for _, spec := range specs {
+		name := names[spec.BaseRepository]
+		if name != "" && !seen[name] {
+			seen[name] = true
+			withSpecs = append(withSpecs, name)
+		}
+	}
+	sort.Strings(withSpecs)
+
+	deps := make(map[string][]string, len(withSpecs))
+	for _, name := range withSpecs {
+		var dependsOn []string
+		for _, dep := range withSpecs {
+			if dep != name && svc.dependsOn(name, dep) {
+				dependsOn = append(dependsOn, dep)
+			}
+		}
+		if len(dependsOn) > 0 {
+			deps[name] = dependsOn
+		}
+	}
+	if cycle := dependencyCycle(deps); cycle != nil {
+		return errors.Newf("changesetDependencies form a cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	for _, spec := range specs {
+		if spec.Type() == batcheslib.ChangesetSpecDescriptionTypeExisting {
+			continue
+		}
+		spec.Body = withChangesetDependencies(spec.Body, deps[names[spec.BaseRepository]])
+	}
+	return nil
+}
+
+// dependsOn returns whether a rule makes the changesets of the repository name
+// depend on the ones of the repository dep.
+func (svc *Service) dependsOn(name, dep string) bool {
+	for _, rule := range svc.changesetDependencies {
+		if !rule.repository.Match(name) {
+			continue
+		}
+		for _, g := range rule.dependsOn {
+			if g.Match(dep) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dependencyCycle returns a cycle of repositories in deps, which maps
+// repositories to the ones they depend on, starting and ending with the same
+// repository, or nil if there is none.
+func dependencyCycle(deps map[string][]string) []string {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	var path []string
+
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			for i, n := range path {
+				if n == name {
+					return append(append([]string{}, path[i:]...), name)
+				}
+			}
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range deps[name] {
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if cycle := visit(name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+var (
+	// changesetDependenciesSection matches the dependencies recorded at the
+	// end of the body of a changeset, which are listed in an HTML comment for
+	// src, and on the next line for the reviewers.
+	changesetDependenciesSection = regexp.MustCompile(`\s*<!-- src-batch-depends-on: [^>]* -->\r?\n[^\n]*\s*$`)
+	// changesetDependenciesMarker matches the HTML comment of the recorded
+	// dependencies anywhere in the body, since code hosts may add to it.
+	changesetDependenciesMarker = regexp.MustCompile(`<!-- src-batch-depends-on: ([^>]*) -->`)
+)
+
+// withChangesetDependencies returns the body of a changeset with the given
+// dependencies recorded at its end, replacing the ones recorded before.
+func withChangesetDependencies(body string, deps []string) string {
+	body = changesetDependenciesSection.ReplaceAllString(body, "")
+	if len(deps) == 0 {
+		return body
+	}
+	if body = strings.TrimRight(body, " \r\n"); body != "" {
+		body += "\n\n"
+	}
+	return body + "<!-- src-batch-depends-on: " + strings.Join(deps, " ") + " -->\n" +
+		"Merge after the changesets in: " + strings.Join(deps, ", ") + "\n"
+}
+
+// parseChangesetDependencies returns the repositories whose changesets must be
+// merged before the changeset with the given body, as recorded by
+// AddChangesetDependencies.
+func parseChangesetDependencies(body string) []string {
+	m := changesetDependenciesMarker.FindStringSubmatch(body)
+	if m == nil {
+		return nil
+	}
+	return strings.Fields(m[1])
+}
+
+// MergeWave is a set of changesets that can be merged at the same time, once
+// the changesets of the waves before have been merged.
+type MergeWave []Changeset
+
+// BlockedChangeset is a changeset that can't be merged, because changesets it
+// depends on can't be merged.
+type BlockedChangeset struct {
+	Changeset
+	// Reasons are the repositories of the changesets that block it, with
+	// their state.
+	Reasons []string
+}
+
+// MergeOrder returns the open changesets of a batch change in the order they
+// must be merged in, according to their dependencies, as waves of changesets
+// that can be merged at the same time. Merged changesets are satisfied
+// dependencies. The changesets that depend on changesets that aren't open or
+// merged, such as closed or unpublished ones, can't be merged and are returned
+// as blocked, together with the changesets that depend on them in turn.
+//
+// An error is returned if the dependencies form a cycle.
+func MergeOrder(changesets []Changeset) ([]MergeWave, []BlockedChangeset, error) {
+	deps := map[string][]string{}
+	byRepo := map[string][]Changeset{}
+	for _, cs := range changesets {
+		byRepo[cs.Repository] = append(byRepo[cs.Repository], cs)
+		deps[cs.Repository] = append(deps[cs.Repository], cs.DependsOn...)
+	}
+	if cycle := dependencyCycle(deps); cycle != nil {
+		return nil, nil, errors.Newf("the changeset dependencies form a cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	// mergeable is true for the repositories whose changesets are all open or
+	// merged, and false for those with changesets in other states.
+	mergeable := map[string]bool{}
+	for repo, css := range byRepo {
+		mergeable[repo] = true
+		for _, cs := range css {
+			if cs.State != "OPEN" && cs.State != "MERGED" {
+				mergeable[repo] = false
+			}
+		}
+	}
+	// blockers returns the reasons that the changesets of the repository can't
+	// be merged, following the dependencies.
+	var blockers func(repo string) []string
+	blockers = func(repo string) []string {
+		var reasons []string
+		for _, dep := range deps[repo] {
+			if _, ok := byRepo[dep]; !ok {
+				// Without changesets, there's nothing to merge first.
+				continue
+			}
+			if !mergeable[dep] {
+				for _, cs := range byRepo[dep] {
+					if cs.State != "OPEN" && cs.State != "MERGED" {
+						reasons = append(reasons, dep+" is "+strings.ToLower(cs.State))
+						break
+					}
+				}
+			} else if len(blockers(dep)) > 0 {
+				reasons = append(reasons, dep+" is blocked")
+			}
+		}
+		return reasons
+	}
+
+	var (
+		blocked []BlockedChangeset
+		pending []Changeset
+	)
+	for _, cs := range changesets {
+		if cs.State != "OPEN" {
+			continue
+		}
+		if reasons := blockers(cs.Repository); len(reasons) > 0 {
+			blocked = append(blocked, BlockedChangeset{Changeset: cs, Reasons: reasons})
+			continue
+		}
+		pending = append(pending, cs)
+	}
+
+	// The open changesets are merged in waves, each with the changesets whose
+	// dependencies are merged by the waves before.
+	merged := map[string]bool{}
+	for repo, css := range byRepo {
+		merged[repo] = true
+		for _, cs := range css {
+			if cs.State != "MERGED" {
+				merged[repo] = false
+			}
+		}
+	}
+	var waves []MergeWave
+	for len(pending) > 0 {
+		var wave MergeWave
+		var rest []Changeset
+		for _, cs := range pending {
+			ready := true
+			for _, dep := range deps[cs.Repository] {
+				if _, ok := byRepo[dep]; ok && !merged[dep] {
+					ready = false
+				}
+			}
+			if ready {
+				wave = append(wave, cs)
+			} else {
+				rest = append(rest, cs)
+			}
+		}
+		if len(wave) == 0 {
+			return nil, nil, errors.New("the changeset dependencies can't be satisfied")
+		}
+		// All open changesets of a repository are in the same wave, since
+		// they have the same dependencies.
+		for _, cs := range wave {
+			merged[cs.Repository] = true
+		}
+		waves = append(waves, wave)
+		pending = rest
+	}
+	return waves, blocked, nil
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+)
+
+func TestResolveChangesetDependencies(t *testing.T) {
+	tests := map[string]specPassTest{
+		"unchanged": {
+			spec: plainSpec,
+			want: plainSpec,
+			check: func(t *testing.T, svc *Service, _ []byte) {
+				if svc.changesetDependencies != nil {
+					t.Errorf("dependencies configured: %v", svc.changesetDependencies)
+				}
+			},
+		},
+		"configured": {
+			spec: `name: test
+changesetDependencies:
+  - repository: github.com/sourcegraph/*-service
+    dependsOn:
+      - github.com/sourcegraph/library
+changesetTemplate:
+  title: Hello World
+`,
+			want: "name: test\nchangesetTemplate:\n  title: Hello World\n",
+			check: func(t *testing.T, svc *Service, _ []byte) {
+				if len(svc.changesetDependencies) != 1 {
+					t.Fatalf("wrong number of rules %d", len(svc.changesetDependencies))
+				}
+				if !svc.dependsOn("github.com/sourcegraph/search-service", "github.com/sourcegraph/library") {
+					t.Error("service doesn't depend on library")
+				}
+				if svc.dependsOn("github.com/sourcegraph/library", "github.com/sourcegraph/search-service") {
+					t.Error("library depends on service")
+				}
+			},
+		},
+	}
+	for name, spec := range map[string]string{
+		"not a list":        "changesetDependencies: github.com/sourcegraph/library\n",
+		"no repository":     "changesetDependencies:\n  - dependsOn: [github.com/sourcegraph/library]\n",
+		"no dependencies":   "changesetDependencies:\n  - repository: github.com/sourcegraph/service\n",
+		"unknown field":     "changesetDependencies:\n  - repository: a\n    dependsOn: [b]\n    after: [c]\n",
+		"invalid pattern":   "changesetDependencies:\n  - repository: 'github.com/[a'\n    dependsOn: [b]\n",
+		"invalid dependsOn": "changesetDependencies:\n  - repository: a\n    dependsOn: ['github.com/[a']\n",
+	} {
+		tests[name] = specPassTest{spec: spec, wantErr: anyError}
+	}
+	runSpecPassTests(t, func(svc *Service) specPass { return svc.resolveChangesetDependencies }, tests)
+}
+
+func TestAddChangesetDependencies(t *testing.T) {
+	svc := &Service{}
+	if _, err := resolveSpec([]byte(`changesetDependencies:
+  - repository: github.com/sourcegraph/*
+    dependsOn:
+      - github.com/sourcegraph/library
+      - github.com/sourcegraph/unchanged
+`), svc.resolveChangesetDependencies); err != nil {
+		t.Fatal(err)
+	}
+
+	repos := []*graphql.Repository{
+		{ID: "repo-1", Name: "github.com/sourcegraph/library"},
+		{ID: "repo-2", Name: "github.com/sourcegraph/service"},
+		{ID: "repo-3", Name: "github.com/sourcegraph/unchanged"},
+	}
+	specs := []*batcheslib.ChangesetSpec{
+		{BaseRepository: "repo-1", HeadRepository: "repo-1", HeadRef: "refs/heads/fix", Body: "Update library"},
+		{BaseRepository: "repo-2", HeadRepository: "repo-2", HeadRef: "refs/heads/fix", Body: "Update service"},
+	}
+	if err := svc.AddChangesetDependencies(repos, specs); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff("Update library", specs[0].Body); diff != "" {
+		t.Errorf("wrong library body (-want +have):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"github.com/sourcegraph/library"}, parseChangesetDependencies(specs[1].Body)); diff != "" {
+		t.Errorf("wrong service dependencies (-want +have):\n%s", diff)
+	}
+
+	// Adding the dependencies again replaces them.
+	body := specs[1].Body
+	if err := svc.AddChangesetDependencies(repos, specs); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(body, specs[1].Body); diff != "" {
+		t.Errorf("dependencies added twice (-want +have):\n%s", diff)
+	}
+}
+
+func TestChangesetDependenciesBody(t *testing.T) {
+	body := withChangesetDependencies("Update the library.\n", []string{"github.com/a/lib", "github.com/b/lib"})
+	want := "Update the library.\n\n<!-- src-batch-depends-on: github.com/a/lib github.com/b/lib -->\nMerge after the changesets in: github.com/a/lib, github.com/b/lib\n"
+	if diff := cmp.Diff(want, body); diff != "" {
+		t.Errorf("wrong body (-want +have):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"github.com/a/lib", "github.com/b/lib"}, parseChangesetDependencies(body)); diff != "" {
+		t.Errorf("wrong dependencies (-want +have):\n%s", diff)
+	}
+
+	// Code hosts may change the line endings and add to the body.
+	if diff := cmp.Diff([]string{"github.com/a/lib"}, parseChangesetDependencies("Hi\r\n\r\n<!-- src-batch-depends-on: github.com/a/lib -->\r\nMerge after\r\n\r\nSigned-off-by: bot")); diff != "" {
+		t.Errorf("wrong dependencies (-want +have):\n%s", diff)
+	}
+
+	if diff := cmp.Diff("Update the library.", withChangesetDependencies(body, nil)); diff != "" {
+		t.Errorf("dependencies not removed (-want +have):\n%s", diff)
+	}
+	if deps := parseChangesetDependencies("No dependencies."); deps != nil {
+		t.Errorf("unexpected dependencies %v", deps)
+	}
+}
+
+func TestDependencyCycle(t *testing.T) {
+	if cycle := dependencyCycle(map[string][]string{"a": {"b", "c"}, "b": {"c"}}); cycle != nil {
+		t.Errorf("unexpected cycle %v", cycle)
+	}
+	have := dependencyCycle(map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}})
+	if diff := cmp.Diff([]string{"a", "b", "c", "a"}, have); diff != "" {
+		t.Errorf("wrong cycle (-want +have):\n%s", diff)
+	}
+}
+
+func TestMergeOrder(t *testing.T) {
+	changesets := []Changeset{
+		{ID: "1", Repository: "library", State: "OPEN"},
+		{ID: "2", Repository: "service", State: "OPEN", DependsOn: []string{"library"}},
+		{ID: "3", Repository: "frontend", State: "OPEN", DependsOn: []string{"service", "library"}},
+		{ID: "4", Repository: "tool", State: "OPEN", DependsOn: []string{"merged", "gone"}},
+		{ID: "5", Repository: "merged", State: "MERGED"},
+		{ID: "6", Repository: "plugin", State: "CLOSED"},
+		{ID: "7", Repository: "extension", State: "OPEN", DependsOn: []string{"plugin"}},
+		{ID: "8", Repository: "addon", State: "OPEN", DependsOn: []string{"extension"}},
+	}
+
+	waves, blocked, err := MergeOrder(changesets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var haveWaves [][]string
+	for _, wave := range waves {
+		var ids []string
+		for _, cs := range wave {
+			ids = append(ids, cs.ID)
+		}
+		haveWaves = append(haveWaves, ids)
+	}
+	if diff := cmp.Diff([][]string{{"1", "4"}, {"2"}, {"3"}}, haveWaves); diff != "" {
+		t.Errorf("wrong waves (-want +have):\n%s", diff)
+	}
+
+	haveBlocked := map[string][]string{}
+	for _, cs := range blocked {
+		haveBlocked[cs.ID] = cs.Reasons
+	}
+	wantBlocked := map[string][]string{
+		"7": {"plugin is closed"},
+		"8": {"extension is blocked"},
+	}
+	if diff := cmp.Diff(wantBlocked, haveBlocked); diff != "" {
+		t.Errorf("wrong blocked changesets (-want +have):\n%s", diff)
+	}
+
+	_, _, err = MergeOrder([]Changeset{
+		{ID: "1", Repository: "a", State: "OPEN", DependsOn: []string{"b"}},
+		{ID: "2", Repository: "b", State: "OPEN", DependsOn: []string{"a"}},
+	})
+	if err == nil {
+		t.Error("no error returned for a cycle")
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/cockroachdb/errors"
+	humanize "github.com/dustin/go-humanize"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+)
+
+// ChangesetSpecSizeWarning is the size of a changeset spec above which it's
+// warned about before uploading. It's the default limit of request bodies of
+// many reverse proxies in front of Sourcegraph instances, such as NGINX's
+// client_max_body_size, which reject larger requests with "413 Request Entity
+// Too Large".
+const ChangesetSpecSizeWarning = 1 << 20
+
+// LargeChangesetSpec is a changeset spec whose upload is larger than a limit.
+type LargeChangesetSpec struct {
+	Spec *batcheslib.ChangesetSpec
+	// Repo is the name of the repository of the spec.
+	Repo string
+	// Size is the size in bytes of the spec in the request that uploads it.
+	Size int
+}
+
+func (s LargeChangesetSpec) String() string {
+	return fmt.Sprintf("%s: %s", s.Repo, humanize.Bytes(uint64(s.Size)))
+}
+
+// LargeChangesetSpecs returns the changeset specs whose upload is larger than
+// limit bytes, largest first, so that an upload the instance would reject can
+// be caught before it's attempted.
+func (svc *Service) LargeChangesetSpecs(repos []*graphql.Repository, specs []*batcheslib.ChangesetSpec, limit int) ([]LargeChangesetSpec, error) {
+	repoNames := make(map[string]string, len(repos))
+	for _, repo := range repos {
+		repoNames[repo.ID] = repo.Name
+	}
+
+	var large []LargeChangesetSpec
+	for _, spec := range specs {
+		size, err := svc.changesetSpecUploadSize(spec)
+		if err != nil {
+			return nil, err
+		}
+		if size <= limit {
+			continue
+		}
+
+		name, ok := repoNames[spec.BaseRepository]
+		if !ok {
+			name = spec.BaseRepository
+		}
+		large = append(large, LargeChangesetSpec{Spec: spec, Repo: name, Size: size})
+	}
+
+	sort.SliceStable(large, func(i, j int) bool { return large[i].Size > large[j].Size })
+	return large, nil
+}
+
+// changesetSpecUploadSize returns the size of the changeset spec as it's sent
+// by CreateChangesetSpec: serialized to JSON, with the code host options, and
+// encoded again as a string variable of the GraphQL request, which escapes the
+// quotes and newlines of the diff.
+func (svc *Service) changesetSpecUploadSize(spec *batcheslib.ChangesetSpec) (int, error) {
+	raw, err := json.Marshal(spec)
+	if err != nil {
+		return 0, errors.Wrap(err, "marshalling changeset spec JSON")
+	}
+	raw, _, err = svc.codeHostOptions.withCodeHostOptions(raw, svc.repoServiceTypes[spec.BaseRepository])
+	if err != nil {
+		return 0, errors.Wrap(err, "adding code host options to changeset spec JSON")
+	}
+
+	variable, err := json.Marshal(string(raw))
+	if err != nil {
+		return 0, err
+	}
+	return len(createChangesetSpecMutation) + len(variable), nil
+}
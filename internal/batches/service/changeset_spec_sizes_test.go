@@ -0,0 +1,63 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+)
+
+func TestService_LargeChangesetSpecs(t *testing.T) {
+	repos := []*graphql.Repository{
+		{ID: "repo-1", Name: "github.com/a/small"},
+		{ID: "repo-2", Name: "github.com/a/large"},
+		{ID: "repo-3", Name: "github.com/a/larger"},
+	}
+	specWithDiff := func(repo, diff string) *batcheslib.ChangesetSpec {
+		return &batcheslib.ChangesetSpec{
+			BaseRepository: repo,
+			HeadRepository: repo,
+			HeadRef:        "refs/heads/fix",
+			Commits:        []batcheslib.GitCommitDescription{{Message: "fix", Diff: diff}},
+		}
+	}
+	specs := []*batcheslib.ChangesetSpec{
+		specWithDiff("repo-1", "+small\n"),
+		specWithDiff("repo-2", strings.Repeat("+line\n", 2000)),
+		specWithDiff("repo-3", strings.Repeat("+line\n", 4000)),
+		// Specs of repositories that weren't resolved are named by their ID.
+		specWithDiff("repo-4", strings.Repeat("+line\n", 3000)),
+	}
+
+	svc := &Service{}
+	large, err := svc.LargeChangesetSpecs(repos, specs, 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var have []string
+	for _, l := range large {
+		have = append(have, l.Repo)
+		if l.Size <= 10000 {
+			t.Errorf("spec of %s isn't larger than the limit: %d", l.Repo, l.Size)
+		}
+	}
+	want := []string{"github.com/a/larger", "repo-4", "github.com/a/large"}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Errorf("wrong large specs (-want +have):\n%s", diff)
+	}
+
+	// The newlines of the diff are escaped twice in the request, which makes
+	// each "+line\n" 8 bytes long.
+	if size := large[0].Size; size < 4000*8 {
+		t.Errorf("upload size %d doesn't account for escaping", size)
+	}
+
+	if large, err := svc.LargeChangesetSpecs(repos, specs, ChangesetSpecSizeWarning); err != nil || len(large) != 0 {
+		t.Errorf("want no large specs, have %v, %v", large, err)
+	}
+}
@@ -0,0 +1,283 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/hashicorp/go-multierror"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+// changesetSpecUploadTTL is how long an uploaded changeset spec is reused.
+// Sourcegraph deletes changeset specs that aren't attached to a batch spec
+// after a while, so older uploads are sent again.
+const changesetSpecUploadTTL = 24 * time.Hour
+
+// ChangesetSpecUploads is a journal of the changeset specs that were uploaded
+// to a Sourcegraph instance. It's appended to after every upload, so that a
+// failed or interrupted upload can be resumed by only sending the changeset
+// specs that are missing.
+type ChangesetSpecUploads struct {
+	path     string
+	endpoint string
+
+	mu      sync.Mutex
+	uploads map[string]changesetSpecUpload
+}
+
+type changesetSpecUpload struct {
+	Key        string                  `json:"key"`
+	ID         graphql.ChangesetSpecID `json:"id"`
+	UploadedAt time.Time               `json:"uploadedAt"`
+}
+
+// OpenChangesetSpecUploads reads the journal at path for the given endpoint.
+// The journal doesn't have to exist yet.
+func OpenChangesetSpecUploads(path, endpoint string) (*ChangesetSpecUploads, error) {
+	u := &ChangesetSpecUploads{
+		path:     path,
+		endpoint: endpoint,
+		uploads:  map[string]changesetSpecUpload{},
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return u, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "opening changeset spec upload journal")
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var upload changesetSpecUpload
+		// Skip lines that were only partially written when src was killed.
+		if err := json.Unmarshal(scanner.Bytes(), &upload); err != nil {
+			continue
+		}
+		if time.Since(upload.UploadedAt) < changesetSpecUploadTTL {
+			u.uploads[upload.Key] = upload
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "reading changeset spec upload journal")
+	}
+	return u, nil
+}
+
+// Clear removes the journal. It should be called once the uploaded changeset
+// specs are attached to a batch spec, since they can't be reused after that.
+func (u *ChangesetSpecUploads) Clear() error {
+	if u == nil {
+		return nil
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.uploads = map[string]changesetSpecUpload{}
+	if err := os.Remove(u.path); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "removing changeset spec upload journal")
+	}
+	return nil
+}
+
+func (u *ChangesetSpecUploads) key(spec *batcheslib.ChangesetSpec) (string, error) {
+	raw, err := json.Marshal(spec)
+	if err != nil {
+		return "", errors.Wrap(err, "marshalling changeset spec JSON")
+	}
+	h := sha256.New()
+	h.Write([]byte(u.endpoint))
+	h.Write([]byte{0})
+	h.Write(raw)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (u *ChangesetSpecUploads) lookup(key string) (graphql.ChangesetSpecID, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	upload, ok := u.uploads[key]
+	return upload.ID, ok
+}
+
+func (u *ChangesetSpecUploads) record(key string, id graphql.ChangesetSpecID) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	upload := changesetSpecUpload{Key: key, ID: id, UploadedAt: time.Now()}
+	u.uploads[key] = upload
+
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(u.path), 0755); err != nil {
+		return errors.Wrap(err, "creating changeset spec upload journal directory")
+	}
+	f, err := os.OpenFile(u.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.Wrap(err, "opening changeset spec upload journal")
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return errors.Wrap(err, "writing changeset spec upload journal")
+	}
+	return f.Close()
+}
+
+type CreateChangesetSpecsOpts struct {
+	// Parallelism is the maximum number of changeset specs uploaded at the
+	// same time.
+	Parallelism int
+	// Retries is the number of times the upload of a changeset spec that
+	// failed with a network or server error is retried before giving up on it.
+	Retries int
+	// Uploads, if set, is used to skip the changeset specs that were already
+	// uploaded, and to record the ones that are uploaded.
+	Uploads *ChangesetSpecUploads
+	// Progress is called after each changeset spec was uploaded or skipped.
+	Progress func(done, total int)
+}
+
+// CreateChangesetSpecs uploads the given changeset specs in parallel and
+// returns their IDs, in the same order as the specs. A failed upload doesn't
+// stop the others: all uploads are attempted, and the errors of the failed
+// ones are returned together.
+func (svc *Service) CreateChangesetSpecs(ctx context.Context, specs []*batcheslib.ChangesetSpec, opts CreateChangesetSpecsOpts) ([]graphql.ChangesetSpecID, error) {
+	return createChangesetSpecs(ctx, specs, opts, svc.CreateChangesetSpec)
+}
+
+// createChangesetSpecRetryDelay is the delay before the first retry of a
+// failed upload. It doubles with every further retry.
+var createChangesetSpecRetryDelay = time.Second
+
+func createChangesetSpecs(
+	ctx context.Context,
+	specs []*batcheslib.ChangesetSpec,
+	opts CreateChangesetSpecsOpts,
+	create func(context.Context, *batcheslib.ChangesetSpec) (graphql.ChangesetSpecID, error),
+) ([]graphql.ChangesetSpecID, error) {
+	parallelism := opts.Parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	var (
+		ids  = make([]graphql.ChangesetSpecID, len(specs))
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, parallelism)
+		mu   sync.Mutex
+		done int
+		errs *multierror.Error
+	)
+
+	finished := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil {
+			errs = multierror.Append(errs, err)
+			return
+		}
+		done++
+		if opts.Progress != nil {
+			opts.Progress(done, len(specs))
+		}
+	}
+
+	for i, spec := range specs {
+		wg.Add(1)
+		go func(i int, spec *batcheslib.ChangesetSpec) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			id, err := createChangesetSpec(ctx, spec, opts, create)
+			if err != nil {
+				finished(errors.Wrapf(err, "uploading changeset spec for %s", spec.BaseRepository))
+				return
+			}
+			ids[i] = id
+			finished(nil)
+		}(i, spec)
+	}
+	wg.Wait()
+
+	if err := errs.ErrorOrNil(); err != nil {
+		if len(errs.Errors) < len(specs) {
+			return nil, cmderrors.WithKind(err, cmderrors.KindPartialFailure)
+		}
+		return nil, err
+	}
+	return ids, nil
+}
+
+// createChangesetSpec uploads a single changeset spec, unless it was uploaded
+// before, retrying failures that may be transient with an exponential backoff.
+func createChangesetSpec(
+	ctx context.Context,
+	spec *batcheslib.ChangesetSpec,
+	opts CreateChangesetSpecsOpts,
+	create func(context.Context, *batcheslib.ChangesetSpec) (graphql.ChangesetSpecID, error),
+) (graphql.ChangesetSpecID, error) {
+	var key string
+	if opts.Uploads != nil {
+		var err error
+		if key, err = opts.Uploads.key(spec); err != nil {
+			return "", err
+		}
+		if id, ok := opts.Uploads.lookup(key); ok {
+			return id, nil
+		}
+	}
+
+	delay := createChangesetSpecRetryDelay
+	for attempt := 0; ; attempt++ {
+		id, err := create(ctx, spec)
+		if err == nil {
+			if opts.Uploads != nil {
+				if err := opts.Uploads.record(key, id); err != nil {
+					return "", err
+				}
+			}
+			return id, nil
+		}
+		if attempt >= opts.Retries || ctx.Err() != nil || !retryableUploadError(err) {
+			return "", err
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// retryableUploadError returns whether the upload of a changeset spec that
+// failed with err is retried: network errors and server errors may be
+// transient, while other errors, such as a changeset spec that Sourcegraph
+// rejects, would only fail again.
+func retryableUploadError(err error) bool {
+	var statusErr *api.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+	return cmderrors.Classify(err) == cmderrors.KindNetwork
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func TestCreateChangesetSpecs(t *testing.T) {
+	createChangesetSpecRetryDelay = time.Millisecond
+	t.Cleanup(func() { createChangesetSpecRetryDelay = time.Second })
+
+	specs := []*batcheslib.ChangesetSpec{
+		{BaseRepository: "repo-0"},
+		{BaseRepository: "repo-1"},
+		{BaseRepository: "repo-2"},
+	}
+	journal := filepath.Join(t.TempDir(), "uploads.jsonl")
+
+	// uploader creates changeset specs, failing for the repos in failing
+	// until they were attempted the given number of times.
+	type uploader struct {
+		mu       sync.Mutex
+		attempts map[string]int
+		failing  map[string]int
+	}
+	create := func(u *uploader) func(context.Context, *batcheslib.ChangesetSpec) (graphql.ChangesetSpecID, error) {
+		return func(_ context.Context, spec *batcheslib.ChangesetSpec) (graphql.ChangesetSpecID, error) {
+			u.mu.Lock()
+			defer u.mu.Unlock()
+
+			u.attempts[spec.BaseRepository]++
+			if u.attempts[spec.BaseRepository] <= u.failing[spec.BaseRepository] {
+				return "", &api.StatusError{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway"}
+			}
+			return graphql.ChangesetSpecID(fmt.Sprintf("spec-%s-%d", spec.BaseRepository, u.attempts[spec.BaseRepository])), nil
+		}
+	}
+
+	// The upload of repo-2 keeps failing, the one of repo-1 succeeds after a
+	// retry.
+	first := &uploader{attempts: map[string]int{}, failing: map[string]int{"repo-1": 1, "repo-2": 10}}
+	uploads, err := OpenChangesetSpecUploads(journal, "https://sourcegraph.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var progress []int
+	_, err = createChangesetSpecs(context.Background(), specs, CreateChangesetSpecsOpts{
+		Parallelism: 2,
+		Retries:     2,
+		Uploads:     uploads,
+		Progress:    func(done, total int) { progress = append(progress, done) },
+	}, create(first))
+	if err == nil {
+		t.Fatal("unexpected nil error")
+	}
+	if diff := cmp.Diff(map[string]int{"repo-0": 1, "repo-1": 2, "repo-2": 3}, first.attempts); diff != "" {
+		t.Errorf("wrong attempts (-want +have):\n%s", diff)
+	}
+	if diff := cmp.Diff([]int{1, 2}, progress); diff != "" {
+		t.Errorf("wrong progress (-want +have):\n%s", diff)
+	}
+
+	// Resuming only uploads the changeset spec that is missing.
+	second := &uploader{attempts: map[string]int{}}
+	uploads, err = OpenChangesetSpecUploads(journal, "https://sourcegraph.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := createChangesetSpecs(context.Background(), specs, CreateChangesetSpecsOpts{
+		Parallelism: 2,
+		Uploads:     uploads,
+	}, create(second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]int{"repo-2": 1}, second.attempts); diff != "" {
+		t.Errorf("wrong attempts (-want +have):\n%s", diff)
+	}
+	want := []graphql.ChangesetSpecID{"spec-repo-0-1", "spec-repo-1-2", "spec-repo-2-1"}
+	if diff := cmp.Diff(want, ids); diff != "" {
+		t.Errorf("wrong IDs (-want +have):\n%s", diff)
+	}
+
+	// Uploads to another instance aren't reused.
+	other, err := OpenChangesetSpecUploads(journal, "https://other.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := other.key(specs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := other.lookup(key); ok {
+		t.Error("upload to another instance was reused")
+	}
+
+	// Clearing the journal forgets all uploads.
+	if err := uploads.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	uploads, err = OpenChangesetSpecUploads(journal, "https://sourcegraph.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads.uploads) != 0 {
+		t.Errorf("uploads were not cleared: %+v", uploads.uploads)
+	}
+}
+
+func TestCreateChangesetSpecs_Retries(t *testing.T) {
+	createChangesetSpecRetryDelay = time.Millisecond
+	t.Cleanup(func() { createChangesetSpecRetryDelay = time.Second })
+
+	for name, tc := range map[string]struct {
+		err          error
+		wantAttempts int
+	}{
+		"network error": {
+			err:          &net.OpError{Op: "dial", Err: errors.New("connection refused")},
+			wantAttempts: 3,
+		},
+		"server error": {
+			err:          &api.StatusError{StatusCode: http.StatusInternalServerError, Status: "500 Internal Server Error"},
+			wantAttempts: 3,
+		},
+		"unavailable": {
+			err:          cmderrors.WithKind(errors.New("service unavailable"), cmderrors.KindNetwork),
+			wantAttempts: 3,
+		},
+		"client error": {
+			err:          &api.StatusError{StatusCode: http.StatusBadRequest, Status: "400 Bad Request"},
+			wantAttempts: 1,
+		},
+		"graphql error": {
+			err:          api.GraphQlErrors{},
+			wantAttempts: 1,
+		},
+		"other error": {
+			err:          errors.New("invalid changeset spec"),
+			wantAttempts: 1,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			attempts := 0
+			_, err := createChangesetSpecs(context.Background(), []*batcheslib.ChangesetSpec{{BaseRepository: "repo-0"}}, CreateChangesetSpecsOpts{
+				Retries: 2,
+			}, func(context.Context, *batcheslib.ChangesetSpec) (graphql.ChangesetSpecID, error) {
+				attempts++
+				return "", tc.err
+			})
+			if err == nil {
+				t.Fatal("unexpected nil error")
+			}
+			if attempts != tc.wantAttempts {
+				t.Errorf("wrong number of attempts: want %d, have %d", tc.wantAttempts, attempts)
+			}
+		})
+	}
+}
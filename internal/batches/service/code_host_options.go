@@ -0,0 +1,188 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// codeHostOptions are the options of the changeset template that only apply
+// to changesets on certain code hosts. See resolveCodeHostOptions.
+type codeHostOptions struct {
+	GitLab    *gitLabOptions
+	Gerrit    *gerritOptions
+	Bitbucket *bitbucketOptions
+}
+
+type gitLabOptions struct {
+	// Labels are added to the merge requests.
+	Labels []string `yaml:"labels" json:"labels,omitempty"`
+	// TargetProject is the path of the project that merge requests are
+	// opened against, such as a fork's upstream.
+	TargetProject string `yaml:"targetProject" json:"targetProject,omitempty"`
+}
+
+type gerritOptions struct {
+	// Topic is set on the changes.
+	Topic string `yaml:"topic" json:"topic,omitempty"`
+}
+
+type bitbucketOptions struct {
+	// DefaultReviewers adds the default reviewers of the repository to the
+	// pull requests.
+	DefaultReviewers bool `yaml:"defaultReviewers" json:"defaultReviewers,omitempty"`
+	// Reviewers are the usernames of additional reviewers.
+	Reviewers []string `yaml:"reviewers" json:"reviewers,omitempty"`
+}
+
+// resolveCodeHostOptions resolves the code host specific options of the
+// changeset template in the batch spec, which the batch spec parser doesn't
+// know:
+//
+//	changesetTemplate:
+//	  title: Hello World
+//	  # ...
+//	  gitlab:
+//	    labels: [automation]
+//	    targetProject: upstream/project
+//	  gerrit:
+//	    topic: hello-world
+//	  bitbucket:
+//	    defaultReviewers: true
+//	    reviewers: [alice]
+//
+// The options are validated and removed from the batch spec, and remembered by
+// the Service, which adds the options for the code host of a changeset's
+// repository to its changeset spec.
+func (svc *Service) resolveCodeHostOptions(spec *yaml.Node) (modified bool, err error) {
+	template := mappingValue(spec, "changesetTemplate")
+	if template == nil || template.Kind != yaml.MappingNode {
+		return false, nil
+	}
+
+	var opts codeHostOptions
+	for _, host := range []struct {
+		key      string
+		target   interface{}
+		validate func() error
+	}{
+		{"gitlab", &opts.GitLab, func() error { return opts.GitLab.validate() }},
+		{"gerrit", &opts.Gerrit, func() error { return opts.Gerrit.validate() }},
+		{"bitbucket", &opts.Bitbucket, func() error { return opts.Bitbucket.validate() }},
+	} {
+		idx := mappingIndex(template, host.key)
+		if idx < 0 {
+			continue
+		}
+		if err := decodeStrict(template.Content[idx+1], host.target); err != nil {
+			return false, errors.Wrapf(err, "changesetTemplate.%s", host.key)
+		}
+		if err := host.validate(); err != nil {
+			return false, errors.Wrapf(err, "changesetTemplate.%s", host.key)
+		}
+		removeMappingKey(template, idx)
+	}
+
+	if opts == (codeHostOptions{}) {
+		return false, nil
+	}
+	svc.codeHostOptions = &opts
+	return true, nil
+}
+
+// decodeStrict decodes node into v, failing on unknown fields.
+func decodeStrict(node *yaml.Node, v interface{}) error {
+	if node.Kind != yaml.MappingNode {
+		return errors.New("must be a mapping")
+	}
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	return dec.Decode(v)
+}
+
+func (o *gitLabOptions) validate() error {
+	if len(o.Labels) == 0 && o.TargetProject == "" {
+		return errors.New("at least one of labels and targetProject must be set")
+	}
+	for _, label := range o.Labels {
+		if strings.TrimSpace(label) == "" || strings.Contains(label, ",") {
+			return errors.Newf("invalid label %q: labels must not be empty or contain commas", label)
+		}
+	}
+	if o.TargetProject != "" && (!strings.Contains(o.TargetProject, "/") || strings.HasPrefix(o.TargetProject, "/") || strings.HasSuffix(o.TargetProject, "/")) {
+		return errors.Newf("invalid targetProject %q: must be the path of a project, such as group/project", o.TargetProject)
+	}
+	return nil
+}
+
+func (o *gerritOptions) validate() error {
+	if o.Topic == "" {
+		return errors.New("topic must be set")
+	}
+	if strings.ContainsAny(o.Topic, " \t\n,") {
+		return errors.Newf("invalid topic %q: must not contain whitespace or commas", o.Topic)
+	}
+	return nil
+}
+
+func (o *bitbucketOptions) validate() error {
+	if !o.DefaultReviewers && len(o.Reviewers) == 0 {
+		return errors.New("at least one of defaultReviewers and reviewers must be set")
+	}
+	for _, r := range o.Reviewers {
+		if strings.TrimSpace(r) == "" {
+			return errors.New("reviewers must not be empty")
+		}
+	}
+	return nil
+}
+
+// forServiceType returns the options that apply to changesets on code hosts of
+// the given external service type, or nil if there are none.
+func (o *codeHostOptions) forServiceType(serviceType string) interface{} {
+	if o == nil {
+		return nil
+	}
+	switch strings.ToLower(serviceType) {
+	case "gitlab":
+		if o.GitLab != nil {
+			return o.GitLab
+		}
+	case "gerrit":
+		if o.Gerrit != nil {
+			return o.Gerrit
+		}
+	case "bitbucketserver", "bitbucketcloud":
+		if o.Bitbucket != nil {
+			return o.Bitbucket
+		}
+	}
+	return nil
+}
+
+// withCodeHostOptions adds the code host options that apply to the code host
+// of the repository to the raw changeset spec, as codeHostOptions. ok is false
+// if none apply, in which case raw is returned unchanged.
+func (o *codeHostOptions) withCodeHostOptions(raw []byte, serviceType string) (_ []byte, ok bool, err error) {
+	opts := o.forServiceType(serviceType)
+	if opts == nil {
+		return raw, false, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, false, err
+	}
+	if fields["codeHostOptions"], err = json.Marshal(opts); err != nil {
+		return nil, false, err
+	}
+	raw, err = json.Marshal(fields)
+	return raw, true, err
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestResolveCodeHostOptions(t *testing.T) {
+	const unchanged = "name: test\nchangesetTemplate:\n  title: Hello\n"
+	tests := map[string]specPassTest{
+		"unchanged": {
+			spec: unchanged,
+			want: unchanged,
+			check: func(t *testing.T, svc *Service, _ []byte) {
+				if svc.codeHostOptions != nil {
+					t.Errorf("unexpected code host options: %+v", svc.codeHostOptions)
+				}
+			},
+		},
+		"options": {
+			spec: `name: test
+changesetTemplate:
+  title: Hello
+  gitlab:
+    labels: [automation, cleanup]
+    targetProject: upstream/project
+  gerrit:
+    topic: hello-world
+  bitbucket:
+    defaultReviewers: true
+    reviewers: [alice]
+  branch: hello
+`,
+			want: "name: test\nchangesetTemplate:\n  title: Hello\n  branch: hello\n",
+			check: func(t *testing.T, svc *Service, _ []byte) {
+				want := &codeHostOptions{
+					GitLab:    &gitLabOptions{Labels: []string{"automation", "cleanup"}, TargetProject: "upstream/project"},
+					Gerrit:    &gerritOptions{Topic: "hello-world"},
+					Bitbucket: &bitbucketOptions{DefaultReviewers: true, Reviewers: []string{"alice"}},
+				}
+				if diff := cmp.Diff(want, svc.codeHostOptions); diff != "" {
+					t.Errorf("wrong options (-want +have):\n%s", diff)
+				}
+			},
+		},
+	}
+	for name, tc := range map[string]struct {
+		template string
+		wantErr  string
+	}{
+		"unknown field": {
+			template: "  gitlab:\n    label: [automation]\n",
+			wantErr:  `changesetTemplate.gitlab: yaml: unmarshal errors:`,
+		},
+		"not a mapping": {
+			template: "  gerrit: hello-world\n",
+			wantErr:  "changesetTemplate.gerrit: must be a mapping",
+		},
+		"empty gitlab": {
+			template: "  gitlab: {}\n",
+			wantErr:  "changesetTemplate.gitlab: at least one of labels and targetProject must be set",
+		},
+		"invalid target project": {
+			template: "  gitlab:\n    targetProject: project\n",
+			wantErr:  `changesetTemplate.gitlab: invalid targetProject "project"`,
+		},
+		"invalid label": {
+			template: "  gitlab:\n    labels: ['a,b']\n",
+			wantErr:  `changesetTemplate.gitlab: invalid label "a,b"`,
+		},
+		"invalid topic": {
+			template: "  gerrit:\n    topic: hello world\n",
+			wantErr:  `changesetTemplate.gerrit: invalid topic "hello world"`,
+		},
+		"empty bitbucket": {
+			template: "  bitbucket:\n    defaultReviewers: false\n",
+			wantErr:  "changesetTemplate.bitbucket: at least one of defaultReviewers and reviewers must be set",
+		},
+	} {
+		tests[name] = specPassTest{spec: unchanged + tc.template, wantErr: []string{tc.wantErr}}
+	}
+	runSpecPassTests(t, func(svc *Service) specPass { return svc.resolveCodeHostOptions }, tests)
+}
+
+func TestWithCodeHostOptions(t *testing.T) {
+	opts := &codeHostOptions{
+		GitLab:    &gitLabOptions{Labels: []string{"automation"}},
+		Bitbucket: &bitbucketOptions{DefaultReviewers: true},
+	}
+	raw := []byte(`{"baseRepository":"repo","title":"Hello"}`)
+
+	for _, tc := range []struct {
+		serviceType string
+		want        string
+		wantOK      bool
+	}{
+		{"gitlab", `{"baseRepository":"repo","codeHostOptions":{"labels":["automation"]},"title":"Hello"}`, true},
+		{"bitbucketServer", `{"baseRepository":"repo","codeHostOptions":{"defaultReviewers":true},"title":"Hello"}`, true},
+		{"bitbucketCloud", `{"baseRepository":"repo","codeHostOptions":{"defaultReviewers":true},"title":"Hello"}`, true},
+		{"gerrit", string(raw), false},
+		{"github", string(raw), false},
+	} {
+		t.Run(tc.serviceType, func(t *testing.T) {
+			have, ok, err := opts.withCodeHostOptions(raw, tc.serviceType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tc.wantOK {
+				t.Errorf("wrong ok: want %t, have %t", tc.wantOK, ok)
+			}
+			if diff := cmp.Diff(tc.want, string(have)); diff != "" {
+				t.Errorf("wrong spec (-want +have):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("no options", func(t *testing.T) {
+		var opts *codeHostOptions
+		have, ok, err := opts.withCodeHostOptions(raw, "gitlab")
+		if err != nil || ok || string(have) != string(raw) {
+			t.Errorf("unexpected result: %s, %t, %v", have, ok, err)
+		}
+	})
+}
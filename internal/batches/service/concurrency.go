@@ -0,0 +1,58 @@
+package service
+
+import (
+	"strconv"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// resolveConcurrency removes the `maxParallelism:` field and the `serial:`
+// fields of the steps from the batch spec:
+//
+//	maxParallelism: 4
+//	steps:
+//	  - run: ./open-ticket.sh
+//	    container: alpine:3
+//	    serial: true
+//
+// maxParallelism limits the number of workspaces that are executed at the
+// same time, on top of the -j flag. A step with `serial: true` runs in only one
+// workspace at a time, while other steps keep running in parallel, such as a
+// step that calls an external API with a tight rate limit.
+//
+// The configuration is remembered by the Service and used by the Coordinators
+// it creates.
+func (svc *Service) resolveConcurrency(spec *yaml.Node) (modified bool, err error) {
+	if i := mappingIndex(spec, "maxParallelism"); i >= 0 {
+		value := spec.Content[i+1].Value
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return false, errors.Newf("maxParallelism must be a positive number, got %q", value)
+		}
+		svc.maxParallelism = n
+		removeMappingKey(spec, i)
+		modified = true
+	}
+
+	err = forEachStep(spec, func(i int, step *yaml.Node) error {
+		j := mappingIndex(step, "serial")
+		if j < 0 {
+			return nil
+		}
+		var serial bool
+		if err := step.Content[j+1].Decode(&serial); err != nil {
+			return errors.Newf("step %d: serial must be true or false, got %q", i+1, step.Content[j+1].Value)
+		}
+		if serial {
+			if svc.serialSteps == nil {
+				svc.serialSteps = map[int]bool{}
+			}
+			svc.serialSteps[i] = true
+		}
+		removeMappingKey(step, j)
+		modified = true
+		return nil
+	})
+	return modified, err
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestResolveConcurrency(t *testing.T) {
+	runSpecPassTests(t, func(svc *Service) specPass { return svc.resolveConcurrency }, map[string]specPassTest{
+		"unchanged": {
+			spec: plainSpec,
+			want: plainSpec,
+			check: func(t *testing.T, svc *Service, _ []byte) {
+				if svc.maxParallelism != 0 || svc.serialSteps != nil {
+					t.Errorf("concurrency configured: %d, %v", svc.maxParallelism, svc.serialSteps)
+				}
+			},
+		},
+		"configured": {
+			spec: `name: test
+maxParallelism: 4
+steps:
+  - run: ./codemod.sh
+    container: alpine:3
+    serial: false
+  - run: ./open-ticket.sh
+    container: alpine:3
+    serial: true
+`,
+			want: `name: test
+steps:
+  - run: ./codemod.sh
+    container: alpine:3
+  - run: ./open-ticket.sh
+    container: alpine:3
+`,
+			check: func(t *testing.T, svc *Service, _ []byte) {
+				if svc.maxParallelism != 4 {
+					t.Errorf("wrong max parallelism %d", svc.maxParallelism)
+				}
+				if diff := cmp.Diff(map[int]bool{1: true}, svc.serialSteps); diff != "" {
+					t.Errorf("wrong serial steps (-want +have):\n%s", diff)
+				}
+			},
+		},
+		"zero":       {spec: "maxParallelism: 0\n", wantErr: []string{"maxParallelism must be a positive number"}},
+		"not number": {spec: "maxParallelism: many\n", wantErr: []string{"maxParallelism must be a positive number"}},
+		"serial":     {spec: "steps:\n  - run: echo\n    serial: yes please\n", wantErr: []string{"step 1: serial must be true or false"}},
+	})
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sourcegraph/go-diff/diff"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+)
+
+// BaseCheck is the result of checking a changeset spec against the current
+// head of its base branch.
+type BaseCheck struct {
+	Spec *batcheslib.ChangesetSpec
+	Repo *graphql.Repository
+	// HeadRev is the current commit of the base branch.
+	HeadRev string
+	// Stale is true if the base branch moved since the spec was built.
+	Stale bool
+	// Conflict is true if the diff of the spec doesn't apply to HeadRev.
+	Conflict bool
+}
+
+// baseCheckParallelism is the number of changeset specs checked at a time.
+const baseCheckParallelism = 8
+
+// CheckBaseBranches looks up the current head of the base branch of each
+// changeset spec. For specs whose base branch moved since they were built,
+// it tests whether their diffs still apply cleanly to the new head, by
+// applying them to the files they touch, fetched from the raw API. Specs of
+// imported changesets are skipped.
+func (svc *Service) CheckBaseBranches(ctx context.Context, specs []*batcheslib.ChangesetSpec, repos []*graphql.Repository) ([]BaseCheck, error) {
+	byID := make(map[string]*graphql.Repository, len(repos))
+	for _, r := range repos {
+		byID[r.ID] = r
+	}
+
+	var checks []BaseCheck
+	for _, spec := range specs {
+		if spec.ExternalID != "" {
+			continue
+		}
+		repo, ok := byID[spec.BaseRepository]
+		if !ok {
+			return nil, errors.Newf("unknown repository %q", spec.BaseRepository)
+		}
+		checks = append(checks, BaseCheck{Spec: spec, Repo: repo})
+	}
+
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, baseCheckParallelism)
+		errs = make([]error, len(checks))
+	)
+	for i := range checks {
+		wg.Add(1)
+		go func(c *BaseCheck, err *error) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			*err = svc.checkBaseBranch(ctx, c)
+		}(&checks[i], &errs[i])
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return checks, nil
+}
+
+func (svc *Service) checkBaseBranch(ctx context.Context, c *BaseCheck) error {
+	branch := strings.TrimPrefix(c.Spec.BaseRef, "refs/heads/")
+	head, err := svc.resolveRepositoryNameAndBranch(ctx, c.Repo.Name, branch)
+	if err != nil {
+		return errors.Wrapf(err, "resolving base branch of %s", c.Repo.Name)
+	}
+
+	c.HeadRev = head.Commit.OID
+	if c.HeadRev == c.Spec.BaseRev {
+		return nil
+	}
+	c.Stale = true
+
+	applies, err := svc.diffsApply(ctx, c.Repo.Name, c.HeadRev, c.Spec.Commits)
+	if err != nil {
+		return errors.Wrapf(err, "checking diff of %s", c.Repo.Name)
+	}
+	c.Conflict = !applies
+	return nil
+}
+
+// diffsApply returns true if the diffs of the commits apply, in order, to the
+// repository at the given revision.
+func (svc *Service) diffsApply(ctx context.Context, repo, rev string, commits []batcheslib.GitCommitDescription) (bool, error) {
+	dir, err := os.MkdirTemp("", "src-base-check-*")
+	if err != nil {
+		return false, err
+	}
+	defer os.RemoveAll(dir)
+
+	fetched := map[string]bool{}
+	for _, c := range commits {
+		fileDiffs, err := diff.ParseMultiFileDiff([]byte(c.Diff))
+		if err != nil {
+			return false, errors.Wrap(err, "parsing diff")
+		}
+		for _, fd := range fileDiffs {
+			if fd.OrigName == "/dev/null" || fetched[fd.OrigName] {
+				continue
+			}
+			fetched[fd.OrigName] = true
+			if err := svc.fetchRawFile(ctx, repo, rev, fd.OrigName, dir); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	for _, c := range commits {
+		// Like the diffs generated by the workspaces, the diffs don't have
+		// a/ and b/ prefixes.
+		cmd := exec.CommandContext(ctx, "git", "apply", "-p0", "-")
+		cmd.Dir = dir
+		cmd.Stdin = strings.NewReader(c.Diff)
+		if err := cmd.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				return false, nil
+			}
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// fetchRawFile downloads the file of the repository at the given revision into
+// dir. Files that don't exist at the revision are skipped, so that the diff
+// touching them fails to apply.
+func (svc *Service) fetchRawFile(ctx context.Context, repo, rev, name, dir string) error {
+	req, err := svc.client.NewHTTPRequest(ctx, "GET", path.Join(repo+"@"+rev, "-", "raw", name), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := svc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to fetch %s (HTTP %d from %s)", name, resp.StatusCode, req.URL.String())
+	}
+
+	dest := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+		return err
+	}
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, resp.Body)
+	return err
+}
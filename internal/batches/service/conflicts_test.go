@@ -0,0 +1,95 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"testing"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+)
+
+func TestService_CheckBaseBranches(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	heads := map[string]string{"repo-1": "base", "repo-2": "moved", "repo-3": "moved"}
+	files := map[string]string{
+		"/github.com/a/two@moved/-/raw/README.md":   "hello\nmore\n",
+		"/github.com/a/three@moved/-/raw/README.md": "goodbye\n",
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.api/graphql", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var name string
+		switch {
+		case bytes.Contains(body, []byte(`"github.com/a/one"`)):
+			name = "repo-1"
+		case bytes.Contains(body, []byte(`"github.com/a/two"`)):
+			name = "repo-2"
+		default:
+			name = "repo-3"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"data":{"repository":{"id":%q,"commit":{"oid":%q}}}}`, name, heads[name])
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	svc := &Service{client: api.NewClient(api.ClientOpts{Endpoint: ts.URL, Out: &bytes.Buffer{}})}
+
+	repos := []*graphql.Repository{
+		{ID: "repo-1", Name: "github.com/a/one"},
+		{ID: "repo-2", Name: "github.com/a/two"},
+		{ID: "repo-3", Name: "github.com/a/three"},
+	}
+	diff := "--- README.md\n+++ README.md\n@@ -1 +1 @@\n-hello\n+hello world\n"
+	var specs []*batcheslib.ChangesetSpec
+	for _, r := range repos {
+		specs = append(specs, &batcheslib.ChangesetSpec{
+			BaseRepository: r.ID,
+			BaseRef:        "refs/heads/main",
+			BaseRev:        "base",
+			Commits:        []batcheslib.GitCommitDescription{{Diff: diff}},
+		})
+	}
+	specs = append(specs, &batcheslib.ChangesetSpec{BaseRepository: "repo-1", ExternalID: "1"})
+
+	checks, err := svc.CheckBaseBranches(context.Background(), specs, repos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 3 {
+		t.Fatalf("wrong number of checks: %d", len(checks))
+	}
+
+	for i, want := range []struct{ stale, conflict bool }{
+		{false, false},
+		{true, false},
+		{true, true},
+	} {
+		if checks[i].Stale != want.stale || checks[i].Conflict != want.conflict {
+			t.Errorf("wrong check for %s: stale=%t conflict=%t", checks[i].Repo.Name, checks[i].Stale, checks[i].Conflict)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+# Prints a JSON inventory of the dependency manifests below the current
+# directory. This is the run script of the dependency-inventory library step.
+import json
+import os
+import sys
+import xml.etree.ElementTree as ET
+
+SKIP_DIRS = {".git", "node_modules", "vendor"}
+
+
+def parse_go_mod(text):
+    module, deps, in_block = None, [], False
+    for line in text.splitlines():
+        line = line.split("//")[0].strip()
+        if not line:
+            continue
+        if line.startswith("module "):
+            module = line.split()[1].strip('"')
+        elif line in ("require (", "require("):
+            in_block = True
+        elif in_block and line == ")":
+            in_block = False
+        elif in_block or line.startswith("require "):
+            fields = line.split()
+            if fields[0] == "require":
+                fields = fields[1:]
+            if len(fields) >= 2:
+                deps.append({"name": fields[0], "version": fields[1]})
+    return module, deps
+
+
+def parse_package_json(text):
+    data = json.loads(text)
+    deps = []
+    for scope in ("dependencies", "devDependencies", "peerDependencies"):
+        for name, version in sorted((data.get(scope) or {}).items()):
+            deps.append({"name": name, "version": version, "scope": scope})
+    return data.get("name"), deps
+
+
+def parse_pom(text):
+    root = ET.fromstring(text)
+    ns = root.tag[: root.tag.index("}") + 1] if root.tag.startswith("{") else ""
+
+    def child(el, tag):
+        c = el.find(ns + tag)
+        return c.text.strip() if c is not None and c.text else None
+
+    deps = []
+    for dep in root.iter(ns + "dependency"):
+        deps.append({
+            "name": "%s:%s" % (child(dep, "groupId"), child(dep, "artifactId")),
+            "version": child(dep, "version"),
+            "scope": child(dep, "scope"),
+        })
+    return "%s:%s" % (child(root, "groupId"), child(root, "artifactId")), deps
+
+
+PARSERS = {
+    "go.mod": ("go", parse_go_mod),
+    "package.json": ("npm", parse_package_json),
+    "pom.xml": ("maven", parse_pom),
+}
+
+manifests = []
+for dirpath, dirnames, filenames in os.walk("."):
+    dirnames[:] = sorted(d for d in dirnames if d not in SKIP_DIRS)
+    for filename in sorted(filenames):
+        if filename not in PARSERS:
+            continue
+        path = os.path.relpath(os.path.join(dirpath, filename))
+        kind, parse = PARSERS[filename]
+        manifest = {"path": path, "type": kind}
+        try:
+            with open(path, encoding="utf-8") as f:
+                manifest["name"], manifest["dependencies"] = parse(f.read())
+        except Exception as e:
+            manifest["error"] = str(e)
+        manifests.append(manifest)
+
+json.dump(manifests, sys.stdout)
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"strings"
+	"time"
+)
+
+// ChangesetEvent is a change of the state, review state, or check state of a
+// changeset between two fetches of the changesets of a batch change.
+type ChangesetEvent struct {
+	Time time.Time `json:"time"`
+	// Event names the change, such as opened, review-requested, ci-failed,
+	// or merged.
+	Event      string `json:"event"`
+	Changeset  string `json:"changeset"`
+	Repository string `json:"repository"`
+	URL        string `json:"url,omitempty"`
+	// Field is the field of the changeset that changed: state, reviewState,
+	// or checkState.
+	Field string `json:"field"`
+	From  string `json:"from,omitempty"`
+	To    string `json:"to,omitempty"`
+}
+
+// ChangesetEvents returns the events that turn the changesets in prev into
+// the ones in next, in the order of next, followed by removed events for the
+// changesets in prev that are no longer in next. If prev is nil, the events
+// leading to the current state of the changesets in next are returned.
+func ChangesetEvents(prev, next []Changeset, now time.Time) []ChangesetEvent {
+	prevByID := make(map[string]Changeset, len(prev))
+	for _, cs := range prev {
+		prevByID[cs.ID] = cs
+	}
+
+	var events []ChangesetEvent
+	add := func(cs Changeset, field, from, to, event string) {
+		events = append(events, ChangesetEvent{
+			Time:       now,
+			Event:      event,
+			Changeset:  cs.ID,
+			Repository: cs.Repository,
+			URL:        cs.URL,
+			Field:      field,
+			From:       from,
+			To:         to,
+		})
+	}
+
+	seen := make(map[string]bool, len(next))
+	for _, cs := range next {
+		seen[cs.ID] = true
+		old := prevByID[cs.ID]
+
+		if cs.State != old.State {
+			add(cs, "state", old.State, cs.State, stateEvent(old.State, cs.State))
+		}
+		if cs.ReviewState != old.ReviewState && cs.ReviewState != "" {
+			add(cs, "reviewState", old.ReviewState, cs.ReviewState, reviewStateEvent(cs.ReviewState))
+		}
+		if cs.CheckState != old.CheckState && cs.CheckState != "" {
+			add(cs, "checkState", old.CheckState, cs.CheckState, checkStateEvent(cs.CheckState))
+		}
+	}
+
+	for _, cs := range prev {
+		if !seen[cs.ID] {
+			add(cs, "state", cs.State, "", "removed")
+		}
+	}
+
+	return events
+}
+
+func stateEvent(from, to string) string {
+	switch to {
+	case "OPEN":
+		if from == "CLOSED" {
+			return "reopened"
+		}
+		return "opened"
+	case "DRAFT":
+		return "drafted"
+	default:
+		return eventName(to)
+	}
+}
+
+func reviewStateEvent(state string) string {
+	switch state {
+	case "PENDING":
+		return "review-requested"
+	case "DISMISSED":
+		return "review-dismissed"
+	default:
+		return eventName(state)
+	}
+}
+
+func checkStateEvent(state string) string {
+	return "ci-" + eventName(state)
+}
+
+// eventName turns a GraphQL enum value such as CHANGES_REQUESTED into an event
+// name such as changes-requested.
+func eventName(value string) string {
+	return strings.ReplaceAll(strings.ToLower(value), "_", "-")
+}
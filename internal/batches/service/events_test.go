@@ -0,0 +1,50 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestChangesetEvents(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	prev := []Changeset{
+		{ID: "1", Repository: "a", State: "UNPUBLISHED"},
+		{ID: "2", Repository: "b", State: "OPEN", ReviewState: "PENDING", CheckState: "PENDING", URL: "https://b/1"},
+		{ID: "3", Repository: "c", State: "CLOSED", URL: "https://c/1"},
+		{ID: "4", Repository: "d", State: "OPEN", URL: "https://d/1"},
+	}
+	next := []Changeset{
+		{ID: "1", Repository: "a", State: "OPEN", ReviewState: "PENDING", URL: "https://a/1"},
+		{ID: "2", Repository: "b", State: "MERGED", ReviewState: "APPROVED", CheckState: "FAILED", URL: "https://b/1"},
+		{ID: "3", Repository: "c", State: "OPEN", URL: "https://c/1"},
+	}
+
+	want := []ChangesetEvent{
+		{Time: now, Event: "opened", Changeset: "1", Repository: "a", URL: "https://a/1", Field: "state", From: "UNPUBLISHED", To: "OPEN"},
+		{Time: now, Event: "review-requested", Changeset: "1", Repository: "a", URL: "https://a/1", Field: "reviewState", To: "PENDING"},
+		{Time: now, Event: "merged", Changeset: "2", Repository: "b", URL: "https://b/1", Field: "state", From: "OPEN", To: "MERGED"},
+		{Time: now, Event: "approved", Changeset: "2", Repository: "b", URL: "https://b/1", Field: "reviewState", From: "PENDING", To: "APPROVED"},
+		{Time: now, Event: "ci-failed", Changeset: "2", Repository: "b", URL: "https://b/1", Field: "checkState", From: "PENDING", To: "FAILED"},
+		{Time: now, Event: "reopened", Changeset: "3", Repository: "c", URL: "https://c/1", Field: "state", From: "CLOSED", To: "OPEN"},
+		{Time: now, Event: "removed", Changeset: "4", Repository: "d", URL: "https://d/1", Field: "state", From: "OPEN"},
+	}
+	if diff := cmp.Diff(want, ChangesetEvents(prev, next, now)); diff != "" {
+		t.Errorf("wrong events (-want +have):\n%s", diff)
+	}
+
+	if events := ChangesetEvents(next, next, now); len(events) != 0 {
+		t.Errorf("unexpected events for unchanged changesets: %v", events)
+	}
+
+	want = []ChangesetEvent{
+		{Time: now, Event: "drafted", Changeset: "1", Repository: "a", Field: "state", To: "DRAFT"},
+		{Time: now, Event: "changes-requested", Changeset: "1", Repository: "a", Field: "reviewState", To: "CHANGES_REQUESTED"},
+	}
+	initial := []Changeset{{ID: "1", Repository: "a", State: "DRAFT", ReviewState: "CHANGES_REQUESTED"}}
+	if diff := cmp.Diff(want, ChangesetEvents(nil, initial, now)); diff != "" {
+		t.Errorf("wrong initial events (-want +have):\n%s", diff)
+	}
+}
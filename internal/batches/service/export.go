@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/util"
+)
+
+// BatchChangeExportVersion is the version of the BatchChangeExport format
+// written by ExportBatchChange.
+const BatchChangeExportVersion = 1
+
+// BatchChangeExport is a batch change exported from one Sourcegraph instance,
+// to be imported on another. Repositories are referenced by name, since their
+// IDs differ between instances.
+type BatchChangeExport struct {
+	Version     int                 `json:"version"`
+	Name        string              `json:"name"`
+	Description string              `json:"description,omitempty"`
+	BatchSpec   string              `json:"batchSpec"`
+	Changesets  []ExportedChangeset `json:"changesets"`
+}
+
+// ExportedChangeset is a changeset of an exported batch change.
+type ExportedChangeset struct {
+	Repository string `json:"repository"`
+	// State is the ChangesetState of the changeset on the exporting instance.
+	State string `json:"state"`
+	// ExternalID is the ID of the changeset on the code host, if it has been
+	// published or is tracked.
+	ExternalID  string `json:"externalID,omitempty"`
+	ExternalURL string `json:"externalURL,omitempty"`
+
+	// The following fields describe the branch of changesets created by the
+	// batch change, and are empty for tracked changesets.
+	BaseRef   string                            `json:"baseRef,omitempty"`
+	BaseRev   string                            `json:"baseRev,omitempty"`
+	HeadRef   string                            `json:"headRef,omitempty"`
+	Title     string                            `json:"title,omitempty"`
+	Body      string                            `json:"body,omitempty"`
+	Commits   []batcheslib.GitCommitDescription `json:"commits,omitempty"`
+	Published interface{}                       `json:"published,omitempty"`
+}
+
+const exportBatchChangeQuery = `
+query ExportBatchChange($namespace: ID!, $name: String!, $after: String) {
+    batchChange(namespace: $namespace, name: $name) {
+        name
+        description
+        currentSpec {
+            originalInput
+        }
+        changesets(first: 100, after: $after) {
+            nodes {
+                __typename
+                ... on ExternalChangeset {
+                    state
+                    externalID
+                    externalURL {
+                        url
+                    }
+                    repository {
+                        name
+                    }
+                    currentSpec {
+                        description {
+                            __typename
+                            ... on GitBranchChangesetDescription {
+                                baseRef
+                                baseRev
+                                headRef
+                                title
+                                body
+                                published
+                                commits {
+                                    message
+                                    author {
+                                        name
+                                        email
+                                    }
+                                    diff
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+            pageInfo {
+                hasNextPage
+                endCursor
+            }
+        }
+    }
+}
+`
+
+// ExportBatchChange exports the batch change with the given name in the given
+// namespace: its current batch spec and the specs and states of its
+// changesets. Changesets in repositories the user doesn't have access to are
+// omitted.
+func (svc *Service) ExportBatchChange(ctx context.Context, namespace, name string) (*BatchChangeExport, error) {
+	export := &BatchChangeExport{Version: BatchChangeExportVersion}
+	var after *string
+
+	for {
+		var result struct {
+			BatchChange *struct {
+				Name        string
+				Description string
+				CurrentSpec struct{ OriginalInput string }
+				Changesets  struct {
+					Nodes []struct {
+						Typename    string `json:"__typename"`
+						State       string
+						ExternalID  string
+						ExternalURL *struct{ URL string }
+						Repository  struct{ Name string }
+						CurrentSpec *struct {
+							Description struct {
+								Typename  string `json:"__typename"`
+								BaseRef   string
+								BaseRev   string
+								HeadRef   string
+								Title     string
+								Body      string
+								Published interface{}
+								Commits   []struct {
+									Message string
+									Author  struct{ Name, Email string }
+									Diff    string
+								}
+							}
+						}
+					}
+					PageInfo struct {
+						HasNextPage bool
+						EndCursor   *string
+					}
+				}
+			}
+		}
+		if ok, err := svc.client.NewRequest(exportBatchChangeQuery, map[string]interface{}{
+			"namespace": namespace,
+			"name":      name,
+			"after":     after,
+		}).Do(ctx, &result); err != nil || !ok {
+			return nil, err
+		}
+		if result.BatchChange == nil {
+			return nil, errors.Newf("batch change %q not found", name)
+		}
+
+		export.Name = result.BatchChange.Name
+		export.Description = result.BatchChange.Description
+		export.BatchSpec = result.BatchChange.CurrentSpec.OriginalInput
+
+		for _, node := range result.BatchChange.Changesets.Nodes {
+			if node.Typename != "ExternalChangeset" {
+				continue
+			}
+
+			cs := ExportedChangeset{
+				Repository: node.Repository.Name,
+				State:      node.State,
+				ExternalID: node.ExternalID,
+			}
+			if node.ExternalURL != nil {
+				cs.ExternalURL = node.ExternalURL.URL
+			}
+			if node.CurrentSpec != nil && node.CurrentSpec.Description.Typename == "GitBranchChangesetDescription" {
+				desc := node.CurrentSpec.Description
+				cs.BaseRef = desc.BaseRef
+				cs.BaseRev = desc.BaseRev
+				cs.HeadRef = desc.HeadRef
+				cs.Title = desc.Title
+				cs.Body = desc.Body
+				cs.Published = desc.Published
+				for _, c := range desc.Commits {
+					cs.Commits = append(cs.Commits, batcheslib.GitCommitDescription{
+						Message:     c.Message,
+						AuthorName:  c.Author.Name,
+						AuthorEmail: c.Author.Email,
+						Diff:        c.Diff,
+					})
+				}
+			}
+			export.Changesets = append(export.Changesets, cs)
+		}
+
+		if !result.BatchChange.Changesets.PageInfo.HasNextPage {
+			return export, nil
+		}
+		after = result.BatchChange.Changesets.PageInfo.EndCursor
+	}
+}
+
+// ImportChangesetSpecs resolves the repositories of the exported changesets on
+// the instance the service is connected to, and builds the changeset specs
+// that recreate them. See BuildImportChangesetSpecs for how their states are
+// mapped.
+func (svc *Service) ImportChangesetSpecs(ctx context.Context, export *BatchChangeExport) ([]*batcheslib.ChangesetSpec, error) {
+	if export.Version != BatchChangeExportVersion {
+		return nil, errors.Newf("unsupported export version %d, expected %d", export.Version, BatchChangeExportVersion)
+	}
+
+	repos := map[string]*graphql.Repository{}
+	for _, cs := range export.Changesets {
+		if _, ok := repos[cs.Repository]; ok {
+			continue
+		}
+		repo, err := svc.resolveRepositoryName(ctx, cs.Repository)
+		if err != nil {
+			return nil, errors.Wrapf(err, "resolving repository %q", cs.Repository)
+		}
+		repos[cs.Repository] = repo
+	}
+
+	return svc.BuildImportChangesetSpecs(export.Changesets, repos)
+}
+
+// BuildImportChangesetSpecs builds the changeset specs that recreate the
+// exported changesets in the given repositories, keyed by name.
+//
+// Changesets that exist on the code host, because they were published or are
+// tracked, are imported as tracked changesets referencing the same external
+// ID, so that no duplicate changesets are opened and their state keeps being
+// synced from the code host. Changesets that haven't been published are
+// recreated from their branch description, with the same published value.
+// Deleted changesets are skipped.
+func (svc *Service) BuildImportChangesetSpecs(changesets []ExportedChangeset, repos map[string]*graphql.Repository) ([]*batcheslib.ChangesetSpec, error) {
+	specs := make([]*batcheslib.ChangesetSpec, 0, len(changesets))
+	for _, cs := range changesets {
+		if cs.State == "DELETED" {
+			continue
+		}
+
+		repo, ok := repos[cs.Repository]
+		if !ok {
+			return nil, errors.Newf("repository %q not found", cs.Repository)
+		}
+
+		if cs.ExternalID != "" {
+			specs = append(specs, &batcheslib.ChangesetSpec{
+				BaseRepository: repo.ID,
+				ExternalID:     cs.ExternalID,
+			})
+			continue
+		}
+
+		if cs.HeadRef == "" {
+			return nil, errors.Newf("changeset in repository %q has neither an external ID nor a branch", cs.Repository)
+		}
+
+		published := cs.Published
+		if published == nil && !svc.features.AllowOptionalPublished {
+			published = false
+		}
+
+		specs = append(specs, &batcheslib.ChangesetSpec{
+			BaseRepository: repo.ID,
+			BaseRef:        util.EnsureRefPrefix(cs.BaseRef),
+			BaseRev:        cs.BaseRev,
+			HeadRepository: repo.ID,
+			HeadRef:        util.EnsureRefPrefix(cs.HeadRef),
+			Title:          cs.Title,
+			Body:           cs.Body,
+			Commits:        cs.Commits,
+			Published:      batcheslib.PublishedValue{Val: published},
+		})
+	}
+
+	return specs, nil
+}
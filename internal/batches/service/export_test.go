@@ -0,0 +1,72 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+)
+
+func TestService_BuildImportChangesetSpecs(t *testing.T) {
+	repos := map[string]*graphql.Repository{
+		"github.com/sourcegraph/src-cli":     {ID: "repo-1", Name: "github.com/sourcegraph/src-cli"},
+		"github.com/sourcegraph/sourcegraph": {ID: "repo-2", Name: "github.com/sourcegraph/sourcegraph"},
+	}
+	commits := []batcheslib.GitCommitDescription{
+		{Message: "Hello world", Diff: "--- a.txt\n+++ a.txt\n@@ -1 +1 @@\n-a\n+b\n"},
+	}
+
+	svc := &Service{features: batches.FeatureFlags{AllowOptionalPublished: false}}
+	specs, err := svc.BuildImportChangesetSpecs([]ExportedChangeset{
+		{
+			Repository: "github.com/sourcegraph/src-cli",
+			State:      "OPEN",
+			ExternalID: "12",
+			HeadRef:    "hello-world",
+		},
+		{
+			Repository: "github.com/sourcegraph/sourcegraph",
+			State:      "UNPUBLISHED",
+			BaseRef:    "main",
+			BaseRev:    "f00b4r",
+			HeadRef:    "hello-world",
+			Title:      "Hello world",
+			Commits:    commits,
+		},
+		{
+			Repository: "github.com/sourcegraph/sourcegraph",
+			State:      "DELETED",
+			ExternalID: "13",
+		},
+	}, repos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*batcheslib.ChangesetSpec{
+		{
+			BaseRepository: "repo-1",
+			ExternalID:     "12",
+		},
+		{
+			BaseRepository: "repo-2",
+			BaseRef:        "refs/heads/main",
+			BaseRev:        "f00b4r",
+			HeadRepository: "repo-2",
+			HeadRef:        "refs/heads/hello-world",
+			Title:          "Hello world",
+			Commits:        commits,
+			Published:      batcheslib.PublishedValue{Val: false},
+		},
+	}
+	if diff := cmp.Diff(want, specs); diff != "" {
+		t.Errorf("wrong specs (-want +have):\n%s", diff)
+	}
+
+	if _, err := svc.BuildImportChangesetSpecs([]ExportedChangeset{{Repository: "github.com/sourcegraph/missing", ExternalID: "1"}}, repos); err == nil {
+		t.Error("unexpected nil error for missing repository")
+	}
+}
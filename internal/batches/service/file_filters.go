@@ -0,0 +1,49 @@
+package service
+
+import (
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/sourcegraph/src-cli/internal/batches/diff"
+)
+
+// resolveFileFilters removes the `includeFiles:` and `excludeFiles:` fields
+// from the batch spec. They are lists of globs that select the files of the
+// diffs produced by the steps that end up in the changeset specs, for example
+// to drop lockfiles or vendored code that a step regenerated.
+//
+// The filter is remembered by the Service and applied by the Coordinators it
+// creates.
+func (svc *Service) resolveFileFilters(spec *yaml.Node) (modified bool, err error) {
+	globs := func(key string) ([]string, error) {
+		i := mappingIndex(spec, key)
+		if i < 0 {
+			return nil, nil
+		}
+
+		var patterns []string
+		if err := spec.Content[i+1].Decode(&patterns); err != nil {
+			return nil, errors.Newf("%s must be a list of globs", key)
+		}
+		removeMappingKey(spec, i)
+		modified = true
+		return patterns, nil
+	}
+
+	include, err := globs("includeFiles")
+	if err != nil {
+		return false, err
+	}
+	exclude, err := globs("excludeFiles")
+	if err != nil {
+		return false, err
+	}
+	if include == nil && exclude == nil {
+		return modified, nil
+	}
+
+	if svc.fileFilter, err = diff.NewFileFilter(include, exclude); err != nil {
+		return false, err
+	}
+	return modified, nil
+}
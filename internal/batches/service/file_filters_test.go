@@ -0,0 +1,46 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestResolveFileFilters(t *testing.T) {
+	runSpecPassTests(t, func(svc *Service) specPass { return svc.resolveFileFilters }, map[string]specPassTest{
+		"no filters": {
+			spec: plainSpec,
+			want: plainSpec,
+			check: func(t *testing.T, svc *Service, _ []byte) {
+				if svc.fileFilter != nil {
+					t.Error("file filter was set")
+				}
+			},
+		},
+		"filters": {
+			spec: `name: test
+excludeFiles:
+  - "*.lock"
+  - vendor/
+includeFiles: ["src/**"]
+steps:
+  - run: echo
+    container: alpine:3
+`,
+			want: plainSpec,
+			check: func(t *testing.T, svc *Service, _ []byte) {
+				for path, want := range map[string]bool{
+					"src/main.go":        true,
+					"src/web/yarn.lock":  false,
+					"src/vendor/a/b.go":  true,
+					"vendor/src/main.go": false,
+					"README.md":          false,
+				} {
+					if have := svc.fileFilter.Match(path); have != want {
+						t.Errorf("Match(%q) = %t, want %t", path, have, want)
+					}
+				}
+			},
+		},
+		"not a list":   {spec: "excludeFiles: '*.lock'\n", wantErr: []string{"excludeFiles must be a list of globs"}},
+		"invalid glob": {spec: "includeFiles: ['[a']\n", wantErr: anyError},
+	})
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// concatenatedIncludeKeys are the top-level fields whose lists are
+// concatenated when batch specs are merged. All other lists are replaced.
+var concatenatedIncludeKeys = map[string]bool{
+	"on":               true,
+	"steps":            true,
+	"importChangesets": true,
+	"workspaces":       true,
+}
+
+// resolveIncludes merges the batch spec fragments that the batch spec
+// includes into it. Fragments are YAML files, given by a path relative
+// to the including file or by an http(s) URL, and can include other fragments
+// themselves. They are included in two ways:
+//
+//	include:
+//	  - ./fragments/defaults.yaml
+//	steps:
+//	  - include: https://example.com/batch-steps/go-mod-tidy.yaml
+//	  - run: echo
+//	    container: alpine:3
+//
+// The top-level `include:`, a path or a list of paths, merges the fragments
+// into the spec, in order, and then the spec itself on top of them:
+//
+//   - fields that are objects, such as changesetTemplate, are merged field by
+//     field
+//   - the lists of on, steps, importChangesets and workspaces are
+//     concatenated, so that the steps of the fragments come first
+//   - all other fields are replaced by the later value
+//
+// A step that only consists of `include:` is replaced by the steps of the
+// fragment, which allows placing shared step sequences anywhere in the steps.
+//
+// Relative paths in the spec itself are resolved against dir, which should be
+// the directory containing the batch spec.
+func resolveIncludes(spec *yaml.Node, dir string) (modified bool, err error) {
+	r := &includeResolver{
+		client: &http.Client{Timeout: 30 * time.Second},
+		dir:    dir,
+	}
+	return r.resolve(spec, "")
+}
+
+type includeResolver struct {
+	client *http.Client
+	dir    string
+	// stack are the locations of the fragments being included, to detect
+	// cycles.
+	stack []string
+}
+
+// resolve resolves the includes of the spec or fragment at location, which
+// is empty for the batch spec itself. It returns whether spec was modified.
+func (r *includeResolver) resolve(spec *yaml.Node, location string) (bool, error) {
+	modified := false
+
+	if steps := mappingValue(spec, "steps"); steps != nil && steps.Kind == yaml.SequenceNode {
+		var resolved []*yaml.Node
+		for i, step := range steps.Content {
+			if step.Kind != yaml.MappingNode || mappingIndex(step, "include") < 0 {
+				resolved = append(resolved, step)
+				continue
+			}
+			if len(step.Content) != 2 {
+				return false, errors.Newf("step %d: steps with include must not set other fields", i+1)
+			}
+
+			fragment, err := r.include(step.Content[1], location)
+			if err != nil {
+				return false, errors.Wrapf(err, "step %d", i+1)
+			}
+			if fragmentSteps := mappingValue(fragment, "steps"); fragmentSteps != nil && fragmentSteps.Kind == yaml.SequenceNode {
+				resolved = append(resolved, fragmentSteps.Content...)
+			}
+			modified = true
+		}
+		steps.Content = resolved
+	}
+
+	idx := mappingIndex(spec, "include")
+	if idx < 0 {
+		return modified, nil
+	}
+
+	refs := []*yaml.Node{spec.Content[idx+1]}
+	if refs[0].Kind == yaml.SequenceNode {
+		refs = refs[0].Content
+	}
+	removeMappingKey(spec, idx)
+
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, ref := range refs {
+		fragment, err := r.include(ref, location)
+		if err != nil {
+			return false, err
+		}
+		mergeSpecs(merged, fragment, true)
+	}
+	mergeSpecs(merged, spec, true)
+	spec.Content = merged.Content
+
+	return true, nil
+}
+
+// include loads the fragment that ref, relative to location, points to, and
+// resolves its includes.
+func (r *includeResolver) include(ref *yaml.Node, location string) (*yaml.Node, error) {
+	if ref.Kind != yaml.ScalarNode || ref.Value == "" {
+		return nil, errors.New("include must be a path or URL")
+	}
+
+	target, err := r.locate(ref.Value, location)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range r.stack {
+		if l == target {
+			return nil, errors.Newf("including %s: include cycle: %s -> %s", ref.Value, strings.Join(r.stack, " -> "), target)
+		}
+	}
+
+	data, err := r.read(target)
+	if err != nil {
+		return nil, errors.Wrapf(err, "including %s", ref.Value)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, errors.Wrapf(err, "including %s", ref.Value)
+	}
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}, nil
+	}
+	fragment := doc.Content[0]
+	if fragment.Kind != yaml.MappingNode {
+		return nil, errors.Newf("including %s: not a batch spec fragment", ref.Value)
+	}
+
+	r.stack = append(r.stack, target)
+	defer func() { r.stack = r.stack[:len(r.stack)-1] }()
+	if _, err := r.resolve(fragment, target); err != nil {
+		return nil, errors.Wrapf(err, "including %s", ref.Value)
+	}
+	return fragment, nil
+}
+
+// locate returns the absolute path or URL of ref, relative to location.
+func (r *includeResolver) locate(ref, location string) (string, error) {
+	if isURL(ref) {
+		return ref, nil
+	}
+	if isURL(location) {
+		base, err := url.Parse(location)
+		if err != nil {
+			return "", err
+		}
+		u, err := base.Parse(ref)
+		if err != nil {
+			return "", errors.Wrapf(err, "including %s", ref)
+		}
+		return u.String(), nil
+	}
+
+	if filepath.IsAbs(ref) {
+		return ref, nil
+	}
+	dir := r.dir
+	if location != "" {
+		dir = filepath.Dir(location)
+	}
+	return filepath.Abs(filepath.Join(dir, ref))
+}
+
+// read returns the contents of the file or URL.
+func (r *includeResolver) read(target string) ([]byte, error) {
+	if !isURL(target) {
+		return os.ReadFile(target)
+	}
+
+	resp, err := r.client.Get(target)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Newf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://")
+}
+
+// mergeSpecs merges the mapping node src into dst. Nested mappings are merged,
+// the lists of concatenatedIncludeKeys are concatenated if top is true, and
+// all other values of src replace those of dst.
+func mergeSpecs(dst, src *yaml.Node, top bool) {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i].Value, src.Content[i+1]
+
+		existing := mappingValue(dst, key)
+		switch {
+		case existing == nil:
+			dst.Content = append(dst.Content, src.Content[i], value)
+		case existing.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode:
+			mergeSpecs(existing, value, false)
+		case top && concatenatedIncludeKeys[key] && existing.Kind == yaml.SequenceNode && value.Kind == yaml.SequenceNode:
+			existing.Content = append(existing.Content, value.Content...)
+		default:
+			setMappingValue(dst, key, value)
+		}
+	}
+}
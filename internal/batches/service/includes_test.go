@@ -0,0 +1,114 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v3"
+)
+
+func TestResolveIncludes(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/remote.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("steps:\n  - run: go vet ./...\n    container: golang:1.17\n"))
+	}))
+	t.Cleanup(ts.Close)
+
+	dir := t.TempDir()
+	writeFile := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile("fragments/defaults.yaml", `
+include: common.yaml
+on:
+  - repositoriesMatchingQuery: lang:go
+steps:
+  - run: gofmt -w .
+    container: golang:1.17
+changesetTemplate:
+  title: Default title
+  body: Default body
+  published: false
+`)
+	writeFile("fragments/common.yaml", `
+description: Shared description
+changesetTemplate:
+  commit:
+    message: Default message
+`)
+	writeFile("fragments/tidy.yaml", `
+steps:
+  - run: go mod tidy
+    container: golang:1.17
+  - include: `+ts.URL+`/remote.yaml
+`)
+
+	tests := map[string]specPassTest{
+		"no includes": {spec: plainSpec, want: plainSpec},
+		"merged": {
+			spec: `name: test
+include: fragments/defaults.yaml
+on:
+  - repository: github.com/sourcegraph/src-cli
+steps:
+  - run: echo first
+    container: alpine:3
+  - include: fragments/tidy.yaml
+changesetTemplate:
+  title: My title
+  published: true
+`,
+			check: func(t *testing.T, _ *Service, data []byte) {
+				var have map[string]interface{}
+				unmarshalSpec(t, data, &have)
+				want := map[string]interface{}{
+					"name":        "test",
+					"description": "Shared description",
+					"on": []interface{}{
+						map[string]interface{}{"repositoriesMatchingQuery": "lang:go"},
+						map[string]interface{}{"repository": "github.com/sourcegraph/src-cli"},
+					},
+					"steps": []interface{}{
+						map[string]interface{}{"run": "gofmt -w .", "container": "golang:1.17"},
+						map[string]interface{}{"run": "echo first", "container": "alpine:3"},
+						map[string]interface{}{"run": "go mod tidy", "container": "golang:1.17"},
+						map[string]interface{}{"run": "go vet ./...", "container": "golang:1.17"},
+					},
+					"changesetTemplate": map[string]interface{}{
+						"title":     "My title",
+						"body":      "Default body",
+						"published": true,
+						"commit":    map[string]interface{}{"message": "Default message"},
+					},
+				}
+				if diff := cmp.Diff(want, have); diff != "" {
+					t.Errorf("wrong spec (-want +have):\n%s", diff)
+				}
+			},
+		},
+		"cycle":                  {spec: "include: cycle/a.yaml\n", wantErr: []string{"include cycle"}},
+		"step with other fields": {spec: "steps:\n  - include: fragments/tidy.yaml\n    if: true\n", wantErr: anyError},
+		"missing":                {spec: "include: " + ts.URL + "/missing.yaml\n", wantErr: []string{"404"}},
+	}
+	writeFile("cycle/a.yaml", "include: b.yaml\n")
+	writeFile("cycle/b.yaml", "include: a.yaml\n")
+
+	runSpecPassTests(t, func(*Service) specPass {
+		return func(spec *yaml.Node) (bool, error) { return resolveIncludes(spec, dir) }
+	}, tests)
+}
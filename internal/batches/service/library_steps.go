@@ -0,0 +1,141 @@
+package service
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// dependencyInventoryScript is the run script of the dependency-inventory
+// library step. It prints a JSON array with an object for every go.mod,
+// package.json and pom.xml file in the workspace, containing its path, type,
+// name, and its dependencies with their versions.
+//
+//go:embed dependency_inventory.py
+var dependencyInventoryScript string
+
+const (
+	dependencyInventoryStep      = "dependency-inventory"
+	dependencyInventoryContainer = "python:3.10-alpine"
+	dependencyInventoryOutput    = "dependencies"
+)
+
+// resolveLibrarySteps replaces the steps in the batch spec that use a library
+// step with `uses:` by regular steps. The only library step is
+// dependency-inventory, which parses the dependency manifests in the workspace
+// and stores them in an output, so that later steps and the changeset template
+// can reference the dependencies without parsing the manifests themselves:
+//
+//	steps:
+//	  - uses: dependency-inventory
+//	    output: deps # optional, defaults to "dependencies"
+//	  - run: echo "${{ range outputs.deps }}${{ .path }} ${{ end }}"
+//	    container: alpine:3
+//
+// The step may set a different `container:` or `build:`, which must provide
+// python3, and all other step fields, such as `if:` and `env:`, are kept.
+func resolveLibrarySteps(spec *yaml.Node) (modified bool, err error) {
+	// Library steps can also be used in the steps of workspaces, which
+	// resolveWorkspaceSteps moves to the steps of the spec.
+	if steps := mappingValue(spec, "steps"); steps != nil && steps.Kind == yaml.SequenceNode {
+		m, err := replaceLibrarySteps(steps, func(i int) string { return fmt.Sprintf("step %d", i+1) })
+		if err != nil {
+			return false, err
+		}
+		modified = modified || m
+	}
+	if workspaces := mappingValue(spec, "workspaces"); workspaces != nil && workspaces.Kind == yaml.SequenceNode {
+		for i, conf := range workspaces.Content {
+			if conf.Kind != yaml.MappingNode {
+				continue
+			}
+			steps := mappingValue(conf, "steps")
+			if steps == nil || steps.Kind != yaml.SequenceNode {
+				continue
+			}
+			m, err := replaceLibrarySteps(steps, func(j int) string { return fmt.Sprintf("workspace %d: step %d", i+1, j+1) })
+			if err != nil {
+				return false, err
+			}
+			modified = modified || m
+		}
+	}
+	return modified, nil
+}
+
+// replaceLibrarySteps replaces the library steps in the sequence node steps.
+// Errors are prefixed with the name of the step, as returned by name for its
+// index.
+func replaceLibrarySteps(steps *yaml.Node, name func(i int) string) (modified bool, err error) {
+	for i, step := range steps.Content {
+		if step.Kind != yaml.MappingNode {
+			continue
+		}
+
+		usesIdx := mappingIndex(step, "uses")
+		if usesIdx < 0 {
+			continue
+		}
+		if uses := step.Content[usesIdx+1].Value; uses != dependencyInventoryStep {
+			return false, errors.Newf("%s: unknown library step %q", name(i), uses)
+		}
+		if mappingIndex(step, "run") >= 0 {
+			return false, errors.Newf("%s: run must not be set for library steps", name(i))
+		}
+
+		output := dependencyInventoryOutput
+		if idx := mappingIndex(step, "output"); idx >= 0 {
+			output = step.Content[idx+1].Value
+			removeMappingKey(step, idx)
+			usesIdx = mappingIndex(step, "uses")
+		}
+		removeMappingKey(step, usesIdx)
+
+		if mappingIndex(step, "container") < 0 && mappingIndex(step, "build") < 0 {
+			setMappingValue(step, "container", scalarNode(dependencyInventoryContainer))
+		}
+		setMappingValue(step, "run", scalarNode(fmt.Sprintf("python3 - <<'EOF'\n%sEOF\n", dependencyInventoryScript)))
+
+		outputs := mappingValue(step, "outputs")
+		if outputs == nil {
+			outputs = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			setMappingValue(step, "outputs", outputs)
+		}
+		if mappingIndex(outputs, output) >= 0 {
+			return false, errors.Newf("%s: output %q is already defined", name(i), output)
+		}
+		setMappingValue(outputs, output, &yaml.Node{
+			Kind: yaml.MappingNode,
+			Tag:  "!!map",
+			Content: []*yaml.Node{
+				scalarNode("value"), scalarNode("${{ step.stdout }}"),
+				scalarNode("format"), scalarNode("json"),
+			},
+		})
+		modified = true
+	}
+
+	return modified, nil
+}
+
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// setMappingValue sets the value of key in the mapping node, adding the key
+// if it doesn't exist.
+func setMappingValue(node *yaml.Node, key string, value *yaml.Node) {
+	if i := mappingIndex(node, key); i >= 0 {
+		node.Content[i+1] = value
+		return
+	}
+	node.Content = append(node.Content, scalarNode(key), value)
+}
+
+// removeMappingKey removes the key at index i, and its value, from the mapping
+// node.
+func removeMappingKey(node *yaml.Node, i int) {
+	node.Content = append(node.Content[:i], node.Content[i+2:]...)
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestResolveLibrarySteps(t *testing.T) {
+	runSpecPassTests(t, func(*Service) specPass { return resolveLibrarySteps }, map[string]specPassTest{
+		"no library steps": {spec: plainSpec, want: plainSpec},
+		"dependency inventory": {
+			spec: `name: test
+steps:
+  - uses: dependency-inventory
+    output: deps
+    if: ${{ eq repository.name "github.com/sourcegraph/src-cli" }}
+  - run: echo
+    container: alpine:3
+`,
+			check: func(t *testing.T, _ *Service, data []byte) {
+				var have struct {
+					Steps []struct {
+						Run       string
+						Container string
+						If        string
+						Outputs   map[string]struct {
+							Value  string
+							Format string
+						}
+						Uses   string
+						Output string
+					}
+				}
+				unmarshalSpec(t, data, &have)
+
+				step := have.Steps[0]
+				if step.Uses != "" || step.Output != "" {
+					t.Errorf("library step fields were not removed: %+v", step)
+				}
+				if step.Container != dependencyInventoryContainer {
+					t.Errorf("wrong container: %q", step.Container)
+				}
+				if !strings.Contains(step.Run, dependencyInventoryScript) {
+					t.Errorf("run doesn't contain the inventory script: %q", step.Run)
+				}
+				if step.If == "" {
+					t.Error("if condition was removed")
+				}
+				if diff := cmp.Diff("${{ step.stdout }}", step.Outputs["deps"].Value); diff != "" {
+					t.Errorf("wrong output value (-want +have):\n%s", diff)
+				}
+				if step.Outputs["deps"].Format != "json" {
+					t.Errorf("wrong output format: %q", step.Outputs["deps"].Format)
+				}
+				if have.Steps[1].Run != "echo" {
+					t.Errorf("other step was modified: %+v", have.Steps[1])
+				}
+			},
+		},
+		"unknown step": {spec: "steps:\n  - uses: nope\n", wantErr: []string{`unknown library step "nope"`}},
+		"run set":      {spec: "steps:\n  - uses: dependency-inventory\n    run: echo\n", wantErr: []string{"run must not be set for library steps"}},
+		"output clash": {
+			spec:    "steps:\n  - uses: dependency-inventory\n    outputs:\n      dependencies:\n        value: x\n",
+			wantErr: []string{`output "dependencies" is already defined`},
+		},
+	})
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+const mergeChangesetsMutation = `
+mutation MergeChangesets($batchChange: ID!, $changesets: [ID!]!, $squash: Boolean) {
+    mergeChangesets(batchChange: $batchChange, changesets: $changesets, squash: $squash) {
+        id
+    }
+}
+`
+
+// MergeChangesets starts a bulk operation that merges the given open
+// changesets of the batch change on the code host, squashing their commits if
+// squash is true. The ID of the bulk operation is returned.
+func (svc *Service) MergeChangesets(ctx context.Context, batchChange string, changesets []string, squash bool) (string, error) {
+	var result struct {
+		MergeChangesets struct {
+			ID string
+		}
+	}
+	if ok, err := svc.client.NewRequest(mergeChangesetsMutation, map[string]interface{}{
+		"batchChange": batchChange,
+		"changesets":  changesets,
+		"squash":      squash,
+	}).Do(ctx, &result); err != nil || !ok {
+		return "", err
+	}
+
+	return result.MergeChangesets.ID, nil
+}
+
+const bulkOperationQuery = `
+query BulkOperation($id: ID!) {
+    node(id: $id) {
+        __typename
+        ... on BulkOperation {
+            state
+            errors {
+                changeset {
+                    id
+                }
+                error
+            }
+        }
+    }
+}
+`
+
+// BulkOperationError is the error of a changeset in a bulk operation.
+type BulkOperationError struct {
+	Changeset string
+	Error     string
+}
+
+// WaitForBulkOperation polls the bulk operation with the given ID every
+// interval until it's no longer processing, and returns the errors of the
+// changesets it failed for.
+func (svc *Service) WaitForBulkOperation(ctx context.Context, id string, interval time.Duration) ([]BulkOperationError, error) {
+	for {
+		var result struct {
+			Node *struct {
+				Typename string `json:"__typename"`
+				State    string
+				Errors   []struct {
+					Changeset *struct{ ID string }
+					Error     *string
+				}
+			}
+		}
+		if ok, err := svc.client.NewRequest(bulkOperationQuery, map[string]interface{}{
+			"id": id,
+		}).Do(ctx, &result); err != nil || !ok {
+			return nil, err
+		}
+		if result.Node == nil || result.Node.Typename != "BulkOperation" {
+			return nil, errors.Newf("bulk operation %q not found", id)
+		}
+
+		if result.Node.State != "PROCESSING" {
+			var errs []BulkOperationError
+			for _, e := range result.Node.Errors {
+				var be BulkOperationError
+				if e.Changeset != nil {
+					be.Changeset = e.Changeset.ID
+				}
+				if e.Error != nil {
+					be.Error = *e.Error
+				}
+				errs = append(errs, be)
+			}
+			return errs, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
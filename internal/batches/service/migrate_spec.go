@@ -0,0 +1,205 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// SpecMigration is the result of MigrateSpec.
+type SpecMigration struct {
+	// Spec is the migrated raw batch spec.
+	Spec []byte
+	// Changes are the rewrites that were made.
+	Changes []SpecNote
+	// Manual are the constructs that couldn't be rewritten automatically and
+	// need to be looked at.
+	Manual []SpecNote
+}
+
+// SpecNote is a message about a line of a batch spec.
+type SpecNote struct {
+	Line    int
+	Message string
+}
+
+// legacyTemplateVariables are the template variables that were renamed, by
+// their old name.
+var legacyTemplateVariables = map[string]string{
+	"campaign.name":        "batch_change.name",
+	"campaign.description": "batch_change.description",
+}
+
+// templateNamespaces are the namespaces of the variables that can be used in
+// template expressions.
+var templateNamespaces = map[string]bool{
+	"repository":    true,
+	"batch_change":  true,
+	"previous_step": true,
+	"step":          true,
+	"steps":         true,
+	"outputs":       true,
+	"params":        true,
+	"env":           true,
+}
+
+var (
+	// templateExpression matches a template expression on a single line.
+	templateExpression = regexp.MustCompile(`\$\{\{.*?\}\}`)
+	// templateVariable matches the variables in a template expression, which
+	// aren't fields of other values.
+	templateVariable = regexp.MustCompile(`(^|[^\w.])([a-zA-Z_]\w*)\.([a-zA-Z_]\w*)`)
+	// templateString matches the string literals in a template expression.
+	templateString = regexp.MustCompile("\"(?:[^\"\\\\]|\\\\.)*\"|`[^`]*`")
+	// goTemplateField matches template expressions that access fields like Go
+	// templates do, which was never supported.
+	goTemplateField = regexp.MustCompile(`\$\{\{-?\s*\.`)
+)
+
+// MigrateSpec rewrites the deprecated constructs in the given raw batch spec to
+// their current equivalents, keeping the rest of the file as it is:
+//
+//   - the campaign.name and campaign.description template variables, from
+//     before campaigns were renamed to batch changes, become batch_change.name
+//     and batch_change.description
+//   - quoted "true" and "false" values of changesetTemplate.published, which
+//     older versions accepted as strings, become booleans
+//
+// The constructs that can't be rewritten without changing what the batch spec
+// does are listed as needing manual attention: published: false, which
+// prevents publishing changesets from the UI since Sourcegraph 3.30, the
+// experimental transformChanges, and template expressions with unknown
+// variables or Go template field syntax.
+func MigrateSpec(data []byte) (*SpecMigration, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, errors.Wrap(err, "parsing batch spec")
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("batch spec is not a mapping")
+	}
+	spec := root.Content[0]
+
+	m := &SpecMigration{}
+	lines := strings.SplitAfter(string(data), "\n")
+
+	if template := mappingValue(spec, "changesetTemplate"); template != nil && template.Kind == yaml.MappingNode {
+		if published := mappingValue(template, "published"); published != nil {
+			m.migratePublished(lines, published)
+		}
+	}
+
+	// The expressions are rewritten after the published field, whose edits
+	// rely on the columns of the parsed batch spec.
+	for i, line := range lines {
+		lines[i] = templateExpression.ReplaceAllStringFunc(line, func(expr string) string {
+			return m.migrateExpression(i+1, expr)
+		})
+	}
+
+	if idx := mappingIndex(spec, "transformChanges"); idx >= 0 {
+		m.Manual = append(m.Manual, SpecNote{
+			Line:    spec.Content[idx].Line,
+			Message: "transformChanges is experimental and may change between versions: check it against the batch spec reference of your Sourcegraph version",
+		})
+	}
+
+	m.Spec = []byte(strings.Join(lines, ""))
+	sort.SliceStable(m.Changes, func(i, j int) bool { return m.Changes[i].Line < m.Changes[j].Line })
+	sort.SliceStable(m.Manual, func(i, j int) bool { return m.Manual[i].Line < m.Manual[j].Line })
+	return m, nil
+}
+
+// migrateExpression returns the template expression expr on the given line
+// with the legacy variables renamed, noting the expressions that need manual
+// attention.
+func (m *SpecMigration) migrateExpression(line int, expr string) string {
+	if goTemplateField.MatchString(expr) {
+		m.Manual = append(m.Manual, SpecNote{
+			Line:    line,
+			Message: fmt.Sprintf("%s accesses a field like Go templates do: use a variable such as repository.name instead", expr),
+		})
+		return expr
+	}
+
+	// Only the parts of the expression outside of string literals are
+	// variables.
+	var b strings.Builder
+	last := 0
+	for _, loc := range append(templateString.FindAllStringIndex(expr, -1), []int{len(expr), len(expr)}) {
+		b.WriteString(m.migrateVariables(line, expr, expr[last:loc[0]]))
+		b.WriteString(expr[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	return b.String()
+}
+
+// migrateVariables renames the legacy variables in code, a part of the template
+// expression expr.
+func (m *SpecMigration) migrateVariables(line int, expr, code string) string {
+	return templateVariable.ReplaceAllStringFunc(code, func(match string) string {
+		sub := templateVariable.FindStringSubmatch(match)
+		prefix, name := sub[1], sub[2]+"."+sub[3]
+		if current, ok := legacyTemplateVariables[name]; ok {
+			m.Changes = append(m.Changes, SpecNote{
+				Line:    line,
+				Message: fmt.Sprintf("renamed template variable %s to %s", name, current),
+			})
+			return prefix + current
+		}
+		if !templateNamespaces[sub[2]] {
+			m.Manual = append(m.Manual, SpecNote{
+				Line:    line,
+				Message: fmt.Sprintf("unknown template variable %s in %s", name, expr),
+			})
+		}
+		return match
+	})
+}
+
+// migratePublished turns the quoted booleans of the published field into
+// booleans, editing the lines of the batch spec in place.
+func (m *SpecMigration) migratePublished(lines []string, published *yaml.Node) {
+	var values []*yaml.Node
+	switch published.Kind {
+	case yaml.ScalarNode:
+		values = []*yaml.Node{published}
+		if published.Style == 0 && published.Tag == "!!bool" && published.Value == "false" {
+			m.Manual = append(m.Manual, SpecNote{
+				Line:    published.Line,
+				Message: "published: false prevents publishing the changesets from the UI: remove it to control publication from the UI, which Sourcegraph 3.30 and later support",
+			})
+		}
+	case yaml.SequenceNode:
+		for _, item := range published.Content {
+			if item.Kind == yaml.MappingNode && len(item.Content) == 2 {
+				values = append(values, item.Content[1])
+			}
+		}
+	}
+
+	for _, v := range values {
+		quoted := v.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0
+		if !quoted || (v.Value != "true" && v.Value != "false") {
+			continue
+		}
+		// The column of a quoted scalar is the one of its opening quote.
+		line, col := v.Line-1, v.Column-1
+		if line >= len(lines) || col+len(v.Value)+2 > len(lines[line]) {
+			continue
+		}
+		old := lines[line][col : col+len(v.Value)+2]
+		if strings.Trim(old, `"'`) != v.Value {
+			continue
+		}
+		lines[line] = lines[line][:col] + v.Value + lines[line][col+len(old):]
+		m.Changes = append(m.Changes, SpecNote{
+			Line:    v.Line,
+			Message: fmt.Sprintf("changed published value %s to the boolean %s", old, v.Value),
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMigrateSpec(t *testing.T) {
+	spec := `name: hello-world
+# The description is rendered with ${{ campaign.name }}, too.
+description: ${{ campaign.description }}
+on:
+  - repositoriesMatchingQuery: file:README.md
+steps:
+  - run: echo ${{ replace repository.name "github.com/" "" }} >> README.md
+    container: alpine:3
+  - run: echo ${{ .Repository.Name }} ${{ step.stdout }} ${{ github.sha }}
+    container: alpine:3
+transformChanges:
+  group:
+    - directory: client
+      branch: client
+changesetTemplate:
+  title: ${{ campaign.name }}
+  body: Part of ${{campaign.name}}, see ${{ outputs.url }}.
+  branch: hello-world
+  commit:
+    message: Append Hello World to all README.md files
+  published:
+    - github.com/sourcegraph/*: "true"
+    - github.com/sourcegraph/src-cli: 'false'
+    - github.com/sourcegraph/sourcegraph: draft
+`
+
+	have, err := MigrateSpec([]byte(spec))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantSpec := `name: hello-world
+# The description is rendered with ${{ batch_change.name }}, too.
+description: ${{ batch_change.description }}
+on:
+  - repositoriesMatchingQuery: file:README.md
+steps:
+  - run: echo ${{ replace repository.name "github.com/" "" }} >> README.md
+    container: alpine:3
+  - run: echo ${{ .Repository.Name }} ${{ step.stdout }} ${{ github.sha }}
+    container: alpine:3
+transformChanges:
+  group:
+    - directory: client
+      branch: client
+changesetTemplate:
+  title: ${{ batch_change.name }}
+  body: Part of ${{batch_change.name}}, see ${{ outputs.url }}.
+  branch: hello-world
+  commit:
+    message: Append Hello World to all README.md files
+  published:
+    - github.com/sourcegraph/*: true
+    - github.com/sourcegraph/src-cli: false
+    - github.com/sourcegraph/sourcegraph: draft
+`
+	if diff := cmp.Diff(wantSpec, string(have.Spec)); diff != "" {
+		t.Errorf("wrong spec (-want +have):\n%s", diff)
+	}
+
+	wantChanges := []SpecNote{
+		{Line: 2, Message: "renamed template variable campaign.name to batch_change.name"},
+		{Line: 3, Message: "renamed template variable campaign.description to batch_change.description"},
+		{Line: 16, Message: "renamed template variable campaign.name to batch_change.name"},
+		{Line: 17, Message: "renamed template variable campaign.name to batch_change.name"},
+		{Line: 22, Message: `changed published value "true" to the boolean true`},
+		{Line: 23, Message: `changed published value 'false' to the boolean false`},
+	}
+	if diff := cmp.Diff(wantChanges, have.Changes); diff != "" {
+		t.Errorf("wrong changes (-want +have):\n%s", diff)
+	}
+
+	wantManual := []SpecNote{
+		{Line: 9, Message: "${{ .Repository.Name }} accesses a field like Go templates do: use a variable such as repository.name instead"},
+		{Line: 9, Message: "unknown template variable github.sha in ${{ github.sha }}"},
+		{Line: 11, Message: "transformChanges is experimental and may change between versions: check it against the batch spec reference of your Sourcegraph version"},
+	}
+	if diff := cmp.Diff(wantManual, have.Manual); diff != "" {
+		t.Errorf("wrong manual notes (-want +have):\n%s", diff)
+	}
+}
+
+func TestMigrateSpecPublishedFalse(t *testing.T) {
+	have, err := MigrateSpec([]byte("name: x\nchangesetTemplate:\n  published: false\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(have.Changes) != 0 || len(have.Manual) != 1 || have.Manual[0].Line != 3 {
+		t.Errorf("unexpected migration: %+v", have)
+	}
+
+	if _, err := MigrateSpec([]byte("- not a mapping")); err == nil {
+		t.Error("want error for a batch spec that isn't a mapping")
+	}
+}
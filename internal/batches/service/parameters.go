@@ -0,0 +1,244 @@
+package service
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/hashicorp/go-multierror"
+	"gopkg.in/yaml.v3"
+)
+
+// batchSpecParameter is a parameter declared in the `parameters:` field of a
+// batch spec.
+type batchSpecParameter struct {
+	Type        string    `yaml:"type"`
+	Default     yaml.Node `yaml:"default"`
+	Description string    `yaml:"description"`
+}
+
+// The types of batch spec parameters.
+const (
+	parameterTypeString  = "string"
+	parameterTypeNumber  = "number"
+	parameterTypeBoolean = "boolean"
+)
+
+// loadTimeExpression matches the template expressions that are replaced when
+// the batch spec is loaded. All other template expressions are left to be
+// rendered when the steps are executed.
+var loadTimeExpression = regexp.MustCompile(`\$\{\{\s*(params|env)\.([A-Za-z_][A-Za-z0-9_-]*)\s*\}\}`)
+
+// resolveParameters replaces the `${{ params.NAME }}` and `${{ env.NAME }}`
+// template expressions in the batch spec with the values of the parameters
+// and environment variables, so that one batch spec can be used for many
+// variations of a batch change:
+//
+//	parameters:
+//	  version:
+//	    type: string
+//	    description: The version of the library to upgrade to.
+//	  publish:
+//	    type: boolean
+//	    default: false
+//	steps:
+//	  - run: go get example.com/lib@${{ params.version }}
+//	    container: golang:1.17
+//	changesetTemplate:
+//	  title: Upgrade to ${{ params.version }} in ${{ env.TEAM }}
+//	  published: ${{ params.publish }}
+//
+// The values of the parameters are given by values, as strings, or by their
+// default. Parameters are of type string, the default, number or boolean. A
+// value that consists of only one expression of a number or boolean parameter
+// gets that type in the batch spec.
+//
+// The replaced values end up in the batch spec that is sent to Sourcegraph,
+// so environment variables can't be used in the env of a step, where they'd
+// usually hold secrets. Those have to be passed through by name and are
+// resolved when the step is executed.
+//
+// All parameters and environment variables are checked before anything is
+// replaced: values for undeclared parameters, parameters without a value,
+// values that aren't of the type of their parameter, and expressions of
+// undeclared parameters, of unset environment variables or of environment
+// variables in the env of a step are errors.
+func resolveParameters(spec *yaml.Node, values map[string]string, lookupEnv func(string) (string, bool)) (modified bool, err error) {
+	var declared map[string]batchSpecParameter
+	if idx := mappingIndex(spec, "parameters"); idx >= 0 {
+		if err := spec.Content[idx+1].Decode(&declared); err != nil {
+			return false, errors.Wrap(err, "parsing parameters")
+		}
+		removeMappingKey(spec, idx)
+		modified = true
+	}
+
+	params, err := parameterValues(declared, values)
+	if err != nil {
+		return false, err
+	}
+
+	stepEnv := map[*yaml.Node]bool{}
+	if steps := mappingValue(spec, "steps"); steps != nil {
+		for _, step := range steps.Content {
+			if env := mappingValue(step, "env"); env != nil {
+				walkScalars(env, func(node *yaml.Node) { stepEnv[node] = true })
+			}
+		}
+	}
+
+	// Check all expressions before replacing any, so that all errors are
+	// reported at once.
+	var errs *multierror.Error
+	var scalars []*yaml.Node
+	walkScalars(spec, func(node *yaml.Node) {
+		matches := loadTimeExpression.FindAllStringSubmatch(node.Value, -1)
+		if len(matches) == 0 {
+			return
+		}
+		scalars = append(scalars, node)
+		for _, m := range matches {
+			switch m[1] {
+			case "params":
+				if _, ok := params[m[2]]; !ok {
+					errs = multierror.Append(errs, errors.Newf("line %d: parameter %q is not declared", node.Line, m[2]))
+				}
+			case "env":
+				if stepEnv[node] {
+					errs = multierror.Append(errs, errors.Newf("line %d: environment variable %s can't be used in the env of a step, where its value would be stored in the batch spec: pass it through with `- %s` instead", node.Line, m[2], m[2]))
+				} else if _, ok := lookupEnv(m[2]); !ok {
+					errs = multierror.Append(errs, errors.Newf("line %d: environment variable %s is not set", node.Line, m[2]))
+				}
+			}
+		}
+	})
+	if err := errs.ErrorOrNil(); err != nil {
+		return false, err
+	}
+
+	for _, node := range scalars {
+		if m := loadTimeExpression.FindStringSubmatch(node.Value); m != nil && m[0] == strings.TrimSpace(node.Value) && m[1] == "params" {
+			// The whole value is a parameter, which keeps its type.
+			p := params[m[2]]
+			node.Value, node.Tag, node.Style = p.value, p.tag, 0
+			continue
+		}
+
+		node.Value = loadTimeExpression.ReplaceAllStringFunc(node.Value, func(expr string) string {
+			m := loadTimeExpression.FindStringSubmatch(expr)
+			if m[1] == "params" {
+				return params[m[2]].value
+			}
+			value, _ := lookupEnv(m[2])
+			return value
+		})
+		node.Tag = "!!str"
+	}
+	return modified || len(scalars) > 0, nil
+}
+
+// parameterValue is the value of a parameter, and its YAML tag.
+type parameterValue struct {
+	value string
+	tag   string
+}
+
+// parameterValues returns the values of the declared parameters, which are
+// given by values or by their defaults.
+func parameterValues(declared map[string]batchSpecParameter, values map[string]string) (map[string]parameterValue, error) {
+	var errs *multierror.Error
+
+	var unknown []string
+	for name := range values {
+		if _, ok := declared[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		errs = multierror.Append(errs, errors.Newf("parameter %q is not declared in the batch spec", name))
+	}
+
+	names := make([]string, 0, len(declared))
+	for name := range declared {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	params := make(map[string]parameterValue, len(declared))
+	for _, name := range names {
+		p := declared[name]
+
+		value, ok := values[name]
+		if !ok {
+			if p.Default.Kind == 0 {
+				errs = multierror.Append(errs, errors.Newf("parameter %q has no default and no value was given with -param %s=VALUE", name, name))
+				continue
+			}
+			if p.Default.Kind != yaml.ScalarNode {
+				errs = multierror.Append(errs, errors.Newf("parameter %q: default must be a %s", name, parameterType(p)))
+				continue
+			}
+			value = p.Default.Value
+		}
+
+		pv, err := typedParameterValue(parameterType(p), value)
+		if err != nil {
+			errs = multierror.Append(errs, errors.Wrapf(err, "parameter %q", name))
+			continue
+		}
+		params[name] = pv
+	}
+
+	return params, errs.ErrorOrNil()
+}
+
+func parameterType(p batchSpecParameter) string {
+	if p.Type == "" {
+		return parameterTypeString
+	}
+	return p.Type
+}
+
+// typedParameterValue checks that value is of the given type, and returns it
+// in its canonical form.
+func typedParameterValue(typ, value string) (parameterValue, error) {
+	switch typ {
+	case parameterTypeString:
+		return parameterValue{value: value, tag: "!!str"}, nil
+
+	case parameterTypeNumber:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return parameterValue{}, errors.Newf("%q is not a number", value)
+		}
+		if f == float64(int64(f)) {
+			return parameterValue{value: strconv.FormatInt(int64(f), 10), tag: "!!int"}, nil
+		}
+		return parameterValue{value: strconv.FormatFloat(f, 'g', -1, 64), tag: "!!float"}, nil
+
+	case parameterTypeBoolean:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return parameterValue{}, errors.Newf("%q is not a boolean", value)
+		}
+		return parameterValue{value: strconv.FormatBool(b), tag: "!!bool"}, nil
+
+	default:
+		return parameterValue{}, errors.Newf("unknown type %q, must be %s, %s or %s", typ, parameterTypeString, parameterTypeNumber, parameterTypeBoolean)
+	}
+}
+
+// walkScalars calls fn for every scalar node below node, including the keys
+// of mappings.
+func walkScalars(node *yaml.Node, fn func(*yaml.Node)) {
+	if node.Kind == yaml.ScalarNode {
+		fn(node)
+		return
+	}
+	for _, child := range node.Content {
+		walkScalars(child, fn)
+	}
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v3"
+)
+
+func TestResolveParameters(t *testing.T) {
+	env := map[string]string{"TEAM": "platform", "GOPROXY": "https://proxy.example.com"}
+	lookupEnv := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	spec := `name: bump-${{ params.version }}
+parameters:
+  version:
+    description: The version to upgrade to.
+  publish:
+    type: boolean
+    default: false
+  parallelism:
+    type: number
+    default: 4
+steps:
+  - run: go get example.com/lib@${{ params.version }} && echo ${{ repository.name }}
+    container: golang:1.17
+    env:
+      PARALLELISM: ${{ params.parallelism }}
+changesetTemplate:
+  title: Upgrade to ${{ params.version }} in ${{ env.TEAM }}
+  published: ${{ params.publish }}
+`
+
+	resolve := func(values map[string]string) func(*Service) specPass {
+		return func(*Service) specPass {
+			return func(spec *yaml.Node) (bool, error) { return resolveParameters(spec, values, lookupEnv) }
+		}
+	}
+	for name, tc := range map[string]struct {
+		values map[string]string
+		specPassTest
+	}{
+		"no parameters": {
+			specPassTest: specPassTest{
+				spec: "name: test\nsteps:\n  - run: echo ${{ repository.name }}\n    container: alpine:3\n",
+				want: "name: test\nsteps:\n  - run: echo ${{ repository.name }}\n    container: alpine:3\n",
+			},
+		},
+		"resolved": {
+			values: map[string]string{"version": "1.2.0", "publish": "true"},
+			specPassTest: specPassTest{
+				spec: spec,
+				check: func(t *testing.T, _ *Service, data []byte) {
+					var have map[string]interface{}
+					unmarshalSpec(t, data, &have)
+					want := map[string]interface{}{
+						"name": "bump-1.2.0",
+						"steps": []interface{}{
+							map[string]interface{}{
+								"run":       "go get example.com/lib@1.2.0 && echo ${{ repository.name }}",
+								"container": "golang:1.17",
+								"env": map[string]interface{}{
+									"PARALLELISM": 4,
+								},
+							},
+						},
+						"changesetTemplate": map[string]interface{}{
+							"title":     "Upgrade to 1.2.0 in platform",
+							"published": true,
+						},
+					}
+					if diff := cmp.Diff(want, have); diff != "" {
+						t.Errorf("wrong spec (-want +have):\n%s", diff)
+					}
+				},
+			},
+		},
+		"missing value": {
+			specPassTest: specPassTest{
+				spec:    spec,
+				wantErr: []string{`parameter "version" has no default`},
+			},
+		},
+		"wrong type": {
+			values: map[string]string{"version": "1.2.0", "publish": "maybe", "parallelism": "many"},
+			specPassTest: specPassTest{
+				spec:    spec,
+				wantErr: []string{`"maybe" is not a boolean`, `"many" is not a number`},
+			},
+		},
+		"undeclared value": {
+			values: map[string]string{"version": "1.2.0", "verison": "1.2.0"},
+			specPassTest: specPassTest{
+				spec:    spec,
+				wantErr: []string{`parameter "verison" is not declared in the batch spec`},
+			},
+		},
+		"undeclared expressions": {
+			specPassTest: specPassTest{
+				spec:    "name: ${{ params.name }}\ndescription: ${{ env.DESCRIPTION }}\n",
+				wantErr: []string{`line 1: parameter "name" is not declared`, `line 2: environment variable DESCRIPTION is not set`},
+			},
+		},
+		"environment variable in step env": {
+			specPassTest: specPassTest{
+				spec:    "steps:\n  - run: go get ./...\n    container: golang:1.17\n    env:\n      - GOPROXY=${{ env.GOPROXY }}\n",
+				wantErr: []string{"line 5: environment variable GOPROXY can't be used in the env of a step"},
+			},
+		},
+		"unknown type": {
+			specPassTest: specPassTest{
+				spec:    "parameters:\n  version:\n    type: semver\n    default: 1.0.0\n",
+				wantErr: []string{`unknown type "semver"`},
+			},
+		},
+	} {
+		runSpecPassTests(t, resolve(tc.values), map[string]specPassTest{name: tc.specPassTest})
+	}
+}
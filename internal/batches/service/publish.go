@@ -0,0 +1,323 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gobwas/glob"
+)
+
+// Changeset is a changeset of a batch change.
+type Changeset struct {
+	ID         string
+	Repository string
+	// State is the ChangesetState of the changeset, such as UNPUBLISHED,
+	// DRAFT, or OPEN.
+	State string
+	// ReviewState is the ChangesetReviewState of the changeset, such as
+	// PENDING or APPROVED, if it's published.
+	ReviewState string
+	// CheckState is the ChangesetCheckState of the changeset, such as PASSED
+	// or FAILED, if the code host reports checks.
+	CheckState string
+	// URL is the URL of the changeset on the code host, if it's published.
+	URL string
+	// DependsOn are the repositories whose changesets must be merged before
+	// this one. See resolveChangesetDependencies.
+	DependsOn []string
+}
+
+const batchChangeChangesetsQuery = `
+query BatchChangeChangesets($namespace: ID!, $name: String!, $after: String) {
+    batchChange(namespace: $namespace, name: $name) {
+        id
+        changesets(first: 100, after: $after) {
+            nodes {
+                __typename
+                ... on ExternalChangeset {
+                    id
+                    state
+                    reviewState
+                    checkState
+                    body
+                    externalURL {
+                        url
+                    }
+                    repository {
+                        name
+                    }
+                }
+            }
+            pageInfo {
+                hasNextPage
+                endCursor
+            }
+        }
+    }
+}
+`
+
+// FetchChangesets returns the ID of the batch change with the given name in
+// the given namespace and its changesets. Changesets in repositories the user
+// doesn't have access to are omitted.
+func (svc *Service) FetchChangesets(ctx context.Context, namespace, name string) (string, []Changeset, error) {
+	var (
+		id         string
+		changesets []Changeset
+		after      *string
+	)
+
+	for {
+		var result struct {
+			BatchChange *struct {
+				ID         string
+				Changesets struct {
+					Nodes []struct {
+						Typename    string `json:"__typename"`
+						ID          string
+						State       string
+						ReviewState *string
+						CheckState  *string
+						Body        *string
+						ExternalURL *struct{ URL string }
+						Repository  struct{ Name string }
+					}
+					PageInfo struct {
+						HasNextPage bool
+						EndCursor   *string
+					}
+				}
+			}
+		}
+		if ok, err := svc.client.NewRequest(batchChangeChangesetsQuery, map[string]interface{}{
+			"namespace": namespace,
+			"name":      name,
+			"after":     after,
+		}).Do(ctx, &result); err != nil || !ok {
+			return "", nil, err
+		}
+		if result.BatchChange == nil {
+			return "", nil, errors.Newf("batch change %q not found", name)
+		}
+
+		id = result.BatchChange.ID
+		for _, node := range result.BatchChange.Changesets.Nodes {
+			if node.Typename != "ExternalChangeset" {
+				continue
+			}
+			cs := Changeset{
+				ID:         node.ID,
+				Repository: node.Repository.Name,
+				State:      node.State,
+			}
+			if node.ReviewState != nil {
+				cs.ReviewState = *node.ReviewState
+			}
+			if node.CheckState != nil {
+				cs.CheckState = *node.CheckState
+			}
+			if node.ExternalURL != nil {
+				cs.URL = node.ExternalURL.URL
+			}
+			if node.Body != nil {
+				cs.DependsOn = parseChangesetDependencies(*node.Body)
+			}
+			changesets = append(changesets, cs)
+		}
+
+		if !result.BatchChange.Changesets.PageInfo.HasNextPage {
+			return id, changesets, nil
+		}
+		after = result.BatchChange.Changesets.PageInfo.EndCursor
+	}
+}
+
+const publishChangesetsMutation = `
+mutation PublishChangesets($batchChange: ID!, $changesets: [ID!]!, $draft: Boolean) {
+    publishChangesets(batchChange: $batchChange, changesets: $changesets, draft: $draft) {
+        id
+    }
+}
+`
+
+// PublishChangesets starts a bulk operation that publishes the given
+// changesets of the batch change, either as drafts or, if draft is false, as
+// regular changesets. Changesets that are already published as drafts are
+// converted into regular changesets. The ID of the bulk operation is
+// returned.
+//
+// Only changesets whose changeset spec doesn't set the published field can be
+// published this way.
+func (svc *Service) PublishChangesets(ctx context.Context, batchChange string, changesets []string, draft bool) (string, error) {
+	var result struct {
+		PublishChangesets struct {
+			ID string
+		}
+	}
+	if ok, err := svc.client.NewRequest(publishChangesetsMutation, map[string]interface{}{
+		"batchChange": batchChange,
+		"changesets":  changesets,
+		"draft":       draft,
+	}).Do(ctx, &result); err != nil || !ok {
+		return "", err
+	}
+
+	return result.PublishChangesets.ID, nil
+}
+
+// PublishOperation is a bulk operation started by PublishChangesetsPaced.
+type PublishOperation struct {
+	// ID is the ID of the bulk operation.
+	ID string
+	// Changesets are the IDs of the changesets published by the operation.
+	Changesets []string
+}
+
+// PublishChangesetsPaced publishes the given changesets like
+// PublishChangesets, but starts a new bulk operation for at most perMinute
+// changesets each minute, so that publishing a large batch change doesn't
+// exhaust the API rate limits on the code host. If perMinute is 0, all
+// changesets are published at once.
+//
+// progress is called after each bulk operation has been started with the
+// operation and the number of changesets published so far.
+func (svc *Service) PublishChangesetsPaced(ctx context.Context, batchChange string, changesets []string, draft bool, perMinute int, progress func(op PublishOperation, done, total int)) error {
+	if perMinute <= 0 {
+		perMinute = len(changesets)
+	}
+
+	done := 0
+	for i, chunk := range chunkStrings(changesets, perMinute) {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Minute):
+			}
+		}
+
+		id, err := svc.PublishChangesets(ctx, batchChange, chunk, draft)
+		if err != nil {
+			return err
+		}
+		done += len(chunk)
+		progress(PublishOperation{ID: id, Changesets: chunk}, done, len(changesets))
+	}
+
+	return nil
+}
+
+// chunkStrings splits s into chunks of at most size elements.
+func chunkStrings(s []string, size int) [][]string {
+	var chunks [][]string
+	for size > 0 && len(s) > 0 {
+		if len(s) < size {
+			size = len(s)
+		}
+		chunks = append(chunks, s[:size])
+		s = s[size:]
+	}
+	return chunks
+}
+
+// PublishableChangesets returns the changesets that can be published. If draft
+// is true, these are the unpublished changesets, otherwise drafts are included
+// too, so that they can be converted into regular changesets. If repos is not
+// empty, only changesets in the given repositories are returned.
+func PublishableChangesets(changesets []Changeset, draft bool, repos []string) []Changeset {
+	inRepos := make(map[string]bool, len(repos))
+	for _, r := range repos {
+		inRepos[r] = true
+	}
+
+	var publishable []Changeset
+	for _, cs := range changesets {
+		if len(repos) > 0 && !inRepos[cs.Repository] {
+			continue
+		}
+		if cs.State == "UNPUBLISHED" || (!draft && cs.State == "DRAFT") {
+			publishable = append(publishable, cs)
+		}
+	}
+	return publishable
+}
+
+// SelectChangesets returns the changesets that the given selection, as entered
+// by the user, selects. The selection is a list of terms separated by commas
+// or whitespace, each of which is either a number or range of numbers such as
+// 2-5, which select changesets by their 1-based position in changesets, a
+// glob pattern matching repository names, or "all". The changesets are
+// returned in their original order, each at most once.
+func SelectChangesets(changesets []Changeset, selection string) ([]Changeset, error) {
+	selected := make([]bool, len(changesets))
+	terms := strings.FieldsFunc(selection, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+	for _, term := range terms {
+		if term == "all" {
+			for i := range selected {
+				selected[i] = true
+			}
+			continue
+		}
+
+		if from, to, ok, err := parseSelectionRange(term); ok {
+			if err != nil {
+				return nil, err
+			}
+			if from < 1 || to > len(changesets) || from > to {
+				return nil, errors.Newf("%s is out of range: there are %d changesets", term, len(changesets))
+			}
+			for i := from; i <= to; i++ {
+				selected[i-1] = true
+			}
+			continue
+		}
+
+		g, err := glob.Compile(term)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid repository pattern %q", term)
+		}
+		matched := false
+		for i, cs := range changesets {
+			if g.Match(cs.Repository) {
+				selected[i] = true
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, errors.Newf("no changeset is in a repository matching %q", term)
+		}
+	}
+
+	var result []Changeset
+	for i, cs := range changesets {
+		if selected[i] {
+			result = append(result, cs)
+		}
+	}
+	return result, nil
+}
+
+// parseSelectionRange parses a number or range of numbers in a selection. ok is
+// false if the term isn't made up of digits and at most one dash.
+func parseSelectionRange(term string) (from, to int, ok bool, err error) {
+	if strings.Trim(term, "0123456789-") != "" || strings.Count(term, "-") > 1 {
+		return 0, 0, false, nil
+	}
+
+	start, end := term, term
+	if i := strings.Index(term, "-"); i >= 0 {
+		start, end = term[:i], term[i+1:]
+	}
+	if from, err = strconv.Atoi(start); err != nil {
+		return 0, 0, true, errors.Newf("invalid range %q", term)
+	}
+	if to, err = strconv.Atoi(end); err != nil {
+		return 0, 0, true, errors.Newf("invalid range %q", term)
+	}
+	return from, to, true, nil
+}
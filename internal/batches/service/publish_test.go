@@ -0,0 +1,94 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPublishableChangesets(t *testing.T) {
+	changesets := []Changeset{
+		{ID: "1", Repository: "github.com/sourcegraph/a", State: "UNPUBLISHED"},
+		{ID: "2", Repository: "github.com/sourcegraph/b", State: "DRAFT"},
+		{ID: "3", Repository: "github.com/sourcegraph/c", State: "OPEN"},
+		{ID: "4", Repository: "github.com/sourcegraph/d", State: "UNPUBLISHED"},
+	}
+
+	for name, tc := range map[string]struct {
+		draft bool
+		repos []string
+		want  []string
+	}{
+		"draft":         {draft: true, want: []string{"1", "4"}},
+		"publish":       {draft: false, want: []string{"1", "2", "4"}},
+		"limited repos": {draft: false, repos: []string{"github.com/sourcegraph/b", "github.com/sourcegraph/c"}, want: []string{"2"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var have []string
+			for _, cs := range PublishableChangesets(changesets, tc.draft, tc.repos) {
+				have = append(have, cs.ID)
+			}
+			if diff := cmp.Diff(tc.want, have); diff != "" {
+				t.Errorf("wrong changesets (-want +have):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestChunkStrings(t *testing.T) {
+	for name, tc := range map[string]struct {
+		s    []string
+		size int
+		want [][]string
+	}{
+		"empty":   {s: nil, size: 2, want: nil},
+		"exact":   {s: []string{"a", "b", "c", "d"}, size: 2, want: [][]string{{"a", "b"}, {"c", "d"}}},
+		"partial": {s: []string{"a", "b", "c"}, size: 2, want: [][]string{{"a", "b"}, {"c"}}},
+		"large":   {s: []string{"a", "b"}, size: 10, want: [][]string{{"a", "b"}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, chunkStrings(tc.s, tc.size)); diff != "" {
+				t.Errorf("wrong chunks (-want +have):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSelectChangesets(t *testing.T) {
+	changesets := []Changeset{
+		{ID: "1", Repository: "github.com/sourcegraph/a"},
+		{ID: "2", Repository: "github.com/sourcegraph/b"},
+		{ID: "3", Repository: "gitlab.com/sourcegraph/c"},
+		{ID: "4", Repository: "github.com/other/d"},
+	}
+
+	for selection, want := range map[string][]string{
+		"":                         nil,
+		"all":                      {"1", "2", "3", "4"},
+		"2":                        {"2"},
+		"4, 1":                     {"1", "4"},
+		"2-3 3-4":                  {"2", "3", "4"},
+		"github.com/sourcegraph/*": {"1", "2"},
+		"1,gitlab.com/*":           {"1", "3"},
+	} {
+		t.Run(selection, func(t *testing.T) {
+			selected, err := SelectChangesets(changesets, selection)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var have []string
+			for _, cs := range selected {
+				have = append(have, cs.ID)
+			}
+			if diff := cmp.Diff(want, have); diff != "" {
+				t.Errorf("wrong changesets (-want +have):\n%s", diff)
+			}
+		})
+	}
+
+	for _, selection := range []string{"0", "5", "3-2", "1-2-3", "-", "bitbucket.org/*", "github.com/[a"} {
+		if _, err := SelectChangesets(changesets, selection); err == nil {
+			t.Errorf("unexpected nil error for %q", selection)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+	"gopkg.in/yaml.v3"
+)
+
+// ResolveLocally merges the fragments the batch spec includes into it,
+// replaces its parameters with the given values and its environment
+// variables with their values as looked up by lookupEnv, and replaces its
+// library steps. Unlike the other fields that src resolves, these don't
+// depend on the Sourcegraph instance. dir is the directory of the batch spec,
+// which includes are relative to.
+func ResolveLocally(data []byte, dir string, params map[string]string, lookupEnv func(string) (string, bool)) ([]byte, error) {
+	return resolveSpecWithParameters(data, params, localSpecPasses(dir, params, lookupEnv)...)
+}
+
+// localSpecPasses are the passes of ResolveLocally.
+func localSpecPasses(dir string, params map[string]string, lookupEnv func(string) (string, bool)) []specPass {
+	return []specPass{
+		func(spec *yaml.Node) (bool, error) { return resolveIncludes(spec, dir) },
+		func(spec *yaml.Node) (bool, error) { return resolveParameters(spec, params, lookupEnv) },
+		resolveLibrarySteps,
+	}
+}
+
+// ResolveBatchSpec resolves all the fields of the raw batch spec that src
+// resolves itself, in the order they depend on each other, and parses the
+// result. The service is configured for executing the batch spec along the
+// way, so a service handles one batch spec at a time.
+//
+// It returns the parsed batch spec and the resolved raw batch spec, which is
+// what's uploaded to Sourcegraph. If the batch spec has validation errors,
+// they're returned together with the raw batch spec. The environment
+// variables that replaced the `${{ env.NAME }}` expressions are remembered,
+// see LoadTimeEnv.
+func (svc *Service) ResolveBatchSpec(data []byte, dir string, params map[string]string, lookupEnv func(string) (string, bool)) (*batcheslib.BatchSpec, []byte, error) {
+	svc.loadTimeEnv = map[string]string{}
+	recordEnv := func(name string) (string, bool) {
+		value, ok := lookupEnv(name)
+		if ok {
+			svc.loadTimeEnv[name] = value
+		}
+		return value, ok
+	}
+
+	passes := append(localSpecPasses(dir, params, recordEnv),
+		svc.resolveWorkspaceSteps,
+		func(spec *yaml.Node) (bool, error) { return svc.resolveStepBuilds(spec, dir) },
+		svc.resolveWorkspaceStrategies,
+		svc.resolveFileFilters,
+		svc.resolveStepCommits,
+		svc.resolveConcurrency,
+		svc.resolveStepCacheKeyPaths,
+		func(spec *yaml.Node) (bool, error) { return svc.resolveStepEnvironments(spec, dir, lookupEnv) },
+		svc.resolveCodeHostOptions,
+		svc.resolveChangesetDependencies,
+	)
+	data, err := resolveSpecWithParameters(data, params, passes...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	spec, err := svc.ParseBatchSpec(data)
+	return spec, data, err
+}
+
+// LoadTimeEnv returns the environment variables that replaced the
+// `${{ env.NAME }}` expressions of the batch spec last resolved by
+// ResolveBatchSpec, by name. Secrets passed through to steps aren't included.
+func (svc *Service) LoadTimeEnv() map[string]string {
+	return svc.loadTimeEnv
+}
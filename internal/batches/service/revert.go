@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cockroachdb/errors"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/diff"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/util"
+)
+
+// MergedChangeset is a changeset of a batch change that has been merged on
+// the code host.
+type MergedChangeset struct {
+	Title       string
+	ExternalURL string
+	Repository  *graphql.Repository
+	Diff        string
+}
+
+const mergedChangesetsQuery = `
+query BatchChangeMergedChangesets($namespace: ID!, $name: String!, $after: String, $queryCommit: Boolean!, $rev: String!) {
+    batchChange(namespace: $namespace, name: $name) {
+        changesets(first: 100, after: $after, state: MERGED) {
+            nodes {
+                __typename
+                ... on ExternalChangeset {
+                    title
+                    externalURL {
+                        url
+                    }
+                    repository {
+                        ...repositoryFields
+                    }
+                    diff {
+                        ... on RepositoryComparison {
+                            fileDiffs {
+                                rawDiff
+                            }
+                        }
+                    }
+                }
+            }
+            pageInfo {
+                hasNextPage
+                endCursor
+            }
+        }
+    }
+}
+` + graphql.RepositoryFieldsFragment
+
+// FetchMergedChangesets returns the merged changesets of the batch change with
+// the given name in the given namespace, together with their diffs.
+func (svc *Service) FetchMergedChangesets(ctx context.Context, namespace, name string) ([]MergedChangeset, error) {
+	var (
+		changesets []MergedChangeset
+		after      *string
+	)
+
+	for {
+		var result struct {
+			BatchChange *struct {
+				Changesets struct {
+					Nodes []struct {
+						Typename    string `json:"__typename"`
+						Title       string
+						ExternalURL *struct{ URL string }
+						Repository  *graphql.Repository
+						Diff        *struct {
+							FileDiffs struct{ RawDiff string }
+						}
+					}
+					PageInfo struct {
+						HasNextPage bool
+						EndCursor   *string
+					}
+				}
+			}
+		}
+		if ok, err := svc.client.NewRequest(mergedChangesetsQuery, map[string]interface{}{
+			"namespace":   namespace,
+			"name":        name,
+			"after":       after,
+			"queryCommit": false,
+			"rev":         "",
+		}).Do(ctx, &result); err != nil || !ok {
+			return nil, err
+		}
+		if result.BatchChange == nil {
+			return nil, errors.Newf("batch change %q not found", name)
+		}
+
+		for _, node := range result.BatchChange.Changesets.Nodes {
+			if node.Typename != "ExternalChangeset" || node.Repository == nil || node.Diff == nil {
+				continue
+			}
+
+			cs := MergedChangeset{
+				Title:      node.Title,
+				Repository: node.Repository,
+				Diff:       node.Diff.FileDiffs.RawDiff,
+			}
+			if node.ExternalURL != nil {
+				cs.ExternalURL = node.ExternalURL.URL
+			}
+			changesets = append(changesets, cs)
+		}
+
+		if !result.BatchChange.Changesets.PageInfo.HasNextPage {
+			return changesets, nil
+		}
+		after = result.BatchChange.Changesets.PageInfo.EndCursor
+	}
+}
+
+// RevertOpts configures the changeset specs built by
+// BuildRevertChangesetSpecs.
+type RevertOpts struct {
+	// BatchChange is the name of the batch change that is reverted.
+	BatchChange string
+	// Branch is the branch that is created in each repository.
+	Branch string
+}
+
+// BuildRevertChangesetSpecs builds a changeset spec for each of the given
+// changesets, whose diff undoes the changes the merged changeset made. The
+// changesets target the default branch of their repository.
+func (svc *Service) BuildRevertChangesetSpecs(changesets []MergedChangeset, opts RevertOpts) ([]*batcheslib.ChangesetSpec, error) {
+	var published interface{} = nil
+	if !svc.features.AllowOptionalPublished {
+		published = false
+	}
+
+	var authorName, authorEmail string
+	if svc.features.IncludeAutoAuthorDetails {
+		authorName = "Sourcegraph"
+		authorEmail = "batch-changes@sourcegraph.com"
+	}
+
+	specs := make([]*batcheslib.ChangesetSpec, 0, len(changesets))
+	for _, cs := range changesets {
+		if cs.Repository.DefaultBranch == nil {
+			return nil, errors.Newf("repository %q has no default branch", cs.Repository.Name)
+		}
+
+		reversed, err := diff.Reverse(cs.Diff)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reversing diff in %q", cs.Repository.Name)
+		}
+
+		body := fmt.Sprintf("This reverts the changes made by batch change %s", opts.BatchChange)
+		if cs.ExternalURL != "" {
+			body += " in " + cs.ExternalURL
+		}
+		body += "."
+
+		specs = append(specs, &batcheslib.ChangesetSpec{
+			BaseRepository: cs.Repository.ID,
+			BaseRef:        cs.Repository.BaseRef(),
+			BaseRev:        cs.Repository.Rev(),
+			HeadRepository: cs.Repository.ID,
+			HeadRef:        util.EnsureRefPrefix(opts.Branch),
+			Title:          "Revert: " + cs.Title,
+			Body:           body,
+			Commits: []batcheslib.GitCommitDescription{
+				{
+					Message:     fmt.Sprintf("Revert %q", cs.Title),
+					AuthorName:  authorName,
+					AuthorEmail: authorEmail,
+					Diff:        reversed,
+				},
+			},
+			Published: batcheslib.PublishedValue{Val: published},
+		})
+	}
+
+	return specs, nil
+}
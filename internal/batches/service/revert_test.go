@@ -0,0 +1,59 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+)
+
+func TestService_BuildRevertChangesetSpecs(t *testing.T) {
+	repo := &graphql.Repository{
+		ID:            "repo-id",
+		Name:          "github.com/sourcegraph/src-cli",
+		DefaultBranch: &graphql.Branch{Name: "main", Target: graphql.Target{OID: "f00b4r"}},
+	}
+
+	svc := &Service{features: batches.FeatureFlags{AllowOptionalPublished: true}}
+	specs, err := svc.BuildRevertChangesetSpecs([]MergedChangeset{
+		{
+			Title:       "Hello world",
+			ExternalURL: "https://github.com/sourcegraph/src-cli/pull/1",
+			Repository:  repo,
+			Diff:        "--- a.txt\n+++ a.txt\n@@ -1 +1 @@\n-a\n+b\n",
+		},
+	}, RevertOpts{BatchChange: "hello-world", Branch: "revert-hello-world"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*batcheslib.ChangesetSpec{
+		{
+			BaseRepository: "repo-id",
+			BaseRef:        "refs/heads/main",
+			BaseRev:        "f00b4r",
+			HeadRepository: "repo-id",
+			HeadRef:        "refs/heads/revert-hello-world",
+			Title:          "Revert: Hello world",
+			Body:           "This reverts the changes made by batch change hello-world in https://github.com/sourcegraph/src-cli/pull/1.",
+			Commits: []batcheslib.GitCommitDescription{
+				{
+					Message: `Revert "Hello world"`,
+					Diff:    "--- a.txt\n+++ a.txt\n@@ -1,1 +1,1 @@\n-b\n+a\n",
+				},
+			},
+			Published: batcheslib.PublishedValue{Val: nil},
+		},
+	}
+	if diff := cmp.Diff(want, specs); diff != "" {
+		t.Errorf("wrong specs (-want +have):\n%s", diff)
+	}
+
+	repo.DefaultBranch = nil
+	if _, err := svc.BuildRevertChangesetSpecs([]MergedChangeset{{Repository: repo}}, RevertOpts{}); err == nil {
+		t.Error("unexpected nil error for repository without default branch")
+	}
+}
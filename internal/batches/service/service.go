@@ -10,13 +10,17 @@ import (
 	"path"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/cockroachdb/errors"
+	"github.com/hashicorp/go-multierror"
+	"github.com/neelance/parallel"
 
 	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
 
 	"github.com/sourcegraph/src-cli/internal/api"
 	"github.com/sourcegraph/src-cli/internal/batches"
+	"github.com/sourcegraph/src-cli/internal/batches/diff"
 	"github.com/sourcegraph/src-cli/internal/batches/docker"
 	"github.com/sourcegraph/src-cli/internal/batches/executor"
 	"github.com/sourcegraph/src-cli/internal/batches/graphql"
@@ -28,12 +32,59 @@ type Service struct {
 	client           api.Client
 	features         batches.FeatureFlags
 	imageCache       *docker.ImageCache
+	withoutHostEnv   bool
+
+	// workspaceStrategies are the strategies of the workspace configurations
+	// of the batch spec, by index. See resolveWorkspaceStrategies.
+	workspaceStrategies map[int]workspaceStrategy
+	// workspaceSteps are the steps that the workspace configurations add, by
+	// index. See resolveWorkspaceSteps.
+	workspaceSteps map[int]workspaceSteps
+	// codeHostOptions are the code host specific options of the changeset
+	// template. See resolveCodeHostOptions.
+	codeHostOptions *codeHostOptions
+	// repoServiceTypes are the external service types of the resolved
+	// repositories, by ID, which decide the code host options that apply to
+	// their changeset specs.
+	repoServiceTypes map[string]string
+	// fileFilter selects the files of the diffs that end up in changeset
+	// specs. See resolveFileFilters.
+	fileFilter *diff.FileFilter
+	// commitPerStep and stepCommitMessages configure changeset specs with a
+	// commit per step. See resolveStepCommits.
+	commitPerStep      bool
+	stepCommitMessages map[int]string
+	// stepEnvironments are the parts of the environments of the steps that
+	// src resolves itself. See resolveStepEnvironments.
+	stepEnvironments map[int]executor.StepEnvironment
+	// maxParallelism limits the number of tasks executed at the same time, if
+	// it's positive, and serialSteps are the steps that run in one workspace
+	// at a time, by index. See resolveConcurrency.
+	maxParallelism int
+	serialSteps    map[int]bool
+	// stepCacheKeyPaths are the glob patterns of the files that the cache
+	// keys of the steps are based on, by index. See resolveStepCacheKeyPaths.
+	stepCacheKeyPaths map[int][]string
+	// changesetDependencies are the rules that decide the order the
+	// changesets must be merged in. See resolveChangesetDependencies.
+	changesetDependencies []*changesetDependencyRule
+	// loadTimeEnv are the environment variables that replaced the
+	// `${{ env.NAME }}` expressions of the batch spec. See ResolveBatchSpec.
+	loadTimeEnv map[string]string
 }
 
 type Opts struct {
 	AllowUnsupported bool
 	AllowIgnored     bool
 	Client           api.Client
+	// RegistryMirror is the registry mirror that images from Docker Hub are
+	// pulled through, if any.
+	RegistryMirror string
+	// WithoutHostEnv resolves batch specs without the secrets and dotenv
+	// files of the environments of their steps, which aren't needed to render
+	// changeset specs from the results of an earlier execution, possibly on
+	// another machine.
+	WithoutHostEnv bool
 }
 
 var (
@@ -45,7 +96,8 @@ func New(opts *Opts) *Service {
 		allowUnsupported: opts.AllowUnsupported,
 		allowIgnored:     opts.AllowIgnored,
 		client:           opts.Client,
-		imageCache:       docker.NewImageCache(),
+		imageCache:       docker.NewMirroredImageCache(opts.RegistryMirror),
+		withoutHostEnv:   opts.WithoutHostEnv,
 	}
 }
 
@@ -85,6 +137,12 @@ func (svc *Service) DetermineFeatureFlags(ctx context.Context) error {
 	return svc.features.SetFromVersion(version)
 }
 
+// EnableAllFeatures enables all features without querying the Sourcegraph
+// version, such as when a batch spec is only executed in local directories.
+func (svc *Service) EnableAllFeatures() error {
+	return svc.features.SetFromVersion("dev")
+}
+
 // TODO(campaigns-deprecation): this shim can be removed in Sourcegraph 4.0.
 func (svc *Service) newOperations() graphql.Operations {
 	return graphql.NewOperations(
@@ -132,6 +190,10 @@ func (svc *Service) CreateChangesetSpec(ctx context.Context, spec *batcheslib.Ch
 	if err != nil {
 		return "", errors.Wrap(err, "marshalling changeset spec JSON")
 	}
+	raw, withOptions, err := svc.codeHostOptions.withCodeHostOptions(raw, svc.repoServiceTypes[spec.BaseRepository])
+	if err != nil {
+		return "", errors.Wrap(err, "adding code host options to changeset spec JSON")
+	}
 
 	var result struct {
 		CreateChangesetSpec struct {
@@ -141,35 +203,68 @@ func (svc *Service) CreateChangesetSpec(ctx context.Context, spec *batcheslib.Ch
 	if ok, err := svc.newRequest(createChangesetSpecMutation, map[string]interface{}{
 		"spec": string(raw),
 	}).Do(ctx, &result); err != nil || !ok {
+		if err != nil && withOptions && strings.Contains(err.Error(), "codeHostOptions") {
+			return "", errors.Wrap(err, "this Sourcegraph instance doesn't support the gitlab, gerrit, and bitbucket options of changesetTemplate, remove them from the batch spec")
+		}
 		return "", err
 	}
 
 	return graphql.ChangesetSpecID(result.CreateChangesetSpec.ID), nil
 }
 
-// EnsureDockerImages iterates over the steps within the batch spec to ensure the
-// images exist and to determine the exact content digest to be used when running
-// each step, including any required by the service itself.
+// EnsureDockerImages ensures that the distinct images of the steps within the
+// batch spec exist, pulling up to parallelism of them at a time, and
+// determines the exact content digest to be used when running each step. This
+// happens up front, so that pulling images isn't attributed to the execution
+// of the first tasks.
 //
-// Progress information is reported back to the given progress function: perc
-// will be a value between 0.0 and 1.0, inclusive.
-func (svc *Service) EnsureDockerImages(ctx context.Context, spec *batcheslib.BatchSpec, progress func(done, total int)) (map[string]docker.Image, error) {
-	total := len(spec.Steps)
-	progress(0, total)
-
-	// TODO: this _really_ should be parallelised, since the image cache takes
-	// care to only pull the same image once.
+// Progress information is reported back to the given progress function as the
+// number of images that are ready out of the total number of images.
+func (svc *Service) EnsureDockerImages(ctx context.Context, spec *batcheslib.BatchSpec, parallelism int, progress func(done, total int)) (map[string]docker.Image, error) {
+	var names []string
 	images := make(map[string]docker.Image)
-	for i := range spec.Steps {
-		img, err := svc.EnsureImage(ctx, spec.Steps[i].Container)
-		if err != nil {
-			return nil, err
+	for _, step := range spec.Steps {
+		if _, ok := images[step.Container]; !ok {
+			images[step.Container] = nil
+			names = append(names, step.Container)
 		}
-		images[spec.Steps[i].Container] = img
+	}
+
+	total := len(names)
+	progress(0, total)
 
-		progress(i+1, total)
+	if parallelism < 1 {
+		parallelism = 1
 	}
+	var (
+		mu   sync.Mutex
+		done int
+		errs *multierror.Error
+	)
+	run := parallel.NewRun(parallelism)
+	for _, name := range names {
+		run.Acquire()
+		go func(name string) {
+			defer run.Release()
+
+			img, err := svc.EnsureImage(ctx, name)
 
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = multierror.Append(errs, err)
+				return
+			}
+			images[name] = img
+			done++
+			progress(done, total)
+		}(name)
+	}
+	run.Wait()
+
+	if err := errs.ErrorOrNil(); err != nil {
+		return nil, err
+	}
 	return images, nil
 }
 
@@ -188,11 +283,11 @@ func (svc *Service) EnsureImage(ctx context.Context, name string) (docker.Image,
 }
 
 func (svc *Service) DetermineWorkspaces(ctx context.Context, repos []*graphql.Repository, spec *batcheslib.BatchSpec) ([]RepoWorkspace, error) {
-	return findWorkspaces(ctx, spec, svc, repos)
+	return findWorkspaces(ctx, spec, svc, svc.workspaceStrategies, svc.workspaceSteps, repos)
 }
 
 func (svc *Service) BuildTasks(ctx context.Context, spec *batcheslib.BatchSpec, workspaces []RepoWorkspace) []*executor.Task {
-	return buildTasks(ctx, spec, workspaces)
+	return buildTasks(ctx, spec, workspaces, svc.commitPerStep, svc.stepEnvironments, svc.stepCacheKeyPaths)
 }
 
 func (svc *Service) NewCoordinator(opts executor.NewCoordinatorOpts) *executor.Coordinator {
@@ -200,6 +295,14 @@ func (svc *Service) NewCoordinator(opts executor.NewCoordinatorOpts) *executor.C
 	opts.Client = svc.client
 	opts.Features = svc.features
 	opts.EnsureImage = svc.EnsureImage
+	opts.FileFilter = svc.fileFilter
+	opts.CommitPerStep = svc.commitPerStep
+	opts.StepCommitMessages = svc.stepCommitMessages
+	opts.StepEnvironments = svc.stepEnvironments
+	opts.SerialSteps = svc.serialSteps
+	if svc.maxParallelism > 0 && (opts.Parallelism <= 0 || opts.Parallelism > svc.maxParallelism) {
+		opts.Parallelism = svc.maxParallelism
+	}
 
 	return executor.NewCoordinator(opts)
 }
@@ -451,9 +554,13 @@ func (svc *Service) ResolveRepositories(ctx context.Context, spec *batcheslib.Ba
 		for _, repo := range reposWithBranch {
 			if other, ok := seen[repo.ID]; !ok {
 				seen[repo.ID] = repo
+				if svc.repoServiceTypes == nil {
+					svc.repoServiceTypes = map[string]string{}
+				}
+				svc.repoServiceTypes[repo.ID] = repo.ExternalRepository.ServiceType
 
 				switch st := strings.ToLower(repo.ExternalRepository.ServiceType); st {
-				case "github", "gitlab", "bitbucketserver":
+				case "github", "gitlab", "bitbucketserver", "gerrit", "perforce":
 				default:
 					if !svc.allowUnsupported {
 						unsupported.Append(repo)
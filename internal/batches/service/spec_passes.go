@@ -0,0 +1,106 @@
+package service
+
+import (
+	"bytes"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// specPass resolves some of the fields of a raw batch spec that src resolves
+// itself, before the batch spec parser sees them. It rewrites spec, the
+// top-level mapping node of the batch spec, in place, and returns whether it
+// modified it.
+type specPass func(spec *yaml.Node) (modified bool, err error)
+
+// resolveSpec parses the raw batch spec, runs the passes over it in order,
+// and encodes it again if any of them modified it, so that the spec is only
+// parsed and encoded once however many passes there are. If none of them
+// did, data is returned unchanged, keeping its formatting and comments.
+//
+// Malformed specs, and specs that aren't a mapping, are returned unchanged
+// without running the passes, leaving reporting them to the batch spec
+// parser.
+func resolveSpec(data []byte, passes ...specPass) ([]byte, error) {
+	root, err := parseSpecNode(data)
+	if err != nil || root == nil {
+		return data, nil
+	}
+	return runSpecPasses(data, root, passes)
+}
+
+// resolveSpecWithParameters is resolveSpec for passes that include
+// resolveParameters with the given parameter values. Values given for a spec
+// that can't declare any parameters are an error instead of being ignored.
+func resolveSpecWithParameters(data []byte, values map[string]string, passes ...specPass) ([]byte, error) {
+	root, err := parseSpecNode(data)
+	if len(values) > 0 {
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing batch spec")
+		}
+		if root == nil {
+			return nil, errors.New("batch spec declares no parameters")
+		}
+	}
+	if err != nil || root == nil {
+		return data, nil
+	}
+	return runSpecPasses(data, root, passes)
+}
+
+// parseSpecNode parses the raw batch spec into a YAML document node. The
+// node is nil if the spec isn't a mapping.
+func parseSpecNode(data []byte) (*yaml.Node, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	return &root, nil
+}
+
+func runSpecPasses(data []byte, root *yaml.Node, passes []specPass) ([]byte, error) {
+	modified := false
+	for _, pass := range passes {
+		m, err := pass(root.Content[0])
+		if err != nil {
+			return nil, err
+		}
+		modified = modified || m
+	}
+	if !modified {
+		return data, nil
+	}
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return nil, errors.Wrap(err, "encoding batch spec")
+	}
+	if err := enc.Close(); err != nil {
+		return nil, errors.Wrap(err, "encoding batch spec")
+	}
+	return out.Bytes(), nil
+}
+
+// forEachStep calls fn with the index and node of every step of the spec
+// that is a mapping, stopping at the first error. Steps that aren't mappings
+// are left to the batch spec parser.
+func forEachStep(spec *yaml.Node, fn func(i int, step *yaml.Node) error) error {
+	steps := mappingValue(spec, "steps")
+	if steps == nil || steps.Kind != yaml.SequenceNode {
+		return nil
+	}
+	for i, step := range steps.Content {
+		if step.Kind != yaml.MappingNode {
+			continue
+		}
+		if err := fn(i, step); err != nil {
+			return err
+		}
+	}
+	return nil
+}
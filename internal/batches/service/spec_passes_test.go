@@ -0,0 +1,180 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v3"
+)
+
+// plainSpec is a batch spec that none of the spec passes modify.
+const plainSpec = "name: test\nsteps:\n  - run: echo\n    container: alpine:3\n"
+
+// specPassTest is a test of a spec pass, which is run over spec with
+// resolveSpec.
+type specPassTest struct {
+	spec string
+	// want is the resolved spec. It's not compared if it's empty, which
+	// leaves checking the spec to check.
+	want string
+	// wantErr are the substrings of the expected error. An error is only
+	// expected if there are any.
+	wantErr []string
+	// check, if set, checks the spec and the configuration that the pass
+	// left in the Service.
+	check func(t *testing.T, svc *Service, data []byte)
+}
+
+// anyError is the wantErr of tests that only expect an error.
+var anyError = []string{""}
+
+// runSpecPassTests runs the tests of the spec pass that pass returns for a new
+// Service.
+func runSpecPassTests(t *testing.T, pass func(svc *Service) specPass, tests map[string]specPassTest) {
+	t.Helper()
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			svc := &Service{}
+			have, err := resolveSpec([]byte(tc.spec), pass(svc))
+			if len(tc.wantErr) > 0 {
+				if err == nil {
+					t.Fatal("unexpected nil error")
+				}
+				for _, want := range tc.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("error %q doesn't contain %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.want != "" {
+				if diff := cmp.Diff(tc.want, string(have)); diff != "" {
+					t.Errorf("wrong spec (-want +have):\n%s", diff)
+				}
+			}
+			if tc.check != nil {
+				tc.check(t, svc, have)
+			}
+		})
+	}
+}
+
+// unmarshalSpec unmarshals the resolved spec into v.
+func unmarshalSpec(t *testing.T, data []byte, v interface{}) {
+	t.Helper()
+	if err := yaml.Unmarshal(data, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolveSpec(t *testing.T) {
+	removeKey := func(key string) specPass {
+		return func(spec *yaml.Node) (bool, error) {
+			i := mappingIndex(spec, key)
+			if i < 0 {
+				return false, nil
+			}
+			removeMappingKey(spec, i)
+			return true, nil
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		spec   string
+		passes []specPass
+		want   string
+	}{
+		"unchanged": {
+			spec:   "# A comment.\nname:   test\n",
+			passes: []specPass{removeKey("a"), removeKey("b")},
+			want:   "# A comment.\nname:   test\n",
+		},
+		"malformed": {
+			spec:   "name: [test\n",
+			passes: []specPass{removeKey("name")},
+			want:   "name: [test\n",
+		},
+		"not a mapping": {
+			spec:   "- name: test\n",
+			passes: []specPass{removeKey("name")},
+			want:   "- name: test\n",
+		},
+		"empty": {
+			passes: []specPass{removeKey("name")},
+		},
+		"passes in order": {
+			spec: "name: test\na: 1\nb: 2\nc: 3\n",
+			passes: []specPass{
+				removeKey("a"),
+				func(spec *yaml.Node) (bool, error) {
+					// The passes see the changes of the passes before
+					// them.
+					if mappingIndex(spec, "a") >= 0 {
+						t.Error("a wasn't removed before the second pass")
+					}
+					return false, nil
+				},
+				removeKey("c"),
+			},
+			want: "name: test\nb: 2\n",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			have, err := resolveSpec([]byte(tc.spec), tc.passes...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, string(have)); diff != "" {
+				t.Errorf("wrong spec (-want +have):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		errTest := errors.New("pass failed")
+		ran := false
+		_, err := resolveSpec([]byte(plainSpec),
+			func(*yaml.Node) (bool, error) { return false, errTest },
+			func(*yaml.Node) (bool, error) { ran = true; return false, nil },
+		)
+		if err != errTest {
+			t.Errorf("wrong error: %v", err)
+		}
+		if ran {
+			t.Error("pass after the failing pass was run")
+		}
+	})
+}
+
+func TestResolveSpecWithParameters(t *testing.T) {
+	values := map[string]string{"version": "1.2.0"}
+	for name, tc := range map[string]struct {
+		spec    string
+		wantErr string
+	}{
+		"malformed":     {spec: "name: [test\n", wantErr: "parsing batch spec"},
+		"not a mapping": {spec: "- name: test\n", wantErr: "batch spec declares no parameters"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := resolveSpecWithParameters([]byte(tc.spec), values)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("wrong error: want %q, have %v", tc.wantErr, err)
+			}
+
+			// Without values, the spec is left to the batch spec parser.
+			have, err := resolveSpecWithParameters([]byte(tc.spec), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(have) != tc.spec {
+				t.Errorf("spec was modified:\n%s", have)
+			}
+		})
+	}
+}
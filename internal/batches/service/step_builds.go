@@ -0,0 +1,83 @@
+package service
+
+import (
+	"path/filepath"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/sourcegraph/src-cli/internal/batches/docker"
+)
+
+// stepBuild is the `build:` field of a step. It can either be given as a
+// string, in which case it is the path to the build context, or as an object.
+type stepBuild struct {
+	Context    string `yaml:"context"`
+	Dockerfile string `yaml:"dockerfile"`
+}
+
+// resolveStepBuilds replaces the `build:` field of every step in the batch
+// spec with a `container:` field that references the image built from the
+// build context. Relative build contexts are resolved against dir, which
+// should be the directory containing the batch spec.
+//
+// The images are registered with the image cache and are built when
+// EnsureDockerImages is called.
+func (svc *Service) resolveStepBuilds(spec *yaml.Node, dir string) (modified bool, err error) {
+	err = forEachStep(spec, func(i int, step *yaml.Node) error {
+		buildIdx := mappingIndex(step, "build")
+		if buildIdx < 0 {
+			return nil
+		}
+		if mappingIndex(step, "container") >= 0 {
+			return errors.Newf("step %d: only one of container and build may be set", i+1)
+		}
+
+		var build stepBuild
+		if node := step.Content[buildIdx+1]; node.Kind == yaml.ScalarNode {
+			build.Context = node.Value
+		} else if err := node.Decode(&build); err != nil {
+			return errors.Wrapf(err, "step %d: parsing build", i+1)
+		}
+		if build.Context == "" {
+			return errors.Newf("step %d: build context must not be empty", i+1)
+		}
+		if !filepath.IsAbs(build.Context) {
+			build.Context = filepath.Join(dir, build.Context)
+		}
+
+		tag, err := svc.imageCache.RegisterBuild(docker.BuildContext{
+			Context:    build.Context,
+			Dockerfile: build.Dockerfile,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "step %d", i+1)
+		}
+
+		step.Content[buildIdx].Value = "container"
+		step.Content[buildIdx+1] = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: tag}
+		modified = true
+		return nil
+	})
+	return modified, err
+}
+
+// mappingIndex returns the index of the key node with the given name in the
+// mapping node, or -1 if it doesn't exist.
+func mappingIndex(node *yaml.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// mappingValue returns the value node for the given key in the mapping node,
+// or nil if it doesn't exist.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if i := mappingIndex(node, key); i >= 0 {
+		return node.Content[i+1]
+	}
+	return nil
+}
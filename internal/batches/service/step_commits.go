@@ -0,0 +1,65 @@
+package service
+
+import (
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// The values of the `commits:` field of the changeset template.
+const (
+	commitsSquash  = "squash"
+	commitsPerStep = "perStep"
+)
+
+// resolveStepCommits removes the `commits:` field of the changeset template
+// and the `commitMessage:` fields of the steps from the batch spec.
+//
+// With `commits: perStep`, every step that changes files becomes its own
+// commit in the changeset specs, instead of all changes being squashed into a
+// single commit. The commit message of a step is rendered from its
+// `commitMessage:` template, which has the same context as the changeset
+// template, or is the commit message of the changeset template if the step
+// has none.
+//
+// The configuration is remembered by the Service and used by the Tasks and
+// Coordinators it creates.
+func (svc *Service) resolveStepCommits(spec *yaml.Node) (modified bool, err error) {
+	commits := commitsSquash
+	if tmpl := mappingValue(spec, "changesetTemplate"); tmpl != nil && tmpl.Kind == yaml.MappingNode {
+		if i := mappingIndex(tmpl, "commits"); i >= 0 {
+			commits = tmpl.Content[i+1].Value
+			if commits != commitsSquash && commits != commitsPerStep {
+				return false, errors.Newf("changesetTemplate.commits must be %q or %q, got %q", commitsSquash, commitsPerStep, commits)
+			}
+			removeMappingKey(tmpl, i)
+			modified = true
+		}
+	}
+
+	messages := map[int]string{}
+	err = forEachStep(spec, func(i int, step *yaml.Node) error {
+		j := mappingIndex(step, "commitMessage")
+		if j < 0 {
+			return nil
+		}
+		if commits != commitsPerStep {
+			return errors.Newf("step %d: commitMessage requires changesetTemplate.commits to be %q", i+1, commitsPerStep)
+		}
+		messages[i] = step.Content[j+1].Value
+		removeMappingKey(step, j)
+		modified = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if commits == commitsPerStep {
+		if transform := mappingValue(spec, "transformChanges"); transform != nil && mappingValue(transform, "group") != nil {
+			return false, errors.Newf("changesetTemplate.commits %q can't be combined with transformChanges.group", commitsPerStep)
+		}
+		svc.commitPerStep = true
+		svc.stepCommitMessages = messages
+	}
+	return modified, nil
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestResolveStepCommits(t *testing.T) {
+	runSpecPassTests(t, func(svc *Service) specPass { return svc.resolveStepCommits }, map[string]specPassTest{
+		"squash": {
+			spec: plainSpec + "changesetTemplate:\n  title: test\n",
+			want: plainSpec + "changesetTemplate:\n  title: test\n",
+			check: func(t *testing.T, svc *Service, _ []byte) {
+				if svc.commitPerStep {
+					t.Error("commits per step enabled")
+				}
+			},
+		},
+		"per step": {
+			spec: `name: test
+steps:
+  - run: gofmt -w .
+    container: golang:1.17
+    commitMessage: Format code
+  - run: go mod tidy
+    container: golang:1.17
+changesetTemplate:
+  title: test
+  commits: perStep
+`,
+			want: `name: test
+steps:
+  - run: gofmt -w .
+    container: golang:1.17
+  - run: go mod tidy
+    container: golang:1.17
+changesetTemplate:
+  title: test
+`,
+			check: func(t *testing.T, svc *Service, _ []byte) {
+				if !svc.commitPerStep {
+					t.Error("commits per step not enabled")
+				}
+				if diff := cmp.Diff(map[int]string{0: "Format code"}, svc.stepCommitMessages); diff != "" {
+					t.Errorf("wrong commit messages (-want +have):\n%s", diff)
+				}
+			},
+		},
+		"unknown mode": {
+			spec:    "changesetTemplate:\n  commits: perFile\n",
+			wantErr: []string{"changesetTemplate.commits must be"},
+		},
+		"commitMessage without mode": {
+			spec:    "steps:\n  - run: echo\n    commitMessage: echo\n",
+			wantErr: []string{"step 1: commitMessage requires changesetTemplate.commits"},
+		},
+		"groups": {
+			spec:    "changesetTemplate:\n  commits: perStep\ntransformChanges:\n  group:\n    - directory: a\n      branch: a\n",
+			wantErr: []string{"can't be combined with transformChanges.group"},
+		},
+	})
+}
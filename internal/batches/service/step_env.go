@@ -0,0 +1,205 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+)
+
+// envName matches valid names of environment variables.
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// resolveStepEnvironments resolves the entries of the `env:` lists of the
+// steps in the batch spec that the batch spec parser doesn't know:
+//
+//	steps:
+//	  - run: ./codemod.sh
+//	    container: alpine:3
+//	    env:
+//	      - CI
+//	      - secret: GITHUB_TOKEN
+//	      - fromFile: ./codemod.env
+//	        secret: true
+//	      - GOFLAGS: -mod=mod
+//
+// A name passes the variable through from the environment of src, as before,
+// and is left to the batch spec parser: if the variable isn't set, it's
+// skipped. `secret:` passes a variable through the same way and marks it as a
+// secret, but it's an error if it isn't set. `fromFile:` reads variables
+// from a dotenv file, given by a path relative to dir, which should be the
+// directory containing the batch spec; with `secret: true`, all of them are
+// secrets. Variables set in the list itself take precedence over those read
+// from files. A mapping with only the key `secret` therefore can't be used to
+// set a variable named secret.
+//
+// The values of secrets are left out of cache keys, so that rotating a token
+// doesn't invalidate the cache, and are hidden in the output of src. The
+// values read from files are remembered by the Service and passed to the
+// Tasks and Coordinators it creates, so that they don't end up in the batch
+// spec that's sent to Sourcegraph.
+//
+// If the Service was created with WithoutHostEnv, secrets don't have to be
+// set and files aren't read, since nothing is executed.
+func (svc *Service) resolveStepEnvironments(spec *yaml.Node, dir string, lookupEnv func(string) (string, bool)) (modified bool, err error) {
+	if svc.withoutHostEnv {
+		// Only the names of secrets are checked.
+		lookupEnv = func(string) (string, bool) { return "", true }
+	}
+
+	environments := map[int]executor.StepEnvironment{}
+	err = forEachStep(spec, func(i int, step *yaml.Node) error {
+		env := mappingValue(step, "env")
+		if env == nil || env.Kind != yaml.SequenceNode {
+			return nil
+		}
+
+		var extra executor.StepEnvironment
+		entries := make([]*yaml.Node, 0, len(env.Content))
+		for _, entry := range env.Content {
+			switch {
+			case entry.Kind == yaml.MappingNode && mappingIndex(entry, "fromFile") >= 0:
+				modified = true
+				if svc.withoutHostEnv {
+					continue
+				}
+				vars, secret, err := readStepEnvFile(entry, dir)
+				if err != nil {
+					return errors.Wrapf(err, "step %d", i+1)
+				}
+				if extra.Files == nil {
+					extra.Files = map[string]string{}
+				}
+				for k, v := range vars {
+					extra.Files[k] = v
+					if secret {
+						addStepEnvSecret(&extra, k)
+					}
+				}
+				continue
+
+			case entry.Kind == yaml.MappingNode && len(entry.Content) == 2 && entry.Content[0].Value == "secret":
+				name := entry.Content[1].Value
+				if err := checkHostEnv(name, lookupEnv); err != nil {
+					return errors.Wrapf(err, "step %d", i+1)
+				}
+				addStepEnvSecret(&extra, name)
+				entry = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}
+				modified = true
+			}
+			entries = append(entries, entry)
+		}
+		env.Content = entries
+
+		if extra.Files != nil || extra.Secrets != nil {
+			environments[i] = extra
+		}
+		return nil
+	})
+	if err != nil || !modified {
+		return false, err
+	}
+	svc.stepEnvironments = environments
+	return true, nil
+}
+
+func addStepEnvSecret(env *executor.StepEnvironment, name string) {
+	if env.Secrets == nil {
+		env.Secrets = map[string]bool{}
+	}
+	env.Secrets[name] = true
+}
+
+// checkHostEnv returns an error if name isn't the name of a variable that is
+// set in the environment of src. It's only used for secrets: plain names are
+// skipped if they aren't set, like before secrets were added.
+func checkHostEnv(name string, lookupEnv func(string) (string, bool)) error {
+	if !envName.MatchString(name) {
+		return errors.Newf("invalid environment variable name %q", name)
+	}
+	if _, ok := lookupEnv(name); !ok {
+		return errors.Newf("secret environment variable %s is passed through to the step, but isn't set", name)
+	}
+	return nil
+}
+
+// readStepEnvFile reads the dotenv file of a `fromFile:` entry of the env of
+// a step.
+func readStepEnvFile(entry *yaml.Node, dir string) (vars map[string]string, secret bool, err error) {
+	var e struct {
+		FromFile string `yaml:"fromFile"`
+		Secret   bool   `yaml:"secret"`
+	}
+	if err := entry.Decode(&e); err != nil {
+		return nil, false, errors.Wrap(err, "fromFile")
+	}
+	if e.FromFile == "" {
+		return nil, false, errors.New("fromFile must be the path of a dotenv file")
+	}
+
+	path := e.FromFile
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "reading environment file")
+	}
+	vars, err = parseDotenv(data)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "parsing environment file %s", e.FromFile)
+	}
+	return vars, e.Secret, nil
+}
+
+// parseDotenv parses a dotenv file: lines of NAME=VALUE, optionally prefixed
+// with `export`, with blank lines and lines starting with # ignored. Values
+// can be single-quoted, taken literally, or double-quoted, in which case Go
+// escape sequences such as \n are interpreted. Unquoted values end at a #
+// preceded by whitespace.
+func parseDotenv(data []byte) (map[string]string, error) {
+	vars := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+
+		i := strings.Index(line, "=")
+		if i <= 0 {
+			return nil, errors.Newf("line %d: expected NAME=VALUE", n)
+		}
+		name := strings.TrimSpace(line[:i])
+		if !envName.MatchString(name) {
+			return nil, errors.Newf("line %d: invalid name %q", n, name)
+		}
+
+		value := strings.TrimSpace(line[i+1:])
+		switch {
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, errors.Newf("line %d: invalid quoted value", n)
+			}
+			value = unquoted
+		default:
+			if j := strings.Index(value, " #"); j >= 0 {
+				value = strings.TrimSpace(value[:j])
+			}
+		}
+		vars[name] = value
+	}
+	return vars, scanner.Err()
+}
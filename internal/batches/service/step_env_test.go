@@ -0,0 +1,143 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v3"
+
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+)
+
+func TestResolveStepEnvironments(t *testing.T) {
+	lookupEnv := func(name string) (string, bool) {
+		switch name {
+		case "CI", "GITHUB_TOKEN":
+			return "value", true
+		}
+		return "", false
+	}
+
+	dir := t.TempDir()
+	dotenv := "# codemod settings\nexport LEVEL=debug\nNAME='a # b'\nGREETING=\"hello\\nworld\"\nTOKEN=abc # comment\n"
+	if err := os.WriteFile(filepath.Join(dir, "codemod.env"), []byte(dotenv), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secrets.env"), []byte("API_KEY=s3cr3t\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	const unchanged = "name: test\nsteps:\n  - run: echo\n    container: alpine:3\n    env:\n      - CI\n      - FOO: bar\n"
+	tests := map[string]specPassTest{
+		"unchanged": {
+			spec: unchanged,
+			want: unchanged,
+			check: func(t *testing.T, svc *Service, _ []byte) {
+				if svc.stepEnvironments != nil {
+					t.Errorf("unexpected step environments: %v", svc.stepEnvironments)
+				}
+			},
+		},
+		"secrets and files": {
+			spec: `name: test
+steps:
+  - run: echo
+    container: alpine:3
+  - run: ./codemod.sh
+    container: alpine:3
+    env:
+      - CI
+      - secret: GITHUB_TOKEN
+      - fromFile: codemod.env
+      - fromFile: secrets.env
+        secret: true
+      - LEVEL: info
+`,
+			want: `name: test
+steps:
+  - run: echo
+    container: alpine:3
+  - run: ./codemod.sh
+    container: alpine:3
+    env:
+      - CI
+      - GITHUB_TOKEN
+      - LEVEL: info
+`,
+			check: func(t *testing.T, svc *Service, _ []byte) {
+				want := map[int]executor.StepEnvironment{
+					1: {
+						Files: map[string]string{
+							"LEVEL":    "debug",
+							"NAME":     "a # b",
+							"GREETING": "hello\nworld",
+							"TOKEN":    "abc",
+							"API_KEY":  "s3cr3t",
+						},
+						Secrets: map[string]bool{"GITHUB_TOKEN": true, "API_KEY": true},
+					},
+				}
+				if diff := cmp.Diff(want, svc.stepEnvironments); diff != "" {
+					t.Errorf("wrong step environments (-want +have):\n%s", diff)
+				}
+			},
+		},
+		// Plain names are skipped by the batch spec parser if they aren't
+		// set, even if other entries use secret: or fromFile:.
+		"unset plain variables": {
+			spec: "steps:\n  - env:\n      - MISSING\n      - secret: GITHUB_TOKEN\n",
+			want: "steps:\n  - env:\n      - MISSING\n      - GITHUB_TOKEN\n",
+		},
+	}
+	for name, spec := range map[string]string{
+		"unset secret":        "steps:\n  - env:\n      - secret: MISSING\n",
+		"invalid secret name": "steps:\n  - env:\n      - secret: NOT-A-NAME\n",
+		"missing file":        "steps:\n  - env:\n      - fromFile: missing.env\n",
+		"empty fromFile":      "steps:\n  - env:\n      - fromFile: ''\n",
+		"malformed secret":    "steps:\n  - env:\n      - fromFile: missing.env\n        secret: maybe\n",
+	} {
+		tests[name] = specPassTest{spec: spec, wantErr: anyError}
+	}
+	runSpecPassTests(t, func(svc *Service) specPass {
+		return func(spec *yaml.Node) (bool, error) { return svc.resolveStepEnvironments(spec, dir, lookupEnv) }
+	}, tests)
+}
+
+func TestResolveStepEnvironments_WithoutHostEnv(t *testing.T) {
+	lookupEnv := func(string) (string, bool) { return "", false }
+
+	runSpecPassTests(t, func(svc *Service) specPass {
+		svc.withoutHostEnv = true
+		return func(spec *yaml.Node) (bool, error) { return svc.resolveStepEnvironments(spec, t.TempDir(), lookupEnv) }
+	}, map[string]specPassTest{
+		"unset secret and missing file": {
+			spec: "steps:\n  - env:\n      - secret: GITHUB_TOKEN\n      - fromFile: missing.env\n",
+			want: "steps:\n  - env:\n      - GITHUB_TOKEN\n",
+			check: func(t *testing.T, svc *Service, _ []byte) {
+				want := map[int]executor.StepEnvironment{0: {Secrets: map[string]bool{"GITHUB_TOKEN": true}}}
+				if diff := cmp.Diff(want, svc.stepEnvironments); diff != "" {
+					t.Errorf("wrong step environments (-want +have):\n%s", diff)
+				}
+			},
+		},
+		"invalid secret name": {
+			spec:    "steps:\n  - env:\n      - secret: NOT-A-NAME\n",
+			wantErr: []string{`invalid environment variable name "NOT-A-NAME"`},
+		},
+	})
+}
+
+func TestParseDotenv(t *testing.T) {
+	for _, data := range []string{
+		"NO_EQUALS\n",
+		"=value\n",
+		"1NAME=value\n",
+		`QUOTED="unterminated\"` + "\n",
+	} {
+		if _, err := parseDotenv([]byte(data)); err == nil {
+			t.Errorf("unexpected nil error for %q", data)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sourcegraph/go-diff/diff"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/repozip"
+)
+
+// WriteWorkspaceArchive writes the files of the repository as they are after
+// all steps were executed to w, as a gzipped tarball. They're reconstructed by
+// applying the diffs of the changeset specs, in the order of their branches,
+// to the archive of the repository at their base revision, which is fetched
+// into cacheDir. All specs must be for the repository and have the same base
+// revision.
+//
+// If path isn't empty, only the files in that directory of the repository are
+// written, relative to it, such as the files of a workspace.
+func (svc *Service) WriteWorkspaceArchive(ctx context.Context, w io.Writer, cacheDir string, repo *graphql.Repository, specs []*batcheslib.ChangesetSpec, path string) error {
+	if len(specs) == 0 {
+		return errors.Newf("no changeset specs for %s", repo.Name)
+	}
+	specs = append([]*batcheslib.ChangesetSpec(nil), specs...)
+	sort.Slice(specs, func(i, j int) bool { return specs[i].HeadRef < specs[j].HeadRef })
+
+	var diffs []string
+	for _, spec := range specs {
+		if spec.BaseRepository != repo.ID || spec.BaseRev != specs[0].BaseRev {
+			return errors.Newf("the changeset specs of %s aren't all based on revision %s", repo.Name, specs[0].BaseRev)
+		}
+		for _, c := range spec.Commits {
+			diffs = append(diffs, c.Diff)
+		}
+	}
+
+	archive := repozip.NewArchiveRegistry(svc.client, cacheDir, false).Checkout(repozip.RepoRevision{RepoName: repo.Name, Commit: specs[0].BaseRev}, "")
+	if err := archive.Ensure(ctx); err != nil {
+		return errors.Wrapf(err, "fetching archive of %s", repo.Name)
+	}
+	defer archive.Close()
+
+	return writeWorkspaceTarball(ctx, w, archive.Path(), diffs, path)
+}
+
+// writeWorkspaceTarball writes the files of the zip archive at zipPath with
+// the diffs applied to w, as a gzipped tarball. Only the files that the diffs
+// touch are extracted to apply the diffs to them with git.
+func writeWorkspaceTarball(ctx context.Context, w io.Writer, zipPath string, diffs []string, path string) error {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	files := make(map[string]*zip.File, len(r.File))
+	for _, f := range r.File {
+		if !f.FileInfo().IsDir() {
+			files[f.Name] = f
+		}
+	}
+
+	touched := map[string]bool{}
+	for _, d := range diffs {
+		fileDiffs, err := diff.ParseMultiFileDiff([]byte(d))
+		if err != nil {
+			return errors.Wrap(err, "parsing diff")
+		}
+		for _, fd := range fileDiffs {
+			for _, name := range []string{fd.OrigName, fd.NewName} {
+				if name != "/dev/null" && name != "" {
+					touched[name] = true
+				}
+			}
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "src-workspace-archive-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	for name := range touched {
+		f, ok := files[name]
+		if !ok {
+			continue
+		}
+		if err := extractZipFile(f, filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+			return errors.Wrapf(err, "extracting %s", name)
+		}
+	}
+	for i, d := range diffs {
+		// Like the diffs generated by the workspaces, the diffs don't have
+		// a/ and b/ prefixes.
+		cmd := exec.CommandContext(ctx, "git", "apply", "-p0", "-")
+		cmd.Dir = dir
+		cmd.Stdin = strings.NewReader(d)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return errors.Wrapf(err, "applying diff %d: %s", i+1, out)
+		}
+	}
+
+	prefix := ""
+	if path = strings.Trim(path, "/"); path != "" {
+		prefix = path + "/"
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if !touched[name] {
+			names = append(names, name)
+		}
+	}
+	for name := range touched {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		hdr := &tar.Header{Name: strings.TrimPrefix(name, prefix), Typeflag: tar.TypeReg}
+		var content io.ReadCloser
+		if touched[name] {
+			local := filepath.Join(dir, filepath.FromSlash(name))
+			info, err := os.Lstat(local)
+			if os.IsNotExist(err) {
+				// Deleted by a diff.
+				continue
+			} else if err != nil {
+				return err
+			}
+			if info.Mode()&os.ModeSymlink != 0 {
+				target, err := os.Readlink(local)
+				if err != nil {
+					return err
+				}
+				hdr.Typeflag, hdr.Linkname, hdr.Mode = tar.TypeSymlink, target, 0777
+				hdr.ModTime = info.ModTime()
+			} else {
+				hdr.Size, hdr.Mode, hdr.ModTime = info.Size(), int64(info.Mode().Perm()), info.ModTime()
+				if content, err = os.Open(local); err != nil {
+					return err
+				}
+			}
+		} else {
+			f := files[name]
+			info := f.FileInfo()
+			hdr.Mode, hdr.ModTime = int64(info.Mode().Perm()), f.Modified
+			if content, err = f.Open(); err != nil {
+				return err
+			}
+			if info.Mode()&os.ModeSymlink != 0 {
+				target, err := io.ReadAll(content)
+				content.Close()
+				if err != nil {
+					return err
+				}
+				hdr.Typeflag, hdr.Linkname, hdr.Mode = tar.TypeSymlink, string(target), 0777
+				content = nil
+			} else {
+				hdr.Size = int64(f.UncompressedSize64)
+			}
+		}
+		if hdr.ModTime.IsZero() {
+			hdr.ModTime = time.Unix(0, 0)
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			if content != nil {
+				content.Close()
+			}
+			return err
+		}
+		if content != nil {
+			_, err := io.Copy(tw, content)
+			content.Close()
+			if err != nil {
+				return errors.Wrapf(err, "writing %s", name)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// extractZipFile writes the content of the file in the zip archive to dest,
+// keeping its mode.
+func extractZipFile(f *zip.File, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+		return err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if f.Mode()&os.ModeSymlink != 0 {
+		target, err := io.ReadAll(rc)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(string(target), dest)
+	}
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode().Perm()|0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
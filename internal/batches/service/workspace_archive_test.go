@@ -0,0 +1,123 @@
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriteWorkspaceTarball(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	zipPath := filepath.Join(t.TempDir(), "repo.zip")
+	f, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range map[string]string{
+		"README.md":            "hello\n",
+		"client/web/main.go":   "package main\n",
+		"client/web/old.go":    "package old\n",
+		"client/other/main.go": "package other\n",
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	diffs := []string{
+		`diff --git client/web/main.go client/web/main.go
+--- client/web/main.go
++++ client/web/main.go
+@@ -1 +1,3 @@
+ package main
++
++func main() {}
+diff --git client/web/old.go client/web/old.go
+deleted file mode 100644
+--- client/web/old.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package old
+`,
+		`diff --git client/web/new.go client/web/new.go
+new file mode 100644
+--- /dev/null
++++ client/web/new.go
+@@ -0,0 +1 @@
++package new
+`,
+	}
+
+	read := func(t *testing.T, path string) map[string]string {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := writeWorkspaceTarball(context.Background(), &buf, zipPath, diffs, path); err != nil {
+			t.Fatal(err)
+		}
+		gr, err := gzip.NewReader(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files := map[string]string{}
+		tr := tar.NewReader(gr)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			content, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[hdr.Name] = string(content)
+		}
+		return files
+	}
+
+	t.Run("repository", func(t *testing.T) {
+		want := map[string]string{
+			"README.md":            "hello\n",
+			"client/web/main.go":   "package main\n\nfunc main() {}\n",
+			"client/web/new.go":    "package new\n",
+			"client/other/main.go": "package other\n",
+		}
+		if diff := cmp.Diff(want, read(t, "")); diff != "" {
+			t.Errorf("wrong files (-want +have):\n%s", diff)
+		}
+	})
+
+	t.Run("path", func(t *testing.T) {
+		want := map[string]string{
+			"main.go": "package main\n\nfunc main() {}\n",
+			"new.go":  "package new\n",
+		}
+		if diff := cmp.Diff(want, read(t, "client/web/")); diff != "" {
+			t.Errorf("wrong files (-want +have):\n%s", diff)
+		}
+	})
+}
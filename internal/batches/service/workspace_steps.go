@@ -0,0 +1,199 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+	"gopkg.in/yaml.v3"
+)
+
+// workspaceSteps are the steps that a `workspaces:` entry adds to the steps of
+// the batch spec.
+type workspaceSteps struct {
+	// indexes are the indexes of the steps in the steps of the spec.
+	indexes []int
+	// replace is true if the steps replace the top-level steps of the spec
+	// instead of being appended to them.
+	replace bool
+}
+
+// resolveWorkspaceSteps moves the `steps:` of the entries in `workspaces:` of
+// the batch spec to the end of its top-level steps, so that they're validated
+// like any other step, and removes their `replaceSteps:` fields:
+//
+//	steps:
+//	  - run: ./codemod.sh
+//	    container: alpine:3
+//	workspaces:
+//	  - rootAtLocationOf: package.json
+//	    in: github.com/sourcegraph/*
+//	    steps:
+//	      - run: yarn
+//	        container: node:16
+//
+// The workspaces of an entry execute the top-level steps followed by the
+// steps of the entry, in the order in which they're given, or only the steps
+// of the entry with `replaceSteps: true`. Other workspaces, including the
+// repositories that no entry matches, only execute the top-level steps.
+//
+// Which steps belong to which entry is remembered by the Service, so that
+// DetermineWorkspaces gives every workspace its steps. Since the steps are part
+// of the tasks, they're part of the cache keys. The moved steps get an `if:`
+// that only holds in the repositories matching the `in:` of their entry, and
+// the top-level steps one that doesn't hold in the repositories of entries
+// with `replaceSteps: true`, so that the uploaded batch spec, which Sourcegraph
+// displays and executes server-side, keeps them scoped to their workspaces.
+func (svc *Service) resolveWorkspaceSteps(spec *yaml.Node) (modified bool, err error) {
+	workspaces := mappingValue(spec, "workspaces")
+	if workspaces == nil || workspaces.Kind != yaml.SequenceNode {
+		return false, nil
+	}
+
+	steps := mappingValue(spec, "steps")
+	if steps != nil && steps.Kind != yaml.SequenceNode {
+		// Leave reporting malformed steps to the batch spec parser.
+		return false, nil
+	}
+
+	topLevel := 0
+	if steps != nil {
+		topLevel = len(steps.Content)
+	}
+
+	configs := map[int]workspaceSteps{}
+	var replacing []string
+	for i, conf := range workspaces.Content {
+		if conf.Kind != yaml.MappingNode {
+			continue
+		}
+
+		var ws workspaceSteps
+		if idx := mappingIndex(conf, "replaceSteps"); idx >= 0 {
+			if err := conf.Content[idx+1].Decode(&ws.replace); err != nil {
+				return false, errors.Newf("workspace %d: replaceSteps must be true or false", i+1)
+			}
+			removeMappingKey(conf, idx)
+			modified = true
+		}
+
+		idx := mappingIndex(conf, "steps")
+		if idx < 0 {
+			if ws.replace {
+				return false, errors.Newf("workspace %d: replaceSteps requires steps", i+1)
+			}
+			continue
+		}
+		confSteps := conf.Content[idx+1]
+		if confSteps.Kind != yaml.SequenceNode || len(confSteps.Content) == 0 {
+			return false, errors.Newf("workspace %d: steps must be a non-empty list of steps", i+1)
+		}
+		removeMappingKey(conf, idx)
+
+		in := workspaceIn(conf)
+		if ws.replace {
+			replacing = append(replacing, in)
+		}
+
+		if steps == nil {
+			steps = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			setMappingValue(spec, "steps", steps)
+		}
+		for _, step := range confSteps.Content {
+			scopeStep(step, inWorkspaceCondition(in))
+			ws.indexes = append(ws.indexes, len(steps.Content))
+			steps.Content = append(steps.Content, step)
+		}
+		configs[i] = ws
+		modified = true
+	}
+
+	if len(replacing) > 0 {
+		conds := make([]string, len(replacing))
+		for i, in := range replacing {
+			conds[i] = "(" + inWorkspaceCondition(in) + ")"
+		}
+		cond := "not " + conds[0]
+		if len(conds) > 1 {
+			cond = "not (or " + strings.Join(conds, " ") + ")"
+		}
+		for _, step := range steps.Content[:topLevel] {
+			scopeStep(step, cond)
+		}
+	}
+
+	if len(configs) > 0 {
+		svc.workspaceSteps = configs
+	}
+	return modified, nil
+}
+
+// workspaceIn returns the `in:` glob of the `workspaces:` entry conf, which
+// selects the repositories of its workspaces.
+func workspaceIn(conf *yaml.Node) string {
+	if in := mappingValue(conf, "in"); in != nil && in.Kind == yaml.ScalarNode {
+		return in.Value
+	}
+	return ""
+}
+
+// inWorkspaceCondition returns the template expression that holds in the
+// repositories matching the `in:` glob of a `workspaces:` entry.
+func inWorkspaceCondition(in string) string {
+	return "matches repository.name " + strconv.Quote(in)
+}
+
+// scopeStep restricts the step to the workspaces in which the template
+// expression cond holds, in addition to its own `if:`.
+func scopeStep(step *yaml.Node, cond string) {
+	if step.Kind != yaml.MappingNode {
+		// Leave reporting malformed steps to the batch spec parser.
+		return
+	}
+
+	expr := "${{ " + cond + " }}"
+	if old := mappingValue(step, "if"); old != nil {
+		if old.Kind != yaml.ScalarNode {
+			return
+		}
+		expr = "${{ if " + cond + " }}" + old.Value + "${{ else }}false${{ end }}"
+	}
+	setMappingValue(step, "if", scalarNode(expr))
+}
+
+// stepsForWorkspace returns the steps of the spec that the workspaces of the
+// `workspaces:` entry with the given index execute, given the steps of the
+// entries, and their indexes in the steps of the spec. conf is -1 for
+// repositories that no entry matches.
+func stepsForWorkspace(steps []batcheslib.Step, configs map[int]workspaceSteps, conf int) ([]batcheslib.Step, []int) {
+	indexes := make([]int, 0, len(steps))
+	if len(configs) == 0 {
+		for idx := range steps {
+			indexes = append(indexes, idx)
+		}
+		return steps, indexes
+	}
+
+	owner := map[int]int{}
+	for i, ws := range configs {
+		for _, idx := range ws.indexes {
+			owner[idx] = i
+		}
+	}
+
+	replace := configs[conf].replace
+	selected := make([]batcheslib.Step, 0, len(steps))
+	for idx, step := range steps {
+		if i, ok := owner[idx]; ok {
+			if i == conf {
+				selected = append(selected, step)
+				indexes = append(indexes, idx)
+			}
+		} else if !replace {
+			selected = append(selected, step)
+			indexes = append(indexes, idx)
+		}
+	}
+	return selected, indexes
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestResolveWorkspaceSteps(t *testing.T) {
+	const unchanged = "name: test\nsteps:\n  - run: echo\n    container: alpine:3\nworkspaces:\n  - rootAtLocationOf: go.mod\n"
+	tests := map[string]specPassTest{
+		"unchanged": {
+			spec: unchanged,
+			want: unchanged,
+			check: func(t *testing.T, svc *Service, _ []byte) {
+				if svc.workspaceSteps != nil {
+					t.Errorf("unexpected workspace steps: %v", svc.workspaceSteps)
+				}
+			},
+		},
+		"steps": {
+			spec: `name: test
+steps:
+  - run: echo
+    container: alpine:3
+workspaces:
+  - rootAtLocationOf: package.json
+    in: github.com/sourcegraph/*
+    steps:
+      - run: yarn
+        container: node:16
+  - rootAtLocationOf: pom.xml
+  - rootAtLocationOf: go.mod
+    in: github.com/golang/*
+    replaceSteps: true
+    steps:
+      - run: go mod tidy
+        container: golang:1.17
+      - run: go fmt ./...
+        container: golang:1.17
+        if: ${{ eq steps.path "" }}
+`,
+			want: `name: test
+steps:
+  - run: echo
+    container: alpine:3
+    if: ${{ not (matches repository.name "github.com/golang/*") }}
+  - run: yarn
+    container: node:16
+    if: ${{ matches repository.name "github.com/sourcegraph/*" }}
+  - run: go mod tidy
+    container: golang:1.17
+    if: ${{ matches repository.name "github.com/golang/*" }}
+  - run: go fmt ./...
+    container: golang:1.17
+    if: ${{ if matches repository.name "github.com/golang/*" }}${{ eq steps.path "" }}${{ else }}false${{ end }}
+workspaces:
+  - rootAtLocationOf: package.json
+    in: github.com/sourcegraph/*
+  - rootAtLocationOf: pom.xml
+  - rootAtLocationOf: go.mod
+    in: github.com/golang/*
+`,
+			check: func(t *testing.T, svc *Service, _ []byte) {
+				want := map[int]workspaceSteps{
+					0: {indexes: []int{1}},
+					2: {indexes: []int{2, 3}, replace: true},
+				}
+				if diff := cmp.Diff(want, svc.workspaceSteps, cmp.AllowUnexported(workspaceSteps{})); diff != "" {
+					t.Errorf("wrong workspace steps (-want +have):\n%s", diff)
+				}
+			},
+		},
+		"no top-level steps": {
+			spec: "name: test\nworkspaces:\n  - rootAtLocationOf: go.mod\n    in: github.com/golang/*\n    steps:\n      - run: go mod tidy\n        container: golang:1.17\n",
+			want: "name: test\nworkspaces:\n  - rootAtLocationOf: go.mod\n    in: github.com/golang/*\nsteps:\n  - run: go mod tidy\n    container: golang:1.17\n    if: ${{ matches repository.name \"github.com/golang/*\" }}\n",
+		},
+	}
+	for name, spec := range map[string]string{
+		"replaceSteps without steps": "workspaces:\n  - rootAtLocationOf: go.mod\n    replaceSteps: true\n",
+		"malformed replaceSteps":     "workspaces:\n  - rootAtLocationOf: go.mod\n    replaceSteps: maybe\n    steps:\n      - run: echo\n",
+		"empty steps":                "workspaces:\n  - rootAtLocationOf: go.mod\n    steps: []\n",
+	} {
+		tests[name] = specPassTest{spec: spec, wantErr: anyError}
+	}
+	runSpecPassTests(t, func(svc *Service) specPass { return svc.resolveWorkspaceSteps }, tests)
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// workspaceStrategy describes how the projects of a language ecosystem are
+// laid out in a repository.
+type workspaceStrategy struct {
+	// manifest is the name of the file that marks the root of a project.
+	manifest string
+	// ignoredDirs are the names of directories that never contain project
+	// roots, such as vendored dependencies, build output and test fixtures.
+	ignoredDirs []string
+}
+
+// workspaceStrategies are the strategies that can be given as the
+// `strategy:` field of a `workspaces:` entry.
+var workspaceStrategies = map[string]workspaceStrategy{
+	"go-modules": {
+		manifest:    "go.mod",
+		ignoredDirs: []string{"vendor", "testdata"},
+	},
+	"npm-workspaces": {
+		manifest:    "package.json",
+		ignoredDirs: []string{"node_modules", "bower_components"},
+	},
+	"maven-modules": {
+		manifest:    "pom.xml",
+		ignoredDirs: []string{"target", "src"},
+	},
+	"cargo": {
+		manifest:    "Cargo.toml",
+		ignoredDirs: []string{"target", "vendor"},
+	},
+}
+
+// includes returns true if dir, relative to the repository root, can be the
+// root of a project.
+func (s workspaceStrategy) includes(dir string) bool {
+	for _, part := range strings.Split(dir, "/") {
+		for _, ignored := range s.ignoredDirs {
+			if part == ignored {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// resolveWorkspaceStrategies replaces the `strategy:` field of every entry in
+// `workspaces:` of the batch spec with the `rootAtLocationOf:` field of the
+// strategy's manifest file.
+//
+// The strategies are remembered by the Service, so that DetermineWorkspaces
+// skips directories that the strategy ignores.
+func (svc *Service) resolveWorkspaceStrategies(spec *yaml.Node) (modified bool, err error) {
+	workspaces := mappingValue(spec, "workspaces")
+	if workspaces == nil || workspaces.Kind != yaml.SequenceNode {
+		return false, nil
+	}
+
+	strategies := map[int]workspaceStrategy{}
+	for i, conf := range workspaces.Content {
+		if conf.Kind != yaml.MappingNode {
+			continue
+		}
+
+		strategyIdx := mappingIndex(conf, "strategy")
+		if strategyIdx < 0 {
+			continue
+		}
+		if mappingIndex(conf, "rootAtLocationOf") >= 0 {
+			return false, errors.Newf("workspace %d: only one of strategy and rootAtLocationOf may be set", i+1)
+		}
+
+		name := conf.Content[strategyIdx+1].Value
+		strategy, ok := workspaceStrategies[name]
+		if !ok {
+			return false, errors.Newf("workspace %d: unknown strategy %q, must be one of %s", i+1, name, strings.Join(workspaceStrategyNames(), ", "))
+		}
+
+		removeMappingKey(conf, strategyIdx)
+		setMappingValue(conf, "rootAtLocationOf", scalarNode(strategy.manifest))
+		strategies[i] = strategy
+	}
+
+	if len(strategies) == 0 {
+		return false, nil
+	}
+	svc.workspaceStrategies = strategies
+	return true, nil
+}
+
+func workspaceStrategyNames() []string {
+	names := make([]string, 0, len(workspaceStrategies))
+	for name := range workspaceStrategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
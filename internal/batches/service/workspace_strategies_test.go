@@ -0,0 +1,75 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestResolveWorkspaceStrategies(t *testing.T) {
+	const noStrategies = "name: test\nworkspaces:\n  - in: github.com/*\n    rootAtLocationOf: go.mod\n"
+	runSpecPassTests(t, func(svc *Service) specPass { return svc.resolveWorkspaceStrategies }, map[string]specPassTest{
+		"no strategies": {
+			spec: noStrategies,
+			want: noStrategies,
+			check: func(t *testing.T, svc *Service, _ []byte) {
+				if svc.workspaceStrategies != nil {
+					t.Errorf("strategies were registered: %+v", svc.workspaceStrategies)
+				}
+			},
+		},
+		"strategies": {
+			spec: `name: test
+workspaces:
+  - in: github.com/sourcegraph/*
+    rootAtLocationOf: package.json
+  - in: github.com/rust-lang/*
+    strategy: cargo
+    onlyFetchWorkspace: true
+`,
+			check: func(t *testing.T, svc *Service, data []byte) {
+				var have struct {
+					Workspaces []map[string]interface{}
+				}
+				unmarshalSpec(t, data, &have)
+				want := []map[string]interface{}{
+					{"in": "github.com/sourcegraph/*", "rootAtLocationOf": "package.json"},
+					{"in": "github.com/rust-lang/*", "rootAtLocationOf": "Cargo.toml", "onlyFetchWorkspace": true},
+				}
+				if diff := cmp.Diff(want, have.Workspaces); diff != "" {
+					t.Errorf("wrong workspaces (-want +have):\n%s", diff)
+				}
+
+				if _, ok := svc.workspaceStrategies[0]; ok {
+					t.Error("strategy registered for workspace without strategy")
+				}
+				if s := svc.workspaceStrategies[1]; s.manifest != "Cargo.toml" {
+					t.Errorf("wrong strategy registered: %+v", s)
+				}
+			},
+		},
+		"unknown strategy": {
+			spec:    "workspaces:\n  - in: '*'\n    strategy: nope\n",
+			wantErr: []string{`workspace 1: unknown strategy "nope"`},
+		},
+		"rootAtLocationOf set": {
+			spec:    "workspaces:\n  - in: '*'\n    strategy: go-modules\n    rootAtLocationOf: go.mod\n",
+			wantErr: []string{"only one of strategy and rootAtLocationOf may be set"},
+		},
+	})
+}
+
+func TestWorkspaceStrategyIncludes(t *testing.T) {
+	strategy := workspaceStrategies["go-modules"]
+	for dir, want := range map[string]bool{
+		"":                      true,
+		"cmd/tool":              true,
+		"vendored":              true,
+		"vendor/github.com/x/y": false,
+		"internal/testdata/mod": false,
+	} {
+		if have := strategy.includes(dir); have != want {
+			t.Errorf("includes(%q) = %t, want %t", dir, have, want)
+		}
+	}
+}
@@ -14,9 +14,12 @@ import (
 )
 
 type RepoWorkspace struct {
-	Repo               *graphql.Repository
-	Path               string
-	Steps              []batcheslib.Step
+	Repo  *graphql.Repository
+	Path  string
+	Steps []batcheslib.Step
+	// StepIndexes are the indexes of Steps in the steps of the batch spec,
+	// or nil if they're the same. See executor.Task.
+	StepIndexes        []int
 	OnlyFetchWorkspace bool
 }
 
@@ -29,10 +32,15 @@ type directoryFinder interface {
 // each repository.
 // The repositories that were matched by a workspace config and all repos that didn't
 // match a config are returned as workspaces.
+// strategies maps workspace config indexes to the strategy they were given
+// with, whose ignored directories are excluded from the found workspaces.
+// workspaceSteps maps workspace config indexes to the steps they add.
 func findWorkspaces(
 	ctx context.Context,
 	spec *batcheslib.BatchSpec,
 	finder directoryFinder,
+	strategies map[int]workspaceStrategy,
+	workspaceSteps map[int]workspaceSteps,
 	repos []*graphql.Repository,
 ) ([]RepoWorkspace, error) {
 	repoByID := make(map[string]*graphql.Repository)
@@ -82,6 +90,8 @@ func findWorkspaces(
 		Repo               *graphql.Repository
 		Paths              []string
 		OnlyFetchWorkspace bool
+		// Conf is the index of the workspace config, or -1 for the root.
+		Conf int
 	}
 	workspacesByID := map[string]repoWorkspaces{}
 	for idx, repos := range matched {
@@ -92,6 +102,16 @@ func findWorkspaces(
 		}
 
 		for repo, dirs := range repoDirs {
+			if strategy, ok := strategies[idx]; ok {
+				var included []string
+				for _, dir := range dirs {
+					if strategy.includes(dir) {
+						included = append(included, dir)
+					}
+				}
+				dirs = included
+			}
+
 			// Don't add repos that don't have any matched workspaces.
 			if len(dirs) == 0 {
 				continue
@@ -100,6 +120,7 @@ func findWorkspaces(
 				Repo:               repo,
 				Paths:              dirs,
 				OnlyFetchWorkspace: conf.OnlyFetchWorkspace,
+				Conf:               idx,
 			}
 		}
 	}
@@ -112,6 +133,7 @@ func findWorkspaces(
 				Repo:               repo,
 				Paths:              []string{""},
 				OnlyFetchWorkspace: false,
+				Conf:               -1,
 			}
 			continue
 		}
@@ -126,7 +148,10 @@ func findWorkspaces(
 				fetchWorkspace = false
 			}
 
-			steps, err := stepsForRepo(spec, util.NewTemplatingRepo(workspace.Repo.Name, workspace.Repo.FileMatches))
+			wsSpec := *spec
+			var wsIndexes []int
+			wsSpec.Steps, wsIndexes = stepsForWorkspace(spec.Steps, workspaceSteps, workspace.Conf)
+			steps, indexes, err := stepsForRepo(&wsSpec, util.NewTemplatingRepo(workspace.Repo.Name, workspace.Repo.FileMatches))
 			if err != nil {
 				return nil, err
 			}
@@ -140,6 +165,7 @@ func findWorkspaces(
 				Repo:               workspace.Repo,
 				Path:               path,
 				Steps:              steps,
+				StepIndexes:        specStepIndexes(wsIndexes, indexes),
 				OnlyFetchWorkspace: fetchWorkspace,
 			})
 		}
@@ -156,13 +182,53 @@ func findWorkspaces(
 	return workspaces, nil
 }
 
-// stepsForRepo calculates the steps required to run on the given repo.
-func stepsForRepo(spec *batcheslib.BatchSpec, repo template.Repository) ([]batcheslib.Step, error) {
+// WorkspaceAt returns the workspace at the given path in the given repository,
+// with the steps of the spec that are run in it, without searching the
+// repository for workspaces, such as for the fixtures of `src batch test`.
+// If no steps are run in the workspace, ok is false.
+func (svc *Service) WorkspaceAt(spec *batcheslib.BatchSpec, repo *graphql.Repository, path string) (ws RepoWorkspace, ok bool, err error) {
+	conf := -1
+	for idx, c := range spec.Workspaces {
+		g, err := glob.Compile(c.In)
+		if err != nil {
+			return RepoWorkspace{}, false, batcheslib.NewValidationError(errors.Errorf("failed to compile glob %q: %v", c.In, err))
+		}
+		if g.Match(repo.Name) {
+			conf = idx
+			break
+		}
+	}
+
+	wsSpec := *spec
+	var wsIndexes []int
+	wsSpec.Steps, wsIndexes = stepsForWorkspace(spec.Steps, svc.workspaceSteps, conf)
+	steps, indexes, err := stepsForRepo(&wsSpec, util.NewTemplatingRepo(repo.Name, repo.FileMatches))
+	if err != nil {
+		return RepoWorkspace{}, false, err
+	}
+	if len(steps) == 0 {
+		return RepoWorkspace{}, false, nil
+	}
+
+	return RepoWorkspace{
+		Repo:               repo,
+		Path:               path,
+		Steps:              steps,
+		StepIndexes:        specStepIndexes(wsIndexes, indexes),
+		OnlyFetchWorkspace: conf >= 0 && path != "" && spec.Workspaces[conf].OnlyFetchWorkspace,
+	}, true, nil
+}
+
+// stepsForRepo calculates the steps required to run on the given repo, and
+// their indexes in the steps of the spec.
+func stepsForRepo(spec *batcheslib.BatchSpec, repo template.Repository) ([]batcheslib.Step, []int, error) {
 	taskSteps := []batcheslib.Step{}
-	for _, step := range spec.Steps {
+	indexes := []int{}
+	for idx, step := range spec.Steps {
 		// If no if condition is given, just go ahead and add the step to the list.
 		if step.IfCondition() == "" {
 			taskSteps = append(taskSteps, step)
+			indexes = append(indexes, idx)
 			continue
 		}
 
@@ -176,16 +242,36 @@ func stepsForRepo(spec *batcheslib.BatchSpec, repo template.Repository) ([]batch
 		}
 		static, boolVal, err := template.IsStaticBool(step.IfCondition(), stepCtx)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		// If we could evaluate the condition statically and the resulting
 		// boolean is false, we don't add that step.
 		if !static {
 			taskSteps = append(taskSteps, step)
+			indexes = append(indexes, idx)
 		} else if boolVal {
 			taskSteps = append(taskSteps, step)
+			indexes = append(indexes, idx)
 		}
 	}
-	return taskSteps, nil
+	return taskSteps, indexes, nil
+}
+
+// specStepIndexes returns the indexes in the steps of the spec of the steps
+// that stepsForRepo selected from the steps that stepsForWorkspace selected,
+// given the indexes they returned, or nil if they're the same.
+func specStepIndexes(workspaceIndexes, repoIndexes []int) []int {
+	indexes := make([]int, len(repoIndexes))
+	same := true
+	for i, idx := range repoIndexes {
+		indexes[i] = workspaceIndexes[idx]
+		if indexes[i] != i {
+			same = false
+		}
+	}
+	if same {
+		return nil
+	}
+	return indexes
 }
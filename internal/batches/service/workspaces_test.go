@@ -26,6 +26,8 @@ func TestFindWorkspaces(t *testing.T) {
 	tests := map[string]struct {
 		spec          *batcheslib.BatchSpec
 		finderResults map[*graphql.Repository][]string
+		strategies    map[int]workspaceStrategy
+		steps         map[int]workspaceSteps
 
 		// workspaces in which repo/path they are executed
 		wantWorkspaces []RepoWorkspace
@@ -118,12 +120,72 @@ func TestFindWorkspaces(t *testing.T) {
 				{Repo: repos[2], Steps: steps, Path: "d/e/f", OnlyFetchWorkspace: true},
 			},
 		},
+		"workspace configuration with strategy": {
+			spec: &batcheslib.BatchSpec{
+				Steps: steps,
+				Workspaces: []batcheslib.WorkspaceConfiguration{
+					{In: "*automation-testing", RootAtLocationOf: "go.mod"},
+				},
+			},
+			finderResults: finderResults{
+				repos[0]: {"", "cmd/tool", "vendor/github.com/x/y", "internal/testdata/mod"},
+				repos[2]: {"vendor"},
+			},
+			strategies: map[int]workspaceStrategy{0: workspaceStrategies["go-modules"]},
+			wantWorkspaces: []RepoWorkspace{
+				{Repo: repos[0], Steps: steps, Path: ""},
+				{Repo: repos[0], Steps: steps, Path: "cmd/tool"},
+				{Repo: repos[1], Steps: steps, Path: ""},
+			},
+		},
+		"workspace configurations with steps": {
+			spec: &batcheslib.BatchSpec{
+				Steps: []batcheslib.Step{{Run: "echo 1"}, {Run: "yarn"}, {Run: "go mod tidy"}},
+				Workspaces: []batcheslib.WorkspaceConfiguration{
+					{In: "github.com/*/automation-testing", RootAtLocationOf: "package.json"},
+					{In: "bitbucket.sgdev.org/*", RootAtLocationOf: "go.mod"},
+				},
+			},
+			finderResults: finderResults{
+				repos[0]: {"web"},
+				repos[2]: {"", "cmd/tool"},
+			},
+			steps: map[int]workspaceSteps{
+				0: {indexes: []int{1}},
+				1: {indexes: []int{2}, replace: true},
+			},
+			wantWorkspaces: []RepoWorkspace{
+				{Repo: repos[0], Steps: []batcheslib.Step{{Run: "echo 1"}, {Run: "yarn"}}, Path: "web"},
+				{Repo: repos[1], Steps: steps, Path: ""},
+				{Repo: repos[2], Steps: []batcheslib.Step{{Run: "go mod tidy"}}, StepIndexes: []int{2}, Path: ""},
+				{Repo: repos[2], Steps: []batcheslib.Step{{Run: "go mod tidy"}}, StepIndexes: []int{2}, Path: "cmd/tool"},
+			},
+		},
+		"workspace configuration replacing an identical step": {
+			spec: &batcheslib.BatchSpec{
+				Steps: []batcheslib.Step{{Run: "echo 1"}, {Run: "echo 1"}},
+				Workspaces: []batcheslib.WorkspaceConfiguration{
+					{In: "bitbucket.sgdev.org/*", RootAtLocationOf: "go.mod"},
+				},
+			},
+			finderResults: finderResults{
+				repos[2]: {""},
+			},
+			steps: map[int]workspaceSteps{
+				0: {indexes: []int{1}, replace: true},
+			},
+			wantWorkspaces: []RepoWorkspace{
+				{Repo: repos[0], Steps: steps, Path: ""},
+				{Repo: repos[1], Steps: steps, Path: ""},
+				{Repo: repos[2], Steps: steps, StepIndexes: []int{1}, Path: ""},
+			},
+		},
 	}
 
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
 			finder := &mockDirectoryFinder{results: tt.finderResults}
-			workspaces, err := findWorkspaces(context.Background(), tt.spec, finder, repos)
+			workspaces, err := findWorkspaces(context.Background(), tt.spec, finder, tt.strategies, tt.steps, repos)
 			if err != nil {
 				t.Fatalf("unexpected err: %s", err)
 			}
@@ -148,7 +210,13 @@ type mockDirectoryFinder struct {
 }
 
 func (m *mockDirectoryFinder) FindDirectoriesInRepos(ctx context.Context, fileName string, repos ...*graphql.Repository) (map[*graphql.Repository][]string, error) {
-	return m.results, nil
+	results := make(map[*graphql.Repository][]string)
+	for _, repo := range repos {
+		if dirs, ok := m.results[repo]; ok {
+			results[repo] = dirs
+		}
+	}
+	return results, nil
 }
 
 func TestStepsForRepo(t *testing.T) {
@@ -260,7 +328,7 @@ func TestStepsForRepo(t *testing.T) {
 
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			haveSteps, err := stepsForRepo(tt.spec, util.NewTemplatingRepo(testRepo1.Name, testRepo1.FileMatches))
+			haveSteps, _, err := stepsForRepo(tt.spec, util.NewTemplatingRepo(testRepo1.Name, testRepo1.FileMatches))
 			if err != nil {
 				t.Fatalf("unexpected err: %s", err)
 			}
@@ -272,3 +340,35 @@ func TestStepsForRepo(t *testing.T) {
 		})
 	}
 }
+
+func TestWorkspaceAt(t *testing.T) {
+	repo := &graphql.Repository{ID: "repo-id-0", Name: "github.com/sourcegraph/automation-testing"}
+	steps := []batcheslib.Step{{Run: "echo 1"}, {Run: "echo 2", If: "false"}}
+	spec := &batcheslib.BatchSpec{
+		Steps: steps,
+		Workspaces: []batcheslib.WorkspaceConfiguration{
+			{In: "github.com/sourcegraph/sourcegraph", RootAtLocationOf: "go.mod"},
+			{In: "*automation-testing", RootAtLocationOf: "package.json", OnlyFetchWorkspace: true},
+		},
+	}
+	svc := &Service{}
+
+	for path, wantFetch := range map[string]bool{"": false, "a/b": true} {
+		ws, ok, err := svc.WorkspaceAt(spec, repo, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			t.Fatalf("%q: no workspace", path)
+		}
+		want := RepoWorkspace{Repo: repo, Path: path, Steps: steps[:1], OnlyFetchWorkspace: wantFetch}
+		if diff := cmp.Diff(want, ws); diff != "" {
+			t.Errorf("%q: wrong workspace (-want +have):\n%s", path, diff)
+		}
+	}
+
+	spec.Steps = steps[1:]
+	if _, ok, err := svc.WorkspaceAt(spec, repo, ""); err != nil || ok {
+		t.Errorf("want no workspace without steps, have ok=%t err=%v", ok, err)
+	}
+}
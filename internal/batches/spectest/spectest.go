@@ -0,0 +1,262 @@
+// Package spectest defines the test files of `src batch test`, which execute
+// a batch spec against fixture repositories and compare the diffs and outputs
+// to golden files, the way unit tests do.
+package spectest
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/sourcegraph/src-cli/internal/siteconfig"
+)
+
+// TestFile is a batch spec and the fixtures it's tested against.
+//
+//	spec: hello-world.batch.yaml
+//	fixtures:
+//	  - name: go-module
+//	    dir: testdata/go-module
+//	    repository: github.com/example/go-module
+//	    diff: testdata/go-module.diff
+//	    outputs: testdata/go-module.outputs.json
+//	  - repository: github.com/sourcegraph/src-cli@3.31.0
+//	    diff: testdata/src-cli.diff
+type TestFile struct {
+	// Spec is the path of the batch spec.
+	Spec     string    `yaml:"spec"`
+	Fixtures []Fixture `yaml:"fixtures"`
+}
+
+// Fixture is a repository the batch spec is executed in, and the golden files
+// of the result.
+type Fixture struct {
+	// Name defaults to the repository, or to the directory.
+	Name string `yaml:"name"`
+	// Dir is a local directory that is used as the repository, instead of
+	// fetching it from Sourcegraph.
+	Dir string `yaml:"dir"`
+	// Repository is the name of the repository, optionally followed by @ and
+	// the revision. If Dir is set, it's the name the directory is executed as,
+	// and defaults to the name of the directory.
+	Repository string `yaml:"repository"`
+	// Path is the path of the workspace in the repository. The root of the
+	// repository is the empty string.
+	Path string `yaml:"path"`
+	// Diff is the path of the golden file of the diff.
+	Diff string `yaml:"diff"`
+	// Outputs is the path of the golden file of the outputs of the steps, as
+	// JSON. If it's empty, the outputs aren't checked.
+	Outputs string `yaml:"outputs"`
+}
+
+// Parse reads the test file at path. Unknown fields are errors. The paths of
+// the spec, the directories, and the golden files are relative to the
+// directory of the test file, and are returned as absolute paths.
+func Parse(path string) (*TestFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading test file")
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	var tf TestFile
+	if err := dec.Decode(&tf); err != nil {
+		return nil, errors.Wrapf(err, "parsing test file %s", path)
+	}
+
+	base, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	if err := tf.resolve(base); err != nil {
+		return nil, errors.Wrapf(err, "invalid test file %s", path)
+	}
+	return &tf, nil
+}
+
+func (tf *TestFile) resolve(base string) error {
+	abs := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(base, filepath.FromSlash(p))
+	}
+
+	if tf.Spec == "" {
+		return errors.New("spec is required")
+	}
+	tf.Spec = abs(tf.Spec)
+
+	if len(tf.Fixtures) == 0 {
+		return errors.New("at least one fixture is required")
+	}
+	names := map[string]bool{}
+	for i := range tf.Fixtures {
+		f := &tf.Fixtures[i]
+		if f.Dir == "" && f.Repository == "" {
+			return errors.Newf("fixture %d: dir or repository is required", i+1)
+		}
+		if f.Dir != "" && strings.Contains(f.Repository, "@") {
+			return errors.Newf("fixture %d: repository of a dir cannot have a revision", i+1)
+		}
+		if f.Diff == "" {
+			return errors.Newf("fixture %d: diff is required", i+1)
+		}
+		f.Path = strings.Trim(f.Path, "/")
+
+		if f.Dir != "" && f.Repository == "" {
+			f.Repository = filepath.Base(filepath.Clean(f.Dir))
+		}
+		if f.Name == "" {
+			f.Name = f.Repository
+		}
+		if names[f.Name] {
+			return errors.Newf("fixture %d: duplicate name %q", i+1, f.Name)
+		}
+		names[f.Name] = true
+
+		f.Dir = abs(f.Dir)
+		f.Diff = abs(f.Diff)
+		f.Outputs = abs(f.Outputs)
+	}
+	return nil
+}
+
+// IsLocal returns whether the fixture is a local directory.
+func (f *Fixture) IsLocal() bool {
+	return f.Dir != ""
+}
+
+// RepoAndRev returns the name and the revision of the repository. rev is
+// empty if the default branch is used.
+func (f *Fixture) RepoAndRev() (name, rev string) {
+	if i := strings.LastIndex(f.Repository, "@"); i >= 0 && !f.IsLocal() {
+		return f.Repository[:i], f.Repository[i+1:]
+	}
+	return f.Repository, ""
+}
+
+// Mismatch is a golden file that doesn't match the result.
+type Mismatch struct {
+	// File is the path of the golden file.
+	File string
+	// Diff is the unified diff from the golden file to the result, or a
+	// description of the problem if the golden file doesn't exist.
+	Diff string
+}
+
+// Check compares the given diff and outputs to the golden files of the
+// fixture.
+func (f *Fixture) Check(diff string, outputs map[string]interface{}) ([]Mismatch, error) {
+	var mismatches []Mismatch
+
+	want, ok, err := readGolden(f.Diff)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		mismatches = append(mismatches, missingGolden(f.Diff))
+	} else if d := siteconfig.Diff(f.Diff, "result", want, diff); d != "" {
+		mismatches = append(mismatches, Mismatch{File: f.Diff, Diff: d})
+	}
+
+	if f.Outputs == "" {
+		return mismatches, nil
+	}
+	want, ok, err = readGolden(f.Outputs)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return append(mismatches, missingGolden(f.Outputs)), nil
+	}
+	var wantOutputs map[string]interface{}
+	if err := json.Unmarshal([]byte(want), &wantOutputs); err != nil {
+		return nil, errors.Wrapf(err, "reading %s", f.Outputs)
+	}
+	have, err := marshalOutputs(outputs)
+	if err != nil {
+		return nil, err
+	}
+	// Compare the decoded values, so that the formatting of the golden file
+	// doesn't matter.
+	var haveOutputs map[string]interface{}
+	if err := json.Unmarshal(have, &haveOutputs); err != nil {
+		return nil, err
+	}
+	if !reflect.DeepEqual(normalizeOutputs(wantOutputs), normalizeOutputs(haveOutputs)) {
+		wantFormatted, err := marshalOutputs(wantOutputs)
+		if err != nil {
+			return nil, err
+		}
+		mismatches = append(mismatches, Mismatch{
+			File: f.Outputs,
+			Diff: siteconfig.Diff(f.Outputs, "result", string(wantFormatted), string(have)),
+		})
+	}
+	return mismatches, nil
+}
+
+// Update writes the given diff and outputs to the golden files of the
+// fixture.
+func (f *Fixture) Update(diff string, outputs map[string]interface{}) error {
+	if err := writeGolden(f.Diff, []byte(diff)); err != nil {
+		return err
+	}
+	if f.Outputs == "" {
+		return nil
+	}
+	data, err := marshalOutputs(outputs)
+	if err != nil {
+		return err
+	}
+	return writeGolden(f.Outputs, data)
+}
+
+func readGolden(path string) (string, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", false, nil
+		}
+		return "", false, errors.Wrap(err, "reading golden file")
+	}
+	return string(data), true, nil
+}
+
+func missingGolden(path string) Mismatch {
+	return Mismatch{File: path, Diff: "golden file doesn't exist, run with -update to create it"}
+}
+
+func writeGolden(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return errors.Wrap(os.WriteFile(path, data, 0644), "writing golden file")
+}
+
+func marshalOutputs(outputs map[string]interface{}) ([]byte, error) {
+	data, err := json.MarshalIndent(normalizeOutputs(outputs), "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "encoding outputs")
+	}
+	return append(data, '\n'), nil
+}
+
+// normalizeOutputs returns an empty map for nil, so that steps without
+// outputs match a golden file of {}.
+func normalizeOutputs(outputs map[string]interface{}) map[string]interface{} {
+	if outputs == nil {
+		return map[string]interface{}{}
+	}
+	return outputs
+}
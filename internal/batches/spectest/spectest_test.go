@@ -0,0 +1,156 @@
+package spectest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "batch.test.yaml")
+	if err := os.WriteFile(path, []byte(`
+spec: hello.batch.yaml
+fixtures:
+  - dir: testdata/go-module
+    path: /cmd/
+    diff: testdata/go-module.diff
+    outputs: testdata/go-module.json
+  - name: src-cli
+    repository: github.com/sourcegraph/src-cli@3.31.0
+    diff: /golden/src-cli.diff
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tf, err := Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "hello.batch.yaml"); tf.Spec != want {
+		t.Errorf("wrong spec: want %q, have %q", want, tf.Spec)
+	}
+
+	local := tf.Fixtures[0]
+	if !local.IsLocal() || local.Name != "go-module" || local.Path != "cmd" {
+		t.Errorf("wrong local fixture: %+v", local)
+	}
+	if want := filepath.Join(dir, "testdata", "go-module.json"); local.Outputs != want {
+		t.Errorf("wrong outputs: want %q, have %q", want, local.Outputs)
+	}
+	if name, rev := local.RepoAndRev(); name != "go-module" || rev != "" {
+		t.Errorf("wrong repository of local fixture: %q@%q", name, rev)
+	}
+
+	remote := tf.Fixtures[1]
+	if remote.IsLocal() || remote.Diff != "/golden/src-cli.diff" {
+		t.Errorf("wrong remote fixture: %+v", remote)
+	}
+	if name, rev := remote.RepoAndRev(); name != "github.com/sourcegraph/src-cli" || rev != "3.31.0" {
+		t.Errorf("wrong repository of remote fixture: %q@%q", name, rev)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for name, tc := range map[string]struct {
+		content string
+		wantErr string
+	}{
+		"unknown field": {
+			content: "spec: a.yaml\nfixture: []\n",
+			wantErr: "field fixture not found",
+		},
+		"no spec": {
+			content: "fixtures:\n  - dir: a\n    diff: a.diff\n",
+			wantErr: "spec is required",
+		},
+		"no fixtures": {
+			content: "spec: a.yaml\n",
+			wantErr: "at least one fixture is required",
+		},
+		"no repository": {
+			content: "spec: a.yaml\nfixtures:\n  - diff: a.diff\n",
+			wantErr: "fixture 1: dir or repository is required",
+		},
+		"no diff": {
+			content: "spec: a.yaml\nfixtures:\n  - dir: a\n",
+			wantErr: "fixture 1: diff is required",
+		},
+		"dir with revision": {
+			content: "spec: a.yaml\nfixtures:\n  - dir: a\n    repository: a@main\n    diff: a.diff\n",
+			wantErr: "fixture 1: repository of a dir cannot have a revision",
+		},
+		"duplicate name": {
+			content: "spec: a.yaml\nfixtures:\n  - dir: a\n    diff: a.diff\n  - dir: b/a\n    diff: b.diff\n",
+			wantErr: `fixture 2: duplicate name "a"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "batch.test.yaml")
+			if err := os.WriteFile(path, []byte(tc.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Parse(path)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("want error %q, have %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestFixture_CheckAndUpdate(t *testing.T) {
+	dir := t.TempDir()
+	f := Fixture{
+		Diff:    filepath.Join(dir, "golden", "a.diff"),
+		Outputs: filepath.Join(dir, "golden", "a.json"),
+	}
+	diff := "diff --git README.md README.md\n+hello\n"
+	outputs := map[string]interface{}{"count": 2, "files": []string{"README.md"}}
+
+	mismatches, err := f.Check(diff, outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatches) != 2 || !strings.Contains(mismatches[0].Diff, "doesn't exist") {
+		t.Fatalf("want two missing golden files, have %+v", mismatches)
+	}
+
+	if err := f.Update(diff, outputs); err != nil {
+		t.Fatal(err)
+	}
+	if mismatches, err := f.Check(diff, outputs); err != nil || len(mismatches) != 0 {
+		t.Fatalf("want no mismatches after update, have %+v, %v", mismatches, err)
+	}
+
+	// The formatting of the outputs doesn't matter.
+	if err := os.WriteFile(f.Outputs, []byte(`{"files":["README.md"],"count":2}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if mismatches, err := f.Check(diff, outputs); err != nil || len(mismatches) != 0 {
+		t.Fatalf("want no mismatches for reformatted outputs, have %+v, %v", mismatches, err)
+	}
+
+	mismatches, err = f.Check(diff+"+world\n", map[string]interface{}{"count": 3, "files": []string{"README.md"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatches) != 2 {
+		t.Fatalf("want two mismatches, have %+v", mismatches)
+	}
+	if !strings.Contains(mismatches[0].Diff, "\n++world\n") {
+		t.Errorf("wrong diff of the diff:\n%s", mismatches[0].Diff)
+	}
+	if !strings.Contains(mismatches[1].Diff, `+  "count": 3,`) {
+		t.Errorf("wrong diff of the outputs:\n%s", mismatches[1].Diff)
+	}
+
+	// Steps without outputs match {}.
+	f.Outputs = filepath.Join(dir, "golden", "empty.json")
+	if err := f.Update(diff, nil); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(f.Outputs); string(data) != "{}\n" {
+		t.Errorf("wrong empty outputs: %q", data)
+	}
+}
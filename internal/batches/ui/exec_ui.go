@@ -4,6 +4,7 @@ import (
 	"github.com/sourcegraph/src-cli/internal/batches"
 	"github.com/sourcegraph/src-cli/internal/batches/executor"
 	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/repozip"
 	"github.com/sourcegraph/src-cli/internal/batches/workspace"
 )
 
@@ -35,6 +36,16 @@ type ExecUI interface {
 	ExecutingTasksSkippingErrors(err error)
 
 	LogFilesKept(files []string)
+	ArchiveCacheStats(stats repozip.CacheStats)
+	WorkspacesKept(workspaces []executor.KeptWorkspace)
+
+	CheckingBaseBranches()
+	CheckingBaseBranchesSuccess(stale, conflicting []string)
+
+	CheckingBranchCollisions()
+	CheckingBranchCollisionsSuccess(collisions []string)
+
+	LargeChangesetSpecs(specs []string)
 
 	NoChangesetSpecs()
 	UploadingChangesetSpecs(num int)
@@ -14,6 +14,7 @@ import (
 	"github.com/sourcegraph/src-cli/internal/batches"
 	"github.com/sourcegraph/src-cli/internal/batches/executor"
 	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/repozip"
 	"github.com/sourcegraph/src-cli/internal/batches/workspace"
 
 	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
@@ -61,6 +62,8 @@ func (ui *JSONLines) DeterminingWorkspaceCreatorTypeSuccess(wt workspace.Creator
 		t = "VOLUME"
 	case workspace.CreatorTypeBind:
 		t = "BIND"
+	case workspace.CreatorTypeRemote:
+		t = "REMOTE"
 	}
 	logOperationSuccess(batcheslib.LogEventOperationDeterminingWorkspaceType, &batcheslib.DeterminingWorkspaceTypeMetadata{Type: t})
 }
@@ -110,6 +113,34 @@ func (ui *JSONLines) LogFilesKept(files []string) {
 	}
 }
 
+// ArchiveCacheStats is a no-op, since there is no log event for the cache of
+// repository archives.
+func (ui *JSONLines) ArchiveCacheStats(stats repozip.CacheStats) {}
+
+// WorkspacesKept is a no-op, since there is no log event for kept
+// workspaces.
+func (ui *JSONLines) WorkspacesKept(workspaces []executor.KeptWorkspace) {}
+
+// CheckingBaseBranches is a no-op, since there is no log event for checking
+// base branches.
+func (ui *JSONLines) CheckingBaseBranches() {}
+
+// CheckingBaseBranchesSuccess is a no-op, since there is no log event for
+// checking base branches.
+func (ui *JSONLines) CheckingBaseBranchesSuccess(stale, conflicting []string) {}
+
+// CheckingBranchCollisions is a no-op, since there is no log event for
+// checking branch collisions.
+func (ui *JSONLines) CheckingBranchCollisions() {}
+
+// CheckingBranchCollisionsSuccess is a no-op, since there is no log event for
+// checking branch collisions.
+func (ui *JSONLines) CheckingBranchCollisionsSuccess(collisions []string) {}
+
+// LargeChangesetSpecs is a no-op, since there is no log event for large
+// changeset specs.
+func (ui *JSONLines) LargeChangesetSpecs(specs []string) {}
+
 func (ui *JSONLines) NoChangesetSpecs() {
 	ui.UploadingChangesetSpecsSuccess([]graphql.ChangesetSpecID{})
 }
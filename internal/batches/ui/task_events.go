@@ -0,0 +1,155 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/lib/batches/git"
+
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+)
+
+// TaskEventType is the type of a TaskEvent.
+type TaskEventType string
+
+const (
+	// TaskEventQueued is emitted for every task that is going to be executed,
+	// before execution of any task starts.
+	TaskEventQueued TaskEventType = "queued"
+	// TaskEventCached is emitted for tasks whose results were found in the
+	// cache. If only the results of some steps were found, Step is the last
+	// step that is skipped.
+	TaskEventCached TaskEventType = "cached"
+	// TaskEventDownloading is emitted when the repository archive for a task
+	// starts downloading.
+	TaskEventDownloading TaskEventType = "downloading"
+	// TaskEventStepStarted is emitted when the container for a step is
+	// started.
+	TaskEventStepStarted TaskEventType = "step-started"
+	// TaskEventStepFinished is emitted when a step finished, successfully or
+	// not. ExitCode is -1 if the step failed before its container exited.
+	TaskEventStepFinished TaskEventType = "step-finished"
+	// TaskEventDone is emitted when a task is finished. Error is set if the
+	// task failed.
+	TaskEventDone TaskEventType = "done"
+)
+
+// TaskEvent is a single line of the newline-delimited JSON stream written by
+// TaskEventStream. Steps are numbered starting at 1.
+type TaskEvent struct {
+	Type       TaskEventType `json:"type"`
+	Timestamp  time.Time     `json:"timestamp"`
+	Repository string        `json:"repository"`
+	Workspace  string        `json:"workspace"`
+	Step       int           `json:"step,omitempty"`
+	ExitCode   *int          `json:"exitCode,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// TaskEventStream writes task lifecycle events as newline-delimited JSON, so
+// that other tools can build their own progress UIs around src-cli.
+type TaskEventStream struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+
+	clock func() time.Time
+}
+
+// NewTaskEventStream returns a TaskEventStream that writes to w. It is safe to
+// use from multiple goroutines.
+func NewTaskEventStream(w io.Writer) *TaskEventStream {
+	return &TaskEventStream{
+		enc:   json.NewEncoder(w),
+		clock: func() time.Time { return time.Now().UTC().Truncate(time.Millisecond) },
+	}
+}
+
+// Cached emits a TaskEventCached event for each of the given tasks.
+func (s *TaskEventStream) Cached(tasks []*executor.Task) {
+	for _, t := range tasks {
+		s.emit(t, TaskEvent{Type: TaskEventCached})
+	}
+}
+
+// Wrap returns a TaskExecutionUI that emits events to the stream in addition
+// to calling ui.
+func (s *TaskEventStream) Wrap(ui executor.TaskExecutionUI) executor.TaskExecutionUI {
+	return &taskExecutionEvents{TaskExecutionUI: ui, stream: s}
+}
+
+func (s *TaskEventStream) emit(task *executor.Task, e TaskEvent) {
+	e.Timestamp = s.clock()
+	e.Repository = task.Repository.Name
+	e.Workspace = task.Path
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.enc.Encode(e); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+type taskExecutionEvents struct {
+	executor.TaskExecutionUI
+	stream *TaskEventStream
+}
+
+func (ui *taskExecutionEvents) Start(tasks []*executor.Task) {
+	for _, t := range tasks {
+		ui.stream.emit(t, TaskEvent{Type: TaskEventQueued})
+	}
+	ui.TaskExecutionUI.Start(tasks)
+}
+
+func (ui *taskExecutionEvents) TaskFinished(task *executor.Task, err error) {
+	e := TaskEvent{Type: TaskEventDone}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	ui.stream.emit(task, e)
+	ui.TaskExecutionUI.TaskFinished(task, err)
+}
+
+func (ui *taskExecutionEvents) StepsExecutionUI(task *executor.Task) executor.StepsExecutionUI {
+	return &stepsExecutionEvents{
+		StepsExecutionUI: ui.TaskExecutionUI.StepsExecutionUI(task),
+		stream:           ui.stream,
+		task:             task,
+	}
+}
+
+type stepsExecutionEvents struct {
+	executor.StepsExecutionUI
+	stream *TaskEventStream
+	task   *executor.Task
+}
+
+func (ui *stepsExecutionEvents) ArchiveDownloadStarted() {
+	ui.stream.emit(ui.task, TaskEvent{Type: TaskEventDownloading})
+	ui.StepsExecutionUI.ArchiveDownloadStarted()
+}
+
+func (ui *stepsExecutionEvents) SkippingStepsUpto(startStep int) {
+	ui.stream.emit(ui.task, TaskEvent{Type: TaskEventCached, Step: startStep})
+	ui.StepsExecutionUI.SkippingStepsUpto(startStep)
+}
+
+func (ui *stepsExecutionEvents) StepStarted(step int, runScript string, env map[string]string) {
+	ui.stream.emit(ui.task, TaskEvent{Type: TaskEventStepStarted, Step: step})
+	ui.StepsExecutionUI.StepStarted(step, runScript, env)
+}
+
+func (ui *stepsExecutionEvents) StepFinished(step int, diff []byte, changes *git.Changes, outputs map[string]interface{}) {
+	exitCode := 0
+	ui.stream.emit(ui.task, TaskEvent{Type: TaskEventStepFinished, Step: step, ExitCode: &exitCode})
+	ui.StepsExecutionUI.StepFinished(step, diff, changes, outputs)
+}
+
+func (ui *stepsExecutionEvents) StepFailed(step int, err error, exitCode int) {
+	ui.stream.emit(ui.task, TaskEvent{Type: TaskEventStepFinished, Step: step, ExitCode: &exitCode, Error: err.Error()})
+	ui.StepsExecutionUI.StepFailed(step, err, exitCode)
+}
@@ -0,0 +1,82 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+)
+
+func TestTaskEventStream(t *testing.T) {
+	now := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	stream := NewTaskEventStream(&buf)
+	stream.clock = func() time.Time { return now }
+
+	cached := &executor.Task{Repository: &graphql.Repository{Name: "github.com/sourcegraph/cached"}}
+	task := &executor.Task{Repository: &graphql.Repository{Name: "github.com/sourcegraph/src-cli"}, Path: "cmd"}
+
+	stream.Cached([]*executor.Task{cached})
+
+	ui := stream.Wrap(&noopTaskExecutionUI{})
+	ui.Start([]*executor.Task{task})
+	ui.TaskStarted(task)
+	steps := ui.StepsExecutionUI(task)
+	steps.ArchiveDownloadStarted()
+	steps.SkippingStepsUpto(1)
+	steps.StepStarted(2, "echo", nil)
+	steps.StepFinished(2, nil, nil, nil)
+	steps.StepStarted(3, "false", nil)
+	steps.StepFailed(3, errors.New("exit status 1"), 1)
+	ui.TaskFinished(task, errors.New("step failed"))
+
+	zero, one := 0, 1
+	want := []TaskEvent{
+		{Type: TaskEventCached, Repository: "github.com/sourcegraph/cached"},
+		{Type: TaskEventQueued, Repository: "github.com/sourcegraph/src-cli", Workspace: "cmd"},
+		{Type: TaskEventDownloading, Repository: "github.com/sourcegraph/src-cli", Workspace: "cmd"},
+		{Type: TaskEventCached, Repository: "github.com/sourcegraph/src-cli", Workspace: "cmd", Step: 1},
+		{Type: TaskEventStepStarted, Repository: "github.com/sourcegraph/src-cli", Workspace: "cmd", Step: 2},
+		{Type: TaskEventStepFinished, Repository: "github.com/sourcegraph/src-cli", Workspace: "cmd", Step: 2, ExitCode: &zero},
+		{Type: TaskEventStepStarted, Repository: "github.com/sourcegraph/src-cli", Workspace: "cmd", Step: 3},
+		{Type: TaskEventStepFinished, Repository: "github.com/sourcegraph/src-cli", Workspace: "cmd", Step: 3, ExitCode: &one, Error: "exit status 1"},
+		{Type: TaskEventDone, Repository: "github.com/sourcegraph/src-cli", Workspace: "cmd", Error: "step failed"},
+	}
+	for i := range want {
+		want[i].Timestamp = now
+	}
+
+	var have []TaskEvent
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var e TaskEvent
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		have = append(have, e)
+	}
+
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Errorf("wrong events (-want +have):\n%s", diff)
+	}
+}
+
+type noopTaskExecutionUI struct{}
+
+func (noopTaskExecutionUI) Start([]*executor.Task)                                              {}
+func (noopTaskExecutionUI) Success()                                                            {}
+func (noopTaskExecutionUI) Failed(err error)                                                    {}
+func (noopTaskExecutionUI) TaskStarted(*executor.Task)                                          {}
+func (noopTaskExecutionUI) TaskFinished(*executor.Task, error)                                  {}
+func (noopTaskExecutionUI) TaskChangesetSpecsBuilt(*executor.Task, []*batcheslib.ChangesetSpec) {}
+func (noopTaskExecutionUI) StepsExecutionUI(*executor.Task) executor.StepsExecutionUI {
+	return executor.NoopStepsExecUI{}
+}
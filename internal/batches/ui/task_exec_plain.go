@@ -0,0 +1,122 @@
+package ui
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/go-diff/diff"
+
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+	"github.com/sourcegraph/sourcegraph/lib/output"
+)
+
+// taskExecPlain is a TaskExecutionUI that prints a line for every finished
+// task instead of redrawing status bars, so that its output can be read in
+// logs and on terminals that can't redraw lines.
+type taskExecPlain struct {
+	out     *output.Output
+	verbose bool
+
+	mu    sync.Mutex
+	clock clock
+
+	total    int
+	started  map[*executor.Task]time.Time
+	finished int
+	errored  int
+}
+
+var _ executor.TaskExecutionUI = &taskExecPlain{}
+
+func newTaskExecPlain(out *output.Output, verbose bool) *taskExecPlain {
+	return &taskExecPlain{
+		out:     out,
+		verbose: verbose,
+		clock:   defaultClock,
+		started: map[*executor.Task]time.Time{},
+	}
+}
+
+func (ui *taskExecPlain) Start(tasks []*executor.Task) {
+	ui.total = len(tasks)
+	ui.out.WriteLine(output.Linef("", batchPendingColor, "Executing %d tasks", len(tasks)))
+}
+
+func (ui *taskExecPlain) Success() {
+	ui.out.WriteLine(output.Linef(batchSuccessEmoji, batchSuccessColor, "Executed %d tasks, %d errored", ui.finished, ui.errored))
+}
+
+func (ui *taskExecPlain) Failed(err error) {
+	// noop right now
+}
+
+func (ui *taskExecPlain) TaskStarted(task *executor.Task) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.started[task] = ui.clock()
+	ui.out.Verbosef("Started %s", taskDisplayName(task))
+}
+
+func (ui *taskExecPlain) TaskFinished(task *executor.Task, err error) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.finished++
+	took := ui.clock().Sub(ui.started[task]).Truncate(time.Millisecond)
+	if err != nil {
+		ui.errored++
+		status := err.Error()
+		if texter, ok := err.(statusTexter); ok {
+			status = texter.StatusText()
+		}
+		ui.out.WriteLine(output.Linef(output.EmojiFailure, output.StyleWarning, "[%d/%d] %s: %s (%s)", ui.finished, ui.total, taskDisplayName(task), status, took))
+		return
+	}
+	ui.out.WriteLine(output.Linef(batchSuccessEmoji, batchSuccessColor, "[%d/%d] %s: Done! (%s)", ui.finished, ui.total, taskDisplayName(task), took))
+}
+
+func (ui *taskExecPlain) TaskChangesetSpecsBuilt(task *executor.Task, specs []*batcheslib.ChangesetSpec) {
+	if !ui.verbose {
+		return
+	}
+
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	var fileDiffs []*diff.FileDiff
+	for _, spec := range specs {
+		fd, err := diff.ParseMultiFileDiff([]byte(spec.Commits[0].Diff))
+		if err != nil {
+			ui.out.Verbosef("%s: failed to display status: %s", taskDisplayName(task), err)
+			return
+		}
+		fileDiffs = append(fileDiffs, fd...)
+	}
+	if len(fileDiffs) == 0 {
+		return
+	}
+
+	lines, err := verboseDiffSummary(fileDiffs)
+	if err != nil {
+		ui.out.Verbosef("%s: failed to display status: %s", taskDisplayName(task), err)
+		return
+	}
+	ui.out.Verbosef("%s:", taskDisplayName(task))
+	for _, line := range lines {
+		ui.out.Verbose(line)
+	}
+}
+
+func (ui *taskExecPlain) StepsExecutionUI(task *executor.Task) executor.StepsExecutionUI {
+	return executor.NoopStepsExecUI{}
+}
+
+func taskDisplayName(t *executor.Task) string {
+	if t.Path != "" {
+		return t.Repository.Name + ":" + t.Path
+	}
+	return t.Repository.Name
+}
@@ -8,6 +8,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/mattn/go-runewidth"
 	"github.com/sourcegraph/go-diff/diff"
 
 	"github.com/sourcegraph/src-cli/internal/batches/executor"
@@ -68,6 +69,13 @@ func (ts *taskStatus) String() string {
 
 type clock func() time.Time
 
+// maxDisplayNameWidth and maxFilenameWidth are the widths up to which the
+// names of tasks and files are padded to align the columns after them.
+const (
+	maxDisplayNameWidth = 40
+	maxFilenameWidth    = 60
+)
+
 var defaultClock = time.Now
 
 func newTaskExecTUI(out *output.Output, verbose bool, numParallelism int) *taskExecTUI {
@@ -111,19 +119,19 @@ var _ executor.TaskExecutionUI = &taskExecTUI{}
 
 func (ui *taskExecTUI) Start(tasks []*executor.Task) {
 	for _, t := range tasks {
-		status := &taskStatus{}
-		if t.Path != "" {
-			status.displayName = t.Repository.Name + ":" + t.Path
-		} else {
-			status.displayName = t.Repository.Name
-		}
+		status := &taskStatus{displayName: taskDisplayName(t)}
 
-		if len(status.displayName) > ui.maxRepoName {
-			ui.maxRepoName = len(status.displayName)
+		if w := runewidth.StringWidth(status.displayName); w > ui.maxRepoName {
+			ui.maxRepoName = w
 		}
 
 		ui.statuses[t] = status
 	}
+	// Very long names aren't padded, so that they don't push the other
+	// columns off narrow terminals.
+	if ui.maxRepoName > maxDisplayNameWidth {
+		ui.maxRepoName = maxDisplayNameWidth
+	}
 
 	ui.numStatusBars = ui.numParallelism
 	if len(tasks) < ui.numStatusBars {
@@ -299,7 +307,7 @@ func (ui *taskExecTUI) TaskChangesetSpecsBuilt(task *executor.Task, specs []*bat
 	for _, spec := range specs {
 		fd, err := diff.ParseMultiFileDiff([]byte(spec.Commits[0].Diff))
 		if err != nil {
-			ui.progress.Verbosef("%-*s failed to display status: %s", ui.maxRepoName, ts.displayName, err)
+			ui.progress.Verbosef("%s failed to display status: %s", runewidth.FillRight(ts.displayName, ui.maxRepoName), err)
 			return
 		}
 		fileDiffs = append(fileDiffs, fd...)
@@ -312,7 +320,7 @@ func (ui *taskExecTUI) TaskChangesetSpecsBuilt(task *executor.Task, specs []*bat
 	} else {
 		lines, err := verboseDiffSummary(fileDiffs)
 		if err != nil {
-			ui.progress.Verbosef("%-*s failed to display status: %s", ui.maxRepoName, ts.displayName, err)
+			ui.progress.Verbosef("%s failed to display status: %s", runewidth.FillRight(ts.displayName, ui.maxRepoName), err)
 			return
 		}
 
@@ -356,8 +364,8 @@ func verboseDiffSummary(fileDiffs []*diff.FileDiff) ([]string, error) {
 
 		fileNames[i] = name
 
-		if len(name) > maxFilenameLen {
-			maxFilenameLen = len(name)
+		if w := runewidth.StringWidth(name); w > maxFilenameLen {
+			maxFilenameLen = w
 		}
 
 		stat := f.Stat()
@@ -370,11 +378,15 @@ func verboseDiffSummary(fileDiffs []*diff.FileDiff) ([]string, error) {
 		fileStats[name] = fmt.Sprintf("%d %s", num, diffStatDiagram(stat))
 	}
 
+	if maxFilenameLen > maxFilenameWidth {
+		maxFilenameLen = maxFilenameWidth
+	}
+
 	sort.Slice(fileNames, func(i, j int) bool { return fileNames[i] < fileNames[j] })
 
 	for _, name := range fileNames {
 		stats := fileStats[name]
-		lines = append(lines, fmt.Sprintf("\t%-*s | %s", maxFilenameLen, name, stats))
+		lines = append(lines, fmt.Sprintf("\t%s | %s", runewidth.FillRight(name, maxFilenameLen), stats))
 	}
 
 	var insertionsPlural string
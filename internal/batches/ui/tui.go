@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"os/exec"
+	"strings"
 
 	"github.com/cockroachdb/errors"
+	humanize "github.com/dustin/go-humanize"
 	"github.com/hashicorp/go-multierror"
 	"github.com/neelance/parallel"
 	"github.com/sourcegraph/sourcegraph/lib/output"
@@ -14,6 +16,7 @@ import (
 	"github.com/sourcegraph/src-cli/internal/batches"
 	"github.com/sourcegraph/src-cli/internal/batches/executor"
 	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/repozip"
 	"github.com/sourcegraph/src-cli/internal/batches/workspace"
 	"github.com/sourcegraph/src-cli/internal/cmderrors"
 )
@@ -28,18 +31,22 @@ var _ ExecUI = &TUI{}
 
 type TUI struct {
 	Out *output.Output
+	// Plain switches to line-oriented output without spinners and progress
+	// bars, for log collectors and terminals that can't render them.
+	Plain bool
 
-	pending  output.Pending
-	progress output.Progress
+	pending       output.Pending
+	progress      output.Progress
+	progressLabel string
 
 	progressPrinter *taskExecTUI
 }
 
 func (ui *TUI) ParsingBatchSpec() {
-	ui.pending = batchCreatePending(ui.Out, "Parsing batch spec")
+	ui.startPending("Parsing batch spec")
 }
 func (ui *TUI) ParsingBatchSpecSuccess() {
-	batchCompletePending(ui.pending, "Parsing batch spec")
+	ui.completePending("Parsing batch spec")
 }
 
 func (ui *TUI) ParsingBatchSpecFailure(err error) {
@@ -57,48 +64,52 @@ func (ui *TUI) ParsingBatchSpecFailure(err error) {
 }
 
 func (ui *TUI) ResolvingNamespace() {
-	ui.pending = batchCreatePending(ui.Out, "Resolving namespace")
+	ui.startPending("Resolving namespace")
 }
 
 func (ui *TUI) ResolvingNamespaceSuccess(_namespace string) {
-	batchCompletePending(ui.pending, "Resolving namespace")
+	ui.completePending("Resolving namespace")
 }
 
 func (ui *TUI) PreparingContainerImages() {
-	ui.progress = ui.Out.Progress([]output.ProgressBar{{
-		Label: "Preparing container images",
-		Max:   1.0,
-	}}, nil)
+	ui.startProgress("Preparing container images", 1.0)
 }
 
 func (ui *TUI) PreparingContainerImagesProgress(done, total int) {
-	ui.progress.SetValue(0, float64(done)/float64(total))
+	if ui.progress != nil {
+		ui.progress.SetLabelAndRecalc(0, fmt.Sprintf("Preparing container images (%d/%d)", done, total))
+	}
+	if total > 0 {
+		ui.setProgress(float64(done) / float64(total))
+	}
 }
 
 func (ui *TUI) PreparingContainerImagesSuccess() {
-	ui.progress.Complete()
+	ui.completeProgress()
 }
 
 func (ui *TUI) DeterminingWorkspaceCreatorType() {
-	ui.pending = batchCreatePending(ui.Out, "Determining workspace type")
+	ui.startPending("Determining workspace type")
 }
 
 func (ui *TUI) DeterminingWorkspaceCreatorTypeSuccess(wt workspace.CreatorType) {
 	switch wt {
 	case workspace.CreatorTypeBind:
-		ui.pending.VerboseLine(output.Linef("🚧", output.StyleSuccess, "Workspace creator: bind"))
+		ui.verboseLine(output.Linef("🚧", output.StyleSuccess, "Workspace creator: bind"))
 	case workspace.CreatorTypeVolume:
-		ui.pending.VerboseLine(output.Linef("🚧", output.StyleSuccess, "Workspace creator: volume"))
+		ui.verboseLine(output.Linef("🚧", output.StyleSuccess, "Workspace creator: volume"))
+	case workspace.CreatorTypeRemote:
+		ui.verboseLine(output.Linef("🚧", output.StyleSuccess, "Workspace creator: remote"))
 	}
 
-	batchCompletePending(ui.pending, "Set workspace type")
+	ui.completePending("Set workspace type")
 }
 
 func (ui *TUI) ResolvingRepositories() {
-	ui.pending = batchCreatePending(ui.Out, "Resolving repositories")
+	ui.startPending("Resolving repositories")
 }
 func (ui *TUI) ResolvingRepositoriesDone(repos []*graphql.Repository, unsupported batches.UnsupportedRepoSet, ignored batches.IgnoredRepoSet) {
-	batchCompletePending(ui.pending, fmt.Sprintf("Resolved %d repositories", len(repos)))
+	ui.completePending(fmt.Sprintf("Resolved %d repositories", len(repos)))
 
 	if unsupported != nil && len(unsupported) != 0 {
 		block := ui.Out.Block(output.Line(" ", output.StyleWarning, "Some repositories are hosted on unsupported code hosts and will be skipped. Use the -allow-unsupported flag to avoid skipping them."))
@@ -116,15 +127,15 @@ func (ui *TUI) ResolvingRepositoriesDone(repos []*graphql.Repository, unsupporte
 }
 
 func (ui *TUI) DeterminingWorkspaces() {
-	ui.pending = batchCreatePending(ui.Out, "Determining workspaces")
+	ui.startPending("Determining workspaces")
 }
 
 func (ui *TUI) DeterminingWorkspacesSuccess(num int) {
-	batchCompletePending(ui.pending, fmt.Sprintf("Found %d workspaces with steps to execute", num))
+	ui.completePending(fmt.Sprintf("Found %d workspaces with steps to execute", num))
 }
 
 func (ui *TUI) CheckingCache() {
-	ui.pending = batchCreatePending(ui.Out, "Checking cache for changeset specs")
+	ui.startPending("Checking cache for changeset specs")
 }
 
 func (ui *TUI) CheckingCacheSuccess(cachedSpecsFound int, uncachedTasks int) {
@@ -136,15 +147,18 @@ func (ui *TUI) CheckingCacheSuccess(cachedSpecsFound int, uncachedTasks int) {
 	}
 	switch uncachedTasks {
 	case 0:
-		batchCompletePending(ui.pending, fmt.Sprintf("%s; no tasks need to be executed", specsFoundMessage))
+		ui.completePending(fmt.Sprintf("%s; no tasks need to be executed", specsFoundMessage))
 	case 1:
-		batchCompletePending(ui.pending, fmt.Sprintf("%s; %d task needs to be executed", specsFoundMessage, uncachedTasks))
+		ui.completePending(fmt.Sprintf("%s; %d task needs to be executed", specsFoundMessage, uncachedTasks))
 	default:
-		batchCompletePending(ui.pending, fmt.Sprintf("%s; %d tasks need to be executed", specsFoundMessage, uncachedTasks))
+		ui.completePending(fmt.Sprintf("%s; %d tasks need to be executed", specsFoundMessage, uncachedTasks))
 	}
 }
 
 func (ui *TUI) ExecutingTasks(verbose bool, parallelism int) executor.TaskExecutionUI {
+	if ui.Plain {
+		return newTaskExecPlain(ui.Out, verbose)
+	}
 	ui.progressPrinter = newTaskExecTUI(ui.Out, verbose, parallelism)
 	return ui.progressPrinter
 }
@@ -163,6 +177,86 @@ func (ui *TUI) LogFilesKept(files []string) {
 	}
 }
 
+func (ui *TUI) ArchiveCacheStats(stats repozip.CacheStats) {
+	msg := fmt.Sprintf("Repository archives: %d cached, %d unchanged since cached, %d downloaded", stats.Hits, stats.NotModified, stats.Downloaded)
+	if stats.Resumed > 0 {
+		msg += fmt.Sprintf(" (%d resumed)", stats.Resumed)
+	}
+	if stats.BytesDownloaded > 0 {
+		msg += fmt.Sprintf(", %s transferred", humanize.Bytes(uint64(stats.BytesDownloaded)))
+	}
+	ui.Out.WriteLine(output.Line("", batchSuccessColor, msg))
+}
+
+func (ui *TUI) WorkspacesKept(workspaces []executor.KeptWorkspace) {
+	block := ui.Out.Block(output.Line("", batchSuccessColor, "Preserving workspaces:"))
+	defer block.Close()
+
+	for _, w := range workspaces {
+		name := w.Repository
+		if w.Path != "" {
+			name += "/" + w.Path
+		}
+		if w.Failed {
+			name += " (failed)"
+		}
+		block.Writef("%s: %s", name, w.Location)
+	}
+}
+
+func (ui *TUI) CheckingBaseBranches() {
+	ui.startPending("Checking base branches of changeset specs")
+}
+
+func (ui *TUI) CheckingBaseBranchesSuccess(stale, conflicting []string) {
+	if len(stale) == 0 {
+		ui.completePending("No base branches moved since execution")
+		return
+	}
+	ui.completePending(fmt.Sprintf("%d base branches moved since execution, %d of them conflict", len(stale), len(conflicting)))
+
+	isConflicting := make(map[string]bool, len(conflicting))
+	for _, name := range conflicting {
+		isConflicting[name] = true
+	}
+
+	block := ui.Out.Block(output.Line(output.EmojiWarning, output.StyleWarning, "Repositories whose base branch moved:"))
+	defer block.Close()
+	for _, name := range stale {
+		if isConflicting[name] {
+			block.Writef("%s (diff no longer applies)", name)
+		} else {
+			block.Write(name)
+		}
+	}
+}
+
+func (ui *TUI) CheckingBranchCollisions() {
+	ui.startPending("Checking branches of changeset specs")
+}
+
+func (ui *TUI) CheckingBranchCollisionsSuccess(collisions []string) {
+	if len(collisions) == 0 {
+		ui.completePending("No branches in use by others")
+		return
+	}
+	ui.completePending(fmt.Sprintf("%d branches are already in use", len(collisions)))
+
+	block := ui.Out.Block(output.Line(output.EmojiWarning, output.StyleWarning, "Changeset specs whose branch is already in use:"))
+	defer block.Close()
+	for _, c := range collisions {
+		block.Write(c)
+	}
+}
+
+func (ui *TUI) LargeChangesetSpecs(specs []string) {
+	block := ui.Out.Block(output.Line(output.EmojiWarning, output.StyleWarning, "Changeset specs that may be too large for the Sourcegraph instance to accept:"))
+	defer block.Close()
+	for _, s := range specs {
+		block.Write(s)
+	}
+}
+
 func (ui *TUI) NoChangesetSpecs() {
 	ui.Out.WriteLine(output.Linef(output.EmojiWarning, output.StyleWarning, `No changeset specs created`))
 }
@@ -175,25 +269,23 @@ func (ui *TUI) UploadingChangesetSpecs(num int) {
 		label = fmt.Sprintf("Sending %d changeset specs", num)
 	}
 
-	ui.progress = ui.Out.Progress([]output.ProgressBar{
-		{Label: label, Max: float64(num)},
-	}, nil)
+	ui.startProgress(label, float64(num))
 }
 
 func (ui *TUI) UploadingChangesetSpecsProgress(done, total int) {
-	ui.progress.SetValue(0, float64(done))
+	ui.setProgress(float64(done))
 }
 
 func (ui *TUI) UploadingChangesetSpecsSuccess(ids []graphql.ChangesetSpecID) {
-	ui.progress.Complete()
+	ui.completeProgress()
 }
 
 func (ui *TUI) CreatingBatchSpec() {
-	ui.pending = batchCreatePending(ui.Out, "Creating batch spec on Sourcegraph")
+	ui.startPending("Creating batch spec on Sourcegraph")
 }
 
 func (ui *TUI) CreatingBatchSpecSuccess(previewURL string) {
-	batchCompletePending(ui.pending, "Creating batch spec on Sourcegraph")
+	ui.completePending("Creating batch spec on Sourcegraph")
 }
 
 func (ui *TUI) CreatingBatchSpecError(err error) error {
@@ -210,11 +302,11 @@ func (ui *TUI) PreviewBatchSpec(batchSpecURL string) {
 }
 
 func (ui *TUI) ApplyingBatchSpec() {
-	ui.pending = batchCreatePending(ui.Out, "Applying batch spec")
+	ui.startPending("Applying batch spec")
 }
 
 func (ui *TUI) ApplyingBatchSpecSuccess(batchChangeURL string) {
-	batchCompletePending(ui.pending, "Applying batch spec")
+	ui.completePending("Applying batch spec")
 
 	ui.Out.Write("")
 	block := ui.Out.Block(output.Line(batchSuccessEmoji, batchSuccessColor, "Batch change applied!"))
@@ -280,10 +372,33 @@ func printExecutionError(out *output.Output, err error) {
 	if _, ok := err.(*cmderrors.ExitCodeError); ok {
 		return
 	}
+	// The kind of the error only matters for the exit code.
+	if kindErr, ok := err.(*cmderrors.KindError); ok {
+		err = kindErr.Unwrap()
+	}
 
 	out.Write("")
 
 	writeErrs := func(errs []error) {
+		// Failed tasks are grouped by the cause of their failure, so that
+		// the same error in many repositories is only shown once.
+		var taskErrs []executor.TaskExecutionErr
+		var otherErrs []error
+		for _, e := range errs {
+			if taskErr, ok := e.(executor.TaskExecutionErr); ok {
+				taskErrs = append(taskErrs, taskErr)
+			} else {
+				otherErrs = append(otherErrs, e)
+			}
+		}
+		if len(taskErrs) > 1 {
+			writeTaskErrorDigest(out, taskErrs)
+			if len(otherErrs) == 0 {
+				return
+			}
+			errs = otherErrs
+		}
+
 		var block *output.Block
 
 		if len(errs) > 1 {
@@ -348,6 +463,43 @@ func flattenErrs(err error) (result []error) {
 	return result
 }
 
+// taskErrorExcerptLines is the number of lines of output shown for each group
+// of failed tasks.
+const taskErrorExcerptLines = 8
+
+// writeTaskErrorDigest prints the failed tasks grouped by the cause of the
+// failure, with an excerpt of the output of one of them and the paths to the
+// logs of all of them.
+func writeTaskErrorDigest(out *output.Output, errs []executor.TaskExecutionErr) {
+	groups := executor.GroupTaskExecutionErrs(errs)
+
+	block := out.Block(output.Linef(output.EmojiFailure, output.StyleWarning, "%d repositories failed with %d different errors:", len(errs), len(groups)))
+	defer block.Close()
+
+	for i, g := range groups {
+		if i > 0 {
+			block.Write("")
+		}
+
+		repos := "repositories"
+		if len(g.Errs) == 1 {
+			repos = "repository"
+		}
+		block.Writef("%s%d %s failed: %s%s", output.StyleBold, len(g.Errs), repos, g.Signature, output.StyleReset)
+
+		first := g.Errs[0]
+		block.Writef("Output in %s:", first.Repository)
+		for _, line := range strings.Split(first.Excerpt(taskErrorExcerptLines), "\n") {
+			block.Writef("  %s", line)
+		}
+
+		block.Write("Logs:")
+		for _, err := range g.Errs {
+			block.Writef("  %s: %s", err.Repository, err.Logfile)
+		}
+	}
+}
+
 func formatTaskExecutionErr(err executor.TaskExecutionErr) string {
 	if ee, ok := errors.Cause(err).(*exec.ExitError); ok && ee.String() == "signal: killed" {
 		return fmt.Sprintf(
@@ -368,6 +520,61 @@ func formatTaskExecutionErr(err executor.TaskExecutionErr) string {
 	)
 }
 
+// startPending shows a spinner with the given message, or prints the message
+// if the output is plain.
+func (ui *TUI) startPending(message string) {
+	if ui.Plain {
+		ui.pending = nil
+		ui.Out.WriteLine(output.Line("", batchPendingColor, message))
+		return
+	}
+	ui.pending = batchCreatePending(ui.Out, message)
+}
+
+// completePending replaces the spinner started by startPending with the given
+// message, or prints the message if the output is plain.
+func (ui *TUI) completePending(message string) {
+	if ui.pending == nil {
+		ui.Out.WriteLine(output.Line(batchSuccessEmoji, batchSuccessColor, message))
+		return
+	}
+	batchCompletePending(ui.pending, message)
+}
+
+func (ui *TUI) verboseLine(line output.FancyLine) {
+	if ui.pending == nil {
+		ui.Out.VerboseLine(line)
+		return
+	}
+	ui.pending.VerboseLine(line)
+}
+
+// startProgress shows a progress bar with the given label, or prints the label
+// if the output is plain, where only the completion is reported.
+func (ui *TUI) startProgress(label string, max float64) {
+	ui.progressLabel = label
+	if ui.Plain {
+		ui.progress = nil
+		ui.Out.WriteLine(output.Line("", batchPendingColor, label))
+		return
+	}
+	ui.progress = ui.Out.Progress([]output.ProgressBar{{Label: label, Max: max}}, nil)
+}
+
+func (ui *TUI) setProgress(value float64) {
+	if ui.progress != nil {
+		ui.progress.SetValue(0, value)
+	}
+}
+
+func (ui *TUI) completeProgress() {
+	if ui.progress == nil {
+		ui.Out.WriteLine(output.Line(batchSuccessEmoji, batchSuccessColor, ui.progressLabel))
+		return
+	}
+	ui.progress.Complete()
+}
+
 func batchCreatePending(out *output.Output, message string) output.Pending {
 	return out.Pending(output.Line("", batchPendingColor, message))
 }
@@ -16,12 +16,15 @@ import (
 	"github.com/sourcegraph/sourcegraph/lib/batches/git"
 
 	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/reaper"
 	"github.com/sourcegraph/src-cli/internal/batches/repozip"
 	"github.com/sourcegraph/src-cli/internal/batches/util"
 )
 
 type dockerBindWorkspaceCreator struct {
 	Dir string
+
+	tracker *reaper.Tracker
 }
 
 var _ Creator = &dockerBindWorkspaceCreator{}
@@ -60,11 +63,12 @@ func (*dockerBindWorkspaceCreator) prepareGitRepo(ctx context.Context, w *docker
 func (wc *dockerBindWorkspaceCreator) unzipToWorkspace(ctx context.Context, repo *graphql.Repository, zip string) (*dockerBindWorkspace, error) {
 	prefix := "workspace-" + util.SlugForRepo(repo.Name, repo.Rev())
 	workspace, err := unzipToTempDir(ctx, zip, wc.Dir, prefix)
+	wc.tracker.TrackDir(workspace)
 	if err != nil {
 		return nil, errors.Wrap(err, "unzipping the ZIP archive")
 	}
 
-	return &dockerBindWorkspace{tempDir: wc.Dir, dir: workspace}, nil
+	return &dockerBindWorkspace{tempDir: wc.Dir, dir: workspace, tracker: wc.tracker}, nil
 }
 
 func (wc *dockerBindWorkspaceCreator) copyToWorkspace(ctx context.Context, w *dockerBindWorkspace, files map[string]string) error {
@@ -108,13 +112,25 @@ func (wc *dockerBindWorkspaceCreator) copyToWorkspace(ctx context.Context, w *do
 type dockerBindWorkspace struct {
 	tempDir string
 
-	dir string
+	dir     string
+	tracker *reaper.Tracker
 }
 
 var _ Workspace = &dockerBindWorkspace{}
 
 func (w *dockerBindWorkspace) Close(ctx context.Context) error {
-	return os.RemoveAll(w.dir)
+	if err := os.RemoveAll(w.dir); err != nil {
+		return err
+	}
+	w.tracker.UntrackDir(w.dir)
+	return nil
+}
+
+func (w *dockerBindWorkspace) Keep() string {
+	// Untracking the directory keeps it from being removed when the
+	// execution is interrupted or cleaned up.
+	w.tracker.UntrackDir(w.dir)
+	return w.dir
 }
 
 func (w *dockerBindWorkspace) DockerRunOpts(ctx context.Context, target string) ([]string, error) {
@@ -182,6 +198,27 @@ func (w *dockerBindWorkspace) ApplyDiff(ctx context.Context, diff []byte) error
 	return err
 }
 
+func (w *dockerBindWorkspace) Checkpoint(ctx context.Context) (string, error) {
+	if _, err := runGitCmd(ctx, w.dir, "add", "--all"); err != nil {
+		return "", errors.Wrap(err, "git add failed")
+	}
+
+	out, err := runGitCmd(ctx, w.dir, "write-tree")
+	if err != nil {
+		return "", errors.Wrap(err, "git write-tree failed")
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (w *dockerBindWorkspace) DiffSince(ctx context.Context, tree string) ([]byte, error) {
+	if _, err := runGitCmd(ctx, w.dir, "add", "--all"); err != nil {
+		return nil, errors.Wrap(err, "git add failed")
+	}
+
+	// See Diff for the options.
+	return runGitCmd(ctx, w.dir, "diff", "--cached", "--no-prefix", "--binary", tree)
+}
+
 func unzipToTempDir(ctx context.Context, zipFile, tempDir, tempFilePrefix string) (string, error) {
 	volumeDir, err := os.MkdirTemp(tempDir, tempFilePrefix)
 	if err != nil {
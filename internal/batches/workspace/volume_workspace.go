@@ -17,6 +17,7 @@ import (
 
 	"github.com/sourcegraph/src-cli/internal/batches/docker"
 	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/reaper"
 	"github.com/sourcegraph/src-cli/internal/batches/repozip"
 	"github.com/sourcegraph/src-cli/internal/exec"
 	"github.com/sourcegraph/src-cli/internal/version"
@@ -27,11 +28,21 @@ type imageEnsurer func(ctx context.Context, image string) (docker.Image, error)
 type dockerVolumeWorkspaceCreator struct {
 	tempDir     string
 	EnsureImage imageEnsurer
+	tracker     *reaper.Tracker
+	// remote is set if the Docker daemon is on another machine, so that the
+	// files on this machine are streamed into the containers instead of
+	// being bind mounted.
+	remote bool
 }
 
 var _ Creator = &dockerVolumeWorkspaceCreator{}
 
-func (wc *dockerVolumeWorkspaceCreator) Type() CreatorType { return CreatorTypeVolume }
+func (wc *dockerVolumeWorkspaceCreator) Type() CreatorType {
+	if wc.remote {
+		return CreatorTypeRemote
+	}
+	return CreatorTypeVolume
+}
 
 func (wc *dockerVolumeWorkspaceCreator) Create(ctx context.Context, repo *graphql.Repository, steps []batcheslib.Step, archive repozip.Archive) (Workspace, error) {
 	volume, err := wc.createVolume(ctx)
@@ -56,6 +67,8 @@ func (wc *dockerVolumeWorkspaceCreator) Create(ctx context.Context, repo *graphq
 		tempDir: wc.tempDir,
 		volume:  volume,
 		uidGid:  ug,
+		tracker: wc.tracker,
+		remote:  wc.remote,
 	}
 	if err := wc.unzipRepoIntoVolume(ctx, w, archive.Path()); err != nil {
 		return nil, errors.Wrap(err, "unzipping repo into workspace")
@@ -68,13 +81,15 @@ func (wc *dockerVolumeWorkspaceCreator) Create(ctx context.Context, repo *graphq
 	return w, errors.Wrap(wc.prepareGitRepo(ctx, w), "preparing local git repo")
 }
 
-func (*dockerVolumeWorkspaceCreator) createVolume(ctx context.Context) (string, error) {
+func (wc *dockerVolumeWorkspaceCreator) createVolume(ctx context.Context) (string, error) {
 	out, err := exec.CommandContext(ctx, "docker", "volume", "create").CombinedOutput()
 	if err != nil {
 		return "", err
 	}
 
-	return string(bytes.TrimSpace(out)), nil
+	volume := string(bytes.TrimSpace(out))
+	wc.tracker.TrackVolume(volume)
+	return volume, nil
 }
 
 func (*dockerVolumeWorkspaceCreator) prepareGitRepo(ctx context.Context, w *dockerVolumeWorkspace) error {
@@ -144,6 +159,10 @@ func (wc *dockerVolumeWorkspaceCreator) unzipRepoIntoVolume(ctx context.Context,
 		return errors.Wrapf(err, "chown output:\n\n%s\n\n", string(out))
 	}
 
+	if w.remote {
+		return wc.streamZipIntoVolume(ctx, w, zip, dummy)
+	}
+
 	// Now we can unzip the archive as the user and clean up the temporary file.
 	opts = append([]string{
 		"run",
@@ -166,10 +185,45 @@ func (wc *dockerVolumeWorkspaceCreator) unzipRepoIntoVolume(ctx context.Context,
 	return nil
 }
 
+// streamZipIntoVolume unzips the archive into the volume like
+// unzipRepoIntoVolume, but streams it to the container instead of mounting it,
+// for Docker daemons on other machines.
+func (wc *dockerVolumeWorkspaceCreator) streamZipIntoVolume(ctx context.Context, w *dockerVolumeWorkspace, zip, dummy string) error {
+	f, err := os.Open(zip)
+	if err != nil {
+		return errors.Wrap(err, "opening archive")
+	}
+	defer f.Close()
+
+	opts := append([]string{
+		"run",
+		"--rm",
+		"--init",
+		"--interactive",
+		"--workdir", "/work",
+	}, w.dockerRunOptsWithUser(w.uidGid, "/work")...)
+	opts = append(
+		opts,
+		DockerVolumeWorkspaceImage,
+		"sh", "-c",
+		fmt.Sprintf("cat > /tmp/zip && unzip /tmp/zip; rm /work/%s", dummy),
+	)
+
+	cmd := exec.CommandContext(ctx, "docker", opts...)
+	cmd.Stdin = f
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "unzip output:\n\n%s\n\n", string(out))
+	}
+	return nil
+}
+
 func (wc *dockerVolumeWorkspaceCreator) copyFilesIntoVolumes(ctx context.Context, w *dockerVolumeWorkspace, files map[string]string) error {
 	if len(files) == 0 {
 		return nil
 	}
+	if w.remote {
+		return wc.streamFilesIntoVolume(ctx, w, files)
+	}
 
 	opts := append([]string{
 		"run",
@@ -208,6 +262,32 @@ func (wc *dockerVolumeWorkspaceCreator) copyFilesIntoVolumes(ctx context.Context
 	return nil
 }
 
+// streamFilesIntoVolume copies the files into the volume like
+// copyFilesIntoVolumes, but streams them to the container as a tar archive
+// instead of mounting them, for Docker daemons on other machines.
+func (wc *dockerVolumeWorkspaceCreator) streamFilesIntoVolume(ctx context.Context, w *dockerVolumeWorkspace, files map[string]string) error {
+	archive, err := docker.TarFiles(files)
+	if err != nil {
+		return err
+	}
+
+	opts := append([]string{
+		"run",
+		"--rm",
+		"--init",
+		"--interactive",
+		"--workdir", "/work",
+	}, w.dockerRunOptsWithUser(w.uidGid, "/work")...)
+	opts = append(opts, DockerVolumeWorkspaceImage, "tar", "-x", "-f", "-", "-C", "/work")
+
+	cmd := exec.CommandContext(ctx, "docker", opts...)
+	cmd.Stdin = archive
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "tar output:\n\n%s\n\n", string(out))
+	}
+	return nil
+}
+
 // dockerVolumeWorkspace workspaces are placed on Docker volumes (surprise!),
 // and are therefore transparent to the host filesystem. This has performance
 // advantages if bind mounts are slow, such as on Docker for Mac, but could make
@@ -215,14 +295,29 @@ func (wc *dockerVolumeWorkspaceCreator) copyFilesIntoVolumes(ctx context.Context
 type dockerVolumeWorkspace struct {
 	tempDir string
 	volume  string
+	tracker *reaper.Tracker
 	uidGid  docker.UIDGID
+	// remote is set if the volume is on a Docker daemon on another machine.
+	remote bool
 }
 
 var _ Workspace = &dockerVolumeWorkspace{}
 
 func (w *dockerVolumeWorkspace) Close(ctx context.Context) error {
 	// Cleanup here is easy: we just get rid of the Docker volume.
-	return exec.CommandContext(ctx, "docker", "volume", "rm", w.volume).Run()
+	if err := exec.CommandContext(ctx, "docker", "volume", "rm", w.volume).Run(); err != nil {
+		return err
+	}
+	w.tracker.UntrackVolume(w.volume)
+	return nil
+}
+
+func (w *dockerVolumeWorkspace) Keep() string {
+	w.tracker.UntrackVolume(w.volume)
+	if w.remote {
+		return "Docker volume " + w.volume + " on the remote Docker host"
+	}
+	return "Docker volume " + w.volume
 }
 
 func (w *dockerVolumeWorkspace) DockerRunOpts(ctx context.Context, target string) ([]string, error) {
@@ -294,6 +389,37 @@ git add --all > /dev/null
 	return nil
 }
 
+func (w *dockerVolumeWorkspace) Checkpoint(ctx context.Context) (string, error) {
+	script := `#!/bin/sh
+
+git add --all > /dev/null
+exec git write-tree
+`
+
+	out, err := w.runScript(ctx, "/work", script)
+	if err != nil {
+		return "", errors.Wrapf(err, "git write-tree:\n\n%s", string(out))
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (w *dockerVolumeWorkspace) DiffSince(ctx context.Context, tree string) ([]byte, error) {
+	// See Diff for the options.
+	script := fmt.Sprintf(`#!/bin/sh
+
+git add --all > /dev/null
+exec git diff --cached --no-prefix --binary %s
+`, tree)
+
+	out, err := w.runScript(ctx, "/work", script)
+	if err != nil {
+		return nil, errors.Wrapf(err, "git diff:\n\n%s", string(out))
+	}
+
+	return out, nil
+}
+
 // DockerVolumeWorkspaceImage is the Docker image we'll run our unzip and git
 // commands in. This needs to match the name defined in
 // .github/workflows/docker.yml.
@@ -312,6 +438,10 @@ func init() {
 // container started from the dockerWorkspaceImage, then run it and return the
 // output.
 func (w *dockerVolumeWorkspace) runScript(ctx context.Context, target, script string) ([]byte, error) {
+	if w.remote {
+		return w.streamScript(ctx, target, script)
+	}
+
 	f, err := os.CreateTemp(w.tempDir, "src-run-*")
 	if err != nil {
 		return nil, errors.Wrap(err, "creating run script")
@@ -350,6 +480,28 @@ func (w *dockerVolumeWorkspace) runScript(ctx context.Context, target, script st
 	return out, nil
 }
 
+// streamScript runs the script like runScript, but streams it to the shell in
+// the container instead of mounting it, for Docker daemons on other machines.
+func (w *dockerVolumeWorkspace) streamScript(ctx context.Context, target, script string) ([]byte, error) {
+	opts := append([]string{
+		"run",
+		"--rm",
+		"--init",
+		"--interactive",
+		"--workdir", target,
+	}, w.dockerRunOptsWithUser(w.uidGid, target)...)
+	opts = append(opts, DockerVolumeWorkspaceImage, "sh", "-s")
+
+	cmd := exec.CommandContext(ctx, "docker", opts...)
+	cmd.Stdin = strings.NewReader(script)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return out, errors.Wrapf(err, "Docker output:\n\n%s\n\n", string(out))
+	}
+
+	return out, nil
+}
+
 func (w *dockerVolumeWorkspace) dockerRunOptsWithUser(ug docker.UIDGID, target string) []string {
 	return []string{
 		"--user", ug.String(),
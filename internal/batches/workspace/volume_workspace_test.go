@@ -660,3 +660,84 @@ func TestVolumeWorkspace_runScript(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestVolumeWorkspaceCreator_Remote(t *testing.T) {
+	ctx := context.Background()
+
+	f, err := os.CreateTemp(os.TempDir(), "volume-workspace-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	additional := filepath.Join(t.TempDir(), "gitignore")
+	if err := os.WriteFile(additional, []byte("node_modules\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	archive := &fakeRepoArchive{
+		mockPath:                f.Name(),
+		mockAdditionalFilePaths: map[string]string{".gitignore": additional},
+	}
+
+	// Nothing is bind mounted on a remote Docker host: the archive, the
+	// additional files and the scripts are all streamed to the containers.
+	expect.Commands(
+		t,
+		expect.NewGlob(
+			expect.Behaviour{Stdout: []byte(volumeID)},
+			"docker", "volume", "create",
+		),
+		expect.NewGlob(
+			expect.Success,
+			"docker", "run", "--rm", "--init", "--workdir", "/work",
+			"--user", "0:0",
+			"--mount", "type=volume,source="+volumeID+",target=/work",
+			DockerVolumeWorkspaceImage,
+			"sh", "-c", "touch /work/*; chown -R 1:2 /work",
+		),
+		expect.NewGlob(
+			expect.Success,
+			"docker", "run", "--rm", "--init", "--interactive", "--workdir", "/work",
+			"--user", "1:2",
+			"--mount", "type=volume,source="+volumeID+",target=/work",
+			DockerVolumeWorkspaceImage,
+			"sh", "-c", "cat > /tmp/zip && unzip /tmp/zip; rm /work/*",
+		),
+		expect.NewGlob(
+			expect.Success,
+			"docker", "run", "--rm", "--init", "--interactive", "--workdir", "/work",
+			"--user", "1:2",
+			"--mount", "type=volume,source="+volumeID+",target=/work",
+			DockerVolumeWorkspaceImage,
+			"tar", "-x", "-f", "-", "-C", "/work",
+		),
+		expect.NewGlob(
+			expect.Success,
+			"docker", "run", "--rm", "--init", "--interactive", "--workdir", "/work",
+			"--user", "1:2",
+			"--mount", "type=volume,source="+volumeID+",target=/work",
+			DockerVolumeWorkspaceImage,
+			"sh", "-s",
+		),
+	)
+
+	wc := &dockerVolumeWorkspaceCreator{
+		EnsureImage: func(_ context.Context, _ string) (docker.Image, error) {
+			return &mock.Image{UidGid: docker.UIDGID{UID: 1, GID: 2}}, nil
+		},
+		remote: true,
+	}
+	if have, want := wc.Type(), CreatorTypeRemote; have != want {
+		t.Errorf("unexpected type: have=%v want=%v", have, want)
+	}
+
+	repo := &graphql.Repository{DefaultBranch: &graphql.Branch{Name: "main"}}
+	w, err := wc.Create(ctx, repo, []batcheslib.Step{{}}, archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !w.(*dockerVolumeWorkspace).remote {
+		t.Error("workspace of remote creator isn't remote")
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/sourcegraph/src-cli/internal/batches/docker"
 	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/reaper"
 	"github.com/sourcegraph/src-cli/internal/batches/repozip"
 )
 
@@ -42,6 +43,10 @@ type Workspace interface {
 	// delete the workspace when Close is called.
 	Close(ctx context.Context) error
 
+	// Keep is called instead of Close to retain the workspace after the
+	// execution for inspection. It returns where the workspace can be found.
+	Keep() string
+
 	// Changes is called after each step is executed, and should return the
 	// cumulative file changes that have occurred since Prepare was called.
 	Changes(ctx context.Context) (*git.Changes, error)
@@ -52,6 +57,14 @@ type Workspace interface {
 
 	// ApplyDiff applies the given diff
 	ApplyDiff(ctx context.Context, diff []byte) error
+
+	// Checkpoint records the current state of the workspace and returns the
+	// ID of the Git tree it's stored in, to be passed to DiffSince.
+	Checkpoint(ctx context.Context) (string, error)
+
+	// DiffSince returns the diff between the given Checkpoint and the
+	// current state of the workspace.
+	DiffSince(ctx context.Context, tree string) ([]byte, error)
 }
 
 type CreatorType int
@@ -59,14 +72,22 @@ type CreatorType int
 const (
 	CreatorTypeBind CreatorType = iota
 	CreatorTypeVolume
+	// CreatorTypeRemote creates volume workspaces on a Docker daemon on
+	// another machine, into which the repository archives and the files of
+	// steps are streamed instead of being bind mounted.
+	CreatorTypeRemote
 )
 
-func NewCreator(ctx context.Context, preference, cacheDir, tempDir string, images map[string]docker.Image) Creator {
+// NewCreator returns the Creator for the preferred workspace type. The
+// workspaces it creates are recorded by tracker, which may be nil.
+func NewCreator(ctx context.Context, preference, cacheDir, tempDir string, images map[string]docker.Image, tracker *reaper.Tracker) Creator {
 	var workspaceType CreatorType
 	if preference == "volume" {
 		workspaceType = CreatorTypeVolume
 	} else if preference == "bind" {
 		workspaceType = CreatorTypeBind
+	} else if preference == "remote" || docker.RemoteHost(ctx) != "" {
+		workspaceType = CreatorTypeRemote
 	} else {
 		workspaceType = BestCreatorType(ctx, images)
 	}
@@ -78,10 +99,13 @@ func NewCreator(ctx context.Context, preference, cacheDir, tempDir string, image
 		}
 		return img, nil
 	}
-	if workspaceType == CreatorTypeVolume {
-		return &dockerVolumeWorkspaceCreator{tempDir: tempDir, EnsureImage: ensureImage}
+	switch workspaceType {
+	case CreatorTypeVolume:
+		return &dockerVolumeWorkspaceCreator{tempDir: tempDir, EnsureImage: ensureImage, tracker: tracker}
+	case CreatorTypeRemote:
+		return &dockerVolumeWorkspaceCreator{tempDir: tempDir, EnsureImage: ensureImage, tracker: tracker, remote: true}
 	}
-	return &dockerBindWorkspaceCreator{Dir: cacheDir}
+	return &dockerBindWorkspaceCreator{Dir: cacheDir, tracker: tracker}
 }
 
 // BestCreatorType determines the correct workspace creator type to use based
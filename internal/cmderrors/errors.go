@@ -29,11 +29,17 @@ func ExitCode(code int, err error) *ExitCodeError {
 type ExitCodeError struct {
 	error
 	exitCode int
+	reported bool
 }
 
 func (e *ExitCodeError) HasError() bool { return e.error != nil }
 func (e *ExitCodeError) Code() int      { return e.exitCode }
 
+// Reported returns true if the error was already shown to the user.
+func (e *ExitCodeError) Reported() bool { return e.reported }
+
+func (e *ExitCodeError) Unwrap() error { return e.error }
+
 func (e *ExitCodeError) Error() string {
 	if e.error != nil {
 		return fmt.Sprintf("%s (exit code: %d)", e.error, e.exitCode)
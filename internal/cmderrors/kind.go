@@ -0,0 +1,210 @@
+package cmderrors
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/url"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Kind is the kind of failure that made a command fail. Kinds and their exit
+// codes are part of the interface of src, so that scripts wrapping it can
+// branch on them, and must not change.
+type Kind string
+
+const (
+	// KindFailure is any failure that has no more specific kind.
+	KindFailure Kind = "failure"
+	// KindUsage is an invalid invocation, such as an unknown flag.
+	KindUsage Kind = "usage"
+	// KindGraphQL is an error returned by the GraphQL API.
+	KindGraphQL Kind = "graphql"
+	// KindAuth is a missing or rejected access token.
+	KindAuth Kind = "auth"
+	// KindValidation is invalid input, such as a batch spec that fails
+	// validation.
+	KindValidation Kind = "validation"
+	// KindExecution is a failure to execute the steps of a batch spec.
+	KindExecution Kind = "execution"
+	// KindPartialFailure is an operation that failed for some of the items it
+	// works on and succeeded for the others.
+	KindPartialFailure Kind = "partial_failure"
+	// KindNetwork is a failure to reach Sourcegraph.
+	KindNetwork Kind = "network"
+	// KindIncompatible is a Sourcegraph instance that doesn't support what's
+	// asked of it.
+	KindIncompatible Kind = "incompatible"
+	// KindInterrupted is a command interrupted by a signal.
+	KindInterrupted Kind = "interrupted"
+	// KindNotConfirmed is a command that did nothing because its changes
+	// weren't confirmed, such as 'src batch apply -confirm'.
+	KindNotConfirmed Kind = "not_confirmed"
+)
+
+// The exit codes of the kinds of failures.
+const (
+	FailureExitCode        = 1
+	UsageExitCode          = 2
+	AuthExitCode           = 3
+	ValidationExitCode     = 4
+	ExecutionExitCode      = 5
+	PartialFailureExitCode = 6
+	NetworkExitCode        = 7
+	IncompatibleExitCode   = 8
+	NotConfirmedExitCode   = 9
+	InterruptedExitCode    = 130
+)
+
+var kindExitCodes = map[Kind]int{
+	KindFailure:        FailureExitCode,
+	KindUsage:          UsageExitCode,
+	KindGraphQL:        GraphqlErrorsExitCode,
+	KindAuth:           AuthExitCode,
+	KindValidation:     ValidationExitCode,
+	KindExecution:      ExecutionExitCode,
+	KindPartialFailure: PartialFailureExitCode,
+	KindNetwork:        NetworkExitCode,
+	KindIncompatible:   IncompatibleExitCode,
+	KindNotConfirmed:   NotConfirmedExitCode,
+	KindInterrupted:    InterruptedExitCode,
+}
+
+// ExitCode returns the exit code of the kind.
+func (k Kind) ExitCode() int {
+	if code, ok := kindExitCodes[k]; ok {
+		return code
+	}
+	return FailureExitCode
+}
+
+// KindError is an error marked with the kind of failure it is.
+type KindError struct {
+	error
+	kind Kind
+}
+
+// WithKind marks err as the given kind of failure. It returns nil if err is
+// nil.
+func WithKind(err error, kind Kind) error {
+	if err == nil {
+		return nil
+	}
+	return &KindError{error: err, kind: kind}
+}
+
+func (e *KindError) Kind() Kind    { return e.kind }
+func (e *KindError) Unwrap() error { return e.error }
+
+// Kinder is implemented by errors that know which kind of failure they are.
+type Kinder interface {
+	Kind() Kind
+}
+
+// Classify returns the kind of failure of err. Errors marked with WithKind,
+// or otherwise implementing Kinder, have that kind, the others are classified
+// by their type.
+func Classify(err error) Kind {
+	var kinder Kinder
+	if errors.As(err, &kinder) {
+		return kinder.Kind()
+	}
+
+	var usageErr *UsageError
+	if errors.As(err, &usageErr) {
+		return KindUsage
+	}
+
+	var exitErr *ExitCodeError
+	if errors.As(err, &exitErr) {
+		if exitErr.error != nil {
+			if kind := Classify(exitErr.error); kind != KindFailure {
+				return kind
+			}
+		}
+		for kind, code := range kindExitCodes {
+			// The graphql kind shares its code with the usage kind, which
+			// is reported as a UsageError instead.
+			if code == exitErr.exitCode && kind != KindUsage {
+				return kind
+			}
+		}
+		return KindFailure
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return KindInterrupted
+	}
+
+	var netErr net.Error
+	var urlErr *url.Error
+	if errors.As(err, &netErr) || errors.As(err, &urlErr) {
+		return KindNetwork
+	}
+
+	return KindFailure
+}
+
+// ExitCodeOf returns the exit code src exits with when a command fails with
+// err.
+func ExitCodeOf(err error) int {
+	var exitErr *ExitCodeError
+	if errors.As(err, &exitErr) {
+		return exitErr.exitCode
+	}
+	return Classify(err).ExitCode()
+}
+
+// Reported returns an ExitCodeError for err, which was already shown to the
+// user, with the exit code of its kind. Unlike other ExitCodeErrors, the error
+// isn't printed again, but it is part of the output of -error-json.
+func Reported(err error) *ExitCodeError {
+	var exitErr *ExitCodeError
+	if errors.As(err, &exitErr) {
+		return &ExitCodeError{error: exitErr.error, exitCode: exitErr.exitCode, reported: true}
+	}
+	return &ExitCodeError{error: err, exitCode: ExitCodeOf(err), reported: true}
+}
+
+// JSONError is the representation of an error written by WriteJSON.
+type JSONError struct {
+	Kind     Kind     `json:"kind"`
+	ExitCode int      `json:"exitCode"`
+	Message  string   `json:"message"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// WriteJSON writes err as a JSONError on a single line to w. If err combines
+// multiple errors, their messages are listed in Errors.
+func WriteJSON(w io.Writer, err error) error {
+	je := JSONError{
+		Kind:     Classify(err),
+		ExitCode: ExitCodeOf(err),
+	}
+
+	// The message of an ExitCodeError contains the exit code, which is a
+	// field of its own here.
+	var exitErr *ExitCodeError
+	if errors.As(err, &exitErr) {
+		err = exitErr.error
+	}
+	if err != nil {
+		je.Message = err.Error()
+
+		var multi interface{ WrappedErrors() []error }
+		if errors.As(err, &multi) {
+			for _, e := range multi.WrappedErrors() {
+				je.Errors = append(je.Errors, e.Error())
+			}
+		}
+	}
+
+	data, err := json.Marshal(je)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
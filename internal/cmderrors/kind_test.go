@@ -0,0 +1,97 @@
+package cmderrors
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/hashicorp/go-multierror"
+)
+
+func TestClassify(t *testing.T) {
+	for name, tc := range map[string]struct {
+		err      error
+		kind     Kind
+		exitCode int
+	}{
+		"plain error": {
+			err:      errors.New("boom"),
+			kind:     KindFailure,
+			exitCode: 1,
+		},
+		"usage": {
+			err:      Usage("no file given"),
+			kind:     KindUsage,
+			exitCode: 2,
+		},
+		"marked": {
+			err:      errors.Wrap(WithKind(errors.New("401 Unauthorized"), KindAuth), "querying"),
+			kind:     KindAuth,
+			exitCode: 3,
+		},
+		"exit code": {
+			err:      ExitCode(GraphqlErrorsExitCode, nil),
+			kind:     KindGraphQL,
+			exitCode: 2,
+		},
+		"exit code of marked error": {
+			err:      ExitCode(1, WithKind(errors.New("partial"), KindPartialFailure)),
+			kind:     KindPartialFailure,
+			exitCode: 1,
+		},
+		"reported": {
+			err:      Reported(WithKind(errors.New("step failed"), KindExecution)),
+			kind:     KindExecution,
+			exitCode: 5,
+		},
+		"not confirmed": {
+			err:      WithKind(errors.New("nothing was applied"), KindNotConfirmed),
+			kind:     KindNotConfirmed,
+			exitCode: 9,
+		},
+		"network": {
+			err:      &url.Error{Op: "Post", URL: "https://sourcegraph.test", Err: errors.New("connection refused")},
+			kind:     KindNetwork,
+			exitCode: 7,
+		},
+		"interrupted": {
+			err:      &url.Error{Op: "Post", URL: "https://sourcegraph.test", Err: context.Canceled},
+			kind:     KindInterrupted,
+			exitCode: 130,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if have := Classify(tc.err); have != tc.kind {
+				t.Errorf("wrong kind: want %q, have %q", tc.kind, have)
+			}
+			if have := ExitCodeOf(tc.err); have != tc.exitCode {
+				t.Errorf("wrong exit code: want %d, have %d", tc.exitCode, have)
+			}
+		})
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var errs *multierror.Error
+	errs = multierror.Append(errs, errors.New("repo-1 failed"), errors.New("repo-2 failed"))
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, Reported(WithKind(errs, KindExecution))); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"kind":"execution","exitCode":5,"message":"2 errors occurred:\n\t* repo-1 failed\n\t* repo-2 failed\n\n","errors":["repo-1 failed","repo-2 failed"]}` + "\n"
+	if have := buf.String(); have != want {
+		t.Errorf("wrong JSON:\nwant %s\nhave %s", want, have)
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, ExitCode1); err != nil {
+		t.Fatal(err)
+	}
+	want = `{"kind":"failure","exitCode":1,"message":""}` + "\n"
+	if have := buf.String(); have != want {
+		t.Errorf("wrong JSON:\nwant %s\nhave %s", want, have)
+	}
+}
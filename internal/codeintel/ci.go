@@ -0,0 +1,139 @@
+package codeintel
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ciProvider describes where a CI provider exposes the commit and repository
+// of a build in the environment.
+type ciProvider struct {
+	// detect is an environment variable that is set in builds of the
+	// provider.
+	detect string
+	// commit is the environment variable holding the commit hash.
+	commit string
+	// repo returns the repository URL or name, if the provider exposes it.
+	repo func(getenv func(string) string) string
+}
+
+func envVar(name string) func(func(string) string) string {
+	return func(getenv func(string) string) string { return getenv(name) }
+}
+
+var ciProviders = map[string]ciProvider{
+	"azure": {
+		detect: "TF_BUILD",
+		commit: "BUILD_SOURCEVERSION",
+		repo:   envVar("BUILD_REPOSITORY_URI"),
+	},
+	"bitbucket": {
+		detect: "BITBUCKET_BUILD_NUMBER",
+		commit: "BITBUCKET_COMMIT",
+		repo:   envVar("BITBUCKET_GIT_HTTP_ORIGIN"),
+	},
+	"buildkite": {
+		detect: "BUILDKITE",
+		commit: "BUILDKITE_COMMIT",
+		repo:   envVar("BUILDKITE_REPO"),
+	},
+	"circleci": {
+		detect: "CIRCLECI",
+		commit: "CIRCLE_SHA1",
+		repo:   envVar("CIRCLE_REPOSITORY_URL"),
+	},
+	"github": {
+		detect: "GITHUB_ACTIONS",
+		commit: "GITHUB_SHA",
+		repo: func(getenv func(string) string) string {
+			server, repo := getenv("GITHUB_SERVER_URL"), getenv("GITHUB_REPOSITORY")
+			if server == "" || repo == "" {
+				return ""
+			}
+			return strings.TrimSuffix(server, "/") + "/" + repo
+		},
+	},
+	"gitlab": {
+		detect: "GITLAB_CI",
+		commit: "CI_COMMIT_SHA",
+		repo:   envVar("CI_PROJECT_URL"),
+	},
+	"jenkins": {
+		detect: "JENKINS_URL",
+		commit: "GIT_COMMIT",
+		repo:   envVar("GIT_URL"),
+	},
+	"travis": {
+		detect: "TRAVIS",
+		commit: "TRAVIS_COMMIT",
+		repo: func(getenv func(string) string) string {
+			if slug := getenv("TRAVIS_REPO_SLUG"); slug != "" {
+				return "github.com/" + slug
+			}
+			return ""
+		},
+	},
+}
+
+// CIProviderNames returns the names of the CI providers that are supported by
+// CommitFromEnv and RepoFromEnv.
+func CIProviderNames() []string {
+	names := make([]string, 0, len(ciProviders))
+	for name := range ciProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupCIProvider returns the CI provider with the given name. If name is
+// "auto", the provider is detected from the environment.
+func lookupCIProvider(name string, getenv func(string) string) (string, ciProvider, error) {
+	if name == "auto" {
+		for _, n := range CIProviderNames() {
+			if p := ciProviders[n]; getenv(p.detect) != "" {
+				return n, p, nil
+			}
+		}
+		return "", ciProvider{}, fmt.Errorf("no supported CI provider detected, expected one of %s", strings.Join(CIProviderNames(), ", "))
+	}
+
+	p, ok := ciProviders[name]
+	if !ok {
+		return "", ciProvider{}, fmt.Errorf("unknown CI provider %q, expected auto or one of %s", name, strings.Join(CIProviderNames(), ", "))
+	}
+	return name, p, nil
+}
+
+// CommitFromEnv returns the commit of the current build of the given CI
+// provider, as set in the environment. If provider is "auto", the provider is
+// detected from the environment.
+func CommitFromEnv(provider string, getenv func(string) string) (string, error) {
+	name, p, err := lookupCIProvider(provider, getenv)
+	if err != nil {
+		return "", err
+	}
+	commit := getenv(p.commit)
+	if commit == "" {
+		return "", fmt.Errorf("%s is not set, are you running in a %s build?", p.commit, name)
+	}
+	return commit, nil
+}
+
+// RepoFromEnv returns the Sourcegraph-friendly name of the repository of the
+// current build of the given CI provider, as set in the environment.
+func RepoFromEnv(provider string, getenv func(string) string) (string, error) {
+	name, p, err := lookupCIProvider(provider, getenv)
+	if err != nil {
+		return "", err
+	}
+	repo := p.repo(getenv)
+	if repo == "" {
+		return "", fmt.Errorf("the repository of the %s build is not set in the environment", name)
+	}
+	if strings.Contains(repo, "://") || strings.Contains(repo, "@") {
+		return parseRemote(repo)
+	}
+	return repo, nil
+}
@@ -0,0 +1,93 @@
+package codeintel
+
+import "testing"
+
+func TestCommitAndRepoFromEnv(t *testing.T) {
+	for name, tc := range map[string]struct {
+		provider   string
+		env        map[string]string
+		wantCommit string
+		wantRepo   string
+		wantErr    bool
+	}{
+		"github": {
+			provider: "github",
+			env: map[string]string{
+				"GITHUB_SHA":        "deadbeef",
+				"GITHUB_SERVER_URL": "https://github.com",
+				"GITHUB_REPOSITORY": "sourcegraph/src-cli",
+			},
+			wantCommit: "deadbeef",
+			wantRepo:   "github.com/sourcegraph/src-cli",
+		},
+		"gitlab detected": {
+			provider: "auto",
+			env: map[string]string{
+				"GITLAB_CI":      "true",
+				"CI_COMMIT_SHA":  "cafebabe",
+				"CI_PROJECT_URL": "https://gitlab.example.com/group/project",
+			},
+			wantCommit: "cafebabe",
+			wantRepo:   "gitlab.example.com/group/project",
+		},
+		"buildkite ssh repo": {
+			provider: "buildkite",
+			env: map[string]string{
+				"BUILDKITE_COMMIT": "abc123",
+				"BUILDKITE_REPO":   "git@github.com:sourcegraph/sourcegraph.git",
+			},
+			wantCommit: "abc123",
+			wantRepo:   "github.com/sourcegraph/sourcegraph",
+		},
+		"travis": {
+			provider: "travis",
+			env: map[string]string{
+				"TRAVIS_COMMIT":    "abc123",
+				"TRAVIS_REPO_SLUG": "sourcegraph/src-cli",
+			},
+			wantCommit: "abc123",
+			wantRepo:   "github.com/sourcegraph/src-cli",
+		},
+		"nothing detected": {
+			provider: "auto",
+			env:      map[string]string{},
+			wantErr:  true,
+		},
+		"unknown provider": {
+			provider: "teamcity",
+			env:      map[string]string{},
+			wantErr:  true,
+		},
+		"commit missing": {
+			provider: "circleci",
+			env:      map[string]string{"CIRCLECI": "true"},
+			wantErr:  true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			getenv := func(k string) string { return tc.env[k] }
+
+			commit, err := CommitFromEnv(tc.provider, getenv)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("unexpected nil error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if commit != tc.wantCommit {
+				t.Errorf("wrong commit: have=%q want=%q", commit, tc.wantCommit)
+			}
+
+			repo, err := RepoFromEnv(tc.provider, getenv)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if repo != tc.wantRepo {
+				t.Errorf("wrong repo: have=%q want=%q", repo, tc.wantRepo)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package codeintel
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+// Index is an index file found by DiscoverIndexes.
+type Index struct {
+	// File is the path of the index file.
+	File string
+	// Root is the directory of the index file relative to the repository
+	// root, which is the root of the project it indexes.
+	Root string
+}
+
+// IsRootPattern returns true if root is a glob pattern rather than a single
+// directory.
+func IsRootPattern(root string) bool {
+	return strings.ContainsAny(root, "*?[{")
+}
+
+// DiscoverIndexes finds the index files named fileName below repoRoot whose
+// directory, relative to repoRoot, matches the given glob pattern. The
+// pattern uses slashes as separators; * doesn't match slashes but ** does.
+// Hidden directories and node_modules are skipped.
+func DiscoverIndexes(repoRoot, fileName, rootPattern string) ([]Index, error) {
+	g, err := glob.Compile(strings.TrimSuffix(rootPattern, "/"), '/')
+	if err != nil {
+		return nil, err
+	}
+
+	var indexes []Index
+	err = filepath.WalkDir(repoRoot, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != repoRoot && (strings.HasPrefix(d.Name(), ".") || d.Name() == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() != fileName {
+			return nil
+		}
+
+		rel, err := filepath.Rel(repoRoot, filepath.Dir(p))
+		if err != nil {
+			return err
+		}
+		root := SanitizeRoot(filepath.ToSlash(rel))
+		if !g.Match(root) {
+			return nil
+		}
+
+		indexes = append(indexes, Index{File: p, Root: root})
+		return nil
+	})
+	return indexes, err
+}
+
+// InferTopLevel returns the root directory of the git clone enclosing the
+// working dir.
+func InferTopLevel() (string, error) {
+	return runGitCommand("rev-parse", "--show-toplevel")
+}
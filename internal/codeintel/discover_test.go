@@ -0,0 +1,72 @@
+package codeintel
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiscoverIndexes(t *testing.T) {
+	repo := t.TempDir()
+	for _, p := range []string{
+		"dump.lsif",
+		"cmd/a/dump.lsif",
+		"cmd/b/dump.lsif",
+		"cmd/b/nested/dump.lsif",
+		"lib/dump.lsif",
+		"lib/other.lsif",
+		".cache/dump.lsif",
+		"web/node_modules/x/dump.lsif",
+	} {
+		p = filepath.Join(repo, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for pattern, want := range map[string][]string{
+		"cmd/*":       {"cmd/a", "cmd/b"},
+		"cmd/*/":      {"cmd/a", "cmd/b"},
+		"cmd/**":      {"cmd/a", "cmd/b", "cmd/b/nested"},
+		"**":          {"cmd/a", "cmd/b", "cmd/b/nested", "", "lib"},
+		"{lib,cmd/a}": {"cmd/a", "lib"},
+	} {
+		t.Run(pattern, func(t *testing.T) {
+			indexes, err := DiscoverIndexes(repo, "dump.lsif", pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var have []string
+			for _, index := range indexes {
+				have = append(have, index.Root)
+				if want := filepath.Join(repo, filepath.FromSlash(index.Root), "dump.lsif"); index.File != want {
+					t.Errorf("wrong file for root %q: have=%q want=%q", index.Root, index.File, want)
+				}
+			}
+			if diff := cmp.Diff(want, have); diff != "" {
+				t.Errorf("wrong roots (-want +have):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestIsRootPattern(t *testing.T) {
+	for root, want := range map[string]bool{
+		"":         false,
+		"cmd/a":    false,
+		"cmd/*":    true,
+		"**":       true,
+		"{a,b}":    true,
+		"cmd/[ab]": true,
+	} {
+		if have := IsRootPattern(root); have != want {
+			t.Errorf("wrong result for %q: have=%v want=%v", root, have, want)
+		}
+	}
+}
@@ -1,36 +1,49 @@
 package codeintel
 
 import (
+	"bytes"
 	"fmt"
 	"net/url"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 )
 
 // InferRepo gets a Sourcegraph-friendly repo name from the git clone enclosing the working dir.
+// The origin remote is used if it exists, otherwise the only remote of the clone.
 func InferRepo() (string, error) {
 	remoteURL, err := runGitCommand("remote", "get-url", "origin")
 	if err != nil {
-		return "", err
+		remotes, listErr := runGitCommand("remote")
+		if listErr != nil || len(strings.Fields(remotes)) != 1 {
+			return "", err
+		}
+		if remoteURL, err = runGitCommand("remote", "get-url", strings.TrimSpace(remotes)); err != nil {
+			return "", err
+		}
 	}
 
 	return parseRemote(remoteURL)
 }
 
+// scpLikeRemote matches remotes in the scp-like syntax of git, such as
+// git@github.com:sourcegraph/src-cli.git or example.com:repo.git.
+var scpLikeRemote = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):([^/].*)$`)
+
 // parseRemote converts a git origin url into a Sourcegraph-friendly repo name.
 func parseRemote(remoteURL string) (string, error) {
+	remoteURL = strings.TrimSuffix(strings.TrimSpace(remoteURL), "/")
+
 	// e.g., git@github.com:sourcegraph/src-cli.git
-	if strings.HasPrefix(remoteURL, "git@") {
-		if parts := strings.Split(remoteURL, ":"); len(parts) == 2 {
-			return strings.Join([]string{
-				strings.TrimPrefix(parts[0], "git@"),
-				strings.TrimSuffix(parts[1], ".git"),
-			}, "/"), nil
+	if !strings.Contains(remoteURL, "://") {
+		if m := scpLikeRemote.FindStringSubmatch(remoteURL); m != nil {
+			return m[1] + "/" + strings.TrimSuffix(m[2], ".git"), nil
 		}
 	}
 
-	// e.g., https://github.com/sourcegraph/src-cli.git
+	// e.g., https://github.com/sourcegraph/src-cli.git or
+	// ssh://git@gitlab.example.com:2222/sourcegraph/src-cli.git
 	if url, err := url.Parse(remoteURL); err == nil {
 		return url.Hostname() + strings.TrimSuffix(url.Path, ".git"), nil
 	}
@@ -64,10 +77,15 @@ func InferRoot(file string) (string, error) {
 }
 
 // runGitCommand runs a git command and trims all leading/trailing whitespace from the output.
+// Only the standard output is returned, so that warnings, such as those printed in shallow
+// clones, don't end up in the result.
 func runGitCommand(args ...string) (string, error) {
-	output, err := exec.Command("git", args...).CombinedOutput()
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
 	if err != nil {
-		return "", fmt.Errorf("failed to run git command: %s\n%s", err, output)
+		return "", fmt.Errorf("failed to run git command: %s\n%s", err, stderr.Bytes())
 	}
 
 	return strings.TrimSpace(string(output)), nil
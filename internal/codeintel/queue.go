@@ -0,0 +1,147 @@
+package codeintel
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// QueuedUpload holds the metadata of an upload that failed and is queued to
+// be retried.
+type QueuedUpload struct {
+	Repo              string    `json:"repo"`
+	Commit            string    `json:"commit"`
+	Root              string    `json:"root"`
+	Indexer           string    `json:"indexer"`
+	AssociatedIndexID *int      `json:"associatedIndexId,omitempty"`
+	QueuedAt          time.Time `json:"queuedAt"`
+	Attempts          int       `json:"attempts"`
+	LastError         string    `json:"lastError"`
+}
+
+// QueueEntry is an upload in a Queue.
+type QueueEntry struct {
+	// ID identifies the entry in the queue. IDs sort in the order the entries
+	// were added.
+	ID string
+	// File is the path of the queued copy of the index file.
+	File string
+	QueuedUpload
+}
+
+// Queue persists failed uploads in a directory, so that they can be retried
+// later. For every upload, the queue contains a copy of the index file and a
+// JSON file with the upload's metadata.
+type Queue struct {
+	dir string
+}
+
+// OpenQueue returns the queue in the given directory, creating the directory
+// if it doesn't exist.
+func OpenQueue(dir string) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("creating queue directory: %w", err)
+	}
+	return &Queue{dir: dir}, nil
+}
+
+// Add copies the index file into the queue, together with the upload's
+// metadata, and returns the ID of the new entry.
+func (q *Queue) Add(indexFile string, upload QueuedUpload) (string, error) {
+	var suffix [4]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return "", err
+	}
+	id := upload.QueuedAt.UTC().Format("20060102T150405.000000000Z") + "-" + hex.EncodeToString(suffix[:])
+
+	if err := copyFile(indexFile, q.indexPath(id)); err != nil {
+		os.Remove(q.indexPath(id))
+		return "", fmt.Errorf("copying index file to queue: %w", err)
+	}
+
+	// The metadata is written last, so that entries without it are
+	// incomplete copies and ignored by List.
+	if err := q.Update(QueueEntry{ID: id, QueuedUpload: upload}); err != nil {
+		os.Remove(q.indexPath(id))
+		return "", err
+	}
+	return id, nil
+}
+
+// List returns the entries in the queue, in the order they were added.
+func (q *Queue) List() ([]QueueEntry, error) {
+	matches, err := filepath.Glob(filepath.Join(q.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+
+	entries := make([]QueueEntry, 0, len(matches))
+	for _, m := range matches {
+		data, err := os.ReadFile(m)
+		if err != nil {
+			return nil, err
+		}
+
+		id := strings.TrimSuffix(filepath.Base(m), ".json")
+		entry := QueueEntry{ID: id, File: q.indexPath(id)}
+		if err := json.Unmarshal(data, &entry.QueuedUpload); err != nil {
+			return nil, fmt.Errorf("reading queued upload %s: %w", id, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Update writes the metadata of the given entry.
+func (q *Queue) Update(entry QueueEntry) error {
+	data, err := json.MarshalIndent(entry.QueuedUpload, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := q.metadataPath(entry.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("writing queued upload %s: %w", entry.ID, err)
+	}
+	return os.Rename(tmp, q.metadataPath(entry.ID))
+}
+
+// Remove deletes the entry with the given ID from the queue.
+func (q *Queue) Remove(id string) error {
+	if err := os.Remove(q.metadataPath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(q.indexPath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (q *Queue) indexPath(id string) string    { return filepath.Join(q.dir, id+".lsif") }
+func (q *Queue) metadataPath(id string) string { return filepath.Join(q.dir, id+".json") }
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,73 @@
+package codeintel
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestQueue(t *testing.T) {
+	dir := t.TempDir()
+	q, err := OpenQueue(filepath.Join(dir, "queue"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index := filepath.Join(dir, "dump.lsif")
+	if err := os.WriteFile(index, []byte("lsif"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2021, 10, 20, 12, 0, 0, 0, time.UTC)
+	first, err := q.Add(index, QueuedUpload{Repo: "github.com/a/b", Commit: "deadbeef", QueuedAt: now, LastError: "timeout"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := q.Add(index, QueuedUpload{Repo: "github.com/a/b", Commit: "cafebabe", Root: "cmd", QueuedAt: now.Add(time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The original file can go away once it's queued.
+	if err := os.Remove(index); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := q.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids, commits []string
+	for _, e := range entries {
+		ids = append(ids, e.ID)
+		commits = append(commits, e.Commit)
+		if data, err := os.ReadFile(e.File); err != nil || string(data) != "lsif" {
+			t.Errorf("wrong queued index file for %s: %q, %v", e.ID, data, err)
+		}
+	}
+	if diff := cmp.Diff([]string{first, second}, ids); diff != "" {
+		t.Errorf("wrong entries (-want +have):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"deadbeef", "cafebabe"}, commits); diff != "" {
+		t.Errorf("wrong commits (-want +have):\n%s", diff)
+	}
+
+	entries[1].Attempts = 2
+	if err := q.Update(entries[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Remove(first); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err = q.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ID != second || entries[0].Attempts != 2 {
+		t.Errorf("wrong entries after update and remove: %+v", entries)
+	}
+}
@@ -0,0 +1,213 @@
+package codeintel
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gobwas/glob"
+)
+
+// WatchedIndex is an index file found by a Watcher.
+type WatchedIndex struct {
+	// File is the path of the index file.
+	File string
+	// Dir is the directory of the index file relative to the watched
+	// directory.
+	Dir string
+	// SHA256 is the hex-encoded SHA-256 checksum of the content of the file.
+	SHA256 string
+}
+
+// Watcher finds the index files that appear in a directory, such as the ones
+// that the indexers of a CI build for several languages write while they
+// finish. Files are only reported once their size and modification time
+// didn't change between two polls, so that files that are still being written
+// aren't reported, and again if they change afterwards.
+type Watcher struct {
+	dir  string
+	glob glob.Glob
+
+	// seen is the size and modification time of the files at the last poll.
+	seen map[string]fileState
+	// reported is the state of the files when they were last reported.
+	reported map[string]fileState
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// NewWatcher returns a Watcher of the index files below dir whose name
+// matches the given glob pattern. Hidden files and directories are skipped.
+// The directory doesn't need to exist yet.
+func NewWatcher(dir, namePattern string) (*Watcher, error) {
+	g, err := glob.Compile(namePattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file name pattern %q: %w", namePattern, err)
+	}
+	return &Watcher{
+		dir:      dir,
+		glob:     g,
+		seen:     map[string]fileState{},
+		reported: map[string]fileState{},
+	}, nil
+}
+
+// Poll returns the index files that are ready since the last poll, sorted by
+// path. active is true if files appeared or changed since the last poll,
+// including files that aren't ready yet.
+func (w *Watcher) Poll() (ready []WatchedIndex, active bool, err error) {
+	current := map[string]fileState{}
+	err = filepath.WalkDir(w.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				// The watched directory doesn't exist yet, or a file was
+				// removed while walking.
+				return nil
+			}
+			return err
+		}
+		if p != w.dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() || !w.glob.Match(d.Name()) {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		current[p] = fileState{size: fi.Size(), modTime: fi.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	for p, state := range current {
+		prev, ok := w.seen[p]
+		if !ok || prev != state {
+			active = true
+			continue
+		}
+		if reported, ok := w.reported[p]; ok && reported == state {
+			continue
+		}
+
+		sum, err := fileSHA256(p)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, false, err
+		}
+		rel, err := filepath.Rel(w.dir, filepath.Dir(p))
+		if err != nil {
+			return nil, false, err
+		}
+		ready = append(ready, WatchedIndex{File: p, Dir: SanitizeRoot(filepath.ToSlash(rel)), SHA256: sum})
+		w.reported[p] = state
+	}
+	w.seen = current
+
+	sort.Slice(ready, func(i, j int) bool { return ready[i].File < ready[j].File })
+	return ready, active, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CheckpointedUpload is an upload recorded in a WatchCheckpoint.
+type CheckpointedUpload struct {
+	File     string `json:"file"`
+	Root     string `json:"root"`
+	Indexer  string `json:"indexer"`
+	UploadID int    `json:"uploadId,omitempty"`
+	// State is QUEUED LOCALLY if the upload failed and was queued to be
+	// retried, and empty otherwise.
+	State      string    `json:"state,omitempty"`
+	UploadedAt time.Time `json:"uploadedAt"`
+}
+
+// WatchCheckpoint records the index files uploaded by a watcher by their
+// content, so that a watcher that is restarted, such as by retrying a CI job,
+// doesn't upload them again.
+type WatchCheckpoint struct {
+	path    string
+	uploads map[string]CheckpointedUpload
+}
+
+// OpenWatchCheckpoint reads the checkpoint at path. If it doesn't exist, the
+// checkpoint is empty, and created once an upload is added.
+func OpenWatchCheckpoint(path string) (*WatchCheckpoint, error) {
+	c := &WatchCheckpoint{path: path, uploads: map[string]CheckpointedUpload{}}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.uploads); err != nil {
+		return nil, fmt.Errorf("reading checkpoint %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// WatchCheckpointKey is the key of the upload of an index file with the given
+// checksum for the given repository and commit. Index files with the same
+// content are only uploaded once for a commit.
+func WatchCheckpointKey(repo, commit, sha256 string) string {
+	return repo + "@" + commit + ":" + sha256
+}
+
+// Get returns the upload with the given key.
+func (c *WatchCheckpoint) Get(key string) (CheckpointedUpload, bool) {
+	u, ok := c.uploads[key]
+	return u, ok
+}
+
+// Add records the upload with the given key and writes the checkpoint.
+func (c *WatchCheckpoint) Add(key string, upload CheckpointedUpload) error {
+	c.uploads[key] = upload
+
+	data, err := json.MarshalIndent(c.uploads, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	return os.Rename(tmp, c.path)
+}
@@ -0,0 +1,119 @@
+package codeintel
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWatcher(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "out")
+	w, err := NewWatcher(dir, "*.lsif")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	poll := func(wantActive bool) []WatchedIndex {
+		t.Helper()
+		ready, active, err := w.Poll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if active != wantActive {
+			t.Errorf("wrong active: want %t, have %t", wantActive, active)
+		}
+		return ready
+	}
+	write := func(name, content string) {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := func(ready []WatchedIndex) []string {
+		var names []string
+		for _, r := range ready {
+			rel, _ := filepath.Rel(dir, r.File)
+			names = append(names, filepath.ToSlash(rel)+" "+r.Dir)
+		}
+		return names
+	}
+
+	if ready := poll(false); len(ready) != 0 {
+		t.Fatalf("unexpected files: %v", ready)
+	}
+
+	write("go.lsif", "go")
+	write("cmd/app/ts.lsif", "ts")
+	write("notes.txt", "no index")
+	write(".hidden/java.lsif", "hidden")
+	write(".tmp.lsif", "hidden")
+
+	// New files are only ready once they didn't change for a poll.
+	if ready := poll(true); len(ready) != 0 {
+		t.Fatalf("unexpected files: %v", ready)
+	}
+	ready := poll(false)
+	if diff := cmp.Diff([]string{"cmd/app/ts.lsif cmd/app", "go.lsif "}, files(ready)); diff != "" {
+		t.Errorf("wrong files (-want +have):\n%s", diff)
+	}
+	if sum := sha256.Sum256([]byte("go")); ready[1].SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("wrong checksum: %q", ready[1].SHA256)
+	}
+
+	// Reported files aren't reported again, unless they change.
+	if ready := poll(false); len(ready) != 0 {
+		t.Fatalf("unexpected files: %v", ready)
+	}
+	write("go.lsif", "go, again")
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(dir, "go.lsif"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	poll(true)
+	if diff := cmp.Diff([]string{"go.lsif "}, files(poll(false))); diff != "" {
+		t.Errorf("wrong files (-want +have):\n%s", diff)
+	}
+}
+
+func TestWatchCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	c, err := OpenWatchCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := WatchCheckpointKey("github.com/sourcegraph/src-cli", "deadbeef", "cafe")
+	if _, ok := c.Get(key); ok {
+		t.Fatal("empty checkpoint has an upload")
+	}
+
+	upload := CheckpointedUpload{File: "go.lsif", Indexer: "lsif-go", UploadID: 42, UploadedAt: time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)}
+	if err := c.Add(key, upload); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenWatchCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	have, ok := reopened.Get(key)
+	if !ok {
+		t.Fatal("upload wasn't persisted")
+	}
+	if diff := cmp.Diff(upload, have); diff != "" {
+		t.Errorf("wrong upload (-want +have):\n%s", diff)
+	}
+	if _, ok := reopened.Get(WatchCheckpointKey("github.com/sourcegraph/src-cli", "0ther", "cafe")); ok {
+		t.Error("upload of another commit found")
+	}
+}
@@ -0,0 +1,248 @@
+package debug
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// The kinds of identifying data that are pseudonymized, and the formats of
+// their pseudonyms. Hosts use the reserved .invalid top-level domain, so that
+// they still read like host names but can't be mistaken for real ones.
+const (
+	anonymizedHosts = "hosts"
+	anonymizedIPs   = "ips"
+	anonymizedUsers = "users"
+	anonymizedRepos = "repos"
+)
+
+var pseudonymFormats = map[string]string{
+	anonymizedHosts: "host-%d.invalid",
+	anonymizedIPs:   "ip-%d",
+	anonymizedUsers: "user-%d",
+	anonymizedRepos: "repo-%d",
+}
+
+// publicHosts are hosts whose names, and the paths below them, are left
+// as is, because they only appear in references to public code, such as Go
+// import paths in stack traces and Kubernetes annotations. Subdomains of the
+// hosts are public, too.
+var publicHosts = []string{
+	"sourcegraph.com",
+	"golang.org",
+	"go.opentelemetry.io",
+	"k8s.io",
+	"kubernetes.io",
+	"gopkg.in",
+	"docker.io",
+	"cluster.local",
+}
+
+// publicCodeHosts are code hosts whose names are left as is, but whose repos
+// are pseudonymized, except for the ones owned by Sourcegraph.
+var publicCodeHosts = []string{"github.com", "gitlab.com", "bitbucket.org"}
+
+const (
+	hostnameExpr = `(?:[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?\.)+[A-Za-z]{2,63}`
+	// Host names without any other context are only matched if they have at
+	// least three labels, so that file names like config.json aren't.
+	bareHostnameExpr = `(?:[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?\.){2,}[A-Za-z]{2,63}`
+)
+
+// anonymizePattern matches the identifying data in the files of an archive.
+// Its alternatives are tried in order, so that a URL is matched as a whole
+// before the host names and user names in it are.
+var anonymizePattern = regexp.MustCompile(strings.Join([]string{
+	// URLs, with optional user info.
+	`(?P<scheme>\b[A-Za-z][A-Za-z0-9+.-]*://)(?:(?P<userinfo>[^/\s:@"'<>]+)(?P<password>:[^/\s@"'<>]*)?@)?(?P<urlhost>[A-Za-z0-9.-]+)`,
+	// Email addresses.
+	`(?P<email>\b[A-Za-z0-9._%+-]+)@(?P<emailhost>` + hostnameExpr + `)\b`,
+	// User names given as key and value, like user=alice or "username": "alice".
+	`\b(?P<userkey>(?i:user(?:name)?|login|author|committer))(?P<usersep>"?\s*[:=]\s*"?)(?P<user>[A-Za-z][\w.-]*)`,
+	// Repository names, like github.com/owner/name.
+	`\b(?P<repohost>` + hostnameExpr + `)/(?P<repo>[\w.-]+/[\w.-]+)`,
+	// IPv4 addresses.
+	`\b(?P<ip>\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3})\b`,
+	// Host names.
+	`\b(?P<host>` + bareHostnameExpr + `)\b`,
+}, "|"))
+
+// Anonymizer pseudonymizes host names, internal IP addresses, user names and
+// repository names in the files of a debug archive. The same value is
+// replaced with the same pseudonym in all files, so that the archive can still
+// be used to correlate events, and the mapping from pseudonyms back to the
+// original values is kept so that it can be stored separately.
+//
+// Anonymization is based on patterns and therefore best-effort: the archive
+// should still be reviewed before sharing it.
+type Anonymizer struct {
+	// pseudonyms maps the kind of data to a map from original values to their
+	// pseudonyms.
+	pseudonyms map[string]map[string]string
+}
+
+// NewAnonymizer returns an Anonymizer without any pseudonyms.
+func NewAnonymizer() *Anonymizer {
+	return &Anonymizer{pseudonyms: map[string]map[string]string{}}
+}
+
+// File returns a copy of f with its path, data and error anonymized. Each
+// element of the path is anonymized on its own, so that the structure of the
+// archive is kept.
+func (a *Anonymizer) File(f *File) *File {
+	elems := strings.Split(f.Path, "/")
+	for i, elem := range elems {
+		elems[i] = a.String(elem)
+	}
+
+	anonymized := &File{
+		Path: path.Join(elems...),
+		Data: []byte(a.String(string(f.Data))),
+	}
+	if f.Err != nil {
+		anonymized.Err = errors.New(a.String(f.Err.Error()))
+	}
+	return anonymized
+}
+
+// String returns s with all identifying data replaced by pseudonyms.
+func (a *Anonymizer) String(s string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range anonymizePattern.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(s[last:loc[0]])
+		b.WriteString(a.replace(s, loc))
+		last = loc[1]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// Mapping returns the pseudonyms of each kind of data, mapped to the original
+// values they replace.
+func (a *Anonymizer) Mapping() map[string]map[string]string {
+	mapping := make(map[string]map[string]string, len(a.pseudonyms))
+	for kind, pseudonyms := range a.pseudonyms {
+		mapping[kind] = make(map[string]string, len(pseudonyms))
+		for original, pseudonym := range pseudonyms {
+			mapping[kind][pseudonym] = original
+		}
+	}
+	return mapping
+}
+
+// replace returns the replacement of the match of anonymizePattern in s at
+// loc.
+func (a *Anonymizer) replace(s string, loc []int) string {
+	group := func(name string) string {
+		i := anonymizePattern.SubexpIndex(name)
+		if loc[2*i] < 0 {
+			return ""
+		}
+		return s[loc[2*i]:loc[2*i+1]]
+	}
+
+	switch {
+	case group("scheme") != "":
+		url := group("scheme")
+		if user := group("userinfo"); user != "" {
+			url += a.pseudonym(anonymizedUsers, user)
+			if group("password") != "" {
+				url += ":REDACTED"
+			}
+			url += "@"
+		}
+		return url + a.host(group("urlhost"))
+
+	case group("email") != "":
+		return a.pseudonym(anonymizedUsers, group("email")) + "@" + a.host(group("emailhost"))
+
+	case group("userkey") != "":
+		return group("userkey") + group("usersep") + a.pseudonym(anonymizedUsers, group("user"))
+
+	case group("repohost") != "":
+		return a.repo(group("repohost"), group("repo"))
+
+	case group("ip") != "":
+		return a.ip(group("ip"))
+
+	default:
+		return a.host(group("host"))
+	}
+}
+
+// host returns the pseudonym of a host name or IP address. Public hosts and
+// names without a domain, such as the names of Kubernetes services, are kept.
+func (a *Anonymizer) host(name string) string {
+	if net.ParseIP(name) != nil {
+		return a.ip(name)
+	}
+	if !strings.Contains(name, ".") || isPublicHost(name) || hasHost(publicCodeHosts, name) {
+		return name
+	}
+	return a.pseudonym(anonymizedHosts, strings.ToLower(name))
+}
+
+// repo returns the pseudonym of the repository name on the given host.
+func (a *Anonymizer) repo(host, name string) string {
+	if isPublicHost(host) {
+		return host + "/" + name
+	}
+	if hasHost(publicCodeHosts, host) && strings.EqualFold(strings.SplitN(name, "/", 2)[0], "sourcegraph") {
+		return host + "/" + name
+	}
+	return a.host(host) + "/" + a.pseudonym(anonymizedRepos, strings.ToLower(host+"/"+name))
+}
+
+// ip returns the pseudonym of an internal IP address. Other addresses are
+// kept.
+func (a *Anonymizer) ip(addr string) string {
+	ip := net.ParseIP(addr)
+	if ip == nil || !isInternalIP(ip) {
+		return addr
+	}
+	return a.pseudonym(anonymizedIPs, ip.String())
+}
+
+// pseudonym returns the pseudonym of the original value of the given kind,
+// creating it if the value hasn't been seen before.
+func (a *Anonymizer) pseudonym(kind, original string) string {
+	pseudonyms, ok := a.pseudonyms[kind]
+	if !ok {
+		pseudonyms = map[string]string{}
+		a.pseudonyms[kind] = pseudonyms
+	}
+	if p, ok := pseudonyms[original]; ok {
+		return p
+	}
+	p := fmt.Sprintf(pseudonymFormats[kind], len(pseudonyms)+1)
+	pseudonyms[original] = p
+	return p
+}
+
+func isPublicHost(name string) bool {
+	return hasHost(publicHosts, name)
+}
+
+// hasHost returns true if name is one of the hosts, or a subdomain of one.
+func hasHost(hosts []string, name string) bool {
+	name = strings.ToLower(name)
+	for _, h := range hosts {
+		if name == h || strings.HasSuffix(name, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+// sharedAddressSpace is the range of carrier-grade NAT addresses, which are
+// commonly used for pods and services in Kubernetes clusters.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func isInternalIP(ip net.IP) bool {
+	return ip.IsPrivate() || ip.IsLinkLocalUnicast() || sharedAddressSpace.Contains(ip)
+}
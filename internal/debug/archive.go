@@ -0,0 +1,178 @@
+// Package debug collects diagnostic information about Sourcegraph deployments
+// into zip archives that can be shared with Sourcegraph support.
+package debug
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// File is a single file in a debug archive.
+type File struct {
+	// Path is the slash-separated path of the file within the archive,
+	// relative to the archive's base directory.
+	Path string
+	Data []byte
+	// Err is set if collecting the file failed. Data may still contain
+	// partial output.
+	Err error
+}
+
+// Archive writes Files into a zip archive, below a common base directory.
+//
+// The paths of the files are sanitized, so that the archive extracts cleanly
+// on Windows and macOS, too, whatever the names of the pods, containers and
+// contexts in them: see sanitizeArchivePath. Paths that only differ in case
+// are made unique.
+type Archive struct {
+	zw   *zip.Writer
+	base string
+	now  func() time.Time
+
+	// names are the lower-cased names of the entries in the archive.
+	names map[string]struct{}
+}
+
+// NewArchive returns an Archive that writes to w, placing all files in the
+// base directory.
+func NewArchive(w io.Writer, base string) *Archive {
+	return &Archive{
+		zw:    zip.NewWriter(w),
+		base:  sanitizeArchivePath(base),
+		now:   time.Now,
+		names: map[string]struct{}{},
+	}
+}
+
+// Add writes f to the archive. If collecting f failed, the error is written
+// to an additional file with an .err suffix, so that it's visible to whoever
+// inspects the archive.
+func (a *Archive) Add(f *File) error {
+	name := a.entryName(f.Path)
+	if f.Err == nil || len(f.Data) > 0 {
+		if err := a.write(name, f.Data); err != nil {
+			return err
+		}
+	}
+	if f.Err != nil {
+		return a.write(a.entryName(name+".err"), []byte(fmt.Sprintf("%s\n", f.Err)))
+	}
+	return nil
+}
+
+// entryName returns the sanitized name of the entry for the file at p, which
+// is unique in the archive even on case-insensitive file systems.
+func (a *Archive) entryName(p string) string {
+	name := sanitizeArchivePath(p)
+	dir, file := path.Split(name)
+	ext := path.Ext(file)
+	for i := 2; ; i++ {
+		if _, ok := a.names[strings.ToLower(name)]; !ok {
+			break
+		}
+		name = dir + strings.TrimSuffix(file, ext) + fmt.Sprintf("-%d", i) + ext
+	}
+	a.names[strings.ToLower(name)] = struct{}{}
+	return name
+}
+
+// maxArchiveSegment is the maximum length of the name of a file or directory
+// in an archive, which leaves enough room within the path length limit of
+// Windows for a few levels of directories.
+const maxArchiveSegment = 100
+
+// windowsReservedNames are the names of devices, which can't be used as file
+// names on Windows, with or without an extension.
+var windowsReservedNames = map[string]bool{
+	"con": true, "prn": true, "aux": true, "nul": true,
+	"com1": true, "com2": true, "com3": true, "com4": true, "com5": true, "com6": true, "com7": true, "com8": true, "com9": true,
+	"lpt1": true, "lpt2": true, "lpt3": true, "lpt4": true, "lpt5": true, "lpt6": true, "lpt7": true, "lpt8": true, "lpt9": true,
+}
+
+// sanitizeArchivePath turns p, a slash-separated path assembled from the
+// names of pods, containers and contexts, into a relative path that can be
+// extracted on Linux, macOS and Windows. In each segment of the path,
+// backslashes, colons, the other characters that Windows doesn't allow and
+// control characters are replaced by underscores, trailing dots and spaces are
+// removed, reserved device names are prefixed with an underscore, and segments
+// longer than maxArchiveSegment are shortened, keeping their extension and
+// adding a hash of the original name so that they remain distinct. Empty, .
+// and .. segments are dropped.
+func sanitizeArchivePath(p string) string {
+	var segments []string
+	for _, segment := range strings.Split(p, "/") {
+		if segment = sanitizeArchiveSegment(segment); segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	if len(segments) == 0 {
+		return "_"
+	}
+	return strings.Join(segments, "/")
+}
+
+func sanitizeArchiveSegment(segment string) string {
+	if segment == "." || segment == ".." {
+		return ""
+	}
+
+	original := segment
+	segment = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`<>:"\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, segment)
+	segment = strings.TrimRight(segment, ". ")
+	if segment == "" {
+		return ""
+	}
+
+	stem := segment
+	if i := strings.IndexByte(segment, '.'); i > 0 {
+		stem = segment[:i]
+	}
+	if windowsReservedNames[strings.ToLower(stem)] {
+		segment = "_" + segment
+	}
+
+	if len(segment) > maxArchiveSegment {
+		sum := sha256.Sum256([]byte(original))
+		hash := "-" + hex.EncodeToString(sum[:])[:8]
+		ext := path.Ext(segment)
+		if len(ext) > maxArchiveSegment/4 {
+			ext = ""
+		}
+		stem := strings.ToValidUTF8(segment[:maxArchiveSegment-len(hash)-len(ext)], "")
+		segment = stem + hash + ext
+	}
+	return segment
+}
+
+func (a *Archive) write(name string, data []byte) error {
+	w, err := a.zw.CreateHeader(&zip.FileHeader{
+		Name:     path.Join(a.base, name),
+		Method:   zip.Deflate,
+		Modified: a.now(),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "creating %q in archive", name)
+	}
+	if _, err := w.Write(data); err != nil {
+		return errors.Wrapf(err, "writing %q to archive", name)
+	}
+	return nil
+}
+
+// Close finishes writing the archive. It doesn't close the underlying writer.
+func (a *Archive) Close() error {
+	return a.zw.Close()
+}
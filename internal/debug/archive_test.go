@@ -0,0 +1,127 @@
+package debug
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestArchive(t *testing.T) {
+	var buf bytes.Buffer
+	archive := NewArchive(&buf, "debug")
+
+	for _, f := range []*File{
+		{Path: "docker/inspect.json", Data: []byte("[]")},
+		{Path: "docker/logs.txt", Data: []byte("partial"), Err: errors.New("exit status 1")},
+		{Path: "config/site-config.json", Err: errors.New("not a site admin")},
+	} {
+		if err := archive.Add(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	have := readArchive(t, buf.Bytes())
+	want := map[string]string{
+		"debug/docker/inspect.json":         "[]",
+		"debug/docker/logs.txt":             "partial",
+		"debug/docker/logs.txt.err":         "exit status 1\n",
+		"debug/config/site-config.json.err": "not a site admin\n",
+	}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Errorf("wrong archive contents (-want +have):\n%s", diff)
+	}
+}
+
+func readArchive(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string]string, len(zr.File))
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(content)
+	}
+	return files
+}
+
+func TestArchiveSanitizesPaths(t *testing.T) {
+	var buf bytes.Buffer
+	archive := NewArchive(&buf, "debug")
+
+	for _, f := range []*File{
+		{Path: "pods/Frontend/logs.txt", Data: []byte("a")},
+		{Path: "pods/frontend/logs.txt", Data: []byte("b"), Err: errors.New("exit status 1")},
+		{Path: `contexts/arn:aws:eks/con/..\logs.txt`, Data: []byte("c")},
+		{Path: "../../etc/passwd", Data: []byte("d")},
+	} {
+		if err := archive.Add(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	have := readArchive(t, buf.Bytes())
+	want := map[string]string{
+		"debug/pods/Frontend/logs.txt":                "a",
+		"debug/pods/frontend/logs-2.txt":              "b",
+		"debug/pods/frontend/logs-2.txt.err":          "exit status 1\n",
+		"debug/contexts/arn_aws_eks/_con/.._logs.txt": "c",
+		"debug/etc/passwd":                            "d",
+	}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Errorf("wrong archive contents (-want +have):\n%s", diff)
+	}
+}
+
+func TestSanitizeArchivePath(t *testing.T) {
+	for p, want := range map[string]string{
+		"docker/logs.txt":                 "docker/logs.txt",
+		"pods/a:b/c|d?.txt":               "pods/a_b/c_d_.txt",
+		"pods/trailing. /x":               "pods/trailing/x",
+		"NUL.txt":                         "_NUL.txt",
+		"lpt1":                            "_lpt1",
+		"console.txt":                     "console.txt",
+		"a//./b":                          "a/b",
+		"":                                "_",
+		"tab\there":                       "tab_here",
+		strings.Repeat("x", 150) + ".log": strings.Repeat("x", 87) + "-" + shortHash(strings.Repeat("x", 150)+".log") + ".log",
+	} {
+		if have := sanitizeArchivePath(p); have != want {
+			t.Errorf("wrong path for %q:\nhave=%q\nwant=%q", p, have, want)
+		}
+		for _, segment := range strings.Split(sanitizeArchivePath(p), "/") {
+			if len(segment) > maxArchiveSegment {
+				t.Errorf("segment of %q too long: %d", p, len(segment))
+			}
+		}
+	}
+}
+
+func shortHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:8]
+}
@@ -0,0 +1,44 @@
+package debug
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/exec"
+)
+
+// CommandFile runs the given command and returns a File at path that contains
+// its standard output. If the command fails, its standard error is included
+// in the File's error.
+func CommandFile(ctx context.Context, path, name string, args ...string) *File {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	f := &File{Path: path}
+	if err := cmd.Run(); err != nil {
+		f.Err = errors.Wrapf(err, "running %s %s: %s", name, strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+	f.Data = stdout.Bytes()
+	return f
+}
+
+// CombinedCommandFile runs the given command and returns a File at path that
+// contains its standard output and standard error, interleaved.
+func CombinedCommandFile(ctx context.Context, path, name string, args ...string) *File {
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	f := &File{Path: path}
+	if err := cmd.Run(); err != nil {
+		f.Err = errors.Wrapf(err, "running %s %s", name, strings.Join(args, " "))
+	}
+	f.Data = out.Bytes()
+	return f
+}
@@ -0,0 +1,39 @@
+package debug
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+)
+
+const siteConfigQuery = `query SiteConfig {
+	site {
+		configuration {
+			effectiveContents
+		}
+	}
+}`
+
+// SiteConfigFile returns a File containing the site configuration of the
+// Sourcegraph instance. This requires the client to authenticate as a site
+// admin.
+func SiteConfigFile(ctx context.Context, client api.Client) *File {
+	f := &File{Path: "config/site-config.json"}
+
+	var result struct {
+		Site struct {
+			Configuration struct {
+				EffectiveContents string
+			}
+		}
+	}
+	if ok, err := client.NewQuery(siteConfigQuery).Do(ctx, &result); err != nil || !ok {
+		f.Err = errors.Wrap(err, "querying site configuration")
+		return f
+	}
+
+	f.Data = []byte(result.Site.Configuration.EffectiveContents)
+	return f
+}
@@ -0,0 +1,97 @@
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/cockroachdb/errors"
+)
+
+// redacted replaces secret values in collected files.
+const redacted = "REDACTED"
+
+// lastAppliedAnnotation holds the complete manifest of a resource applied
+// with kubectl apply, including the data that is otherwise redacted.
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+var (
+	// sensitiveKey matches the keys of config map entries whose values are
+	// redacted as a whole.
+	sensitiveKey = regexp.MustCompile(`(?i)pass(?:word|wd)?|secret|token|api[_-]?key|credential|private[_-]?key|auth`)
+	// sensitiveAssignment matches assignments of secrets within config files,
+	// like password: hunter2 or "apiKey": "abc".
+	sensitiveAssignment = regexp.MustCompile(`(?i)([\w.-]*(?:password|passwd|secret|token|api[_-]?key|credentials?)[\w.-]*"?\s*[:=]\s*)("[^"]*"|'[^']*'|[^\s,;]+)`)
+	// urlPassword matches the password in the user info of a URL.
+	urlPassword = regexp.MustCompile(`(://[^/\s:@]+:)[^/\s@]+@`)
+	// privateKey matches PEM encoded private keys.
+	privateKey = regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?-----END [A-Z ]*PRIVATE KEY-----`)
+)
+
+// kubeConfigMapsFile returns the config maps in the namespace of the target
+// as JSON, with secrets redacted: the values of entries whose keys look like
+// secrets, passwords and tokens assigned within the values, passwords in URLs
+// and private keys. Binary data is replaced by its size, and the
+// last-applied-configuration annotation, which duplicates the data, is
+// dropped.
+func kubeConfigMapsFile(ctx context.Context, target KubeTarget) *File {
+	f := CommandFile(ctx, "kubectl/configmaps.json", target.Kubectl.name(), target.kubectlArgs("get", "configmaps", "--output", "json")...)
+	if f.Err != nil {
+		// The output can't be redacted if it's incomplete.
+		f.Data = nil
+		return f
+	}
+
+	data, err := redactConfigMaps(f.Data)
+	if err != nil {
+		return &File{Path: f.Path, Err: err}
+	}
+	f.Data = data
+	return f
+}
+
+// redactConfigMaps redacts the secrets in the JSON output of kubectl get
+// configmaps.
+func redactConfigMaps(data []byte) ([]byte, error) {
+	var list struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, errors.Wrap(err, "parsing config maps")
+	}
+
+	for _, item := range list.Items {
+		if metadata, ok := item["metadata"].(map[string]interface{}); ok {
+			delete(metadata, "managedFields")
+			if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+				delete(annotations, lastAppliedAnnotation)
+			}
+		}
+		if entries, ok := item["data"].(map[string]interface{}); ok {
+			for key, value := range entries {
+				s, _ := value.(string)
+				entries[key] = redactConfigValue(key, s)
+			}
+		}
+		if entries, ok := item["binaryData"].(map[string]interface{}); ok {
+			for key, value := range entries {
+				s, _ := value.(string)
+				entries[key] = fmt.Sprintf("%s (%d bytes of base64)", redacted, len(s))
+			}
+		}
+	}
+
+	return json.MarshalIndent(list, "", "  ")
+}
+
+// redactConfigValue redacts the secrets in the value of the config map entry
+// with the given key.
+func redactConfigValue(key, value string) string {
+	if sensitiveKey.MatchString(key) {
+		return redacted
+	}
+	value = privateKey.ReplaceAllString(value, redacted)
+	value = urlPassword.ReplaceAllString(value, "${1}"+redacted+"@")
+	return sensitiveAssignment.ReplaceAllString(value, "${1}"+redacted)
+}
@@ -0,0 +1,621 @@
+package debug
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sourcegraph/jsonx"
+	"gopkg.in/yaml.v3"
+)
+
+// Bundle is the contents of a debug archive, by the path of the files relative
+// to the base directory of the archive.
+type Bundle map[string][]byte
+
+// ReadBundle reads the debug archive at path.
+func ReadBundle(path string) (Bundle, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening debug archive %s", path)
+	}
+	defer zr.Close()
+
+	b := Bundle{}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		// Strip the base directory of the archive.
+		name := f.Name
+		if i := strings.IndexByte(name, '/'); i >= 0 {
+			name = name[i+1:]
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading %s from %s", f.Name, path)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading %s from %s", f.Name, path)
+		}
+		b[name] = data
+	}
+	return b, nil
+}
+
+// BundleDiff is what changed between two debug archives of the same
+// deployment. The Context of each change is the kubeconfig context it's in,
+// if the archives were collected from several contexts.
+type BundleDiff struct {
+	Resources []ResourceChange `json:"resources"`
+	Crashes   []ContainerCrash `json:"crashes"`
+	Config    []ConfigChange   `json:"config"`
+	Usage     []UsageChange    `json:"usage"`
+	// Skipped are the files that couldn't be compared, because they're
+	// missing from one of the archives or can't be parsed.
+	Skipped []string `json:"skipped"`
+}
+
+// Empty returns true if nothing changed.
+func (d *BundleDiff) Empty() bool {
+	return len(d.Resources) == 0 && len(d.Crashes) == 0 && len(d.Config) == 0 && len(d.Usage) == 0
+}
+
+// ResourceChange is a Kubernetes resource that was added, removed, or whose
+// spec changed.
+type ResourceChange struct {
+	Context string `json:"context,omitempty"`
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	// Change is one of added, removed and changed.
+	Change string        `json:"change"`
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+// FieldChange is a changed field of a manifest or configuration. Old or New
+// is nil if the field was added or removed.
+type FieldChange struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+// ContainerCrash is a container that crashes in the new archive, and didn't
+// in the old one, or restarted more often. The containers of the pods of a
+// workload are counted together, as their pods are replaced over time.
+type ContainerCrash struct {
+	Context   string `json:"context,omitempty"`
+	Workload  string `json:"workload"`
+	Container string `json:"container"`
+	// Reason is why the container is or was last terminated, such as
+	// CrashLoopBackOff or OOMKilled.
+	Reason      string `json:"reason"`
+	Restarts    int    `json:"restarts"`
+	OldRestarts int    `json:"oldRestarts"`
+}
+
+// ConfigChange is a changed field of the site configuration, or of a config
+// map.
+type ConfigChange struct {
+	Context string `json:"context,omitempty"`
+	// Source is "site configuration" or the name of the config map.
+	Source string `json:"source"`
+	FieldChange
+}
+
+// UsageChange is the change of the resource usage of a container, summed up
+// across the pods of its workload.
+type UsageChange struct {
+	Context   string `json:"context,omitempty"`
+	Workload  string `json:"workload"`
+	Container string `json:"container"`
+	// OldCPU and NewCPU are in millicores.
+	OldCPU int64 `json:"oldCPU"`
+	NewCPU int64 `json:"newCPU"`
+	// OldMemory and NewMemory are in bytes.
+	OldMemory int64 `json:"oldMemory"`
+	NewMemory int64 `json:"newMemory"`
+}
+
+// UsageChangeThreshold is the relative change of the CPU or memory usage of a
+// container above which it's reported.
+const UsageChangeThreshold = 0.25
+
+// DiffBundles compares the old debug archive with the new one. Both should be
+// collected from the same deployment, with the standard profile or above, for
+// all the changes to be found.
+func DiffBundles(old, new Bundle) *BundleDiff {
+	d := &BundleDiff{}
+
+	d.diffSiteConfig(old, new)
+	oldRoots, newRoots := old.contextRoots(), new.contextRoots()
+	for _, context := range sortedKeys(newRoots) {
+		root := newRoots[context]
+		if _, ok := oldRoots[context]; !ok {
+			d.Skipped = append(d.Skipped, fmt.Sprintf("%s: only in the new archive", root))
+			continue
+		}
+		d.diffResources(context, root, old, new)
+		d.diffCrashes(context, root, old, new)
+		d.diffConfigMaps(context, root, old, new)
+		d.diffUsage(context, root, old, new)
+	}
+	for _, context := range sortedKeys(oldRoots) {
+		if _, ok := newRoots[context]; !ok {
+			d.Skipped = append(d.Skipped, fmt.Sprintf("%s: only in the old archive", oldRoots[context]))
+		}
+	}
+	return d
+}
+
+// contextRoots returns the directories of the Kubernetes contexts in the
+// archive, by the name of the context. Archives of a single context have
+// their files at the top level, with an empty name.
+func (b Bundle) contextRoots() map[string]string {
+	roots := map[string]string{}
+	for p := range b {
+		if rest := strings.TrimPrefix(p, "contexts/"); rest != p {
+			if i := strings.IndexByte(rest, '/'); i > 0 {
+				roots[rest[:i]] = "contexts/" + rest[:i] + "/"
+			}
+		} else if strings.HasPrefix(p, "kubectl/") {
+			roots[""] = ""
+		}
+	}
+	return roots
+}
+
+// files returns the file at p in both archives. ok is false if the file is
+// missing from either, which is recorded as skipped if it's in one of them.
+func (d *BundleDiff) files(p string, old, new Bundle) (oldData, newData []byte, ok bool) {
+	oldData, inOld := old[p]
+	newData, inNew := new[p]
+	switch {
+	case inOld && inNew:
+		return oldData, newData, true
+	case inOld:
+		d.Skipped = append(d.Skipped, fmt.Sprintf("%s: missing from the new archive", p))
+	case inNew:
+		d.Skipped = append(d.Skipped, fmt.Sprintf("%s: missing from the old archive", p))
+	}
+	return nil, nil, false
+}
+
+func (d *BundleDiff) skipParseError(p string, err error) {
+	d.Skipped = append(d.Skipped, fmt.Sprintf("%s: %s", p, err))
+}
+
+// manifestFiles are the files with the Kubernetes resources whose specs are
+// compared.
+var manifestFiles = []string{
+	"kubectl/workloads.yaml",
+	"kubectl/ingresses.yaml",
+	"kubectl/gateways.yaml",
+	"kubectl/networkpolicies.yaml",
+}
+
+func (d *BundleDiff) diffResources(context, root string, old, new Bundle) {
+	for _, name := range manifestFiles {
+		p := root + name
+		oldData, newData, ok := d.files(p, old, new)
+		if !ok {
+			continue
+		}
+		oldItems, err := parseKubeList(oldData)
+		if err != nil {
+			d.skipParseError(p, err)
+			continue
+		}
+		newItems, err := parseKubeList(newData)
+		if err != nil {
+			d.skipParseError(p, err)
+			continue
+		}
+
+		oldByKey, newByKey := resourcesByKey(oldItems), resourcesByKey(newItems)
+		for _, key := range sortedKeys(newByKey) {
+			r := newByKey[key]
+			kind, name := resourceKindName(r)
+			o, ok := oldByKey[key]
+			if !ok {
+				d.Resources = append(d.Resources, ResourceChange{Context: context, Kind: kind, Name: name, Change: "added"})
+				continue
+			}
+			var fields []FieldChange
+			diffValues("spec", o["spec"], r["spec"], &fields)
+			if len(fields) > 0 {
+				d.Resources = append(d.Resources, ResourceChange{Context: context, Kind: kind, Name: name, Change: "changed", Fields: fields})
+			}
+		}
+		for _, key := range sortedKeys(oldByKey) {
+			if _, ok := newByKey[key]; !ok {
+				kind, name := resourceKindName(oldByKey[key])
+				d.Resources = append(d.Resources, ResourceChange{Context: context, Kind: kind, Name: name, Change: "removed"})
+			}
+		}
+	}
+}
+
+// parseKubeList parses the items of the YAML or JSON output of kubectl get.
+func parseKubeList(data []byte) ([]map[string]interface{}, error) {
+	var list struct {
+		Items []map[string]interface{} `yaml:"items"`
+	}
+	if err := yaml.Unmarshal(data, &list); err != nil {
+		return nil, errors.Wrap(err, "parsing resources")
+	}
+	return list.Items, nil
+}
+
+func resourcesByKey(items []map[string]interface{}) map[string]map[string]interface{} {
+	byKey := make(map[string]map[string]interface{}, len(items))
+	for _, item := range items {
+		kind, name := resourceKindName(item)
+		byKey[kind+"/"+name] = item
+	}
+	return byKey
+}
+
+func resourceKindName(item map[string]interface{}) (kind, name string) {
+	kind, _ = item["kind"].(string)
+	metadata, _ := item["metadata"].(map[string]interface{})
+	name, _ = metadata["name"].(string)
+	return kind, name
+}
+
+// diffValues appends the fields that differ between old and new, below path.
+// Maps are compared key by key, and lists of the same length item by item.
+func diffValues(path string, old, new interface{}, fields *[]FieldChange) {
+	oldMap, oldIsMap := old.(map[string]interface{})
+	newMap, newIsMap := new.(map[string]interface{})
+	if oldIsMap && newIsMap {
+		keys := map[string]struct{}{}
+		for k := range oldMap {
+			keys[k] = struct{}{}
+		}
+		for k := range newMap {
+			keys[k] = struct{}{}
+		}
+		for _, k := range sortedKeys(keys) {
+			diffValues(path+"."+k, oldMap[k], newMap[k], fields)
+		}
+		return
+	}
+
+	oldList, oldIsList := old.([]interface{})
+	newList, newIsList := new.([]interface{})
+	if oldIsList && newIsList && len(oldList) == len(newList) {
+		for i := range oldList {
+			diffValues(fmt.Sprintf("%s[%d]", path, i), oldList[i], newList[i], fields)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(old, new) {
+		*fields = append(*fields, FieldChange{Path: path, Old: old, New: new})
+	}
+}
+
+// crashReasons are the reasons of waiting and terminated containers that mean
+// that they crash.
+var crashReasons = map[string]bool{
+	"CrashLoopBackOff":     true,
+	"Error":                true,
+	"OOMKilled":            true,
+	"ContainerCannotRun":   true,
+	"CreateContainerError": true,
+	"RunContainerError":    true,
+}
+
+// containerState is the state of a container across the pods of a workload.
+type containerState struct {
+	crashing bool
+	reason   string
+	restarts int
+}
+
+func (d *BundleDiff) diffCrashes(context, root string, old, new Bundle) {
+	p := root + "kubectl/pods.yaml"
+	oldData, newData, ok := d.files(p, old, new)
+	if !ok {
+		return
+	}
+	oldStates, err := parseContainerStates(oldData)
+	if err != nil {
+		d.skipParseError(p, err)
+		return
+	}
+	newStates, err := parseContainerStates(newData)
+	if err != nil {
+		d.skipParseError(p, err)
+		return
+	}
+
+	for _, key := range sortedKeys(newStates) {
+		s, o := newStates[key], oldStates[key]
+		if (s.crashing && !o.crashing) || (s.reason != "" && s.restarts > o.restarts) {
+			workload, container := splitContainerKey(key)
+			d.Crashes = append(d.Crashes, ContainerCrash{
+				Context:     context,
+				Workload:    workload,
+				Container:   container,
+				Reason:      s.reason,
+				Restarts:    s.restarts,
+				OldRestarts: o.restarts,
+			})
+		}
+	}
+}
+
+// parseContainerStates returns the states of the containers in the YAML output
+// of kubectl get pods, by their workload and name.
+func parseContainerStates(data []byte) (map[string]containerState, error) {
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Name string `yaml:"name"`
+			} `yaml:"metadata"`
+			Status struct {
+				ContainerStatuses []struct {
+					Name         string                  `yaml:"name"`
+					RestartCount int                     `yaml:"restartCount"`
+					State        map[string]podStateInfo `yaml:"state"`
+					LastState    map[string]podStateInfo `yaml:"lastState"`
+				} `yaml:"containerStatuses"`
+			} `yaml:"status"`
+		} `yaml:"items"`
+	}
+	if err := yaml.Unmarshal(data, &list); err != nil {
+		return nil, errors.Wrap(err, "parsing pods")
+	}
+
+	states := map[string]containerState{}
+	for _, pod := range list.Items {
+		workload := workloadName(pod.Metadata.Name)
+		for _, c := range pod.Status.ContainerStatuses {
+			key := workload + "/" + c.Name
+			s := states[key]
+			s.restarts += c.RestartCount
+			for _, state := range []string{"waiting", "terminated"} {
+				if reason := c.State[state].Reason; crashReasons[reason] {
+					s.crashing = true
+					s.reason = reason
+				}
+			}
+			if reason := c.LastState["terminated"].Reason; s.reason == "" && c.RestartCount > 0 && reason != "" {
+				s.reason = reason
+			}
+			states[key] = s
+		}
+	}
+	return states, nil
+}
+
+type podStateInfo struct {
+	Reason string `yaml:"reason"`
+}
+
+func splitContainerKey(key string) (workload, container string) {
+	i := strings.LastIndexByte(key, '/')
+	return key[:i], key[i+1:]
+}
+
+var (
+	// replicaSetPodSuffix matches the pod template hash and the random
+	// suffix of the names of pods of deployments.
+	replicaSetPodSuffix = regexp.MustCompile(`-[bcdfghjklmnpqrstvwxz2456789]{5,10}-[bcdfghjklmnpqrstvwxz2456789]{5}$`)
+	// randomPodSuffix matches the random suffix of the names of pods of
+	// daemon sets and jobs.
+	randomPodSuffix = regexp.MustCompile(`-[bcdfghjklmnpqrstvwxz2456789]{5}$`)
+)
+
+// workloadName returns the name of the workload of a pod, by removing the
+// suffixes Kubernetes generates from its name. The names of the pods of
+// stateful sets are kept, as they're stable.
+func workloadName(pod string) string {
+	if loc := replicaSetPodSuffix.FindStringIndex(pod); loc != nil {
+		return pod[:loc[0]]
+	}
+	if loc := randomPodSuffix.FindStringIndex(pod); loc != nil {
+		return pod[:loc[0]]
+	}
+	return pod
+}
+
+func (d *BundleDiff) diffSiteConfig(old, new Bundle) {
+	const p = "config/site-config.json"
+	oldData, newData, ok := d.files(p, old, new)
+	if !ok {
+		return
+	}
+	var oldConfig, newConfig interface{}
+	if err := unmarshalJSONC(oldData, &oldConfig); err != nil {
+		d.skipParseError(p, err)
+		return
+	}
+	if err := unmarshalJSONC(newData, &newConfig); err != nil {
+		d.skipParseError(p, err)
+		return
+	}
+
+	var fields []FieldChange
+	diffValues("", oldConfig, newConfig, &fields)
+	for _, f := range fields {
+		f.Path = strings.TrimPrefix(f.Path, ".")
+		d.Config = append(d.Config, ConfigChange{Source: "site configuration", FieldChange: f})
+	}
+}
+
+// unmarshalJSONC unmarshals JSON with comments and trailing commas, like the
+// site configuration.
+func unmarshalJSONC(data []byte, v interface{}) error {
+	plain, errs := jsonx.Parse(string(data), jsonx.ParseOptions{Comments: true, TrailingCommas: true})
+	if len(errs) > 0 {
+		return errors.Newf("parsing JSON: %v", errs)
+	}
+	return json.Unmarshal(plain, v)
+}
+
+func (d *BundleDiff) diffConfigMaps(context, root string, old, new Bundle) {
+	p := root + "kubectl/configmaps.json"
+	oldData, newData, ok := d.files(p, old, new)
+	if !ok {
+		return
+	}
+	oldItems, err := parseKubeList(oldData)
+	if err != nil {
+		d.skipParseError(p, err)
+		return
+	}
+	newItems, err := parseKubeList(newData)
+	if err != nil {
+		d.skipParseError(p, err)
+		return
+	}
+
+	// Config maps are compared by their data. The data of added and removed
+	// config maps is compared to nothing.
+	data := func(items []map[string]interface{}) map[string]interface{} {
+		byName := map[string]interface{}{}
+		for _, item := range items {
+			_, name := resourceKindName(item)
+			byName[name] = map[string]interface{}{"data": item["data"], "binaryData": item["binaryData"]}
+		}
+		return byName
+	}
+	var fields []FieldChange
+	diffValues("", data(oldItems), data(newItems), &fields)
+	for _, f := range fields {
+		// The path is .NAME.data.KEY.
+		path := strings.TrimPrefix(f.Path, ".")
+		name := path
+		if i := strings.Index(path, ".data"); i >= 0 {
+			name, path = path[:i], path[i+1:]
+		} else if i := strings.Index(path, ".binaryData"); i >= 0 {
+			name, path = path[:i], path[i+1:]
+		} else {
+			path = ""
+		}
+		d.Config = append(d.Config, ConfigChange{
+			Context:     context,
+			Source:      "config map " + name,
+			FieldChange: FieldChange{Path: path, Old: f.Old, New: f.New},
+		})
+	}
+}
+
+func (d *BundleDiff) diffUsage(context, root string, old, new Bundle) {
+	p := root + "kubectl/top-pods.txt"
+	oldData, newData, ok := d.files(p, old, new)
+	if !ok {
+		return
+	}
+	oldUsage, newUsage := parseTopPods(oldData), parseTopPods(newData)
+
+	for _, key := range sortedKeys(newUsage) {
+		n := newUsage[key]
+		o, ok := oldUsage[key]
+		if !ok {
+			continue
+		}
+		if usageChanged(o.cpu, n.cpu) || usageChanged(o.memory, n.memory) {
+			workload, container := splitContainerKey(key)
+			d.Usage = append(d.Usage, UsageChange{
+				Context:   context,
+				Workload:  workload,
+				Container: container,
+				OldCPU:    o.cpu,
+				NewCPU:    n.cpu,
+				OldMemory: o.memory,
+				NewMemory: n.memory,
+			})
+		}
+	}
+}
+
+func usageChanged(old, new int64) bool {
+	if old == 0 {
+		return new != 0
+	}
+	change := float64(new-old) / float64(old)
+	return change > UsageChangeThreshold || change < -UsageChangeThreshold
+}
+
+type containerUsage struct {
+	cpu, memory int64
+}
+
+// parseTopPods parses the output of kubectl top pods --containers into the
+// usage of the containers by their workload and name. Lines that can't be
+// parsed are ignored.
+func parseTopPods(data []byte) map[string]containerUsage {
+	usage := map[string]containerUsage{}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 4 || fields[0] == "POD" {
+			continue
+		}
+		cpu, err := parseCPU(fields[2])
+		if err != nil {
+			continue
+		}
+		memory, err := parseMemory(fields[3])
+		if err != nil {
+			continue
+		}
+		key := workloadName(fields[0]) + "/" + fields[1]
+		u := usage[key]
+		u.cpu += cpu
+		u.memory += memory
+		usage[key] = u
+	}
+	return usage
+}
+
+// parseCPU parses a CPU quantity such as 250m or 2 into millicores.
+func parseCPU(s string) (int64, error) {
+	if m := strings.TrimSuffix(s, "m"); m != s {
+		return strconv.ParseInt(m, 10, 64)
+	}
+	cores, err := strconv.ParseFloat(s, 64)
+	return int64(cores * 1000), err
+}
+
+var memoryUnits = map[string]int64{
+	"Ki": 1 << 10, "Mi": 1 << 20, "Gi": 1 << 30, "Ti": 1 << 40,
+	"k": 1e3, "M": 1e6, "G": 1e9, "T": 1e12,
+}
+
+// parseMemory parses a memory quantity such as 512Mi into bytes.
+func parseMemory(s string) (int64, error) {
+	for _, n := range []int{2, 1} {
+		if len(s) <= n {
+			continue
+		}
+		if unit, ok := memoryUnits[s[len(s)-n:]]; ok {
+			v, err := strconv.ParseInt(s[:len(s)-n], 10, 64)
+			return v * unit, err
+		}
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// sortedKeys returns the sorted keys of m, a map with string keys.
+func sortedKeys(m interface{}) []string {
+	v := reflect.ValueOf(m)
+	keys := make([]string, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,199 @@
+package debug
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiffBundles(t *testing.T) {
+	workloads := func(image string, replicas int) []byte {
+		return []byte(`apiVersion: v1
+kind: List
+items:
+- kind: Deployment
+  metadata:
+    name: frontend
+    resourceVersion: "` + image + `"
+  spec:
+    replicas: ` + string(rune('0'+replicas)) + `
+    template:
+      spec:
+        containers:
+        - name: frontend
+          image: sourcegraph/frontend:` + image + `
+  status:
+    readyReplicas: 1
+`)
+	}
+	old := Bundle{
+		"config/site-config.json": []byte(`{
+  // The URL of the instance.
+  "externalURL": "https://sourcegraph.example.com",
+  "search.limits": {"maxRepos": 100},
+}`),
+		"kubectl/workloads.yaml": append(workloads("3.30.0", 2), []byte(`- kind: StatefulSet
+  metadata:
+    name: redis
+  spec:
+    replicas: 1
+`)...),
+		"kubectl/pods.yaml": []byte(`items:
+- metadata:
+    name: frontend-5d8f7c9b6d-x2x4q
+  status:
+    containerStatuses:
+    - name: frontend
+      restartCount: 0
+      state:
+        running: {}
+- metadata:
+    name: gitserver-0
+  status:
+    containerStatuses:
+    - name: gitserver
+      restartCount: 1
+      state:
+        running: {}
+      lastState:
+        terminated:
+          reason: OOMKilled
+`),
+		"kubectl/configmaps.json": []byte(`{"items": [{"metadata": {"name": "sourcegraph"}, "data": {"LOG_LEVEL": "warn", "PGPASSWORD": "REDACTED"}}]}`),
+		"kubectl/top-pods.txt": []byte(`POD                          NAME        CPU(cores)   MEMORY(bytes)
+frontend-5d8f7c9b6d-x2x4q    frontend    100m         500Mi
+gitserver-0                  gitserver   1            2Gi
+`),
+		"kubectl/ingresses.yaml": []byte(`items: []`),
+	}
+	new := Bundle{
+		"config/site-config.json": []byte(`{
+  "externalURL": "https://sourcegraph.example.com",
+  "search.limits": {"maxRepos": 200},
+  "experimentalFeatures": {"structuralSearch": "disabled"}
+}`),
+		"kubectl/workloads.yaml": workloads("3.31.0", 3),
+		"kubectl/pods.yaml": []byte(`items:
+- metadata:
+    name: frontend-7f9b8d6c5b-mzq2n
+  status:
+    containerStatuses:
+    - name: frontend
+      restartCount: 4
+      state:
+        waiting:
+          reason: CrashLoopBackOff
+- metadata:
+    name: frontend-7f9b8d6c5b-t7w9k
+  status:
+    containerStatuses:
+    - name: frontend
+      restartCount: 1
+      state:
+        running: {}
+- metadata:
+    name: gitserver-0
+  status:
+    containerStatuses:
+    - name: gitserver
+      restartCount: 1
+      state:
+        running: {}
+      lastState:
+        terminated:
+          reason: OOMKilled
+`),
+		"kubectl/configmaps.json": []byte(`{"items": [{"metadata": {"name": "sourcegraph"}, "data": {"LOG_LEVEL": "debug", "PGPASSWORD": "REDACTED"}}]}`),
+		"kubectl/top-pods.txt": []byte(`POD                          NAME        CPU(cores)   MEMORY(bytes)
+frontend-7f9b8d6c5b-mzq2n    frontend    10m          400Mi
+frontend-7f9b8d6c5b-t7w9k    frontend    100m         400Mi
+gitserver-0                  gitserver   1100m        2Gi
+`),
+	}
+
+	have := DiffBundles(old, new)
+	want := &BundleDiff{
+		Resources: []ResourceChange{
+			{Kind: "Deployment", Name: "frontend", Change: "changed", Fields: []FieldChange{
+				{Path: "spec.replicas", Old: 2, New: 3},
+				{Path: "spec.template.spec.containers[0].image", Old: "sourcegraph/frontend:3.30.0", New: "sourcegraph/frontend:3.31.0"},
+			}},
+			{Kind: "StatefulSet", Name: "redis", Change: "removed"},
+		},
+		Crashes: []ContainerCrash{
+			{Workload: "frontend", Container: "frontend", Reason: "CrashLoopBackOff", Restarts: 5},
+		},
+		Config: []ConfigChange{
+			{Source: "site configuration", FieldChange: FieldChange{Path: "experimentalFeatures", New: map[string]interface{}{"structuralSearch": "disabled"}}},
+			{Source: "site configuration", FieldChange: FieldChange{Path: "search.limits.maxRepos", Old: 100.0, New: 200.0}},
+			{Source: "config map sourcegraph", FieldChange: FieldChange{Path: "data.LOG_LEVEL", Old: "warn", New: "debug"}},
+		},
+		Usage: []UsageChange{
+			{Workload: "frontend", Container: "frontend", OldCPU: 100, NewCPU: 110, OldMemory: 500 << 20, NewMemory: 800 << 20},
+		},
+		Skipped: []string{"kubectl/ingresses.yaml: missing from the new archive"},
+	}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Errorf("wrong diff (-want +have):\n%s", diff)
+	}
+}
+
+func TestDiffBundlesContexts(t *testing.T) {
+	old := Bundle{
+		"contexts/prod/kubectl/top-pods.txt":      []byte("gitserver-0 gitserver 1 1Gi\n"),
+		"contexts/executors/kubectl/top-pods.txt": []byte("executor-0 executor 1 1Gi\n"),
+	}
+	new := Bundle{
+		"contexts/prod/kubectl/top-pods.txt": []byte("gitserver-0 gitserver 1 2Gi\n"),
+	}
+
+	have := DiffBundles(old, new)
+	want := &BundleDiff{
+		Usage: []UsageChange{
+			{Context: "prod", Workload: "gitserver-0", Container: "gitserver", OldCPU: 1000, NewCPU: 1000, OldMemory: 1 << 30, NewMemory: 2 << 30},
+		},
+		Skipped: []string{"contexts/executors/: only in the old archive"},
+	}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Errorf("wrong diff (-want +have):\n%s", diff)
+	}
+}
+
+func TestReadBundle(t *testing.T) {
+	var buf bytes.Buffer
+	archive := NewArchive(&buf, "debug")
+	if err := archive.Add(&File{Path: "kubectl/pods.txt", Data: []byte("pods")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "debug.zip")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	have, err := ReadBundle(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(Bundle{"kubectl/pods.txt": []byte("pods")}, have); diff != "" {
+		t.Errorf("wrong bundle (-want +have):\n%s", diff)
+	}
+}
+
+func TestWorkloadName(t *testing.T) {
+	for pod, want := range map[string]string{
+		"frontend-5d8f7c9b6d-x2x4q": "frontend",
+		"node-exporter-zq7xk":       "node-exporter",
+		"gitserver-0":               "gitserver-0",
+		"sourcegraph-frontend":      "sourcegraph-frontend",
+	} {
+		if have := workloadName(pod); have != want {
+			t.Errorf("%s: want %q, have %q", pod, want, have)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package debug
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// dockerHostOS is the OS Docker runs on, which decides where its daemon logs
+// are found. It's overridden in tests.
+var dockerHostOS = runtime.GOOS
+
+// dockerVolumesFormat lists the names of the volumes mounted into a container,
+// one per line.
+const dockerVolumesFormat = `{{range .Mounts}}{{if eq .Type "volume"}}{{println .Name}}{{end}}{{end}}`
+
+// DockerFiles collects the files for a debug archive that describe the Docker
+// daemon the given container runs on: its version and configuration, the disk
+// usage of its images, containers and volumes, the volumes of the container,
+// the free space of the file systems in the container, and the daemon's logs,
+// where they're accessible. Single-node deployments mostly fail because a disk
+// runs full, which only these reveal.
+func DockerFiles(ctx context.Context, container string, logOpts LogOptions) []*File {
+	files := []*File{
+		CommandFile(ctx, "docker/version.txt", "docker", "version"),
+		CommandFile(ctx, "docker/info.txt", "docker", "info"),
+		CommandFile(ctx, "docker/system-df.txt", "docker", "system", "df", "--verbose"),
+		CommandFile(ctx, "docker/filesystems.txt", "docker", "container", "exec", container, "df", "-h"),
+		dockerVolumesFile(ctx, container),
+	}
+	return append(files, logOpts.logFiles(dockerDaemonLogFile(ctx, logOpts))...)
+}
+
+// dockerVolumesFile returns the output of docker volume inspect for the
+// volumes mounted into the container.
+func dockerVolumesFile(ctx context.Context, container string) *File {
+	const path = "docker/volumes.json"
+
+	mounts := CommandFile(ctx, path, "docker", "container", "inspect", "--format", dockerVolumesFormat, container)
+	if mounts.Err != nil {
+		return mounts
+	}
+	volumes := strings.Fields(string(mounts.Data))
+	if len(volumes) == 0 {
+		// Everything is bind mounted, which system-df and filesystems cover.
+		return &File{Path: path, Data: []byte("[]\n")}
+	}
+	return CommandFile(ctx, path, "docker", append([]string{"volume", "inspect"}, volumes...)...)
+}
+
+// dockerDaemonLogFile returns the logs of the Docker daemon: from journald on
+// Linux, and from the log files of Docker Desktop on macOS and Windows.
+func dockerDaemonLogFile(ctx context.Context, logOpts LogOptions) *File {
+	const path = "docker/daemon.log"
+
+	switch dockerHostOS {
+	case "linux":
+		args := []string{"--unit", "docker.service", "--no-pager"}
+		if logOpts.Since > 0 {
+			args = append(args, "--since", fmt.Sprintf("-%ds", int(logOpts.Since.Seconds())))
+		}
+		if !logOpts.Timestamps {
+			args = append(args, "--output", "cat")
+		}
+		return CommandFile(ctx, path, "journalctl", args...)
+
+	case "darwin", "windows":
+		candidates := dockerDesktopLogPaths()
+		for _, p := range candidates {
+			data, err := os.ReadFile(p)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return &File{Path: path, Err: errors.Wrapf(err, "reading %s", p)}
+			}
+			return &File{Path: path, Data: data}
+		}
+		return &File{Path: path, Err: errors.Newf("no Docker Desktop log found at %s", strings.Join(candidates, ", "))}
+
+	default:
+		return &File{Path: path, Err: errors.Newf("collecting Docker daemon logs on %s is not supported", dockerHostOS)}
+	}
+}
+
+// dockerDesktopLogPaths returns the paths at which the versions of Docker
+// Desktop keep the daemon log, newest first.
+func dockerDesktopLogPaths() []string {
+	if dockerHostOS == "windows" {
+		dir := os.Getenv("LOCALAPPDATA")
+		return []string{
+			filepath.Join(dir, "Docker", "log", "vm", "dockerd.log"),
+			filepath.Join(dir, "Docker", "log.txt"),
+		}
+	}
+
+	home, _ := os.UserHomeDir()
+	dir := filepath.Join(home, "Library", "Containers", "com.docker.docker", "Data", "log")
+	return []string{
+		filepath.Join(dir, "vm", "dockerd.log"),
+		filepath.Join(dir, "vm", "docker.log"),
+	}
+}
@@ -0,0 +1,71 @@
+package debug
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/src-cli/internal/exec/expect"
+)
+
+func TestDockerFiles(t *testing.T) {
+	oldOS := dockerHostOS
+	dockerHostOS = "linux"
+	t.Cleanup(func() { dockerHostOS = oldOS })
+
+	expect.Commands(t,
+		expect.NewGlob(expect.Behaviour{Stdout: []byte("Server: Docker Engine\n")}, "docker", "version"),
+		expect.NewGlob(expect.Success, "docker", "info"),
+		expect.NewGlob(expect.Behaviour{Stdout: []byte("Local Volumes space usage:\n")}, "docker", "system", "df", "--verbose"),
+		expect.NewGlob(expect.Behaviour{Stdout: []byte("/dev/sda1 100G 100G 0 100% /var/opt/sourcegraph\n")}, "docker", "container", "exec", "sg", "df", "-h"),
+		expect.NewGlob(expect.Behaviour{Stdout: []byte("sg-data\nsg-config\n\n")}, "docker", "container", "inspect", "--format", "*", "sg"),
+		expect.NewGlob(expect.Behaviour{Stdout: []byte(`[{"Name": "sg-data"}, {"Name": "sg-config"}]`)}, "docker", "volume", "inspect", "sg-data", "sg-config"),
+		expect.NewGlob(expect.Behaviour{ExitCode: 1, Stderr: []byte("No journal files were found.")}, "journalctl", "--unit", "docker.service", "--no-pager", "--since", "-7200s", "--output", "cat"),
+	)
+
+	files := DockerFiles(context.Background(), "sg", LogOptions{Since: 2 * time.Hour})
+
+	byPath := make(map[string]*File, len(files))
+	for _, f := range files {
+		byPath[f.Path] = f
+	}
+
+	if have := string(byPath["docker/filesystems.txt"].Data); !strings.Contains(have, "100%") {
+		t.Errorf("wrong filesystems output: %q", have)
+	}
+	if have := string(byPath["docker/volumes.json"].Data); !strings.Contains(have, "sg-config") {
+		t.Errorf("wrong volumes output: %q", have)
+	}
+	if f := byPath["docker/daemon.log"]; f.Err == nil || !strings.Contains(f.Err.Error(), "No journal files") {
+		t.Errorf("unexpected error for failed journalctl: %v", f.Err)
+	}
+}
+
+func TestDockerFilesWithoutVolumes(t *testing.T) {
+	oldOS := dockerHostOS
+	dockerHostOS = "plan9"
+	t.Cleanup(func() { dockerHostOS = oldOS })
+
+	expect.Commands(t,
+		expect.NewGlob(expect.Success, "docker", "version"),
+		expect.NewGlob(expect.Success, "docker", "info"),
+		expect.NewGlob(expect.Success, "docker", "system", "df", "--verbose"),
+		expect.NewGlob(expect.Success, "docker", "container", "exec", "sg", "df", "-h"),
+		expect.NewGlob(expect.Behaviour{Stdout: []byte("\n")}, "docker", "container", "inspect", "--format", "*", "sg"),
+	)
+
+	files := DockerFiles(context.Background(), "sg", LogOptions{})
+
+	byPath := make(map[string]*File, len(files))
+	for _, f := range files {
+		byPath[f.Path] = f
+	}
+
+	if f := byPath["docker/volumes.json"]; f.Err != nil || string(f.Data) != "[]\n" {
+		t.Errorf("wrong volumes file: data=%q err=%v", f.Data, f.Err)
+	}
+	if f := byPath["docker/daemon.log"]; f.Err == nil {
+		t.Error("unexpected nil error for unsupported OS")
+	}
+}
@@ -0,0 +1,246 @@
+package debug
+
+import (
+	"context"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/exec"
+)
+
+// Kubectl configures how kubectl is invoked by the collectors, so that they
+// don't depend on the environment of the operator.
+type Kubectl struct {
+	// Path is the kubectl binary. Empty means kubectl from $PATH.
+	Path string
+	// Kubeconfig is the kubeconfig file. Empty means the default of kubectl,
+	// which respects $KUBECONFIG.
+	Kubeconfig string
+}
+
+// name returns the name of the kubectl binary.
+func (k Kubectl) name() string {
+	if k.Path == "" {
+		return "kubectl"
+	}
+	return k.Path
+}
+
+// args returns the arguments for a kubectl invocation using the kubeconfig.
+func (k Kubectl) args(args ...string) []string {
+	if k.Kubeconfig == "" {
+		return args
+	}
+	return append([]string{"--kubeconfig", k.Kubeconfig}, args...)
+}
+
+// KubeTarget is a namespace in a Kubernetes cluster that debug information is
+// collected from.
+type KubeTarget struct {
+	Kubectl Kubectl
+	// Context is the kubeconfig context to use. Empty means the current
+	// context.
+	Context string
+	// Namespace is the namespace of the Sourcegraph deployment. Empty means
+	// the default namespace of the context.
+	Namespace string
+	// OpenShift additionally collects the OpenShift resources of the
+	// namespace: see openShiftFiles.
+	OpenShift bool
+}
+
+// kubectlArgs returns the arguments for a kubectl invocation against the
+// target.
+func (t KubeTarget) kubectlArgs(args ...string) []string {
+	var global []string
+	if t.Context != "" {
+		global = append(global, "--context", t.Context)
+	}
+	if t.Namespace != "" {
+		global = append(global, "--namespace", t.Namespace)
+	}
+	return t.Kubectl.args(append(global, args...)...)
+}
+
+// output runs kubectl against the target and returns its standard output.
+func (t KubeTarget) output(ctx context.Context, args ...string) (string, error) {
+	out, err := exec.CommandContext(ctx, t.Kubectl.name(), t.kubectlArgs(args...)...).Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// ListKubeContexts returns the names of the contexts in the kubeconfig.
+func ListKubeContexts(ctx context.Context, kubectl Kubectl) ([]string, error) {
+	out, err := exec.CommandContext(ctx, kubectl.name(), kubectl.args("config", "get-contexts", "--output", "name")...).Output()
+	if err != nil {
+		return nil, errors.Wrap(err, "listing kubeconfig contexts")
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// KubeTargetInfo describes the cluster and namespace a KubeTarget resolves to.
+type KubeTargetInfo struct {
+	Context   string
+	Cluster   string
+	Server    string
+	Namespace string
+}
+
+// DescribeKubeTarget resolves the context, cluster and namespace that debug
+// information will be collected from for the target, falling back to the
+// defaults of the kubeconfig where the target leaves them empty. It fails if
+// the kubeconfig doesn't define the context.
+func DescribeKubeTarget(ctx context.Context, target KubeTarget) (KubeTargetInfo, error) {
+	info := KubeTargetInfo{Context: target.Context, Namespace: target.Namespace}
+	if info.Context == "" {
+		current, err := target.output(ctx, "config", "current-context")
+		if err != nil {
+			return info, errors.Wrap(err, "getting current kubeconfig context")
+		}
+		info.Context = current
+	}
+
+	view := func(jsonpath string) (string, error) {
+		return target.output(ctx, "config", "view", "--minify", "--output", "jsonpath="+jsonpath)
+	}
+
+	var err error
+	if info.Cluster, err = view("{.contexts[0].context.cluster}"); err != nil {
+		return info, errors.Wrapf(err, "reading kubeconfig context %q", info.Context)
+	}
+	if info.Server, err = view("{.clusters[0].cluster.server}"); err != nil {
+		return info, errors.Wrapf(err, "reading kubeconfig context %q", info.Context)
+	}
+	if info.Namespace == "" {
+		if info.Namespace, err = view("{.contexts[0].context.namespace}"); err != nil {
+			return info, errors.Wrapf(err, "reading kubeconfig context %q", info.Context)
+		}
+		if info.Namespace == "" {
+			info.Namespace = "default"
+		}
+	}
+	return info, nil
+}
+
+// kubeGatewayResources are the resources of the Gateway API, which replaces
+// ingresses in some clusters. They only exist if the Gateway API is installed.
+const kubeGatewayResources = "gateways.gateway.networking.k8s.io,httproutes.gateway.networking.k8s.io"
+
+// kubeWorkloadResources are the resources that run the pods of a deployment.
+const kubeWorkloadResources = "deployments,statefulsets,daemonsets"
+
+// listKubePods returns the names of the pods in the target namespace.
+func listKubePods(ctx context.Context, target KubeTarget) ([]string, error) {
+	out, err := target.output(ctx, "get", "pods", "--output", "jsonpath={.items[*].metadata.name}")
+	if err != nil {
+		return nil, errors.Wrap(err, "listing pods")
+	}
+	return strings.Fields(out), nil
+}
+
+// KubeFiles collects the files for a debug archive of a Sourcegraph deployment
+// on Kubernetes. With ProfileMinimal, this is the state of the pods, services
+// and events in the namespace, and the logs of all their containers.
+// ProfileStandard adds the pod manifests, the resource usage of the pods, the
+// persistent volume claims, and the resources that route traffic and scale the
+// deployment: endpoints, ingresses, Gateway API gateways and routes, network
+// policies, deployments, stateful sets and daemon sets, horizontal pod
+// autoscalers, and the config maps with their secrets redacted. ProfileFull
+// adds the state and resource usage of the nodes. If the target is an
+// OpenShift cluster, the routes, image streams and security context
+// constraints are collected, too.
+func KubeFiles(ctx context.Context, target KubeTarget, profile Profile, logOpts LogOptions) []*File {
+	kubectl := func(path string, args ...string) *File {
+		return CommandFile(ctx, path, target.Kubectl.name(), target.kubectlArgs(args...)...)
+	}
+
+	files := []*File{
+		kubectl("kubectl/version.txt", "version"),
+		kubectl("kubectl/pods.txt", "get", "pods", "--output", "wide"),
+	}
+	if profile >= ProfileStandard {
+		files = append(files, kubectl("kubectl/pods.yaml", "get", "pods", "--output", "yaml"))
+	}
+	files = append(files,
+		kubectl("kubectl/services.txt", "get", "services", "--output", "wide"),
+		kubectl("kubectl/events.txt", "get", "events", "--sort-by", ".lastTimestamp"),
+	)
+	if profile >= ProfileStandard {
+		files = append(files,
+			kubectl("kubectl/top-pods.txt", "top", "pods", "--containers"),
+			kubectl("kubectl/pvcs.txt", "get", "persistentvolumeclaims"),
+			kubectl("kubectl/endpoints.txt", "get", "endpoints", "--output", "wide"),
+			kubectl("kubectl/ingresses.yaml", "get", "ingresses", "--output", "yaml"),
+			kubectl("kubectl/gateways.yaml", "get", kubeGatewayResources, "--output", "yaml"),
+			kubectl("kubectl/networkpolicies.yaml", "get", "networkpolicies", "--output", "yaml"),
+			kubectl("kubectl/workloads.txt", "get", kubeWorkloadResources, "--output", "wide"),
+			kubectl("kubectl/workloads.yaml", "get", kubeWorkloadResources, "--output", "yaml"),
+			kubectl("kubectl/hpas.txt", "describe", "horizontalpodautoscalers"),
+			kubeConfigMapsFile(ctx, target),
+		)
+	}
+	if profile >= ProfileFull {
+		files = append(files,
+			kubectl("kubectl/nodes.txt", "describe", "nodes"),
+			kubectl("kubectl/top-nodes.txt", "top", "nodes"),
+		)
+	}
+	if target.OpenShift {
+		files = append(files, openShiftFiles(ctx, target, profile)...)
+	}
+
+	pods, err := listKubePods(ctx, target)
+	if err != nil {
+		return append(files, &File{Path: "pods", Err: err})
+	}
+	for _, pod := range pods {
+		args := append([]string{"logs", pod, "--all-containers"}, logOpts.kubectlArgs()...)
+		files = append(files, kubectl(path.Join("pods", pod, "describe.txt"), "describe", "pod", pod))
+		files = append(files, logOpts.commandFiles(ctx, path.Join("pods", pod, "logs.txt"), false, target.Kubectl.name(), target.kubectlArgs(args...)...)...)
+	}
+	return files
+}
+
+// KubeContextFiles collects the files of KubeFiles for each of the targets in
+// parallel. If there is more than one target, the files of each target are
+// placed in a directory named after its context.
+func KubeContextFiles(ctx context.Context, targets []KubeTarget, profile Profile, logOpts LogOptions) []*File {
+	if len(targets) == 1 {
+		return KubeFiles(ctx, targets[0], profile, logOpts)
+	}
+
+	results := make([][]*File, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target KubeTarget) {
+			defer wg.Done()
+			results[i] = KubeFiles(ctx, target, profile, logOpts)
+		}(i, target)
+	}
+	wg.Wait()
+
+	var files []*File
+	for i, target := range targets {
+		dir := path.Join("contexts", contextDirName(target.Context))
+		for _, f := range results[i] {
+			f.Path = path.Join(dir, f.Path)
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+var unsafeContextChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// contextDirName turns the name of a kubeconfig context, which may look like
+// arn:aws:eks:us-east-1:1234:cluster/sourcegraph, into a directory name.
+func contextDirName(name string) string {
+	if name == "" {
+		return "current"
+	}
+	return unsafeContextChars.ReplaceAllString(name, "_")
+}
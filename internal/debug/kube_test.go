@@ -0,0 +1,147 @@
+package debug
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/src-cli/internal/exec/expect"
+)
+
+func TestKubeFiles(t *testing.T) {
+	kubectl := func(b expect.Behaviour, args ...string) *expect.Expectation {
+		return expect.NewGlob(b, "kubectl", append([]string{"--context", "executors", "--namespace", "sg"}, args...)...)
+	}
+	expect.Commands(t,
+		kubectl(expect.Success, "version"),
+		kubectl(expect.Success, "get", "pods", "--output", "wide"),
+		kubectl(expect.Success, "get", "pods", "--output", "yaml"),
+		kubectl(expect.Success, "get", "services", "--output", "wide"),
+		kubectl(expect.Success, "get", "events", "--sort-by", ".lastTimestamp"),
+		kubectl(expect.Behaviour{ExitCode: 1, Stderr: []byte("metrics not available")}, "top", "pods", "--containers"),
+		kubectl(expect.Success, "get", "persistentvolumeclaims"),
+		kubectl(expect.Success, "get", "endpoints", "--output", "wide"),
+		kubectl(expect.Success, "get", "ingresses", "--output", "yaml"),
+		kubectl(expect.Behaviour{ExitCode: 1, Stderr: []byte(`the server doesn't have a resource type "gateways"`)}, "get", "gateways.gateway.networking.k8s.io,httproutes.gateway.networking.k8s.io", "--output", "yaml"),
+		kubectl(expect.Success, "get", "networkpolicies", "--output", "yaml"),
+		kubectl(expect.Success, "get", "deployments,statefulsets,daemonsets", "--output", "wide"),
+		kubectl(expect.Success, "get", "deployments,statefulsets,daemonsets", "--output", "yaml"),
+		kubectl(expect.Success, "describe", "horizontalpodautoscalers"),
+		kubectl(expect.Behaviour{Stdout: []byte(`{"items": [{"data": {"PGPASSWORD": "hunter2"}}]}`)}, "get", "configmaps", "--output", "json"),
+		kubectl(expect.Behaviour{Stdout: []byte("frontend-0 gitserver-0")}, "get", "pods", "--output", "jsonpath=*"),
+		kubectl(expect.Success, "describe", "pod", "frontend-0"),
+		kubectl(expect.Behaviour{Stdout: []byte("frontend logs")}, "logs", "frontend-0", "--all-containers", "--timestamps"),
+		kubectl(expect.Success, "describe", "pod", "gitserver-0"),
+		kubectl(expect.Behaviour{Stdout: []byte("gitserver logs")}, "logs", "gitserver-0", "--all-containers", "--timestamps"),
+	)
+
+	files := KubeFiles(context.Background(), KubeTarget{Context: "executors", Namespace: "sg"}, ProfileStandard, LogOptions{Timestamps: true})
+
+	byPath := make(map[string]*File, len(files))
+	for _, f := range files {
+		byPath[f.Path] = f
+	}
+
+	if f := byPath["kubectl/top-pods.txt"]; f.Err == nil {
+		t.Error("unexpected nil error for failed command")
+	}
+	if f := byPath["kubectl/gateways.yaml"]; f.Err == nil {
+		t.Error("unexpected nil error for missing Gateway API")
+	}
+	if have := string(byPath["kubectl/configmaps.json"].Data); strings.Contains(have, "hunter2") {
+		t.Errorf("config maps not redacted: %s", have)
+	}
+	if have := string(byPath["pods/frontend-0/logs.txt"].Data); have != "frontend logs" {
+		t.Errorf("wrong frontend logs: %q", have)
+	}
+	if have := string(byPath["pods/gitserver-0/logs.txt"].Data); have != "gitserver logs" {
+		t.Errorf("wrong gitserver logs: %q", have)
+	}
+}
+
+func TestKubeFilesProfiles(t *testing.T) {
+	kubectl := func(b expect.Behaviour, args ...string) *expect.Expectation {
+		return expect.NewGlob(b, "kubectl", args...)
+	}
+
+	t.Run("minimal", func(t *testing.T) {
+		expect.Commands(t,
+			kubectl(expect.Success, "version"),
+			kubectl(expect.Success, "get", "pods", "--output", "wide"),
+			kubectl(expect.Success, "get", "services", "--output", "wide"),
+			kubectl(expect.Success, "get", "events", "--sort-by", ".lastTimestamp"),
+			kubectl(expect.Behaviour{Stdout: []byte("frontend-0")}, "get", "pods", "--output", "jsonpath=*"),
+			kubectl(expect.Success, "describe", "pod", "frontend-0"),
+			kubectl(expect.Success, "logs", "frontend-0", "--all-containers", "--since", "1h0m0s"),
+		)
+
+		KubeFiles(context.Background(), KubeTarget{}, ProfileMinimal, LogOptions{Since: MinimalLogsSince})
+	})
+
+	t.Run("full", func(t *testing.T) {
+		expect.Commands(t,
+			kubectl(expect.Success, "version"),
+			kubectl(expect.Success, "get", "pods", "--output", "wide"),
+			kubectl(expect.Success, "get", "pods", "--output", "yaml"),
+			kubectl(expect.Success, "get", "services", "--output", "wide"),
+			kubectl(expect.Success, "get", "events", "--sort-by", ".lastTimestamp"),
+			kubectl(expect.Success, "top", "pods", "--containers"),
+			kubectl(expect.Success, "get", "persistentvolumeclaims"),
+			kubectl(expect.Success, "get", "endpoints", "--output", "wide"),
+			kubectl(expect.Success, "get", "ingresses", "--output", "yaml"),
+			kubectl(expect.Success, "get", "gateways.gateway.networking.k8s.io,httproutes.gateway.networking.k8s.io", "--output", "yaml"),
+			kubectl(expect.Success, "get", "networkpolicies", "--output", "yaml"),
+			kubectl(expect.Success, "get", "deployments,statefulsets,daemonsets", "--output", "wide"),
+			kubectl(expect.Success, "get", "deployments,statefulsets,daemonsets", "--output", "yaml"),
+			kubectl(expect.Success, "describe", "horizontalpodautoscalers"),
+			kubectl(expect.Behaviour{Stdout: []byte(`{"items": []}`)}, "get", "configmaps", "--output", "json"),
+			kubectl(expect.Success, "describe", "nodes"),
+			kubectl(expect.Success, "top", "nodes"),
+			kubectl(expect.Success, "get", "pods", "--output", "jsonpath=*"),
+		)
+
+		KubeFiles(context.Background(), KubeTarget{}, ProfileFull, LogOptions{})
+	})
+}
+
+func TestDescribeKubeTarget(t *testing.T) {
+	kubectl := func(b expect.Behaviour, args ...string) *expect.Expectation {
+		return expect.NewGlob(b, "bin/kubectl", append([]string{"--kubeconfig", "prod.yaml"}, args...)...)
+	}
+	expect.Commands(t,
+		kubectl(expect.Behaviour{Stdout: []byte("production\n")}, "config", "current-context"),
+		kubectl(expect.Behaviour{Stdout: []byte("gke_sg_us")}, "config", "view", "--minify", "--output", "jsonpath=*context.cluster*"),
+		kubectl(expect.Behaviour{Stdout: []byte("https://10.0.0.1")}, "config", "view", "--minify", "--output", "jsonpath=*cluster.server*"),
+		kubectl(expect.Success, "config", "view", "--minify", "--output", "jsonpath=*context.namespace*"),
+	)
+
+	have, err := DescribeKubeTarget(context.Background(), KubeTarget{
+		Kubectl: Kubectl{Path: "bin/kubectl", Kubeconfig: "prod.yaml"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := KubeTargetInfo{
+		Context:   "production",
+		Cluster:   "gke_sg_us",
+		Server:    "https://10.0.0.1",
+		Namespace: "default",
+	}
+	if have != want {
+		t.Errorf("wrong info: have=%+v want=%+v", have, want)
+	}
+}
+
+func TestContextDirName(t *testing.T) {
+	for name, want := range map[string]string{
+		"":          "current",
+		"minikube":  "minikube",
+		"gke_sg_us": "gke_sg_us",
+		"arn:aws:eks:us-east-1:1234:cluster/sourcegraph": "arn_aws_eks_us-east-1_1234_cluster_sourcegraph",
+	} {
+		if have := contextDirName(name); have != want {
+			t.Errorf("wrong directory for %q: have=%q want=%q", name, have, want)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package debug
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/exec"
+)
+
+// LogOptions control which container logs are collected, and how much of
+// them ends up in the archive.
+type LogOptions struct {
+	// Since limits the logs to those written in the given duration before
+	// collection. Zero means all logs.
+	Since time.Duration
+	// Timestamps prefixes every log line with the time it was written.
+	Timestamps bool
+	// MaxBytes caps the size of a single log file. Larger logs are truncated
+	// according to Policy. Zero means no cap.
+	MaxBytes int
+	// Policy decides which part of a log larger than MaxBytes is kept. The
+	// default is LogPolicyHeadTail.
+	Policy LogPolicy
+	// TopMessages is the number of the most frequent messages of a log larger
+	// than MaxBytes that are listed in an additional -top.txt file, so that
+	// the messages that flood it are visible even if they're cut out. Zero
+	// means no such file.
+	TopMessages int
+}
+
+// LogPolicy decides which part of a log larger than LogOptions.MaxBytes is
+// kept.
+type LogPolicy string
+
+const (
+	// LogPolicyHeadTail keeps the beginning and the end of the log.
+	LogPolicyHeadTail LogPolicy = "head-tail"
+	// LogPolicyHead keeps the beginning of the log.
+	LogPolicyHead LogPolicy = "head"
+	// LogPolicyTail keeps the end of the log.
+	LogPolicyTail LogPolicy = "tail"
+)
+
+// LogPolicies are the valid log policies.
+var LogPolicies = []LogPolicy{LogPolicyHeadTail, LogPolicyHead, LogPolicyTail}
+
+// ParseLogPolicy returns the log policy of the given name.
+func ParseLogPolicy(name string) (LogPolicy, error) {
+	names := make([]string, 0, len(LogPolicies))
+	for _, p := range LogPolicies {
+		if string(p) == name {
+			return p, nil
+		}
+		names = append(names, string(p))
+	}
+	return LogPolicyHeadTail, errors.Newf("unknown log policy %q, must be one of %s", name, strings.Join(names, ", "))
+}
+
+// dockerArgs returns the arguments to pass to docker container logs.
+func (o LogOptions) dockerArgs() []string {
+	var args []string
+	if o.Since > 0 {
+		args = append(args, "--since", o.Since.String())
+	}
+	if o.Timestamps {
+		args = append(args, "--timestamps")
+	}
+	return args
+}
+
+// kubectlArgs returns the arguments to pass to kubectl logs.
+func (o LogOptions) kubectlArgs() []string {
+	var args []string
+	if o.Since > 0 {
+		args = append(args, "--since", o.Since.String())
+	}
+	if o.Timestamps {
+		args = append(args, "--timestamps")
+	}
+	return args
+}
+
+// logFiles applies the options to the log in f, which returns f and, if f was
+// truncated, the file of its most frequent messages.
+func (o LogOptions) logFiles(f *File) []*File {
+	s := newLogSampler(o)
+	s.Write(f.Data)
+	return s.files(f.Path, f.Err)
+}
+
+// commandFiles runs the given command, which prints a log, and returns the log
+// as a file at path with the options applied, like logFiles. The log is
+// sampled while it's read, so that only as much of it as ends up in the
+// archive is kept in memory. If combined is set, the log is read from
+// standard output and standard error, interleaved. Otherwise, it's read from
+// standard output and standard error is included in the error.
+func (o LogOptions) commandFiles(ctx context.Context, path string, combined bool, name string, args ...string) []*File {
+	s := newLogSampler(o)
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = s
+	cmd.Stderr = &stderr
+	if combined {
+		cmd.Stderr = s
+	}
+
+	var err error
+	if runErr := cmd.Run(); runErr != nil {
+		if combined {
+			err = errors.Wrapf(runErr, "running %s %s", name, strings.Join(args, " "))
+		} else {
+			err = errors.Wrapf(runErr, "running %s %s: %s", name, strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+		}
+	}
+	return s.files(path, err)
+}
+
+const (
+	// maxLogLineBytes is the length up to which log lines are compared when
+	// counting messages.
+	maxLogLineBytes = 200
+	// maxLogMessages is the number of distinct messages that are counted.
+	// Further messages are counted together, so that the memory used by a
+	// sampler is bounded.
+	maxLogMessages = 10000
+)
+
+// logSampler is an io.Writer that keeps a log as long as it isn't larger than
+// MaxBytes of its options, and only the parts of it that the policy keeps
+// otherwise. It also counts how often each message occurs.
+type logSampler struct {
+	opts             LogOptions
+	headMax, tailMax int
+
+	// full is the whole log, until it gets larger than MaxBytes.
+	full       []byte
+	truncated  bool
+	head, tail []byte
+	total      int
+
+	// line is the beginning of the current line, up to maxLogLineBytes.
+	line   []byte
+	lines  int
+	counts map[string]int
+	other  int
+}
+
+func newLogSampler(opts LogOptions) *logSampler {
+	s := &logSampler{opts: opts, counts: map[string]int{}}
+	switch opts.Policy {
+	case LogPolicyHead:
+		s.headMax = opts.MaxBytes
+	case LogPolicyTail:
+		s.tailMax = opts.MaxBytes
+	default:
+		s.headMax = opts.MaxBytes / 2
+		s.tailMax = opts.MaxBytes - s.headMax
+	}
+	return s
+}
+
+func (s *logSampler) Write(p []byte) (int, error) {
+	s.total += len(p)
+	if s.opts.MaxBytes > 0 && s.opts.TopMessages > 0 {
+		s.countLines(p)
+	}
+
+	switch {
+	case s.opts.MaxBytes <= 0 || (!s.truncated && len(s.full)+len(p) <= s.opts.MaxBytes):
+		s.full = append(s.full, p...)
+
+	case !s.truncated:
+		data := append(s.full, p...)
+		s.head = append([]byte(nil), data[:s.headMax]...)
+		s.tail = append([]byte(nil), data[len(data)-s.tailMax:]...)
+		s.full = nil
+		s.truncated = true
+
+	default:
+		s.tail = append(s.tail, p...)
+		if len(s.tail) > 2*s.tailMax {
+			s.tail = append([]byte(nil), s.tail[len(s.tail)-s.tailMax:]...)
+		}
+	}
+	return len(p), nil
+}
+
+// countLines counts the messages of the complete lines in p.
+func (s *logSampler) countLines(p []byte) {
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			s.appendLine(p)
+			return
+		}
+		s.appendLine(p[:i])
+		s.countLine()
+		p = p[i+1:]
+	}
+}
+
+func (s *logSampler) appendLine(p []byte) {
+	if n := maxLogLineBytes - len(s.line); n > 0 {
+		if len(p) > n {
+			p = p[:n]
+		}
+		s.line = append(s.line, p...)
+	}
+}
+
+func (s *logSampler) countLine() {
+	message := logMessage(s.line)
+	s.line = s.line[:0]
+	s.lines++
+
+	if _, ok := s.counts[message]; !ok && len(s.counts) >= maxLogMessages {
+		s.other++
+		return
+	}
+	s.counts[message]++
+}
+
+// logMessage returns the message of a log line: the line with every number
+// replaced by N, so that lines that only differ in timestamps, durations and
+// IDs are counted as the same message.
+func logMessage(line []byte) string {
+	var b strings.Builder
+	digits := false
+	for _, c := range line {
+		if c >= '0' && c <= '9' {
+			if !digits {
+				b.WriteByte('N')
+			}
+			digits = true
+			continue
+		}
+		digits = false
+		b.WriteByte(c)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// files returns the log file at path and, if the log was truncated, the file
+// of its most frequent messages.
+func (s *logSampler) files(logPath string, err error) []*File {
+	if !s.truncated {
+		return []*File{{Path: logPath, Data: s.full, Err: err}}
+	}
+
+	f := &File{Path: logPath, Data: joinLogSegments(s.head, s.tail, s.total), Err: err}
+	if s.opts.TopMessages <= 0 {
+		return []*File{f}
+	}
+	if len(s.line) > 0 {
+		s.countLine()
+	}
+	topPath := strings.TrimSuffix(logPath, path.Ext(logPath)) + "-top.txt"
+	return []*File{f, {Path: topPath, Data: s.topMessages()}}
+}
+
+// topMessages lists the most frequent messages of the log and how often they
+// occur.
+func (s *logSampler) topMessages() []byte {
+	messages := make([]string, 0, len(s.counts))
+	for m := range s.counts {
+		messages = append(messages, m)
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		if ci, cj := s.counts[messages[i]], s.counts[messages[j]]; ci != cj {
+			return ci > cj
+		}
+		return messages[i] < messages[j]
+	})
+	if len(messages) > s.opts.TopMessages {
+		messages = messages[:s.opts.TopMessages]
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "The %d most frequent of %d lines (%d distinct messages), with numbers replaced by N:\n\n", len(messages), s.lines, len(s.counts))
+	fmt.Fprintf(&b, "%10s  %s\n", "count", "message")
+	for _, m := range messages {
+		fmt.Fprintf(&b, "%10d  %s\n", s.counts[m], m)
+	}
+	if s.other > 0 {
+		fmt.Fprintf(&b, "%10d  (lines with messages beyond the first %d distinct ones)\n", s.other, maxLogMessages)
+	}
+	return b.Bytes()
+}
+
+// truncateLog shortens data to at most max bytes, plus a marker line, by
+// cutting out its middle. The cut is moved to line boundaries where possible,
+// so that no partial lines remain.
+func truncateLog(data []byte, max int) []byte {
+	if max <= 0 || len(data) <= max {
+		return data
+	}
+	return joinLogSegments(data[:max/2], data[len(data)-(max-max/2):], len(data))
+}
+
+// joinLogSegments joins the head and tail of a log of total bytes with a
+// marker line that says how much was cut out between them. The cuts are moved
+// to line boundaries where possible, so that no partial lines remain.
+func joinLogSegments(head, tail []byte, total int) []byte {
+	if i := bytes.LastIndexByte(head, '\n'); i >= 0 {
+		head = head[:i+1]
+	}
+	if i := bytes.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+		tail = tail[i+1:]
+	}
+
+	omitted := total - len(head) - len(tail)
+	marker := fmt.Sprintf("[... %d bytes omitted by src debug ...]\n", omitted)
+	if len(head) > 0 && head[len(head)-1] != '\n' {
+		marker = "\n" + marker
+	}
+
+	out := make([]byte, 0, len(head)+len(marker)+len(tail))
+	out = append(out, head...)
+	out = append(out, marker...)
+	return append(out, tail...)
+}
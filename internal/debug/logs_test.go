@@ -0,0 +1,129 @@
+package debug
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTruncateLog(t *testing.T) {
+	for name, tc := range map[string]struct {
+		data string
+		max  int
+		want string
+	}{
+		"no limit": {
+			data: "a\nb\nc\n",
+			max:  0,
+			want: "a\nb\nc\n",
+		},
+		"below limit": {
+			data: "a\nb\nc\n",
+			max:  6,
+			want: "a\nb\nc\n",
+		},
+		"line boundaries": {
+			data: "first\nsecond\nthird\nfourth\nfifth\n",
+			max:  16,
+			want: "first\n[... 20 bytes omitted by src debug ...]\nfifth\n",
+		},
+		"single line": {
+			data: strings.Repeat("x", 20),
+			max:  10,
+			want: "xxxxx\n[... 10 bytes omitted by src debug ...]\nxxxxx",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if have := string(truncateLog([]byte(tc.data), tc.max)); have != tc.want {
+				t.Errorf("wrong result:\nhave=%q\nwant=%q", have, tc.want)
+			}
+		})
+	}
+}
+
+func TestLogSampler(t *testing.T) {
+	var log strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&log, "2021-10-01T12:00:%02dZ request %d failed\n", i%60, i)
+		if i%10 == 0 {
+			fmt.Fprintf(&log, "2021-10-01T12:00:%02dZ cache miss\n", i%60)
+		}
+	}
+	data := log.String()
+
+	// sample writes data in chunks that don't align with lines, like a
+	// command's output.
+	sample := func(opts LogOptions) []*File {
+		s := newLogSampler(opts)
+		for rest := data; len(rest) > 0; {
+			n := 7
+			if n > len(rest) {
+				n = len(rest)
+			}
+			s.Write([]byte(rest[:n]))
+			rest = rest[n:]
+		}
+		return s.files("pods/frontend-0/logs.txt", nil)
+	}
+
+	t.Run("below limit", func(t *testing.T) {
+		files := sample(LogOptions{MaxBytes: len(data), TopMessages: 5})
+		if len(files) != 1 || string(files[0].Data) != data {
+			t.Errorf("log was modified: %d files", len(files))
+		}
+	})
+
+	t.Run("head-tail", func(t *testing.T) {
+		files := sample(LogOptions{MaxBytes: 200})
+		if len(files) != 1 {
+			t.Fatalf("wrong number of files: %d", len(files))
+		}
+		if have, want := string(files[0].Data), string(truncateLog([]byte(data), 200)); have != want {
+			t.Errorf("wrong log:\nhave=%q\nwant=%q", have, want)
+		}
+	})
+
+	t.Run("head", func(t *testing.T) {
+		have := string(sample(LogOptions{MaxBytes: 200, Policy: LogPolicyHead})[0].Data)
+		if !strings.HasPrefix(have, "2021-10-01T12:00:00Z request 0 failed\n") || !strings.HasSuffix(have, "bytes omitted by src debug ...]\n") {
+			t.Errorf("wrong log: %q", have)
+		}
+	})
+
+	t.Run("tail", func(t *testing.T) {
+		have := string(sample(LogOptions{MaxBytes: 200, Policy: LogPolicyTail})[0].Data)
+		if !strings.HasPrefix(have, "[... ") || !strings.HasSuffix(have, "request 99 failed\n") {
+			t.Errorf("wrong log: %q", have)
+		}
+	})
+
+	t.Run("top messages", func(t *testing.T) {
+		files := sample(LogOptions{MaxBytes: 200, TopMessages: 5})
+		if len(files) != 2 {
+			t.Fatalf("wrong number of files: %d", len(files))
+		}
+		if files[1].Path != "pods/frontend-0/logs-top.txt" {
+			t.Errorf("wrong path: %q", files[1].Path)
+		}
+		want := `The 2 most frequent of 110 lines (2 distinct messages), with numbers replaced by N:
+
+     count  message
+       100  N-N-NTN:N:NZ request N failed
+        10  N-N-NTN:N:NZ cache miss
+`
+		if have := string(files[1].Data); have != want {
+			t.Errorf("wrong top messages:\nhave=%q\nwant=%q", have, want)
+		}
+	})
+}
+
+func TestParseLogPolicy(t *testing.T) {
+	for _, want := range LogPolicies {
+		if have, err := ParseLogPolicy(string(want)); err != nil || have != want {
+			t.Errorf("wrong policy for %q: have=%q err=%v", want, have, err)
+		}
+	}
+	if _, err := ParseLogPolicy("middle"); err == nil {
+		t.Error("unexpected nil error for unknown policy")
+	}
+}
@@ -0,0 +1,152 @@
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// The OpenShift resources are given with their API groups, so that they are
+// found by plain kubectl as well as by oc, and can't be confused with the
+// resources of other operators that use the same names.
+const (
+	openShiftRouteResources       = "routes.route.openshift.io"
+	openShiftImageStreamResources = "imagestreams.image.openshift.io"
+	openShiftSCCResources         = "securitycontextconstraints.security.openshift.io"
+	openShiftClusterVersions      = "clusterversions.config.openshift.io"
+)
+
+// openShiftSCCAnnotation is set by OpenShift on every pod to the name of the
+// security context constraints that admitted it.
+const openShiftSCCAnnotation = "openshift.io/scc"
+
+// openShiftFiles collects the OpenShift resources that commonly break
+// Sourcegraph deployments on OpenShift, and that KubeFiles doesn't collect:
+// the routes and image streams in the namespace, the security context
+// constraints that apply to its service accounts and pods, and, with
+// ProfileStandard, the version of the cluster.
+func openShiftFiles(ctx context.Context, target KubeTarget, profile Profile) []*File {
+	kubectl := func(path string, args ...string) *File {
+		return CommandFile(ctx, path, target.Kubectl.name(), target.kubectlArgs(args...)...)
+	}
+
+	files := []*File{
+		kubectl("openshift/routes.yaml", "get", openShiftRouteResources, "--output", "yaml"),
+		kubectl("openshift/imagestreams.txt", "get", openShiftImageStreamResources, "--output", "wide"),
+	}
+	if profile >= ProfileStandard {
+		files = append(files,
+			kubectl("openshift/imagestreams.yaml", "get", openShiftImageStreamResources, "--output", "yaml"),
+			kubectl("openshift/clusterversion.yaml", "get", openShiftClusterVersions, "--output", "yaml"),
+		)
+	}
+	return append(files,
+		kubectl("openshift/pod-sccs.txt", "get", "pods", "--output",
+			"custom-columns=NAME:.metadata.name,SERVICE ACCOUNT:.spec.serviceAccountName,SCC:.metadata.annotations.openshift\\.io/scc"),
+		openShiftSCCsFile(ctx, target),
+	)
+}
+
+// openShiftSCCsFile returns the security context constraints that are
+// relevant to the Sourcegraph deployment as JSON: those that admitted one of
+// the pods in the namespace, and those that are granted to its service
+// accounts directly or through the service account groups. SCCs granted
+// through RBAC roles are only included if they admitted a pod.
+func openShiftSCCsFile(ctx context.Context, target KubeTarget) *File {
+	const path = "openshift/sccs.json"
+
+	accounts := CommandFile(ctx, path, target.Kubectl.name(), target.kubectlArgs("get", "serviceaccounts", "--output", "json")...)
+	if accounts.Err != nil {
+		return &File{Path: path, Err: errors.Wrap(accounts.Err, "listing service accounts")}
+	}
+	pods := CommandFile(ctx, path, target.Kubectl.name(), target.kubectlArgs("get", "pods", "--output", "json")...)
+	if pods.Err != nil {
+		return &File{Path: path, Err: errors.Wrap(pods.Err, "listing pods")}
+	}
+	sccs := CommandFile(ctx, path, target.Kubectl.name(), target.kubectlArgs("get", openShiftSCCResources, "--output", "json")...)
+	if sccs.Err != nil {
+		return &File{Path: path, Err: sccs.Err}
+	}
+
+	data, err := filterOpenShiftSCCs(sccs.Data, accounts.Data, pods.Data)
+	if err != nil {
+		return &File{Path: path, Err: err}
+	}
+	return &File{Path: path, Data: data}
+}
+
+// kubeList is the part of the JSON output of kubectl get that the OpenShift
+// collectors need.
+type kubeList struct {
+	Items []struct {
+		Metadata struct {
+			Name        string            `json:"name"`
+			Namespace   string            `json:"namespace"`
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	} `json:"items"`
+}
+
+// filterOpenShiftSCCs returns the security context constraints in the JSON
+// output of kubectl get securitycontextconstraints that admitted one of the
+// pods, or that list one of the service accounts, or a group containing
+// them, as users or groups. The SCCs are returned in their original order.
+func filterOpenShiftSCCs(sccData, accountData, podData []byte) ([]byte, error) {
+	var accounts, pods kubeList
+	if err := json.Unmarshal(accountData, &accounts); err != nil {
+		return nil, errors.Wrap(err, "parsing service accounts")
+	}
+	if err := json.Unmarshal(podData, &pods); err != nil {
+		return nil, errors.Wrap(err, "parsing pods")
+	}
+	var sccs struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+	if err := json.Unmarshal(sccData, &sccs); err != nil {
+		return nil, errors.Wrap(err, "parsing security context constraints")
+	}
+
+	// The names OpenShift grants SCCs to: the service accounts themselves, the
+	// group of the service accounts in their namespace, and the groups that
+	// contain all service accounts.
+	subjects := map[string]bool{
+		"system:serviceaccounts": true,
+		"system:authenticated":   true,
+	}
+	for _, sa := range accounts.Items {
+		subjects["system:serviceaccount:"+sa.Metadata.Namespace+":"+sa.Metadata.Name] = true
+		subjects["system:serviceaccounts:"+sa.Metadata.Namespace] = true
+	}
+	admitted := map[string]bool{}
+	for _, pod := range pods.Items {
+		if scc := pod.Metadata.Annotations[openShiftSCCAnnotation]; scc != "" {
+			admitted[scc] = true
+		}
+	}
+
+	relevant := []map[string]interface{}{}
+	for _, scc := range sccs.Items {
+		metadata, _ := scc["metadata"].(map[string]interface{})
+		name, _ := metadata["name"].(string)
+		if admitted[name] || grantsAny(scc["users"], subjects) || grantsAny(scc["groups"], subjects) {
+			delete(metadata, "managedFields")
+			relevant = append(relevant, scc)
+		}
+	}
+
+	return json.MarshalIndent(map[string]interface{}{"items": relevant}, "", "  ")
+}
+
+// grantsAny returns whether the users or groups of an SCC contain one of the
+// subjects.
+func grantsAny(names interface{}, subjects map[string]bool) bool {
+	list, _ := names.([]interface{})
+	for _, n := range list {
+		if s, ok := n.(string); ok && subjects[strings.TrimSpace(s)] {
+			return true
+		}
+	}
+	return false
+}
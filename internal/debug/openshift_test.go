@@ -0,0 +1,96 @@
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/sourcegraph/src-cli/internal/exec/expect"
+)
+
+func TestKubeFilesOpenShift(t *testing.T) {
+	kubectl := func(b expect.Behaviour, args ...string) *expect.Expectation {
+		return expect.NewGlob(b, "oc", append([]string{"--namespace", "sg"}, args...)...)
+	}
+	expect.Commands(t,
+		kubectl(expect.Success, "version"),
+		kubectl(expect.Success, "get", "pods", "--output", "wide"),
+		kubectl(expect.Success, "get", "services", "--output", "wide"),
+		kubectl(expect.Success, "get", "events", "--sort-by", ".lastTimestamp"),
+		kubectl(expect.Success, "get", "routes.route.openshift.io", "--output", "yaml"),
+		kubectl(expect.Success, "get", "imagestreams.image.openshift.io", "--output", "wide"),
+		kubectl(expect.Success, "get", "pods", "--output", "custom-columns=*"),
+		kubectl(expect.Behaviour{Stdout: []byte(`{"items": [{"metadata": {"name": "sourcegraph", "namespace": "sg"}}]}`)}, "get", "serviceaccounts", "--output", "json"),
+		kubectl(expect.Behaviour{Stdout: []byte(`{"items": []}`)}, "get", "pods", "--output", "json"),
+		kubectl(expect.Behaviour{Stdout: []byte(`{"items": [{"metadata": {"name": "anyuid"}, "users": ["system:serviceaccount:sg:sourcegraph"]}]}`)}, "get", "securitycontextconstraints.security.openshift.io", "--output", "json"),
+		kubectl(expect.Success, "get", "pods", "--output", "jsonpath=*"),
+	)
+
+	files := KubeFiles(context.Background(), KubeTarget{Kubectl: Kubectl{Path: "oc"}, Namespace: "sg", OpenShift: true}, ProfileMinimal, LogOptions{})
+
+	var sccs *File
+	for _, f := range files {
+		if f.Path == "openshift/sccs.json" {
+			sccs = f
+		}
+	}
+	if sccs == nil || sccs.Err != nil {
+		t.Fatalf("security context constraints not collected: %+v", sccs)
+	}
+	if have := sccNames(t, sccs.Data); !cmp.Equal(have, []string{"anyuid"}) {
+		t.Errorf("wrong security context constraints: %v", have)
+	}
+}
+
+func TestFilterOpenShiftSCCs(t *testing.T) {
+	accounts := []byte(`{"items": [
+		{"metadata": {"name": "sourcegraph", "namespace": "sg"}},
+		{"metadata": {"name": "default", "namespace": "sg"}}
+	]}`)
+	pods := []byte(`{"items": [
+		{"metadata": {"name": "frontend-0", "annotations": {"openshift.io/scc": "restricted-v2"}}},
+		{"metadata": {"name": "migrator"}}
+	]}`)
+	sccs := []byte(`{"items": [
+		{"metadata": {"name": "anyuid", "managedFields": [{"manager": "oc"}]}, "users": ["system:serviceaccount:sg:sourcegraph"]},
+		{"metadata": {"name": "hostaccess"}, "users": ["system:serviceaccount:other:sourcegraph"]},
+		{"metadata": {"name": "nonroot"}, "groups": ["system:serviceaccounts:sg"]},
+		{"metadata": {"name": "privileged"}, "users": ["system:admin"], "groups": ["system:cluster-admins"]},
+		{"metadata": {"name": "restricted-v2"}, "users": [], "groups": null},
+		{"metadata": {"name": "restricted"}, "groups": ["system:authenticated"]}
+	]}`)
+
+	out, err := filterOpenShiftSCCs(sccs, accounts, pods)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"anyuid", "nonroot", "restricted-v2", "restricted"}
+	if diff := cmp.Diff(want, sccNames(t, out)); diff != "" {
+		t.Errorf("wrong security context constraints (-want +have):\n%s", diff)
+	}
+
+	if strings.Contains(string(out), "managedFields") {
+		t.Errorf("managed fields not dropped:\n%s", out)
+	}
+
+	if out, err := filterOpenShiftSCCs([]byte(`{"items": []}`), accounts, pods); err != nil || string(out) != "{\n  \"items\": []\n}" {
+		t.Errorf("wrong output without security context constraints: %q, %v", out, err)
+	}
+}
+
+func sccNames(t *testing.T, data []byte) []string {
+	t.Helper()
+
+	var list kubeList
+	if err := json.Unmarshal(data, &list); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, item := range list.Items {
+		names = append(names, item.Metadata.Name)
+	}
+	return names
+}
@@ -0,0 +1,55 @@
+package debug
+
+import (
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Profile selects how much the collectors gather, so that a debug archive
+// can be as light or as heavy as the investigation needs. Each profile
+// collects everything of the profiles below it.
+type Profile int
+
+const (
+	// ProfileMinimal only describes the deployment and includes the recent
+	// container logs.
+	ProfileMinimal Profile = iota
+	// ProfileStandard adds resource usage, disk usage and all logs. It's the
+	// default.
+	ProfileStandard
+	// ProfileFull adds everything else: node and database details, and
+	// traces.
+	ProfileFull
+)
+
+// MinimalLogsSince is how far back container logs are collected with
+// ProfileMinimal, unless a different window is given.
+const MinimalLogsSince = time.Hour
+
+var profileNames = map[Profile]string{
+	ProfileMinimal:  "minimal",
+	ProfileStandard: "standard",
+	ProfileFull:     "full",
+}
+
+func (p Profile) String() string {
+	return profileNames[p]
+}
+
+// ParseProfile returns the profile of the given name.
+func ParseProfile(name string) (Profile, error) {
+	for p, n := range profileNames {
+		if n == name {
+			return p, nil
+		}
+	}
+	return ProfileStandard, errors.Newf("unknown profile %q, must be one of %s", name, strings.Join(ProfileNames(), ", "))
+}
+
+// ProfileNames returns the names of the profiles, from the lightest to the
+// heaviest.
+func ProfileNames() []string {
+	return []string{ProfileMinimal.String(), ProfileStandard.String(), ProfileFull.String()}
+}
@@ -0,0 +1,19 @@
+package debug
+
+import "testing"
+
+func TestParseProfile(t *testing.T) {
+	for _, want := range []Profile{ProfileMinimal, ProfileStandard, ProfileFull} {
+		have, err := ParseProfile(want.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have != want {
+			t.Errorf("wrong profile for %q: have=%v", want, have)
+		}
+	}
+
+	if _, err := ParseProfile("maximal"); err == nil {
+		t.Error("unexpected nil error for unknown profile")
+	}
+}
@@ -0,0 +1,87 @@
+package debug
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/exec"
+)
+
+// servImage is the image of single-container Sourcegraph deployments.
+const servImage = "sourcegraph/server"
+
+// postgresStatusQuery is run against the Postgres database that is embedded in
+// sourcegraph/server.
+const postgresStatusQuery = `SELECT version();
+SELECT datname, numbackends, pg_size_pretty(pg_database_size(datname)) AS size FROM pg_stat_database WHERE datname IS NOT NULL;`
+
+// FindServContainer returns the name of the running sourcegraph/server
+// container. It fails if there is none, or more than one.
+func FindServContainer(ctx context.Context) (string, error) {
+	out, err := exec.CommandContext(ctx, "docker", "container", "ls", "--format", "{{.Names}} {{.Image}}").Output()
+	if err != nil {
+		return "", errors.Wrap(err, "listing Docker containers")
+	}
+
+	var names []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if image := fields[1]; image == servImage || strings.HasPrefix(image, servImage+":") || strings.HasPrefix(image, servImage+"@") {
+			names = append(names, fields[0])
+		}
+	}
+
+	switch len(names) {
+	case 0:
+		return "", errors.Newf("no running %s container found", servImage)
+	case 1:
+		return names[0], nil
+	default:
+		return "", errors.Newf("found multiple %s containers (%s), specify one with -container", servImage, strings.Join(names, ", "))
+	}
+}
+
+// postgresDetailsQuery is run against the sourcegraph database that is
+// embedded in sourcegraph/server with ProfileFull.
+const postgresDetailsQuery = `SELECT pid, usename, state, wait_event_type, wait_event, now() - query_start AS duration, left(query, 200) AS query FROM pg_stat_activity WHERE datname IS NOT NULL ORDER BY query_start;
+SELECT relname, pg_size_pretty(pg_total_relation_size(relid)) AS size FROM pg_catalog.pg_statio_user_tables ORDER BY pg_total_relation_size(relid) DESC LIMIT 25;`
+
+// ServFiles collects the files for a debug archive of a single-container
+// sourcegraph/server deployment. With ProfileMinimal, this is the state of the
+// container as seen by Docker and its logs. ProfileStandard adds its resource
+// usage, the disk usage of /var/opt/sourcegraph, the logs in /var/log as a
+// tarball, and the status of the embedded Postgres database. ProfileFull adds
+// the activity and the largest tables of the database.
+func ServFiles(ctx context.Context, container string, profile Profile, logOpts LogOptions) []*File {
+	logsArgs := append(append([]string{"container", "logs"}, logOpts.dockerArgs()...), container)
+
+	files := []*File{
+		CommandFile(ctx, "docker/containers.txt", "docker", "container", "ls", "--all"),
+		CommandFile(ctx, "docker/inspect.json", "docker", "container", "inspect", container),
+	}
+	if profile >= ProfileStandard {
+		files = append(files, CommandFile(ctx, "docker/stats.txt", "docker", "container", "stats", "--no-stream", container))
+	}
+	files = append(files, logOpts.commandFiles(ctx, "docker/logs.txt", true, "docker", logsArgs...)...)
+	if profile < ProfileStandard {
+		return files
+	}
+
+	files = append(files,
+		CommandFile(ctx, "server/disk-usage.txt", "docker", "container", "exec", container, "du", "-h", "-d", "1", "/var/opt/sourcegraph"),
+		CommandFile(ctx, "server/var-log.tar", "docker", "container", "cp", container+":/var/log", "-"),
+		CommandFile(ctx, "server/postgres.txt", "docker", "container", "exec", "--user", "postgres", container, "psql", "--command", postgresStatusQuery),
+	)
+	if profile >= ProfileFull {
+		files = append(files, CommandFile(ctx, "server/postgres-details.txt", "docker", "container", "exec", "--user", "postgres", container, "psql", "--dbname", "sourcegraph", "--command", postgresDetailsQuery))
+	}
+	return files
+}
@@ -0,0 +1,153 @@
+package debug
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/src-cli/internal/exec/expect"
+)
+
+func TestFindServContainer(t *testing.T) {
+	ctx := context.Background()
+
+	for name, tc := range map[string]struct {
+		stdout  string
+		want    string
+		wantErr bool
+	}{
+		"single container": {
+			stdout: "sg sourcegraph/server:3.33.0\nredis redis:6\n",
+			want:   "sg",
+		},
+		"untagged image": {
+			stdout: "sg sourcegraph/server\n",
+			want:   "sg",
+		},
+		"no container": {
+			stdout:  "redis redis:6\nsg sourcegraph/server-other:1\n",
+			wantErr: true,
+		},
+		"multiple containers": {
+			stdout:  "a sourcegraph/server:3.33.0\nb sourcegraph/server:3.32.0\n",
+			wantErr: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			expect.Commands(t, expect.NewGlob(
+				expect.Behaviour{Stdout: []byte(tc.stdout)},
+				"docker", "container", "ls", "--format", `\{\{.Names}} \{\{.Image}}`,
+			))
+
+			have, err := FindServContainer(ctx)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("unexpected nil error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %+v", err)
+			}
+			if have != tc.want {
+				t.Errorf("wrong container: have=%q want=%q", have, tc.want)
+			}
+		})
+	}
+}
+
+func TestServFiles(t *testing.T) {
+	expect.Commands(t,
+		expect.NewGlob(expect.Behaviour{Stdout: []byte("CONTAINER ID\n")}, "docker", "container", "ls", "--all"),
+		expect.NewGlob(expect.Behaviour{Stdout: []byte("[]")}, "docker", "container", "inspect", "sg"),
+		expect.NewGlob(expect.Success, "docker", "container", "stats", "--no-stream", "sg"),
+		expect.NewGlob(expect.Behaviour{Stdout: []byte("out\n"), Stderr: []byte("err\n")}, "docker", "container", "logs", "sg"),
+		expect.NewGlob(expect.Behaviour{Stdout: []byte("1G\t/var/opt/sourcegraph\n")}, "docker", "container", "exec", "sg", "du", "-h", "-d", "1", "/var/opt/sourcegraph"),
+		expect.NewGlob(expect.Behaviour{ExitCode: 1, Stderr: []byte("no such file")}, "docker", "container", "cp", "sg:/var/log", "-"),
+		expect.NewGlob(expect.Success, "docker", "container", "exec", "--user", "postgres", "sg", "psql", "--command", "*"),
+	)
+
+	files := ServFiles(context.Background(), "sg", ProfileStandard, LogOptions{})
+
+	byPath := make(map[string]*File, len(files))
+	for _, f := range files {
+		byPath[f.Path] = f
+	}
+
+	if have := string(byPath["docker/inspect.json"].Data); have != "[]" {
+		t.Errorf("wrong inspect output: %q", have)
+	}
+	if have := string(byPath["docker/logs.txt"].Data); !strings.Contains(have, "out\n") || !strings.Contains(have, "err\n") {
+		t.Errorf("logs don't contain stdout and stderr: %q", have)
+	}
+	if f := byPath["server/var-log.tar"]; f.Err == nil {
+		t.Error("unexpected nil error for failed command")
+	}
+	if f := byPath["server/disk-usage.txt"]; f.Err != nil {
+		t.Errorf("unexpected error: %+v", f.Err)
+	}
+}
+
+func TestServFilesLogOptions(t *testing.T) {
+	expect.Commands(t,
+		expect.NewGlob(expect.Success, "docker", "container", "ls", "--all"),
+		expect.NewGlob(expect.Success, "docker", "container", "inspect", "sg"),
+		expect.NewGlob(expect.Success, "docker", "container", "stats", "--no-stream", "sg"),
+		expect.NewGlob(expect.Behaviour{Stdout: []byte(strings.Repeat("line\n", 100))}, "docker", "container", "logs", "--since", "2h0m0s", "--timestamps", "sg"),
+		expect.NewGlob(expect.Success, "docker", "container", "exec", "sg", "du", "-h", "-d", "1", "/var/opt/sourcegraph"),
+		expect.NewGlob(expect.Success, "docker", "container", "cp", "sg:/var/log", "-"),
+		expect.NewGlob(expect.Success, "docker", "container", "exec", "--user", "postgres", "sg", "psql", "--command", "*"),
+	)
+
+	files := ServFiles(context.Background(), "sg", ProfileStandard, LogOptions{
+		Since:      2 * time.Hour,
+		Timestamps: true,
+		MaxBytes:   50,
+	})
+
+	for _, f := range files {
+		if f.Path != "docker/logs.txt" {
+			continue
+		}
+		if f.Err != nil {
+			t.Fatalf("unexpected error: %+v", f.Err)
+		}
+		if !strings.Contains(string(f.Data), "bytes omitted") {
+			t.Errorf("logs not truncated: %q", f.Data)
+		}
+	}
+}
+
+func TestServFilesProfiles(t *testing.T) {
+	t.Run("minimal", func(t *testing.T) {
+		expect.Commands(t,
+			expect.NewGlob(expect.Success, "docker", "container", "ls", "--all"),
+			expect.NewGlob(expect.Success, "docker", "container", "inspect", "sg"),
+			expect.NewGlob(expect.Success, "docker", "container", "logs", "--since", "1h0m0s", "sg"),
+		)
+
+		files := ServFiles(context.Background(), "sg", ProfileMinimal, LogOptions{Since: MinimalLogsSince})
+		if len(files) != 3 {
+			t.Errorf("wrong number of files: %d", len(files))
+		}
+	})
+
+	t.Run("full", func(t *testing.T) {
+		expect.Commands(t,
+			expect.NewGlob(expect.Success, "docker", "container", "ls", "--all"),
+			expect.NewGlob(expect.Success, "docker", "container", "inspect", "sg"),
+			expect.NewGlob(expect.Success, "docker", "container", "stats", "--no-stream", "sg"),
+			expect.NewGlob(expect.Success, "docker", "container", "logs", "sg"),
+			expect.NewGlob(expect.Success, "docker", "container", "exec", "sg", "du", "-h", "-d", "1", "/var/opt/sourcegraph"),
+			expect.NewGlob(expect.Success, "docker", "container", "cp", "sg:/var/log", "-"),
+			expect.NewGlob(expect.Success, "docker", "container", "exec", "--user", "postgres", "sg", "psql", "--command", "*"),
+			expect.NewGlob(expect.Behaviour{Stdout: []byte("repo | 10 GB\n")}, "docker", "container", "exec", "--user", "postgres", "sg", "psql", "--dbname", "sourcegraph", "--command", "*"),
+		)
+
+		files := ServFiles(context.Background(), "sg", ProfileFull, LogOptions{})
+		if have := string(files[len(files)-1].Data); have != "repo | 10 GB\n" {
+			t.Errorf("wrong database details: %q", have)
+		}
+	})
+}
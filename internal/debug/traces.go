@@ -0,0 +1,135 @@
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+)
+
+// jaegerPath is the path of the Jaeger UI that Sourcegraph proxies for site
+// admins.
+const jaegerPath = "-/debug/jaeger"
+
+// TraceOptions control which traces are collected from Jaeger.
+type TraceOptions struct {
+	// Service is the Jaeger service whose traces are collected.
+	Service string
+	// Since limits the traces to those started in the given duration before
+	// collection.
+	Since time.Duration
+	// MinDuration is the minimum duration of collected traces.
+	MinDuration time.Duration
+	// Limit is the number of traces to collect. The slowest traces are kept.
+	Limit int
+}
+
+// jaegerTrace is a trace as returned by the Jaeger query API. Only the fields
+// needed to order traces are decoded, the trace itself is archived as is.
+type jaegerTrace struct {
+	raw   json.RawMessage
+	Spans []struct {
+		StartTime int64 `json:"startTime"`
+		Duration  int64 `json:"duration"`
+	} `json:"spans"`
+}
+
+// duration returns the duration of the trace in microseconds, from the start
+// of its first span to the end of its last one.
+func (t *jaegerTrace) duration() int64 {
+	var start, end int64
+	for i, s := range t.Spans {
+		if i == 0 || s.StartTime < start {
+			start = s.StartTime
+		}
+		if e := s.StartTime + s.Duration; e > end {
+			end = e
+		}
+	}
+	return end - start
+}
+
+// TraceFile returns a File containing the slowest recent traces of a service,
+// as recorded by the Jaeger instance of the deployment. The File uses the
+// format of the Jaeger query API, so that it can be loaded into the Jaeger UI.
+func TraceFile(ctx context.Context, client api.Client, opts TraceOptions) *File {
+	f := &File{Path: fmt.Sprintf("traces/%s.json", opts.Service)}
+
+	traces, err := queryJaegerTraces(ctx, client, opts, time.Now())
+	if err != nil {
+		f.Err = err
+		return f
+	}
+
+	sort.SliceStable(traces, func(i, j int) bool {
+		return traces[i].duration() > traces[j].duration()
+	})
+	if len(traces) > opts.Limit {
+		traces = traces[:opts.Limit]
+	}
+
+	data := make([]json.RawMessage, len(traces))
+	for i, t := range traces {
+		data[i] = t.raw
+	}
+	if f.Data, err = json.MarshalIndent(map[string]interface{}{"data": data}, "", "  "); err != nil {
+		f.Err = errors.Wrap(err, "encoding traces")
+	}
+	return f
+}
+
+// jaegerSearchLimit is the number of traces requested from Jaeger, out of
+// which the slowest are kept. Jaeger doesn't sort traces by duration itself.
+const jaegerSearchLimit = 1000
+
+func queryJaegerTraces(ctx context.Context, client api.Client, opts TraceOptions, now time.Time) ([]*jaegerTrace, error) {
+	q := url.Values{}
+	q.Set("service", opts.Service)
+	q.Set("start", strconv.FormatInt(now.Add(-opts.Since).UnixNano()/int64(time.Microsecond), 10))
+	q.Set("end", strconv.FormatInt(now.UnixNano()/int64(time.Microsecond), 10))
+	q.Set("limit", strconv.Itoa(jaegerSearchLimit))
+	if opts.MinDuration > 0 {
+		q.Set("minDuration", opts.MinDuration.String())
+	}
+
+	req, err := client.NewHTTPRequest(ctx, "GET", jaegerPath+"/api/traces?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying Jaeger")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, errors.Newf("querying Jaeger: unexpected status %s: %s", resp.Status, body)
+	}
+
+	var result struct {
+		Data []json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, errors.Wrap(err, "decoding Jaeger response")
+	}
+
+	traces := make([]*jaegerTrace, 0, len(result.Data))
+	for _, raw := range result.Data {
+		t := &jaegerTrace{raw: raw}
+		if err := json.Unmarshal(raw, t); err != nil {
+			return nil, errors.Wrap(err, "decoding Jaeger trace")
+		}
+		traces = append(traces, t)
+	}
+	return traces, nil
+}
@@ -0,0 +1,79 @@
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+)
+
+func TestTraceFile(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/-/debug/jaeger/api/traces" {
+			http.NotFound(w, r)
+			return
+		}
+		if have := r.URL.Query().Get("service"); have != "frontend" {
+			t.Errorf("wrong service: %q", have)
+		}
+		if have := r.URL.Query().Get("minDuration"); have != "1s" {
+			t.Errorf("wrong minDuration: %q", have)
+		}
+		io.WriteString(w, `{"data": [
+			{"traceID": "fast", "spans": [{"startTime": 100, "duration": 10}]},
+			{"traceID": "slow", "spans": [{"startTime": 100, "duration": 10}, {"startTime": 150, "duration": 500}]},
+			{"traceID": "medium", "spans": [{"startTime": 100, "duration": 200}]}
+		]}`)
+	}))
+	defer ts.Close()
+
+	client := api.NewClient(api.ClientOpts{Endpoint: ts.URL, Out: io.Discard})
+	f := TraceFile(context.Background(), client, TraceOptions{
+		Service:     "frontend",
+		Since:       time.Hour,
+		MinDuration: time.Second,
+		Limit:       2,
+	})
+	if f.Err != nil {
+		t.Fatalf("unexpected error: %+v", f.Err)
+	}
+	if f.Path != "traces/frontend.json" {
+		t.Errorf("wrong path: %q", f.Path)
+	}
+
+	var result struct {
+		Data []struct {
+			TraceID string `json:"traceID"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(f.Data, &result); err != nil {
+		t.Fatal(err)
+	}
+	var have []string
+	for _, trace := range result.Data {
+		have = append(have, trace.TraceID)
+	}
+	if diff := cmp.Diff([]string{"slow", "medium"}, have); diff != "" {
+		t.Errorf("wrong traces (-want +have):\n%s", diff)
+	}
+}
+
+func TestTraceFileError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer ts.Close()
+
+	client := api.NewClient(api.ClientOpts{Endpoint: ts.URL, Out: io.Discard})
+	f := TraceFile(context.Background(), client, TraceOptions{Service: "frontend", Since: time.Hour, Limit: 10})
+	if f.Err == nil {
+		t.Error("unexpected nil error")
+	}
+}
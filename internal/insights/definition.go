@@ -0,0 +1,199 @@
+// Package insights defines search-based code insights, so that they can be
+// created from the command line or from definition files, and dashboards be
+// provisioned as code.
+package insights
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Definition is a search-based insight, which plots the number of results of
+// one or more search queries over time as lines.
+//
+//	title: Go versions
+//	repositories: [github.com/sourcegraph/src-cli]
+//	interval: 1w
+//	dashboards: [RGFzaGJvYXJkOjE=]
+//	series:
+//	  - label: Go 1.16
+//	    query: file:go.mod ^go 1.16
+//	  - label: Go 1.17
+//	    query: file:go.mod ^go 1.17
+//	    color: "#37b24d"
+type Definition struct {
+	Title string `yaml:"title"`
+	// Repositories are the repositories the queries are run in. If empty, the
+	// queries are run in all repositories.
+	Repositories []string `yaml:"repositories"`
+	// Interval is the time between the data points, such as 1w or 3mo. The
+	// default is DefaultInterval.
+	Interval string `yaml:"interval"`
+	// Dashboards are the IDs of the dashboards the insight is added to.
+	Dashboards []string `yaml:"dashboards"`
+	Series     []Series `yaml:"series"`
+}
+
+// Series is a line of an insight.
+type Series struct {
+	// Label defaults to the query.
+	Label string `yaml:"label"`
+	Query string `yaml:"query"`
+	// Color is the color of the line, as a CSS color. It defaults to a color
+	// of Palette.
+	Color string `yaml:"color"`
+}
+
+// DefaultInterval is the interval of insights that don't set one.
+const DefaultInterval = "1mo"
+
+// Palette are the colors of series that don't set one, in order.
+var Palette = []string{"#4263eb", "#f76707", "#37b24d", "#ae3ec9", "#1098ad", "#f03e3e", "#f59f00", "#495057"}
+
+// ParseDefinition parses a YAML insight definition. Unknown fields are errors.
+func ParseDefinition(data []byte) (*Definition, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	var def Definition
+	if err := dec.Decode(&def); err != nil {
+		return nil, errors.Wrap(err, "parsing insight definition")
+	}
+	return &def, nil
+}
+
+// Validate returns an error if the definition can't be created.
+func (d *Definition) Validate() error {
+	if strings.TrimSpace(d.Title) == "" {
+		return errors.New("title is required")
+	}
+	if len(d.Series) == 0 {
+		return errors.New("at least one series is required")
+	}
+	for i, s := range d.Series {
+		if strings.TrimSpace(s.Query) == "" {
+			return errors.Newf("series %d: query is required", i+1)
+		}
+	}
+	for _, repo := range d.Repositories {
+		if strings.TrimSpace(repo) == "" {
+			return errors.New("repositories must not be empty")
+		}
+	}
+	if _, err := d.interval(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Interval is the time between the data points of an insight.
+type Interval struct {
+	// Unit is one of HOUR, DAY, WEEK, MONTH, and YEAR.
+	Unit  string
+	Value int
+}
+
+var intervalUnits = map[string]string{
+	"h": "HOUR", "hour": "HOUR", "hours": "HOUR",
+	"d": "DAY", "day": "DAY", "days": "DAY",
+	"w": "WEEK", "week": "WEEK", "weeks": "WEEK",
+	"mo": "MONTH", "month": "MONTH", "months": "MONTH",
+	"y": "YEAR", "year": "YEAR", "years": "YEAR",
+}
+
+var intervalPattern = regexp.MustCompile(`^\s*(\d+)\s*([a-zA-Z]+)\s*$`)
+
+// ParseInterval parses an interval such as 1w, 3mo, or 2 days.
+func ParseInterval(s string) (Interval, error) {
+	m := intervalPattern.FindStringSubmatch(s)
+	if m == nil {
+		return Interval{}, errors.Newf("invalid interval %q: must be a number followed by a unit, such as 1w or 3mo", s)
+	}
+	unit, ok := intervalUnits[strings.ToLower(m[2])]
+	if !ok {
+		return Interval{}, errors.Newf("invalid interval %q: unknown unit %q, must be one of h, d, w, mo, and y", s, m[2])
+	}
+	value, err := strconv.Atoi(m[1])
+	if err != nil || value < 1 {
+		return Interval{}, errors.Newf("invalid interval %q: must be at least 1", s)
+	}
+	return Interval{Unit: unit, Value: value}, nil
+}
+
+func (d *Definition) interval() (Interval, error) {
+	if d.Interval == "" {
+		return ParseInterval(DefaultInterval)
+	}
+	return ParseInterval(d.Interval)
+}
+
+// Input returns the LineChartSearchInsightInput of the createLineChartSearchInsight
+// mutation that creates the insight. The definition must be valid.
+func (d *Definition) Input() (map[string]interface{}, error) {
+	interval, err := d.interval()
+	if err != nil {
+		return nil, err
+	}
+
+	repos := d.Repositories
+	if repos == nil {
+		repos = []string{}
+	}
+
+	series := make([]map[string]interface{}, len(d.Series))
+	for i, s := range d.Series {
+		label := s.Label
+		if label == "" {
+			label = s.Query
+		}
+		color := s.Color
+		if color == "" {
+			color = Palette[i%len(Palette)]
+		}
+
+		series[i] = map[string]interface{}{
+			"query": s.Query,
+			"options": map[string]interface{}{
+				"label":     label,
+				"lineColor": color,
+			},
+			"repositoryScope": map[string]interface{}{
+				"repositories": repos,
+			},
+			"timeScope": map[string]interface{}{
+				"stepInterval": map[string]interface{}{
+					"unit":  interval.Unit,
+					"value": interval.Value,
+				},
+			},
+		}
+	}
+
+	input := map[string]interface{}{
+		"options":    map[string]interface{}{"title": d.Title},
+		"dataSeries": series,
+	}
+	if len(d.Dashboards) > 0 {
+		input["dashboards"] = d.Dashboards
+	}
+	return input, nil
+}
+
+// String returns a summary of the insight, such as
+// "Go versions: 2 series in all repositories every 1 week".
+func (d *Definition) String() string {
+	scope := "all repositories"
+	if len(d.Repositories) == 1 {
+		scope = d.Repositories[0]
+	} else if len(d.Repositories) > 1 {
+		scope = fmt.Sprintf("%d repositories", len(d.Repositories))
+	}
+	interval, _ := d.interval()
+	return fmt.Sprintf("%s: %d series in %s every %d %s", d.Title, len(d.Series), scope, interval.Value, strings.ToLower(interval.Unit))
+}
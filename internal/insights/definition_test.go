@@ -0,0 +1,145 @@
+package insights
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseInterval(t *testing.T) {
+	for input, want := range map[string]Interval{
+		"1w":      {Unit: "WEEK", Value: 1},
+		"3mo":     {Unit: "MONTH", Value: 3},
+		"2 days":  {Unit: "DAY", Value: 2},
+		"12h":     {Unit: "HOUR", Value: 12},
+		"1 Year":  {Unit: "YEAR", Value: 1},
+		" 6 hour": {Unit: "HOUR", Value: 6},
+	} {
+		have, err := ParseInterval(input)
+		if err != nil {
+			t.Errorf("%q: %v", input, err)
+			continue
+		}
+		if have != want {
+			t.Errorf("%q: want %+v, have %+v", input, want, have)
+		}
+	}
+
+	for _, input := range []string{"", "w", "1", "0w", "1m", "1 fortnight", "-1w"} {
+		if _, err := ParseInterval(input); err == nil {
+			t.Errorf("%q: no error", input)
+		}
+	}
+}
+
+func TestParseDefinition(t *testing.T) {
+	def, err := ParseDefinition([]byte(`
+title: Go versions
+repositories: [github.com/sourcegraph/src-cli]
+interval: 1w
+dashboards: [RGFzaGJvYXJkOjE=]
+series:
+  - label: Go 1.16
+    query: file:go.mod ^go 1.16
+  - query: file:go.mod ^go 1.17
+    color: "#000000"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := def.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	have, err := def.Input()
+	if err != nil {
+		t.Fatal(err)
+	}
+	scope := map[string]interface{}{"repositories": []string{"github.com/sourcegraph/src-cli"}}
+	timeScope := map[string]interface{}{"stepInterval": map[string]interface{}{"unit": "WEEK", "value": 1}}
+	want := map[string]interface{}{
+		"options":    map[string]interface{}{"title": "Go versions"},
+		"dashboards": []string{"RGFzaGJvYXJkOjE="},
+		"dataSeries": []map[string]interface{}{
+			{
+				"query":           "file:go.mod ^go 1.16",
+				"options":         map[string]interface{}{"label": "Go 1.16", "lineColor": Palette[0]},
+				"repositoryScope": scope,
+				"timeScope":       timeScope,
+			},
+			{
+				"query":           "file:go.mod ^go 1.17",
+				"options":         map[string]interface{}{"label": "file:go.mod ^go 1.17", "lineColor": "#000000"},
+				"repositoryScope": scope,
+				"timeScope":       timeScope,
+			},
+		},
+	}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Errorf("wrong input (-want +have):\n%s", diff)
+	}
+
+	if want, have := "Go versions: 2 series in github.com/sourcegraph/src-cli every 1 week", def.String(); want != have {
+		t.Errorf("wrong summary: want %q, have %q", want, have)
+	}
+}
+
+func TestParseDefinition_UnknownField(t *testing.T) {
+	_, err := ParseDefinition([]byte("title: x\nseries:\n  - query: a\n    colour: red\n"))
+	if err == nil || !strings.Contains(err.Error(), "colour") {
+		t.Fatalf("want error about unknown field, have %v", err)
+	}
+}
+
+func TestDefinition_Validate(t *testing.T) {
+	for name, tc := range map[string]struct {
+		def     Definition
+		wantErr string
+	}{
+		"no title": {
+			def:     Definition{Series: []Series{{Query: "a"}}},
+			wantErr: "title is required",
+		},
+		"no series": {
+			def:     Definition{Title: "x"},
+			wantErr: "at least one series is required",
+		},
+		"no query": {
+			def:     Definition{Title: "x", Series: []Series{{Query: "a"}, {Label: "b"}}},
+			wantErr: "series 2: query is required",
+		},
+		"empty repository": {
+			def:     Definition{Title: "x", Series: []Series{{Query: "a"}}, Repositories: []string{""}},
+			wantErr: "repositories must not be empty",
+		},
+		"invalid interval": {
+			def:     Definition{Title: "x", Series: []Series{{Query: "a"}}, Interval: "soon"},
+			wantErr: `invalid interval "soon"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := tc.def.Validate()
+			if err == nil || !strings.HasPrefix(err.Error(), tc.wantErr) {
+				t.Errorf("want error %q, have %v", tc.wantErr, err)
+			}
+		})
+	}
+
+	// All repositories and the default interval.
+	def := Definition{Title: "x", Series: []Series{{Query: "a"}}}
+	input, err := def.Input()
+	if err != nil {
+		t.Fatal(err)
+	}
+	series := input["dataSeries"].([]map[string]interface{})[0]
+	if diff := cmp.Diff(map[string]interface{}{"repositories": []string{}}, series["repositoryScope"]); diff != "" {
+		t.Errorf("wrong repository scope (-want +have):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]interface{}{"stepInterval": map[string]interface{}{"unit": "MONTH", "value": 1}}, series["timeScope"]); diff != "" {
+		t.Errorf("wrong time scope (-want +have):\n%s", diff)
+	}
+	if _, ok := input["dashboards"]; ok {
+		t.Error("unexpected dashboards")
+	}
+}
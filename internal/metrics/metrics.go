@@ -0,0 +1,301 @@
+// Package metrics collects metrics of long-running src operations and serves
+// them in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Default is the registry that the metrics of src are registered with.
+var Default = NewRegistry()
+
+// DefaultBuckets are the upper bounds of the buckets of histograms of
+// durations in seconds, from 5ms to 10s.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// metric is a metric with all its series.
+type metric interface {
+	write(w io.Writer)
+}
+
+// Registry is a set of metrics, which it serves over HTTP.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: map[string]metric{}}
+}
+
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.metrics[name]; ok {
+		panic(fmt.Sprintf("metric %q registered twice", name))
+	}
+	r.metrics[name] = m
+}
+
+// WriteTo writes all metrics, sorted by name, in the Prometheus text format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	metrics := r.metrics
+	r.mu.Unlock()
+	sort.Strings(names)
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, name := range names {
+		metrics[name].write(cw)
+	}
+	return cw.n, cw.w.Flush()
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = r.WriteTo(w)
+}
+
+// Serve serves the metrics on addr under /metrics, until the returned server
+// is closed. It fails right away if addr can't be listened on. The Addr of the
+// server is the address listened on, with the port that was picked if addr
+// has none.
+func (r *Registry) Serve(addr string) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r)
+	srv := &http.Server{Addr: ln.Addr().String(), Handler: mux}
+	go func() { _ = srv.Serve(ln) }()
+	return srv, nil
+}
+
+// vec holds the series of a metric, by their label values.
+type vec struct {
+	name       string
+	help       string
+	typ        string
+	labelNames []string
+
+	mu     sync.Mutex
+	series map[string]interface{}
+	labels map[string][]string
+}
+
+func newVec(name, help, typ string, labelNames []string) *vec {
+	return &vec{
+		name:       name,
+		help:       help,
+		typ:        typ,
+		labelNames: labelNames,
+		series:     map[string]interface{}{},
+		labels:     map[string][]string{},
+	}
+}
+
+// get returns the series with the given label values, created by create if it
+// doesn't exist yet. It must be called with v.mu held.
+func (v *vec) get(labelValues []string, create func() interface{}) interface{} {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("metric %q has %d labels, got %d values", v.name, len(v.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = create()
+		v.series[key] = s
+		v.labels[key] = append([]string(nil), labelValues...)
+	}
+	return s
+}
+
+// write writes the header of the metric, and calls writeSeries for every
+// series, sorted by their label values. It must be called with v.mu held.
+func (v *vec) write(w io.Writer, writeSeries func(labelValues []string, s interface{})) {
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(v.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, v.typ)
+
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		writeSeries(v.labels[key], v.series[key])
+	}
+}
+
+// labelString formats the labels of a series, with extra name and value
+// pairs appended.
+func (v *vec) labelString(labelValues []string, extra ...string) string {
+	var pairs []string
+	for i, name := range v.labelNames {
+		pairs = append(pairs, name+`="`+labelValueEscaper.Replace(labelValues[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+labelValueEscaper.Replace(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Counter is a metric whose value only goes up, such as a number of requests.
+type Counter struct{ vec *vec }
+
+// NewCounter registers a counter with the given label names.
+func (r *Registry) NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{vec: newVec(name, help, "counter", labelNames)}
+	r.register(name, c)
+	return c
+}
+
+// Inc increments the series with the given label values by 1.
+func (c *Counter) Inc(labelValues ...string) { c.Add(1, labelValues...) }
+
+// Add adds delta, which must not be negative, to the series with the given
+// label values.
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic(fmt.Sprintf("counter %q can't be decreased", c.vec.name))
+	}
+	c.vec.mu.Lock()
+	defer c.vec.mu.Unlock()
+	*c.vec.get(labelValues, func() interface{} { return new(float64) }).(*float64) += delta
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.vec.mu.Lock()
+	defer c.vec.mu.Unlock()
+	c.vec.write(w, func(labelValues []string, s interface{}) {
+		fmt.Fprintf(w, "%s%s %s\n", c.vec.name, c.vec.labelString(labelValues), formatFloat(*s.(*float64)))
+	})
+}
+
+// Gauge is a metric whose value goes up and down, such as a number of tasks
+// that are running.
+type Gauge struct{ vec *vec }
+
+// NewGauge registers a gauge with the given label names.
+func (r *Registry) NewGauge(name, help string, labelNames ...string) *Gauge {
+	g := &Gauge{vec: newVec(name, help, "gauge", labelNames)}
+	r.register(name, g)
+	return g
+}
+
+// Set sets the series with the given label values to value.
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.vec.mu.Lock()
+	defer g.vec.mu.Unlock()
+	*g.vec.get(labelValues, func() interface{} { return new(float64) }).(*float64) = value
+}
+
+// Add adds delta, which may be negative, to the series with the given label
+// values.
+func (g *Gauge) Add(delta float64, labelValues ...string) {
+	g.vec.mu.Lock()
+	defer g.vec.mu.Unlock()
+	*g.vec.get(labelValues, func() interface{} { return new(float64) }).(*float64) += delta
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.vec.mu.Lock()
+	defer g.vec.mu.Unlock()
+	g.vec.write(w, func(labelValues []string, s interface{}) {
+		fmt.Fprintf(w, "%s%s %s\n", g.vec.name, g.vec.labelString(labelValues), formatFloat(*s.(*float64)))
+	})
+}
+
+// Histogram is a metric that counts observations, such as durations, in
+// buckets.
+type Histogram struct {
+	vec     *vec
+	buckets []float64
+}
+
+type histogramSeries struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram registers a histogram with the given bucket upper bounds,
+// which must be sorted, and label names.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	h := &Histogram{vec: newVec(name, help, "histogram", labelNames), buckets: buckets}
+	r.register(name, h)
+	return h
+}
+
+// Observe adds value to the series with the given label values.
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.vec.mu.Lock()
+	defer h.vec.mu.Unlock()
+	s := h.vec.get(labelValues, func() interface{} {
+		return &histogramSeries{counts: make([]uint64, len(h.buckets))}
+	}).(*histogramSeries)
+
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.vec.mu.Lock()
+	defer h.vec.mu.Unlock()
+	h.vec.write(w, func(labelValues []string, series interface{}) {
+		s := series.(*histogramSeries)
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.vec.name, h.vec.labelString(labelValues, "le", formatFloat(bound)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.vec.name, h.vec.labelString(labelValues, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.vec.name, h.vec.labelString(labelValues), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.vec.name, h.vec.labelString(labelValues), s.count)
+	})
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
+
+type countingWriter struct {
+	w *bufio.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
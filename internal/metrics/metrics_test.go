@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	tasks := r.NewCounter("test_tasks_total", "Tasks that completed.", "result")
+	running := r.NewGauge("test_tasks_running", "Tasks that are running.")
+	latency := r.NewHistogram("test_request_duration_seconds", "Duration of requests.", []float64{0.1, 1}, "code")
+
+	tasks.Inc("success")
+	tasks.Add(2, "success")
+	tasks.Inc(`fail"ure`)
+	running.Set(3)
+	running.Add(-1)
+	latency.Observe(0.05, "200")
+	latency.Observe(0.5, "200")
+	latency.Observe(5, "200")
+
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_request_duration_seconds Duration of requests.
+# TYPE test_request_duration_seconds histogram
+test_request_duration_seconds_bucket{code="200",le="0.1"} 1
+test_request_duration_seconds_bucket{code="200",le="1"} 2
+test_request_duration_seconds_bucket{code="200",le="+Inf"} 3
+test_request_duration_seconds_sum{code="200"} 5.55
+test_request_duration_seconds_count{code="200"} 3
+# HELP test_tasks_running Tasks that are running.
+# TYPE test_tasks_running gauge
+test_tasks_running 2
+# HELP test_tasks_total Tasks that completed.
+# TYPE test_tasks_total counter
+test_tasks_total{result="fail\"ure"} 1
+test_tasks_total{result="success"} 3
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("wrong output (-want +have):\n%s", diff)
+	}
+}
+
+func TestRegistryServe(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "A test counter.").Inc()
+
+	srv, err := r.Serve("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	resp, err := http.Get("http://" + srv.Addr + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("wrong content type %q", resp.Header.Get("Content-Type"))
+	}
+	if !strings.Contains(string(body), "test_total 1\n") {
+		t.Errorf("missing metric in %q", body)
+	}
+}
+
+func TestDuplicateRegistration(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+	r := NewRegistry()
+	r.NewCounter("test_total", "")
+	r.NewGauge("test_total", "")
+}
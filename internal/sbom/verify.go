@@ -0,0 +1,142 @@
+// Package sbom verifies the signatures and SBOM attestations of the container
+// images of a Sourcegraph deployment.
+package sbom
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/exec"
+)
+
+// Deployment types whose images can be listed.
+const (
+	DeploymentKubernetes = "kubernetes"
+	DeploymentDocker     = "docker"
+)
+
+// Status is the outcome of verifying an image.
+type Status string
+
+const (
+	// StatusVerified means that the image is signed and has an SBOM
+	// attestation, both verified with the public key.
+	StatusVerified Status = "verified"
+	// StatusUnsigned means that the signature of the image couldn't be
+	// verified.
+	StatusUnsigned Status = "unsigned"
+	// StatusNoAttestation means that the image is signed, but its SBOM
+	// attestation couldn't be verified.
+	StatusNoAttestation Status = "no-sbom-attestation"
+	// StatusUnexpected means that the image isn't published by Sourcegraph,
+	// so it wasn't verified.
+	StatusUnexpected Status = "unexpected"
+)
+
+// Result is the outcome of verifying one image.
+type Result struct {
+	Image  string `json:"image"`
+	Status Status `json:"status"`
+	// Detail explains why the image couldn't be verified.
+	Detail string `json:"detail,omitempty"`
+}
+
+// ListImages returns the distinct images of the running containers of a
+// deployment. For Kubernetes deployments, namespace selects the namespace, or
+// the default namespace of the current context if it's empty.
+func ListImages(ctx context.Context, deployment, namespace string) ([]string, error) {
+	var name string
+	var args []string
+	switch deployment {
+	case DeploymentKubernetes:
+		name = "kubectl"
+		if namespace != "" {
+			args = append(args, "--namespace", namespace)
+		}
+		args = append(args, "get", "pods", "--output", `jsonpath={range .items[*]}{range .spec.containers[*]}{.image}{"\n"}{end}{end}`)
+	case DeploymentDocker:
+		name = "docker"
+		args = []string{"container", "ls", "--format", "{{.Image}}"}
+	default:
+		return nil, errors.Newf("unknown deployment type %q", deployment)
+	}
+
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "listing images with %s", name)
+	}
+
+	seen := map[string]bool{}
+	var images []string
+	for _, image := range strings.Fields(string(out)) {
+		if !seen[image] {
+			seen[image] = true
+			images = append(images, image)
+		}
+	}
+	sort.Strings(images)
+	return images, nil
+}
+
+// IsSourcegraphImage returns true if the image is published by Sourcegraph,
+// that is if its repository is in a sourcegraph namespace of a registry.
+func IsSourcegraphImage(image string) bool {
+	parts := strings.Split(image, "/")
+	if len(parts) < 2 {
+		return false
+	}
+	// The first part is either the registry or, for Docker Hub images without
+	// a registry, the namespace.
+	for _, ns := range parts[:len(parts)-1] {
+		if ns == "sourcegraph" || strings.HasPrefix(ns, "sourcegraph-") {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify verifies the signature and SBOM attestation of every Sourcegraph
+// image with cosign, using the given public key. The key can be a path or a
+// URL, or anything else cosign accepts for --key. Images that aren't
+// published by Sourcegraph are reported as unexpected.
+func Verify(ctx context.Context, images []string, publicKey string) []Result {
+	results := make([]Result, 0, len(images))
+	for _, image := range images {
+		results = append(results, verifyImage(ctx, image, publicKey))
+	}
+	return results
+}
+
+func verifyImage(ctx context.Context, image, publicKey string) Result {
+	if !IsSourcegraphImage(image) {
+		return Result{Image: image, Status: StatusUnexpected, Detail: "not a Sourcegraph image"}
+	}
+
+	if err := cosign(ctx, "verify", "--key", publicKey, image); err != nil {
+		return Result{Image: image, Status: StatusUnsigned, Detail: err.Error()}
+	}
+	if err := cosign(ctx, "verify-attestation", "--key", publicKey, "--type", "spdxjson", image); err != nil {
+		return Result{Image: image, Status: StatusNoAttestation, Detail: err.Error()}
+	}
+	return Result{Image: image, Status: StatusVerified}
+}
+
+func cosign(ctx context.Context, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "cosign", args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// cosign explains failed verifications in the last line of its
+		// output.
+		lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+		if msg := lines[len(lines)-1]; msg != "" {
+			return errors.New(msg)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package sbom
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/sourcegraph/src-cli/internal/exec/expect"
+)
+
+func TestMain(m *testing.M) {
+	code := expect.Handle(m)
+	os.Exit(code)
+}
+
+func TestListImages(t *testing.T) {
+	expect.Commands(t, expect.NewGlob(
+		expect.Behaviour{Stdout: []byte("sourcegraph/frontend:3.33.0\nredis:6\nsourcegraph/frontend:3.33.0\n")},
+		"docker", "container", "ls", "--format", `\{\{.Image}}`,
+	))
+
+	have, err := ListImages(context.Background(), DeploymentDocker, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"redis:6", "sourcegraph/frontend:3.33.0"}, have); diff != "" {
+		t.Errorf("wrong images (-want +have):\n%s", diff)
+	}
+}
+
+func TestIsSourcegraphImage(t *testing.T) {
+	for image, want := range map[string]bool{
+		"sourcegraph/frontend:3.33.0":                      true,
+		"index.docker.io/sourcegraph/gitserver@sha256:abc": true,
+		"us.gcr.io/sourcegraph-dev/server:insiders":        true,
+		"redis:6":                  false,
+		"library/postgres:12":      false,
+		"quay.io/evil/sourcegraph": false,
+	} {
+		if have := IsSourcegraphImage(image); have != want {
+			t.Errorf("wrong result for %q: have=%v want=%v", image, have, want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	expect.Commands(t,
+		expect.NewGlob(expect.Success, "cosign", "verify", "--key", "key.pub", "sourcegraph/frontend:3.33.0"),
+		expect.NewGlob(expect.Success, "cosign", "verify-attestation", "--key", "key.pub", "--type", "spdxjson", "sourcegraph/frontend:3.33.0"),
+		expect.NewGlob(expect.Success, "cosign", "verify", "--key", "key.pub", "sourcegraph/gitserver:3.33.0"),
+		expect.NewGlob(expect.Behaviour{ExitCode: 1, Stderr: []byte("Error: none of the attestations matched the predicate type\n")}, "cosign", "verify-attestation", "--key", "key.pub", "--type", "spdxjson", "sourcegraph/gitserver:3.33.0"),
+		expect.NewGlob(expect.Behaviour{ExitCode: 1, Stderr: []byte("Error: no matching signatures\n")}, "cosign", "verify", "--key", "key.pub", "sourcegraph/searcher:dev"),
+	)
+
+	have := Verify(context.Background(), []string{
+		"sourcegraph/frontend:3.33.0",
+		"sourcegraph/gitserver:3.33.0",
+		"sourcegraph/searcher:dev",
+		"redis:6",
+	}, "key.pub")
+
+	want := []Result{
+		{Image: "sourcegraph/frontend:3.33.0", Status: StatusVerified},
+		{Image: "sourcegraph/gitserver:3.33.0", Status: StatusNoAttestation, Detail: "Error: none of the attestations matched the predicate type"},
+		{Image: "sourcegraph/searcher:dev", Status: StatusUnsigned, Detail: "Error: no matching signatures"},
+		{Image: "redis:6", Status: StatusUnexpected, Detail: "not a Sourcegraph image"},
+	}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Errorf("wrong results (-want +have):\n%s", diff)
+	}
+}
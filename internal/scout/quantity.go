@@ -0,0 +1,86 @@
+package scout
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// ParseCPU parses a CPU quantity as used by Kubernetes, such as "500m" or
+// "2", and returns it in millicores.
+func ParseCPU(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	if strings.HasSuffix(s, "m") {
+		return strconv.ParseInt(strings.TrimSuffix(s, "m"), 10, 64)
+	}
+	if strings.HasSuffix(s, "n") {
+		n, err := strconv.ParseInt(strings.TrimSuffix(s, "n"), 10, 64)
+		return n / 1000000, err
+	}
+	cores, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, errors.Newf("invalid CPU quantity %q", s)
+	}
+	return int64(cores * 1000), nil
+}
+
+var memoryUnits = []struct {
+	suffix     string
+	multiplier float64
+}{
+	// Longer suffixes first, so that "Mi" isn't matched as "i" or "M".
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40},
+	{"kB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"k", 1e3}, {"K", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12},
+	{"B", 1},
+}
+
+// ParseMemory parses a memory quantity as used by Kubernetes or Docker, such
+// as "512Mi", "2G" or "1.5GiB", and returns it in bytes.
+func ParseMemory(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	multiplier := 1.0
+	for _, u := range memoryUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSuffix(s, u.suffix)
+			multiplier = u.multiplier
+			break
+		}
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, errors.Newf("invalid memory quantity %q", s)
+	}
+	return int64(v * multiplier), nil
+}
+
+// FormatCPU formats millicores like Kubernetes does.
+func FormatCPU(millis int64) string {
+	if millis%1000 == 0 {
+		return strconv.FormatInt(millis/1000, 10)
+	}
+	return strconv.FormatInt(millis, 10) + "m"
+}
+
+// FormatMemory formats bytes in the largest binary unit that keeps the value
+// integral, or in Mi rounded up.
+func FormatMemory(bytes int64) string {
+	switch {
+	case bytes == 0:
+		return "0"
+	case bytes%(1<<30) == 0:
+		return strconv.FormatInt(bytes>>30, 10) + "Gi"
+	default:
+		return strconv.FormatInt((bytes+(1<<20)-1)>>20, 10) + "Mi"
+	}
+}
@@ -0,0 +1,65 @@
+package scout
+
+import "testing"
+
+func TestParseCPU(t *testing.T) {
+	for in, want := range map[string]int64{
+		"":           0,
+		"500m":       500,
+		"2":          2000,
+		"0.5":        500,
+		"250000000n": 250,
+	} {
+		have, err := ParseCPU(in)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", in, err)
+		}
+		if have != want {
+			t.Errorf("wrong value for %q: have=%d want=%d", in, have, want)
+		}
+	}
+
+	if _, err := ParseCPU("lots"); err == nil {
+		t.Error("unexpected nil error")
+	}
+}
+
+func TestParseMemory(t *testing.T) {
+	for in, want := range map[string]int64{
+		"":        0,
+		"1024":    1024,
+		"512Mi":   512 << 20,
+		"2Gi":     2 << 30,
+		"2G":      2e9,
+		"1.5GiB":  3 << 29,
+		"100MB":   100e6,
+		"12.3KiB": 12595,
+	} {
+		have, err := ParseMemory(in)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", in, err)
+		}
+		if have != want {
+			t.Errorf("wrong value for %q: have=%d want=%d", in, have, want)
+		}
+	}
+
+	if _, err := ParseMemory("lots"); err == nil {
+		t.Error("unexpected nil error")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	if have := FormatCPU(2000); have != "2" {
+		t.Errorf("wrong CPU: %q", have)
+	}
+	if have := FormatCPU(1500); have != "1500m" {
+		t.Errorf("wrong CPU: %q", have)
+	}
+	if have := FormatMemory(4 << 30); have != "4Gi" {
+		t.Errorf("wrong memory: %q", have)
+	}
+	if have := FormatMemory(1536 << 20); have != "1536Mi" {
+		t.Errorf("wrong memory: %q", have)
+	}
+}
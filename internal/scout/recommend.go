@@ -0,0 +1,196 @@
+package scout
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+)
+
+// Size is a deployment size tier, determined by the number of users and
+// repositories of an instance.
+type Size struct {
+	Name     string `json:"name"`
+	MaxUsers int    `json:"maxUsers"`
+	MaxRepos int    `json:"maxRepos"`
+	// scale multiplies the baseline resources of the services that scale
+	// with the size of the instance.
+	scale int64
+}
+
+var sizes = []Size{
+	{"XS", 100, 1000, 1},
+	{"S", 1000, 10000, 2},
+	{"M", 5000, 50000, 4},
+	{"L", 10000, 100000, 6},
+	{"XL", 0, 0, 8},
+}
+
+// SizeFor returns the size tier for an instance with the given number of
+// users and repositories.
+func SizeFor(users, repos int) Size {
+	for _, s := range sizes {
+		if s.MaxUsers == 0 || (users <= s.MaxUsers && repos <= s.MaxRepos) {
+			return s
+		}
+	}
+	return sizes[len(sizes)-1]
+}
+
+// guideline are the baseline resources of a service for the smallest size.
+type guideline struct {
+	cpu    int64
+	memory int64
+	// scales is true if the resources of the service grow with the size of
+	// the instance.
+	scales bool
+}
+
+const (
+	mi = int64(1) << 20
+	gi = int64(1) << 30
+)
+
+var guidelines = map[string]guideline{
+	"codeinsights-db":           {2000, 2 * gi, true},
+	"codeintel-db":              {4000, 4 * gi, true},
+	"frontend":                  {2000, 4 * gi, true},
+	"gitserver":                 {4000, 8 * gi, true},
+	"pgsql":                     {4000, 4 * gi, true},
+	"precise-code-intel-worker": {2000, 4 * gi, true},
+	"redis-cache":               {1000, 7 * gi, false},
+	"redis-store":               {1000, 7 * gi, false},
+	"repo-updater":              {1000, 512 * mi, false},
+	"searcher":                  {2000, 2 * gi, true},
+	"symbols":                   {2000, 4 * gi, true},
+	"syntect-server":            {4000, 6 * gi, false},
+	"worker":                    {2000, 4 * gi, true},
+	"zoekt-indexserver":         {4000, 8 * gi, true},
+	"zoekt-webserver":           {4000, 8 * gi, true},
+}
+
+// serviceFor returns the Sourcegraph service of a container, based on its
+// name, or an empty string if the container isn't a known service.
+func serviceFor(container string) string {
+	var service string
+	for name := range guidelines {
+		if strings.Contains(container, name) && len(name) > len(service) {
+			service = name
+		}
+	}
+	return service
+}
+
+// Recommendation is a suggested change to the resources of a container.
+type Recommendation struct {
+	Container   string `json:"container"`
+	Service     string `json:"service"`
+	Resource    string `json:"resource"`
+	Kind        string `json:"kind"`
+	Current     string `json:"current"`
+	Recommended string `json:"recommended"`
+	Reason      string `json:"reason"`
+}
+
+const (
+	underProvisioned = "under-provisioned"
+	overProvisioned  = "over-provisioned"
+)
+
+// Recommend compares the limits and usage of the containers with the
+// guidelines for the given size, and returns recommendations for the
+// containers of known services that are under- or over-provisioned.
+func Recommend(containers []*Container, size Size) []Recommendation {
+	var recs []Recommendation
+	for _, c := range containers {
+		service := serviceFor(c.Name)
+		if service == "" {
+			continue
+		}
+		g := guidelines[service]
+		scale := int64(1)
+		if g.scales {
+			scale = size.scale
+		}
+
+		name := c.Name
+		if c.Pod != "" {
+			name = c.Pod + "/" + c.Name
+		}
+
+		for _, r := range []struct {
+			resource           string
+			limit, usage, want int64
+			format             func(int64) string
+		}{
+			{"cpu", c.CPULimit, c.CPUUsage, g.cpu * scale, FormatCPU},
+			{"memory", c.MemoryLimit, c.MemoryUsage, g.memory * scale, FormatMemory},
+		} {
+			rec := Recommendation{
+				Container: name,
+				Service:   service,
+				Resource:  r.resource,
+				Current:   r.format(r.limit),
+			}
+
+			switch {
+			case r.limit > 0 && r.usage*10 > r.limit*9:
+				// Usage close to the limit trumps the guidelines: the
+				// container is throttled or about to be killed.
+				want := r.usage * 3 / 2
+				if want < r.want {
+					want = r.want
+				}
+				rec.Kind = underProvisioned
+				rec.Recommended = r.format(want)
+				rec.Reason = fmt.Sprintf("usage of %s is at %d%% of the limit", r.format(r.usage), r.usage*100/r.limit)
+			case r.limit == 0:
+				rec.Kind = underProvisioned
+				rec.Current = "none"
+				rec.Recommended = r.format(r.want)
+				rec.Reason = fmt.Sprintf("no limit is set; size %s needs %s", size.Name, r.format(r.want))
+			case r.limit < r.want:
+				rec.Kind = underProvisioned
+				rec.Recommended = r.format(r.want)
+				rec.Reason = fmt.Sprintf("size %s needs %s", size.Name, r.format(r.want))
+			case r.limit > 2*r.want && r.usage < r.want/2:
+				rec.Kind = overProvisioned
+				rec.Recommended = r.format(r.want)
+				rec.Reason = fmt.Sprintf("size %s needs %s and usage is %s", size.Name, r.format(r.want), r.format(r.usage))
+			default:
+				continue
+			}
+			recs = append(recs, rec)
+		}
+	}
+	return recs
+}
+
+const instanceCountsQuery = `query InstanceCounts {
+	users {
+		totalCount
+	}
+	repositories {
+		totalCount(precise: true)
+	}
+}`
+
+// InstanceCounts returns the number of users and repositories of the
+// Sourcegraph instance.
+func InstanceCounts(ctx context.Context, client api.Client) (users, repos int, err error) {
+	var result struct {
+		Users struct {
+			TotalCount int
+		}
+		Repositories struct {
+			TotalCount int
+		}
+	}
+	if ok, err := client.NewQuery(instanceCountsQuery).Do(ctx, &result); err != nil || !ok {
+		return 0, 0, errors.Wrap(err, "querying user and repository counts")
+	}
+	return result.Users.TotalCount, result.Repositories.TotalCount, nil
+}
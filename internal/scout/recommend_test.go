@@ -0,0 +1,82 @@
+package scout
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSizeFor(t *testing.T) {
+	for _, tc := range []struct {
+		users, repos int
+		want         string
+	}{
+		{10, 50, "XS"},
+		{10, 5000, "S"},
+		{2000, 500, "M"},
+		{10000, 100000, "L"},
+		{20000, 10, "XL"},
+	} {
+		if have := SizeFor(tc.users, tc.repos).Name; have != tc.want {
+			t.Errorf("wrong size for %d users and %d repos: have=%s want=%s", tc.users, tc.repos, have, tc.want)
+		}
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	containers := []*Container{
+		// Matches the guidelines.
+		{Pod: "frontend-1", Name: "frontend", CPULimit: 8000, MemoryLimit: 16 * gi, CPUUsage: 1000, MemoryUsage: 2 * gi},
+		// Limits below the guidelines.
+		{Pod: "gitserver-0", Name: "gitserver", CPULimit: 2000, MemoryLimit: 16 * gi, MemoryUsage: 4 * gi},
+		// Memory usage close to the limit.
+		{Pod: "searcher-0", Name: "searcher", CPULimit: 8000, MemoryLimit: 4 * gi, MemoryUsage: 4*gi - 100*mi},
+		// Far more than needed, and barely used.
+		{Name: "sourcegraph-repo-updater-0", CPULimit: 8000, MemoryLimit: 512 * mi, CPUUsage: 10},
+		// Not a Sourcegraph service.
+		{Pod: "frontend-1", Name: "istio-proxy", CPULimit: 100},
+	}
+
+	have := Recommend(containers, SizeFor(2000, 500))
+	want := []Recommendation{
+		{
+			Container:   "gitserver-0/gitserver",
+			Service:     "gitserver",
+			Resource:    "cpu",
+			Kind:        underProvisioned,
+			Current:     "2",
+			Recommended: "16",
+			Reason:      "size M needs 16",
+		},
+		{
+			Container:   "gitserver-0/gitserver",
+			Service:     "gitserver",
+			Resource:    "memory",
+			Kind:        underProvisioned,
+			Current:     "16Gi",
+			Recommended: "32Gi",
+			Reason:      "size M needs 32Gi",
+		},
+		{
+			Container:   "searcher-0/searcher",
+			Service:     "searcher",
+			Resource:    "memory",
+			Kind:        underProvisioned,
+			Current:     "4Gi",
+			Recommended: "8Gi",
+			Reason:      "usage of 3996Mi is at 97% of the limit",
+		},
+		{
+			Container:   "sourcegraph-repo-updater-0",
+			Service:     "repo-updater",
+			Resource:    "cpu",
+			Kind:        overProvisioned,
+			Current:     "8",
+			Recommended: "1",
+			Reason:      "size M needs 1 and usage is 10m",
+		},
+	}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Errorf("wrong recommendations (-want +have):\n%s", diff)
+	}
+}
@@ -0,0 +1,174 @@
+// Package scout compares the resources of a Sourcegraph deployment with
+// sizing guidelines and recommends adjustments.
+package scout
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/exec"
+)
+
+// Container holds the resources allocated to a container, and the resources
+// it uses. CPU is in millicores and memory in bytes; zero means unset or
+// unknown.
+type Container struct {
+	// Pod is the pod of the container. It's empty for Docker deployments.
+	Pod  string `json:"pod,omitempty"`
+	Name string `json:"name"`
+
+	CPURequest    int64 `json:"cpuRequest"`
+	CPULimit      int64 `json:"cpuLimit"`
+	MemoryRequest int64 `json:"memoryRequest"`
+	MemoryLimit   int64 `json:"memoryLimit"`
+
+	CPUUsage    int64 `json:"cpuUsage"`
+	MemoryUsage int64 `json:"memoryUsage"`
+}
+
+// KubeContainers returns the containers of the pods in the given namespace,
+// or the default namespace of the current context if it's empty. Usage is
+// read from the metrics API and left at zero if that isn't available.
+func KubeContainers(ctx context.Context, namespace string) ([]*Container, error) {
+	kubectl := func(args ...string) ([]byte, error) {
+		if namespace != "" {
+			args = append([]string{"--namespace", namespace}, args...)
+		}
+		return exec.CommandContext(ctx, "kubectl", args...).Output()
+	}
+
+	out, err := kubectl("get", "pods", "--output", "json")
+	if err != nil {
+		return nil, errors.Wrap(err, "listing pods")
+	}
+
+	var pods struct {
+		Items []struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Spec struct {
+				Containers []struct {
+					Name      string `json:"name"`
+					Resources struct {
+						Requests map[string]string `json:"requests"`
+						Limits   map[string]string `json:"limits"`
+					} `json:"resources"`
+				} `json:"containers"`
+			} `json:"spec"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(out, &pods); err != nil {
+		return nil, errors.Wrap(err, "decoding pods")
+	}
+
+	var containers []*Container
+	byName := map[string]*Container{}
+	for _, pod := range pods.Items {
+		for _, c := range pod.Spec.Containers {
+			container := &Container{Pod: pod.Metadata.Name, Name: c.Name}
+			if container.CPURequest, err = ParseCPU(c.Resources.Requests["cpu"]); err != nil {
+				return nil, err
+			}
+			if container.CPULimit, err = ParseCPU(c.Resources.Limits["cpu"]); err != nil {
+				return nil, err
+			}
+			if container.MemoryRequest, err = ParseMemory(c.Resources.Requests["memory"]); err != nil {
+				return nil, err
+			}
+			if container.MemoryLimit, err = ParseMemory(c.Resources.Limits["memory"]); err != nil {
+				return nil, err
+			}
+			containers = append(containers, container)
+			byName[pod.Metadata.Name+"/"+c.Name] = container
+		}
+	}
+
+	// The metrics server is optional, so missing usage isn't an error.
+	if out, err := kubectl("top", "pods", "--containers", "--no-headers"); err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(out))
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) != 4 {
+				continue
+			}
+			c, ok := byName[fields[0]+"/"+fields[1]]
+			if !ok {
+				continue
+			}
+			c.CPUUsage, _ = ParseCPU(fields[2])
+			c.MemoryUsage, _ = ParseMemory(fields[3])
+		}
+	}
+
+	return containers, nil
+}
+
+// DockerContainers returns the running Docker containers with their limits
+// and current usage. Docker has no requests, so they are left at zero.
+func DockerContainers(ctx context.Context) ([]*Container, error) {
+	out, err := exec.CommandContext(ctx, "docker", "container", "ls", "--quiet").Output()
+	if err != nil {
+		return nil, errors.Wrap(err, "listing containers")
+	}
+	ids := strings.Fields(string(out))
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	args := append([]string{"container", "inspect", "--format", "{{.Name}} {{.HostConfig.NanoCpus}} {{.HostConfig.Memory}}"}, ids...)
+	out, err = exec.CommandContext(ctx, "docker", args...).Output()
+	if err != nil {
+		return nil, errors.Wrap(err, "inspecting containers")
+	}
+
+	var containers []*Container
+	byName := map[string]*Container{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		c := &Container{Name: strings.TrimPrefix(fields[0], "/")}
+		nanoCPUs, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing CPU limit of %s", c.Name)
+		}
+		c.CPULimit = nanoCPUs / 1000000
+		if c.MemoryLimit, err = ParseMemory(fields[2]); err != nil {
+			return nil, err
+		}
+		containers = append(containers, c)
+		byName[c.Name] = c
+	}
+
+	args = append([]string{"container", "stats", "--no-stream", "--format", "{{.Name}}\t{{.CPUPerc}}\t{{.MemUsage}}"}, ids...)
+	if out, err := exec.CommandContext(ctx, "docker", args...).Output(); err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(out))
+		for scanner.Scan() {
+			fields := strings.Split(scanner.Text(), "\t")
+			if len(fields) != 3 {
+				continue
+			}
+			c, ok := byName[fields[0]]
+			if !ok {
+				continue
+			}
+			// CPU usage is a percentage of one core, such as "150.25%".
+			if cpu, err := ParseCPU(strings.TrimSuffix(fields[1], "%")); err == nil {
+				c.CPUUsage = cpu / 100
+			}
+			// Memory usage is reported as "1.2GiB / 4GiB".
+			c.MemoryUsage, _ = ParseMemory(strings.SplitN(fields[2], "/", 2)[0])
+		}
+	}
+
+	return containers, nil
+}
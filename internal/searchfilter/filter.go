@@ -0,0 +1,190 @@
+// Package searchfilter drops noisy search results client-side, by their path,
+// size, and whether they look binary or generated, so that vendored and
+// minified matches can be dropped without crafting complex queries.
+package searchfilter
+
+import (
+	"bytes"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Filter decides which file results are kept. Its zero value keeps all
+// results.
+type Filter struct {
+	// Include, if set, keeps only the files whose paths match it, and
+	// Exclude drops the files whose paths match it.
+	Include *regexp.Regexp
+	Exclude *regexp.Regexp
+	// MaxSize, if positive, drops the files larger than it, in bytes.
+	MaxSize int
+	// SkipBinary drops binary files, and SkipGenerated drops vendored,
+	// minified and generated files.
+	SkipBinary    bool
+	SkipGenerated bool
+}
+
+// IsSet returns whether the filter drops any results.
+func (f *Filter) IsSet() bool {
+	return f != nil && (f.Include != nil || f.Exclude != nil || f.MaxSize > 0 || f.SkipBinary || f.SkipGenerated)
+}
+
+// NeedsContent returns whether the filter needs the content of files, rather
+// than just their matching lines.
+func (f *Filter) NeedsContent() bool {
+	return f != nil && f.MaxSize > 0
+}
+
+// KeepPath returns whether a result is kept based on its path only.
+func (f *Filter) KeepPath(p string) bool {
+	if f == nil {
+		return true
+	}
+	if f.Include != nil && !f.Include.MatchString(p) {
+		return false
+	}
+	if f.Exclude != nil && f.Exclude.MatchString(p) {
+		return false
+	}
+	if f.SkipGenerated && IsVendoredOrGeneratedPath(p) {
+		return false
+	}
+	return true
+}
+
+// KeepFile returns whether a file result is kept, given its path and its whole
+// content.
+func (f *Filter) KeepFile(p, content string) bool {
+	if !f.KeepPath(p) {
+		return false
+	}
+	if f.MaxSize > 0 && len(content) > f.MaxSize {
+		return false
+	}
+	if f.SkipBinary && IsBinary(content) {
+		return false
+	}
+	if f.SkipGenerated && (IsGeneratedContent(content) || IsMinified(strings.Split(content, "\n"))) {
+		return false
+	}
+	return true
+}
+
+// KeepLines returns whether a file result is kept, given its path and only
+// its matching lines, as the streaming search API returns them. Binary and
+// generated files are recognized by their lines as far as possible.
+func (f *Filter) KeepLines(p string, lines []string) bool {
+	if !f.KeepPath(p) {
+		return false
+	}
+	if f.SkipBinary {
+		for _, l := range lines {
+			if IsBinary(l) {
+				return false
+			}
+		}
+	}
+	if f.SkipGenerated {
+		if IsMinified(lines) {
+			return false
+		}
+		for _, l := range lines {
+			if IsGeneratedContent(l) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// vendoredPath matches the paths of vendored dependencies and build output,
+// after the conventions of GitHub's linguist.
+var vendoredPath = regexp.MustCompile(`(^|/)(vendor|node_modules|bower_components|third_party|3rdparty|Godeps/_workspace|\.yarn|dist|bin/Release|bin/Debug|Pods|Carthage/Build)/`)
+
+// generatedNames are the base names of files that are always generated.
+var generatedNames = map[string]bool{
+	"package-lock.json": true,
+	"yarn.lock":         true,
+	"pnpm-lock.yaml":    true,
+	"Cargo.lock":        true,
+	"Gemfile.lock":      true,
+	"composer.lock":     true,
+	"poetry.lock":       true,
+	"go.sum":            true,
+}
+
+// generatedSuffixes are the suffixes of the names of files that are usually
+// generated or minified.
+var generatedSuffixes = []string{
+	".min.js", ".min.css", ".js.map", ".css.map",
+	".pb.go", "_pb2.py", ".pb.cc", ".pb.h",
+	"_generated.go", ".generated.ts", ".designer.cs",
+}
+
+// IsVendoredOrGeneratedPath returns whether the path is of a vendored
+// dependency, a lock file, or a file whose name marks it as generated or
+// minified.
+func IsVendoredOrGeneratedPath(p string) bool {
+	if vendoredPath.MatchString(p) {
+		return true
+	}
+	base := path.Base(p)
+	if generatedNames[base] {
+		return true
+	}
+	for _, suffix := range generatedSuffixes {
+		if strings.HasSuffix(base, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// generatedMarker matches the comments that code generators put at the top
+// of files, such as Go's "Code generated ... DO NOT EDIT."
+var generatedMarker = regexp.MustCompile(`(?i)(^|\n)\W*(code generated .* do not edit|@generated|auto-generated|autogenerated|generated by .* do not (edit|modify))`)
+
+// IsGeneratedContent returns whether the content has a marker of generated
+// code in its first lines.
+func IsGeneratedContent(content string) bool {
+	if len(content) > 1024 {
+		content = content[:1024]
+	}
+	return generatedMarker.MatchString(content)
+}
+
+// minifiedLineLength is the average line length above which code is
+// considered minified.
+const minifiedLineLength = 110
+
+// IsMinified returns whether the lines look minified: long on average, or
+// at least one of them very long.
+func IsMinified(lines []string) bool {
+	if len(lines) == 0 {
+		return false
+	}
+	total := 0
+	for _, l := range lines {
+		if len(l) > 10*minifiedLineLength {
+			return true
+		}
+		total += len(l)
+	}
+	return len(lines) > 1 && total/len(lines) > minifiedLineLength
+}
+
+// IsBinary returns whether the content looks binary: it has a NUL byte or
+// isn't valid UTF-8 in its first 8000 bytes, like git decides.
+func IsBinary(content string) bool {
+	if len(content) > 8000 {
+		content = content[:8000]
+		// Don't mistake a multi-byte character that was cut for invalid
+		// UTF-8.
+		for i := 0; i < utf8.UTFMax && len(content) > 0 && !utf8.ValidString(content); i++ {
+			content = content[:len(content)-1]
+		}
+	}
+	return bytes.IndexByte([]byte(content), 0) >= 0 || !utf8.ValidString(content)
+}
@@ -0,0 +1,68 @@
+package searchfilter
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestFilter_KeepFile(t *testing.T) {
+	for name, tc := range map[string]struct {
+		filter  Filter
+		path    string
+		content string
+		want    bool
+	}{
+		"zero value":     {path: "vendor/a.go", content: "\x00", want: true},
+		"include":        {filter: Filter{Include: regexp.MustCompile(`\.go$`)}, path: "cmd/main.go", want: true},
+		"not included":   {filter: Filter{Include: regexp.MustCompile(`\.go$`)}, path: "README.md", want: false},
+		"excluded":       {filter: Filter{Exclude: regexp.MustCompile(`^test/`)}, path: "test/a.go", want: false},
+		"small enough":   {filter: Filter{MaxSize: 10}, path: "a.go", content: "0123456789", want: true},
+		"too large":      {filter: Filter{MaxSize: 10}, path: "a.go", content: "0123456789a", want: false},
+		"text":           {filter: Filter{SkipBinary: true}, path: "a.txt", content: "héllo", want: true},
+		"nul byte":       {filter: Filter{SkipBinary: true}, path: "a.bin", content: "PK\x03\x04\x00", want: false},
+		"invalid utf-8":  {filter: Filter{SkipBinary: true}, path: "a.bin", content: "\xff\xfe", want: false},
+		"cut multi-byte": {filter: Filter{SkipBinary: true}, path: "a.txt", content: strings.Repeat("a", 7999) + "é", want: true},
+		"vendored":       {filter: Filter{SkipGenerated: true}, path: "web/node_modules/x/index.js", want: false},
+		"lock file":      {filter: Filter{SkipGenerated: true}, path: "yarn.lock", want: false},
+		"minified name":  {filter: Filter{SkipGenerated: true}, path: "static/app.min.js", want: false},
+		"protobuf":       {filter: Filter{SkipGenerated: true}, path: "api/api.pb.go", want: false},
+		"go generated":   {filter: Filter{SkipGenerated: true}, path: "a.go", content: "// Code generated by stringer; DO NOT EDIT.\n\npackage a\n", want: false},
+		"@generated":     {filter: Filter{SkipGenerated: true}, path: "a.js", content: "/* @generated */\n", want: false},
+		"minified":       {filter: Filter{SkipGenerated: true}, path: "a.js", content: strings.Repeat("var a=1;", 200), want: false},
+		"handwritten":    {filter: Filter{SkipGenerated: true}, path: "vendors.go", content: "package a\n\nfunc main() {}\n", want: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if have := tc.filter.KeepFile(tc.path, tc.content); have != tc.want {
+				t.Errorf("have %t, want %t", have, tc.want)
+			}
+		})
+	}
+}
+
+func TestFilter_KeepLines(t *testing.T) {
+	f := &Filter{SkipBinary: true, SkipGenerated: true}
+
+	if !f.KeepLines("a.go", []string{"fmt.Println(a)", "return nil"}) {
+		t.Error("regular lines were dropped")
+	}
+	if f.KeepLines("a.js", []string{strings.Repeat("x", 2000)}) {
+		t.Error("minified line was kept")
+	}
+	if f.KeepLines("a.bin", []string{"ELF\x00\x01"}) {
+		t.Error("binary line was kept")
+	}
+	if f.KeepLines("vendor/a.go", []string{"return nil"}) {
+		t.Error("vendored file was kept")
+	}
+}
+
+func TestFilter_IsSet(t *testing.T) {
+	var nilFilter *Filter
+	if nilFilter.IsSet() || (&Filter{}).IsSet() {
+		t.Error("empty filter is set")
+	}
+	if !(&Filter{SkipBinary: true}).IsSet() {
+		t.Error("filter isn't set")
+	}
+}
@@ -0,0 +1,128 @@
+// Package searchquery builds Sourcegraph search queries from typed values,
+// taking care of the quoting and escaping rules of the query syntax.
+package searchquery
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Options are the filters of a query. Empty values are omitted from it.
+type Options struct {
+	// Repos are the exact names of the repositories to search.
+	Repos []string
+	// Files are substrings of the paths of the files to search.
+	Files []string
+	// Langs are the languages of the files to search.
+	Langs []string
+	// Authors are substrings of the names or emails of the commit authors,
+	// for commit and diff searches.
+	Authors []string
+	// After and Before limit commit and diff searches to a time range, such as
+	// "2 weeks ago" or "2021-10-01".
+	After, Before string
+	// Type is the type of the results: repo, path, file, commit, diff or
+	// symbol.
+	Type string
+	// PatternType is literal, regexp or structural.
+	PatternType string
+	// CaseSensitive makes the pattern case sensitive.
+	CaseSensitive bool
+	// Count is the maximum number of results.
+	Count int
+}
+
+var (
+	types        = []string{"commit", "diff", "file", "path", "repo", "symbol"}
+	patternTypes = []string{"literal", "regexp", "structural"}
+)
+
+// Build returns the query that searches for the pattern with the given
+// filters. The pattern is used as is, and may be empty.
+func Build(pattern string, opts Options) (string, error) {
+	if opts.Type != "" && !contains(types, opts.Type) {
+		return "", errors.Newf("invalid type %q, must be one of %s", opts.Type, strings.Join(types, ", "))
+	}
+	if opts.PatternType != "" && !contains(patternTypes, opts.PatternType) {
+		return "", errors.Newf("invalid pattern type %q, must be one of %s", opts.PatternType, strings.Join(patternTypes, ", "))
+	}
+	if opts.Count < 0 {
+		return "", errors.New("count must not be negative")
+	}
+
+	var parts []string
+	add := func(field, value string) {
+		parts = append(parts, field+":"+quote(value))
+	}
+
+	if len(opts.Repos) > 0 {
+		add("repo", "^"+alternation(opts.Repos)+"$")
+	}
+	if len(opts.Files) > 0 {
+		add("file", alternation(opts.Files))
+	}
+	for _, lang := range opts.Langs {
+		// Multiple lang filters match files of any of the languages.
+		add("lang", lang)
+	}
+	if len(opts.Authors) > 0 {
+		add("author", alternation(opts.Authors))
+	}
+	if opts.After != "" {
+		add("after", opts.After)
+	}
+	if opts.Before != "" {
+		add("before", opts.Before)
+	}
+	if opts.Type != "" {
+		add("type", opts.Type)
+	}
+	if opts.PatternType != "" {
+		add("patterntype", opts.PatternType)
+	}
+	if opts.CaseSensitive {
+		add("case", "yes")
+	}
+	if opts.Count > 0 {
+		add("count", strconv.Itoa(opts.Count))
+	}
+
+	if pattern != "" {
+		parts = append(parts, pattern)
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// alternation returns a regular expression matching any of the literal
+// values.
+func alternation(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = regexp.QuoteMeta(v)
+	}
+	if len(quoted) == 1 {
+		return quoted[0]
+	}
+	return "(" + strings.Join(quoted, "|") + ")"
+}
+
+// quote quotes a filter value if it's empty or contains whitespace or quotes,
+// which would otherwise end it.
+func quote(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\n\"'") {
+		return value
+	}
+	return strconv.Quote(value)
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
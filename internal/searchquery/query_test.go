@@ -0,0 +1,71 @@
+package searchquery
+
+import "testing"
+
+func TestBuild(t *testing.T) {
+	for name, tc := range map[string]struct {
+		pattern string
+		opts    Options
+		want    string
+	}{
+		"pattern only": {
+			pattern: "fmt.Println",
+			want:    "fmt.Println",
+		},
+		"single repo": {
+			pattern: "error",
+			opts:    Options{Repos: []string{"github.com/sourcegraph/src-cli"}},
+			want:    `repo:^github\.com/sourcegraph/src-cli$ error`,
+		},
+		"multiple repos and files": {
+			opts: Options{
+				Repos: []string{"github.com/a/b", "github.com/a/c"},
+				Files: []string{"cmd/", "main.go"},
+			},
+			want: `repo:^(github\.com/a/b|github\.com/a/c)$ file:(cmd/|main\.go)`,
+		},
+		"commit filters with spaces": {
+			pattern: "fix",
+			opts: Options{
+				Authors: []string{"Jane Doe"},
+				After:   "2 weeks ago",
+				Type:    "commit",
+			},
+			want: `author:"Jane Doe" after:"2 weeks ago" type:commit fix`,
+		},
+		"all the rest": {
+			pattern: "foo(...)",
+			opts: Options{
+				Langs:         []string{"go", "python"},
+				PatternType:   "structural",
+				CaseSensitive: true,
+				Count:         100,
+			},
+			want: "lang:go lang:python patterntype:structural case:yes count:100 foo(...)",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			have, err := Build(tc.pattern, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if have != tc.want {
+				t.Errorf("wrong query:\nhave=%s\nwant=%s", have, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	for name, opts := range map[string]Options{
+		"type":         {Type: "blob"},
+		"pattern type": {PatternType: "fuzzy"},
+		"count":        {Count: -1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Build("", opts); err == nil {
+				t.Error("unexpected nil error")
+			}
+		})
+	}
+}
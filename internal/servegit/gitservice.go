@@ -0,0 +1,198 @@
+package servegit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// gitService serves the smart HTTP protocol of git-upload-pack, which is all
+// that's needed to clone and fetch repositories. Pushing isn't supported.
+//
+// The protocol version the client asks for in the Git-Protocol header is
+// passed on to git, so that clients such as gitserver can use protocol v2 and
+// only ask for the refs they need, instead of getting all refs of every
+// repository advertised. Shallow clones and fetches are negotiated by
+// git-upload-pack itself.
+type gitService struct {
+	// Dir returns the directory of the repository with the given name.
+	Dir func(name string) string
+
+	// Trace, if set, is called at the start of every request with the git
+	// service, the name of the repository and the protocol the client asked
+	// for. The returned function is called with the error the request failed
+	// with, if any, when it's done.
+	Trace func(svc, repo, protocol string) func(error)
+}
+
+// gitProtocolPattern matches the values of the Git-Protocol header that are
+// passed on to git, which are colon-separated keys and values such as
+// "version=2".
+var gitProtocolPattern = regexp.MustCompile(`^[a-zA-Z0-9=:._-]*$`)
+
+func (s *gitService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The repository is empty for requests of the repository at the root.
+	path := "/" + strings.TrimPrefix(r.URL.Path, "/")
+
+	var repo, svc string
+	switch {
+	case strings.HasSuffix(path, "/info/refs"):
+		repo, svc = strings.Trim(strings.TrimSuffix(path, "/info/refs"), "/"), "info/refs"
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if service := r.URL.Query().Get("service"); service != "git-upload-pack" {
+			http.Error(w, "only the smart HTTP protocol of git-upload-pack is supported", http.StatusForbidden)
+			return
+		}
+
+	case strings.HasSuffix(path, "/git-upload-pack"):
+		repo, svc = strings.Trim(strings.TrimSuffix(path, "/git-upload-pack"), "/"), "upload-pack"
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/x-git-upload-pack-request" {
+			http.Error(w, fmt.Sprintf("unexpected Content-Type %q", ct), http.StatusUnsupportedMediaType)
+			return
+		}
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	protocol := r.Header.Get("Git-Protocol")
+	if !gitProtocolPattern.MatchString(protocol) {
+		http.Error(w, fmt.Sprintf("invalid Git-Protocol %q", protocol), http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if s.Trace != nil {
+		done := s.Trace(svc, repo, protocol)
+		defer func() { done(err) }()
+	}
+
+	dir := s.Dir(repo)
+	if fi, statErr := os.Stat(dir); statErr != nil || !fi.IsDir() {
+		http.NotFound(w, r)
+		err = errors.Newf("repository %q not found", repo)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	if svc == "info/refs" {
+		err = s.advertiseRefs(r.Context(), w, dir, protocol)
+		return
+	}
+
+	body := r.Body
+	switch r.Header.Get("Content-Encoding") {
+	case "":
+	case "gzip", "x-gzip":
+		// git compresses requests with many haves, such as fetches into
+		// repositories with many refs.
+		zr, zErr := gzip.NewReader(r.Body)
+		if zErr != nil {
+			http.Error(w, "invalid gzip request body", http.StatusBadRequest)
+			err = zErr
+			return
+		}
+		defer zr.Close()
+		body = zr
+	default:
+		http.Error(w, "unsupported Content-Encoding", http.StatusUnsupportedMediaType)
+		err = errors.Newf("unsupported Content-Encoding %q", r.Header.Get("Content-Encoding"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-git-upload-pack-result")
+	err = runUploadPack(r.Context(), w, body, dir, protocol, "")
+}
+
+// advertiseRefs writes the response to the first request of a clone or fetch.
+// With protocol v0 and v1 that's the refs of the repository, preceded by a
+// line naming the service. With protocol v2 it's the capabilities of the
+// server, without that line.
+func (s *gitService) advertiseRefs(ctx context.Context, w http.ResponseWriter, dir, protocol string) error {
+	w.Header().Set("Content-Type", "application/x-git-upload-pack-advertisement")
+	var prefix string
+	if !isProtocolV2(protocol) {
+		prefix = pktLine("# service=git-upload-pack\n") + "0000"
+	}
+	return runUploadPack(ctx, w, nil, dir, protocol, prefix, "--advertise-refs")
+}
+
+// runUploadPack runs git-upload-pack in stateless mode on dir, with its
+// output, preceded by prefix, streamed to w. If it fails before writing any
+// output, the error is reported to the client with a 500 status instead.
+func runUploadPack(ctx context.Context, w http.ResponseWriter, stdin io.Reader, dir, protocol, prefix string, args ...string) error {
+	args = append([]string{"upload-pack", "--stateless-rpc"}, args...)
+	cmd := exec.CommandContext(ctx, "git", append(args, dir)...)
+	if protocol != "" {
+		cmd.Env = append(os.Environ(), "GIT_PROTOCOL="+protocol)
+	}
+	cmd.Stdin = stdin
+
+	var stderr bytes.Buffer
+	fw := &flushWriter{w: w, prefix: prefix}
+	cmd.Stdout = fw
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		err = errors.Wrapf(err, "git %s: %s", strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+		if !fw.written {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return err
+	}
+	return nil
+}
+
+// isProtocolV2 returns whether the Git-Protocol header asks for protocol v2.
+func isProtocolV2(protocol string) bool {
+	for _, param := range strings.Split(protocol, ":") {
+		if param == "version=2" {
+			return true
+		}
+	}
+	return false
+}
+
+// pktLine encodes s as a line of the git pkt-line format.
+func pktLine(s string) string {
+	return fmt.Sprintf("%04x%s", len(s)+4, s)
+}
+
+// flushWriter flushes every write to the client, so that packs of large
+// repositories and progress messages are streamed instead of buffered. The
+// prefix is written before the first write.
+type flushWriter struct {
+	w       http.ResponseWriter
+	prefix  string
+	written bool
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	if !fw.written {
+		fw.written = true
+		if _, err := io.WriteString(fw.w, fw.prefix); err != nil {
+			return 0, err
+		}
+	}
+	n, err := fw.w.Write(p)
+	if f, ok := fw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return n, err
+}
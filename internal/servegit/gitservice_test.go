@@ -0,0 +1,124 @@
+package servegit
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitService(t *testing.T) {
+	root := t.TempDir()
+	git := func(dir string, args ...string) string {
+		t.Helper()
+		c := exec.Command("git", append([]string{"-c", "user.name=a", "-c", "user.email=a@example.com"}, args...)...)
+		c.Dir = dir
+		out, err := c.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	repo := filepath.Join(root, "project1")
+	git(root, "init", "project1")
+	for _, msg := range []string{"one", "two", "three"} {
+		git(repo, "commit", "--allow-empty", "-m", msg)
+	}
+
+	ts := httptest.NewServer((&Serve{
+		Info:  testLogger(t),
+		Debug: discardLogger,
+		Root:  root,
+	}).handler())
+	t.Cleanup(ts.Close)
+
+	for _, version := range []string{"0", "2"} {
+		t.Run("protocol v"+version, func(t *testing.T) {
+			dst := t.TempDir()
+			git(dst, "-c", "protocol.version="+version, "clone", "--depth", "1", ts.URL+"/repos/project1", "clone")
+			clone := filepath.Join(dst, "clone")
+
+			if have := git(clone, "rev-parse", "--is-shallow-repository"); have != "true" {
+				t.Errorf("clone isn't shallow")
+			}
+			if have := git(clone, "rev-list", "--count", "HEAD"); have != "1" {
+				t.Errorf("wrong number of commits cloned: want 1, have %s", have)
+			}
+
+			git(clone, "-c", "protocol.version="+version, "fetch", "--deepen", "1")
+			if have := git(clone, "rev-list", "--count", "HEAD"); have != "2" {
+				t.Errorf("wrong number of commits after deepening: want 2, have %s", have)
+			}
+
+			git(clone, "-c", "protocol.version="+version, "fetch", "--unshallow")
+			if have := git(clone, "rev-list", "--count", "HEAD"); have != "3" {
+				t.Errorf("wrong number of commits after unshallowing: want 3, have %s", have)
+			}
+		})
+	}
+
+	t.Run("root repository", func(t *testing.T) {
+		dst := t.TempDir()
+		ts := httptest.NewServer((&Serve{Info: testLogger(t), Debug: discardLogger, Root: repo}).handler())
+		t.Cleanup(ts.Close)
+		git(dst, "clone", ts.URL+"/repos", "clone")
+		if _, err := os.Stat(filepath.Join(dst, "clone", ".git")); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestGitServiceProtocol(t *testing.T) {
+	root := gitInitRepos(t, "project1")
+	var protocols []string
+	ts := httptest.NewServer(&gitService{
+		Dir: func(name string) string { return filepath.Join(root, name) },
+		Trace: func(svc, repo, protocol string) func(error) {
+			protocols = append(protocols, svc+" "+repo+" "+protocol)
+			return func(error) {}
+		},
+	})
+	t.Cleanup(ts.Close)
+
+	for _, tc := range []struct {
+		protocol    string
+		wantService bool
+	}{
+		{protocol: "", wantService: true},
+		{protocol: "version=1", wantService: true},
+		{protocol: "version=2", wantService: false},
+	} {
+		req, err := http.NewRequest("GET", ts.URL+"/project1/info/refs?service=git-upload-pack", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Git-Protocol", tc.protocol)
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body strings.Builder
+		_, _ = io.Copy(&body, resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != 200 {
+			t.Fatalf("%q: unexpected status %d: %s", tc.protocol, resp.StatusCode, body.String())
+		}
+		if have := strings.HasPrefix(body.String(), "001e# service=git-upload-pack\n0000"); have != tc.wantService {
+			t.Errorf("%q: service line sent: want %v, have %v in %q", tc.protocol, tc.wantService, have, body.String())
+		}
+		if tc.protocol == "version=2" && !strings.Contains(body.String(), "version 2") {
+			t.Errorf("%q: no protocol v2 capability advertisement in %q", tc.protocol, body.String())
+		}
+	}
+
+	want := []string{"info/refs project1 ", "info/refs project1 version=1", "info/refs project1 version=2"}
+	if strings.Join(protocols, ",") != strings.Join(want, ",") {
+		t.Errorf("wrong traces: want %q, have %q", want, protocols)
+	}
+}
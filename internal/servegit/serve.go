@@ -15,12 +15,30 @@ import (
 	"time"
 
 	"github.com/cockroachdb/errors"
-	"github.com/sourcegraph/sourcegraph/lib/gitservice"
+
+	"github.com/sourcegraph/src-cli/internal/metrics"
+)
+
+var (
+	gitRequests = metrics.Default.NewCounter(
+		"src_serve_git_requests_total",
+		"Git requests served, by git service and result (success or failure).",
+		"service", "result",
+	)
+	gitRequestDuration = metrics.Default.NewHistogram(
+		"src_serve_git_request_duration_seconds",
+		"Duration of git requests, by git service.",
+		metrics.DefaultBuckets,
+		"service",
+	)
 )
 
 type Serve struct {
-	Addr  string
-	Root  string
+	Addr string
+	Root string
+	// Depth is how many directories deep below Root repositories are
+	// searched for. If it's 0, there is no limit.
+	Depth int
 	Info  *log.Logger
 	Debug *log.Logger
 }
@@ -100,13 +118,20 @@ func (s *Serve) handler() http.Handler {
 	})
 
 	fs := http.FileServer(http.Dir(s.Root))
-	svc := &gitservice.Handler{
+	svc := &gitService{
 		Dir: func(name string) string {
 			return filepath.Join(s.Root, filepath.FromSlash(name))
 		},
 		Trace: func(svc, repo, protocol string) func(error) {
 			start := time.Now()
 			return func(err error) {
+				result := "success"
+				if err != nil {
+					result = "failure"
+				}
+				gitRequests.Inc(svc, result)
+				gitRequestDuration.Observe(time.Since(start).Seconds(), svc)
+
 				s.Debug.Printf("git service svc=%s protocol=%s repo=%s duration=%v", svc, protocol, repo, time.Since(start))
 				if err != nil {
 					s.Debug.Println(err)
@@ -117,7 +142,7 @@ func (s *Serve) handler() http.Handler {
 	mux.Handle("/repos/", http.StripPrefix("/repos/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Use git service if git is trying to clone. Otherwise show http.FileServer for convenience
 		for _, suffix := range []string{"/info/refs", "/git-upload-pack"} {
-			if strings.HasSuffix(r.URL.Path, suffix) {
+			if strings.HasSuffix("/"+r.URL.Path, suffix) {
 				svc.ServeHTTP(w, r)
 				return
 			}
@@ -130,92 +155,235 @@ func (s *Serve) handler() http.Handler {
 	})
 }
 
-// Repos returns a slice of all the git repositories it finds.
+// Repos returns a slice of all the git repositories it finds. Repositories are
+// working trees, bare repositories, and the submodules of working trees.
+// Symlinked directories are followed, and Depth limits how deep below Root
+// repositories are searched for.
 func (s *Serve) Repos() ([]Repo, error) {
-	var repos []Repo
-	var reposRootIsRepo bool
-
 	root, err := filepath.EvalSymlinks(s.Root)
 	if err != nil {
 		s.Info.Printf("WARN: ignoring error searching %s: %v", root, err)
 		return nil, nil
 	}
 
-	err = filepath.Walk(root, func(path string, fi os.FileInfo, fileErr error) error {
-		if fileErr != nil {
-			s.Info.Printf("WARN: ignoring error searching %s: %v", path, fileErr)
-			return nil
-		}
-		if !fi.IsDir() {
-			return nil
+	w := &repoWalker{serve: s, root: root, visited: map[string]bool{}}
+	w.walk(root, 0)
+
+	if !w.rootIsRepo {
+		return w.repos, nil
+	}
+
+	// Update all names to be relative to the parent of reposRoot. This is to
+	// give a better name than "." for repos root
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the absolute path of reposRoot: %w", err)
+	}
+	rootName := filepath.Base(abs)
+	for i := range w.repos {
+		w.repos[i].Name = pathpkg.Join(rootName, w.repos[i].Name)
+	}
+
+	return w.repos, nil
+}
+
+// repoKind is the kind of git repository a directory is.
+type repoKind int
+
+const (
+	notRepo repoKind = iota
+	// workTree is a working tree, with a .git directory or a .git file that
+	// points to the git directory, as submodules have.
+	workTree
+	// bareRepo is a bare repository.
+	bareRepo
+	// bareGitDir is a directory that contains a bare repository as its .git
+	// directory. Other repositories can be nested in it.
+	bareGitDir
+)
+
+type repoWalker struct {
+	serve *Serve
+	root  string
+
+	// visited are the directories that were searched, after resolving
+	// symlinks, so that symlink loops and directories linked to more than
+	// once are only searched once.
+	visited    map[string]bool
+	repos      []Repo
+	rootIsRepo bool
+}
+
+func (w *repoWalker) walk(path string, depth int) {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		w.serve.Info.Printf("WARN: ignoring error searching %s: %v", path, err)
+		return
+	}
+	if w.visited[real] {
+		return
+	}
+	w.visited[real] = true
+
+	kind := w.serve.repoKind(path)
+	if kind == notRepo {
+		w.serve.Debug.Printf("not a repository root: %s", path)
+	} else {
+		w.add(path)
+	}
+
+	switch kind {
+	case workTree:
+		// The subdirectories of a working tree belong to it, except for its
+		// submodules.
+		for _, sub := range w.serve.submodules(path) {
+			w.walk(filepath.Join(path, sub), depth+1)
 		}
+		return
+	case bareRepo:
+		return
+	}
 
+	if w.serve.Depth > 0 && depth >= w.serve.Depth {
+		return
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		w.serve.Info.Printf("WARN: ignoring error searching %s: %v", path, err)
+		return
+	}
+	for _, entry := range entries {
 		// We recurse into bare repositories to find subprojects. Prevent
 		// recursing into .git
-		if filepath.Base(path) == ".git" {
-			return filepath.SkipDir
+		if entry.Name() == ".git" {
+			continue
 		}
 
-		// Check whether a particular directory is a repository or not.
-		//
-		// A directory which also is a repository (have .git folder inside it)
-		// will contain nil error. If it does, proceed to configure.
-		gitdir := filepath.Join(path, ".git")
-		if fi, err := os.Stat(gitdir); err != nil || !fi.IsDir() {
-			s.Debug.Printf("not a repository root: %s", path)
-			return nil
+		child := filepath.Join(path, entry.Name())
+		if entry.Type()&os.ModeSymlink != 0 {
+			fi, err := os.Stat(child)
+			if err != nil {
+				w.serve.Info.Printf("WARN: ignoring error searching %s: %v", child, err)
+				continue
+			}
+			if !fi.IsDir() {
+				continue
+			}
+		} else if !entry.IsDir() {
+			continue
 		}
+		w.walk(child, depth+1)
+	}
+}
 
-		subpath, err := filepath.Rel(root, path)
-		if err != nil {
-			// According to WalkFunc docs, path is always filepath.Join(root,
-			// subpath). So Rel should always work.
-			s.Info.Fatalf("filepath.Walk returned %s which is not relative to %s: %v", path, root, err)
-		}
+func (w *repoWalker) add(path string) {
+	subpath, err := filepath.Rel(w.root, path)
+	if err != nil {
+		// The walker only visits paths joined to root, so Rel should always
+		// work.
+		w.serve.Info.Fatalf("found repository %s which is not relative to %s: %v", path, w.root, err)
+	}
 
-		name := filepath.ToSlash(subpath)
-		reposRootIsRepo = reposRootIsRepo || name == "."
-		repos = append(repos, Repo{
-			Name: name,
-			URI:  pathpkg.Join("/repos", name),
-		})
+	uri := filepath.ToSlash(subpath)
+	// Bare repositories are conventionally named with a .git suffix, which
+	// isn't part of the name of the repository.
+	name := uri
+	if name != "." {
+		name = strings.TrimSuffix(name, ".git")
+	}
+	w.rootIsRepo = w.rootIsRepo || name == "."
+	w.repos = append(w.repos, Repo{
+		Name: name,
+		URI:  pathpkg.Join("/repos", uri),
+	})
+}
 
+// repoKind returns which kind of repository the directory at path is.
+func (s *Serve) repoKind(path string) repoKind {
+	gitdir := filepath.Join(path, ".git")
+	fi, err := os.Stat(gitdir)
+	switch {
+	case err == nil && fi.IsDir():
 		// Check whether a repository is a bare repository or not.
-		//
-		// If it yields false, which means it is a non-bare repository,
-		// skip the directory so that it will not recurse to the subdirectories.
-		// If it is a bare repository, proceed to recurse.
 		c := exec.Command("git", "rev-parse", "--is-bare-repository")
 		c.Dir = gitdir
 		out, _ := c.CombinedOutput()
-
 		if string(out) == "false\n" {
-			return filepath.SkipDir
+			return workTree
 		}
+		return bareGitDir
 
-		return nil
-	})
+	case err == nil:
+		// Submodules and linked worktrees have a .git file pointing to their
+		// git directory. Other files named .git are ignored.
+		if gitFileDir(gitdir) != "" {
+			return workTree
+		}
+		return notRepo
+	}
 
+	// A bare repository has HEAD, objects and refs at its top level.
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(path, name)); err != nil {
+			return notRepo
+		}
+	}
+	return bareRepo
+}
+
+// gitFileDir returns the git directory that the .git file at path points to,
+// or "" if the file doesn't point to an existing directory.
+func gitFileDir(path string) string {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, err
+		return ""
+	}
+	content := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(content, "gitdir:") {
+		return ""
+	}
+	dir := strings.TrimSpace(strings.TrimPrefix(content, "gitdir:"))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(filepath.Dir(path), dir)
+	}
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		return ""
 	}
+	return dir
+}
 
-	if !reposRootIsRepo {
-		return repos, nil
+// submodules returns the paths of the submodules of the working tree at path
+// that are checked out.
+func (s *Serve) submodules(path string) []string {
+	if _, err := os.Stat(filepath.Join(path, ".gitmodules")); err != nil {
+		return nil
 	}
 
-	// Update all names to be relative to the parent of reposRoot. This is to
-	// give a better name than "." for repos root
-	abs, err := filepath.Abs(root)
+	c := exec.Command("git", "config", "--file", ".gitmodules", "--get-regexp", `^submodule\..*\.path$`)
+	c.Dir = path
+	out, err := c.Output()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get the absolute path of reposRoot: %w", err)
-	}
-	rootName := filepath.Base(abs)
-	for i := range repos {
-		repos[i].Name = pathpkg.Join(rootName, repos[i].Name)
+		s.Debug.Printf("reading submodules of %s: %v", path, err)
+		return nil
 	}
 
-	return repos, nil
+	var paths []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		sub := filepath.FromSlash(fields[1])
+		if gitFileDir(filepath.Join(path, sub, ".git")) == "" {
+			if fi, err := os.Stat(filepath.Join(path, sub, ".git")); err != nil || !fi.IsDir() {
+				s.Debug.Printf("submodule %s of %s isn't checked out", sub, path)
+				continue
+			}
+		}
+		paths = append(paths, sub)
+	}
+	return paths
 }
 
 func explainAddr(addr string) string {
@@ -221,6 +221,92 @@ func TestIgnoreGitSubmodules(t *testing.T) {
 	}
 }
 
+func TestReposLayouts(t *testing.T) {
+	root := gitInitRepos(t)
+	run := func(dir string, args ...string) {
+		t.Helper()
+		c := exec.Command("git", args...)
+		c.Dir = dir
+		if out, err := c.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	mkdir := func(path string) string {
+		t.Helper()
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// A bare mirror, named with a .git suffix.
+	run(mkdir("mirrors"), "init", "--bare", "github.com-org-api.git")
+
+	// A working tree with a checked out submodule.
+	app := mkdir("app")
+	run(app, "init")
+	if err := os.WriteFile(filepath.Join(app, ".gitmodules"), []byte("[submodule \"lib\"]\n\tpath = vendor/lib\n\turl = ../lib\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run(mkdir("app/.git/modules"), "init", "--bare", "lib")
+	mkdir("app/vendor/lib")
+	if err := os.WriteFile(filepath.Join(app, "vendor", "lib", ".git"), []byte("gitdir: ../../.git/modules/lib\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A directory of repositories linked into the root, and a symlink loop.
+	linked := gitInitRepos(t, "tool")
+	if err := os.Symlink(linked, filepath.Join(root, "linked")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(root, filepath.Join(mkdir("loop"), "root")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A repository too deep for a depth limit of 2.
+	run(mkdir("a/b"), "init", "c")
+
+	for _, tc := range []struct {
+		depth int
+		want  []Repo
+	}{
+		{
+			depth: 0,
+			want: []Repo{
+				{Name: "a/b/c", URI: "/repos/a/b/c"},
+				{Name: "app", URI: "/repos/app"},
+				{Name: "app/vendor/lib", URI: "/repos/app/vendor/lib"},
+				{Name: "linked/tool", URI: "/repos/linked/tool"},
+				{Name: "mirrors/github.com-org-api", URI: "/repos/mirrors/github.com-org-api.git"},
+			},
+		},
+		{
+			depth: 2,
+			want: []Repo{
+				{Name: "app", URI: "/repos/app"},
+				{Name: "app/vendor/lib", URI: "/repos/app/vendor/lib"},
+				{Name: "linked/tool", URI: "/repos/linked/tool"},
+				{Name: "mirrors/github.com-org-api", URI: "/repos/mirrors/github.com-org-api.git"},
+			},
+		},
+	} {
+		repos, err := (&Serve{
+			Info:  testLogger(t),
+			Debug: discardLogger,
+			Root:  root,
+			Depth: tc.depth,
+		}).Repos()
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts := cmpopts.SortSlices(func(a, b Repo) bool { return a.Name < b.Name })
+		if diff := cmp.Diff(tc.want, repos, opts); diff != "" {
+			t.Errorf("depth %d: wrong repos (-want +have):\n%s", tc.depth, diff)
+		}
+	}
+}
+
 func testLogger(t *testing.T) *log.Logger {
 	return log.New(testWriter{t}, "testLogger ", log.LstdFlags)
 }
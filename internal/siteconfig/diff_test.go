@@ -0,0 +1,35 @@
+package siteconfig
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiff(t *testing.T) {
+	if diff := Diff("a", "b", "x\ny\n", "x\ny\n"); diff != "" {
+		t.Errorf("unexpected diff of equal texts:\n%s", diff)
+	}
+
+	old := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n"
+	new := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\n"
+	want := `--- old
++++ new
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -13,3 +13,4 @@
+ 13
+ 14
+ 15
++16
+`
+	if diff := cmp.Diff(want, Diff("old", "new", old, new)); diff != "" {
+		t.Errorf("wrong diff (-want +have):\n%s", diff)
+	}
+}
@@ -0,0 +1,317 @@
+// Package siteconfig edits the site configuration of Sourcegraph instances,
+// which is JSON with comments and trailing commas.
+package siteconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sourcegraph/jsonx"
+)
+
+// Operation is an operation of a JSON Patch, as defined by RFC 6902.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// ParsePatch parses a JSON Patch document, which is a list of operations.
+func ParsePatch(data []byte) ([]Operation, error) {
+	var patch []Operation
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil, errors.Wrap(err, "parsing JSON Patch")
+	}
+	for i, op := range patch {
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, errors.Newf("operation %d: %s requires a value", i+1, op.Op)
+			}
+		case "move", "copy":
+			if op.From == "" {
+				return nil, errors.Newf("operation %d: %s requires from", i+1, op.Op)
+			}
+		case "remove":
+		default:
+			return nil, errors.Newf("operation %d: unknown operation %q", i+1, op.Op)
+		}
+	}
+	return patch, nil
+}
+
+var formatOptions = jsonx.FormatOptions{InsertSpaces: true, TabSize: 2}
+
+// ApplyPatch applies the operations of a JSON Patch to the JSON document text,
+// which can contain comments and trailing commas. Comments and formatting
+// outside of the changed values are kept. If an operation fails, including a
+// test operation, an error is returned and none of the operations apply.
+func ApplyPatch(text string, patch []Operation) (string, error) {
+	for i, op := range patch {
+		var err error
+		text, err = applyOperation(text, op)
+		if err != nil {
+			return "", errors.Wrapf(err, "operation %d (%s %s)", i+1, op.Op, op.Path)
+		}
+	}
+	return text, nil
+}
+
+func applyOperation(text string, op Operation) (string, error) {
+	switch op.Op {
+	case "add":
+		return add(text, op.Path, op.Value)
+
+	case "replace":
+		return replace(text, op.Path, op.Value)
+
+	case "remove":
+		return remove(text, op.Path)
+
+	case "copy", "move":
+		value, err := get(text, op.From)
+		if err != nil {
+			return "", errors.Wrap(err, "from")
+		}
+		if op.Op == "move" {
+			if op.Path == op.From {
+				return text, nil
+			}
+			if strings.HasPrefix(op.Path, op.From+"/") {
+				return "", errors.New("can't move a value into itself")
+			}
+			if text, err = remove(text, op.From); err != nil {
+				return "", err
+			}
+		}
+		return add(text, op.Path, value)
+
+	case "test":
+		value, err := get(text, op.Path)
+		if err != nil {
+			return "", err
+		}
+		equal, err := jsonEqual(value, op.Value)
+		if err != nil {
+			return "", err
+		}
+		if !equal {
+			return "", errors.Newf("value is %s, not %s", compact(value), compact(op.Value))
+		}
+		return text, nil
+
+	default:
+		return "", errors.Newf("unknown operation %q", op.Op)
+	}
+}
+
+// get returns the value at the JSON Pointer as it's written in text.
+func get(text, pointer string) (json.RawMessage, error) {
+	root, err := parseTree(text)
+	if err != nil {
+		return nil, err
+	}
+	path, err := resolvePointer(root, pointer, false)
+	if err != nil {
+		return nil, err
+	}
+	node := jsonx.FindNodeAtLocation(root, path)
+	if node == nil {
+		return nil, errors.Newf("%s doesn't exist", pointer)
+	}
+	return json.RawMessage(string([]rune(text)[node.Offset : node.Offset+node.Length])), nil
+}
+
+// add sets the value at the JSON Pointer, adding object properties and
+// inserting array elements as needed.
+func add(text, pointer string, value json.RawMessage) (string, error) {
+	if pointer == "" {
+		return format(string(value))
+	}
+
+	root, err := parseTree(text)
+	if err != nil {
+		return "", err
+	}
+	path, err := resolvePointer(root, pointer, true)
+	if err != nil {
+		return "", err
+	}
+
+	last := path[len(path)-1]
+	parent := jsonx.FindNodeAtLocation(root, path[:len(path)-1])
+	if parent == nil {
+		return "", errors.Newf("the parent of %s doesn't exist", pointer)
+	}
+
+	var edits []jsonx.Edit
+	if !last.IsProperty && last.Index >= 0 && last.Index < len(parent.Children) {
+		// Insert before the element at the index, which jsonx doesn't
+		// support, as it replaces elements at existing indexes.
+		next := parent.Children[last.Index]
+		edits, err = jsonx.FormatEdit(text, jsonx.Edit{Offset: next.Offset, Content: string(value) + ","}, formatOptions)
+	} else {
+		edits, _, err = jsonx.ComputePropertyEdit(text, path, value, nil, formatOptions)
+	}
+	if err != nil {
+		return "", err
+	}
+	return jsonx.ApplyEdits(text, edits...)
+}
+
+// replace replaces the existing value at the JSON Pointer.
+func replace(text, pointer string, value json.RawMessage) (string, error) {
+	if pointer == "" {
+		return format(string(value))
+	}
+	if _, err := get(text, pointer); err != nil {
+		return "", err
+	}
+
+	root, err := parseTree(text)
+	if err != nil {
+		return "", err
+	}
+	path, err := resolvePointer(root, pointer, false)
+	if err != nil {
+		return "", err
+	}
+	edits, _, err := jsonx.ComputePropertyEdit(text, path, value, nil, formatOptions)
+	if err != nil {
+		return "", err
+	}
+	return jsonx.ApplyEdits(text, edits...)
+}
+
+// remove removes the value at the JSON Pointer.
+func remove(text, pointer string) (string, error) {
+	if pointer == "" {
+		return "", errors.New("can't remove the whole document")
+	}
+	if _, err := get(text, pointer); err != nil {
+		return "", err
+	}
+
+	root, err := parseTree(text)
+	if err != nil {
+		return "", err
+	}
+	path, err := resolvePointer(root, pointer, false)
+	if err != nil {
+		return "", err
+	}
+	edits, _, err := jsonx.ComputePropertyRemoval(text, path, formatOptions)
+	if err != nil {
+		return "", err
+	}
+	return jsonx.ApplyEdits(text, edits...)
+}
+
+// resolvePointer turns a JSON Pointer (RFC 6901) into a path in the document.
+// Whether a token is an object property or an array index depends on the
+// value it's applied to. With forAdd, the last token can be "-" or the length
+// of an array to append to it, which gives the index -1.
+func resolvePointer(root *jsonx.Node, pointer string, forAdd bool) (jsonx.Path, error) {
+	if pointer == "" {
+		return jsonx.Path{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, errors.Newf("invalid JSON Pointer %q: must start with /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	path := make(jsonx.Path, 0, len(tokens))
+	node := root
+	for i, token := range tokens {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		isLast := i == len(tokens)-1
+
+		if node == nil || node.Type != jsonx.Array {
+			path = append(path, jsonx.Segment{IsProperty: true, Property: token})
+		} else {
+			index, err := arrayIndex(token, len(node.Children), forAdd && isLast)
+			if err != nil {
+				return nil, errors.Wrapf(err, "%s", pointer)
+			}
+			path = append(path, jsonx.Segment{Index: index})
+		}
+		node = jsonx.FindNodeAtLocation(root, path)
+	}
+	return path, nil
+}
+
+func arrayIndex(token string, length int, forAdd bool) (int, error) {
+	if token == "-" && forAdd {
+		return -1, nil
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, errors.Newf("invalid array index %q", token)
+	}
+	if forAdd && index == length {
+		return -1, nil
+	}
+	if index >= length {
+		return 0, errors.Newf("array index %d out of range", index)
+	}
+	return index, nil
+}
+
+// parseTree parses text, which must be valid JSON with comments and trailing
+// commas.
+func parseTree(text string) (*jsonx.Node, error) {
+	root, errs := jsonx.ParseTree(text, jsonx.ParseOptions{Comments: true, TrailingCommas: true})
+	if len(errs) > 0 {
+		return nil, errors.Newf("invalid JSON: %v", errs)
+	}
+	return root, nil
+}
+
+// Validate returns an error if text isn't valid JSON with comments and
+// trailing commas.
+func Validate(text string) error {
+	_, errs := jsonx.ParseWithDetailedErrors(text, jsonx.ParseOptions{Comments: true, TrailingCommas: true})
+	if len(errs) > 0 {
+		return errors.Newf("invalid JSON: %v", errs)
+	}
+	return nil
+}
+
+func format(text string) (string, error) {
+	return jsonx.ApplyEdits(text, jsonx.Format(text, formatOptions)...)
+}
+
+func jsonEqual(a, b json.RawMessage) (bool, error) {
+	var va, vb interface{}
+	if err := unmarshal(a, &va); err != nil {
+		return false, err
+	}
+	if err := unmarshal(b, &vb); err != nil {
+		return false, err
+	}
+	ja, _ := json.Marshal(va)
+	jb, _ := json.Marshal(vb)
+	return bytes.Equal(ja, jb), nil
+}
+
+// unmarshal unmarshals a value that can contain comments and trailing commas.
+func unmarshal(data json.RawMessage, v interface{}) error {
+	plain, errs := jsonx.Parse(string(data), jsonx.ParseOptions{Comments: true, TrailingCommas: true})
+	if len(errs) > 0 {
+		return errors.Newf("invalid JSON: %v", errs)
+	}
+	return json.Unmarshal(plain, v)
+}
+
+func compact(data json.RawMessage) string {
+	var v interface{}
+	if err := unmarshal(data, &v); err != nil {
+		return string(data)
+	}
+	out, _ := json.Marshal(v)
+	return string(out)
+}
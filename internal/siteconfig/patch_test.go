@@ -0,0 +1,132 @@
+package siteconfig
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestApplyPatch(t *testing.T) {
+	const config = `{
+  // The URL of the instance.
+  "externalURL": "https://sourcegraph.example.com",
+  "auth.providers": [
+    {"type": "builtin"},
+  ],
+  "search.limits": {"maxRepos": 100},
+}`
+
+	for name, tc := range map[string]struct {
+		patch string
+		want  string
+	}{
+		"replace property": {
+			patch: `[{"op": "test", "path": "/externalURL", "value": "https://sourcegraph.example.com"},
+			         {"op": "replace", "path": "/externalURL", "value": "https://sg.example.com"}]`,
+			want: `{
+  // The URL of the instance.
+  "externalURL": "https://sg.example.com",
+  "auth.providers": [
+    {"type": "builtin"},
+  ],
+  "search.limits": {"maxRepos": 100},
+}`,
+		},
+		"add property": {
+			patch: `[{"op": "add", "path": "/search.limits/maxRepos~1user", "value": 10}]`,
+			want: `{
+  // The URL of the instance.
+  "externalURL": "https://sourcegraph.example.com",
+  "auth.providers": [
+    {"type": "builtin"},
+  ],
+  "search.limits": {
+    "maxRepos": 100,
+    "maxRepos/user": 10
+  },
+}`,
+		},
+		"insert and append array elements": {
+			patch: `[{"op": "add", "path": "/auth.providers/0", "value": {"type": "github"}},
+			         {"op": "add", "path": "/auth.providers/-", "value": {"type": "saml"}}]`,
+			want: `{
+  // The URL of the instance.
+  "externalURL": "https://sourcegraph.example.com",
+  "auth.providers": [
+    {
+      "type": "github"
+    },
+    {
+      "type": "builtin"
+    },
+    {
+      "type": "saml"
+    },
+  ],
+  "search.limits": {"maxRepos": 100},
+}`,
+		},
+		// Comments before removed properties are removed with them.
+		"remove and move": {
+			patch: `[{"op": "remove", "path": "/search.limits"},
+			         {"op": "move", "from": "/externalURL", "path": "/oldExternalURL"}]`,
+			want: `{
+  "auth.providers": [
+    {"type": "builtin"},
+  ],
+  "oldExternalURL": "https://sourcegraph.example.com",
+}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			patch, err := ParsePatch([]byte(tc.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			have, err := ApplyPatch(config, patch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, have); diff != "" {
+				t.Errorf("wrong config (-want +have):\n%s", diff)
+			}
+			if err := Validate(have); err != nil {
+				t.Errorf("patched config is invalid: %s", err)
+			}
+		})
+	}
+
+	for name, patch := range map[string]string{
+		"failed test":         `[{"op": "test", "path": "/search.limits/maxRepos", "value": 99}]`,
+		"missing property":    `[{"op": "replace", "path": "/missing", "value": 1}]`,
+		"missing parent":      `[{"op": "add", "path": "/missing/child", "value": 1}]`,
+		"index out of range":  `[{"op": "add", "path": "/auth.providers/2", "value": 1}]`,
+		"invalid index":       `[{"op": "remove", "path": "/auth.providers/01"}]`,
+		"invalid pointer":     `[{"op": "remove", "path": "externalURL"}]`,
+		"move into itself":    `[{"op": "move", "from": "/search.limits", "path": "/search.limits/x"}]`,
+		"remove the document": `[{"op": "remove", "path": ""}]`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			ops, err := ParsePatch([]byte(patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ApplyPatch(config, ops); err == nil {
+				t.Error("unexpected nil error")
+			}
+		})
+	}
+}
+
+func TestParsePatch(t *testing.T) {
+	for _, patch := range []string{
+		`{"op": "add"}`,
+		`[{"op": "add", "path": "/a"}]`,
+		`[{"op": "copy", "path": "/a"}]`,
+		`[{"op": "merge", "path": "/a"}]`,
+	} {
+		if _, err := ParsePatch([]byte(patch)); err == nil {
+			t.Errorf("unexpected nil error for %s", patch)
+		}
+	}
+}
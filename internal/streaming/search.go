@@ -8,6 +8,7 @@ import (
 	"strconv"
 
 	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/searchfilter"
 )
 
 // Opts contains the search options supported by Search.
@@ -15,13 +16,22 @@ type Opts struct {
 	Display int
 	Trace   bool
 	Json    bool
+	// Filter, if set, drops file matches before they're decoded. It can only
+	// use the matching lines of files, not their content.
+	Filter *searchfilter.Filter
 }
 
 // Search calls the streaming search endpoint and uses decoder to decode the
 // response body.
 func Search(query string, opts Opts, client api.Client, decoder Decoder) error {
+	return SearchContext(context.Background(), query, opts, client, decoder)
+}
+
+// SearchContext is Search with a context, which cancels the search when it's
+// done.
+func SearchContext(ctx context.Context, query string, opts Opts, client api.Client, decoder Decoder) error {
 	// Create request.
-	req, err := client.NewHTTPRequest(context.Background(), "GET", "search/stream?q="+url.QueryEscape(query), nil)
+	req, err := client.NewHTTPRequest(ctx, "GET", "search/stream?q="+url.QueryEscape(query), nil)
 	if err != nil {
 		return err
 	}
@@ -40,6 +50,14 @@ func Search(query string, opts Opts, client api.Client, decoder Decoder) error {
 	defer resp.Body.Close()
 
 	// Process response.
+	if opts.Filter.IsSet() && decoder.OnMatches != nil {
+		onMatches := decoder.OnMatches
+		decoder.OnMatches = func(matches []EventMatch) {
+			if matches = filterMatches(opts.Filter, matches); len(matches) > 0 {
+				onMatches(matches)
+			}
+		}
+	}
 	err = decoder.ReadAll(resp.Body)
 	if err != nil {
 		return fmt.Errorf("error during decoding: %w", err)
@@ -54,3 +72,28 @@ func Search(query string, opts Opts, client api.Client, decoder Decoder) error {
 	}
 	return nil
 }
+
+// filterMatches returns the matches that the filter keeps. Matches that
+// aren't of files are always kept.
+func filterMatches(f *searchfilter.Filter, matches []EventMatch) []EventMatch {
+	kept := matches[:0]
+	for _, m := range matches {
+		keep := true
+		switch m := m.(type) {
+		case *EventContentMatch:
+			lines := make([]string, len(m.LineMatches))
+			for i, l := range m.LineMatches {
+				lines[i] = l.Line
+			}
+			keep = f.KeepLines(m.Path, lines)
+		case *EventPathMatch:
+			keep = f.KeepPath(m.Path)
+		case *EventSymbolMatch:
+			keep = f.KeepPath(m.Path)
+		}
+		if keep {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
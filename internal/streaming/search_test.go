@@ -0,0 +1,33 @@
+package streaming
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/sourcegraph/src-cli/internal/searchfilter"
+)
+
+func TestFilterMatches(t *testing.T) {
+	matches := []EventMatch{
+		&EventContentMatch{Path: "main.go", LineMatches: []EventLineMatch{{Line: "return nil"}}},
+		&EventContentMatch{Path: "vendor/lib/lib.go", LineMatches: []EventLineMatch{{Line: "return nil"}}},
+		&EventContentMatch{Path: "image.png", LineMatches: []EventLineMatch{{Line: "\x89PNG\x00"}}},
+		&EventPathMatch{Path: "docs/README.md"},
+		&EventSymbolMatch{Path: "main.go"},
+		&EventRepoMatch{Repository: "org/docs"},
+	}
+
+	f := &searchfilter.Filter{
+		Exclude:       regexp.MustCompile(`^docs/`),
+		SkipBinary:    true,
+		SkipGenerated: true,
+	}
+	have := filterMatches(f, matches)
+
+	want := []EventMatch{matches[0], matches[4], matches[5]}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Errorf("wrong matches (-want +have):\n%s", diff)
+	}
+}
@@ -0,0 +1,177 @@
+// Package terminal decides how src renders output for the terminal, or the
+// log collector, it writes to.
+package terminal
+
+import (
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Plain returns true if output should be plain and line-oriented, because src
+// runs in CI, where log collectors don't understand ANSI escape codes, on a
+// dumb terminal, or with a locale that isn't UTF-8, where the glyphs of the
+// progress bars aren't rendered.
+func Plain(getenv func(string) string) bool {
+	if ci := getenv("CI"); ci != "" && ci != "false" && ci != "0" {
+		return true
+	}
+	if getenv("TERM") == "dumb" {
+		return true
+	}
+	return !UTF8(getenv)
+}
+
+// UTF8 returns true if the locale of the environment uses UTF-8. Like the C
+// library, it uses the first of LC_ALL, LC_CTYPE and LANG that is set. If
+// none are set, UTF-8 is assumed, since that's what the terminals of
+// environments without a configured locale, such as containers, use.
+func UTF8(getenv func(string) string) bool {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		locale := getenv(name)
+		if locale == "" {
+			continue
+		}
+		locale = strings.ToLower(locale)
+		return strings.Contains(locale, "utf-8") || strings.Contains(locale, "utf8")
+	}
+	return true
+}
+
+// PlainWriter is an io.Writer that removes ANSI escape codes from what's
+// written to it, and optionally replaces characters that aren't ASCII.
+// Escape codes and characters split across writes are handled.
+type PlainWriter struct {
+	w     io.Writer
+	ascii bool
+
+	pending []byte
+}
+
+// NewPlainWriter returns a PlainWriter that writes to w. If ascii is true,
+// characters that aren't ASCII are replaced by ASCII lookalikes, or a
+// question mark if there is none.
+func NewPlainWriter(w io.Writer, ascii bool) *PlainWriter {
+	return &PlainWriter{w: w, ascii: ascii}
+}
+
+func (pw *PlainWriter) Write(p []byte) (int, error) {
+	data := append(pw.pending, p...)
+	pw.pending = nil
+
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); {
+		switch b := data[i]; {
+		case b == 0x1b:
+			n, complete := escapeLength(data[i:])
+			if !complete {
+				pw.pending = append(pw.pending, data[i:]...)
+				i = len(data)
+				continue
+			}
+			i += n
+
+		case b >= utf8.RuneSelf:
+			if !utf8.FullRune(data[i:]) {
+				pw.pending = append(pw.pending, data[i:]...)
+				i = len(data)
+				continue
+			}
+			r, n := utf8.DecodeRune(data[i:])
+			if pw.ascii {
+				out = append(out, asciiReplacement(r)...)
+			} else {
+				out = append(out, data[i:i+n]...)
+			}
+			i += n
+
+		default:
+			out = append(out, b)
+			i++
+		}
+	}
+
+	if _, err := pw.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// escapeLength returns the length of the escape sequence at the start of
+// data, and false if data ends before the sequence does.
+func escapeLength(data []byte) (int, bool) {
+	if len(data) < 2 {
+		return 0, false
+	}
+
+	switch data[1] {
+	case '[':
+		// Control sequences end with a byte in the range @ to ~.
+		for i := 2; i < len(data); i++ {
+			if data[i] >= 0x40 && data[i] <= 0x7e {
+				return i + 1, true
+			}
+		}
+		return 0, false
+
+	case ']':
+		// Operating system commands end with BEL or ESC \.
+		for i := 2; i < len(data); i++ {
+			if data[i] == 0x07 {
+				return i + 1, true
+			}
+			if data[i] == 0x1b && i+1 < len(data) && data[i+1] == '\\' {
+				return i + 2, true
+			}
+		}
+		return 0, false
+
+	default:
+		return 2, true
+	}
+}
+
+// asciiReplacements are the ASCII lookalikes of the characters used by the
+// output of src.
+var asciiReplacements = map[rune]string{
+	'│': "|",
+	'├': "|",
+	'└': "`",
+	'┌': "+",
+	'─': "-",
+	'█': "#",
+	'…': "...",
+	'‘': "'",
+	'’': "'",
+	'“': `"`,
+	'”': `"`,
+	'–': "-",
+	'—': "-",
+	'✅': "[ok]",
+	'✔': "[ok]",
+	'❌': "[x]",
+	'✘': "[x]",
+	'❗': "[!]",
+	'⚠': "[!]",
+	'ℹ': "[i]",
+	'💡': "[i]",
+	'⏳': "[...]",
+	'🚀': "[>]",
+}
+
+func asciiReplacement(r rune) string {
+	if s, ok := asciiReplacements[r]; ok {
+		return s
+	}
+	switch {
+	case r >= 0x2800 && r <= 0x28ff:
+		// Braille patterns are used by spinners.
+		return "*"
+	case unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Variation_Selector, r) || r == '\u200d':
+		// Combining marks and modifiers of emojis have no width of their own.
+		return ""
+	default:
+		return "?"
+	}
+}
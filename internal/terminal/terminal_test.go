@@ -0,0 +1,92 @@
+package terminal
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPlain(t *testing.T) {
+	for name, tc := range map[string]struct {
+		env  map[string]string
+		want bool
+	}{
+		"no environment":    {env: map[string]string{}, want: false},
+		"UTF-8 locale":      {env: map[string]string{"LANG": "en_US.UTF-8"}, want: false},
+		"utf8 locale":       {env: map[string]string{"LC_ALL": "de_DE.utf8"}, want: false},
+		"C locale":          {env: map[string]string{"LANG": "C"}, want: true},
+		"LC_ALL overrides":  {env: map[string]string{"LC_ALL": "POSIX", "LANG": "en_US.UTF-8"}, want: true},
+		"LC_CTYPE":          {env: map[string]string{"LC_CTYPE": "ja_JP.eucJP", "LANG": "en_US.UTF-8"}, want: true},
+		"CI":                {env: map[string]string{"CI": "true", "LANG": "en_US.UTF-8"}, want: true},
+		"CI disabled":       {env: map[string]string{"CI": "false"}, want: false},
+		"dumb terminal":     {env: map[string]string{"TERM": "dumb"}, want: true},
+		"capable terminal":  {env: map[string]string{"TERM": "xterm-256color"}, want: false},
+		"empty locale vars": {env: map[string]string{"LC_ALL": "", "LANG": "en_GB.UTF-8"}, want: false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			getenv := func(name string) string { return tc.env[name] }
+			if have := Plain(getenv); have != tc.want {
+				t.Errorf("wrong result: want %t, have %t", tc.want, have)
+			}
+		})
+	}
+}
+
+func TestPlainWriter(t *testing.T) {
+	for name, tc := range map[string]struct {
+		writes []string
+		ascii  bool
+		want   string
+	}{
+		"plain text": {
+			writes: []string{"hello\n"},
+			want:   "hello\n",
+		},
+		"colors": {
+			writes: []string{"\x1b[1;32mdone\x1b[0m\n"},
+			want:   "done\n",
+		},
+		"cursor movement": {
+			writes: []string{"\x1b[2Kline\x1b[1A\x1b7\x1b8\n"},
+			want:   "line\n",
+		},
+		"hyperlink": {
+			writes: []string{"\x1b]8;;https://example.com\x07link\x1b]8;;\x1b\\\n"},
+			want:   "link\n",
+		},
+		"escape code split across writes": {
+			writes: []string{"a\x1b", "[3", "1mb\x1b[0", "m"},
+			want:   "ab",
+		},
+		"unicode kept": {
+			writes: []string{"├── github.com/sourcegraph/ソース ✅\n"},
+			want:   "├── github.com/sourcegraph/ソース ✅\n",
+		},
+		"unicode replaced": {
+			writes: []string{"├── github.com/sourcegraph/ソース ✅\n└── ⠋ ❗️\n"},
+			ascii:  true,
+			want:   "|-- github.com/sourcegraph/??? [ok]\n`-- * [!]\n",
+		},
+		"rune split across writes": {
+			writes: []string{"a\xe2\x94", "\x82b"},
+			ascii:  true,
+			want:   "a|b",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewPlainWriter(&buf, tc.ascii)
+			for _, s := range tc.writes {
+				n, err := w.Write([]byte(s))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n != len(s) {
+					t.Errorf("wrong length written: want %d, have %d", len(s), n)
+				}
+			}
+			if have := buf.String(); have != tc.want {
+				t.Errorf("wrong output:\nwant %q\nhave %q", tc.want, have)
+			}
+		})
+	}
+}
@@ -0,0 +1,374 @@
+// Package upgradecheck checks a Sourcegraph instance for known blockers of an
+// upgrade to another version, for `src admin upgrade-check`.
+package upgradecheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/cockroachdb/errors"
+	"github.com/sourcegraph/jsonx"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+const (
+	// StatusPass means that the check found nothing in the way of the
+	// upgrade.
+	StatusPass Status = "pass"
+	// StatusWarn means that the upgrade can go ahead, but something should be
+	// looked at first.
+	StatusWarn Status = "warn"
+	// StatusFail means that the upgrade must not go ahead.
+	StatusFail Status = "fail"
+	// StatusSkip means that the check couldn't be made, usually because the
+	// instance is too old to report what it needs.
+	StatusSkip Status = "skip"
+)
+
+// Result is the outcome of one check.
+type Result struct {
+	Check  string `json:"check"`
+	Status Status `json:"status"`
+	// Summary is a one-line description of the outcome, and Details are the
+	// individual findings, if any.
+	Summary string   `json:"summary"`
+	Details []string `json:"details,omitempty"`
+}
+
+// Report is the outcome of all checks of an upgrade.
+type Report struct {
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Ready   bool      `json:"ready"`
+	Results []*Result `json:"results"`
+}
+
+// Instance is what's known about the instance that's upgraded.
+type Instance struct {
+	// Version is the product version of the instance.
+	Version string
+	// SiteConfig is the effective site configuration, which is JSON with
+	// comments and trailing commas.
+	SiteConfig       string
+	ExternalServices []ExternalService
+	Migrations       []Migration
+	// SchemaDrift is whether the database schema differs from the schema of
+	// the version of the instance, or nil if the instance doesn't report it.
+	SchemaDrift *bool
+}
+
+// ExternalService is a code host connection, with its configuration.
+type ExternalService struct {
+	Kind        string
+	DisplayName string
+	Config      string
+}
+
+// Migration is an out-of-band migration, which runs in the background after a
+// version is deployed. It must complete before the version that deprecates it
+// is deployed, as that version no longer reads the unmigrated data.
+type Migration struct {
+	ID          string
+	Team        string
+	Component   string
+	Description string
+	Introduced  string
+	Deprecated  *string
+	Progress    float64
+	// ApplyReverse is set when the migration is being rolled back.
+	ApplyReverse bool
+	Errors       []MigrationError
+}
+
+type MigrationError struct {
+	Message string
+}
+
+// Check checks the instance for an upgrade to the version to. It returns an
+// error only if to isn't a valid version.
+func Check(instance *Instance, to string) (*Report, error) {
+	target, err := semver.NewVersion(strings.TrimPrefix(to, "v"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid target version %q", to)
+	}
+
+	report := &Report{
+		From: instance.Version,
+		To:   target.String(),
+		Results: []*Result{
+			checkVersion(instance.Version, target),
+			checkMigrations(instance.Migrations, target),
+			checkDeprecatedConfig(instance, target),
+			checkAuthProviders(instance.SiteConfig),
+			checkDatabase(instance.SchemaDrift),
+		},
+	}
+	report.Ready = true
+	for _, r := range report.Results {
+		if r.Status == StatusFail {
+			report.Ready = false
+		}
+	}
+	return report, nil
+}
+
+// checkVersion checks that the upgrade goes forward, and whether it can be a
+// standard upgrade, which goes to the next minor version at most.
+func checkVersion(version string, target *semver.Version) *Result {
+	r := &Result{Check: "version"}
+
+	current, err := semver.NewVersion(strings.TrimPrefix(version, "v"))
+	if err != nil || current.Metadata() == "dev" {
+		r.Status = StatusSkip
+		r.Summary = fmt.Sprintf("%q isn't a release version", version)
+		return r
+	}
+
+	switch {
+	case !target.GreaterThan(current):
+		r.Status = StatusFail
+		r.Summary = fmt.Sprintf("%s isn't newer than the current version %s", target, current)
+	case target.Major() != current.Major() || target.Minor() > current.Minor()+1:
+		r.Status = StatusWarn
+		r.Summary = fmt.Sprintf("%s to %s skips minor versions, which requires a multi-version upgrade with the migrator", current, target)
+	default:
+		r.Status = StatusPass
+		r.Summary = fmt.Sprintf("%s to %s is a standard upgrade", current, target)
+	}
+	return r
+}
+
+// checkMigrations checks that the out-of-band migrations that the target
+// version deprecates are complete.
+func checkMigrations(migrations []Migration, target *semver.Version) *Result {
+	r := &Result{Check: "out-of-band migrations"}
+
+	var blocking, failing int
+	for _, m := range migrations {
+		name := fmt.Sprintf("%s (%s, %s)", m.Description, m.Team, m.Component)
+		if m.Deprecated != nil && !m.ApplyReverse && m.Progress < 1 {
+			if deprecated, err := semver.NewVersion(*m.Deprecated); err == nil && !deprecated.GreaterThan(target) {
+				blocking++
+				r.Details = append(r.Details, fmt.Sprintf("%s is %.0f%% complete, but %s no longer supports unmigrated data", name, m.Progress*100, *m.Deprecated))
+			}
+		}
+		if len(m.Errors) > 0 {
+			failing++
+			r.Details = append(r.Details, fmt.Sprintf("%s reported an error: %s", name, m.Errors[len(m.Errors)-1].Message))
+		}
+	}
+
+	switch {
+	case blocking > 0:
+		r.Status = StatusFail
+		r.Summary = fmt.Sprintf("%d out-of-band migrations must complete before upgrading", blocking)
+	case failing > 0:
+		r.Status = StatusWarn
+		r.Summary = fmt.Sprintf("%d out-of-band migrations reported errors", failing)
+	default:
+		r.Status = StatusPass
+		r.Summary = fmt.Sprintf("all %d out-of-band migrations required by %s are complete", len(migrations), target)
+	}
+	return r
+}
+
+// deprecatedKey is a configuration setting that was deprecated, and possibly
+// removed.
+type deprecatedKey struct {
+	// key is the top-level key of the setting.
+	key string
+	// kinds are the external service kinds whose configuration the key is
+	// deprecated in, or empty for the site configuration.
+	kinds []string
+	// removed is the version that no longer supports the key, if any.
+	removed string
+	// use is what replaces the key.
+	use string
+}
+
+// appliesTo returns whether the key is deprecated in the configuration of the
+// external service kind, or in the site configuration if kind is empty.
+func (d deprecatedKey) appliesTo(kind string) bool {
+	if kind == "" {
+		return len(d.kinds) == 0
+	}
+	return contains(d.kinds, kind)
+}
+
+// deprecatedKeys are the known deprecated configuration settings.
+var deprecatedKeys = []deprecatedKey{
+	{key: "auth.provider", removed: "3.0.0", use: `"auth.providers"`},
+	{key: "auth.saml", removed: "3.0.0", use: `a "saml" entry of "auth.providers"`},
+	{key: "auth.openIDConnect", removed: "3.0.0", use: `an "openidconnect" entry of "auth.providers"`},
+	{key: "github", removed: "3.0.0", use: "a GitHub code host connection"},
+	{key: "gitlab", removed: "3.0.0", use: "a GitLab code host connection"},
+	{key: "bitbucketServer", removed: "3.0.0", use: "a Bitbucket Server code host connection"},
+	{key: "awsCodeCommit", removed: "3.0.0", use: "an AWS CodeCommit code host connection"},
+	{key: "gitolite", removed: "3.0.0", use: "a Gitolite code host connection"},
+	{key: "phabricator", removed: "3.0.0", use: "a Phabricator code host connection"},
+	{key: "repos.list", removed: "3.0.0", use: "an Other code host connection"},
+	{key: "campaigns.enabled", removed: "3.32.0", use: `"batchChanges.enabled"`},
+	{key: "campaigns.restrictToAdmins", removed: "3.32.0", use: `"batchChanges.restrictToAdmins"`},
+	{key: "useJaeger", removed: "4.0.0", use: `"observability.tracing"`},
+	{key: "lightstepAccessToken", removed: "4.0.0", use: `"observability.tracing"`},
+	{key: "lightstepProject", removed: "4.0.0", use: `"observability.tracing"`},
+	{key: "initialRepositoryEnablement", kinds: []string{"GITHUB", "GITLAB", "BITBUCKETSERVER", "AWSCODECOMMIT", "GITOLITE", "PHABRICATOR", "OTHER"}, use: "repository permissions or the repository settings"},
+}
+
+// checkDeprecatedConfig checks the site configuration and the configurations
+// of the external services for deprecated settings. Settings that the target
+// version removed fail the check.
+func checkDeprecatedConfig(instance *Instance, target *semver.Version) *Result {
+	r := &Result{Check: "deprecated configuration"}
+
+	var removed, deprecated int
+	report := func(where string, config string, kind string) {
+		keys, err := topLevelKeys(config)
+		if err != nil {
+			deprecated++
+			r.Details = append(r.Details, fmt.Sprintf("%s: %s", where, err))
+			return
+		}
+		for _, d := range deprecatedKeys {
+			if !keys[d.key] || !d.appliesTo(kind) {
+				continue
+			}
+			detail := fmt.Sprintf("%s: %q is deprecated", where, d.key)
+			if v, err := semver.NewVersion(d.removed); err == nil && !v.GreaterThan(target) {
+				removed++
+				detail = fmt.Sprintf("%s: %q was removed in %s", where, d.key, d.removed)
+			} else {
+				deprecated++
+			}
+			if d.use != "" {
+				detail += ", use " + d.use + " instead"
+			}
+			r.Details = append(r.Details, detail)
+		}
+	}
+
+	report("site configuration", instance.SiteConfig, "")
+	for _, svc := range instance.ExternalServices {
+		report(fmt.Sprintf("code host connection %q", svc.DisplayName), svc.Config, strings.ToUpper(svc.Kind))
+	}
+
+	switch {
+	case removed > 0:
+		r.Status = StatusFail
+		r.Summary = fmt.Sprintf("%d settings are no longer supported by %s", removed, target)
+	case deprecated > 0:
+		r.Status = StatusWarn
+		r.Summary = fmt.Sprintf("%d settings are deprecated", deprecated)
+	default:
+		r.Status = StatusPass
+		r.Summary = "no deprecated settings are used"
+	}
+	return r
+}
+
+// supportedAuthProviders are the types of auth providers in the
+// "auth.providers" setting that current versions support.
+var supportedAuthProviders = []string{
+	"builtin",
+	"saml",
+	"openidconnect",
+	"http-header",
+	"github",
+	"gitlab",
+	"bitbucketcloud",
+	"gerrit",
+	"azuredevops",
+}
+
+// checkAuthProviders checks that the auth providers of the site configuration
+// are supported.
+func checkAuthProviders(siteConfig string) *Result {
+	r := &Result{Check: "auth providers"}
+
+	var config struct {
+		AuthProviders []struct {
+			Type        string `json:"type"`
+			DisplayName string `json:"displayName"`
+		} `json:"auth.providers"`
+	}
+	if err := unmarshal(siteConfig, &config); err != nil {
+		r.Status = StatusSkip
+		r.Summary = "the site configuration couldn't be parsed: " + err.Error()
+		return r
+	}
+
+	for _, p := range config.AuthProviders {
+		if !contains(supportedAuthProviders, strings.ToLower(p.Type)) {
+			name := p.Type
+			if p.DisplayName != "" {
+				name = fmt.Sprintf("%s (%s)", p.Type, p.DisplayName)
+			}
+			r.Details = append(r.Details, fmt.Sprintf("auth provider %s isn't supported", name))
+		}
+	}
+
+	if len(r.Details) > 0 {
+		r.Status = StatusFail
+		r.Summary = fmt.Sprintf("%d auth providers aren't supported", len(r.Details))
+	} else {
+		r.Status = StatusPass
+		r.Summary = fmt.Sprintf("all %d auth providers are supported", len(config.AuthProviders))
+	}
+	return r
+}
+
+// checkDatabase checks that the database schema is the one the current
+// version expects, since migrations of the upgrade can fail otherwise.
+func checkDatabase(schemaDrift *bool) *Result {
+	r := &Result{Check: "database schema"}
+	switch {
+	case schemaDrift == nil:
+		r.Status = StatusSkip
+		r.Summary = "the instance doesn't report schema drift, check it with 'migrator drift'"
+	case *schemaDrift:
+		r.Status = StatusFail
+		r.Summary = "the database schema differs from the expected schema, fix it with 'migrator drift' first"
+	default:
+		r.Status = StatusPass
+		r.Summary = "the database schema is the expected schema"
+	}
+	return r
+}
+
+// topLevelKeys returns the keys of the JSON object in config, which can
+// contain comments and trailing commas.
+func topLevelKeys(config string) (map[string]bool, error) {
+	var object map[string]json.RawMessage
+	if err := unmarshal(config, &object); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]bool, len(object))
+	for k := range object {
+		keys[k] = true
+	}
+	return keys, nil
+}
+
+func unmarshal(text string, v interface{}) error {
+	if strings.TrimSpace(text) == "" {
+		text = "{}"
+	}
+	plain, errs := jsonx.Parse(text, jsonx.ParseOptions{Comments: true, TrailingCommas: true})
+	if len(errs) > 0 {
+		return errors.Newf("invalid JSON: %v", errs)
+	}
+	return json.Unmarshal(plain, v)
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package upgradecheck
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCheck(t *testing.T) {
+	deprecatedIn := func(v string) *string { return &v }
+	drift := false
+
+	instance := &Instance{
+		Version: "3.40.1",
+		SiteConfig: `{
+  // Comments and trailing commas are allowed.
+  "campaigns.enabled": true,
+  "useJaeger": true,
+  "auth.providers": [
+    {"type": "builtin"},
+    {"type": "crowd", "displayName": "Atlassian Crowd"},
+  ],
+}`,
+		ExternalServices: []ExternalService{
+			{Kind: "GITHUB", DisplayName: "GitHub", Config: `{"url": "https://github.com", "initialRepositoryEnablement": true}`},
+			{Kind: "GITLAB", DisplayName: "GitLab", Config: `{"url": "https://gitlab.com"}`},
+		},
+		Migrations: []Migration{
+			{Description: "done", Team: "search", Component: "db.a", Deprecated: deprecatedIn("3.41.0"), Progress: 1},
+			{Description: "pending", Team: "batches", Component: "db.b", Deprecated: deprecatedIn("3.41.0"), Progress: 0.5},
+			{Description: "later", Team: "code-intel", Component: "db.c", Deprecated: deprecatedIn("3.45.0"), Progress: 0.1},
+			{Description: "failing", Team: "code-intel", Component: "db.d", Progress: 0.1, Errors: []MigrationError{{Message: "first"}, {Message: "last"}}},
+		},
+		SchemaDrift: &drift,
+	}
+
+	report, err := Check(instance, "v3.41.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*Result{
+		{Check: "version", Status: StatusPass, Summary: "3.40.1 to 3.41.0 is a standard upgrade"},
+		{
+			Check:   "out-of-band migrations",
+			Status:  StatusFail,
+			Summary: "1 out-of-band migrations must complete before upgrading",
+			Details: []string{
+				"pending (batches, db.b) is 50% complete, but 3.41.0 no longer supports unmigrated data",
+				"failing (code-intel, db.d) reported an error: last",
+			},
+		},
+		{
+			Check:   "deprecated configuration",
+			Status:  StatusFail,
+			Summary: "1 settings are no longer supported by 3.41.0",
+			Details: []string{
+				`site configuration: "campaigns.enabled" was removed in 3.32.0, use "batchChanges.enabled" instead`,
+				`site configuration: "useJaeger" is deprecated, use "observability.tracing" instead`,
+				`code host connection "GitHub": "initialRepositoryEnablement" is deprecated, use repository permissions or the repository settings instead`,
+			},
+		},
+		{
+			Check:   "auth providers",
+			Status:  StatusFail,
+			Summary: "1 auth providers aren't supported",
+			Details: []string{"auth provider crowd (Atlassian Crowd) isn't supported"},
+		},
+		{Check: "database schema", Status: StatusPass, Summary: "the database schema is the expected schema"},
+	}
+	if diff := cmp.Diff(want, report.Results); diff != "" {
+		t.Errorf("wrong results (-want +have):\n%s", diff)
+	}
+	if report.Ready {
+		t.Error("report is ready despite failed checks")
+	}
+}
+
+func TestCheckVersion(t *testing.T) {
+	for name, tc := range map[string]struct {
+		from, to string
+		want     Status
+	}{
+		"patch":         {from: "3.40.1", to: "3.40.2", want: StatusPass},
+		"minor":         {from: "3.40.1", to: "3.41.0", want: StatusPass},
+		"skipped minor": {from: "3.40.1", to: "3.42.0", want: StatusWarn},
+		"major":         {from: "3.43.0", to: "4.0.0", want: StatusWarn},
+		"downgrade":     {from: "3.40.1", to: "3.39.0", want: StatusFail},
+		"same":          {from: "3.40.1", to: "3.40.1", want: StatusFail},
+		"dev":           {from: "0.0.0+dev", to: "3.40.1", want: StatusSkip},
+		"insiders":      {from: "137540_2022-04-12_8ea9c3b0e72c", to: "3.40.1", want: StatusSkip},
+	} {
+		t.Run(name, func(t *testing.T) {
+			report, err := Check(&Instance{Version: tc.from}, tc.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if have := report.Results[0].Status; have != tc.want {
+				t.Errorf("wrong status: have %s, want %s (%s)", have, tc.want, report.Results[0].Summary)
+			}
+		})
+	}
+
+	if _, err := Check(&Instance{Version: "3.40.1"}, "latest"); err == nil {
+		t.Error("want error for invalid target version, have none")
+	}
+}
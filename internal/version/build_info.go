@@ -0,0 +1,67 @@
+package version
+
+import (
+	"runtime"
+	"sort"
+)
+
+// Libc is the C library src is linked against. It can be supplied as an
+// ldflag for release builds that link against a specific C library, such as
+// musl. Otherwise, it's "static" for builds without cgo, which don't depend on
+// a C library and run on both glibc and musl systems, and "system" for builds
+// with cgo.
+var Libc = ""
+
+// BuildInfo describes the build of src.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"goVersion"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	Libc      string `json:"libc"`
+	// Artifact is the name of the release artifact for the OS and
+	// architecture.
+	Artifact string `json:"artifact"`
+	// Settings are the build settings recorded by the Go toolchain, such as
+	// the ldflags, CGO_ENABLED and the revision that was built, if the
+	// toolchain records them.
+	Settings []BuildSetting `json:"settings,omitempty"`
+}
+
+// BuildSetting is a build setting recorded by the Go toolchain.
+type BuildSetting struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ReadBuildInfo returns the build information of the running binary.
+func ReadBuildInfo() BuildInfo {
+	info := BuildInfo{
+		Version:   BuildTag,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		Libc:      Libc,
+		Artifact:  Artifact(runtime.GOOS, runtime.GOARCH),
+		Settings:  buildSettings(),
+	}
+	if info.Libc == "" {
+		info.Libc = "static"
+		if cgoEnabled {
+			info.Libc = "system"
+		}
+	}
+	sort.Slice(info.Settings, func(i, j int) bool { return info.Settings[i].Key < info.Settings[j].Key })
+	return info
+}
+
+// Artifact returns the name of the release artifact of src for the given OS
+// and architecture, as they're published on GitHub and served by Sourcegraph
+// instances under /.api/src-cli/.
+func Artifact(goos, goarch string) string {
+	name := "src_" + goos + "_" + goarch
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
@@ -0,0 +1,35 @@
+package version
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestArtifact(t *testing.T) {
+	for _, tc := range []struct {
+		goos, goarch string
+		want         string
+	}{
+		{goos: "linux", goarch: "amd64", want: "src_linux_amd64"},
+		{goos: "linux", goarch: "arm64", want: "src_linux_arm64"},
+		{goos: "darwin", goarch: "arm64", want: "src_darwin_arm64"},
+		{goos: "windows", goarch: "amd64", want: "src_windows_amd64.exe"},
+	} {
+		if have := Artifact(tc.goos, tc.goarch); have != tc.want {
+			t.Errorf("%s/%s: want %q, have %q", tc.goos, tc.goarch, tc.want, have)
+		}
+	}
+}
+
+func TestReadBuildInfo(t *testing.T) {
+	info := ReadBuildInfo()
+	if info.OS != runtime.GOOS || info.Arch != runtime.GOARCH {
+		t.Errorf("wrong platform %s/%s", info.OS, info.Arch)
+	}
+	if info.Artifact != Artifact(runtime.GOOS, runtime.GOARCH) {
+		t.Errorf("wrong artifact %q", info.Artifact)
+	}
+	if info.Libc == "" {
+		t.Error("libc is empty")
+	}
+}
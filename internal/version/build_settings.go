@@ -0,0 +1,18 @@
+//go:build go1.18
+// +build go1.18
+
+package version
+
+import "runtime/debug"
+
+func buildSettings() []BuildSetting {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+	settings := make([]BuildSetting, 0, len(info.Settings))
+	for _, s := range info.Settings {
+		settings = append(settings, BuildSetting{Key: s.Key, Value: s.Value})
+	}
+	return settings
+}
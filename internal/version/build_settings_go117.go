@@ -0,0 +1,10 @@
+//go:build !go1.18
+// +build !go1.18
+
+package version
+
+// buildSettings returns no settings, since Go toolchains before 1.18 don't
+// record them.
+func buildSettings() []BuildSetting {
+	return nil
+}
@@ -0,0 +1,160 @@
+// Package batches is the Go API of the batch changes support of src. It
+// resolves batch specs, determines their workspaces, executes their steps in
+// Docker and uploads the resulting changeset specs to Sourcegraph, as `src
+// batch preview` and `src batch apply` do, so that other tools can embed
+// batch execution without running the src binary.
+//
+// The API is meant to be stable: it only exposes its own types, apart from the
+// batch spec and changeset spec types of
+// github.com/sourcegraph/sourcegraph/lib/batches.
+//
+// A typical use resolves, executes and uploads a batch spec in turn:
+//
+//	c, err := batches.New(ctx, batches.Options{
+//		Endpoint:    "https://sourcegraph.example.com",
+//		AccessToken: token,
+//		CacheDir:    cacheDir,
+//	})
+//	spec, err := c.ParseSpec(data, dir, nil)
+//	workspaces, err := c.ResolveWorkspaces(ctx, spec)
+//	changesetSpecs, err := c.Execute(ctx, spec, workspaces)
+//	batchSpec, err := c.CreateBatchSpec(ctx, "", spec, changesetSpecs)
+package batches
+
+import (
+	"context"
+	"io"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+)
+
+// Options configure a Client.
+type Options struct {
+	// Endpoint is the URL of the Sourcegraph instance, and AccessToken the
+	// access token used to authenticate to it.
+	Endpoint          string
+	AccessToken       string
+	AdditionalHeaders map[string]string
+
+	// CacheDir is the directory the repository archives and the results of
+	// steps are cached in. It's required to execute batch specs.
+	CacheDir string
+	// TempDir is the directory temporary files are created in. It defaults
+	// to the temporary directory of the system.
+	TempDir string
+
+	// Parallelism is the number of workspaces executed at the same time. It
+	// defaults to GOMAXPROCS.
+	Parallelism int
+	// Timeout is the maximum duration of a single step. It defaults to an
+	// hour.
+	Timeout time.Duration
+	// Workspace is the workspace mode, as with the -workspace flag of src:
+	// "auto", "bind", "volume", or "remote". It defaults to "auto".
+	Workspace string
+	// Sandbox is the sandbox profile of the step containers: "strict",
+	// "default", or "off". It defaults to "default".
+	Sandbox string
+	// RegistryMirror is the registry mirror that images from Docker Hub are
+	// pulled through, if any.
+	RegistryMirror string
+
+	// AllowUnsupported and AllowIgnored include repositories on unsupported
+	// code hosts and repositories with a .batchignore file.
+	AllowUnsupported bool
+	AllowIgnored     bool
+	// ClearCache executes all steps again instead of using cached results.
+	ClearCache bool
+	// SkipErrors makes Execute return the changeset specs of the workspaces
+	// that succeeded together with the errors of the others, instead of
+	// failing on the first error.
+	SkipErrors bool
+
+	// Progress, if set, is notified about the execution of workspaces.
+	Progress Progress
+}
+
+// Client resolves, executes and uploads batch specs. A Client is configured
+// for the batch spec it parsed last, so it handles one batch spec at a time.
+type Client struct {
+	opts    Options
+	sandbox executor.SandboxProfile
+	client  api.Client
+	svc     *service.Service
+}
+
+// New returns a Client for the Sourcegraph instance of the options. It
+// queries the version of the instance, to use the features of batch specs it
+// supports.
+func New(ctx context.Context, opts Options) (*Client, error) {
+	if opts.Endpoint == "" {
+		return nil, errors.New("no Sourcegraph endpoint given")
+	}
+	if opts.TempDir == "" {
+		opts.TempDir = os.TempDir()
+	}
+	if opts.Parallelism <= 0 {
+		opts.Parallelism = runtime.GOMAXPROCS(0)
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 60 * time.Minute
+	}
+	if opts.Workspace == "" {
+		opts.Workspace = "auto"
+	}
+	sandbox, err := executor.ParseSandboxProfile(opts.Sandbox)
+	if err != nil {
+		return nil, err
+	}
+
+	client := api.NewClient(api.ClientOpts{
+		Endpoint:          opts.Endpoint,
+		AccessToken:       opts.AccessToken,
+		AdditionalHeaders: opts.AdditionalHeaders,
+		Out:               io.Discard,
+	})
+	svc := service.New(&service.Opts{
+		AllowUnsupported: opts.AllowUnsupported,
+		AllowIgnored:     opts.AllowIgnored,
+		Client:           client,
+		RegistryMirror:   opts.RegistryMirror,
+	})
+	if err := svc.DetermineFeatureFlags(ctx); err != nil {
+		return nil, err
+	}
+
+	return &Client{opts: opts, sandbox: sandbox, client: client, svc: svc}, nil
+}
+
+// Spec is a parsed batch spec.
+type Spec struct {
+	// Spec is the parsed batch spec.
+	Spec *batcheslib.BatchSpec
+	// Raw is the batch spec with everything src resolves itself resolved,
+	// which is what's uploaded to Sourcegraph.
+	Raw string
+
+	// repos are the repositories ResolveWorkspaces resolved for the spec.
+	repos []*graphql.Repository
+}
+
+// ParseSpec resolves and parses the batch spec in data, with the given values
+// of its parameters. dir is the directory of the batch spec, which its
+// includes, build contexts and env files are relative to. Environment
+// variables are looked up in the environment of the process.
+func (c *Client) ParseSpec(data []byte, dir string, params map[string]string) (*Spec, error) {
+	spec, raw, err := c.svc.ResolveBatchSpec(data, dir, params, os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+	return &Spec{Spec: spec, Raw: string(raw)}, nil
+}
@@ -0,0 +1,71 @@
+package batches
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data": {"site": {"productVersion": "3.42.0"}}}`)
+	}))
+	t.Cleanup(ts.Close)
+
+	c, err := New(context.Background(), Options{Endpoint: ts.URL, CacheDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(context.Background(), Options{}); err == nil {
+		t.Error("want error for missing endpoint, have none")
+	}
+
+	if _, err := New(context.Background(), Options{Endpoint: "https://example.com", Sandbox: "unknown"}); err == nil {
+		t.Error("want error for unknown sandbox profile, have none")
+	}
+
+	c := newTestClient(t)
+	if c.opts.Parallelism <= 0 || c.opts.Timeout <= 0 || c.opts.Workspace != "auto" {
+		t.Errorf("defaults not applied: %+v", c.opts)
+	}
+}
+
+func TestClient_ParseSpec(t *testing.T) {
+	c := newTestClient(t)
+
+	spec, err := c.ParseSpec([]byte(`
+name: ${{ params.name }}
+parameters:
+  name:
+    default: hello
+on:
+  - repository: github.com/sourcegraph/src-cli
+steps:
+  - run: echo hello
+    container: alpine:3
+changesetTemplate:
+  title: Hello
+  body: Hello
+  branch: hello
+  commit:
+    message: Hello
+`), t.TempDir(), map[string]string{"name": "world"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := spec.Spec.Name, "world"; have != want {
+		t.Errorf("wrong name: have %q, want %q", have, want)
+	}
+
+	if _, err := c.ParseSpec([]byte(`name: invalid name`), t.TempDir(), nil); err == nil {
+		t.Error("want error for invalid spec, have none")
+	}
+}
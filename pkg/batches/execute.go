@@ -0,0 +1,110 @@
+package batches
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/batches/workspace"
+)
+
+// Progress is notified about the execution of the workspaces of a batch spec.
+// Its methods are called concurrently for workspaces that are executed at the
+// same time.
+type Progress interface {
+	// WorkspaceStarted is called when the steps of a workspace start to be
+	// executed.
+	WorkspaceStarted(repo Repository, path string)
+	// WorkspaceFinished is called when the steps of a workspace are done,
+	// with the error they failed with, if any.
+	WorkspaceFinished(repo Repository, path string, err error)
+}
+
+// Execute executes the steps of the batch spec in the given workspaces and
+// returns the changeset specs built from the results, together with the
+// changeset specs of the changesets the batch spec imports. The results of
+// workspaces whose steps were executed before are taken from the cache in
+// Options.CacheDir.
+func (c *Client) Execute(ctx context.Context, spec *Spec, workspaces []*Workspace) ([]*batcheslib.ChangesetSpec, error) {
+	if c.opts.CacheDir == "" {
+		return nil, errors.New("no cache directory given to execute the batch spec in")
+	}
+
+	var creator workspace.Creator
+	if c.svc.HasDockerImages(spec.Spec) {
+		images, err := c.svc.EnsureDockerImages(ctx, spec.Spec, c.opts.Parallelism, func(done, total int) {})
+		if err != nil {
+			return nil, err
+		}
+
+		creator = workspace.NewCreator(ctx, c.opts.Workspace, c.opts.CacheDir, c.opts.TempDir, images, nil)
+		if creator.Type() != workspace.CreatorTypeBind {
+			if _, err := c.svc.EnsureImage(ctx, workspace.DockerVolumeWorkspaceImage); err != nil {
+				return nil, err
+			}
+		}
+		if creator.Type() == workspace.CreatorTypeRemote && c.sandbox == executor.SandboxStrict {
+			return nil, errors.New("the strict sandbox profile can't be used with a remote Docker host")
+		}
+	}
+
+	coord := c.svc.NewCoordinator(executor.NewCoordinatorOpts{
+		Creator:     creator,
+		CacheDir:    c.opts.CacheDir,
+		ClearCache:  c.opts.ClearCache,
+		SkipErrors:  c.opts.SkipErrors,
+		Parallelism: c.opts.Parallelism,
+		Timeout:     c.opts.Timeout,
+		TempDir:     c.opts.TempDir,
+		Sandbox:     c.sandbox,
+	})
+
+	repoWorkspaces := make([]service.RepoWorkspace, len(workspaces))
+	for i, w := range workspaces {
+		repoWorkspaces[i] = w.ws
+	}
+	tasks := c.svc.BuildTasks(ctx, spec.Spec, repoWorkspaces)
+
+	uncached, cachedSpecs, err := coord.CheckCache(ctx, tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	freshSpecs, _, err := coord.Execute(ctx, uncached, spec.Spec, &taskUI{progress: c.opts.Progress})
+	if err != nil && (!c.opts.SkipErrors || errors.Is(err, context.Canceled)) {
+		return nil, err
+	}
+	return append(cachedSpecs, freshSpecs...), err
+}
+
+// taskUI reports the execution of tasks to a Progress, which may be nil.
+type taskUI struct {
+	progress Progress
+}
+
+var _ executor.TaskExecutionUI = &taskUI{}
+
+func (ui *taskUI) Start([]*executor.Task) {}
+func (ui *taskUI) Success()               {}
+func (ui *taskUI) Failed(err error)       {}
+
+func (ui *taskUI) TaskStarted(t *executor.Task) {
+	if ui.progress != nil {
+		ui.progress.WorkspaceStarted(newRepository(t.Repository), t.Path)
+	}
+}
+
+func (ui *taskUI) TaskFinished(t *executor.Task, err error) {
+	if ui.progress != nil {
+		ui.progress.WorkspaceFinished(newRepository(t.Repository), t.Path, err)
+	}
+}
+
+func (ui *taskUI) TaskChangesetSpecsBuilt(*executor.Task, []*batcheslib.ChangesetSpec) {}
+
+func (ui *taskUI) StepsExecutionUI(*executor.Task) executor.StepsExecutionUI {
+	return executor.NoopStepsExecUI{}
+}
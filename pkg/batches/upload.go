@@ -0,0 +1,59 @@
+package batches
+
+import (
+	"context"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+)
+
+// BatchSpec is a batch spec that was created on the Sourcegraph instance.
+type BatchSpec struct {
+	// ID is the GraphQL ID of the batch spec.
+	ID string
+	// PreviewURL is the URL the batch spec can be previewed and applied at.
+	PreviewURL string
+}
+
+// CreateBatchSpec uploads the changeset specs and creates the batch spec with
+// them in the namespace, which is the user or organization name the batch
+// change belongs to. An empty namespace is the user of the access token.
+func (c *Client) CreateBatchSpec(ctx context.Context, namespace string, spec *Spec, changesetSpecs []*batcheslib.ChangesetSpec) (*BatchSpec, error) {
+	namespaceID, err := c.svc.ResolveNamespace(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.svc.ValidateChangesetSpecs(spec.repos, changesetSpecs); err != nil {
+		return nil, err
+	}
+
+	var ids []graphql.ChangesetSpecID
+	if len(changesetSpecs) > 0 {
+		ids, err = c.svc.CreateChangesetSpecs(ctx, changesetSpecs, service.CreateChangesetSpecsOpts{
+			Parallelism: c.opts.Parallelism,
+			Retries:     3,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	id, url, err := c.svc.CreateBatchSpec(ctx, namespaceID, spec.Raw, ids)
+	if err != nil {
+		return nil, err
+	}
+	return &BatchSpec{ID: string(id), PreviewURL: c.opts.Endpoint + url}, nil
+}
+
+// Apply applies the batch spec with the given ID and returns the URL of the
+// batch change.
+func (c *Client) Apply(ctx context.Context, batchSpecID string) (string, error) {
+	batch, err := c.svc.ApplyBatchChange(ctx, graphql.BatchSpecID(batchSpecID))
+	if err != nil {
+		return "", err
+	}
+	return c.opts.Endpoint + batch.URL, nil
+}
@@ -0,0 +1,78 @@
+package batches
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+)
+
+// Repository is a repository on the Sourcegraph instance.
+type Repository struct {
+	// ID is the GraphQL ID of the repository.
+	ID   string
+	Name string
+	// Branch is the branch the steps are executed on, and Commit the commit
+	// it pointed to when the repository was resolved.
+	Branch string
+	Commit string
+}
+
+func newRepository(r *graphql.Repository) Repository {
+	return Repository{
+		ID:     r.ID,
+		Name:   r.Name,
+		Branch: r.BaseRef(),
+		Commit: r.Rev(),
+	}
+}
+
+// Workspace is a directory of a repository that the steps of a batch spec are
+// executed in.
+type Workspace struct {
+	Repository Repository
+	// Path is the directory relative to the root of the repository. It's
+	// empty for the root.
+	Path string
+	// Steps are the steps of the batch spec that are executed in the
+	// workspace.
+	Steps []batcheslib.Step
+
+	ws service.RepoWorkspace
+}
+
+// ResolveWorkspaces resolves the repositories the batch spec applies to and
+// the workspaces in them. Repositories on unsupported code hosts and
+// repositories with a .batchignore file are left out, unless
+// Options.AllowUnsupported or Options.AllowIgnored are set.
+func (c *Client) ResolveWorkspaces(ctx context.Context, spec *Spec) ([]*Workspace, error) {
+	repos, err := c.svc.ResolveRepositories(ctx, spec.Spec)
+	if err != nil {
+		var unsupported batches.UnsupportedRepoSet
+		var ignored batches.IgnoredRepoSet
+		if !errors.As(err, &unsupported) && !errors.As(err, &ignored) {
+			return nil, errors.Wrap(err, "resolving repositories")
+		}
+	}
+	spec.repos = repos
+
+	repoWorkspaces, err := c.svc.DetermineWorkspaces(ctx, repos, spec.Spec)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaces := make([]*Workspace, 0, len(repoWorkspaces))
+	for _, ws := range repoWorkspaces {
+		workspaces = append(workspaces, &Workspace{
+			Repository: newRepository(ws.Repo),
+			Path:       ws.Path,
+			Steps:      ws.Steps,
+			ws:         ws,
+		})
+	}
+	return workspaces, nil
+}